@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// label of the account of API_TOKEN, in logs and events
+const defaultAccount = "default"
+
+// Account is a further transferwise account, like a partner's, checked by the same instance along the one of
+// API_TOKEN, with its own API token, profile, pairs, margins and notification targets
+type Account struct {
+	// env variable holding the API token, resolved like the other secrets, e.g. PARTNER_API_TOKEN
+	TokenEnv string  `json:"tokenEnv"`
+	Profile  *uint64 `json:"profile,omitempty"`
+
+	// comma separated mail recipients of the account's events, TO_MAIL when empty
+	ToMail        string               `json:"toMail,omitempty"`
+	Notifications NotificationsConfig  `json:"notifications"`
+	Pairs         map[string]Overrides `json:"pairs"`
+	Purposes      map[string]Overrides `json:"purposes"`
+	Transfers     map[string]Overrides `json:"transfers"`
+
+	// API token resolved from TokenEnv when the config file is loaded
+	token string
+}
+
+// the account being checked, the globals of the default account swapped for its own while it is
+var currentAccount = struct {
+	sync.Mutex
+	name string
+}{name: defaultAccount}
+
+// held for writing while an account runs, see withAccount, and for reading while the default account runs, see
+// withDefaultAccount, so that the jobs and handlers of the default account never see another account's globals
+var accountMutex sync.RWMutex
+
+func getCurrentAccount() string {
+	currentAccount.Lock()
+	defer currentAccount.Unlock()
+	return currentAccount.name
+}
+
+// Validate the accounts of the config file and resolve their API tokens
+func loadAccounts(c Config) error {
+	for name, account := range c.Accounts {
+		if name == "" || name == defaultAccount || strings.ContainsAny(name, " []") {
+			return fmt.Errorf("invalid account name %q in config file", name)
+		}
+		if account.TokenEnv == "" {
+			return fmt.Errorf("missing tokenEnv for account %v in config file", name)
+		}
+		token, err := resolveSecret(account.TokenEnv, os.Getenv(account.TokenEnv))
+		if err != nil {
+			return fmt.Errorf("account %v: %v", name, err)
+		}
+		if token == "" {
+			return fmt.Errorf("account %v: env variable %v is not set", name, account.TokenEnv)
+		}
+		if account.Profile != nil && *account.Profile == 0 {
+			return fmt.Errorf("invalid profile 0 for account %v in config file", name)
+		}
+		account.Pairs, err = normalizePairs(account.Pairs)
+		if err != nil {
+			return fmt.Errorf("account %v: %v", name, err)
+		}
+		err = validateOverrides(Config{Pairs: account.Pairs, Purposes: account.Purposes, Transfers: account.Transfers,
+			Notifications: account.Notifications})
+		if err != nil {
+			return fmt.Errorf("account %v: %v", name, err)
+		}
+		account.token = token
+		c.Accounts[name] = account
+	}
+	return nil
+}
+
+// The names of the configured accounts, sorted
+func accountNames() []string {
+	accounts := getConfig().Accounts
+	names := make([]string, 0, len(accounts))
+	for name := range accounts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Run fn for the default account, then for each configured account in turn
+func forEachAccount(fn func()) {
+	withDefaultAccount(fn)
+	for _, name := range accountNames() {
+		withAccount(name, fn)
+	}
+}
+
+// Run fn with the API token, profile and per pair, purpose and transfer settings of the account instead of the
+// default ones, its log lines prefixed with its name. Accounts run one at a time, and config reloads wait for them
+func withAccount(name string, fn func()) {
+	account, ok := getConfig().Accounts[name]
+	if !ok {
+		log.Printf("withAccount: unknown account %v", name)
+		return
+	}
+
+	accountMutex.Lock()
+	defer accountMutex.Unlock()
+
+	token, profile, prefix := apiTokenVar, profileIdVar, log.Prefix()
+	config.Lock()
+	base := config.current
+	accountConfig := base
+	accountConfig.Pairs, accountConfig.Purposes, accountConfig.Transfers = account.Pairs, account.Purposes, account.Transfers
+	config.current = accountConfig
+	config.Unlock()
+	apiTokenVar = account.token
+	if account.Profile != nil {
+		profileIdVar = formatUint(*account.Profile)
+	}
+	currentAccount.Lock()
+	currentAccount.name = name
+	currentAccount.Unlock()
+	log.SetPrefix(prefix + "[" + name + "] ")
+
+	defer func() {
+		log.SetPrefix(prefix)
+		currentAccount.Lock()
+		currentAccount.name = defaultAccount
+		currentAccount.Unlock()
+		apiTokenVar, profileIdVar = token, profile
+		config.Lock()
+		config.current = base
+		config.Unlock()
+	}()
+	fn()
+}
+
+// Run fn with the API token, profile and settings of the default account, waiting for the account running if any.
+// Jobs and handlers run through it, and must not nest it nor call withAccount from within it
+func withDefaultAccount(fn func()) {
+	accountMutex.RLock()
+	defer accountMutex.RUnlock()
+	fn()
+}
+
+// Run fn as the named account, the default one when empty, failing when the account isn't configured anymore
+func runAsAccount(name string, fn func()) error {
+	if name == "" || name == defaultAccount {
+		withDefaultAccount(fn)
+		return nil
+	}
+	if _, ok := getConfig().Accounts[name]; !ok {
+		return fmt.Errorf("unknown account %v", name)
+	}
+	withAccount(name, fn)
+	return nil
+}
+
+// The job run through withDefaultAccount, for the scheduler
+func defaultAccountJob(job func()) func() {
+	return func() {
+		withDefaultAccount(job)
+	}
+}
+
+// The handler run through withDefaultAccount
+func defaultAccountHandler(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		withDefaultAccount(func() {
+			handler(w, r)
+		})
+	}
+}
+
+// The notification routes of the event's account, the default ones for the default account or when it has none
+func accountRoutes(event Event) map[EventKind][]string {
+	c := getConfig()
+	if account, ok := c.Accounts[event.Account]; ok && len(account.Notifications.Routes) > 0 {
+		return account.Notifications.Routes
+	}
+	return c.Notifications.Routes
+}
+
+// The mail recipients of the event's account, TO_MAIL for the default account or when it has none
+func accountMailRecipients(event Event) []string {
+	account, ok := getConfig().Accounts[event.Account]
+	if !ok || account.ToMail == "" {
+		return getMailRecipients()
+	}
+	return splitMailRecipients(account.ToMail)
+}
+
+// The API tokens of the configured accounts, redacted like the other secrets
+func accountTokens() []string {
+	var tokens []string
+	for _, account := range getConfig().Accounts {
+		tokens = append(tokens, account.token)
+	}
+	return tokens
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"transferwisely/mocks"
+)
+
+func TestLoadAccounts(t *testing.T) {
+	defer func(file string, c Config) { configFileVar, config.current = file, c }(configFileVar, getConfig())
+	defer os.Unsetenv("PARTNER_API_TOKEN")
+	dir, _ := ioutil.TempDir("", "transferwisely")
+	defer os.RemoveAll(dir)
+	configFileVar = filepath.Join(dir, "config.json")
+
+	tests := []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{"valid", `{"accounts": {"partner": {"tokenEnv": "PARTNER_API_TOKEN", "pairs": {"EUR-INR": {"margin": 0.2}}}}}`, ""},
+		{"reserved name", `{"accounts": {"default": {"tokenEnv": "PARTNER_API_TOKEN"}}}`, "invalid account name"},
+		{"without token env", `{"accounts": {"partner": {}}}`, "missing tokenEnv"},
+		{"token not set", `{"accounts": {"partner": {"tokenEnv": "UNSET_API_TOKEN"}}}`, "UNSET_API_TOKEN is not set"},
+		{"invalid overrides", `{"accounts": {"partner": {"tokenEnv": "PARTNER_API_TOKEN", "pairs": {"EUR-INR": {"interval": 0}}}}}`,
+			"account partner: invalid interval 0"},
+	}
+	_ = os.Setenv("PARTNER_API_TOKEN", "partner-token")
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_ = ioutil.WriteFile(configFileVar, []byte(test.config), 0600)
+			err := loadConfig()
+			if test.err != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), test.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "partner-token", getConfig().Accounts["partner"].token)
+			assert.Equal(t, []string{"partner"}, accountNames())
+		})
+	}
+}
+
+func TestWithAccount(t *testing.T) {
+	defer func(c Config, token string, profile string) { config.current, apiTokenVar, profileIdVar = c, token, profile }(
+		getConfig(), apiTokenVar, profileIdVar)
+	defer func(n []Notifier, limit string) { Notifiers, notifyRateLimitVar = n, limit }(Notifiers, notifyRateLimitVar)
+	notifyRateLimitVar = "0"
+	fake := &fakeNotifier{}
+	Notifiers = []Notifier{fake}
+
+	margin, profile := decimalOf(0.2), uint64(42)
+	apiTokenVar, profileIdVar = "my-token", "1"
+	config.current = Config{
+		Pairs: map[string]Overrides{"GBP-INR": {}},
+		Accounts: map[string]Account{"partner": {
+			Profile:       &profile,
+			ToMail:        "partner@example.com, other@example.com",
+			Notifications: NotificationsConfig{Routes: map[EventKind][]string{EventRebooked: {"fake"}, EventError: {"email"}}},
+			Pairs:         map[string]Overrides{"EUR-INR": {Margin: &margin}},
+			token:         "partner-token",
+		}},
+	}
+
+	var tokens []string
+	mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+		tokens = append(tokens, req.Header.Get("Authorization"))
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(`[{"rate": 1.5}]`))}, nil
+	}
+
+	var accounts []string
+	forEachAccount(func() {
+		accounts = append(accounts, getCurrentAccount())
+		_, _ = getLiveRate("EUR", "INR")
+		if getCurrentAccount() != defaultAccount {
+			assert.Equal(t, "42", profileIdVar)
+			assert.Contains(t, getConfig().Pairs, "EUR-INR")
+			assert.NotContains(t, getConfig().Pairs, "GBP-INR")
+			notify(Event{Kind: EventRebooked, Subject: "Rebooked"})
+			notify(Event{Kind: EventError, Subject: "Error"})
+		}
+	})
+	assert.Equal(t, []string{defaultAccount, "partner"}, accounts)
+	assert.Equal(t, []string{"Bearer my-token", "Bearer partner-token"}, tokens)
+
+	// the default account's globals are back
+	assert.Equal(t, defaultAccount, getCurrentAccount())
+	assert.Equal(t, "my-token", apiTokenVar)
+	assert.Equal(t, "1", profileIdVar)
+	assert.Contains(t, getConfig().Pairs, "GBP-INR")
+
+	assert.Len(t, fake.events, 1, "routed by the account's routes")
+	assert.Equal(t, "[partner] Rebooked", fake.events[0].Subject)
+	assert.Equal(t, "partner", fake.events[0].Account)
+	assert.Equal(t, []string{"partner@example.com", "other@example.com"}, accountMailRecipients(fake.events[0]))
+	assert.Equal(t, "[REDACTED]", redactSecrets("partner-token"))
+}
+
+func TestDefaultAccountWaitsForAccount(t *testing.T) {
+	defer func(c Config, token string) { config.current, apiTokenVar = c, token }(getConfig(), apiTokenVar)
+	apiTokenVar = "my-token"
+	config.current = Config{Accounts: map[string]Account{"partner": {token: "partner-token"}}}
+
+	seen := make(chan string, 1)
+	withAccount("partner", func() {
+		go withDefaultAccount(func() { seen <- apiTokenVar })
+		select {
+		case token := <-seen:
+			t.Errorf("the default account ran with %v while the partner account was", token)
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+	assert.Equal(t, "my-token", <-seen)
+}
+
+func TestApproveAccountProposal(t *testing.T) {
+	defer func(c Config, token string, file string) { config.current, apiTokenVar, stateFileVar = c, token, file }(
+		getConfig(), apiTokenVar, stateFileVar)
+	defer func(n []Notifier) { Notifiers = n }(Notifiers)
+	defer func(cooldown string) { rebookCooldownVar = cooldown }(rebookCooldownVar)
+	Notifiers = []Notifier{&fakeNotifier{}}
+	rebookCooldownVar = "0"
+	dir, _ := ioutil.TempDir("", "transferwisely")
+	defer os.RemoveAll(dir)
+	stateFileVar = filepath.Join(dir, "state.json")
+	apiTokenVar = "my-token"
+	config.current = Config{Accounts: map[string]Account{"partner": {token: "partner-token"}}}
+
+	var tokens []string
+	mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+		body := `{"id": 1, "status": "incoming_payment_waiting"}`
+		if req.Method == http.MethodPost && strings.HasSuffix(req.URL.String(), transfersAPIPath) {
+			tokens = append(tokens, req.Header.Get("Authorization"))
+			body = `{"id": 2, "rate": 0.7, "sourceCurrency": "JPY", "targetCurrency": "INR"}`
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(body))}, nil
+	}
+
+	now := time.Now().UTC()
+	transfer := Transfer{Id: 1, Profile: 1, Rate: 0.69, SourceAmount: decimalOf(1000), SourceCurrency: "JPY", TargetCurrency: "INR"}
+	assert.NoError(t, updateState(func(state *State) error {
+		for _, proposal := range []Proposal{{Id: "mine"}, {Id: "partner's", Account: "partner"}, {Id: "removed", Account: "gone"}} {
+			proposal.Status, proposal.ExpiresAt, proposal.Transfer = proposalPending, now.Add(time.Hour), transfer
+			proposal.Quote = QuoteDetail{Id: "quote-" + proposal.Id, Rate: 0.7}
+			state.Proposals = append(state.Proposals, proposal)
+		}
+		return nil
+	}))
+
+	for i, id := range []string{"mine", "partner's"} {
+		_, err := approveProposal(id, now.Add(time.Duration(i)*time.Minute))
+		assert.NoError(t, err, id)
+	}
+	assert.Equal(t, []string{"Bearer my-token", "Bearer partner-token"}, tokens, "booked with the token of its account")
+	assert.Equal(t, "my-token", apiTokenVar)
+
+	_, err := approveProposal("removed", now)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown account gone")
+	assert.Len(t, tokens, 2)
+}
+
+func TestAccountsKeepTheirOwnChecksAndGuardrails(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "transferwisely")
+	defer os.RemoveAll(dir)
+	defer func(c Config, token string, file string) { config.current, apiTokenVar, stateFileVar = c, token, file }(
+		getConfig(), apiTokenVar, stateFileVar)
+	defer func() {
+		pairChecks.Lock()
+		pairChecks.checkedAt = map[pairCheck]time.Time{}
+		pairChecks.Unlock()
+	}()
+	stateFileVar = filepath.Join(dir, "state.json")
+	apiTokenVar = "my-token"
+	config.current = Config{Accounts: map[string]Account{"partner": {token: "partner-token"}}}
+	now := time.Now().UTC()
+
+	t.Run("checks of the same pair", func(t *testing.T) {
+		assert.True(t, isCheckDue("EUR-INR", 5, now))
+		withAccount("partner", func() {
+			assert.True(t, isCheckDue("EUR-INR", 5, now), "not checked by the partner account yet")
+			assert.False(t, isCheckDue("EUR-INR", 5, now.Add(time.Minute)))
+		})
+		assert.False(t, isCheckDue("EUR-INR", 5, now.Add(time.Minute)))
+	})
+
+	t.Run("guardrails", func(t *testing.T) {
+		assert.NoError(t, recordRebook(now))
+		assert.Error(t, checkRebookAllowed(rebookReasonBetterRate, now))
+		withAccount("partner", func() {
+			assert.NoError(t, checkRebookAllowed(rebookReasonBetterRate, now), "the default account's re-booking doesn't count")
+			assert.NoError(t, recordRebook(now))
+			assert.Error(t, checkRebookAllowed(rebookReasonBetterRate, now))
+		})
+		state, _ := loadState()
+		assert.Len(t, state.Rebooks, 1)
+		assert.Len(t, state.AccountRebooks["partner"], 1)
+	})
+
+	t.Run("interrupted re-booking reconciled with the account's token", func(t *testing.T) {
+		var tokens []string
+		mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+			tokens = append(tokens, req.Header.Get("Authorization"))
+			return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(`[]`))}, nil
+		}
+		withAccount("partner", func() {
+			assert.NoError(t, setPendingRebook(&PendingRebook{CustomerTransactionId: "tx-1", OldTransfer: Transfer{Id: 1},
+				Account: "partner"}))
+		})
+		state, _ := loadState()
+		assert.Nil(t, state.PendingRebook, "the default account has no re-booking in progress")
+		assert.NotNil(t, state.AccountPendingRebooks["partner"])
+
+		assert.NoError(t, reconcilePendingRebook())
+		assert.NotEmpty(t, tokens)
+		for _, token := range tokens {
+			assert.Equal(t, "Bearer partner-token", token)
+		}
+		state, _ = loadState()
+		assert.Empty(t, state.AccountPendingRebooks)
+	})
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/go-co-op/gocron"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// activities transferwise returns per page, its maximum
+const activitiesPageSize = 100
+
+// activities shown on the dashboard
+const dashboardActivities = 20
+
+// Activity is an entry of the profile's activity feed on wise.com: a transfer created, cancelled or completed, a
+// conversion between balances, a card payment, a deposit, see
+// https://docs.wise.com/api-docs/api-reference/activity
+type Activity struct {
+	Id              string           `json:"id"`
+	Type            string           `json:"type"`
+	Resource        ActivityResource `json:"resource"`
+	Title           string           `json:"title"`
+	Description     string           `json:"description"`
+	PrimaryAmount   string           `json:"primaryAmount"`
+	SecondaryAmount string           `json:"secondaryAmount"`
+	Status          string           `json:"status"`
+	CreatedOn       time.Time        `json:"createdOn"`
+	UpdatedOn       time.Time        `json:"updatedOn"`
+}
+
+type ActivityResource struct {
+	Type string `json:"type"`
+	Id   string `json:"id"`
+}
+
+type ActivitiesPage struct {
+	Cursor     string     `json:"cursor"`
+	Activities []Activity `json:"activities"`
+}
+
+// the titles and descriptions come with <strong> and <positive> markup for wise.com
+var activityMarkup = regexp.MustCompile(`<[^>]*>`)
+
+// Plain text of the activity's markup
+func activityText(s string) string {
+	return html.UnescapeString(activityMarkup.ReplaceAllString(s, ""))
+}
+
+// activities of the profile as last polled, shown on the dashboard
+var activityFeed = struct {
+	sync.Mutex
+	activities []Activity
+}{}
+
+// List the profile's activities since the given time, zero for all of them, most recent first, up to limit of them
+func getActivities(profile uint64, since time.Time, limit int) ([]Activity, error) {
+	path := strings.Replace(activitiesAPIPath, "{profileId}", strconv.FormatUint(profile, 10), 1)
+
+	var activities []Activity
+	cursor := ""
+	for len(activities) < limit {
+		params := url.Values{"size": {strconv.Itoa(activitiesPageSize)}}
+		if !since.IsZero() {
+			params.Set("since", since.UTC().Format(time.RFC3339))
+		}
+		if cursor != "" {
+			params.Set("nextCursor", cursor)
+		}
+		url := &url.URL{RawQuery: params.Encode(), Host: hostVar, Scheme: "https", Path: path}
+
+		var page ActivitiesPage
+		_, err := callExternalAPI(http.MethodGet, url.String(), nil, &page)
+		if err != nil {
+			return nil, fmt.Errorf("error GET activities API: %w", err)
+		}
+		activities = append(activities, page.Activities...)
+		if page.Cursor == "" || len(page.Activities) == 0 {
+			break
+		}
+		cursor = page.Cursor
+	}
+
+	sort.SliceStable(activities, func(i, j int) bool { return activities[i].CreatedOn.After(activities[j].CreatedOn) })
+	if len(activities) > limit {
+		activities = activities[:limit]
+	}
+	return activities, nil
+}
+
+func getActivityInterval() (uint64, error) {
+	interval, err := strconv.ParseUint(activityIntervalVar, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value for ACTIVITY_INTERVAL: %v", err)
+	}
+	return interval, nil
+}
+
+// Poll the activities every ACTIVITY_INTERVAL minutes for the dashboard, if set
+func scheduleActivityFeed(scheduler *gocron.Scheduler) error {
+	interval, err := getActivityInterval()
+	if err != nil || interval == 0 {
+		return err
+	}
+	_, err = scheduler.Every(int(interval)).Minutes().Do(defaultAccountJob(pollActivities))
+	if err != nil {
+		return fmt.Errorf("couldn't schedule the pollActivities job: %v", err)
+	}
+	return nil
+}
+
+// Profile of the activity feed: PROFILE_ID, else the one of the tracked transfers
+func activityProfile() uint64 {
+	profile, _ := getConfiguredProfile()
+	if profile != 0 {
+		return profile
+	}
+	for _, tracked := range getTracked() {
+		if tracked.Transfer.Profile != 0 {
+			return tracked.Transfer.Profile
+		}
+	}
+	return 0
+}
+
+func pollActivities() {
+	defer reportPanic("pollActivities")
+
+	profile := activityProfile()
+	if profile == 0 {
+		log.Printf("pollActivities: no PROFILE_ID nor tracked transfer yet to list the activities of")
+		return
+	}
+	activities, err := getActivities(profile, time.Time{}, dashboardActivities)
+	if err != nil {
+		log.Printf("pollActivities: %v", err)
+		return
+	}
+	activityFeed.Lock()
+	activityFeed.activities = activities
+	activityFeed.Unlock()
+}
+
+// The activities as last polled by pollActivities, most recent first
+func getPolledActivities() []Activity {
+	activityFeed.Lock()
+	defer activityFeed.Unlock()
+	return append([]Activity(nil), activityFeed.activities...)
+}
+
+func runActivityCommand(args []string) error {
+	flags := flag.NewFlagSet("activity", flag.ContinueOnError)
+	profile := flags.Uint64("profile", 0, "profile ID to list the activities of, defaults to PROFILE_ID")
+	since := flags.String("since", "", "list the activities since this date, yyyy-mm-dd")
+	limit := flags.Int("limit", 20, "maximum number of activities to list")
+	output := outputFlag(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *profile == 0 {
+		configured, err := getConfiguredProfile()
+		if err != nil {
+			return err
+		}
+		*profile = configured
+	}
+	if *profile == 0 || *limit <= 0 {
+		return fmt.Errorf("usage: activity --profile <id> [--since <yyyy-mm-dd>] [--limit <n>] [--output json], or set PROFILE_ID")
+	}
+	var from time.Time
+	if *since != "" {
+		var err error
+		if from, err = time.Parse("2006-01-02", *since); err != nil {
+			return fmt.Errorf("invalid --since %v, expected yyyy-mm-dd", *since)
+		}
+	}
+
+	activities, err := getActivities(*profile, from, *limit)
+	if err != nil {
+		return err
+	}
+	if activities == nil {
+		activities = []Activity{}
+	}
+	return printOutput(os.Stdout, *output, activities, func(w io.Writer) {
+		writeActivities(w, activities)
+	})
+}
+
+func writeActivities(w io.Writer, activities []Activity) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "DATE\tTYPE\tSTATUS\tTITLE\tAMOUNT")
+	for _, activity := range activities {
+		amount := activityText(activity.PrimaryAmount)
+		if activity.SecondaryAmount != "" {
+			amount += " (" + activityText(activity.SecondaryAmount) + ")"
+		}
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\n", formatTime(activity.CreatedOn), activity.Type, activity.Status,
+			activityText(activity.Title), amount)
+	}
+	_ = tw.Flush()
+}
+
+func init() {
+	registerCommand("activity", Command{
+		Usage: "activity [--profile <id>] [--since <date>]   list the profile's activity feed on wise.com",
+		Run:   runActivityCommand,
+	})
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"transferwisely/mocks"
+)
+
+func TestGetActivities(t *testing.T) {
+	var queries []string
+	mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+		assert.True(t, strings.HasSuffix(req.URL.Path, "/profiles/1/activities"), req.URL.Path)
+		queries = append(queries, req.URL.RawQuery)
+		body := `{"cursor": "page-2", "activities": [
+			{"id": "a2", "type": "TRANSFER", "resource": {"type": "TRANSFER", "id": "8"}, "title": "<strong>John Doe</strong>",
+				"primaryAmount": "1,000 GBP", "secondaryAmount": "101,000 INR", "status": "UPCOMING", "createdOn": "2026-10-15T09:00:00Z"}]}`
+		if req.URL.Query().Get("nextCursor") == "page-2" {
+			body = `{"activities": [
+				{"id": "a1", "type": "BALANCE_ASSET_CONVERSION", "title": "Converted GBP to EUR", "primaryAmount": "<positive>+ 115 EUR</positive>",
+					"status": "COMPLETED", "createdOn": "2026-10-14T09:00:00Z"}]}`
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(body))}, nil
+	}
+
+	activities, err := getActivities(1, time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), 10)
+	assert.NoError(t, err)
+	assert.Len(t, activities, 2)
+	assert.Equal(t, "a2", activities[0].Id, "most recent first")
+	assert.Equal(t, "8", activities[0].Resource.Id)
+	assert.Len(t, queries, 2)
+	assert.Contains(t, queries[0], "since=2026-10-01T00%3A00%3A00Z")
+
+	var out bytes.Buffer
+	writeActivities(&out, activities)
+	assert.Contains(t, out.String(), "TRANSFER")
+	assert.Contains(t, out.String(), "John Doe")
+	assert.Contains(t, out.String(), "1,000 GBP (101,000 INR)")
+	assert.Contains(t, out.String(), "+ 115 EUR")
+	assert.NotContains(t, out.String(), "<strong>")
+
+	t.Run("limit", func(t *testing.T) {
+		queries = nil
+		activities, err := getActivities(1, time.Time{}, 1)
+		assert.NoError(t, err)
+		assert.Len(t, activities, 1)
+		assert.Len(t, queries, 1, "no further page once the limit is reached")
+		assert.NotContains(t, queries[0], "since")
+	})
+
+	t.Run("dashboard", func(t *testing.T) {
+		defer func(profile string) { profileIdVar = profile }(profileIdVar)
+		profileIdVar = "1"
+		pollActivities()
+		defer func() { activityFeed.activities = nil }()
+
+		recorder := httptest.NewRecorder()
+		dashboardHandler(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Contains(t, recorder.Body.String(), "Activity on wise.com")
+		assert.Contains(t, recorder.Body.String(), "<td>John Doe</td>")
+	})
+}
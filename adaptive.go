@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IntervalRange bounds the interval of a pair with ADAPTIVE_INTERVAL, in minutes, a zero range meaning a fixed interval
+type IntervalRange struct {
+	Min uint64
+	Max uint64
+}
+
+// last adaptive interval of each pair, to log its changes only
+var adaptiveIntervals = struct {
+	sync.Mutex
+	byPair map[string]uint64
+}{byPair: map[string]uint64{}}
+
+// Parse an interval range like 1-30, in minutes, an empty one meaning a fixed interval
+func parseIntervalRange(s string) (IntervalRange, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return IntervalRange{}, nil
+	}
+	bounds := strings.SplitN(s, "-", 2)
+	if len(bounds) == 2 {
+		min, minErr := strconv.ParseUint(strings.TrimSpace(bounds[0]), 10, 64)
+		max, maxErr := strconv.ParseUint(strings.TrimSpace(bounds[1]), 10, 64)
+		if minErr == nil && maxErr == nil && min > 0 && min <= max {
+			return IntervalRange{Min: min, Max: max}, nil
+		}
+	}
+	return IntervalRange{}, fmt.Errorf("invalid interval range %q, expected <min>-<max> minutes like 1-30", s)
+}
+
+// Volatility, in percent, at or below which the market is flat and at or above which it is volatile, and the number
+// of observations it is computed over
+func getAdaptiveVolatility() (flat float64, volatile float64, samples int, err error) {
+	bounds := strings.SplitN(adaptiveVolatilityVar, "-", 2)
+	if len(bounds) == 2 {
+		flat, err = strconv.ParseFloat(strings.TrimSpace(bounds[0]), 64)
+		if err == nil {
+			volatile, err = strconv.ParseFloat(strings.TrimSpace(bounds[1]), 64)
+		}
+	}
+	if len(bounds) != 2 || err != nil || flat < 0 || volatile <= flat {
+		return 0, 0, 0, fmt.Errorf("invalid value for ADAPTIVE_VOLATILITY: %v, expected <flat>-<volatile> percentages like 0.02-0.2",
+			adaptiveVolatilityVar)
+	}
+	n, err := strconv.ParseUint(adaptiveSamplesVar, 10, 64)
+	if err != nil || n < 2 {
+		return 0, 0, 0, fmt.Errorf("invalid value for ADAPTIVE_SAMPLES: %v, expected 2 or more", adaptiveSamplesVar)
+	}
+	return flat, volatile, int(n), nil
+}
+
+// Standard deviation of the rates in percent of their mean, comparable across pairs whatever their rate
+func rateVolatility(rates []float64) float64 {
+	if len(rates) < 2 {
+		return 0
+	}
+	mean := 0.0
+	for _, rate := range rates {
+		mean += rate
+	}
+	mean /= float64(len(rates))
+	if mean == 0 {
+		return 0
+	}
+	variance := 0.0
+	for _, rate := range rates {
+		variance += (rate - mean) * (rate - mean)
+	}
+	return math.Sqrt(variance/float64(len(rates))) / mean * 100
+}
+
+// Interval between the bounds for the volatility: the max when the market is flat, the min when it is volatile, and in
+// between the closer to the min the more volatile it is
+func scaleInterval(bounds IntervalRange, volatility float64, flat float64, volatile float64) uint64 {
+	switch {
+	case volatility <= flat:
+		return bounds.Max
+	case volatility >= volatile:
+		return bounds.Min
+	}
+	position := (volatility - flat) / (volatile - flat)
+	return bounds.Max - uint64(math.Round(position*float64(bounds.Max-bounds.Min)))
+}
+
+// Interval of the pair with ADAPTIVE_INTERVAL, from the volatility of the live rates of its last checks. Until there
+// are enough of them, the pair is checked at its interval, kept within the bounds
+func adaptiveInterval(pair string, settings Settings) uint64 {
+	bounds := settings.AdaptiveInterval
+	interval := settings.Interval
+	if interval < bounds.Min {
+		interval = bounds.Min
+	}
+	if interval > bounds.Max {
+		interval = bounds.Max
+	}
+	flat, volatile, samples, err := getAdaptiveVolatility()
+	if err != nil {
+		log.Printf("adaptiveInterval: %v", err)
+		return interval
+	}
+	state, err := loadState()
+	if err != nil {
+		log.Printf("adaptiveInterval: %v", err)
+		return interval
+	}
+
+	var rates []float64
+	for i := len(state.Decisions) - 1; i >= 0 && len(rates) < samples; i-- {
+		decision := state.Decisions[i]
+		if pairKey(decision.SourceCurrency, decision.TargetCurrency) == pair {
+			rates = append(rates, decision.LiveRate)
+		}
+	}
+	volatility := rateVolatility(rates)
+	if len(rates) == samples {
+		interval = scaleInterval(bounds, volatility, flat, volatile)
+	}
+
+	adaptiveIntervals.Lock()
+	previous, ok := adaptiveIntervals.byPair[pair]
+	adaptiveIntervals.byPair[pair] = interval
+	adaptiveIntervals.Unlock()
+	if !ok || previous != interval {
+		log.Printf("|| ADAPTIVE INTERVAL || {%v} | Volatility: %.4f%% over %v checks | Interval: %v minutes ||", pair,
+			volatility, len(rates), interval)
+	}
+	return interval
+}
+
+// Interval the pair is checked at, at now: its adaptive interval within its check windows when it has
+// ADAPTIVE_INTERVAL, else its check interval
+func (s Settings) pairInterval(pair string, now time.Time) (uint64, error) {
+	interval, err := s.checkInterval(now)
+	if err != nil || s.AdaptiveInterval.Max == 0 {
+		return interval, err
+	}
+	if len(s.Windows) > 0 && interval != s.Interval {
+		return interval, nil
+	}
+	return adaptiveInterval(pair, s), nil
+}
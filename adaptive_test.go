@@ -0,0 +1,80 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseIntervalRange(t *testing.T) {
+	bounds, err := parseIntervalRange(" 1-30 ")
+	assert.NoError(t, err)
+	assert.Equal(t, IntervalRange{Min: 1, Max: 30}, bounds)
+
+	bounds, err = parseIntervalRange("")
+	assert.NoError(t, err)
+	assert.Equal(t, IntervalRange{}, bounds)
+
+	for _, invalid := range []string{"30", "30-1", "0-10", "a-10", "1-"} {
+		_, err = parseIntervalRange(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestScaleInterval(t *testing.T) {
+	bounds := IntervalRange{Min: 2, Max: 30}
+	assert.Equal(t, uint64(30), scaleInterval(bounds, 0.01, 0.02, 0.2), "flat market")
+	assert.Equal(t, uint64(2), scaleInterval(bounds, 0.5, 0.02, 0.2), "volatile market")
+	assert.Equal(t, uint64(16), scaleInterval(bounds, 0.11, 0.02, 0.2))
+
+	assert.InDelta(t, 0, rateVolatility([]float64{100, 100, 100}), 1e-9)
+	assert.InDelta(t, 1, rateVolatility([]float64{99, 101}), 1e-9)
+}
+
+func TestPairInterval(t *testing.T) {
+	defer func(file string, vol string, samples string) {
+		stateFileVar, adaptiveVolatilityVar, adaptiveSamplesVar = file, vol, samples
+	}(stateFileVar, adaptiveVolatilityVar, adaptiveSamplesVar)
+	dir, _ := ioutil.TempDir("", "transferwisely")
+	defer os.RemoveAll(dir)
+	stateFileVar, adaptiveVolatilityVar, adaptiveSamplesVar = filepath.Join(dir, "state.json"), "0.02-0.2", "3"
+
+	now := time.Date(2024, 5, 3, 10, 0, 0, 0, time.UTC)
+	settings := Settings{Interval: 5, AdaptiveInterval: IntervalRange{Min: 1, Max: 30}}
+
+	interval, err := settings.pairInterval("GBP-INR", now)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(5), interval, "the interval until there are enough observations")
+
+	record := func(rates ...float64) {
+		assert.NoError(t, updateState(func(state *State) error {
+			for _, rate := range rates {
+				state.Decisions = append(state.Decisions, Decision{Time: now, SourceCurrency: "GBP", TargetCurrency: "INR", LiveRate: rate},
+					Decision{Time: now, SourceCurrency: "JPY", TargetCurrency: "INR", LiveRate: 0.6})
+			}
+			return nil
+		}))
+	}
+	record(100, 100, 100)
+	interval, err = settings.pairInterval("GBP-INR", now)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(30), interval, "a flat market is checked less often")
+
+	record(101, 99, 101)
+	interval, err = settings.pairInterval("GBP-INR", now)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), interval, "a volatile market is checked more often")
+
+	interval, err = Settings{Interval: 5}.pairInterval("GBP-INR", now)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(5), interval, "a fixed interval without ADAPTIVE_INTERVAL")
+
+	windows, _ := parseCheckWindows("Sat-Sun")
+	settings.Windows, settings.OffWindowInterval = windows, 60
+	interval, err = settings.pairInterval("GBP-INR", now)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(60), interval, "the off window interval outside the check windows")
+}
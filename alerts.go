@@ -0,0 +1,288 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/google/uuid"
+	"io"
+	"log"
+	"math"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// alert conditions
+const (
+	alertAbove  = "above"
+	alertBelow  = "below"
+	alertChange = "change"
+)
+
+// alert statuses
+const (
+	alertActive       = "active"
+	alertTriggered    = "triggered"
+	alertAcknowledged = "acknowledged"
+	alertSnoozed      = "snoozed"
+)
+
+// period the change condition compares the live rate against
+const alertChangePeriod = 24 * time.Hour
+
+// alert notification
+const (
+	alertSubject = "Rate alert: {%v} --> {%v} %v"
+	alertText    = "Alert ID: %v\n{%v} --> {%v}\nLive Rate: %v\nCondition: %v\n\nAcknowledge with: transferwisely alerts ack %v"
+)
+
+var errAlertNotFound = errors.New("alert not found")
+
+// Alert notifies once its condition on a currency pair's live rate holds, independently of any transfer.
+// A triggered alert stays quiet until acknowledged, then re-arms as soon as its condition no longer holds
+type Alert struct {
+	Id           string    `json:"id"`
+	Source       string    `json:"source"`
+	Target       string    `json:"target"`
+	Condition    string    `json:"condition"`
+	Threshold    float64   `json:"threshold"`
+	Status       string    `json:"status"`
+	CreatedAt    time.Time `json:"createdAt"`
+	TriggeredAt  time.Time `json:"triggeredAt,omitempty"`
+	TriggerRate  float64   `json:"triggerRate,omitempty"`
+	SnoozedUntil time.Time `json:"snoozedUntil,omitempty"`
+}
+
+func (alert Alert) String() string {
+	switch alert.Condition {
+	case alertChange:
+		return fmt.Sprintf("24h change > %v%%", alert.Threshold)
+	default:
+		return fmt.Sprintf("%v %v", alert.Condition, alert.Threshold)
+	}
+}
+
+// Whether the alert's condition holds at the live rate, given the rate alertChangePeriod ago for change alerts
+func (alert Alert) holds(liveRate float64, pastRate float64) bool {
+	switch alert.Condition {
+	case alertAbove:
+		return liveRate > alert.Threshold
+	case alertBelow:
+		return liveRate < alert.Threshold
+	case alertChange:
+		return pastRate > 0 && math.Abs(liveRate-pastRate)/pastRate*100 > alert.Threshold
+	default:
+		return false
+	}
+}
+
+// Evaluate every alert against the live rates, run along with every check
+func evaluateAlerts(now time.Time) {
+	state, err := loadState()
+	if err != nil {
+		log.Printf("evaluateAlerts: %v", err)
+		return
+	}
+	if len(state.Alerts) == 0 {
+		return
+	}
+
+	// fetch each pair's rates once, however many alerts it has
+	liveRates, pastRates := map[string]float64{}, map[string]float64{}
+	for _, alert := range state.Alerts {
+		pair := pairKey(alert.Source, alert.Target)
+		if _, ok := liveRates[pair]; !ok {
+			liveRate, err := getLiveRate(alert.Source, alert.Target)
+			if err != nil {
+				log.Printf("evaluateAlerts: %v", err)
+				continue
+			}
+			liveRates[pair] = liveRate
+		}
+		if _, ok := pastRates[pair]; !ok && alert.Condition == alertChange {
+			history, err := getRateHistory(alert.Source, alert.Target, now.Add(-alertChangePeriod), now, rateHistoryGroup)
+			if err != nil || len(history) == 0 {
+				log.Printf("evaluateAlerts: no rate history for {%v} --> {%v}: %v", alert.Source, alert.Target, err)
+				continue
+			}
+			pastRates[pair] = history[0].Rate
+		}
+	}
+
+	var triggered []Alert
+	err = updateState(func(state *State) error {
+		triggered = applyAlerts(state.Alerts, liveRates, pastRates, now)
+		return nil
+	})
+	if err != nil {
+		log.Printf("evaluateAlerts: %v", err)
+		return
+	}
+
+	for _, alert := range triggered {
+		log.Printf("|| RATE ALERT TRIGGERED, Live Rate: %v || Alert ID: %v | {%v} --> {%v} | Condition: %v ||",
+			alert.TriggerRate, alert.Id, alert.Source, alert.Target, alert)
+		notify(Event{
+			Kind:    EventAlert,
+			Subject: fmt.Sprintf(alertSubject, alert.Source, alert.Target, alert),
+			Text:    fmt.Sprintf(alertText, alert.Id, alert.Source, alert.Target, formatRate(alert.TriggerRate), alert, alert.Id),
+			Pair:    pairKey(alert.Source, alert.Target),
+		})
+	}
+}
+
+// Move the alerts along their statuses at the given rates by pair, returning the ones that just triggered
+func applyAlerts(alerts []Alert, liveRates map[string]float64, pastRates map[string]float64, now time.Time) (triggered []Alert) {
+	for i := range alerts {
+		alert := &alerts[i]
+		if alert.Status == alertSnoozed {
+			if now.Before(alert.SnoozedUntil) {
+				continue
+			}
+			alert.Status, alert.SnoozedUntil = alertActive, time.Time{}
+		}
+
+		pair := pairKey(alert.Source, alert.Target)
+		liveRate, ok := liveRates[pair]
+		if !ok {
+			continue
+		}
+		holds := alert.holds(liveRate, pastRates[pair])
+
+		switch {
+		case alert.Status == alertActive && holds:
+			alert.Status, alert.TriggeredAt, alert.TriggerRate = alertTriggered, now, liveRate
+			triggered = append(triggered, *alert)
+		case alert.Status == alertAcknowledged && !holds:
+			alert.Status = alertActive
+		}
+	}
+	return triggered
+}
+
+// Apply fn to the alert with the given id
+func updateAlert(id string, fn func(alert *Alert)) error {
+	return updateState(func(state *State) error {
+		for i := range state.Alerts {
+			if state.Alerts[i].Id == id {
+				fn(&state.Alerts[i])
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: %v", errAlertNotFound, id)
+	})
+}
+
+func runAlertsCommand(args []string) error {
+	usage := fmt.Errorf("usage: alerts list [--output json] | alerts add --pair <source>-<target> (--above <rate> | " +
+		"--below <rate> | --change <percent>) | alerts ack <id> | alerts snooze <id> --for <duration> | alerts remove <id>")
+	if len(args) == 0 {
+		return usage
+	}
+
+	switch args[0] {
+	case "list":
+		flags := flag.NewFlagSet("alerts list", flag.ContinueOnError)
+		output := outputFlag(flags)
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		state, err := loadState()
+		if err != nil {
+			return err
+		}
+		alerts := state.Alerts
+		if alerts == nil {
+			alerts = []Alert{}
+		}
+		return printOutput(os.Stdout, *output, alerts, func(w io.Writer) {
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "ID\tPAIR\tCONDITION\tSTATUS")
+			for _, alert := range alerts {
+				fmt.Fprintf(tw, "%v\t%v\t%v\t%v\n", alert.Id, pairKey(alert.Source, alert.Target), alert, alert.Status)
+			}
+			_ = tw.Flush()
+		})
+	case "add":
+		flags := flag.NewFlagSet("alerts add", flag.ContinueOnError)
+		pair := flags.String("pair", "", "currency pair like EUR-USD")
+		above := flags.Float64("above", 0, "notify when the rate rises above")
+		below := flags.Float64("below", 0, "notify when the rate falls below")
+		change := flags.Float64("change", 0, "notify when the rate changed by more than this percentage over 24h")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		source, target, err := splitPairKey(*pair)
+		if err != nil {
+			return usage
+		}
+
+		alert := Alert{Id: uuid.New().String(), Source: source, Target: target, Status: alertActive, CreatedAt: time.Now().UTC()}
+		conditions := 0
+		for condition, threshold := range map[string]float64{alertAbove: *above, alertBelow: *below, alertChange: *change} {
+			if threshold > 0 {
+				alert.Condition, alert.Threshold = condition, threshold
+				conditions++
+			}
+		}
+		if conditions != 1 {
+			return usage
+		}
+		err = updateState(func(state *State) error {
+			state.Alerts = append(state.Alerts, alert)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Alert %v added: {%v} --> {%v} %v\n", alert.Id, source, target, alert)
+		return nil
+	case "ack":
+		if len(args) != 2 {
+			return usage
+		}
+		return updateAlert(args[1], func(alert *Alert) {
+			if alert.Status == alertTriggered {
+				alert.Status = alertAcknowledged
+			}
+		})
+	case "snooze":
+		flags := flag.NewFlagSet("alerts snooze", flag.ContinueOnError)
+		duration := flags.Duration("for", 0, "how long to snooze the alert for, like 2h")
+		if len(args) < 2 {
+			return usage
+		}
+		if err := flags.Parse(args[2:]); err != nil {
+			return err
+		}
+		if *duration <= 0 {
+			return usage
+		}
+		return updateAlert(args[1], func(alert *Alert) {
+			alert.Status, alert.SnoozedUntil = alertSnoozed, time.Now().UTC().Add(*duration)
+		})
+	case "remove":
+		if len(args) != 2 {
+			return usage
+		}
+		return updateState(func(state *State) error {
+			for i := range state.Alerts {
+				if state.Alerts[i].Id == args[1] {
+					state.Alerts = append(state.Alerts[:i], state.Alerts[i+1:]...)
+					return nil
+				}
+			}
+			return fmt.Errorf("%w: %v", errAlertNotFound, args[1])
+		})
+	default:
+		return usage
+	}
+}
+
+func init() {
+	registerCommand("alerts", Command{
+		Usage: "alerts list|add|ack|snooze|remove ...        manage rate alerts independent of transfers",
+		Run:   runAlertsCommand,
+	})
+}
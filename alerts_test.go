@@ -0,0 +1,99 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"transferwisely/mocks"
+)
+
+func TestApplyAlerts(t *testing.T) {
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	alerts := []Alert{
+		{Id: "above", Source: "EUR", Target: "USD", Condition: alertAbove, Threshold: 1.1, Status: alertActive},
+		{Id: "below", Source: "EUR", Target: "USD", Condition: alertBelow, Threshold: 1.0, Status: alertActive},
+		{Id: "change", Source: "GBP", Target: "INR", Condition: alertChange, Threshold: 1, Status: alertActive},
+		{Id: "snoozed", Source: "EUR", Target: "USD", Condition: alertAbove, Threshold: 1.0, Status: alertSnoozed,
+			SnoozedUntil: now.Add(time.Hour)},
+	}
+	liveRates := map[string]float64{"EUR-USD": 1.12, "GBP-INR": 101.5}
+	pastRates := map[string]float64{"GBP-INR": 100}
+
+	triggered := applyAlerts(alerts, liveRates, pastRates, now)
+	assert.Len(t, triggered, 2)
+	assert.Equal(t, "above", triggered[0].Id)
+	assert.Equal(t, 1.12, triggered[0].TriggerRate)
+	assert.Equal(t, "change", triggered[1].Id)
+	assert.Equal(t, alertActive, alerts[1].Status)
+	assert.Equal(t, alertSnoozed, alerts[3].Status)
+
+	assert.Len(t, applyAlerts(alerts, liveRates, pastRates, now.Add(time.Minute)), 0, "triggered alerts stay quiet")
+
+	alerts[0].Status = alertAcknowledged
+	applyAlerts(alerts, liveRates, pastRates, now.Add(time.Minute))
+	assert.Equal(t, alertAcknowledged, alerts[0].Status, "acknowledged while the condition still holds")
+
+	liveRates["EUR-USD"] = 1.05
+	triggered = applyAlerts(alerts, liveRates, pastRates, now.Add(2*time.Hour))
+	assert.Equal(t, alertActive, alerts[0].Status, "re-armed once the condition no longer holds")
+	assert.Len(t, triggered, 1)
+	assert.Equal(t, "snoozed", triggered[0].Id, "snooze is over")
+}
+
+func TestEvaluateAlerts(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "transferwisely")
+	defer os.RemoveAll(dir)
+	defer func(file string) { stateFileVar = file }(stateFileVar)
+	stateFileVar = filepath.Join(dir, "state.json")
+
+	fake := &fakeNotifier{}
+	defer func(n []Notifier) { Notifiers = n }(Notifiers)
+	Notifiers = []Notifier{fake}
+
+	assert.NoError(t, runAlertsCommand([]string{"add", "--pair", "eur-usd", "--above", "1.1"}))
+	assert.NoError(t, runAlertsCommand([]string{"add", "--pair", "GBP-INR", "--change", "1"}))
+	assert.Error(t, runAlertsCommand([]string{"add", "--pair", "GBP-INR", "--above", "1", "--below", "2"}))
+	assert.Error(t, runAlertsCommand([]string{"add", "--pair", "GBP", "--above", "1"}))
+
+	requests := 0
+	mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+		requests++
+		body := `[{"rate": 1.12, "source": "EUR", "target": "USD"}]`
+		switch {
+		case strings.Contains(req.URL.String(), "source=GBP") && strings.Contains(req.URL.String(), "group="):
+			body = `[{"rate": 100, "source": "GBP", "target": "INR"}, {"rate": 100.5, "source": "GBP", "target": "INR"}]`
+		case strings.Contains(req.URL.String(), "source=GBP"):
+			body = `[{"rate": 100.5, "source": "GBP", "target": "INR"}]`
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(body))}, nil
+	}
+
+	evaluateAlerts(time.Now().UTC())
+	assert.Equal(t, 3, requests)
+	assert.Len(t, fake.events, 1)
+	assert.Equal(t, EventAlert, fake.events[0].Kind)
+	assert.Equal(t, "Rate alert: {EUR} --> {USD} above 1.1", fake.events[0].Subject)
+
+	state, _ := loadState()
+	assert.Equal(t, alertTriggered, state.Alerts[0].Status)
+	assert.Equal(t, alertActive, state.Alerts[1].Status)
+
+	t.Run("ack, snooze and remove", func(t *testing.T) {
+		assert.NoError(t, runAlertsCommand([]string{"ack", state.Alerts[0].Id}))
+		assert.NoError(t, runAlertsCommand([]string{"snooze", state.Alerts[1].Id, "--for", "2h"}))
+		assert.Error(t, runAlertsCommand([]string{"ack", "unknown"}))
+
+		state, _ := loadState()
+		assert.Equal(t, alertAcknowledged, state.Alerts[0].Status)
+		assert.Equal(t, alertSnoozed, state.Alerts[1].Status)
+
+		assert.NoError(t, runAlertsCommand([]string{"remove", state.Alerts[0].Id}))
+		state, _ = loadState()
+		assert.Len(t, state.Alerts, 1)
+	})
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"transferwisely/mocks"
+	"transferwisely/wise"
+)
+
+func TestCallExternalAPIErrors(t *testing.T) {
+	mockResponse := func(code int, body string) {
+		mocks.GetDoFunc = func(*http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: code,
+				Body:       ioutil.NopCloser(strings.NewReader(body)),
+			}, nil
+		}
+	}
+
+	t.Run("validation error", func(t *testing.T) {
+		mockResponse(http.StatusUnprocessableEntity,
+			`{"errors":[{"code":"NOT_VALID","message":"Please specify a valid amount","path":"sourceAmount","arguments":["sourceAmount",0]}]}`)
+
+		var quote QuoteDetail
+		code, err := callExternalAPI(http.MethodPost, "https://"+hostSandbox+"/"+quotesAPIPath, nil, &quote)
+		assert.Equal(t, http.StatusUnprocessableEntity, code)
+
+		var apiErr *wise.APIError
+		assert.True(t, errors.As(err, &apiErr))
+		assert.Equal(t, http.StatusUnprocessableEntity, apiErr.Status)
+		assert.Equal(t, "NOT_VALID", apiErr.Code)
+		assert.Equal(t, "/"+quotesAPIPath, apiErr.Path)
+		assert.Equal(t, "sourceAmount: Please specify a valid amount", apiErr.Message)
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		mockResponse(http.StatusUnauthorized, `{"error":"invalid_token","error_description":"Invalid token"}`)
+
+		_, err := getProfiles()
+		var apiErr *wise.APIError
+		assert.True(t, errors.As(err, &apiErr))
+		assert.Equal(t, "invalid_token", apiErr.Code)
+		assert.Equal(t, http.MethodGet, apiErr.Method)
+	})
+
+	t.Run("non json error body", func(t *testing.T) {
+		mockResponse(http.StatusBadGateway, "<html>Bad Gateway</html>")
+
+		_, err := callExternalAPI(http.MethodGet, "https://"+hostSandbox+"/"+liveRateAPIPath, nil, nil)
+		var apiErr *wise.APIError
+		assert.True(t, errors.As(err, &apiErr))
+		assert.Equal(t, "<html>Bad Gateway</html>", apiErr.Message)
+	})
+
+	t.Run("field type mismatch", func(t *testing.T) {
+		mockResponse(http.StatusOK, `[{"rate":"1.1"}]`)
+
+		_, err := getLiveRate("EUR", "USD")
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "cannot unmarshal string")
+	})
+}
+
+func TestAPIErrorIs(t *testing.T) {
+	expired := fmt.Errorf("createTransfer: %w", &wise.APIError{Status: http.StatusUnprocessableEntity, Code: wise.CodeQuoteExpired})
+	assert.True(t, errors.Is(expired, ErrQuoteExpired))
+	assert.False(t, errors.Is(expired, errTokenInvalid))
+
+	unauthorized := fmt.Errorf("getProfiles: %w", &wise.APIError{Status: http.StatusUnauthorized, Code: "invalid_token"})
+	assert.True(t, errors.Is(unauthorized, errTokenInvalid))
+	assert.False(t, errors.Is(unauthorized, ErrQuoteExpired))
+
+	assert.False(t, errors.Is(&wise.APIError{Status: http.StatusBadGateway}, ErrQuoteExpired))
+}
+
+func TestCreateTransferInsufficientImprovement(t *testing.T) {
+	mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+		body := `{}`
+		if req.Method == http.MethodPost && strings.HasSuffix(req.URL.String(), quotesAPIPath) {
+			body = `{"id": "quote-1", "rate": 99.5, "sourceAmount": 1000, "profile": 1}`
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(body))}, nil
+	}
+	booked := Transfer{Id: 7, Profile: 1, Rate: 100, SourceAmount: decimalOf(1000), SourceCurrency: "GBP", TargetCurrency: "INR"}
+
+	_, err := createTransfer(booked, Settings{}, rebookReasonBetterRate)
+	assert.True(t, errors.Is(err, ErrInsufficientImprovement))
+	assert.Contains(t, err.Error(), "quote quote-1 at 99.5 doesn't beat the booked rate 100")
+}
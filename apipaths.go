@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"transferwisely/wise"
+)
+
+// The API paths API_PATHS can override by name, e.g. API_PATHS=quotes=v3/quotes,transfers=v2/transfers to move to
+// newer Wise endpoints before the tool formally supports them
+var apiPaths = map[string]*string{
+	"transfers":         &transfersAPIPath,
+	"quotes":            &quotesAPIPath,
+	"rates":             &liveRateAPIPath,
+	"cancel-transfer":   &cancelTransferAPIPath,
+	"transfer":          &transferAPIPath,
+	"profiles":          &profilesAPIPath,
+	"balances":          &balancesAPIPath,
+	"fund-transfer":     &fundTransferAPIPath,
+	"profile-transfers": &profileTransfersAPIPath,
+	"delivery-estimate": &deliveryEstimateAPIPath,
+	"recipients":        &recipientsAPIPath,
+	"currency-pairs":    &currencyPairsAPIPath,
+	"activities":        &activitiesAPIPath,
+}
+
+var apiPathPlaceholder = regexp.MustCompile(`{[a-zA-Z]+}`)
+
+// Parse API_PATHS into the paths to override by name. An override keeps the {placeholders} of the path it replaces,
+// as the calls fill them in
+func getAPIPaths() (map[string]string, error) {
+	overrides := map[string]string{}
+	if strings.TrimSpace(apiPathsVar) == "" {
+		return overrides, nil
+	}
+	for _, entry := range strings.Split(apiPathsVar, ",") {
+		parts := strings.SplitN(strings.TrimSpace(entry), "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[1]) == "" {
+			return nil, fmt.Errorf("invalid API_PATHS entry %q, expected name=path", entry)
+		}
+		name, path := strings.TrimSpace(parts[0]), strings.Trim(strings.TrimSpace(parts[1]), "/")
+		current, ok := apiPaths[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown API path %v in API_PATHS, expected one of %v", name, apiPathNames())
+		}
+		if _, ok := overrides[name]; ok {
+			return nil, fmt.Errorf("API path %v given twice in API_PATHS", name)
+		}
+		expected := apiPathPlaceholder.FindAllString(*current, -1)
+		actual := apiPathPlaceholder.FindAllString(path, -1)
+		sort.Strings(expected)
+		sort.Strings(actual)
+		if strings.Join(expected, "") != strings.Join(actual, "") {
+			return nil, fmt.Errorf("API path %v in API_PATHS must have the placeholders %v, got %v", name, expected, path)
+		}
+		overrides[name] = path
+	}
+	return overrides, nil
+}
+
+// Point the API calls to the paths overridden in API_PATHS
+func configureAPIPaths() error {
+	overrides, err := getAPIPaths()
+	if err != nil {
+		return err
+	}
+	for name, path := range overrides {
+		*apiPaths[name] = path
+	}
+	return nil
+}
+
+func apiPathNames() []string {
+	names := make([]string, 0, len(apiPaths))
+	for name := range apiPaths {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Call any transferwise API path as is and print its JSON response, refusing writes in READ_ONLY mode
+func runAPICommand(args []string) error {
+	usage := fmt.Errorf("usage: api <method> <path> [--data <json>]")
+	if len(args) < 2 {
+		return usage
+	}
+	method, path := strings.ToUpper(args[0]), args[1]
+	flags := flag.NewFlagSet("api", flag.ContinueOnError)
+	data := flags.String("data", "", "JSON body of the request")
+	if err := flags.Parse(args[2:]); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return usage
+	}
+	if hostVar == "" || apiTokenVar == "" {
+		return ErrEnvVarMissingOrInvalid
+	}
+	if method != http.MethodGet && isReadOnly() {
+		return errReadOnly
+	}
+	var body []byte
+	if *data != "" {
+		if !json.Valid([]byte(*data)) {
+			return fmt.Errorf("invalid --data, expected JSON")
+		}
+		body = []byte(*data)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	res, err := newWiseClient().Raw(ctx, method, path, body)
+	code := http.StatusOK
+	if apiErr, ok := err.(*wise.APIError); ok {
+		code = apiErr.Status
+	} else if err != nil {
+		code = 0
+	}
+	auditAPICall(method, "https://"+hostVar+"/"+strings.TrimPrefix(path, "/"), code, body, res, err, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	if len(res) == 0 {
+		return nil
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, res, "", "  "); err != nil {
+		_, err = os.Stdout.Write(res)
+		return err
+	}
+	_, err = fmt.Fprintln(os.Stdout, indented.String())
+	return err
+}
+
+func init() {
+	registerCommand("api", Command{
+		Usage: "api <method> <path> [--data <json>]          call any transferwise API path and print its JSON response",
+		Run:   runAPICommand,
+	})
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"transferwisely/mocks"
+)
+
+func TestGetAPIPaths(t *testing.T) {
+	defer func(v string) { apiPathsVar = v }(apiPathsVar)
+
+	tests := []struct {
+		paths    string
+		expected map[string]string
+		err      string
+	}{
+		{"", map[string]string{}, ""},
+		{"quotes=v3/quotes, transfer=/v2/transfers/{transferId}/", map[string]string{"quotes": "v3/quotes", "transfer": "v2/transfers/{transferId}"}, ""},
+		{"quotes", nil, "expected name=path"},
+		{"payouts=v1/payouts", nil, "unknown API path payouts"},
+		{"quotes=v3/quotes,quotes=v4/quotes", nil, "given twice"},
+		{"cancel-transfer=v2/transfers/cancel", nil, "placeholders [{transferId}]"},
+	}
+	for _, test := range tests {
+		t.Run(test.paths, func(t *testing.T) {
+			apiPathsVar = test.paths
+			paths, err := getAPIPaths()
+			if test.err != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), test.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, paths)
+		})
+	}
+}
+
+func TestConfigureAPIPaths(t *testing.T) {
+	defer func(v string, path string) { apiPathsVar, liveRateAPIPath = v, path }(apiPathsVar, liveRateAPIPath)
+
+	apiPathsVar = "rates=v2/rates"
+	assert.NoError(t, configureAPIPaths())
+	assert.Equal(t, "v2/rates", liveRateAPIPath)
+
+	var called string
+	mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+		called = req.URL.String()
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(`[{"rate": 1.5}]`))}, nil
+	}
+	rate, err := getLiveRate("GBP", "INR")
+	assert.NoError(t, err)
+	assert.Equal(t, 1.5, rate)
+	assert.Contains(t, called, "v2/rates")
+}
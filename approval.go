@@ -0,0 +1,363 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// proposal statuses
+const (
+	proposalPending  = "pending"
+	proposalApproved = "approved"
+	proposalExpired  = "expired"
+	proposalFailed   = "failed"
+)
+
+// number of past proposals kept in the state file
+const maxProposals = 100
+
+// proposal notification
+const (
+	proposalSubject = "Better rate found, approve the re-booking?"
+	proposalText    = "Proposal ID: %v\nTransfer ID: %v\n{%v} --> {%v}\nQuoted rate: %v (booked %v)\nAmount: %v %v\n" +
+		"Expires: %v\n\nApprove with: transferwisely approve %v"
+)
+
+var (
+	errProposalNotFound   = errors.New("proposal not found")
+	errProposalNotPending = errors.New("proposal is not pending")
+)
+
+// Proposal is a re-booking awaiting approval in APPROVAL_MODE, it expires along with its quote
+type Proposal struct {
+	Id            string      `json:"id"`
+	Status        string      `json:"status"`
+	CreatedAt     time.Time   `json:"createdAt"`
+	ExpiresAt     time.Time   `json:"expiresAt"`
+	Reason        string      `json:"reason"`
+	Transfer      Transfer    `json:"transfer"`
+	Quote         QuoteDetail `json:"quote"`
+	NewTransferId uint64      `json:"newTransferId,omitempty"`
+	Error         string      `json:"error,omitempty"`
+
+	// account of the transfer, booked with its API token and profile once approved, the default one when empty
+	Account string `json:"account,omitempty"`
+
+	// secret of the approve link of the notification, approving without CONTROL_API_TOKEN
+	ApprovalToken string `json:"approvalToken,omitempty"`
+}
+
+// Propose re-booking the transfer at a fresh quote and ask for approval, unless a proposal for it is still pending
+func proposeRebook(transfer Transfer, settings Settings, reason string, now time.Time) (Proposal, error) {
+	state, err := loadState()
+	if err != nil {
+		return Proposal{}, fmt.Errorf("proposeRebook: %v", err)
+	}
+	for _, proposal := range state.Proposals {
+		if proposal.Transfer.Id == transfer.Id && proposal.Status == proposalPending && now.Before(proposal.ExpiresAt) {
+			return proposal, nil
+		}
+	}
+
+	quote, err := createRebookQuote(transfer, settings)
+	if err != nil {
+		return Proposal{}, fmt.Errorf("proposeRebook: %v", err)
+	}
+	if err := checkQuoteFee(quote, settings); err != nil {
+		return Proposal{}, fmt.Errorf("proposeRebook: %w", err)
+	}
+	expiresAt, err := time.Parse(time.RFC3339, quote.RateExpirationTime)
+	if err != nil {
+		return Proposal{}, fmt.Errorf("proposeRebook: invalid expiry of quote %v: %v", quote.Id, err)
+	}
+
+	proposal := Proposal{
+		Id:        uuid.New().String(),
+		Status:    proposalPending,
+		CreatedAt: now,
+		ExpiresAt: expiresAt.UTC(),
+		Reason:    reason,
+		Transfer:  transfer,
+		Quote:     quote,
+		Account:   getCurrentAccount(),
+
+		ApprovalToken: randomHex(16),
+	}
+	err = updateState(func(state *State) error {
+		expireProposals(state, now)
+		state.Proposals = append(state.Proposals, proposal)
+		if len(state.Proposals) > maxProposals {
+			state.Proposals = state.Proposals[len(state.Proposals)-maxProposals:]
+		}
+		return nil
+	})
+	if err != nil {
+		return Proposal{}, fmt.Errorf("proposeRebook: %v", err)
+	}
+
+	log.Printf("|| REBOOK PROPOSED, Quoted Rate: %v || Proposal ID: %v | Transfer ID: %v | {%v} --> {%v} | Booked Rate: %v | Expires: %v ||",
+		quote.Rate, proposal.Id, transfer.Id, transfer.SourceCurrency, transfer.TargetCurrency, transfer.Rate, proposal.ExpiresAt)
+	event := Event{
+		Kind:    EventProposal,
+		Subject: proposalSubject,
+		Text: fmt.Sprintf(proposalText, proposal.Id, transfer.Id, transfer.SourceCurrency, transfer.TargetCurrency,
+			formatRate(quote.Rate), formatRate(transfer.Rate), formatAmount(quote.SourceAmount, transfer.SourceCurrency), transfer.SourceCurrency, formatTime(proposal.ExpiresAt), proposal.Id),
+	}
+	summary, summaryText := notificationRateSummary(transfer.SourceCurrency, transfer.TargetCurrency)
+	event.Data = ProposalMailData{Proposal: proposal, Summary: summary}
+	event.Text += summaryText
+	if publicURLVar != "" {
+		event.ActionLabel = "Approve"
+		event.ActionURL = strings.TrimRight(publicURLVar, "/") + "/proposals/" + proposal.Id + "/approve?token=" +
+			proposal.ApprovalToken
+		event.Text += "\nor at " + event.ActionURL
+	}
+	notify(event)
+	return proposal, nil
+}
+
+// Book the pending proposal's quote with the API token and profile of its account, the approval being refused once
+// the proposal expired
+func approveProposal(id string, now time.Time) (newTransfer Transfer, err error) {
+	state, err := loadState()
+	if err != nil {
+		return Transfer{}, fmt.Errorf("approveProposal: %w", err)
+	}
+	var account string
+	for _, proposal := range state.Proposals {
+		if proposal.Id == id {
+			account = proposal.Account
+		}
+	}
+
+	accountErr := runAsAccount(account, func() {
+		newTransfer, err = bookProposal(id, now)
+	})
+	if accountErr != nil {
+		return Transfer{}, fmt.Errorf("approveProposal: %v of proposal %v", accountErr, id)
+	}
+	return newTransfer, err
+}
+
+// Book the pending proposal under the account approveProposal runs it for
+func bookProposal(id string, now time.Time) (Transfer, error) {
+	state, err := loadState()
+	if err != nil {
+		return Transfer{}, fmt.Errorf("approveProposal: %w", err)
+	}
+	i, err := findPendingProposal(&state, id, now)
+	if err != nil {
+		return Transfer{}, fmt.Errorf("approveProposal: %w", err)
+	}
+
+	err = checkRebookAllowed(state.Proposals[i].Reason, now)
+	if err == nil {
+		err = checkChainAllowed(state.Proposals[i].Transfer, state.Proposals[i].Reason, now)
+	}
+	if err != nil {
+		return Transfer{}, fmt.Errorf("approveProposal: %w", err)
+	}
+	if !beginOperation() {
+		return Transfer{}, fmt.Errorf("approveProposal: shutting down")
+	}
+	defer endOperation()
+
+	// marking it approved first so it can't be booked twice
+	var proposal Proposal
+	err = updateState(func(state *State) error {
+		i, err := findPendingProposal(state, id, now)
+		if err != nil {
+			return err
+		}
+		state.Proposals[i].Status = proposalApproved
+		proposal = state.Proposals[i]
+		return nil
+	})
+	if err != nil {
+		return Transfer{}, fmt.Errorf("approveProposal: %w", err)
+	}
+
+	newTransfer, err := createTransferFromQuote(proposal.Transfer, proposal.Quote)
+	if errors.Is(err, errTransferFunded) && newTransfer.Id != 0 {
+		setProposalResult(id, proposalFailed, newTransfer.Id, err)
+		recordRebooking(proposal.Transfer, newTransfer, proposal.Reason, time.Now().UTC())
+		return newTransfer, fmt.Errorf("approveProposal: %w", err)
+	}
+	if err != nil {
+		status := proposalFailed
+		if errors.Is(err, ErrQuoteExpired) {
+			status = proposalExpired
+		}
+		setProposalResult(id, status, 0, err)
+		annotateQuoteExpired(proposal.Transfer, err, time.Now().UTC())
+		notifyError("Re-booking approved transfer failed", err)
+		return Transfer{}, fmt.Errorf("approveProposal: %w", err)
+	}
+	setProposalResult(id, proposalApproved, newTransfer.Id, nil)
+
+	subject := rebookedSubject
+	if proposal.Reason == rebookReasonRenewal {
+		subject = renewedSubject
+	}
+	_, _ = completeRebook(proposal.Transfer, newTransfer, subject, proposal.Reason, nil)
+	return newTransfer, nil
+}
+
+func setProposalResult(id string, status string, newTransferId uint64, proposalErr error) {
+	err := updateState(func(state *State) error {
+		for i := range state.Proposals {
+			if state.Proposals[i].Id == id {
+				state.Proposals[i].Status = status
+				state.Proposals[i].NewTransferId = newTransferId
+				if proposalErr != nil {
+					state.Proposals[i].Error = proposalErr.Error()
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("setProposalResult: %v", err)
+	}
+}
+
+func findPendingProposal(state *State, id string, now time.Time) (int, error) {
+	expireProposals(state, now)
+	for i := range state.Proposals {
+		if state.Proposals[i].Id != id {
+			continue
+		}
+		if state.Proposals[i].Status != proposalPending {
+			return 0, fmt.Errorf("%w: %v is %v", errProposalNotPending, id, state.Proposals[i].Status)
+		}
+		return i, nil
+	}
+	return 0, fmt.Errorf("%w: %v", errProposalNotFound, id)
+}
+
+func expireProposals(state *State, now time.Time) {
+	for i := range state.Proposals {
+		if state.Proposals[i].Status == proposalPending && !now.Before(state.Proposals[i].ExpiresAt) {
+			state.Proposals[i].Status = proposalExpired
+		}
+	}
+}
+
+// Serve GET /proposals listing the proposals, most recent first, to CONTROL_API_TOKEN bearers only, and
+// /proposals/{id}/approve where GET asks for confirmation, so notification link previews never approve anything, and
+// POST approves with the proposal's approval token of the notification link or CONTROL_API_TOKEN
+func proposalsHandler(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/proposals"), "/")
+	if path == "" && r.Method == http.MethodGet {
+		if controlAPITokenVar == "" || !hasControlToken(r) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="transferwisely"`)
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid or missing bearer token"})
+			return
+		}
+		state, err := loadState()
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		expireProposals(&state, time.Now().UTC())
+		proposals := make([]Proposal, 0, len(state.Proposals))
+		for i := len(state.Proposals) - 1; i >= 0; i-- {
+			proposals = append(proposals, state.Proposals[i])
+		}
+		writeJSON(w, http.StatusOK, proposals)
+		return
+	}
+
+	parts := strings.Split(path, "/")
+	if len(parts) != 2 || parts[1] != "approve" {
+		http.NotFound(w, r)
+		return
+	}
+	token := r.FormValue("token")
+	if !(controlAPITokenVar != "" && hasControlToken(r)) && !isApprovalToken(parts[0], token) {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid or missing approval token"})
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := approveTmpl.Execute(w, struct{ Id, Token string }{parts[0], token}); err != nil {
+			log.Printf("proposalsHandler: %v", err)
+		}
+	case http.MethodPost:
+		newTransfer, err := approveProposal(parts[0], time.Now().UTC())
+		writeApproval(w, newTransfer, err)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// Whether the token is the approval token of the proposal, proposals made before approval tokens having none
+func isApprovalToken(id string, token string) bool {
+	if token == "" {
+		return false
+	}
+	state, err := loadState()
+	if err != nil {
+		log.Printf("isApprovalToken: %v", err)
+		return false
+	}
+	for _, proposal := range state.Proposals {
+		if proposal.Id == id && proposal.ApprovalToken != "" {
+			return subtle.ConstantTimeCompare([]byte(token), []byte(proposal.ApprovalToken)) == 1
+		}
+	}
+	return false
+}
+
+// Answer an approval with the booked transfer, or 404 for an unknown and 409 for an already handled proposal
+func writeApproval(w http.ResponseWriter, newTransfer Transfer, err error) {
+	switch {
+	case errors.Is(err, errProposalNotFound):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, errProposalNotPending):
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	case err != nil:
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	default:
+		writeJSON(w, http.StatusOK, newTransfer)
+	}
+}
+
+func runApproveCommand(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: approve <proposalId>")
+	}
+
+	newTransfer, err := approveProposal(args[0], time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Proposal %v approved, transfer %v booked at %v\n", args[0], newTransfer.Id, newTransfer.Rate)
+	return nil
+}
+
+func init() {
+	registerCommand("approve", Command{
+		Usage: "approve <proposalId>                         book a re-booking proposed in APPROVAL_MODE",
+		Run:   runApproveCommand,
+	})
+}
+
+var approveTmpl = template.Must(template.New("approve").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>transferwisely</title></head>
+<body style="font-family: sans-serif; margin: 2em;">
+<h3>Approve re-booking proposal {{.Id}}?</h3>
+<form method="post"><input type="hidden" name="token" value="{{.Token}}"><button type="submit">Approve</button></form>
+</body>
+</html>
+`))
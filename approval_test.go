@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"transferwisely/mocks"
+)
+
+func TestApprovalWorkflow(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "transferwisely")
+	defer os.RemoveAll(dir)
+	defer func(file, publicURL, token string) {
+		stateFileVar, publicURLVar, controlAPITokenVar = file, publicURL, token
+	}(stateFileVar, publicURLVar, controlAPITokenVar)
+	stateFileVar = filepath.Join(dir, "state.json")
+	publicURLVar = "https://transferwisely.example.com/"
+	controlAPITokenVar = "secret"
+
+	fake := &fakeNotifier{}
+	defer func(n []Notifier) { Notifiers = n }(Notifiers)
+	Notifiers = []Notifier{fake}
+
+	expiry := time.Now().UTC().Add(time.Hour).Format(time.RFC3339)
+	quotes, transfers := 0, 0
+	mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+		body := ""
+		switch {
+		case req.Method == http.MethodPost && strings.HasSuffix(req.URL.String(), quotesAPIPath):
+			quotes++
+			body = fmt.Sprintf(`{"id": "quote-%v", "rate": 0.7, "sourceAmount": 1000, "sourceCurrency": "JPY",
+				"targetCurrency": "INR", "profile": 1, "rateExpirationTime": %q}`, quotes, expiry)
+		case req.Method == http.MethodPost && strings.HasSuffix(req.URL.String(), transfersAPIPath):
+			transfers++
+			body = `{"id": 2, "rate": 0.7, "sourceCurrency": "JPY", "targetCurrency": "INR"}`
+		case req.Method == http.MethodGet && strings.HasSuffix(req.URL.String(), "v1/transfers/1"):
+			body = `{"id": 1, "status": "incoming_payment_waiting"}`
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(body))}, nil
+	}
+
+	transfer := Transfer{Id: 1, Profile: 1, Rate: 0.69, SourceAmount: decimalOf(1000), SourceCurrency: "JPY", TargetCurrency: "INR"}
+	now := time.Now().UTC()
+	proposal, err := proposeRebook(transfer, Settings{}, rebookReasonBetterRate, now)
+	assert.NoError(t, err)
+	assert.Equal(t, proposalPending, proposal.Status)
+	assert.Equal(t, "quote-1", proposal.Quote.Id)
+	assert.Len(t, fake.events, 1)
+	assert.Equal(t, EventProposal, fake.events[0].Kind)
+	assert.NotEmpty(t, proposal.ApprovalToken)
+	approveURL := "/proposals/" + proposal.Id + "/approve?token=" + proposal.ApprovalToken
+	assert.Equal(t, "https://transferwisely.example.com"+approveURL, fake.events[0].ActionURL)
+
+	t.Run("pending proposal is reused", func(t *testing.T) {
+		again, err := proposeRebook(transfer, Settings{}, rebookReasonBetterRate, now.Add(time.Minute))
+		assert.NoError(t, err)
+		assert.Equal(t, proposal.Id, again.Id)
+		assert.Equal(t, 1, quotes)
+		assert.Len(t, fake.events, 1)
+	})
+
+	t.Run("confirmation page doesn't approve", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		proposalsHandler(w, httptest.NewRequest(http.MethodGet, approveURL, nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "<form method=\"post\">")
+		assert.Contains(t, w.Body.String(), `name="token" value="`+proposal.ApprovalToken+`"`)
+		assert.Equal(t, 0, transfers)
+	})
+
+	t.Run("approving without a token is refused", func(t *testing.T) {
+		for _, target := range []string{"/proposals/" + proposal.Id + "/approve", "/proposals/" + proposal.Id + "/approve?token=wrong"} {
+			w := httptest.NewRecorder()
+			proposalsHandler(w, httptest.NewRequest(http.MethodPost, target, nil))
+			assert.Equal(t, http.StatusUnauthorized, w.Code, target)
+		}
+		w := httptest.NewRecorder()
+		proposalsHandler(w, httptest.NewRequest(http.MethodGet, "/proposals", nil))
+		assert.Equal(t, http.StatusUnauthorized, w.Code, "proposal IDs aren't listed to anonymous callers")
+		assert.Equal(t, 0, transfers)
+	})
+
+	t.Run("approve", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		form := strings.NewReader("token=" + proposal.ApprovalToken)
+		r := httptest.NewRequest(http.MethodPost, "/proposals/"+proposal.Id+"/approve", form)
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		proposalsHandler(w, r)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, 1, transfers)
+
+		state, _ := loadState()
+		assert.Equal(t, proposalApproved, state.Proposals[0].Status)
+		assert.Equal(t, uint64(2), state.Proposals[0].NewTransferId)
+		assert.Len(t, state.RebookHistory, 1)
+	})
+
+	t.Run("approving twice is refused", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		proposalsHandler(w, httptest.NewRequest(http.MethodPost, approveURL, nil))
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, 1, transfers)
+	})
+
+	t.Run("unknown proposal", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/proposals/unknown/approve", nil)
+		r.Header.Set("Authorization", "Bearer secret")
+		proposalsHandler(w, r)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("expired proposal", func(t *testing.T) {
+		_ = updateState(func(state *State) error {
+			state.Proposals = append(state.Proposals, Proposal{Id: "old", Status: proposalPending, ExpiresAt: now.Add(-time.Minute)})
+			return nil
+		})
+		_, err := approveProposal("old", now)
+		assert.True(t, strings.Contains(err.Error(), proposalExpired))
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/proposals", nil)
+		r.Header.Set("Authorization", "Bearer secret")
+		proposalsHandler(w, r)
+		var proposals []Proposal
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &proposals))
+		assert.Equal(t, "old", proposals[0].Id)
+		assert.Equal(t, proposalExpired, proposals[0].Status)
+	})
+}
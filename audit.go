@@ -0,0 +1,333 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// audited write operations
+const (
+	auditQuoteCreated      = "quote-created"
+	auditTransferCreated   = "transfer-created"
+	auditTransferCancelled = "transfer-cancelled"
+)
+
+// what redacted secrets are replaced with in the audit log
+const auditRedacted = "[REDACTED]"
+
+// JSON keys whose values are redacted from the audit log, matched case insensitively within the key
+var auditSecretKeys = []string{"token", "password", "secret", "authorization", "apikey"}
+
+var errAuditChainBroken = errors.New("audit log hash chain broken")
+
+// AuditEntry records a write call to the transferwise API, chained to the entry before it by PrevHash so editing,
+// removing or reordering entries breaks the chain
+type AuditEntry struct {
+	Seq      uint64          `json:"seq"`
+	Time     time.Time       `json:"time"`
+	Action   string          `json:"action"`
+	Method   string          `json:"method"`
+	URL      string          `json:"url"`
+	Status   int             `json:"status"`
+	Request  json.RawMessage `json:"request,omitempty"`
+	Response json.RawMessage `json:"response,omitempty"`
+	Error    string          `json:"error,omitempty"`
+	PrevHash string          `json:"prevHash"`
+	Hash     string          `json:"hash"`
+}
+
+// SHA-256 of the previous hash and the entry, its own hash left out
+func (e AuditEntry) computeHash() string {
+	e.Hash = ""
+	data, _ := json.Marshal(e)
+	sum := sha256.Sum256(append([]byte(e.PrevHash+"\n"), data...))
+	return hex.EncodeToString(sum[:])
+}
+
+// serializes the appends to AUDIT_LOG so each entry chains to the one written before it
+var auditMutex sync.Mutex
+
+// Append the write call to AUDIT_LOG, if set, the secrets of its payloads redacted. Failing to audit is logged
+// rather than failing the call, which already reached transferwise
+func auditAPICall(method string, rawURL string, code int, reqBody []byte, resBody []byte, callErr error, now time.Time) {
+	if auditLogVar == "" || method == http.MethodGet {
+		return
+	}
+	entry := AuditEntry{
+		Time:     now,
+		Action:   auditAction(method, rawURL),
+		Method:   method,
+		URL:      redactSecrets(rawURL),
+		Status:   code,
+		Request:  redactPayload(reqBody),
+		Response: redactPayload(resBody),
+	}
+	if callErr != nil {
+		entry.Error = redactSecrets(callErr.Error())
+	}
+	if err := appendAuditEntry(auditLogVar, entry); err != nil {
+		log.Printf("auditAPICall: %v", err)
+	}
+}
+
+func appendAuditEntry(file string, entry AuditEntry) error {
+	auditMutex.Lock()
+	defer auditMutex.Unlock()
+
+	last, err := lastAuditEntry(file)
+	if err != nil {
+		return err
+	}
+	entry.Seq = last.Seq + 1
+	entry.PrevHash = last.Hash
+	entry.Hash = entry.computeHash()
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("error encoding audit entry: %v", err)
+	}
+
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("error opening audit log: %v", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("error writing audit log: %v", err)
+	}
+	return nil
+}
+
+// The last entry of the audit log, the zero entry for a missing or empty log
+func lastAuditEntry(file string) (AuditEntry, error) {
+	entries, err := readAuditLog(file)
+	if err != nil || len(entries) == 0 {
+		return AuditEntry{}, err
+	}
+	return entries[len(entries)-1], nil
+}
+
+func readAuditLog(file string) ([]AuditEntry, error) {
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading audit log: %v", err)
+	}
+	var entries []AuditEntry
+	for i, line := range bytes.Split(data, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var entry AuditEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return nil, fmt.Errorf("error decoding audit log line %v: %v", i+1, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// Check every entry follows the one before it and still hashes to its recorded hash
+func verifyAuditLog(entries []AuditEntry) error {
+	var prev AuditEntry
+	for _, entry := range entries {
+		if entry.Seq != prev.Seq+1 || entry.PrevHash != prev.Hash {
+			return fmt.Errorf("%w: entry %v doesn't follow entry %v", errAuditChainBroken, entry.Seq, prev.Seq)
+		}
+		if entry.computeHash() != entry.Hash {
+			return fmt.Errorf("%w: entry %v was modified", errAuditChainBroken, entry.Seq)
+		}
+		prev = entry
+	}
+	return nil
+}
+
+// The write operation a call stands for, its method and path for the ones not named
+func auditAction(method string, rawURL string) string {
+	path := rawURL
+	if u, err := url.Parse(rawURL); err == nil {
+		path = u.Path
+	}
+	path = strings.Trim(path, "/")
+	switch {
+	case method == http.MethodPost && strings.HasSuffix(path, quotesAPIPath):
+		return auditQuoteCreated
+	case method == http.MethodPost && strings.HasSuffix(path, transfersAPIPath):
+		return auditTransferCreated
+	case method == http.MethodPut && strings.HasSuffix(path, "/cancel"):
+		return auditTransferCancelled
+	default:
+		return method + " " + path
+	}
+}
+
+// The payload with the values of secret looking keys and of the configured secrets redacted, kept as a JSON string
+// when it isn't JSON
+func redactPayload(body []byte) json.RawMessage {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		data, _ := json.Marshal(redactSecrets(string(body)))
+		return data
+	}
+	data, _ := json.Marshal(redactValue(v, auditSecretKeys))
+	return data
+}
+
+// The JSON value with the values of the keys containing one of keys, and of the configured secrets, redacted
+func redactValue(v interface{}, keys []string) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for key, field := range value {
+			if containsKey(key, keys) {
+				value[key] = auditRedacted
+			} else {
+				value[key] = redactValue(field, keys)
+			}
+		}
+		return value
+	case []interface{}:
+		for i := range value {
+			value[i] = redactValue(value[i], keys)
+		}
+		return value
+	case string:
+		return redactSecrets(value)
+	default:
+		return value
+	}
+}
+
+func containsKey(key string, keys []string) bool {
+	key = strings.ToLower(key)
+	for _, k := range keys {
+		if strings.Contains(key, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// The text with the value of every configured secret redacted
+func redactSecrets(s string) string {
+	for _, secret := range secretVars {
+		if *secret.value != "" {
+			s = strings.Replace(s, *secret.value, auditRedacted, -1)
+		}
+	}
+	for _, token := range accountTokens() {
+		if token != "" {
+			s = strings.Replace(s, token, auditRedacted, -1)
+		}
+	}
+	return s
+}
+
+// Write the audit entries as CSV, one row per write call
+func writeAuditCSV(w io.Writer, entries []AuditEntry) error {
+	rows := [][]string{{"seq", "time", "action", "method", "url", "status", "error", "request", "response", "prevHash", "hash"}}
+	for _, entry := range entries {
+		rows = append(rows, []string{formatUint(entry.Seq), entry.Time.Format(time.RFC3339), entry.Action, entry.Method, entry.URL,
+			fmt.Sprint(entry.Status), entry.Error, string(entry.Request), string(entry.Response), entry.PrevHash, entry.Hash})
+	}
+	return writeCSV(w, rows)
+}
+
+func runAuditCommand(args []string) error {
+	usage := fmt.Errorf("usage: audit verify | audit export [--format csv|json] [--from <yyyy-mm-dd>] [--to <yyyy-mm-dd>] [--out <file>]")
+	if len(args) == 0 {
+		return usage
+	}
+	if auditLogVar == "" {
+		return fmt.Errorf("AUDIT_LOG is not set")
+	}
+	entries, err := readAuditLog(auditLogVar)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "verify":
+		if len(args) != 1 {
+			return usage
+		}
+		if err := verifyAuditLog(entries); err != nil {
+			return err
+		}
+		fmt.Printf("%v audit entries, hash chain intact\n", len(entries))
+		return nil
+	case "export":
+		flags := flag.NewFlagSet("audit export", flag.ContinueOnError)
+		format := flags.String("format", "csv", "export format, csv or json")
+		fromFlag := flags.String("from", "", "first day to export, like 2023-01-01")
+		toFlag := flags.String("to", "", "last day to export")
+		out := flags.String("out", "", "file to write, defaults to stdout")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		var from, to time.Time
+		if *fromFlag != "" {
+			if from, err = time.Parse(backtestDateLayout, *fromFlag); err != nil {
+				return fmt.Errorf("invalid --from: %v", err)
+			}
+		}
+		if *toFlag != "" {
+			if to, err = time.Parse(backtestDateLayout, *toFlag); err != nil {
+				return fmt.Errorf("invalid --to: %v", err)
+			}
+			to = to.Add(24 * time.Hour)
+		}
+		filtered := []AuditEntry{}
+		for _, entry := range entries {
+			if (from.IsZero() || !entry.Time.Before(from)) && (to.IsZero() || entry.Time.Before(to)) {
+				filtered = append(filtered, entry)
+			}
+		}
+
+		var w io.Writer = os.Stdout
+		if *out != "" {
+			file, err := os.Create(*out)
+			if err != nil {
+				return fmt.Errorf("error creating %v: %v", *out, err)
+			}
+			defer file.Close()
+			w = file
+		}
+		switch *format {
+		case "csv":
+			return writeAuditCSV(w, filtered)
+		case outputJSON:
+			encoder := json.NewEncoder(w)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(filtered)
+		default:
+			return fmt.Errorf("invalid export format %v, expected csv or %v", *format, outputJSON)
+		}
+	default:
+		return usage
+	}
+}
+
+func init() {
+	registerCommand("audit", Command{
+		Usage: "audit verify|export ...                      verify or export the audit log of write API calls",
+		Run:   runAuditCommand,
+	})
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"transferwisely/mocks"
+)
+
+func TestAuditLog(t *testing.T) {
+	defer func(v string) { auditLogVar = v }(auditLogVar)
+	defer func(v string) { apiTokenVar = v }(apiTokenVar)
+	dir, _ := ioutil.TempDir("", "transferwisely")
+	defer os.RemoveAll(dir)
+	auditLogVar = filepath.Join(dir, "audit.jsonl")
+	apiTokenVar = "api-token"
+	mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+		body := `{}`
+		switch {
+		case req.Method == http.MethodPost && strings.HasSuffix(req.URL.String(), quotesAPIPath):
+			body = `{"id": "quote-1", "rate": 100.5, "sourceAmount": 1000}`
+		case strings.HasSuffix(req.URL.String(), "/cancel"):
+			return &http.Response{StatusCode: http.StatusConflict, Body: ioutil.NopCloser(strings.NewReader(`{}`))}, nil
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(body))}, nil
+	}
+
+	_, err := callExternalAPI(http.MethodPost, quotesAPIPath, []byte(`{"sourceCurrency": "GBP", "clientSecret": "x", "note": "api-token"}`), nil)
+	assert.NoError(t, err)
+	_, err = callExternalAPI(http.MethodGet, liveRateAPIPath, nil, nil)
+	assert.NoError(t, err)
+	_, err = cancelTransfer(7)
+	assert.Error(t, err)
+
+	entries, err := readAuditLog(auditLogVar)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2, "reads aren't audited")
+	assert.Equal(t, auditQuoteCreated, entries[0].Action)
+	assert.JSONEq(t, `{"sourceCurrency": "GBP", "clientSecret": "[REDACTED]", "note": "[REDACTED]"}`, string(entries[0].Request))
+	assert.JSONEq(t, `{"id": "quote-1", "rate": 100.5, "sourceAmount": 1000}`, string(entries[0].Response))
+	assert.Equal(t, auditTransferCancelled, entries[1].Action)
+	assert.Equal(t, http.StatusConflict, entries[1].Status)
+	assert.NotEmpty(t, entries[1].Error)
+	assert.Equal(t, entries[0].Hash, entries[1].PrevHash)
+	assert.NoError(t, verifyAuditLog(entries))
+
+	t.Run("tampered entry", func(t *testing.T) {
+		tampered := append([]AuditEntry(nil), entries...)
+		tampered[0].Status = http.StatusCreated
+		assert.True(t, errors.Is(verifyAuditLog(tampered), errAuditChainBroken))
+	})
+
+	t.Run("removed entry", func(t *testing.T) {
+		assert.True(t, errors.Is(verifyAuditLog(entries[1:]), errAuditChainBroken))
+	})
+}
+
+func TestAuditAction(t *testing.T) {
+	assert.Equal(t, auditQuoteCreated, auditAction(http.MethodPost, "https://api.wise.com/v2/quotes"))
+	assert.Equal(t, auditTransferCreated, auditAction(http.MethodPost, "https://api.wise.com/v1/transfers"))
+	assert.Equal(t, auditTransferCancelled, auditAction(http.MethodPut, "https://api.wise.com/v1/transfers/7/cancel"))
+	assert.Equal(t, "PATCH v2/quotes/quote-1", auditAction(http.MethodPatch, "https://api.wise.com/v2/quotes/quote-1"))
+}
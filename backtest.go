@@ -0,0 +1,163 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// backtest related constants
+const (
+	backtestDateLayout = "2006-01-02"
+	backtestChunk      = 30 * 24 * time.Hour
+	rateTimeLayout     = "2006-01-02T15:04:05-0700"
+)
+
+// BacktestResult is how a strategy would have re-booked a transfer booked at the start of the rate history
+type BacktestResult struct {
+	Pair        string           `json:"pair"`
+	From        time.Time        `json:"from"`
+	To          time.Time        `json:"to"`
+	Strategy    string           `json:"strategy"`
+	Margin      Decimal          `json:"margin"`
+	Rates       int              `json:"rates"`
+	InitialRate float64          `json:"initialRate"`
+	FinalRate   float64          `json:"finalRate"`
+	Improvement float64          `json:"improvement"`
+	Rebooks     []BacktestRebook `json:"rebooks"`
+}
+
+// BacktestRebook is a re-booking the strategy would have made
+type BacktestRebook struct {
+	Time    time.Time `json:"time"`
+	OldRate float64   `json:"oldRate"`
+	NewRate float64   `json:"newRate"`
+}
+
+// Fetch the rate history between from and to in chunks, the rate history API not serving long ranges at once
+func getRateHistoryRange(source string, target string, from time.Time, to time.Time, group string) ([]LiveRate, error) {
+	var history []LiveRate
+	for start := from; start.Before(to); start = start.Add(backtestChunk) {
+		end := start.Add(backtestChunk)
+		if end.After(to) {
+			end = to
+		}
+		chunk, err := getRateHistory(source, target, start, end, group)
+		if err != nil {
+			return nil, err
+		}
+		for _, rate := range chunk {
+			// chunk boundaries are part of both chunks
+			if len(history) > 0 && rate.Time <= history[len(history)-1].Time {
+				continue
+			}
+			history = append(history, rate)
+		}
+	}
+	return history, nil
+}
+
+// Replay the rate history booking at its first rate and re-booking whenever the margin strategy and guardrails allow
+func backtest(history []LiveRate, settings Settings, cooldown time.Duration, maxRebooks int) (BacktestResult, error) {
+	if len(history) == 0 {
+		return BacktestResult{}, fmt.Errorf("backtest: no rate history to replay")
+	}
+
+	result := BacktestResult{
+		Strategy:    strategyMargin,
+		Margin:      settings.Margin,
+		Rates:       len(history),
+		InitialRate: history[0].Rate,
+		Rebooks:     []BacktestRebook{},
+	}
+	transfer := Transfer{Rate: history[0].Rate}
+	var rebooks []time.Time
+	for _, rate := range history[1:] {
+		rebook, err := marginStrategy{}.ShouldRebook(transfer, rate.Rate, settings)
+		if err != nil {
+			return BacktestResult{}, fmt.Errorf("backtest: %v", err)
+		}
+		if !rebook {
+			continue
+		}
+
+		at, err := time.Parse(rateTimeLayout, rate.Time)
+		if err != nil {
+			return BacktestResult{}, fmt.Errorf("backtest: invalid rate time: %v", err)
+		}
+		if rebookAllowed(rebooks, at, cooldown, maxRebooks) != nil {
+			continue
+		}
+		rebooks = append(rebooks, at)
+		result.Rebooks = append(result.Rebooks, BacktestRebook{Time: at.UTC(), OldRate: transfer.Rate, NewRate: rate.Rate})
+		transfer.Rate = rate.Rate
+	}
+
+	result.FinalRate = transfer.Rate
+	result.Improvement = result.FinalRate - result.InitialRate
+	return result, nil
+}
+
+func runBacktestCommand(args []string) error {
+	settings, err := getDefaultSettings()
+	if err != nil {
+		return err
+	}
+	cooldown, maxRebooks, err := getGuardrails()
+	if err != nil {
+		return err
+	}
+
+	flags := flag.NewFlagSet("backtest", flag.ContinueOnError)
+	pair := flags.String("pair", "", "currency pair like GBP-INR")
+	from := flags.String("from", "", "first day to replay, like 2023-01-01")
+	to := flags.String("to", "", "last day to replay, defaults to today")
+	flags.Var(&settings.Margin, "margin", "margin to backtest, defaults to MARGIN")
+	group := flags.String("group", rateHistoryGroup, "rate history granularity, minute, hour or day")
+	output := outputFlag(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	source, target, pairErr := splitPairKey(*pair)
+	start, err := time.Parse(backtestDateLayout, *from)
+	if pairErr != nil || err != nil {
+		return fmt.Errorf("usage: backtest --pair <source>-<target> --from <yyyy-mm-dd> [--to <yyyy-mm-dd>] [--margin <margin>] [--output json]")
+	}
+	end := time.Now().UTC()
+	if *to != "" {
+		end, err = time.Parse(backtestDateLayout, *to)
+		if err != nil {
+			return fmt.Errorf("invalid --to: %v", err)
+		}
+		end = end.Add(24 * time.Hour)
+	}
+	history, err := getRateHistoryRange(source, target, start, end, *group)
+	if err != nil {
+		return err
+	}
+	result, err := backtest(history, settings, cooldown, maxRebooks)
+	if err != nil {
+		return err
+	}
+	result.Pair, result.From, result.To = pairKey(source, target), start, end
+
+	return printOutput(os.Stdout, *output, result, func(w io.Writer) {
+		fmt.Fprintf(w, "Backtest of {%v} --> {%v} from %v to %v with margin %v over %v rates\n", source, target,
+			start.Format(backtestDateLayout), end.Format(backtestDateLayout), result.Margin, result.Rates)
+		for _, rebook := range result.Rebooks {
+			fmt.Fprintf(w, "  %v re-booked at %v (was %v)\n", rebook.Time.Format(time.RFC3339), rebook.NewRate, rebook.OldRate)
+		}
+		fmt.Fprintf(w, "%v re-bookings, rate %v --> %v, improvement %.6f (%.2f%%)\n", len(result.Rebooks),
+			result.InitialRate, result.FinalRate, result.Improvement, result.Improvement/result.InitialRate*100)
+	})
+}
+
+func init() {
+	registerCommand("backtest", Command{
+		Usage: "backtest --pair <src>-<tgt> --from <date>     replay rate history to tune MARGIN",
+		Run:   runBacktestCommand,
+	})
+}
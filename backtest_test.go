@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+	"transferwisely/mocks"
+)
+
+func TestBacktest(t *testing.T) {
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	var history []LiveRate
+	for i, rate := range []float64{100, 100.1, 100.25, 100.3, 100.6, 100.2, 100.9} {
+		history = append(history, LiveRate{Rate: rate, Time: start.Add(time.Duration(i) * time.Hour).Format(rateTimeLayout)})
+	}
+
+	result, err := backtest(history, Settings{Margin: decimalOf(0.2)}, 0, 0)
+	assert.NoError(t, err)
+	assert.Len(t, result.Rebooks, 3)
+	assert.Equal(t, 100.0, result.InitialRate)
+	assert.Equal(t, 100.9, result.FinalRate)
+	assert.InDelta(t, 0.9, result.Improvement, 1e-9)
+
+	t.Run("guardrails", func(t *testing.T) {
+		result, err := backtest(history, Settings{Margin: decimalOf(0.2)}, 150*time.Minute, 0)
+		assert.NoError(t, err)
+		assert.Len(t, result.Rebooks, 2)
+		assert.Equal(t, start.Add(2*time.Hour), result.Rebooks[0].Time)
+		assert.Equal(t, start.Add(6*time.Hour), result.Rebooks[1].Time)
+
+		result, err = backtest(history, Settings{Margin: decimalOf(0.2)}, 0, 1)
+		assert.NoError(t, err)
+		assert.Len(t, result.Rebooks, 1)
+	})
+
+	_, err = backtest(nil, Settings{Margin: decimalOf(0.2)}, 0, 0)
+	assert.Error(t, err)
+}
+
+func TestGetRateHistoryRange(t *testing.T) {
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	requests := 0
+	mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+		requests++
+		body := fmt.Sprintf(`[{"rate": 100, "source": "GBP", "target": "INR", "time": %q},
+			{"rate": 101, "source": "GBP", "target": "INR", "time": %q}]`,
+			start.Add(time.Duration(requests-1)*backtestChunk).Format(rateTimeLayout),
+			start.Add(time.Duration(requests)*backtestChunk).Format(rateTimeLayout))
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(body))}, nil
+	}
+
+	history, err := getRateHistoryRange("GBP", "INR", start, start.Add(2*backtestChunk+time.Hour), rateHistoryGroup)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, requests)
+	assert.Len(t, history, 4)
+}
+
+func TestBacktestCommandUsage(t *testing.T) {
+	assert.Error(t, runBacktestCommand([]string{"--pair", "GBP"}))
+	assert.Error(t, runBacktestCommand([]string{"--pair", "GBP-INR", "--from", "01/01/2023"}))
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// payment types and statuses for funding a transfer
+const (
+	paymentTypeBalance     = "BALANCE"
+	paymentStatusCompleted = "COMPLETED"
+)
+
+func getBalances(profile uint64) ([]Balance, error) {
+	path := strings.Replace(balancesAPIPath, "{profileId}", strconv.FormatUint(profile, 10), 1)
+	params := url.Values{"types": {"STANDARD"}}
+	url := &url.URL{RawQuery: params.Encode(), Host: hostVar, Scheme: "https", Path: path}
+
+	var balances []Balance
+	_, err := callExternalAPI(http.MethodGet, url.String(), nil, &balances)
+	if err != nil {
+		return nil, fmt.Errorf("error GET balances API: %w", err)
+	}
+
+	return balances, nil
+}
+
+// Find the balance held in the given currency, if any
+func getBalance(profile uint64, currency string) (Balance, error) {
+	balances, err := getBalances(profile)
+	if err != nil {
+		return Balance{}, fmt.Errorf("getBalance: %v", err)
+	}
+
+	for _, balance := range balances {
+		if balance.Currency == currency {
+			return balance, nil
+		}
+	}
+	return Balance{}, fmt.Errorf("error: no %v balance found for profile %v", currency, profile)
+}
+
+// Fund the transfer from the profile's multi-currency balance in its source currency
+func fundTransferFromBalance(transfer Transfer) error {
+	balance, err := getBalance(transfer.Profile, transfer.SourceCurrency)
+	if err != nil {
+		return fmt.Errorf("fundTransferFromBalance: %v", err)
+	}
+	if balance.Amount.Value.Cmp(transfer.SourceAmount) < 0 {
+		return fmt.Errorf("fundTransferFromBalance: insufficient %v balance %v to fund %v",
+			transfer.SourceCurrency, balance.Amount.Value, transfer.SourceAmount)
+	}
+
+	path := strings.NewReplacer(
+		"{profileId}", strconv.FormatUint(transfer.Profile, 10),
+		"{transferId}", strconv.FormatUint(transfer.Id, 10),
+	).Replace(fundTransferAPIPath)
+	request, _ := json.Marshal(FundTransferRequest{Type: paymentTypeBalance})
+
+	url := &url.URL{Host: hostVar, Scheme: "https", Path: path}
+	var payment Payment
+	_, err = callExternalAPI(http.MethodPost, url.String(), request, &payment)
+	if err != nil {
+		return fmt.Errorf("error POST fund transfer API: %w", err)
+	}
+	if payment.Status != paymentStatusCompleted {
+		return fmt.Errorf("error: funding transfer %v from balance %v: %v", transfer.Id, payment.Status, payment.ErrorCode)
+	}
+
+	return nil
+}
+
+// low balance notification
+const (
+	lowBalanceSubject = "Not enough %v to fund transfer %v"
+	lowBalanceText    = "Transfer ID: %v\n{%v} --> {%v}\nBooked Rate: %v\nAmount to pay in: %v %v\n%v balance: %v %v\n\n" +
+		"Top up the balance or pay in another way before the rate lock expires, or the rate is lost."
+)
+
+// balances of the booked transfer's profile as last polled, shown on the dashboard
+var balanceWatch = struct {
+	sync.Mutex
+	polledAt   time.Time
+	transferId uint64
+	balances   []Balance
+}{}
+
+// Poll the balances of the booked transfer's profile every BALANCE_CHECK_INTERVAL minutes, or as soon as another
+// transfer is booked, notifying once per transfer when its source currency balance can't fund it
+func watchBalance(transfer Transfer, now time.Time) {
+	interval, err := getBalanceCheckInterval()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if interval == 0 || transfer.Status != transferStatusBooked {
+		return
+	}
+	balanceWatch.Lock()
+	due := transfer.Id != balanceWatch.transferId || now.Sub(balanceWatch.polledAt) >= interval
+	balanceWatch.Unlock()
+	if !due {
+		return
+	}
+
+	balances, err := getBalances(transfer.Profile)
+	if err != nil {
+		log.Printf("watchBalance: %v", err)
+		return
+	}
+	balanceWatch.Lock()
+	balanceWatch.polledAt, balanceWatch.transferId, balanceWatch.balances = now, transfer.Id, balances
+	balanceWatch.Unlock()
+
+	var available Decimal
+	for _, balance := range balances {
+		if balance.Currency == transfer.SourceCurrency {
+			available = balance.Amount.Value
+		}
+	}
+	short := available.Cmp(transfer.SourceAmount) < 0
+
+	alerted := false
+	err = updateState(func(state *State) error {
+		if !short {
+			delete(state.LowBalances, transfer.Id)
+			return nil
+		}
+		if state.LowBalances == nil {
+			state.LowBalances = map[uint64]Decimal{}
+		}
+		_, alerted = state.LowBalances[transfer.Id]
+		state.LowBalances[transfer.Id] = available
+		return nil
+	})
+	if err != nil {
+		log.Printf("watchBalance: %v", err)
+		return
+	}
+	if !short || alerted {
+		return
+	}
+
+	log.Printf("|| LOW BALANCE || Transfer ID: %v | {%v} --> {%v} | Amount: %v | %v Balance: %v ||",
+		transfer.Id, transfer.SourceCurrency, transfer.TargetCurrency, transfer.SourceAmount, transfer.SourceCurrency, available)
+	notify(Event{
+		Kind:    EventLowBalance,
+		Subject: fmt.Sprintf(lowBalanceSubject, transfer.SourceCurrency, transfer.Id),
+		Text: fmt.Sprintf(lowBalanceText, transfer.Id, transfer.SourceCurrency, transfer.TargetCurrency, formatRate(transfer.Rate),
+			formatAmount(transfer.SourceAmount, transfer.SourceCurrency), transfer.SourceCurrency, transfer.SourceCurrency,
+			formatAmount(available, transfer.SourceCurrency), transfer.SourceCurrency),
+		Pair:       pairKey(transfer.SourceCurrency, transfer.TargetCurrency),
+		TransferId: transfer.Id,
+	})
+}
+
+// The balances as last polled by watchBalance, largest first
+func getWatchedBalances() []Balance {
+	balanceWatch.Lock()
+	defer balanceWatch.Unlock()
+	balances := append([]Balance(nil), balanceWatch.balances...)
+	sort.SliceStable(balances, func(i, j int) bool { return balances[i].Amount.Value.Cmp(balances[j].Amount.Value) > 0 })
+	return balances
+}
+
+func getBalanceCheckInterval() (time.Duration, error) {
+	minutes, err := strconv.ParseUint(balanceCheckIntervalVar, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value for BALANCE_CHECK_INTERVAL: %v", err)
+	}
+	return time.Duration(minutes) * time.Minute, nil
+}
+
+func runBalancesCommand(args []string) error {
+	flags := flag.NewFlagSet("balances", flag.ContinueOnError)
+	profile := flags.Uint64("profile", 0, "profile ID to list the balances of, defaults to PROFILE_ID")
+	output := outputFlag(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *profile == 0 {
+		configured, err := getConfiguredProfile()
+		if err != nil {
+			return err
+		}
+		*profile = configured
+	}
+	if *profile == 0 {
+		return fmt.Errorf("usage: balances --profile <id> [--output json], or set PROFILE_ID")
+	}
+
+	balances, err := getBalances(*profile)
+	if err != nil {
+		return err
+	}
+	if balances == nil {
+		balances = []Balance{}
+	}
+	return printOutput(os.Stdout, *output, balances, func(w io.Writer) {
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "CURRENCY\tAMOUNT")
+		for _, balance := range balances {
+			fmt.Fprintf(tw, "%v\t%v\n", balance.Currency, formatAmount(balance.Amount.Value, balance.Currency))
+		}
+		_ = tw.Flush()
+	})
+}
+
+func init() {
+	registerCommand("balances", Command{
+		Usage: "balances [--profile <id>]                    list the multi-currency balances of a profile",
+		Run:   runBalancesCommand,
+	})
+}
+
+type Balance struct {
+	Id       uint64 `json:"id"`
+	Currency string `json:"currency"`
+	Amount   Amount `json:"amount"`
+}
+
+type Amount struct {
+	Value    Decimal `json:"value"`
+	Currency string  `json:"currency"`
+}
+
+type FundTransferRequest struct {
+	Type string `json:"type"`
+}
+
+type Payment struct {
+	Type      string `json:"type"`
+	Status    string `json:"status"`
+	ErrorCode string `json:"errorCode"`
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"transferwisely/mocks"
+)
+
+func TestFundTransferFromBalance(t *testing.T) {
+	transfer := Transfer{Id: 10, Profile: 1, SourceCurrency: "EUR", SourceAmount: decimalOf(100)}
+	mockBalanceAPIs := func(balance float64, paymentStatus string) {
+		mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+			var j []byte
+			code := http.StatusOK
+			if strings.HasSuffix(req.URL.Path, "/payments") {
+				assert.Equal(t, http.MethodPost, req.Method)
+				code = http.StatusCreated
+				j, _ = json.Marshal(Payment{Type: paymentTypeBalance, Status: paymentStatus})
+			} else {
+				j, _ = json.Marshal([]Balance{{Id: 5, Currency: "EUR", Amount: Amount{Value: decimalOf(balance), Currency: "EUR"}}})
+			}
+			return &http.Response{
+				StatusCode: code,
+				Body:       ioutil.NopCloser(bytes.NewReader(j)),
+			}, nil
+		}
+	}
+
+	t.Run("success", func(t *testing.T) {
+		mockBalanceAPIs(150, paymentStatusCompleted)
+		assert.NoError(t, fundTransferFromBalance(transfer))
+	})
+
+	t.Run("insufficient balance", func(t *testing.T) {
+		mockBalanceAPIs(50, paymentStatusCompleted)
+		assert.Error(t, fundTransferFromBalance(transfer))
+	})
+
+	t.Run("payment rejected", func(t *testing.T) {
+		mockBalanceAPIs(150, "REJECTED")
+		assert.Error(t, fundTransferFromBalance(transfer))
+	})
+
+	t.Run("no balance in source currency", func(t *testing.T) {
+		mockBalanceAPIs(150, paymentStatusCompleted)
+		assert.Error(t, fundTransferFromBalance(Transfer{Id: 10, Profile: 1, SourceCurrency: "GBP", SourceAmount: decimalOf(100)}))
+	})
+}
+
+func TestWatchBalance(t *testing.T) {
+	defer func(file, interval, limit string) {
+		stateFileVar, balanceCheckIntervalVar, notifyRateLimitVar = file, interval, limit
+	}(stateFileVar, balanceCheckIntervalVar, notifyRateLimitVar)
+	dir, _ := ioutil.TempDir("", "transferwisely")
+	defer os.RemoveAll(dir)
+	stateFileVar, balanceCheckIntervalVar, notifyRateLimitVar = filepath.Join(dir, "state.json"), "60", "0"
+	fake := &fakeNotifier{}
+	defer func(n []Notifier) { Notifiers = n }(Notifiers)
+	Notifiers = []Notifier{fake}
+
+	balance, polls := 50.0, 0
+	mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+		polls++
+		j, _ := json.Marshal([]Balance{{Id: 5, Currency: "EUR", Amount: Amount{Value: decimalOf(balance), Currency: "EUR"}}})
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewReader(j))}, nil
+	}
+	transfer := Transfer{Id: 10, Profile: 1, SourceCurrency: "EUR", TargetCurrency: "USD", SourceAmount: decimalOf(100), Status: transferStatusBooked}
+	now := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	watchBalance(transfer, now)
+	assert.Len(t, fake.events, 1)
+	assert.Equal(t, EventLowBalance, fake.events[0].Kind)
+	assert.Equal(t, uint64(10), fake.events[0].TransferId)
+	assert.Equal(t, "EUR", getWatchedBalances()[0].Currency)
+
+	watchBalance(transfer, now.Add(30*time.Minute))
+	assert.Equal(t, 1, polls, "polled every BALANCE_CHECK_INTERVAL")
+
+	watchBalance(transfer, now.Add(time.Hour))
+	assert.Equal(t, 2, polls)
+	assert.Len(t, fake.events, 1, "notified once per transfer")
+
+	t.Run("topped up, then short again", func(t *testing.T) {
+		balance = 150
+		watchBalance(transfer, now.Add(2*time.Hour))
+		state, _ := loadState()
+		assert.Empty(t, state.LowBalances)
+
+		balance = 50
+		watchBalance(transfer, now.Add(3*time.Hour))
+		assert.Len(t, fake.events, 2)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		balanceCheckIntervalVar = "0"
+		polls = 0
+		watchBalance(Transfer{Id: 11, Status: transferStatusBooked}, now.Add(4*time.Hour))
+		assert.Zero(t, polls)
+	})
+}
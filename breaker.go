@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// longest wait between two probes of an open circuit
+const breakerMaxBackoff = time.Hour
+
+// api down and recovered notifications
+const (
+	apiDownSubject      = "Transferwise API is failing, calls are paused"
+	apiUnauthorizedText = "%v calls in a row were refused with 401 Unauthorized, the API token may have expired or been revoked.\nLast error: %v\n\nThe API gets probed again in %v."
+	apiFailingText      = "%v calls in a row failed, transferwise may be having an outage.\nLast error: %v\n\nThe API gets probed again in %v."
+	apiRecoveredSubject = "Transferwise API recovered, calls resumed"
+	apiRecoveredText    = "The API answered again after being down for %v."
+)
+
+var errCircuitOpen = errors.New("circuit breaker open")
+
+// circuit over the transfer-wise API calls: opened after CIRCUIT_BREAKER_THRESHOLD failures in a row, refusing calls
+// but a probe every backoff, doubling while the probes fail, until one succeeds
+var breaker = struct {
+	sync.Mutex
+	failures  uint64
+	open      bool
+	openedAt  time.Time
+	backoff   time.Duration
+	nextProbe time.Time
+}{}
+
+// Refuse the call while the circuit is open, but for a single probe once the backoff passed
+func allowAPICall(now time.Time) error {
+	breaker.Lock()
+	defer breaker.Unlock()
+
+	if !breaker.open {
+		return nil
+	}
+	if now.Before(breaker.nextProbe) {
+		return fmt.Errorf("%w after %v failed transferwise api calls, next probe at %v", errCircuitOpen, breaker.failures,
+			breaker.nextProbe.Format(time.RFC3339))
+	}
+	// calls made while the probe is in flight wait for the next one
+	breaker.nextProbe = now.Add(breaker.backoff)
+	return nil
+}
+
+// Count the call toward the circuit: network errors, 401 and 5xx responses are failures, any other response means
+// the API is up, including the 4xx ones rejecting a single request
+func recordAPIResult(code int, err error, now time.Time) {
+	threshold, backoff, configErr := getCircuitBreaker()
+	if configErr != nil || threshold == 0 {
+		return
+	}
+	failed := err != nil && (code == http.StatusUnauthorized || code >= http.StatusInternalServerError)
+
+	breaker.Lock()
+	if !failed {
+		wasOpen, openedAt := breaker.open, breaker.openedAt
+		breaker.failures, breaker.open = 0, false
+		breaker.Unlock()
+		if wasOpen {
+			log.Printf("|| CIRCUIT BREAKER CLOSED, TRANSFERWISE API RECOVERED || Down for: %v ||", now.Sub(openedAt).Round(time.Second))
+			notify(Event{Kind: EventAPIRecovered, Subject: apiRecoveredSubject,
+				Text: fmt.Sprintf(apiRecoveredText, now.Sub(openedAt).Round(time.Second))})
+		}
+		return
+	}
+
+	breaker.failures++
+	failures := breaker.failures
+	if breaker.open {
+		// a failed probe, back off further
+		breaker.backoff *= 2
+		if breaker.backoff > breakerMaxBackoff {
+			breaker.backoff = breakerMaxBackoff
+		}
+		breaker.nextProbe = now.Add(breaker.backoff)
+		breaker.Unlock()
+		return
+	}
+	if failures < threshold {
+		breaker.Unlock()
+		return
+	}
+	breaker.open, breaker.openedAt, breaker.backoff = true, now, backoff
+	breaker.nextProbe = now.Add(backoff)
+	breaker.Unlock()
+
+	text := apiFailingText
+	if code == http.StatusUnauthorized {
+		text = apiUnauthorizedText
+	}
+	log.Printf("|| CIRCUIT BREAKER OPEN || Failed calls: %v | Last error: %v | Next probe in: %v ||", failures, err, backoff)
+	notify(Event{Kind: EventAPIDown, Subject: apiDownSubject, Text: fmt.Sprintf(text, failures, err, backoff)})
+}
+
+// Whether calls to transfer-wise API are currently refused
+func isCircuitOpen() bool {
+	breaker.Lock()
+	defer breaker.Unlock()
+	return breaker.open
+}
+
+func getCircuitBreaker() (threshold uint64, backoff time.Duration, err error) {
+	threshold, err = strconv.ParseUint(circuitBreakerThresholdVar, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid value for CIRCUIT_BREAKER_THRESHOLD: %v", circuitBreakerThresholdVar)
+	}
+	minutes, err := strconv.ParseUint(circuitBreakerBackoffVar, 10, 64)
+	if err != nil || minutes == 0 {
+		return 0, 0, fmt.Errorf("invalid value for CIRCUIT_BREAKER_BACKOFF: %v", circuitBreakerBackoffVar)
+	}
+	return threshold, time.Duration(minutes) * time.Minute, nil
+}
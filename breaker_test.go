@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+	"transferwisely/mocks"
+)
+
+func resetBreaker() {
+	breaker.Lock()
+	breaker.failures, breaker.open, breaker.openedAt, breaker.backoff, breaker.nextProbe = 0, false, time.Time{}, 0, time.Time{}
+	breaker.Unlock()
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	defer func(threshold, backoff, limit string) {
+		circuitBreakerThresholdVar, circuitBreakerBackoffVar, notifyRateLimitVar = threshold, backoff, limit
+	}(circuitBreakerThresholdVar, circuitBreakerBackoffVar, notifyRateLimitVar)
+	circuitBreakerThresholdVar, circuitBreakerBackoffVar, notifyRateLimitVar = "3", "1", "0"
+	defer resetBreaker()
+
+	fake := &fakeNotifier{}
+	defer func(n []Notifier) { Notifiers = n }(Notifiers)
+	Notifiers = []Notifier{fake}
+
+	now := time.Now().UTC()
+	outage := errors.New("503 Service Unavailable")
+
+	t.Run("validation errors don't count", func(t *testing.T) {
+		resetBreaker()
+		for i := 0; i < 5; i++ {
+			recordAPIResult(http.StatusUnprocessableEntity, errors.New("QUOTE_EXPIRED"), now)
+		}
+		assert.False(t, isCircuitOpen())
+	})
+
+	t.Run("a success resets the count", func(t *testing.T) {
+		resetBreaker()
+		recordAPIResult(http.StatusServiceUnavailable, outage, now)
+		recordAPIResult(http.StatusServiceUnavailable, outage, now)
+		recordAPIResult(http.StatusOK, nil, now)
+		recordAPIResult(http.StatusServiceUnavailable, outage, now)
+		assert.False(t, isCircuitOpen())
+	})
+
+	t.Run("opens after consecutive failures, probing with backoff until recovery", func(t *testing.T) {
+		resetBreaker()
+		fake.events = nil
+		for i := 0; i < 3; i++ {
+			assert.NoError(t, allowAPICall(now))
+			recordAPIResult(http.StatusUnauthorized, errors.New("401 Unauthorized"), now)
+		}
+		assert.True(t, isCircuitOpen())
+		assert.Len(t, fake.events, 1, "a single alert")
+		assert.Equal(t, EventAPIDown, fake.events[0].Kind)
+		assert.Contains(t, fake.events[0].Text, "API token may have expired")
+
+		err := allowAPICall(now.Add(30 * time.Second))
+		assert.True(t, errors.Is(err, errCircuitOpen))
+
+		// the probe after a minute fails, the next one waits two
+		assert.NoError(t, allowAPICall(now.Add(time.Minute)))
+		assert.Error(t, allowAPICall(now.Add(time.Minute)), "a single probe at a time")
+		recordAPIResult(http.StatusUnauthorized, errors.New("401 Unauthorized"), now.Add(time.Minute))
+		assert.Error(t, allowAPICall(now.Add(2*time.Minute)))
+		assert.Len(t, fake.events, 1)
+
+		assert.NoError(t, allowAPICall(now.Add(3*time.Minute)))
+		recordAPIResult(http.StatusOK, nil, now.Add(3*time.Minute))
+		assert.False(t, isCircuitOpen())
+		assert.NoError(t, allowAPICall(now.Add(3*time.Minute)))
+		assert.Len(t, fake.events, 2)
+		assert.Equal(t, EventAPIRecovered, fake.events[1].Kind)
+	})
+
+	t.Run("API calls are refused while open", func(t *testing.T) {
+		resetBreaker()
+		calls := 0
+		mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+			calls++
+			return &http.Response{StatusCode: http.StatusBadGateway, Body: ioutil.NopCloser(strings.NewReader(`{}`))}, nil
+		}
+		for i := 0; i < 5; i++ {
+			_, err := getProfiles()
+			assert.Error(t, err)
+		}
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("disabled with a 0 threshold", func(t *testing.T) {
+		resetBreaker()
+		circuitBreakerThresholdVar = "0"
+		for i := 0; i < 5; i++ {
+			recordAPIResult(http.StatusServiceUnavailable, outage, now)
+		}
+		assert.False(t, isCircuitOpen())
+	})
+}
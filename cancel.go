@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// cancel refused notification
+const (
+	cancelRefusedSubject = "Transfer %v is %v, not cancelling it"
+	cancelRefusedText    = "Transfer %v ({%v} --> {%v}, %v %v) moved past %v to %v since it was listed, its payment may " +
+		"already be on its way, so it was left alone."
+	cancelRefusedNewText = "\n\nThe re-booking's transfer %v was cancelled instead."
+	cancelFailedNewText  = "\n\nCancelling the re-booking's transfer %v failed too, cancel it in Wise: %v"
+)
+
+var errTransferFunded = errors.New("transfer already funded")
+
+func getTransfer(transferId uint64) (Transfer, error) {
+	path := strings.Replace(transferAPIPath, "{transferId}", strconv.FormatUint(transferId, 10), 1)
+	url := &url.URL{Host: hostVar, Scheme: "https", Path: path}
+	var transfer Transfer
+	_, err := callExternalAPI(http.MethodGet, url.String(), nil, &transfer)
+	if err != nil {
+		return Transfer{}, fmt.Errorf("error GET transfer API: %w", err)
+	}
+	return transfer, nil
+}
+
+// Re-fetch the transfer, the listing it came from may be stale, and refuse with errTransferFunded when its status
+// moved past incoming_payment_waiting, as cancelling it could orphan a payment in flight
+func checkUnfunded(transfer Transfer) (Transfer, error) {
+	current, err := getTransfer(transfer.Id)
+	if err != nil {
+		return Transfer{}, fmt.Errorf("checkUnfunded: %v", err)
+	}
+	if current.Status != transferStatusBooked {
+		return current, fmt.Errorf("%w: transfer %v is %v", errTransferFunded, transfer.Id, current.Status)
+	}
+	return current, nil
+}
+
+// Cancel the transfer a re-booking replaced, unless it got funded meanwhile. Then the re-booking's transfer is
+// cancelled instead, the funded one being the one to keep, and errTransferFunded tells whether that failed too
+func cancelReplacedTransfer(oldTransfer Transfer, newTransfer Transfer) error {
+	if err := checkNotPinned(oldTransfer); err != nil {
+		return err
+	}
+	current, err := checkUnfunded(oldTransfer)
+	if errors.Is(err, errTransferFunded) {
+		text := fmt.Sprintf(cancelRefusedText, oldTransfer.Id, oldTransfer.SourceCurrency, oldTransfer.TargetCurrency,
+			formatAmount(oldTransfer.SourceAmount, oldTransfer.SourceCurrency), oldTransfer.SourceCurrency, transferStatusBooked,
+			current.Status)
+		_, cancelErr := cancelTransfer(newTransfer.Id)
+		if cancelErr != nil {
+			text += fmt.Sprintf(cancelFailedNewText, newTransfer.Id, cancelErr)
+		} else {
+			text += fmt.Sprintf(cancelRefusedNewText, newTransfer.Id)
+		}
+		log.Printf("|| CANCEL REFUSED || Transfer ID: %v | Status: %v | New Transfer ID: %v ||", oldTransfer.Id,
+			current.Status, newTransfer.Id)
+		notify(Event{
+			Kind:       EventCancelRefused,
+			Subject:    fmt.Sprintf(cancelRefusedSubject, oldTransfer.Id, current.Status),
+			Text:       text,
+			Pair:       pairKey(oldTransfer.SourceCurrency, oldTransfer.TargetCurrency),
+			TransferId: oldTransfer.Id,
+		})
+		if cancelErr != nil {
+			return fmt.Errorf("%w, and cancelling the re-booking's transfer %v failed: %v", err, newTransfer.Id, cancelErr)
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	_, err = cancelTransfer(oldTransfer.Id)
+	return err
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"log"
+	"transferwisely/vcr"
+)
+
+// Record the transferwise API calls of the batch to the cassette file, the API token and the other secrets redacted,
+// so they can be replayed in regression tests and offline demos
+func startRecording(file string) {
+	Client = vcr.NewRecorder(file, Client, vcr.WithRedact(configuredSecrets()...))
+	log.Printf("|| VCR || Recording the API calls to %v ||", file)
+}
+
+// Answer the transferwise API calls of the batch from the cassette file, without any network access
+func startReplay(file string) error {
+	replayer, err := vcr.NewReplayer(file, vcr.WithRedact(configuredSecrets()...))
+	if err != nil {
+		return err
+	}
+	Client = replayer
+	if hostVar == "" {
+		hostVar = hostSandbox
+	}
+	if apiTokenVar == "" {
+		apiTokenVar = "replay"
+	}
+	log.Printf("|| VCR || Replaying the API calls from %v ||", file)
+	return nil
+}
+
+// The values of the configured secrets, redacted from cassettes
+func configuredSecrets() []string {
+	var values []string
+	for _, secret := range secretVars {
+		values = append(values, *secret.value)
+	}
+	return values
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// output formats of the CLI commands
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+// Command is a CLI subcommand, running the batch without any starts the daemon
+type Command struct {
+	Usage string
+	Run   func(args []string) error
+}
+
+var commands = map[string]Command{}
+
+// ExitError ends a command with its own exit code and no message, like check --exit-code telling its outcome
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit code %v", e.Code)
+}
+
+func registerCommand(name string, command Command) {
+	commands[name] = command
+}
+
+// Run the named command, returning the process exit code
+func runCommand(name string, args []string) int {
+	command, ok := commands[name]
+	if !ok {
+		printUsage()
+		return 2
+	}
+
+	err := command.Run(args)
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func printUsage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(os.Stderr, "Usage: transferwisely [command]\n\nWithout a command the batch server is started.\n\nCommands:")
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %v\n", commands[name].Usage)
+	}
+}
+
+// Add the --output flag to the command's flags
+func outputFlag(flags *flag.FlagSet) *string {
+	return flags.String("output", outputText, "output format, text or json")
+}
+
+// Print v as JSON with the json output format, or with text otherwise
+func printOutput(w io.Writer, format string, v interface{}, text func(w io.Writer)) error {
+	switch format {
+	case outputJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	case outputText:
+		text(w)
+		return nil
+	default:
+		return fmt.Errorf("invalid output format %v, expected %v or %v", format, outputText, outputJSON)
+	}
+}
+
+func runCheckCommand(args []string) error {
+	flags := flag.NewFlagSet("check", flag.ContinueOnError)
+	output := outputFlag(flags)
+	pair := flags.String("pair", "", "currency pair like GBP-INR to check against --booked-rate, without listing transfers")
+	bookedRate := flags.String("booked-rate", "", "booked rate to compare the live rate of --pair to, - reading it from stdin")
+	margin := flags.Float64("margin", -1, "margin of --pair, defaults to its configured one")
+	amount := flags.Float64("amount", 0, "source amount of --pair, for MIN_GAIN")
+	exitCode := flags.Bool("exit-code", false, "with --pair, exit with 0 when it's worth re-booking, 1 when not and 2 on error")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := loadConfig(); err != nil {
+		return err
+	}
+	if *pair != "" || *bookedRate != "" {
+		return runPairCheck(os.Stdin, os.Stdout, *output, PairCheckRequest{Pair: *pair, BookedRate: *bookedRate,
+			Margin: *margin, Amount: *amount}, *exitCode)
+	}
+
+	lock, err := acquireInstanceLock("check command")
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	check := runCheck()
+	return printOutput(os.Stdout, *output, check, func(w io.Writer) {
+		fmt.Fprintf(w, "Check done: %v\n", check.Action)
+	})
+}
+
+func runTransfersCommand(args []string) error {
+	if len(args) > 0 && args[0] == "clone" {
+		return runCloneCommand(args[1:])
+	}
+	if len(args) > 0 && args[0] == "create" {
+		return runCreateCommand(args[1:])
+	}
+	if len(args) == 0 || args[0] != "list" {
+		return fmt.Errorf("usage: transfers list [--status <status>] [--output json] | transfers clone <transferId> --target-account <id> | " +
+			"transfers create --pair <source>-<target> --amount <amount> --recipient <id>")
+	}
+	flags := flag.NewFlagSet("transfers list", flag.ContinueOnError)
+	status := flags.String("status", transferStatusBooked, "status of the transfers to list")
+	limit := flags.Int("limit", 100, "maximum number of transfers to list")
+	output := outputFlag(flags)
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	transfers, err := listTransfers(*status, *limit)
+	if err != nil {
+		return err
+	}
+	return printOutput(os.Stdout, *output, transfers, func(w io.Writer) {
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tPAIR\tRATE\tQUOTE")
+		for _, transfer := range transfers {
+			fmt.Fprintf(tw, "%v\t%v\t%v\t%v\n", transfer.Id, pairKey(transfer.SourceCurrency, transfer.TargetCurrency),
+				transfer.Rate, transfer.QuoteUuid)
+		}
+		_ = tw.Flush()
+	})
+}
+
+func runRatesCommand(args []string) error {
+	flags := flag.NewFlagSet("rates", flag.ContinueOnError)
+	source := flags.String("source", "", "source currency")
+	target := flags.String("target", "", "target currency")
+	output := outputFlag(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *source == "" || *target == "" {
+		return fmt.Errorf("usage: rates --source <currency> --target <currency> [--output json]")
+	}
+
+	liveRate, err := getLiveRateDetail(strings.ToUpper(*source), strings.ToUpper(*target))
+	if err != nil {
+		return err
+	}
+	return printOutput(os.Stdout, *output, liveRate, func(w io.Writer) {
+		fmt.Fprintf(w, "{%v} --> {%v}: %v at %v\n", liveRate.Source, liveRate.Target, liveRate.Rate, liveRate.Time)
+	})
+}
+
+func runQuoteCommand(args []string) error {
+	flags := flag.NewFlagSet("quote", flag.ContinueOnError)
+	source := flags.String("source", "", "source currency")
+	target := flags.String("target", "", "target currency")
+	amount := flags.Float64("amount", 0, "source amount")
+	targetAmount := flags.Float64("target-amount", 0, "target amount the recipient gets, instead of --amount")
+	profile := flags.Uint64("profile", 0, "profile ID to quote for, defaults to PROFILE_ID")
+	output := outputFlag(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *profile == 0 {
+		configured, err := getConfiguredProfile()
+		if err != nil {
+			return err
+		}
+		*profile = configured
+	}
+	if *source == "" || *target == "" || (*amount <= 0) == (*targetAmount <= 0) || *profile == 0 {
+		return fmt.Errorf("usage: quote --source <currency> --target <currency> (--amount <amount> | --target-amount <amount>) [--profile <id>] [--output json]")
+	}
+
+	var quote QuoteDetail
+	var err error
+	if *targetAmount > 0 {
+		quote, err = generateTargetQuoteDetail(strings.ToUpper(*source), strings.ToUpper(*target), decimalOf(*targetAmount), *profile)
+	} else {
+		quote, err = generateQuoteDetail(strings.ToUpper(*source), strings.ToUpper(*target), decimalOf(*amount), *profile)
+	}
+	if err != nil {
+		return err
+	}
+	return printOutput(os.Stdout, *output, quote, func(w io.Writer) {
+		fmt.Fprintf(w, "Quote %v: %v %v --> {%v} at %v, expires %v\n", quote.Id, quote.SourceAmount, quote.SourceCurrency,
+			quote.TargetCurrency, quote.Rate, quote.RateExpirationTime)
+	})
+}
+
+func init() {
+	registerCommand("check", Command{
+		Usage: "check [--pair <pair> --booked-rate <rate>]   run a single check, re-booking if needed",
+		Run:   runCheckCommand,
+	})
+	registerCommand("transfers", Command{
+		Usage: "transfers list|clone|create ...              list, clone or create transfers",
+		Run:   runTransfersCommand,
+	})
+	registerCommand("rates", Command{
+		Usage: "rates --source <cur> --target <cur>         show the live rate of a currency pair",
+		Run:   runRatesCommand,
+	})
+	registerCommand("quote", Command{
+		Usage: "quote --source <cur> --target <cur> --amount <amount>  create a quote",
+		Run:   runQuoteCommand,
+	})
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"transferwisely/mocks"
+)
+
+func TestPrintOutput(t *testing.T) {
+	liveRate := LiveRate{Rate: 0.691, Source: "JPY", Target: "INR"}
+	text := func(w io.Writer) { fmt.Fprint(w, "text") }
+
+	var buf bytes.Buffer
+	assert.NoError(t, printOutput(&buf, outputJSON, liveRate, text))
+	var decoded LiveRate
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Equal(t, liveRate, decoded)
+
+	buf.Reset()
+	assert.NoError(t, printOutput(&buf, outputText, liveRate, text))
+	assert.Equal(t, "text", buf.String())
+
+	assert.Error(t, printOutput(&buf, "yaml", liveRate, text))
+}
+
+func TestRunCheck(t *testing.T) {
+	defer func(host, token string) { hostVar, apiTokenVar = host, token }(hostVar, apiTokenVar)
+
+	hostVar, apiTokenVar = "", ""
+	check := runCheck()
+	assert.Equal(t, checkActionError, check.Action)
+	assert.Equal(t, ErrEnvVarMissingOrInvalid.Error(), check.Error)
+
+	hostVar, apiTokenVar = hostSandbox, "token"
+	mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(`[]`))}, nil
+	}
+	check = runCheck()
+	assert.Equal(t, checkActionError, check.Action)
+	assert.Contains(t, check.Error, ErrNoTrackedTransfer.Error())
+}
+
+func TestCommandUsage(t *testing.T) {
+	assert.Error(t, runTransfersCommand(nil))
+	assert.Error(t, runRatesCommand([]string{"--source", "GBP"}))
+	assert.Error(t, runQuoteCommand([]string{"--source", "GBP", "--target", "INR"}))
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// price comparison API of transfer-wise, public data of what banks and other providers charge
+const comparisonsAPIPath = "v3/comparisons/"
+
+// number of providers the rebooked notification compares the booked rate to
+const comparisonProviders = 5
+
+// transfer-wise's own alias in the price comparison API, left out as the booked rate is its own
+const comparisonSelfAlias = "wise"
+
+// comparisons of the rebooked notification
+const (
+	comparisonText     = "\n\nCompared to other providers for %v %v:\n%v"
+	comparisonLineText = "%v: %v, recipient gets %v %v"
+)
+
+// ComparisonResponse is the response of the price comparison API
+type ComparisonResponse struct {
+	Providers []struct {
+		Alias  string `json:"alias"`
+		Name   string `json:"name"`
+		Type   string `json:"type"`
+		Quotes []struct {
+			Rate           float64 `json:"rate"`
+			Fee            Decimal `json:"fee"`
+			ReceivedAmount Decimal `json:"receivedAmount"`
+		} `json:"quotes"`
+	} `json:"providers"`
+}
+
+// ProviderQuote is what a bank or another provider would give for the same source amount
+type ProviderQuote struct {
+	Name           string  `json:"name"`
+	Type           string  `json:"type"`
+	Rate           float64 `json:"rate"`
+	Fee            Decimal `json:"fee"`
+	ReceivedAmount Decimal `json:"receivedAmount"`
+}
+
+func isComparingProviders() bool {
+	compare, _ := strconv.ParseBool(compareProvidersVar)
+	return compare
+}
+
+// Fetch what the other providers would give for the source amount, best first
+func getProviderQuotes(source string, target string, sourceAmount Decimal) ([]ProviderQuote, error) {
+	query := url.Values{}
+	query.Set("sourceCurrency", source)
+	query.Set("targetCurrency", target)
+	query.Set("sendAmount", sourceAmount.String())
+	url := &url.URL{Host: hostVar, Scheme: "https", Path: comparisonsAPIPath, RawQuery: query.Encode()}
+
+	var response ComparisonResponse
+	_, err := callExternalAPI(http.MethodGet, url.String(), nil, &response)
+	if err != nil {
+		return nil, fmt.Errorf("error GET comparisons API: %w", err)
+	}
+
+	var quotes []ProviderQuote
+	for _, provider := range response.Providers {
+		if strings.EqualFold(provider.Alias, comparisonSelfAlias) || len(provider.Quotes) == 0 {
+			continue
+		}
+		quote := provider.Quotes[0]
+		quotes = append(quotes, ProviderQuote{Name: provider.Name, Type: provider.Type, Rate: quote.Rate, Fee: quote.Fee,
+			ReceivedAmount: quote.ReceivedAmount})
+	}
+	sort.SliceStable(quotes, func(i, j int) bool { return quotes[i].ReceivedAmount.Cmp(quotes[j].ReceivedAmount) > 0 })
+	if len(quotes) > comparisonProviders {
+		quotes = quotes[:comparisonProviders]
+	}
+	return quotes, nil
+}
+
+func formatComparison(transfer Transfer, quotes []ProviderQuote) string {
+	if len(quotes) == 0 {
+		return ""
+	}
+	lines := make([]string, len(quotes))
+	for i, quote := range quotes {
+		lines[i] = fmt.Sprintf(comparisonLineText, quote.Name, formatRate(quote.Rate),
+			formatAmount(quote.ReceivedAmount, transfer.TargetCurrency), transfer.TargetCurrency)
+	}
+	return fmt.Sprintf(comparisonText, formatAmount(transfer.SourceAmount, transfer.SourceCurrency), transfer.SourceCurrency, strings.Join(lines, "\n"))
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"transferwisely/mocks"
+)
+
+func TestGetProviderQuotes(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+			assert.Contains(t, req.URL.String(), comparisonsAPIPath)
+			assert.Equal(t, "GBP", req.URL.Query().Get("sourceCurrency"))
+			assert.Equal(t, "INR", req.URL.Query().Get("targetCurrency"))
+			assert.Equal(t, "1000", req.URL.Query().Get("sendAmount"))
+			return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(`{"providers": [
+				{"alias": "wise", "name": "Wise", "type": "moneyTransferProvider", "quotes": [{"rate": 104.1, "fee": 4, "receivedAmount": 103683}]},
+				{"alias": "barclays", "name": "Barclays", "type": "bank", "quotes": [{"rate": 101.2, "fee": 0, "receivedAmount": 101200}]},
+				{"alias": "empty", "name": "Empty", "type": "bank", "quotes": []},
+				{"alias": "remitly", "name": "Remitly", "type": "moneyTransferProvider", "quotes": [{"rate": 103.5, "fee": 2, "receivedAmount": 103293}]}
+			]}`))}, nil
+		}
+
+		quotes, err := getProviderQuotes("GBP", "INR", decimalOf(1000))
+		assert.NoError(t, err)
+		assert.Equal(t, []ProviderQuote{
+			{Name: "Remitly", Type: "moneyTransferProvider", Rate: 103.5, Fee: decimalOf(2), ReceivedAmount: decimalOf(103293)},
+			{Name: "Barclays", Type: "bank", Rate: 101.2, ReceivedAmount: decimalOf(101200)},
+		}, quotes)
+		assert.Equal(t, "\n\nCompared to other providers for 1000.00 GBP:\nRemitly: 103.5, recipient gets 103293.00 INR\n"+
+			"Barclays: 101.2, recipient gets 101200.00 INR",
+			formatComparison(Transfer{SourceAmount: decimalOf(1000), SourceCurrency: "GBP", TargetCurrency: "INR"}, quotes))
+	})
+
+	t.Run("external api error", func(t *testing.T) {
+		mocks.GetDoFunc = func(*http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusBadRequest, Body: ioutil.NopCloser(strings.NewReader("{}"))}, nil
+		}
+		_, err := getProviderQuotes("GBP", "INR", decimalOf(1000))
+		assert.Error(t, err)
+	})
+
+	assert.Empty(t, formatComparison(Transfer{}, nil))
+}
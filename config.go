@@ -0,0 +1,517 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config is read from the JSON CONFIG_FILE and overrides the global env variables per currency pair, transfer purpose or transfer
+type Config struct {
+	Pairs          map[string]Overrides `json:"pairs"`
+	Purposes       map[string]Overrides `json:"purposes"`
+	Transfers      map[string]Overrides `json:"transfers"`
+	Pushover       PushoverConfig       `json:"pushover"`
+	Notifications  NotificationsConfig  `json:"notifications"`
+	SourceRankings []SourceRanking      `json:"sourceRankings"`
+	Accounts       map[string]Account   `json:"accounts"`
+}
+
+// PushoverConfig maps event kinds to their Pushover priority
+type PushoverConfig struct {
+	Priorities map[EventKind]int `json:"priorities"`
+}
+
+// NotificationsConfig routes event kinds to the channels notified about them, kinds left out going to every channel
+type NotificationsConfig struct {
+	Routes map[EventKind][]string `json:"routes"`
+}
+
+// Overrides of the global settings, unset fields fall back to the global ones
+type Overrides struct {
+	Margin   *Decimal `json:"margin,omitempty"`
+	Interval *uint64  `json:"interval,omitempty"`
+	Amount   *Decimal `json:"amount,omitempty"`
+	Strategy string   `json:"strategy,omitempty"`
+	Profile  *uint64  `json:"profile,omitempty"`
+
+	MovingAverageHours *uint64  `json:"movingAverageHours,omitempty"`
+	TrailingStop       *float64 `json:"trailingStop,omitempty"`
+	MinGain            *Decimal `json:"minGain,omitempty"`
+	Direction          string   `json:"direction,omitempty"`
+	AmountMode         string   `json:"amountMode,omitempty"`
+	TargetAmount       *Decimal `json:"targetAmount,omitempty"`
+	Windows            *string  `json:"windows,omitempty"`
+	OffWindowInterval  *uint64  `json:"offWindowInterval,omitempty"`
+	TargetAccount      *uint64  `json:"targetAccount,omitempty"`
+	PayIn              *string  `json:"payIn,omitempty"`
+	PayOut             string   `json:"payOut,omitempty"`
+	MarginDecay        string   `json:"marginDecay,omitempty"`
+	MarginDecayHours   *uint64  `json:"marginDecayHours,omitempty"`
+	MarginFloor        *Decimal `json:"marginFloor,omitempty"`
+	Reference          *string  `json:"reference,omitempty"`
+	Pinned             bool     `json:"pinned,omitempty"`
+	MaxFee             *string  `json:"maxFee,omitempty"`
+	AdaptiveInterval   *string  `json:"adaptiveInterval,omitempty"`
+
+	// who a business profile sends the transfers on behalf of, see originator.go
+	Originator *Originator `json:"originator,omitempty"`
+}
+
+// Settings a transfer is checked and re-booked with
+type Settings struct {
+	Margin   Decimal
+	Interval uint64
+	Amount   Decimal
+	Strategy string
+	Profile  uint64
+
+	MovingAverageHours uint64
+	TrailingStop       float64
+	MinGain            Decimal
+	LowerIsBetter      bool
+	KeepTargetAmount   bool
+	TargetAmount       Decimal
+	Windows            []CheckWindow
+	OffWindowInterval  uint64
+	TargetAccount      uint64
+	PayIn              string
+	PayOut             string
+	MarginDecay        string
+	MarginDecayHours   uint64
+	MarginFloor        Decimal
+	Reference          string
+	Originator         *Originator
+	MaxFee             FeeCap
+	AdaptiveInterval   IntervalRange
+}
+
+var config = struct {
+	sync.RWMutex
+	current Config
+}{}
+
+// pairCheck keys the checks of a currency pair by the account tracking it, accounts possibly tracking the same pair
+type pairCheck struct {
+	account string
+	pair    string
+}
+
+// last check of each currency pair, to honor per pair intervals, and when the pairs whose quote got deferred are
+// checked again regardless
+var pairChecks = struct {
+	sync.Mutex
+	checkedAt map[pairCheck]time.Time
+	retryAt   map[pairCheck]time.Time
+}{checkedAt: map[pairCheck]time.Time{}, retryAt: map[pairCheck]time.Time{}}
+
+// The check of the pair by the account being checked
+func accountPairCheck(pair string) pairCheck {
+	return pairCheck{account: getCurrentAccount(), pair: pair}
+}
+
+// Read and validate CONFIG_FILE, if any
+func loadConfig() error {
+	if configFileVar == "" {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(configFileVar)
+	if err != nil {
+		return fmt.Errorf("error reading config file: %v", err)
+	}
+
+	var newConfig Config
+	err = json.Unmarshal(data, &newConfig)
+	if err != nil {
+		return fmt.Errorf("error decoding config file: %v", err)
+	}
+	newConfig.Pairs, err = normalizePairs(newConfig.Pairs)
+	if err != nil {
+		return err
+	}
+	for i, ranking := range newConfig.SourceRankings {
+		for j, source := range ranking.Sources {
+			newConfig.SourceRankings[i].Sources[j] = strings.ToUpper(strings.TrimSpace(source))
+		}
+		newConfig.SourceRankings[i].Target = strings.ToUpper(strings.TrimSpace(ranking.Target))
+	}
+	err = validateOverrides(newConfig)
+	if err != nil {
+		return err
+	}
+	err = loadAccounts(newConfig)
+	if err != nil {
+		return err
+	}
+
+	accountMutex.Lock()
+	defer accountMutex.Unlock()
+	config.Lock()
+	config.current = newConfig
+	config.Unlock()
+	return nil
+}
+
+func getConfig() Config {
+	config.RLock()
+	defer config.RUnlock()
+
+	return config.current
+}
+
+func validateOverrides(c Config) error {
+	all := map[string]Overrides{}
+	for pair, overrides := range c.Pairs {
+		all["pair "+pair] = overrides
+	}
+	for purpose, overrides := range c.Purposes {
+		all["purpose "+purpose] = overrides
+	}
+	for transferId, overrides := range c.Transfers {
+		if _, err := strconv.ParseUint(transferId, 10, 64); err != nil {
+			return fmt.Errorf("invalid transfer ID %v in config file: %v", transferId, err)
+		}
+		all["transfer "+transferId] = overrides
+	}
+
+	for kind, priority := range c.Pushover.Priorities {
+		if priority < pushoverLowestPriority || priority > pushoverEmergencyPriority {
+			return fmt.Errorf("invalid pushover priority %v for %v in config file", priority, kind)
+		}
+	}
+
+	for kind, channels := range c.Notifications.Routes {
+		for _, channel := range channels {
+			if channel != allChannels && !isNotificationChannel(channel) {
+				return fmt.Errorf("invalid notification channel %v for %v in config file", channel, kind)
+			}
+		}
+	}
+
+	for _, ranking := range c.SourceRankings {
+		if len(ranking.Sources) < 2 || ranking.Target == "" || ranking.TargetAmount.Sign() <= 0 {
+			return fmt.Errorf("invalid source ranking %v in config file, expected 2 sources or more, a target and a target amount", ranking)
+		}
+	}
+
+	for name, overrides := range all {
+		if overrides.Pinned && !strings.HasPrefix(name, "transfer ") {
+			return fmt.Errorf("invalid pinned for %v in config file, only transfers can be pinned", name)
+		}
+		if overrides.Strategy != "" {
+			if _, ok := strategies[overrides.Strategy]; !ok {
+				return fmt.Errorf("invalid strategy %v for %v in config file", overrides.Strategy, name)
+			}
+		}
+		if overrides.Interval != nil && *overrides.Interval == 0 {
+			return fmt.Errorf("invalid interval 0 for %v in config file", name)
+		}
+		if overrides.Amount != nil && overrides.Amount.Sign() <= 0 {
+			return fmt.Errorf("invalid amount %v for %v in config file", *overrides.Amount, name)
+		}
+		if overrides.MovingAverageHours != nil && *overrides.MovingAverageHours == 0 {
+			return fmt.Errorf("invalid moving average hours 0 for %v in config file", name)
+		}
+		if overrides.TrailingStop != nil && *overrides.TrailingStop <= 0 {
+			return fmt.Errorf("invalid trailing stop %v for %v in config file", *overrides.TrailingStop, name)
+		}
+		if overrides.MinGain != nil && overrides.MinGain.Sign() < 0 {
+			return fmt.Errorf("invalid min gain %v for %v in config file", *overrides.MinGain, name)
+		}
+		if overrides.Direction != "" && overrides.Direction != directionHigher && overrides.Direction != directionLower {
+			return fmt.Errorf("invalid direction %v for %v in config file", overrides.Direction, name)
+		}
+		if overrides.AmountMode != "" && overrides.AmountMode != amountModeSource && overrides.AmountMode != amountModeTarget {
+			return fmt.Errorf("invalid amount mode %v for %v in config file", overrides.AmountMode, name)
+		}
+		if overrides.TargetAmount != nil && overrides.TargetAmount.Sign() <= 0 {
+			return fmt.Errorf("invalid target amount %v for %v in config file", *overrides.TargetAmount, name)
+		}
+		if overrides.Windows != nil {
+			if _, err := parseCheckWindows(*overrides.Windows); err != nil {
+				return fmt.Errorf("invalid windows for %v in config file: %v", name, err)
+			}
+		}
+		if overrides.MaxFee != nil {
+			if _, err := parseFeeCap(*overrides.MaxFee); err != nil {
+				return fmt.Errorf("invalid max fee for %v in config file: %v", name, err)
+			}
+		}
+		if overrides.AdaptiveInterval != nil {
+			if _, err := parseIntervalRange(*overrides.AdaptiveInterval); err != nil {
+				return fmt.Errorf("invalid adaptive interval for %v in config file: %v", name, err)
+			}
+		}
+		if overrides.OffWindowInterval != nil && *overrides.OffWindowInterval == 0 {
+			return fmt.Errorf("invalid off window interval 0 for %v in config file", name)
+		}
+		if overrides.TargetAccount != nil && *overrides.TargetAccount == 0 {
+			return fmt.Errorf("invalid target account 0 for %v in config file", name)
+		}
+		if overrides.PayIn != nil && *overrides.PayIn != "" && !isPaymentMethod(*overrides.PayIn) {
+			return fmt.Errorf("invalid pay-in %v for %v in config file", *overrides.PayIn, name)
+		}
+		if overrides.PayOut != "" && !isPaymentMethod(overrides.PayOut) {
+			return fmt.Errorf("invalid payout %v for %v in config file", overrides.PayOut, name)
+		}
+		if _, ok := marginDecayCurves[overrides.MarginDecay]; overrides.MarginDecay != "" && !ok {
+			return fmt.Errorf("invalid margin decay %v for %v in config file", overrides.MarginDecay, name)
+		}
+		if overrides.MarginDecayHours != nil && *overrides.MarginDecayHours == 0 {
+			return fmt.Errorf("invalid margin decay hours 0 for %v in config file", name)
+		}
+		if overrides.MarginFloor != nil && overrides.MarginFloor.Sign() < 0 {
+			return fmt.Errorf("invalid margin floor %v for %v in config file", *overrides.MarginFloor, name)
+		}
+		if overrides.Reference != nil {
+			if _, err := parseReferenceTemplate(*overrides.Reference); err != nil {
+				return fmt.Errorf("invalid reference for %v in config file: %v", name, err)
+			}
+		}
+		if overrides.Originator != nil {
+			if err := validateOriginator(*overrides.Originator); err != nil {
+				return fmt.Errorf("invalid originator for %v in config file: %v", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// The global settings from the env variables
+func getDefaultSettings() (settings Settings, err error) {
+	settings.Margin, err = parseDecimal(marginVar)
+	if err != nil {
+		return Settings{}, fmt.Errorf("invalid value for MARGIN: %v", err)
+	}
+	settings.Interval, err = strconv.ParseUint(intervalVar, 10, 64)
+	if err != nil || settings.Interval == 0 {
+		return Settings{}, fmt.Errorf("invalid value for INTERVAL: %v", intervalVar)
+	}
+	settings.Profile, err = getConfiguredProfile()
+	if err != nil {
+		return Settings{}, err
+	}
+	settings.Strategy = strategyVar
+	if _, ok := strategies[settings.Strategy]; !ok {
+		return Settings{}, fmt.Errorf("invalid value for STRATEGY: %v", strategyVar)
+	}
+	settings.MovingAverageHours, err = strconv.ParseUint(movingAverageHoursVar, 10, 64)
+	if err != nil || settings.MovingAverageHours == 0 {
+		return Settings{}, fmt.Errorf("invalid value for MOVING_AVERAGE_HOURS: %v", movingAverageHoursVar)
+	}
+	settings.TrailingStop, err = strconv.ParseFloat(trailingStopVar, 64)
+	if err != nil || settings.TrailingStop <= 0 {
+		return Settings{}, fmt.Errorf("invalid value for TRAILING_STOP: %v", trailingStopVar)
+	}
+	settings.MinGain, err = parseDecimal(minGainVar)
+	if err != nil || settings.MinGain.Sign() < 0 {
+		return Settings{}, fmt.Errorf("invalid value for MIN_GAIN: %v", minGainVar)
+	}
+	switch directionVar {
+	case directionHigher:
+	case directionLower:
+		settings.LowerIsBetter = true
+	default:
+		return Settings{}, fmt.Errorf("invalid value for DIRECTION: %v, must be %v or %v", directionVar, directionHigher, directionLower)
+	}
+	switch amountModeVar {
+	case amountModeSource:
+	case amountModeTarget:
+		settings.KeepTargetAmount = true
+	default:
+		return Settings{}, fmt.Errorf("invalid value for AMOUNT_MODE: %v, must be %v or %v", amountModeVar, amountModeSource, amountModeTarget)
+	}
+	settings.Windows, err = parseCheckWindows(checkWindowsVar)
+	if err != nil {
+		return Settings{}, fmt.Errorf("invalid value for CHECK_WINDOWS: %v", err)
+	}
+	settings.OffWindowInterval, err = strconv.ParseUint(offWindowIntervalVar, 10, 64)
+	if err != nil || settings.OffWindowInterval == 0 {
+		return Settings{}, fmt.Errorf("invalid value for OFF_WINDOW_INTERVAL: %v", offWindowIntervalVar)
+	}
+	if payInVar != "" && !isPaymentMethod(payInVar) {
+		return Settings{}, fmt.Errorf("invalid value for PAY_IN: %v", payInVar)
+	}
+	if !isPaymentMethod(payOutVar) {
+		return Settings{}, fmt.Errorf("invalid value for PAY_OUT: %v", payOutVar)
+	}
+	settings.PayIn, settings.PayOut = payInVar, payOutVar
+	settings.MarginDecay = marginDecayVar
+	if _, ok := marginDecayCurves[settings.MarginDecay]; !ok {
+		return Settings{}, fmt.Errorf("invalid value for MARGIN_DECAY: %v, must be %v, %v, %v or %v", marginDecayVar,
+			marginDecayNone, marginDecayLinear, marginDecayQuadratic, marginDecaySqrt)
+	}
+	settings.MarginDecayHours, err = strconv.ParseUint(marginDecayHoursVar, 10, 64)
+	if err != nil || settings.MarginDecayHours == 0 {
+		return Settings{}, fmt.Errorf("invalid value for MARGIN_DECAY_HOURS: %v", marginDecayHoursVar)
+	}
+	settings.MarginFloor, err = parseDecimal(marginFloorVar)
+	if err != nil || settings.MarginFloor.Sign() < 0 {
+		return Settings{}, fmt.Errorf("invalid value for MARGIN_FLOOR: %v", marginFloorVar)
+	}
+	if _, err = parseReferenceTemplate(referenceTemplateVar); err != nil {
+		return Settings{}, fmt.Errorf("invalid value for REFERENCE_TEMPLATE: %v", err)
+	}
+	settings.Reference = referenceTemplateVar
+	settings.MaxFee, err = parseFeeCap(maxFeeVar)
+	if err != nil {
+		return Settings{}, fmt.Errorf("invalid value for MAX_FEE: %v", err)
+	}
+	settings.AdaptiveInterval, err = parseIntervalRange(adaptiveIntervalVar)
+	if err != nil {
+		return Settings{}, fmt.Errorf("invalid value for ADAPTIVE_INTERVAL: %v", err)
+	}
+	return settings, nil
+}
+
+// Resolve the settings of a transfer, transfer overrides taking precedence over purpose overrides over pair overrides
+// over the global settings
+func getSettings(transfer Transfer) (Settings, error) {
+	settings, err := getDefaultSettings()
+	if err != nil {
+		return Settings{}, err
+	}
+
+	c := getConfig()
+	settings.apply(c.Pairs[pairKey(transfer.SourceCurrency, transfer.TargetCurrency)])
+	if transfer.Details.TransferPurpose != "" {
+		settings.apply(c.Purposes[transfer.Details.TransferPurpose])
+	}
+	settings.apply(c.Transfers[strconv.FormatUint(transfer.Id, 10)])
+	return settings, nil
+}
+
+func (s *Settings) apply(overrides Overrides) {
+	if overrides.Margin != nil {
+		s.Margin = *overrides.Margin
+	}
+	if overrides.Interval != nil {
+		s.Interval = *overrides.Interval
+	}
+	if overrides.Amount != nil {
+		s.Amount = *overrides.Amount
+	}
+	if overrides.Strategy != "" {
+		s.Strategy = overrides.Strategy
+	}
+	if overrides.Profile != nil {
+		s.Profile = *overrides.Profile
+	}
+	if overrides.MovingAverageHours != nil {
+		s.MovingAverageHours = *overrides.MovingAverageHours
+	}
+	if overrides.TrailingStop != nil {
+		s.TrailingStop = *overrides.TrailingStop
+	}
+	if overrides.MinGain != nil {
+		s.MinGain = *overrides.MinGain
+	}
+	if overrides.Direction != "" {
+		s.LowerIsBetter = overrides.Direction == directionLower
+	}
+	if overrides.AmountMode != "" {
+		s.KeepTargetAmount = overrides.AmountMode == amountModeTarget
+	}
+	if overrides.TargetAmount != nil {
+		s.TargetAmount = *overrides.TargetAmount
+	}
+	if overrides.Windows != nil {
+		s.Windows, _ = parseCheckWindows(*overrides.Windows)
+	}
+	if overrides.OffWindowInterval != nil {
+		s.OffWindowInterval = *overrides.OffWindowInterval
+	}
+	if overrides.TargetAccount != nil {
+		s.TargetAccount = *overrides.TargetAccount
+	}
+	if overrides.PayIn != nil {
+		s.PayIn = *overrides.PayIn
+	}
+	if overrides.PayOut != "" {
+		s.PayOut = overrides.PayOut
+	}
+	if overrides.MarginDecay != "" {
+		s.MarginDecay = overrides.MarginDecay
+	}
+	if overrides.MarginDecayHours != nil {
+		s.MarginDecayHours = *overrides.MarginDecayHours
+	}
+	if overrides.MarginFloor != nil {
+		s.MarginFloor = *overrides.MarginFloor
+	}
+	if overrides.Reference != nil {
+		s.Reference = *overrides.Reference
+	}
+	if overrides.Originator != nil {
+		s.Originator = overrides.Originator
+	}
+	if overrides.MaxFee != nil {
+		s.MaxFee, _ = parseFeeCap(*overrides.MaxFee)
+	}
+	if overrides.AdaptiveInterval != nil {
+		s.AdaptiveInterval, _ = parseIntervalRange(*overrides.AdaptiveInterval)
+	}
+}
+
+// The scheduler runs at the shortest of all configured intervals, adaptive ones included
+func getSchedulerInterval() (uint64, error) {
+	settings, err := getDefaultSettings()
+	if err != nil {
+		return 0, err
+	}
+
+	interval := settings.Interval
+	if settings.AdaptiveInterval.Min > 0 && settings.AdaptiveInterval.Min < interval {
+		interval = settings.AdaptiveInterval.Min
+	}
+	c := getConfig()
+	for _, overrides := range []map[string]Overrides{c.Pairs, c.Purposes, c.Transfers} {
+		for _, o := range overrides {
+			if o.Interval != nil && *o.Interval < interval {
+				interval = *o.Interval
+			}
+			if o.AdaptiveInterval != nil {
+				if bounds, _ := parseIntervalRange(*o.AdaptiveInterval); bounds.Min > 0 && bounds.Min < interval {
+					interval = bounds.Min
+				}
+			}
+		}
+	}
+	return interval, nil
+}
+
+// Whether the pair's interval has elapsed since the account's last check of it, counting the check when it has
+func isCheckDue(pair string, interval uint64, now time.Time) bool {
+	key := accountPairCheck(pair)
+	pairChecks.Lock()
+	defer pairChecks.Unlock()
+
+	// a little slack so scheduler jitter doesn't skip a whole interval
+	lastCheck, ok := pairChecks.checkedAt[key]
+	retryAt, retry := pairChecks.retryAt[key]
+	retryDue := retry && !now.Before(retryAt.Add(-5*time.Second))
+	if ok && now.Sub(lastCheck) < time.Duration(interval)*time.Minute-5*time.Second && !retryDue {
+		return false
+	}
+	pairChecks.checkedAt[key] = now
+	delete(pairChecks.retryAt, key)
+	return true
+}
+
+func pairKey(source string, target string) string {
+	return source + "-" + target
+}
+
+// Split a pair like GBP-INR into its upper cased source and target currencies
+func splitPairKey(pair string) (source string, target string, err error) {
+	currencies := strings.Split(strings.ToUpper(pair), "-")
+	if len(currencies) != 2 || currencies[0] == "" || currencies[1] == "" {
+		return "", "", fmt.Errorf("invalid currency pair %v, expected <source>-<target> like GBP-INR", pair)
+	}
+	return currencies[0], currencies[1], nil
+}
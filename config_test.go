@@ -0,0 +1,268 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"transferwisely/mocks"
+)
+
+func TestGetSettings(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "transferwisely")
+	defer os.RemoveAll(dir)
+	defer func(file, margin, interval string, c Config) {
+		configFileVar, marginVar, intervalVar, config.current = file, margin, interval, c
+	}(configFileVar, marginVar, intervalVar, getConfig())
+
+	configFileVar = filepath.Join(dir, "config.json")
+	marginVar, intervalVar = "0.01", "5"
+	_ = ioutil.WriteFile(configFileVar, []byte(`{
+		"pairs": {"GBP-INR": {"margin": 0.2, "interval": 2, "strategy": "moving-average", "movingAverageHours": 6}},
+		"transfers": {"42": {"amount": 1000, "margin": 0.5}}
+	}`), 0600)
+	assert.NoError(t, loadConfig())
+
+	t.Run("global settings", func(t *testing.T) {
+		settings, err := getSettings(Transfer{Id: 1, SourceCurrency: "EUR", TargetCurrency: "USD"})
+		assert.NoError(t, err)
+		assert.Equal(t, Settings{Margin: decimalOf(0.01), Interval: 5, Strategy: strategyMargin, MovingAverageHours: 24, TrailingStop: 0.5,
+			OffWindowInterval: 60, PayOut: "BANK_TRANSFER", MarginDecay: marginDecayNone, MarginDecayHours: 24}, settings)
+	})
+
+	t.Run("pair overrides", func(t *testing.T) {
+		settings, err := getSettings(Transfer{Id: 1, SourceCurrency: "GBP", TargetCurrency: "INR"})
+		assert.NoError(t, err)
+		assert.Equal(t, decimalOf(0.2), settings.Margin)
+		assert.Equal(t, uint64(2), settings.Interval)
+		assert.Equal(t, strategyMovingAverage, settings.Strategy)
+		assert.Equal(t, uint64(6), settings.MovingAverageHours)
+	})
+
+	t.Run("transfer overrides take precedence", func(t *testing.T) {
+		settings, err := getSettings(Transfer{Id: 42, SourceCurrency: "GBP", TargetCurrency: "INR"})
+		assert.NoError(t, err)
+		assert.Equal(t, decimalOf(0.5), settings.Margin)
+		assert.Equal(t, uint64(2), settings.Interval)
+		assert.Equal(t, decimalOf(1000.0), settings.Amount)
+	})
+
+	t.Run("scheduler runs at the shortest interval", func(t *testing.T) {
+		interval, err := getSchedulerInterval()
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(2), interval)
+	})
+
+	t.Run("invalid config file", func(t *testing.T) {
+		_ = ioutil.WriteFile(configFileVar, []byte(`{"pairs": {"GBP-INR": {"strategy": "yolo"}}}`), 0600)
+		assert.Error(t, loadConfig())
+	})
+}
+
+func TestIsCheckDue(t *testing.T) {
+	now := time.Now().UTC()
+	assert.True(t, isCheckDue("JPY-INR", 5, now))
+	assert.False(t, isCheckDue("JPY-INR", 5, now.Add(2*time.Minute)))
+	assert.True(t, isCheckDue("JPY-INR", 5, now.Add(5*time.Minute)))
+	assert.True(t, isCheckDue("JPY-EUR", 5, now))
+}
+
+func TestMarginStrategy(t *testing.T) {
+	transfer := Transfer{Rate: 0.691}
+	settings := Settings{Margin: decimalOf(0.01)}
+
+	rebook, _ := marginStrategy{}.ShouldRebook(transfer, 0.695, settings)
+	assert.False(t, rebook)
+	rebook, _ = marginStrategy{}.ShouldRebook(transfer, 0.711, settings)
+	assert.True(t, rebook)
+}
+
+func TestComparisonDirection(t *testing.T) {
+	transfer := Transfer{Rate: 1.45, SourceAmount: decimalOf(1000)}
+	settings := Settings{Margin: decimalOf(0.01), LowerIsBetter: true}
+
+	rebook, _ := marginStrategy{}.ShouldRebook(transfer, 1.46, settings)
+	assert.False(t, rebook, "a higher rate is worse")
+	rebook, _ = marginStrategy{}.ShouldRebook(transfer, 1.445, settings)
+	assert.False(t, rebook, "below the margin")
+	rebook, _ = marginStrategy{}.ShouldRebook(transfer, 1.43, settings)
+	assert.True(t, rebook)
+	assert.Equal(t, decimalOf(20), targetGain(transfer, 1.43, settings))
+
+	defer func(direction string, c Config) {
+		directionVar, config.current = direction, c
+	}(directionVar, getConfig())
+	config.current = Config{Pairs: map[string]Overrides{"USD-GBP": {Direction: directionLower}}}
+
+	resolved, err := getSettings(Transfer{SourceCurrency: "USD", TargetCurrency: "GBP"})
+	assert.NoError(t, err)
+	assert.True(t, resolved.LowerIsBetter)
+	resolved, err = getSettings(Transfer{SourceCurrency: "GBP", TargetCurrency: "USD"})
+	assert.NoError(t, err)
+	assert.False(t, resolved.LowerIsBetter)
+
+	lower := []Transfer{
+		{Id: 1, Rate: 0.79, SourceCurrency: "USD", TargetCurrency: "GBP"},
+		{Id: 2, Rate: 0.78, SourceCurrency: "USD", TargetCurrency: "GBP"},
+		{Id: 3, Rate: 0.8, SourceCurrency: "USD", TargetCurrency: "GBP"},
+	}
+	assert.Equal(t, uint64(2), findBestTransfer(lower).Id, "the lowest rate is the best of a lower is better pair")
+	higher := []Transfer{
+		{Id: 1, Rate: 1.26, SourceCurrency: "GBP", TargetCurrency: "USD"},
+		{Id: 2, Rate: 1.27, SourceCurrency: "GBP", TargetCurrency: "USD"},
+	}
+	assert.Equal(t, uint64(2), findBestTransfer(higher).Id)
+
+	directionVar = "sideways"
+	_, err = getDefaultSettings()
+	assert.Error(t, err)
+	assert.Error(t, validateOverrides(Config{Pairs: map[string]Overrides{"USD-GBP": {Direction: "sideways"}}}))
+}
+
+func TestAmountMode(t *testing.T) {
+	defer func(mode string, c Config) {
+		amountModeVar, config.current = mode, c
+	}(amountModeVar, getConfig())
+	config.current = Config{Transfers: map[string]Overrides{"42": {AmountMode: amountModeTarget, TargetAmount: &[]Decimal{decimalOf(500)}[0]}}}
+
+	settings, err := getSettings(Transfer{Id: 42})
+	assert.NoError(t, err)
+	assert.True(t, settings.KeepTargetAmount)
+	assert.Equal(t, decimalOf(500.0), settings.TargetAmount)
+	settings, err = getSettings(Transfer{Id: 1})
+	assert.NoError(t, err)
+	assert.False(t, settings.KeepTargetAmount)
+
+	amountModeVar = "both"
+	_, err = getDefaultSettings()
+	assert.Error(t, err)
+	assert.Error(t, validateOverrides(Config{Transfers: map[string]Overrides{"42": {AmountMode: "both"}}}))
+	assert.Error(t, validateOverrides(Config{Transfers: map[string]Overrides{"42": {TargetAmount: &[]Decimal{decimalOf(-1)}[0]}}}))
+}
+
+func TestCheckWindowsOverrides(t *testing.T) {
+	defer func(windows, offInterval string, c Config) {
+		checkWindowsVar, offWindowIntervalVar, config.current = windows, offInterval, c
+	}(checkWindowsVar, offWindowIntervalVar, getConfig())
+	checkWindowsVar = "Mon-Fri"
+	config.current = Config{Pairs: map[string]Overrides{"BTC-EUR": {Windows: &[]string{""}[0], OffWindowInterval: &[]uint64{120}[0]}}}
+
+	settings, err := getSettings(Transfer{SourceCurrency: "GBP", TargetCurrency: "INR"})
+	assert.NoError(t, err)
+	assert.Len(t, settings.Windows, 1)
+	assert.Equal(t, uint64(60), settings.OffWindowInterval)
+	settings, err = getSettings(Transfer{SourceCurrency: "BTC", TargetCurrency: "EUR"})
+	assert.NoError(t, err)
+	assert.Empty(t, settings.Windows, "checked around the clock")
+	assert.Equal(t, uint64(120), settings.OffWindowInterval)
+
+	offWindowIntervalVar = "0"
+	_, err = getDefaultSettings()
+	assert.Error(t, err)
+	assert.Error(t, validateOverrides(Config{Pairs: map[string]Overrides{"GBP-INR": {Windows: &[]string{"weekdays"}[0]}}}))
+	assert.Error(t, validateOverrides(Config{Pairs: map[string]Overrides{"GBP-INR": {OffWindowInterval: &[]uint64{0}[0]}}}))
+}
+
+func TestMovingAverageStrategy(t *testing.T) {
+	transfer := Transfer{SourceCurrency: "JPY", TargetCurrency: "INR", Rate: 0.691}
+	settings := Settings{Margin: decimalOf(0.001), MovingAverageHours: 24}
+	mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+		assert.Contains(t, req.URL.String(), liveRateAPIPath)
+		assert.Equal(t, rateHistoryGroup, req.URL.Query().Get("group"))
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(strings.NewReader(`[{"rate": 0.690}, {"rate": 0.700}, {"rate": 0.710}]`)),
+		}, nil
+	}
+
+	rebook, err := movingAverageStrategy{}.ShouldRebook(transfer, 0.695, settings)
+	assert.NoError(t, err)
+	assert.False(t, rebook, "above the booked rate but below the moving average")
+	rebook, err = movingAverageStrategy{}.ShouldRebook(transfer, 0.705, settings)
+	assert.NoError(t, err)
+	assert.True(t, rebook)
+	rebook, err = movingAverageStrategy{}.ShouldRebook(transfer, 0.6915, settings)
+	assert.NoError(t, err)
+	assert.False(t, rebook, "below the margin")
+
+	mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(`[]`))}, nil
+	}
+	_, err = movingAverageStrategy{}.ShouldRebook(transfer, 0.705, settings)
+	assert.Error(t, err)
+}
+
+func TestTrailingStopStrategy(t *testing.T) {
+	defer func(file string) { stateFileVar = file }(stateFileVar)
+	dir, _ := ioutil.TempDir("", "transferwisely")
+	defer os.RemoveAll(dir)
+	stateFileVar = filepath.Join(dir, "state.json")
+
+	transfer := Transfer{Id: 1, Rate: 100}
+	settings := Settings{Margin: decimalOf(0.5), TrailingStop: 1}
+	for _, step := range []struct {
+		live     float64
+		expected bool
+		reason   string
+	}{
+		{99, false, "below the booked rate"},
+		{101, false, "first margin breach"},
+		{104, false, "climbing"},
+		{103.5, false, "0.48% below the peak"},
+		{102.9, true, "1.06% below the peak"},
+	} {
+		rebook, err := trailingStopStrategy{}.ShouldRebook(transfer, step.live, settings)
+		assert.NoError(t, err)
+		assert.Equal(t, step.expected, rebook, step.reason)
+	}
+
+	rebook, _ := trailingStopStrategy{}.ShouldRebook(transfer, 100.3, Settings{Margin: decimalOf(0.5), TrailingStop: 1})
+	assert.False(t, rebook, "fell back below the margin")
+
+	t.Run("peak reset below the booked rate", func(t *testing.T) {
+		rebook, err := trailingStopStrategy{}.ShouldRebook(transfer, 99.5, settings)
+		assert.NoError(t, err)
+		assert.False(t, rebook)
+		state, _ := loadState()
+		assert.NotContains(t, state.TrailingPeaks, uint64(1))
+
+		rebook, _ = trailingStopStrategy{}.ShouldRebook(transfer, 101, settings)
+		assert.False(t, rebook, "a new peak, not a fall from the old one")
+		state, _ = loadState()
+		assert.Equal(t, 101.0, state.TrailingPeaks[1])
+	})
+
+	t.Run("lower is better", func(t *testing.T) {
+		transfer := Transfer{Id: 2, Rate: 1.5}
+		settings := Settings{TrailingStop: 1, LowerIsBetter: true}
+		rebook, _ := trailingStopStrategy{}.ShouldRebook(transfer, 1.4, settings)
+		assert.False(t, rebook)
+		rebook, _ = trailingStopStrategy{}.ShouldRebook(transfer, 1.42, settings)
+		assert.True(t, rebook)
+	})
+
+	assert.NoError(t, forgetTrailingPeak(1))
+	state, _ := loadState()
+	assert.Equal(t, map[uint64]float64{2: 1.4}, state.TrailingPeaks)
+}
+
+func TestMinGain(t *testing.T) {
+	transfer := Transfer{SourceCurrency: "GBP", TargetCurrency: "INR", Rate: 100, SourceAmount: decimalOf(1000)}
+	assert.Equal(t, decimalOf(200.0), targetGain(transfer, 100.2, Settings{}))
+	assert.Equal(t, decimalOf(400.0), targetGain(transfer, 100.2, Settings{Amount: decimalOf(2000)}))
+
+	mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(`[{"rate": 100.2}]`))}, nil
+	}
+	result, _, err := compareRates(transfer, Settings{Strategy: strategyMargin, MinGain: decimalOf(500)})
+	assert.NoError(t, err)
+	assert.False(t, result, "better rate, but the recipient only gets 200 INR more")
+
+	result, _, err = compareRates(transfer, Settings{Strategy: strategyMargin, MinGain: decimalOf(150)})
+	assert.NoError(t, err)
+	assert.True(t, result)
+}
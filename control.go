@@ -0,0 +1,260 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maximum number of transfers GET /transfers lists
+const controlTransfersLimit = 100
+
+// ControlStatus is reported by GET /status
+type ControlStatus struct {
+	Paused    bool         `json:"paused"`
+	PausedAt  *time.Time   `json:"pausedAt,omitempty"`
+	PausedBy  string       `json:"pausedBy,omitempty"`
+	NextCheck *time.Time   `json:"nextCheck,omitempty"`
+	LastCheck *CheckResult `json:"lastCheck,omitempty"`
+	Health    HealthStatus `json:"health"`
+}
+
+// where the batch got paused from
+const (
+	pausedByControlAPI = "control API"
+	pausedByRPCAPI     = "RPC API"
+	pausedByCLI        = "CLI"
+)
+
+// Pause stops the scheduled checks, and so any re-booking, until resumed. It is persisted in the state, so that a
+// restart doesn't silently resume the re-bookings, and a pause from the CLI reaches the running batch
+type Pause struct {
+	At time.Time `json:"at"`
+	By string    `json:"by"`
+}
+
+var control = struct {
+	sync.Mutex
+	lastCheck *CheckResult
+}{}
+
+// scheduled and forced checks never run concurrently
+var checkMutex sync.Mutex
+
+// The persisted pause, nil when not paused
+func getPause() (*Pause, error) {
+	state, err := loadState()
+	if err != nil {
+		return nil, fmt.Errorf("getPause: %v", err)
+	}
+	return state.Pause, nil
+}
+
+// Whether the batch is paused, a state that can't be read counting as paused rather than resuming re-bookings
+func isPaused() bool {
+	pause, err := getPause()
+	if err != nil {
+		log.Printf("isPaused: %v, considering the batch paused", err)
+		return true
+	}
+	return pause != nil
+}
+
+// Pause, from by, or resume the batch, keeping the time of the first pause when paused again
+func setPaused(paused bool, by string) error {
+	return updateState(func(state *State) error {
+		if !paused {
+			state.Pause = nil
+		} else if state.Pause == nil {
+			state.Pause = &Pause{At: time.Now().UTC(), By: by}
+		}
+		return nil
+	})
+}
+
+// Log the pause at startup, as a paused batch looks healthy but never re-books
+func logPause() {
+	pause, err := getPause()
+	if err != nil {
+		log.Printf("logPause: %v", err)
+		return
+	}
+	if pause != nil {
+		log.Printf("|| PAUSED SINCE %v THROUGH THE %v, NO RE-BOOKING UNTIL RESUMED ||", formatTime(pause.At), pause.By)
+	}
+}
+
+// Run a check cycle right away, waiting for a running one to finish first
+func checkNow() CheckResult {
+	checkMutex.Lock()
+	defer checkMutex.Unlock()
+
+	check := runCheck()
+	control.Lock()
+	control.lastCheck = &check
+	control.Unlock()
+	return check
+}
+
+func getControlStatus() ControlStatus {
+	control.Lock()
+	status := ControlStatus{LastCheck: control.lastCheck}
+	control.Unlock()
+
+	pause, err := getPause()
+	if err != nil {
+		log.Printf("getControlStatus: %v", err)
+	}
+	status.Paused = pause != nil || err != nil
+	if pause != nil {
+		status.PausedAt, status.PausedBy = &pause.At, pause.By
+	}
+
+	if checkJob != nil && !status.Paused {
+		nextCheck := checkJob.NextRun().UTC()
+		status.NextCheck = &nextCheck
+	}
+	status.Health = getHealthStatus()
+	return status
+}
+
+// Only serve requests carrying CONTROL_API_TOKEN as bearer token, the API being disabled without one
+func requireControlToken(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if controlAPITokenVar == "" {
+			http.NotFound(w, r)
+			return
+		}
+		if !hasControlToken(r) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="transferwisely"`)
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid or missing bearer token"})
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// Whether the request carries CONTROL_API_TOKEN as bearer token
+func hasControlToken(r *http.Request) bool {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(controlAPITokenVar)) == 1
+}
+
+func requireMethod(method string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// Register the control API: GET /status, GET /transfers, POST /check, POST /pause, POST /resume and POST /approve/{proposalId}
+func registerControlAPI(mux *http.ServeMux) {
+	mux.HandleFunc("/status", requireControlToken(requireMethod(http.MethodGet, defaultAccountHandler(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, getControlStatus())
+	}))))
+	mux.HandleFunc("/transfers", requireControlToken(requireMethod(http.MethodGet, defaultAccountHandler(func(w http.ResponseWriter, r *http.Request) {
+		status := r.URL.Query().Get("status")
+		if status == "" {
+			statuses, err := getTrackedStatuses()
+			if err != nil {
+				writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+				return
+			}
+			status = strings.Join(statuses, ",")
+		}
+		transfers, err := listTransfers(status, controlTransfersLimit)
+		if err != nil {
+			writeJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+			return
+		}
+		if transfers == nil {
+			transfers = []Transfer{}
+		}
+		writeJSON(w, http.StatusOK, transfers)
+	}))))
+	mux.HandleFunc("/check", requireControlToken(requireMethod(http.MethodPost, defaultAccountHandler(func(w http.ResponseWriter, r *http.Request) {
+		if !isLeader() {
+			writeJSON(w, http.StatusConflict, map[string]string{"error": "not the leader, only the leader checks"})
+			return
+		}
+		log.Println("|| CHECK REQUESTED THROUGH THE CONTROL API ||")
+		writeJSON(w, http.StatusOK, checkNow())
+	}))))
+	mux.HandleFunc("/pause", requireControlToken(requireMethod(http.MethodPost, defaultAccountHandler(func(w http.ResponseWriter, r *http.Request) {
+		log.Println("|| PAUSED THROUGH THE CONTROL API ||")
+		if err := setPaused(true, pausedByControlAPI); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, getControlStatus())
+	}))))
+	mux.HandleFunc("/resume", requireControlToken(requireMethod(http.MethodPost, defaultAccountHandler(func(w http.ResponseWriter, r *http.Request) {
+		log.Println("|| RESUMED THROUGH THE CONTROL API ||")
+		if err := setPaused(false, pausedByControlAPI); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, getControlStatus())
+	}))))
+	mux.HandleFunc("/approve/", requireControlToken(requireMethod(http.MethodPost, func(w http.ResponseWriter, r *http.Request) {
+		id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/approve/"), "/")
+		newTransfer, err := approveProposal(id, time.Now().UTC())
+		writeApproval(w, newTransfer, err)
+	})))
+}
+
+func runPauseCommand(args []string) error {
+	switch {
+	case len(args) == 1 && args[0] == "status":
+		pause, err := getPause()
+		if err != nil {
+			return err
+		}
+		if pause == nil {
+			fmt.Println("Not paused, re-booking as usual")
+			return nil
+		}
+		fmt.Printf("PAUSED since %v through the %v, no re-booking until resumed with: transferwisely resume\n",
+			formatTime(pause.At), pause.By)
+		return nil
+	case len(args) > 0:
+		return fmt.Errorf("usage: pause [status]")
+	}
+	if err := setPaused(true, pausedByCLI); err != nil {
+		return err
+	}
+	log.Println("|| PAUSED THROUGH THE CLI ||")
+	fmt.Println("Paused, no re-booking until resumed with: transferwisely resume")
+	return nil
+}
+
+func runResumeCommand(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("usage: resume")
+	}
+	if err := setPaused(false, pausedByCLI); err != nil {
+		return err
+	}
+	log.Println("|| RESUMED THROUGH THE CLI ||")
+	fmt.Println("Resumed, re-booking from the next check")
+	return nil
+}
+
+func init() {
+	registerCommand("pause", Command{
+		Usage: "pause [status]                               pause the checks and re-bookings, persisted across restarts",
+		Run:   runPauseCommand,
+	})
+	registerCommand("resume", Command{
+		Usage: "resume                                       resume the checks and re-bookings",
+		Run:   runResumeCommand,
+	})
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"transferwisely/mocks"
+)
+
+func TestControlAPI(t *testing.T) {
+	defer func(v string, file string) { controlAPITokenVar, stateFileVar = v, file }(controlAPITokenVar, stateFileVar)
+	dir, _ := ioutil.TempDir("", "transferwisely")
+	defer os.RemoveAll(dir)
+	stateFileVar = filepath.Join(dir, "state.json")
+	mux := http.NewServeMux()
+	registerControlAPI(mux)
+
+	do := func(method string, path string, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	controlAPITokenVar = ""
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/status", "").Code, "disabled without a token")
+
+	controlAPITokenVar = "secret"
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/status", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/status", "wrong").Code)
+	assert.Equal(t, http.StatusMethodNotAllowed, do(http.MethodGet, "/pause", "secret").Code)
+
+	t.Run("pause and resume", func(t *testing.T) {
+		w := do(http.MethodPost, "/pause", "secret")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.True(t, isPaused())
+
+		var status ControlStatus
+		assert.NoError(t, json.Unmarshal(do(http.MethodGet, "/status", "secret").Body.Bytes(), &status))
+		assert.True(t, status.Paused)
+		assert.NotNil(t, status.PausedAt)
+		assert.Equal(t, pausedByControlAPI, status.PausedBy)
+
+		checks := 0
+		mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+			checks++
+			return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(`[]`))}, nil
+		}
+		checkAndProcess()
+		assert.Equal(t, 0, checks, "no check while paused")
+
+		do(http.MethodPost, "/resume", "secret")
+		assert.False(t, isPaused())
+	})
+
+	t.Run("check", func(t *testing.T) {
+		defer func(host, token string) { hostVar, apiTokenVar = host, token }(hostVar, apiTokenVar)
+		hostVar, apiTokenVar = "", ""
+		w := do(http.MethodPost, "/check", "secret")
+		assert.Equal(t, http.StatusOK, w.Code)
+		var check CheckResult
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &check))
+		assert.Equal(t, checkActionError, check.Action)
+
+		var status ControlStatus
+		assert.NoError(t, json.Unmarshal(do(http.MethodGet, "/status", "secret").Body.Bytes(), &status))
+		assert.Equal(t, checkActionError, status.LastCheck.Action)
+	})
+
+	t.Run("transfers", func(t *testing.T) {
+		var listURL string
+		mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+			listURL = req.URL.String()
+			return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(`[{"id": 1}]`))}, nil
+		}
+		w := do(http.MethodGet, "/transfers?status=processing", "secret")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, listURL, "status=processing")
+		assert.Contains(t, w.Body.String(), `"id":1`)
+	})
+
+	t.Run("approve unknown proposal", func(t *testing.T) {
+		dir, _ := ioutil.TempDir("", "transferwisely")
+		defer os.RemoveAll(dir)
+		defer func(file string) { stateFileVar = file }(stateFileVar)
+		stateFileVar = filepath.Join(dir, "state.json")
+
+		assert.Equal(t, http.StatusNotFound, do(http.MethodPost, "/approve/unknown", "secret").Code)
+	})
+}
+
+func TestPausePersisted(t *testing.T) {
+	defer func(file string) { stateFileVar = file }(stateFileVar)
+	dir, _ := ioutil.TempDir("", "transferwisely")
+	defer os.RemoveAll(dir)
+	stateFileVar = filepath.Join(dir, "state.json")
+
+	assert.False(t, isPaused())
+	assert.NoError(t, runPauseCommand(nil))
+	pause, err := getPause()
+	assert.NoError(t, err)
+	assert.Equal(t, pausedByCLI, pause.By)
+
+	// pausing again keeps the first pause, the state surviving a restart keeps the batch paused
+	assert.NoError(t, setPaused(true, pausedByControlAPI))
+	state, err := loadState()
+	assert.NoError(t, err)
+	assert.Equal(t, pause, state.Pause)
+	assert.True(t, isPaused())
+
+	assert.Error(t, runPauseCommand([]string{"now"}))
+	assert.NoError(t, runResumeCommand(nil))
+	assert.False(t, isPaused())
+
+	// an unreadable state counts as paused
+	assert.NoError(t, ioutil.WriteFile(stateFileVar, []byte("{"), 0600))
+	assert.True(t, isPaused())
+	assert.True(t, getControlStatus().Paused)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/google/uuid"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const transferRequirementsAPIPath = "v1/transfer-requirements"
+
+// TransferRequirement is a set of fields transferwise needs to create a transfer to the recipient
+type TransferRequirement struct {
+	Type   string                     `json:"type"`
+	Fields []TransferRequirementField `json:"fields"`
+}
+
+type TransferRequirementField struct {
+	Name  string                     `json:"name"`
+	Group []TransferRequirementGroup `json:"group"`
+}
+
+type TransferRequirementGroup struct {
+	Key      string `json:"key"`
+	Name     string `json:"name"`
+	Required bool   `json:"required"`
+}
+
+// Fetch the fields transferwise needs to create the transfer to the recipient under the quote
+func getTransferRequirements(createRequest CreateTransferRequest) ([]TransferRequirement, error) {
+	request, _ := json.Marshal(createRequest)
+	url := &url.URL{Host: hostVar, Scheme: "https", Path: transferRequirementsAPIPath}
+
+	var requirements []TransferRequirement
+	_, err := callExternalAPI(http.MethodPost, url.String(), request, &requirements)
+	if err != nil {
+		return nil, fmt.Errorf("error POST transfer requirements API: %w", err)
+	}
+
+	return requirements, nil
+}
+
+// The required transfer details left empty, the ones this tool can't fill in being logged
+func missingRequirements(requirements []TransferRequirement, details TransferDetails) (missing []string) {
+	values := map[string]string{
+		"reference":       details.Reference,
+		"transferPurpose": details.TransferPurpose,
+		"sourceOfFunds":   details.SourceOfFunds,
+	}
+	for _, requirement := range requirements {
+		for _, field := range requirement.Fields {
+			for _, group := range field.Group {
+				if !group.Required {
+					continue
+				}
+				value, ok := values[group.Key]
+				if !ok {
+					log.Printf("missingRequirements: %v (%v) is required but can't be set, create the transfer on wise.com", group.Key, field.Name)
+					missing = append(missing, group.Key)
+					continue
+				}
+				if value == "" {
+					missing = append(missing, group.Key)
+				}
+			}
+		}
+	}
+	return missing
+}
+
+// Quote the amount and create a transfer to the recipient, once transferwise's requirements for it are met
+func createInitialTransfer(source string, target string, amount float64, profile uint64, recipient uint64, details TransferDetails) (Transfer, error) {
+	sourceAmount := roundAmount(decimalOf(amount), source)
+	quote, err := generateQuoteDetail(source, target, sourceAmount, profile)
+	if err != nil {
+		return Transfer{}, fmt.Errorf("createInitialTransfer: %v", err)
+	}
+	if quote.Profile != profile {
+		return Transfer{}, fmt.Errorf("quote %v created under profile %v, expected profile %v", quote.Id, quote.Profile, profile)
+	}
+	quote.SourceAmount = sourceAmount
+	// the transfer has no ID yet, only the pair and purpose overrides can set its originator
+	originator, err := transferOriginator(Transfer{SourceCurrency: source, TargetCurrency: target, Details: details})
+	if err != nil {
+		return Transfer{}, fmt.Errorf("createInitialTransfer: %v", err)
+	}
+
+	createRequest := CreateTransferRequest{
+		TargetAccount:         recipient,
+		QuoteUuid:             quote.Id,
+		CustomerTransactionId: uuid.New().String(),
+		Details:               details,
+		Originator:            originator,
+	}
+	requirements, err := getTransferRequirements(createRequest)
+	if err != nil {
+		return Transfer{}, fmt.Errorf("createInitialTransfer: %v", err)
+	}
+	if missing := missingRequirements(requirements, details); len(missing) > 0 {
+		return Transfer{}, fmt.Errorf("transferwise requires %v for this transfer", strings.Join(missing, ", "))
+	}
+
+	return postTransfer(createRequest, quote)
+}
+
+func runCreateCommand(args []string) error {
+	flags := flag.NewFlagSet("transfers create", flag.ContinueOnError)
+	pair := flags.String("pair", "", "currency pair like GBP-INR")
+	amount := flags.Float64("amount", 0, "source amount")
+	recipient := flags.Uint64("recipient", 0, "recipient account ID")
+	reference := flags.String("reference", "", "reference the recipient sees")
+	purpose := flags.String("purpose", "", "transfer purpose, when transferwise requires one")
+	sourceOfFunds := flags.String("source-of-funds", "", "source of funds, when transferwise requires one")
+	profile := flags.Uint64("profile", 0, "profile ID to create the transfer under, defaults to PROFILE_ID")
+	output := outputFlag(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *profile == 0 {
+		configured, err := getConfiguredProfile()
+		if err != nil {
+			return err
+		}
+		*profile = configured
+	}
+	usage := fmt.Errorf("usage: transfers create --pair <source>-<target> --amount <amount> --recipient <id> [--reference <reference>] " +
+		"[--purpose <purpose>] [--source-of-funds <source>] [--profile <id>] [--output json]")
+	if *pair == "" || *amount <= 0 || *recipient == 0 || *profile == 0 {
+		return usage
+	}
+	source, target, err := splitPairKey(*pair)
+	if err != nil {
+		return err
+	}
+
+	details := TransferDetails{Reference: *reference, TransferPurpose: *purpose, SourceOfFunds: *sourceOfFunds}
+	transfer, err := createInitialTransfer(source, target, *amount, *profile, *recipient, details)
+	if err != nil {
+		return err
+	}
+	return printOutput(os.Stdout, *output, transfer, func(w io.Writer) {
+		fmt.Fprintf(w, "Transfer %v created: %v {%v} --> {%v} at %v to recipient account %v, pay it in on wise.com\n", transfer.Id,
+			formatAmount(transfer.SourceAmount, source), source, target, transfer.Rate, *recipient)
+	})
+}
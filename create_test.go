@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"transferwisely/mocks"
+)
+
+func TestCreateInitialTransfer(t *testing.T) {
+	var created CreateTransferRequest
+	requirements := `[{"type": "transfer", "fields": [{"name": "Transfer reference", "group": [{"key": "reference", "required": true}]},
+		{"name": "Purpose", "group": [{"key": "transferPurpose", "required": false}]}]}]`
+	mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+		body := `{}`
+		switch {
+		case strings.HasSuffix(req.URL.String(), transferRequirementsAPIPath):
+			body = requirements
+		case req.Method == http.MethodPost && strings.HasSuffix(req.URL.String(), quotesAPIPath):
+			body = `{"id": "quote-1", "rate": 100.5, "sourceAmount": 1000, "profile": 1}`
+		case req.Method == http.MethodPost && strings.HasSuffix(req.URL.String(), transfersAPIPath):
+			data, _ := ioutil.ReadAll(req.Body)
+			_ = json.Unmarshal(data, &created)
+			body = `{"id": 8, "rate": 100.5, "targetAccount": 1234, "sourceCurrency": "GBP", "targetCurrency": "INR"}`
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(body))}, nil
+	}
+
+	transfer, err := createInitialTransfer("GBP", "INR", 1000, 1, 1234, TransferDetails{Reference: "rent"})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(8), transfer.Id)
+	assert.Equal(t, decimalOf(1000.0), transfer.SourceAmount)
+	assert.Equal(t, uint64(1234), created.TargetAccount)
+	assert.Equal(t, "rent", created.Details.Reference)
+	assert.NotEmpty(t, created.CustomerTransactionId)
+
+	t.Run("missing required field", func(t *testing.T) {
+		created = CreateTransferRequest{}
+		_, err := createInitialTransfer("GBP", "INR", 1000, 1, 1234, TransferDetails{})
+		assert.EqualError(t, err, "transferwise requires reference for this transfer")
+		assert.Zero(t, created.TargetAccount, "no transfer created")
+	})
+
+	t.Run("quote under another profile", func(t *testing.T) {
+		_, err := createInitialTransfer("GBP", "INR", 1000, 2, 1234, TransferDetails{Reference: "rent"})
+		assert.Error(t, err)
+	})
+}
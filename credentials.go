@@ -0,0 +1,352 @@
+package main
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// credential stores
+const (
+	credentialsStoreKeyring = "keyring"
+	credentialsStoreFile    = "file"
+)
+
+// service the credentials are stored under in the OS keyring
+const keyringService = "transferwisely"
+
+// PBKDF2-SHA256 iterations deriving the credentials file key from CREDENTIALS_PASSPHRASE
+const credentialsKeyIterations = 600000
+
+// secrets auth login stores, read from the credentials store when their env variable is empty
+var credentialKeys = []string{"API_TOKEN", "MAIL_PASS"}
+
+// CredentialsStore keeps secrets out of the environment
+type CredentialsStore interface {
+	// Get returns an empty value for a key never stored
+	Get(key string) (string, error)
+	Set(key string, value string) error
+	Delete(key string) error
+}
+
+// The store CREDENTIALS_STORE selects, nil when credentials are only read from the environment
+func getCredentialsStore() (CredentialsStore, error) {
+	switch credentialsStoreVar {
+	case "":
+		return nil, nil
+	case credentialsStoreKeyring:
+		return keyringStore{}, nil
+	case credentialsStoreFile:
+		if credentialsPassphraseVar == "" {
+			return nil, fmt.Errorf("CREDENTIALS_PASSPHRASE is required with CREDENTIALS_STORE=%v", credentialsStoreFile)
+		}
+		return &fileStore{file: credentialsFileVar, passphrase: credentialsPassphraseVar}, nil
+	default:
+		return nil, fmt.Errorf("invalid value for CREDENTIALS_STORE: %v, must be %v or %v", credentialsStoreVar,
+			credentialsStoreKeyring, credentialsStoreFile)
+	}
+}
+
+// Fill the credential secrets the environment left empty from the credentials store
+func readStoredCredentials() error {
+	store, err := getCredentialsStore()
+	if err != nil || store == nil {
+		return err
+	}
+	for _, secret := range secretVars {
+		if *secret.value != "" || !isCredentialKey(secret.key) {
+			continue
+		}
+		value, err := store.Get(secret.key)
+		if err != nil {
+			return fmt.Errorf("error reading %v from the %v credentials store: %v", secret.key, credentialsStoreVar, err)
+		}
+		*secret.value = value
+	}
+	return nil
+}
+
+func isCredentialKey(key string) bool {
+	for _, k := range credentialKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// runs the OS keyring CLI, feeding it stdin
+var runKeyringCommand = func(stdin string, name string, args ...string) (string, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	out, err := cmd.Output()
+	return strings.TrimRight(string(out), "\r\n"), err
+}
+
+// keyringStore keeps the credentials in the macOS keychain, or in the Secret Service (GNOME Keyring, KWallet) on
+// linux, through their CLIs
+type keyringStore struct{}
+
+func (keyringStore) Get(key string) (string, error) {
+	var value string
+	var err error
+	switch runtime.GOOS {
+	case "darwin":
+		value, err = runKeyringCommand("", "security", "find-generic-password", "-s", keyringService, "-a", key, "-w")
+		// 44 is errSecItemNotFound
+		if isExitCode(err, 44) {
+			return "", nil
+		}
+	case "windows":
+		return "", errKeyringUnsupported
+	default:
+		value, err = runKeyringCommand("", "secret-tool", "lookup", "service", keyringService, "account", key)
+		if isExitCode(err, 1) && value == "" {
+			return "", nil
+		}
+	}
+	return value, err
+}
+
+func (keyringStore) Set(key string, value string) error {
+	var err error
+	switch runtime.GOOS {
+	case "darwin":
+		_, err = runKeyringCommand("", "security", "add-generic-password", "-U", "-s", keyringService, "-a", key, "-w", value)
+	case "windows":
+		return errKeyringUnsupported
+	default:
+		_, err = runKeyringCommand(value, "secret-tool", "store", "--label", keyringService+" "+key,
+			"service", keyringService, "account", key)
+	}
+	return err
+}
+
+func (keyringStore) Delete(key string) error {
+	var err error
+	switch runtime.GOOS {
+	case "darwin":
+		_, err = runKeyringCommand("", "security", "delete-generic-password", "-s", keyringService, "-a", key)
+		if isExitCode(err, 44) {
+			return nil
+		}
+	case "windows":
+		return errKeyringUnsupported
+	default:
+		_, err = runKeyringCommand("", "secret-tool", "clear", "service", keyringService, "account", key)
+	}
+	return err
+}
+
+var errKeyringUnsupported = errors.New("no OS keyring support on windows, use CREDENTIALS_STORE=file")
+
+func isExitCode(err error, code int) bool {
+	var exitErr *exec.ExitError
+	return errors.As(err, &exitErr) && exitErr.ExitCode() == code
+}
+
+// fileStore keeps the credentials AES-256-GCM encrypted in a file, the key being derived from a passphrase
+type fileStore struct {
+	file       string
+	passphrase string
+
+	// key derived for salt, deriving it being slow on purpose
+	salt []byte
+	key  []byte
+}
+
+// encryptedCredentials is the content of CREDENTIALS_FILE
+type encryptedCredentials struct {
+	Salt  []byte `json:"salt"`
+	Nonce []byte `json:"nonce"`
+	Data  []byte `json:"data"`
+}
+
+func (s *fileStore) Get(key string) (string, error) {
+	credentials, _, err := s.load()
+	if err != nil {
+		return "", err
+	}
+	return credentials[key], nil
+}
+
+func (s *fileStore) Set(key string, value string) error {
+	credentials, salt, err := s.load()
+	if err != nil {
+		return err
+	}
+	credentials[key] = value
+	return s.save(credentials, salt)
+}
+
+func (s *fileStore) Delete(key string) error {
+	credentials, salt, err := s.load()
+	if err != nil || credentials[key] == "" {
+		return err
+	}
+	delete(credentials, key)
+	return s.save(credentials, salt)
+}
+
+// Decrypt the credentials, a missing file holding none
+func (s *fileStore) load() (map[string]string, []byte, error) {
+	credentials := map[string]string{}
+	data, err := ioutil.ReadFile(s.file)
+	if os.IsNotExist(err) {
+		return credentials, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var encrypted encryptedCredentials
+	if err := json.Unmarshal(data, &encrypted); err != nil {
+		return nil, nil, fmt.Errorf("error decoding %v: %v", s.file, err)
+	}
+	gcm, err := s.cipher(encrypted.Salt)
+	if err != nil {
+		return nil, nil, err
+	}
+	plaintext, err := gcm.Open(nil, encrypted.Nonce, encrypted.Data, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error decrypting %v, wrong CREDENTIALS_PASSPHRASE?", s.file)
+	}
+	if err := json.Unmarshal(plaintext, &credentials); err != nil {
+		return nil, nil, fmt.Errorf("error decoding %v: %v", s.file, err)
+	}
+	return credentials, encrypted.Salt, nil
+}
+
+// Encrypt the credentials under a fresh nonce, and a fresh salt for a new file
+func (s *fileStore) save(credentials map[string]string, salt []byte) error {
+	if salt == nil {
+		salt = make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return err
+		}
+	}
+	gcm, err := s.cipher(salt)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	plaintext, _ := json.Marshal(credentials)
+	data, _ := json.Marshal(encryptedCredentials{Salt: salt, Nonce: nonce, Data: gcm.Seal(nil, nonce, plaintext, nil)})
+	return ioutil.WriteFile(s.file, data, 0600)
+}
+
+func (s *fileStore) cipher(salt []byte) (cipher.AEAD, error) {
+	if s.key == nil || !hmac.Equal(s.salt, salt) {
+		s.salt, s.key = salt, pbkdf2SHA256([]byte(s.passphrase), salt, credentialsKeyIterations)
+	}
+	block, err := aes.NewCipher(s.key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// PBKDF2 (RFC 8018) with HMAC-SHA256, deriving a single block, the 32 bytes of an AES-256 key
+func pbkdf2SHA256(password []byte, salt []byte, iterations int) []byte {
+	mac := hmac.New(sha256.New, password)
+	mac.Write(salt)
+	mac.Write([]byte{0, 0, 0, 1})
+	u := mac.Sum(nil)
+	key := append([]byte(nil), u...)
+	for i := 1; i < iterations; i++ {
+		mac.Reset()
+		mac.Write(u)
+		u = mac.Sum(u[:0])
+		for j := range key {
+			key[j] ^= u[j]
+		}
+	}
+	return key
+}
+
+// Prompt for a secret on the terminal, without echoing it when stty is available
+func promptSecret(reader *bufio.Reader, prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	if _, err := stty("-echo"); err == nil {
+		defer func() {
+			_, _ = stty("echo")
+			fmt.Fprintln(os.Stderr)
+		}()
+	}
+	line, err := reader.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func runAuthCommand(args []string) error {
+	usage := fmt.Errorf("usage: auth login | auth logout")
+	if len(args) != 1 {
+		return usage
+	}
+	store, err := getCredentialsStore()
+	if err != nil {
+		return err
+	}
+	if store == nil {
+		return fmt.Errorf("set CREDENTIALS_STORE to %v or %v first", credentialsStoreKeyring, credentialsStoreFile)
+	}
+
+	switch args[0] {
+	case "login":
+		reader := bufio.NewReader(os.Stdin)
+		token, err := promptSecret(reader, "Wise API token: ")
+		if err != nil {
+			return err
+		}
+		if token == "" {
+			return fmt.Errorf("no API token given")
+		}
+		mailPass, err := promptSecret(reader, "SMTP password (empty to skip): ")
+		if err != nil {
+			return err
+		}
+		if err := store.Set("API_TOKEN", token); err != nil {
+			return fmt.Errorf("error storing API_TOKEN: %v", err)
+		}
+		if mailPass != "" {
+			if err := store.Set("MAIL_PASS", mailPass); err != nil {
+				return fmt.Errorf("error storing MAIL_PASS: %v", err)
+			}
+		}
+		fmt.Printf("Credentials saved to the %v credentials store, API_TOKEN and MAIL_PASS can be unset\n", credentialsStoreVar)
+		return nil
+	case "logout":
+		for _, key := range credentialKeys {
+			if err := store.Delete(key); err != nil {
+				return fmt.Errorf("error deleting %v: %v", key, err)
+			}
+		}
+		fmt.Printf("Credentials removed from the %v credentials store\n", credentialsStoreVar)
+		return nil
+	default:
+		return usage
+	}
+}
+
+func init() {
+	registerCommand("auth", Command{
+		Usage: "auth login|logout                            store the API token and SMTP password outside of the environment",
+		Run:   runAuthCommand,
+	})
+}
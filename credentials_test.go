@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPBKDF2SHA256(t *testing.T) {
+	// RFC 7914 section 11 test vectors
+	assert.Equal(t, "120fb6cffcf8b32c43e7225256c4f837a86548c92ccc35480805987cb70be17b",
+		hex.EncodeToString(pbkdf2SHA256([]byte("password"), []byte("salt"), 1)))
+	assert.Equal(t, "ae4d0c95af6b46d32d0adff928f06dd02a303f8ef3c251dfd6e2d85a95474c43",
+		hex.EncodeToString(pbkdf2SHA256([]byte("password"), []byte("salt"), 2)))
+}
+
+func TestFileStore(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "transferwisely")
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "credentials.enc")
+	store := &fileStore{file: file, passphrase: "correct horse"}
+
+	value, err := store.Get("API_TOKEN")
+	assert.NoError(t, err)
+	assert.Empty(t, value, "no file yet")
+
+	assert.NoError(t, store.Set("API_TOKEN", "secret-token"))
+	assert.NoError(t, store.Set("MAIL_PASS", "secret-pass"))
+	data, _ := ioutil.ReadFile(file)
+	assert.NotContains(t, string(data), "secret-token")
+
+	value, err = store.Get("API_TOKEN")
+	assert.NoError(t, err)
+	assert.Equal(t, "secret-token", value)
+
+	_, err = (&fileStore{file: file, passphrase: "wrong"}).Get("API_TOKEN")
+	assert.Error(t, err)
+
+	assert.NoError(t, store.Delete("MAIL_PASS"))
+	value, _ = store.Get("MAIL_PASS")
+	assert.Empty(t, value)
+}
+
+func TestReadStoredCredentials(t *testing.T) {
+	defer func(store, token, pass string) {
+		credentialsStoreVar, apiTokenVar, mailPassVar = store, token, pass
+	}(credentialsStoreVar, apiTokenVar, mailPassVar)
+	defer func(run func(string, string, ...string) (string, error)) { runKeyringCommand = run }(runKeyringCommand)
+
+	var lookups []string
+	runKeyringCommand = func(stdin string, name string, args ...string) (string, error) {
+		key := args[len(args)-1]
+		if key == "-w" {
+			key = args[len(args)-2]
+		}
+		lookups = append(lookups, key)
+		return "stored-" + key, nil
+	}
+
+	credentialsStoreVar, apiTokenVar, mailPassVar = credentialsStoreKeyring, "", "from-env"
+	assert.NoError(t, readStoredCredentials())
+	assert.Equal(t, "stored-API_TOKEN", apiTokenVar)
+	assert.Equal(t, "from-env", mailPassVar, "the environment wins")
+	assert.Equal(t, []string{"API_TOKEN"}, lookups)
+
+	credentialsStoreVar = "vault"
+	assert.Error(t, readStoredCredentials())
+
+	t.Run("file store needs a passphrase", func(t *testing.T) {
+		defer func(passphrase string) { credentialsPassphraseVar = passphrase }(credentialsPassphraseVar)
+		credentialsStoreVar, credentialsPassphraseVar = credentialsStoreFile, ""
+		assert.Error(t, readStoredCredentials())
+	})
+}
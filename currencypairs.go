@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// CurrencyPairs are the routes transferwise supports, see
+// https://docs.wise.com/api-docs/api-reference/currencies#list-currency-pairs
+type CurrencyPairs struct {
+	SourceCurrencies []struct {
+		CurrencyCode     string `json:"currencyCode"`
+		TargetCurrencies []struct {
+			CurrencyCode string `json:"currencyCode"`
+		} `json:"targetCurrencies"`
+	} `json:"sourceCurrencies"`
+}
+
+// Target currencies supported from each source currency
+type currencyRoutes map[string]map[string]bool
+
+func getCurrencyRoutes() (currencyRoutes, error) {
+	url := &url.URL{Host: hostVar, Scheme: "https", Path: currencyPairsAPIPath}
+
+	var pairs CurrencyPairs
+	_, err := callExternalAPI(http.MethodGet, url.String(), nil, &pairs)
+	if err != nil {
+		return nil, fmt.Errorf("error GET currency pairs API: %w", err)
+	}
+
+	routes := currencyRoutes{}
+	for _, source := range pairs.SourceCurrencies {
+		targets := map[string]bool{}
+		for _, target := range source.TargetCurrencies {
+			targets[strings.ToUpper(target.CurrencyCode)] = true
+		}
+		routes[strings.ToUpper(source.CurrencyCode)] = targets
+	}
+	return routes, nil
+}
+
+// Check the pair is a route transferwise supports, suggesting a currency for a typo like GPB
+func (routes currencyRoutes) validate(source string, target string) error {
+	targets, ok := routes[source]
+	if !ok {
+		return fmt.Errorf("unknown source currency %v%v", source, routes.suggest(source))
+	}
+	if targets[target] {
+		return nil
+	}
+	if !routes.isCurrency(target) {
+		return fmt.Errorf("unknown target currency %v%v", target, routes.suggest(target))
+	}
+	return fmt.Errorf("transferwise doesn't support sending %v to %v", source, target)
+}
+
+func (routes currencyRoutes) isCurrency(code string) bool {
+	if _, ok := routes[code]; ok {
+		return true
+	}
+	for _, targets := range routes {
+		if targets[code] {
+			return true
+		}
+	}
+	return false
+}
+
+// A known currency the unknown code is likely a typo of: the same letters swapped, or one of them wrong
+func (routes currencyRoutes) suggest(code string) string {
+	known := map[string]bool{}
+	for source, targets := range routes {
+		known[source] = true
+		for target := range targets {
+			known[target] = true
+		}
+	}
+	var candidates []string
+	for currency := range known {
+		if isCurrencyTypo(code, currency) {
+			candidates = append(candidates, currency)
+		}
+	}
+	if len(candidates) == 0 {
+		return ""
+	}
+	sort.Strings(candidates)
+	return fmt.Sprintf(", did you mean %v?", strings.Join(candidates, " or "))
+}
+
+func isCurrencyTypo(code string, currency string) bool {
+	if len(code) != len(currency) || code == currency {
+		return false
+	}
+	var diff []int
+	for i := range code {
+		if code[i] != currency[i] {
+			diff = append(diff, i)
+		}
+	}
+	switch len(diff) {
+	case 1:
+		return true
+	case 2:
+		return diff[1] == diff[0]+1 && code[diff[0]] == currency[diff[1]] && code[diff[1]] == currency[diff[0]]
+	default:
+		return false
+	}
+}
+
+// Upper case and trim the pair keys of the config file, so gbp-inr applies to GBP-INR transfers, rejecting the ones
+// that aren't <source>-<target> or given twice
+func normalizePairs(pairs map[string]Overrides) (map[string]Overrides, error) {
+	if pairs == nil {
+		return nil, nil
+	}
+	normalized := make(map[string]Overrides, len(pairs))
+	for pair, overrides := range pairs {
+		source, target, err := splitPairKey(strings.ReplaceAll(pair, " ", ""))
+		if err != nil {
+			return nil, fmt.Errorf("%v in config file", err)
+		}
+		key := pairKey(source, target)
+		if _, ok := normalized[key]; ok {
+			return nil, fmt.Errorf("pair %v given twice in config file", key)
+		}
+		normalized[key] = overrides
+	}
+	return normalized, nil
+}
+
+// Pairs the config file refers to, each once and sorted
+func configuredPairs(c Config) []string {
+	seen := map[string]bool{}
+	add := func(pairs map[string]Overrides) {
+		for pair := range pairs {
+			seen[pair] = true
+		}
+	}
+	add(c.Pairs)
+	for _, account := range c.Accounts {
+		add(account.Pairs)
+	}
+	for _, ranking := range c.SourceRankings {
+		for _, source := range ranking.Sources {
+			seen[pairKey(source, ranking.Target)] = true
+		}
+	}
+
+	pairs := make([]string, 0, len(seen))
+	for pair := range seen {
+		pairs = append(pairs, pair)
+	}
+	sort.Strings(pairs)
+	return pairs
+}
+
+// Check the pairs of the config file against the routes transferwise supports, before a typo or an unsupported
+// corridor fails the first quote. The check is skipped, with a warning, when the routes can't be listed
+func validateConfiguredPairs() error {
+	pairs := configuredPairs(getConfig())
+	if len(pairs) == 0 {
+		return nil
+	}
+	routes, err := getCurrencyRoutes()
+	if err != nil {
+		log.Printf("validateConfiguredPairs: skipped, %v", err)
+		return nil
+	}
+
+	for _, pair := range pairs {
+		source, target, err := splitPairKey(pair)
+		if err != nil {
+			return err
+		}
+		if err := routes.validate(source, target); err != nil {
+			return fmt.Errorf("invalid pair %v in config file: %v", pair, err)
+		}
+	}
+	log.Printf("|| PAIRS || %v supported by transferwise ||", strings.Join(pairs, ", "))
+	return nil
+}
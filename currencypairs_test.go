@@ -0,0 +1,58 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"transferwisely/wisemock"
+)
+
+func TestCurrencyRoutesValidate(t *testing.T) {
+	routes := currencyRoutes{"GBP": {"INR": true, "EUR": true}, "EUR": {"GBP": true}}
+
+	assert.NoError(t, routes.validate("GBP", "INR"))
+	assert.EqualError(t, routes.validate("GPB", "INR"), "unknown source currency GPB, did you mean GBP?")
+	assert.EqualError(t, routes.validate("GBP", "IRN"), "unknown target currency IRN, did you mean INR?")
+	assert.EqualError(t, routes.validate("GBP", "XYZ"), "unknown target currency XYZ")
+	assert.EqualError(t, routes.validate("EUR", "INR"), "transferwise doesn't support sending EUR to INR")
+}
+
+func TestNormalizePairs(t *testing.T) {
+	margin := decimalOf(0.3)
+	pairs, err := normalizePairs(map[string]Overrides{"gbp-inr": {Margin: &margin}, " EUR - USD ": {}})
+	assert.NoError(t, err)
+	assert.Contains(t, pairs, "GBP-INR")
+	assert.Contains(t, pairs, "EUR-USD")
+
+	_, err = normalizePairs(map[string]Overrides{"GBP-INR": {}, "gbp-inr": {}})
+	assert.EqualError(t, err, "pair GBP-INR given twice in config file")
+
+	_, err = normalizePairs(map[string]Overrides{"GBPINR": {}})
+	assert.EqualError(t, err, "invalid currency pair GBPINR, expected <source>-<target> like GBP-INR in config file")
+}
+
+func TestValidateConfiguredPairs(t *testing.T) {
+	startMockForTest(t, wisemock.DefaultScenario())
+	defer func(file string, c Config) { configFileVar, config.current = file, c }(configFileVar, getConfig())
+	dir, _ := ioutil.TempDir("", "transferwisely")
+	defer os.RemoveAll(dir)
+	configFileVar = filepath.Join(dir, "config.json")
+
+	_ = ioutil.WriteFile(configFileVar, []byte(`{"pairs": {"gbp-inr": {"margin": 0.2}}}`), 0600)
+	assert.NoError(t, loadConfig())
+	assert.NoError(t, validateConfiguredPairs())
+	settings, err := getSettings(Transfer{SourceCurrency: "GBP", TargetCurrency: "INR"})
+	assert.NoError(t, err)
+	assert.Equal(t, decimalOf(0.2), settings.Margin, "lower case pair applied to the transfers")
+
+	_ = ioutil.WriteFile(configFileVar, []byte(`{"pairs": {"GPB-INR": {"margin": 0.2}}}`), 0600)
+	assert.NoError(t, loadConfig())
+	assert.EqualError(t, validateConfiguredPairs(), "invalid pair GPB-INR in config file: unknown source currency GPB, "+
+		"did you mean GBP?")
+
+	_ = ioutil.WriteFile(configFileVar, []byte(`{"sourceRankings": [{"sources": ["gbp", "eur"], "target": "inr", "targetAmount": 1000}]}`), 0600)
+	assert.NoError(t, loadConfig())
+	assert.EqualError(t, validateConfiguredPairs(), "invalid pair EUR-INR in config file: unknown source currency EUR")
+}
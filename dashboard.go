@@ -0,0 +1,239 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// TrackedTransfer is the last observation of a tracked transfer and its live rate
+type TrackedTransfer struct {
+	Transfer  Transfer
+	LiveRate  float64
+	Threshold float64
+	Better    bool
+	CheckedAt time.Time
+}
+
+// tracked transfers by currency pair, as of their last check
+var tracked = struct {
+	sync.Mutex
+	transfers map[string]TrackedTransfer
+}{transfers: map[string]TrackedTransfer{}}
+
+// The live rate re-booking the transfer as soon as the margin is reached
+func rebookThreshold(transfer Transfer, settings Settings) float64 {
+	if settings.LowerIsBetter {
+		return decimalOf(transfer.Rate).Sub(settings.Margin).Float64()
+	}
+	return decimalOf(transfer.Rate).Add(settings.Margin).Float64()
+}
+
+func recordTracked(transfer Transfer, liveRate float64, settings Settings) {
+	threshold := rebookThreshold(transfer, settings)
+	observation := TrackedTransfer{
+		Transfer:  transfer,
+		LiveRate:  liveRate,
+		Threshold: threshold,
+		Better:    rateImprovement(transfer.Rate, liveRate, settings).Sign() > 0,
+		CheckedAt: time.Now().UTC(),
+	}
+	tracked.Lock()
+	tracked.transfers[pairKey(transfer.SourceCurrency, transfer.TargetCurrency)] = observation
+	tracked.Unlock()
+
+	update := RateUpdate{
+		Time:           observation.CheckedAt,
+		TransferId:     transfer.Id,
+		SourceCurrency: transfer.SourceCurrency,
+		TargetCurrency: transfer.TargetCurrency,
+		BookedRate:     transfer.Rate,
+		LiveRate:       liveRate,
+		Threshold:      threshold,
+		Better:         observation.Better,
+	}
+	publishStream(streamRate, update)
+	publishEventBus(busRate, pairKey(transfer.SourceCurrency, transfer.TargetCurrency), update)
+	if isPublishingHomeAssistant() {
+		go publishHomeAssistant(observation, settings)
+	}
+}
+
+func getTracked() []TrackedTransfer {
+	tracked.Lock()
+	defer tracked.Unlock()
+
+	transfers := make([]TrackedTransfer, 0, len(tracked.transfers))
+	for _, transfer := range tracked.transfers {
+		transfers = append(transfers, transfer)
+	}
+	sort.Slice(transfers, func(i, j int) bool {
+		return pairKey(transfers[i].Transfer.SourceCurrency, transfers[i].Transfer.TargetCurrency) <
+			pairKey(transfers[j].Transfer.SourceCurrency, transfers[j].Transfer.TargetCurrency)
+	})
+	return transfers
+}
+
+// DashboardData is rendered by the dashboard template
+type DashboardData struct {
+	Env        string
+	Margin     string
+	LastCheck  time.Time
+	NextCheck  time.Time
+	Tracked    []TrackedTransfer
+	Balances   []Balance
+	Rebooks    []RebookRecord
+	Spreads    []SpreadSummary
+	Activities []Activity
+	Paused     *Pause
+	Error      string
+}
+
+var dashboardTmpl = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"time": func(t time.Time) string {
+		if t.IsZero() {
+			return "-"
+		}
+		return formatTime(t)
+	},
+	"amount": formatAmount,
+	"text":   activityText,
+	"expiry": formatExpiry,
+	"percent": func(value float64) string {
+		return fmt.Sprintf("%.2f%%", value)
+	},
+}).Parse(dashboardTemplate))
+
+func dashboardHandler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	data := DashboardData{
+		Env:        envVar,
+		Margin:     marginVar,
+		LastCheck:  getHealthStatus().LastCheck,
+		Tracked:    getTracked(),
+		Balances:   getWatchedBalances(),
+		Activities: getPolledActivities(),
+	}
+	if checkJob != nil {
+		data.NextCheck = checkJob.NextRun().UTC()
+	}
+
+	state, err := loadState()
+	if err != nil {
+		data.Error = err.Error()
+	}
+	data.Paused = state.Pause
+	for i := len(state.RebookHistory) - 1; i >= 0; i-- {
+		data.Rebooks = append(data.Rebooks, state.RebookHistory[i])
+	}
+	data.Spreads = summarizeQuoteSpreads(state.QuoteSpreads)
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := dashboardTmpl.Execute(w, data); err != nil {
+		log.Printf("dashboardHandler: %v", err)
+	}
+}
+
+const dashboardTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>transferwisely</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #333; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ddd; padding: 0.4em 0.8em; text-align: left; }
+th { background: #f4f4f4; }
+.better { color: #2e7d32; font-weight: bold; }
+.error { color: #c62828; }
+.paused { background: #fff3e0; border: 2px solid #ef6c00; padding: 0.5em; font-weight: bold; }
+</style>
+</head>
+<body>
+<h2>&#128184; transferwisely <small>({{.Env}})</small></h2>
+{{if .Paused}}<p class="paused">&#9208; PAUSED since {{time .Paused.At}} through the {{.Paused.By}}, no re-booking until resumed</p>{{end}}
+<p>Margin: {{.Margin}} | Last check: {{time .LastCheck}} | Next check: {{if .Paused}}paused{{else}}{{time .NextCheck}}{{end}}</p>
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+
+<h3>Tracked transfers</h3>
+<table>
+<tr><th>Transfer ID</th><th>Pair</th><th>Amount</th><th>Booked rate</th><th>Live rate</th><th>Re-book above</th><th>Rate lock expires</th><th>Checked at</th></tr>
+{{range .Tracked}}
+<tr>
+<td>{{.Transfer.Id}}</td>
+<td>{{.Transfer.SourceCurrency}} &rarr; {{.Transfer.TargetCurrency}}</td>
+<td>{{amount .Transfer.SourceAmount .Transfer.SourceCurrency}} {{.Transfer.SourceCurrency}}</td>
+<td>{{.Transfer.Rate}}</td>
+<td{{if .Better}} class="better"{{end}}>{{.LiveRate}}</td>
+<td>{{.Threshold}}</td>
+<td>{{expiry .Transfer.RateExpirationTime}}</td>
+<td>{{time .CheckedAt}}</td>
+</tr>
+{{else}}
+<tr><td colspan="8">No transfer checked yet</td></tr>
+{{end}}
+</table>
+
+{{if .Balances}}
+<h3>Balances</h3>
+<table>
+<tr><th>Currency</th><th>Amount</th></tr>
+{{range .Balances}}
+<tr><td>{{.Currency}}</td><td>{{amount .Amount.Value .Currency}} {{.Currency}}</td></tr>
+{{end}}
+</table>
+{{end}}
+
+{{if .Spreads}}
+<h3>Quote spreads to the mid-market rate</h3>
+<table>
+<tr><th>Pair</th><th>Quotes</th><th>Last</th><th>Average</th><th>Widest</th><th>Trend</th></tr>
+{{range .Spreads}}
+<tr><td>{{.Pair}}</td><td>{{.Quotes}}</td><td>{{percent .Last}}</td><td>{{percent .Avg}}</td><td>{{percent .Max}}</td><td>{{.Sparkline}}</td></tr>
+{{end}}
+</table>
+{{end}}
+
+{{if .Activities}}
+<h3>Activity on wise.com</h3>
+<table>
+<tr><th>Date</th><th>Type</th><th>Status</th><th>Title</th><th>Amount</th></tr>
+{{range .Activities}}
+<tr><td>{{time .CreatedOn}}</td><td>{{.Type}}</td><td>{{.Status}}</td><td>{{text .Title}}</td><td>{{text .PrimaryAmount}}{{if .SecondaryAmount}} ({{text .SecondaryAmount}}){{end}}</td></tr>
+{{end}}
+</table>
+{{end}}
+
+<h3>Re-bookings</h3>
+<table>
+<tr><th>Time</th><th>Pair</th><th>Old transfer</th><th>New transfer</th><th>Old rate</th><th>New rate</th><th>Amount</th><th>Reason</th></tr>
+{{range .Rebooks}}
+<tr>
+<td>{{time .Time}}</td>
+<td>{{.SourceCurrency}} &rarr; {{.TargetCurrency}}</td>
+<td>{{.OldTransferId}}</td>
+<td>{{.NewTransferId}}</td>
+<td>{{.OldRate}}</td>
+<td>{{.NewRate}}</td>
+<td>{{amount .SourceAmount .SourceCurrency}} {{.SourceCurrency}}</td>
+<td>{{.Reason}}</td>
+</tr>
+{{else}}
+<tr><td colspan="8">No re-booking yet</td></tr>
+{{end}}
+</table>
+<script>
+// reload on every check outcome pushed by /stream rather than polling
+new EventSource("/stream?types=decision").addEventListener("decision", function() { location.reload(); });
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDashboardHandler(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "transferwisely")
+	defer os.RemoveAll(dir)
+	defer func(value string) { stateFileVar = value }(stateFileVar)
+	stateFileVar = filepath.Join(dir, "state.json")
+
+	recordTracked(Transfer{Id: 1234, SourceCurrency: "JPY", TargetCurrency: "INR", Rate: 0.691, SourceAmount: decimalOf(10000)}, 0.695, Settings{Margin: decimalOf(0.001)})
+	assert.NoError(t, recordRebookHistory(RebookRecord{
+		Time: time.Now().UTC(), OldTransferId: 1000, NewTransferId: 1234, SourceCurrency: "JPY", TargetCurrency: "INR",
+		OldRate: 0.68, NewRate: 0.691, Reason: rebookReasonBetterRate,
+	}))
+
+	w := httptest.NewRecorder()
+	dashboardHandler(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "1234")
+	assert.Contains(t, w.Body.String(), "0.695")
+	assert.Contains(t, w.Body.String(), "1000")
+	assert.NotContains(t, w.Body.String(), "PAUSED")
+
+	assert.NoError(t, setPaused(true, pausedByCLI))
+	w = httptest.NewRecorder()
+	dashboardHandler(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Contains(t, w.Body.String(), "PAUSED since")
+	assert.Contains(t, w.Body.String(), "through the CLI")
+
+	w = httptest.NewRecorder()
+	dashboardHandler(w, httptest.NewRequest(http.MethodGet, "/unknown", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
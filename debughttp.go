@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// JSON keys whose values are redacted from the DEBUG_HTTP log on top of the secret ones, matched case insensitively
+// within the key: the names, contact details, addresses and bank details of the account holder and the recipients
+var debugPIIKeys = []string{"name", "email", "phone", "address", "city", "postcode", "dateofbirth", "accountnumber",
+	"iban", "bic", "swift", "sortcode", "routing", "ifsc", "abartn"}
+
+// Headers whose values are redacted from the DEBUG_HTTP log, matched case insensitively within the header name
+var debugSecretHeaders = []string{"authorization", "cookie", "token", "signature", "api-key"}
+
+// Log the full transferwise API requests and responses, headers and bodies, with the API token, the secrets and the
+// PII redacted, to diagnose validation failures like 422s on exotic corridors
+type debugHTTP struct {
+	next HTTPClient
+}
+
+// The HTTP client logging through DEBUG_HTTP when it is true, otherwise next as is
+func debugHTTPClient(next HTTPClient) HTTPClient {
+	if debug, _ := strconv.ParseBool(debugHTTPVar); !debug {
+		return next
+	}
+	return &debugHTTP{next: next}
+}
+
+func (d *debugHTTP) Do(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		if reqBody, err = ioutil.ReadAll(req.Body); err != nil {
+			return nil, fmt.Errorf("debugHTTP: %v", err)
+		}
+		_ = req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(reqBody))
+		req.GetBody = func() (io.ReadCloser, error) { return ioutil.NopCloser(bytes.NewReader(reqBody)), nil }
+	}
+	log.Printf("|| HTTP --> %v %v ||\n%v%v", req.Method, redactSecrets(req.URL.String()), formatDebugHeaders(req.Header),
+		formatDebugBody(reqBody))
+
+	res, err := d.next.Do(req)
+	if err != nil {
+		log.Printf("|| HTTP <-- %v %v FAILED || %v", req.Method, redactSecrets(req.URL.String()), redactSecrets(err.Error()))
+		return res, err
+	}
+	resBody, err := ioutil.ReadAll(res.Body)
+	_ = res.Body.Close()
+	res.Body = ioutil.NopCloser(bytes.NewReader(resBody))
+	if err != nil {
+		return res, fmt.Errorf("debugHTTP: %v", err)
+	}
+	log.Printf("|| HTTP <-- %v %v %v ||\n%v%v", req.Method, redactSecrets(req.URL.String()), res.Status,
+		formatDebugHeaders(res.Header), formatDebugBody(resBody))
+	return res, nil
+}
+
+// The headers one per line, sorted by name, the secret ones redacted
+func formatDebugHeaders(header http.Header) string {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		value := strings.Join(header[name], ", ")
+		if containsKey(name, debugSecretHeaders) {
+			value = auditRedacted
+		}
+		fmt.Fprintf(&b, "%v: %v\n", name, redactSecrets(value))
+	}
+	return b.String()
+}
+
+// The body indented, with the secrets and PII redacted when it is JSON, and only its size otherwise, as receipts
+// and other binary bodies make no sense in a log
+func formatDebugBody(body []byte) string {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return ""
+	}
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return fmt.Sprintf("\n<%v bytes of non JSON body>\n", len(body))
+	}
+	data, _ := json.MarshalIndent(redactValue(v, append(append([]string{}, auditSecretKeys...), debugPIIKeys...)), "", "  ")
+	return "\n" + string(data) + "\n"
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"transferwisely/mocks"
+)
+
+func TestDebugHTTPClient(t *testing.T) {
+	defer func(debug string, token string) { debugHTTPVar, apiTokenVar = debug, token }(debugHTTPVar, apiTokenVar)
+	defer log.SetOutput(os.Stderr)
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+
+	var sent string
+	mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+		body, _ := ioutil.ReadAll(req.Body)
+		sent = string(body)
+		return &http.Response{StatusCode: http.StatusUnprocessableEntity, Status: "422 Unprocessable Entity",
+			Header: http.Header{"Content-Type": {"application/json"}},
+			Body: ioutil.NopCloser(strings.NewReader(`{"errors": [{"code": "NOT_VALID", "path": "details.ifscCode", ` +
+				`"message": "IFSC code is invalid"}]}`))}, nil
+	}
+	apiTokenVar = "secret-token"
+	request := `{"targetAccount": 7, "details": {"reference": "rent", "accountHolderName": "Jane Doe", "ifscCode": "XYZ"}}`
+
+	t.Run("off by default", func(t *testing.T) {
+		debugHTTPVar = "false"
+		_, err := callExternalAPI(http.MethodPost, "https://host/v1/transfers", []byte(request), nil)
+		assert.Error(t, err)
+		assert.NotContains(t, logged.String(), "|| HTTP")
+	})
+
+	t.Run("logs requests and responses redacted", func(t *testing.T) {
+		debugHTTPVar = "true"
+		_, err := callExternalAPI(http.MethodPost, "https://host/v1/transfers", []byte(request), nil)
+		assert.Error(t, err)
+		assert.Equal(t, request, sent, "body still sent as is")
+
+		output := logged.String()
+		assert.Contains(t, output, "|| HTTP --> POST https://host/v1/transfers ||")
+		assert.Contains(t, output, "|| HTTP <-- POST https://host/v1/transfers 422 Unprocessable Entity ||")
+		assert.Contains(t, output, "Authorization: [REDACTED]")
+		assert.Contains(t, output, `"reference": "rent"`)
+		assert.Contains(t, output, `"accountHolderName": "[REDACTED]"`)
+		assert.Contains(t, output, `"message": "IFSC code is invalid"`)
+		assert.NotContains(t, output, "secret-token")
+		assert.NotContains(t, output, "Jane Doe")
+	})
+}
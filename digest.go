@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+const (
+	noActionDigestSubject = "Daily digest: no better rate found"
+	noActionDigestText    = "%v checks since the last digest, none found a better rate.\n" +
+		"Transfer ID: %v\n{%v} --> {%v}\nBooked Rate: %v\nLive Rate: min %v | max %v | last %v"
+)
+
+// no action checks collected since the last digest
+var noActionDigest = struct {
+	sync.Mutex
+	checks   int
+	transfer Transfer
+	minRate  float64
+	maxRate  float64
+	lastRate float64
+}{}
+
+func recordNoAction(transfer Transfer, liveRate float64) {
+	noActionDigest.Lock()
+	defer noActionDigest.Unlock()
+
+	if noActionDigest.checks == 0 || liveRate < noActionDigest.minRate {
+		noActionDigest.minRate = liveRate
+	}
+	if noActionDigest.checks == 0 || liveRate > noActionDigest.maxRate {
+		noActionDigest.maxRate = liveRate
+	}
+	noActionDigest.checks++
+	noActionDigest.transfer = transfer
+	noActionDigest.lastRate = liveRate
+}
+
+// Notify a summary of the no action checks since the last digest, if there were any
+func sendNoActionDigest() {
+	noActionDigest.Lock()
+	checks, transfer := noActionDigest.checks, noActionDigest.transfer
+	minRate, maxRate, lastRate := noActionDigest.minRate, noActionDigest.maxRate, noActionDigest.lastRate
+	noActionDigest.checks = 0
+	noActionDigest.Unlock()
+
+	if checks == 0 {
+		return
+	}
+
+	summary, summaryText := notificationRateSummary(transfer.SourceCurrency, transfer.TargetCurrency)
+	notify(Event{
+		Kind:    EventNoActionDigest,
+		Subject: noActionDigestSubject,
+		Text: fmt.Sprintf(noActionDigestText, checks, transfer.Id, transfer.SourceCurrency, transfer.TargetCurrency,
+			formatRate(transfer.Rate), formatRate(minRate), formatRate(maxRate), formatRate(lastRate)) + summaryText,
+		Data: DigestMailData{Checks: checks, Transfer: transfer, MinRate: minRate, MaxRate: maxRate, LastRate: lastRate,
+			Summary: summary},
+		Pair:       pairKey(transfer.SourceCurrency, transfer.TargetCurrency),
+		TransferId: transfer.Id,
+	})
+}
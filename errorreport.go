@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"log"
+	"net/url"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// error report levels
+const (
+	reportLevelError = "error"
+	reportLevelFatal = "fatal"
+)
+
+// Rollbar item API
+const rollbarItemURL = "https://api.rollbar.com/api/1/item/"
+
+// ErrorReport is what gets sent to every configured error reporting service
+type ErrorReport struct {
+	Message string
+	Level   string
+	Time    time.Time
+	Tags    map[string]string
+	Stack   string
+}
+
+// ErrorReporter sends error reports to a single error reporting service
+type ErrorReporter interface {
+	Name() string
+	Report(report ErrorReport) error
+}
+
+var ErrorReporters []ErrorReporter
+
+func init() {
+	ErrorReporters = getConfiguredErrorReporters()
+}
+
+// consecutive failed checks, reported once they reach ERROR_REPORT_THRESHOLD, and the last transfer-wise API status code
+var failures = struct {
+	sync.Mutex
+	checks         uint64
+	lastStatusCode int
+}{}
+
+// Every service whose env variables are provided gets the reports, an invalid SENTRY_DSN being left out
+func getConfiguredErrorReporters() (reporters []ErrorReporter) {
+	if sentryDSNVar != "" {
+		if reporter, err := newSentryReporter(sentryDSNVar); err == nil {
+			reporters = append(reporters, reporter)
+		}
+	}
+	if rollbarAccessTokenVar != "" {
+		reporters = append(reporters, &rollbarReporter{token: rollbarAccessTokenVar})
+	}
+	return
+}
+
+func reportError(report ErrorReport) {
+	if report.Time.IsZero() {
+		report.Time = time.Now().UTC()
+	}
+	if report.Tags == nil {
+		report.Tags = map[string]string{}
+	}
+	if envVar != "" {
+		report.Tags["environment"] = envVar
+	}
+	for _, reporter := range ErrorReporters {
+		if err := reporter.Report(report); err != nil {
+			log.Printf("reportError: %v: %v", reporter.Name(), err)
+		}
+	}
+}
+
+func recordAPIStatusCode(code int) {
+	failures.Lock()
+	failures.lastStatusCode = code
+	failures.Unlock()
+}
+
+// Count the failed checks in a row, reporting the failure once ERROR_REPORT_THRESHOLD is reached so a single
+// transient failure doesn't get reported, and resetting the count on the first check that didn't fail
+func recordCheckOutcome(check CheckResult) {
+	threshold, err := getErrorReportThreshold()
+	if err != nil {
+		return
+	}
+
+	failures.Lock()
+	if check.Action != checkActionError {
+		failures.checks = 0
+		failures.Unlock()
+		return
+	}
+	failures.checks++
+	checks, lastStatusCode := failures.checks, failures.lastStatusCode
+	failures.Unlock()
+	if checks != threshold {
+		return
+	}
+
+	tags := map[string]string{"failed_checks": strconv.FormatUint(checks, 10)}
+	if lastStatusCode != 0 {
+		tags["last_status_code"] = strconv.Itoa(lastStatusCode)
+	}
+	if check.Transfer != nil {
+		tags["pair"] = pairKey(check.Transfer.SourceCurrency, check.Transfer.TargetCurrency)
+		tags["transfer_id"] = strconv.FormatUint(check.Transfer.Id, 10)
+	}
+	reportError(ErrorReport{Message: fmt.Sprintf("%v checks failed in a row: %v", checks, check.Error), Level: reportLevelError, Tags: tags})
+}
+
+// Report a panic of the named job with its stack trace before letting it crash the batch as it would have anyway
+func reportPanic(job string) {
+	if r := recover(); r != nil {
+		reportError(ErrorReport{
+			Message: fmt.Sprintf("panic in %v: %v", job, r),
+			Level:   reportLevelFatal,
+			Tags:    map[string]string{"job": job},
+			Stack:   string(debug.Stack()),
+		})
+		panic(r)
+	}
+}
+
+func getErrorReportThreshold() (uint64, error) {
+	threshold, err := strconv.ParseUint(errorReportThresholdVar, 10, 64)
+	if err != nil || threshold == 0 {
+		return 0, fmt.Errorf("invalid value for ERROR_REPORT_THRESHOLD: %v", errorReportThresholdVar)
+	}
+	return threshold, nil
+}
+
+// sentryReporter sends reports to the store endpoint of the project of a Sentry DSN
+type sentryReporter struct {
+	storeURL string
+	key      string
+}
+
+// Parse a DSN like https://<key>@o0.ingest.sentry.io/<projectId>
+func newSentryReporter(dsn string) (*sentryReporter, error) {
+	parsed, err := url.Parse(dsn)
+	if err != nil || parsed.User == nil || parsed.User.Username() == "" || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid value for SENTRY_DSN")
+	}
+	path := strings.Trim(parsed.Path, "/")
+	slash := strings.LastIndex(path, "/")
+	projectId := path[slash+1:]
+	if projectId == "" {
+		return nil, fmt.Errorf("invalid value for SENTRY_DSN: no project ID")
+	}
+	storeURL := url.URL{Scheme: parsed.Scheme, Host: parsed.Host, Path: "/" + strings.TrimPrefix(path[:slash+1], "/") + "api/" + projectId + "/store/"}
+	return &sentryReporter{storeURL: storeURL.String(), key: parsed.User.Username()}, nil
+}
+
+func (r *sentryReporter) Name() string {
+	return "sentry"
+}
+
+func (r *sentryReporter) Report(report ErrorReport) error {
+	payload := map[string]interface{}{
+		"event_id":  strings.ReplaceAll(uuid.New().String(), "-", ""),
+		"timestamp": report.Time.Format(time.RFC3339),
+		"level":     report.Level,
+		"platform":  "go",
+		"logger":    "transferwisely",
+		"message":   report.Message,
+		"tags":      report.Tags,
+	}
+	if report.Stack != "" {
+		payload["extra"] = map[string]string{"stack": report.Stack}
+	}
+	auth := fmt.Sprintf("Sentry sentry_version=7, sentry_client=transferwisely/1.0, sentry_key=%v", r.key)
+	return postJSONWithHeaders(r.storeURL, map[string]string{"X-Sentry-Auth": auth}, payload)
+}
+
+// rollbarReporter sends reports as Rollbar items
+type rollbarReporter struct {
+	token string
+}
+
+func (r *rollbarReporter) Name() string {
+	return "rollbar"
+}
+
+func (r *rollbarReporter) Report(report ErrorReport) error {
+	level := report.Level
+	if level == reportLevelFatal {
+		level = "critical"
+	}
+	message := map[string]string{"body": report.Message}
+	if report.Stack != "" {
+		message["stack"] = report.Stack
+	}
+	payload := map[string]interface{}{
+		"data": map[string]interface{}{
+			"environment": report.Tags["environment"],
+			"level":       level,
+			"timestamp":   report.Time.Unix(),
+			"platform":    "go",
+			"language":    "go",
+			"body":        map[string]interface{}{"message": message},
+			"custom":      report.Tags,
+		},
+	}
+	return postJSONWithHeaders(rollbarItemURL, map[string]string{"X-Rollbar-Access-Token": r.token}, payload)
+}
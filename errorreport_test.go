@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"transferwisely/mocks"
+)
+
+func TestNewSentryReporter(t *testing.T) {
+	reporter, err := newSentryReporter("https://abc123@o42.ingest.sentry.io/1234")
+	assert.NoError(t, err)
+	assert.Equal(t, &sentryReporter{storeURL: "https://o42.ingest.sentry.io/api/1234/store/", key: "abc123"}, reporter)
+
+	reporter, err = newSentryReporter("https://abc123@sentry.example.com/sentry/7")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://sentry.example.com/sentry/api/7/store/", reporter.storeURL)
+
+	for _, dsn := range []string{"https://o42.ingest.sentry.io/1234", "https://abc123@o42.ingest.sentry.io/", "::"} {
+		_, err = newSentryReporter(dsn)
+		assert.Error(t, err, dsn)
+	}
+}
+
+func TestErrorReporters(t *testing.T) {
+	var requests []*http.Request
+	var bodies []map[string]interface{}
+	mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+		var body map[string]interface{}
+		data, _ := ioutil.ReadAll(req.Body)
+		_ = json.Unmarshal(data, &body)
+		requests, bodies = append(requests, req), append(bodies, body)
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(nil)}, nil
+	}
+	defer func(r []ErrorReporter) { ErrorReporters = r }(ErrorReporters)
+	ErrorReporters = []ErrorReporter{
+		&sentryReporter{storeURL: "https://sentry.example.com/api/1/store/", key: "key"},
+		&rollbarReporter{token: "token"},
+	}
+
+	reportError(ErrorReport{Message: "boom", Level: reportLevelFatal, Tags: map[string]string{"pair": "GBP-INR"}, Stack: "main.go:1"})
+
+	assert.Len(t, requests, 2)
+	assert.Equal(t, "https://sentry.example.com/api/1/store/", requests[0].URL.String())
+	assert.Contains(t, requests[0].Header.Get("X-Sentry-Auth"), "sentry_key=key")
+	assert.Equal(t, "boom", bodies[0]["message"])
+	assert.Equal(t, reportLevelFatal, bodies[0]["level"])
+	assert.Equal(t, "GBP-INR", bodies[0]["tags"].(map[string]interface{})["pair"])
+	assert.Len(t, bodies[0]["event_id"], 32)
+
+	assert.Equal(t, rollbarItemURL, requests[1].URL.String())
+	assert.Equal(t, "token", requests[1].Header.Get("X-Rollbar-Access-Token"))
+	data := bodies[1]["data"].(map[string]interface{})
+	assert.Equal(t, "critical", data["level"])
+	assert.Equal(t, "boom", data["body"].(map[string]interface{})["message"].(map[string]interface{})["body"])
+}
+
+// fakeReporter records the reports it gets
+type fakeReporter struct {
+	reports []ErrorReport
+}
+
+func (r *fakeReporter) Name() string {
+	return "fake"
+}
+
+func (r *fakeReporter) Report(report ErrorReport) error {
+	r.reports = append(r.reports, report)
+	return nil
+}
+
+func TestRecordCheckOutcome(t *testing.T) {
+	defer func(r []ErrorReporter, threshold string) {
+		ErrorReporters, errorReportThresholdVar = r, threshold
+	}(ErrorReporters, errorReportThresholdVar)
+	fake := &fakeReporter{}
+	ErrorReporters, errorReportThresholdVar = []ErrorReporter{fake}, "2"
+	failures.checks = 0
+
+	transfer := Transfer{Id: 7, SourceCurrency: "GBP", TargetCurrency: "INR"}
+	failed := CheckResult{Action: checkActionError, Transfer: &transfer, Error: "transferwise api error"}
+	recordAPIStatusCode(http.StatusBadGateway)
+
+	recordCheckOutcome(failed)
+	assert.Empty(t, fake.reports, "a single failure isn't reported")
+	recordCheckOutcome(failed)
+	assert.Len(t, fake.reports, 1)
+	assert.Equal(t, map[string]string{"failed_checks": "2", "last_status_code": "502", "pair": "GBP-INR", "transfer_id": "7"},
+		withoutEnvironment(fake.reports[0].Tags))
+	recordCheckOutcome(failed)
+	assert.Len(t, fake.reports, 1, "reported once per streak")
+
+	recordCheckOutcome(CheckResult{Action: checkActionNoAction})
+	recordCheckOutcome(failed)
+	recordCheckOutcome(failed)
+	assert.Len(t, fake.reports, 2)
+}
+
+func TestReportPanic(t *testing.T) {
+	defer func(r []ErrorReporter) { ErrorReporters = r }(ErrorReporters)
+	fake := &fakeReporter{}
+	ErrorReporters = []ErrorReporter{fake}
+
+	assert.PanicsWithValue(t, "boom", func() {
+		defer reportPanic("job")
+		panic("boom")
+	})
+	assert.Len(t, fake.reports, 1)
+	assert.Equal(t, "panic in job: boom", fake.reports[0].Message)
+	assert.Equal(t, reportLevelFatal, fake.reports[0].Level)
+	assert.NotEmpty(t, fake.reports[0].Stack)
+}
+
+func withoutEnvironment(tags map[string]string) map[string]string {
+	delete(tags, "environment")
+	return tags
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"transferwisely/nats"
+)
+
+// version of the BusMessage schema, bumped on breaking changes only
+const eventBusSchemaVersion = 1
+
+// types of the messages published to the event bus, each to its own <EVENT_TOPIC_PREFIX>.<type> topic
+const (
+	busRate     = streamRate
+	busDecision = streamDecision
+	busEvent    = "event"
+)
+
+// messages queued for the event bus, the ones it can't keep up with being dropped
+const eventBusBuffer = 256
+
+// content type of the Kafka REST Proxy v2 JSON records
+const kafkaRESTContentType = "application/vnd.kafka.json.v2+json"
+
+// BusMessage is the envelope of everything published to Kafka or NATS. Data is a RateUpdate for rate messages, a
+// Decision for decision ones and an Event for event ones
+type BusMessage struct {
+	SchemaVersion int         `json:"schemaVersion"`
+	Id            string      `json:"id"`
+	Type          string      `json:"type"`
+	Source        string      `json:"source"`
+	Time          time.Time   `json:"time"`
+	Account       string      `json:"account,omitempty"`
+	Pair          string      `json:"pair,omitempty"`
+	Data          interface{} `json:"data"`
+}
+
+// EventPublisher delivers the bus messages to a broker
+type EventPublisher interface {
+	Name() string
+	Publish(topic string, key string, payload []byte) error
+}
+
+// queue of the event bus worker, nil until startEventBus
+var eventBus = struct {
+	sync.Mutex
+	queue chan BusMessage
+}{}
+
+func getEventPublishers() ([]EventPublisher, error) {
+	var publishers []EventPublisher
+	if natsURLVar != "" {
+		if err := nats.ValidateServer(natsURLVar); err != nil {
+			return nil, fmt.Errorf("invalid value for NATS_URL: %v", err)
+		}
+		publishers = append(publishers, &natsPublisher{server: natsURLVar})
+	}
+	if kafkaRESTURLVar != "" {
+		u, err := url.Parse(kafkaRESTURLVar)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid value for KAFKA_REST_URL: %v, expected http(s)://host:port", kafkaRESTURLVar)
+		}
+		publishers = append(publishers, kafkaRESTPublisher{baseURL: strings.TrimSuffix(kafkaRESTURLVar, "/")})
+	}
+	return publishers, nil
+}
+
+// Start publishing the rate updates, decisions and events to NATS_URL and KAFKA_REST_URL in the background, if set
+func startEventBus() error {
+	publishers, err := getEventPublishers()
+	if err != nil || len(publishers) == 0 {
+		return err
+	}
+	queue := make(chan BusMessage, eventBusBuffer)
+	eventBus.Lock()
+	eventBus.queue = queue
+	eventBus.Unlock()
+
+	names := make([]string, len(publishers))
+	for i, publisher := range publishers {
+		names[i] = publisher.Name()
+	}
+	log.Printf("|| EVENT BUS || Publishing to %v under %v.* ||", strings.Join(names, ", "), eventTopicPrefixVar)
+	go func() {
+		for message := range queue {
+			deliverBusMessage(publishers, message)
+		}
+	}()
+	return nil
+}
+
+// Queue v for the event bus, never blocking the check on a slow broker
+func publishEventBus(kind string, pair string, v interface{}) {
+	eventBus.Lock()
+	queue := eventBus.queue
+	eventBus.Unlock()
+	if queue == nil {
+		return
+	}
+	message := BusMessage{
+		SchemaVersion: eventBusSchemaVersion,
+		Id:            uuid.New().String(),
+		Type:          kind,
+		Source:        "transferwisely",
+		Time:          time.Now().UTC(),
+		Account:       getCurrentAccount(),
+		Pair:          pair,
+		Data:          v,
+	}
+	select {
+	case queue <- message:
+	default:
+		log.Printf("publishEventBus: queue full, dropping %v message", kind)
+	}
+}
+
+func deliverBusMessage(publishers []EventPublisher, message BusMessage) {
+	payload, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("deliverBusMessage: %v", err)
+		return
+	}
+	topic := eventTopicPrefixVar + "." + message.Type
+	for _, publisher := range publishers {
+		if err := publisher.Publish(topic, message.Pair, payload); err != nil {
+			log.Printf("deliverBusMessage: %v: %v", publisher.Name(), err)
+		}
+	}
+}
+
+// natsPublisher keeps a connection to the NATS server, connecting again after a failure
+type natsPublisher struct {
+	server string
+	client *nats.Client
+}
+
+func (p *natsPublisher) Name() string {
+	return "nats"
+}
+
+func (p *natsPublisher) Publish(topic string, key string, payload []byte) error {
+	message := nats.Message{Subject: topic, Payload: payload}
+	if p.client != nil {
+		if err := p.client.Publish(message); err == nil {
+			return nil
+		}
+		_ = p.client.Close()
+		p.client = nil
+	}
+	client, err := nats.Connect(p.server, nats.Options{Name: "transferwisely"})
+	if err != nil {
+		return err
+	}
+	p.client = client
+	return client.Publish(message)
+}
+
+// kafkaRESTPublisher produces the records through a Kafka REST Proxy, see
+// https://docs.confluent.io/platform/current/kafka-rest/api.html#post--topics-(string-topic_name), keyed by pair so
+// the messages of a pair keep their order
+type kafkaRESTPublisher struct {
+	baseURL string
+}
+
+func (kafkaRESTPublisher) Name() string {
+	return "kafka"
+}
+
+func (p kafkaRESTPublisher) Publish(topic string, key string, payload []byte) error {
+	record := map[string]interface{}{"value": json.RawMessage(payload)}
+	if key != "" {
+		record["key"] = key
+	}
+	body, _ := json.Marshal(map[string]interface{}{"records": []interface{}{record}})
+	req, err := http.NewRequest(http.MethodPost, p.baseURL+"/topics/"+url.PathEscape(topic), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating kafka rest request: %v", err)
+	}
+	req.Header.Set("Content-Type", kafkaRESTContentType)
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+	res, err := Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error calling kafka rest proxy: %v", err)
+	}
+	data, _ := ioutil.ReadAll(res.Body)
+	_ = res.Body.Close()
+	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("kafka rest proxy error: %v %v", res.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"transferwisely/mocks"
+)
+
+func TestPublishEventBus(t *testing.T) {
+	publishEventBus(busEvent, "GBP-INR", Event{Kind: EventRebooked})
+
+	queue := make(chan BusMessage, 1)
+	eventBus.queue = queue
+	defer func() { eventBus.queue = nil }()
+	publishEventBus(busEvent, "GBP-INR", Event{Kind: EventRebooked, Subject: "Rebooked"})
+	publishEventBus(busEvent, "GBP-INR", Event{Kind: EventRebooked})
+
+	assert.Len(t, queue, 1, "dropped once the queue is full")
+	message := <-queue
+	assert.Equal(t, eventBusSchemaVersion, message.SchemaVersion)
+	assert.Equal(t, busEvent, message.Type)
+	assert.Equal(t, "GBP-INR", message.Pair)
+	assert.Equal(t, defaultAccount, message.Account)
+	assert.NotEmpty(t, message.Id)
+	assert.Equal(t, "Rebooked", message.Data.(Event).Subject)
+}
+
+func TestKafkaRESTPublisher(t *testing.T) {
+	defer func(kafka, nats, prefix string) {
+		kafkaRESTURLVar, natsURLVar, eventTopicPrefixVar = kafka, nats, prefix
+	}(kafkaRESTURLVar, natsURLVar, eventTopicPrefixVar)
+	kafkaRESTURLVar, natsURLVar, eventTopicPrefixVar = "http://kafka-rest:8082/", "", "tw"
+
+	var requests []*http.Request
+	var bodies []string
+	status := http.StatusOK
+	mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+		requests = append(requests, req)
+		data, _ := ioutil.ReadAll(req.Body)
+		bodies = append(bodies, string(data))
+		return &http.Response{StatusCode: status, Body: ioutil.NopCloser(strings.NewReader(`{"error_code": 40401}`))}, nil
+	}
+
+	publishers, err := getEventPublishers()
+	assert.NoError(t, err)
+	assert.Len(t, publishers, 1)
+	message := BusMessage{SchemaVersion: eventBusSchemaVersion, Id: "1", Type: busRate, Pair: "GBP-INR",
+		Data: RateUpdate{TransferId: 7, LiveRate: 105.2}}
+	deliverBusMessage(publishers, message)
+
+	if assert.Len(t, requests, 1) {
+		assert.Equal(t, "http://kafka-rest:8082/topics/tw.rate", requests[0].URL.String())
+		assert.Equal(t, kafkaRESTContentType, requests[0].Header.Get("Content-Type"))
+		var body struct {
+			Records []struct {
+				Key   string     `json:"key"`
+				Value BusMessage `json:"value"`
+			} `json:"records"`
+		}
+		assert.NoError(t, json.Unmarshal([]byte(bodies[0]), &body))
+		assert.Equal(t, "GBP-INR", body.Records[0].Key)
+		assert.Equal(t, busRate, body.Records[0].Value.Type)
+		assert.Equal(t, 105.2, body.Records[0].Value.Data.(map[string]interface{})["liveRate"])
+	}
+
+	status = http.StatusNotFound
+	err = publishers[0].Publish("tw.rate", "", []byte(`{}`))
+	assert.EqualError(t, err, `kafka rest proxy error: 404 {"error_code": 40401}`)
+
+	t.Run("invalid config", func(t *testing.T) {
+		kafkaRESTURLVar = "kafka:9092"
+		_, err := getEventPublishers()
+		assert.Error(t, err)
+		kafkaRESTURLVar, natsURLVar = "", "http://nats:4222"
+		_, err = getEventPublishers()
+		assert.EqualError(t, err, "invalid value for NATS_URL: invalid server scheme http, expected nats or tls")
+	})
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// imminent expiry notification
+const (
+	expiryImminentSubject = "Rate lock expires in %v and no action was taken"
+	expiryImminentText    = "Transfer ID: %v\n{%v} --> {%v}\nBooked Rate: %v\nAmount: %v %v\nRate lock expires: %v"
+)
+
+// transfers whose imminent expiry was already notified
+var expiryAlerts = struct {
+	sync.Mutex
+	alerted map[uint64]bool
+}{alerted: map[uint64]bool{}}
+
+// Notify once per transfer when its rate lock expires within EXPIRY_ALERT while nothing was re-booked
+func alertImminentExpiry(transfer Transfer, now time.Time) {
+	alertBefore, err := getExpiryAlert()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if alertBefore == 0 || transfer.RateExpirationTime == "" {
+		return
+	}
+
+	expiryTime, err := time.Parse(time.RFC3339, transfer.RateExpirationTime)
+	if err != nil {
+		log.Printf("alertImminentExpiry: %v", err)
+		return
+	}
+	left := expiryTime.Sub(now)
+	if left > alertBefore || left <= 0 {
+		return
+	}
+
+	expiryAlerts.Lock()
+	alerted := expiryAlerts.alerted[transfer.Id]
+	expiryAlerts.alerted[transfer.Id] = true
+	expiryAlerts.Unlock()
+	if alerted {
+		return
+	}
+
+	log.Printf("|| RATE LOCK EXPIRING, NO ACTION TAKEN || Transfer ID: %v | {%v} --> {%v} | Booked Rate: %v | Expires: %v ||",
+		transfer.Id, transfer.SourceCurrency, transfer.TargetCurrency, transfer.Rate, transfer.RateExpirationTime)
+	notify(Event{
+		Kind:    EventExpiryImminent,
+		Subject: fmt.Sprintf(expiryImminentSubject, left.Round(time.Minute)),
+		Text: fmt.Sprintf(expiryImminentText, transfer.Id, transfer.SourceCurrency, transfer.TargetCurrency,
+			formatRate(transfer.Rate), transfer.SourceCurrency, formatAmount(transfer.SourceAmount, transfer.SourceCurrency), formatExpiry(transfer.RateExpirationTime)),
+		Pair:       pairKey(transfer.SourceCurrency, transfer.TargetCurrency),
+		TransferId: transfer.Id,
+	})
+}
+
+func getExpiryAlert() (time.Duration, error) {
+	minutes, err := strconv.ParseUint(expiryAlertVar, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value for EXPIRY_ALERT: %v", err)
+	}
+	return time.Duration(minutes) * time.Minute, nil
+}
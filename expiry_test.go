@@ -0,0 +1,33 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestAlertImminentExpiry(t *testing.T) {
+	defer func(v string) { expiryAlertVar = v }(expiryAlertVar)
+	fake := &fakeNotifier{}
+	defer func(n []Notifier) { Notifiers = n }(Notifiers)
+	Notifiers = []Notifier{fake}
+
+	expiryAlertVar = "120"
+	now := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+	transfer := Transfer{Id: 42, RateExpirationTime: "2020-05-01T15:00:00Z"}
+
+	alertImminentExpiry(transfer, now)
+	assert.Empty(t, fake.events, "expires in 3 hours")
+
+	alertImminentExpiry(transfer, now.Add(90*time.Minute))
+	assert.Len(t, fake.events, 1)
+	assert.Equal(t, EventExpiryImminent, fake.events[0].Kind)
+	assert.Equal(t, "Rate lock expires in 1h30m0s and no action was taken", fake.events[0].Subject)
+
+	alertImminentExpiry(transfer, now.Add(2*time.Hour))
+	assert.Len(t, fake.events, 1, "only alerted once")
+
+	expiryAlertVar = "0"
+	alertImminentExpiry(Transfer{Id: 43, RateExpirationTime: "2020-05-01T12:30:00Z"}, now)
+	assert.Len(t, fake.events, 1, "disabled")
+}
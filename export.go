@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+// what the export command can dump
+const (
+	exportRates     = "rates"
+	exportDecisions = "decisions"
+	exportRebooks   = "rebooks"
+)
+
+// Write the live rates seen by past checks as CSV, one row per check
+func writeRatesCSV(w io.Writer, decisions []Decision) error {
+	rows := [][]string{{"time", "sourceCurrency", "targetCurrency", "liveRate", "bookedRate", "transferId"}}
+	for _, decision := range decisions {
+		rows = append(rows, []string{decision.Time.Format(time.RFC3339), decision.SourceCurrency, decision.TargetCurrency,
+			formatFloat(decision.LiveRate), formatFloat(decision.BookedRate), formatUint(decision.TransferId)})
+	}
+	return writeCSV(w, rows)
+}
+
+// Write the outcome of past checks as CSV
+func writeDecisionsCSV(w io.Writer, decisions []Decision) error {
+	rows := [][]string{{"time", "transferId", "sourceCurrency", "targetCurrency", "bookedRate", "liveRate", "action", "reason",
+		"newTransferId", "error"}}
+	for _, decision := range decisions {
+		newTransferId := ""
+		if decision.NewTransferId != 0 {
+			newTransferId = formatUint(decision.NewTransferId)
+		}
+		rows = append(rows, []string{decision.Time.Format(time.RFC3339), formatUint(decision.TransferId), decision.SourceCurrency,
+			decision.TargetCurrency, formatFloat(decision.BookedRate), formatFloat(decision.LiveRate), decision.Action,
+			decision.Reason, newTransferId, decision.Error})
+	}
+	return writeCSV(w, rows)
+}
+
+// Write past re-bookings as CSV
+func writeRebooksCSV(w io.Writer, records []RebookRecord) error {
+	rows := [][]string{{"time", "oldTransferId", "newTransferId", "sourceCurrency", "targetCurrency", "oldRate", "newRate",
+		"sourceAmount", "reason"}}
+	for _, record := range records {
+		rows = append(rows, []string{record.Time.Format(time.RFC3339), formatUint(record.OldTransferId), formatUint(record.NewTransferId),
+			record.SourceCurrency, record.TargetCurrency, formatFloat(record.OldRate), formatFloat(record.NewRate),
+			record.SourceAmount.String(), record.Reason})
+	}
+	return writeCSV(w, rows)
+}
+
+func writeCSV(w io.Writer, rows [][]string) error {
+	writer := csv.NewWriter(w)
+	if err := writer.WriteAll(rows); err != nil {
+		return fmt.Errorf("error writing csv: %v", err)
+	}
+	return nil
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func formatUint(u uint64) string {
+	return strconv.FormatUint(u, 10)
+}
+
+// Keep the decisions within [from, to), zero times leaving that end open
+func filterDecisions(decisions []Decision, from time.Time, to time.Time) (filtered []Decision) {
+	for _, decision := range decisions {
+		if (!from.IsZero() && decision.Time.Before(from)) || (!to.IsZero() && !decision.Time.Before(to)) {
+			continue
+		}
+		filtered = append(filtered, decision)
+	}
+	return filtered
+}
+
+func runExportCommand(args []string) error {
+	usage := fmt.Errorf("usage: export rates|decisions|rebooks [--from <yyyy-mm-dd>] [--to <yyyy-mm-dd>] [--out <file>]")
+	if len(args) == 0 {
+		return usage
+	}
+	flags := flag.NewFlagSet("export "+args[0], flag.ContinueOnError)
+	fromFlag := flags.String("from", "", "first day to export, like 2023-01-01")
+	toFlag := flags.String("to", "", "last day to export")
+	out := flags.String("out", "", "CSV file to write, defaults to stdout")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	var from, to time.Time
+	var err error
+	if *fromFlag != "" {
+		if from, err = time.Parse(backtestDateLayout, *fromFlag); err != nil {
+			return fmt.Errorf("invalid --from: %v", err)
+		}
+	}
+	if *toFlag != "" {
+		if to, err = time.Parse(backtestDateLayout, *toFlag); err != nil {
+			return fmt.Errorf("invalid --to: %v", err)
+		}
+		to = to.Add(24 * time.Hour)
+	}
+
+	state, err := loadState()
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		file, err := os.Create(*out)
+		if err != nil {
+			return fmt.Errorf("error creating %v: %v", *out, err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	switch args[0] {
+	case exportRates:
+		return writeRatesCSV(w, filterDecisions(state.Decisions, from, to))
+	case exportDecisions:
+		return writeDecisionsCSV(w, filterDecisions(state.Decisions, from, to))
+	case exportRebooks:
+		var records []RebookRecord
+		for _, record := range state.RebookHistory {
+			if (from.IsZero() || !record.Time.Before(from)) && (to.IsZero() || record.Time.Before(to)) {
+				records = append(records, record)
+			}
+		}
+		return writeRebooksCSV(w, records)
+	default:
+		return usage
+	}
+}
+
+func init() {
+	registerCommand("export", Command{
+		Usage: "export rates|decisions|rebooks ...           dump the collected rate and check history as CSV",
+		Run:   runExportCommand,
+	})
+}
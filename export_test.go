@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecordDecision(t *testing.T) {
+	defer func(v string) { stateFileVar = v }(stateFileVar)
+	dir, _ := ioutil.TempDir("", "transferwisely")
+	defer os.RemoveAll(dir)
+	stateFileVar = filepath.Join(dir, "state.json")
+	now := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	transfer := Transfer{Id: 1, SourceCurrency: "GBP", TargetCurrency: "INR", Rate: 100}
+
+	assert.NoError(t, recordDecision(CheckResult{Action: checkActionNotDue, Transfer: &transfer}, now))
+	assert.NoError(t, recordDecision(CheckResult{Action: checkActionError, Error: "boom"}, now))
+	assert.NoError(t, recordDecision(CheckResult{Action: checkActionRebooked, Transfer: &transfer, LiveRate: 101,
+		Reason: rebookReasonBetterRate, NewTransfer: &Transfer{Id: 2}}, now))
+
+	state, err := loadState()
+	assert.NoError(t, err)
+	assert.Equal(t, []Decision{{Time: now, TransferId: 1, SourceCurrency: "GBP", TargetCurrency: "INR", BookedRate: 100,
+		LiveRate: 101, Action: checkActionRebooked, Reason: rebookReasonBetterRate, NewTransferId: 2}}, state.Decisions)
+}
+
+func TestExportCSV(t *testing.T) {
+	now := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	decisions := []Decision{
+		{Time: now, TransferId: 1, SourceCurrency: "GBP", TargetCurrency: "INR", BookedRate: 100, LiveRate: 100.25, Action: checkActionNoAction},
+		{Time: now.Add(time.Minute), TransferId: 1, SourceCurrency: "GBP", TargetCurrency: "INR", BookedRate: 100, LiveRate: 101,
+			Action: checkActionRebooked, Reason: rebookReasonBetterRate, NewTransferId: 2},
+	}
+
+	t.Run("rates", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.NoError(t, writeRatesCSV(&buf, decisions))
+		assert.Equal(t, "time,sourceCurrency,targetCurrency,liveRate,bookedRate,transferId\n"+
+			"2023-05-01T10:00:00Z,GBP,INR,100.25,100,1\n"+
+			"2023-05-01T10:01:00Z,GBP,INR,101,100,1\n", buf.String())
+	})
+
+	t.Run("decisions", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.NoError(t, writeDecisionsCSV(&buf, decisions))
+		assert.Equal(t, "time,transferId,sourceCurrency,targetCurrency,bookedRate,liveRate,action,reason,newTransferId,error\n"+
+			"2023-05-01T10:00:00Z,1,GBP,INR,100,100.25,no-action,,,\n"+
+			"2023-05-01T10:01:00Z,1,GBP,INR,100,101,rebooked,better rate,2,\n", buf.String())
+	})
+
+	t.Run("rebooks", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.NoError(t, writeRebooksCSV(&buf, []RebookRecord{{Time: now, OldTransferId: 1, NewTransferId: 2, SourceCurrency: "GBP",
+			TargetCurrency: "INR", OldRate: 100, NewRate: 101, SourceAmount: decimalOf(1000), Reason: rebookReasonBetterRate}}))
+		assert.Equal(t, "time,oldTransferId,newTransferId,sourceCurrency,targetCurrency,oldRate,newRate,sourceAmount,reason\n"+
+			"2023-05-01T10:00:00Z,1,2,GBP,INR,100,101,1000,better rate\n", buf.String())
+	})
+
+	t.Run("filter", func(t *testing.T) {
+		assert.Equal(t, decisions[1:], filterDecisions(decisions, now.Add(time.Second), time.Time{}))
+		assert.Equal(t, decisions[:1], filterDecisions(decisions, time.Time{}, now.Add(time.Minute)))
+	})
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FeeCap is the highest fee a re-booking may pay, in the source currency or in percent of the source amount, 0 meaning
+// no cap
+type FeeCap struct {
+	Value   Decimal
+	Percent bool
+}
+
+// Parse a fee cap like 10, in the source currency, or 0.5%, of the source amount, an empty one meaning no cap
+func parseFeeCap(s string) (FeeCap, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return FeeCap{}, nil
+	}
+	feeCap := FeeCap{Percent: strings.HasSuffix(s, "%")}
+	value, err := parseDecimal(strings.TrimSuffix(s, "%"))
+	if err != nil || value.Sign() <= 0 {
+		return FeeCap{}, fmt.Errorf("invalid fee cap %q, expected an amount like 10 or a percentage like 0.5%%", s)
+	}
+	feeCap.Value = value
+	return feeCap, nil
+}
+
+// The highest fee allowed on the source amount, 0 for no cap
+func (c FeeCap) limit(sourceAmount Decimal) Decimal {
+	if c.Percent {
+		return sourceAmount.Mul(c.Value).Quo(decimalOf(100))
+	}
+	return c.Value
+}
+
+func (c FeeCap) String() string {
+	if c.Percent {
+		return c.Value.String() + "%"
+	}
+	return c.Value.String()
+}
+
+// Refuse the quote when the fee of its payment option is beyond the MAX_FEE cap, however good its rate, as Wise may
+// price the payment options differently from one quote to the next
+func checkQuoteFee(quote QuoteDetail, settings Settings) error {
+	if settings.MaxFee.Value.Sign() <= 0 {
+		return nil
+	}
+	limit := settings.MaxFee.limit(quote.SourceAmount)
+	if quote.Fee.Cmp(limit) > 0 {
+		return fmt.Errorf("%w: quote %v charges %v %v, more than the %v fee cap of %v %v", ErrFeeTooHigh, quote.Id,
+			formatAmount(quote.Fee, quote.SourceCurrency), quote.SourceCurrency, settings.MaxFee,
+			formatAmount(limit, quote.SourceCurrency), quote.SourceCurrency)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"transferwisely/mocks"
+)
+
+func TestParseFeeCap(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected FeeCap
+		err      bool
+	}{
+		{"", FeeCap{}, false},
+		{"10", FeeCap{Value: decimalOf(10)}, false},
+		{"0.5%", FeeCap{Value: decimalOf(0.5), Percent: true}, false},
+		{" 1.5 % ", FeeCap{Value: decimalOf(1.5), Percent: true}, false},
+		{"0", FeeCap{}, true},
+		{"-1%", FeeCap{}, true},
+		{"ten", FeeCap{}, true},
+	}
+	for _, test := range tests {
+		feeCap, err := parseFeeCap(test.value)
+		assert.Equal(t, test.err, err != nil, test.value)
+		assert.Equal(t, test.expected, feeCap, test.value)
+	}
+	assert.Equal(t, "0.5%", FeeCap{Value: decimalOf(0.5), Percent: true}.String())
+	assert.Equal(t, decimalOf(5.0), FeeCap{Value: decimalOf(0.5), Percent: true}.limit(decimalOf(1000)))
+	assert.Equal(t, decimalOf(10.0), FeeCap{Value: decimalOf(10)}.limit(decimalOf(1000)))
+}
+
+func TestCheckQuoteFee(t *testing.T) {
+	quote := QuoteDetail{Id: "quote-1", SourceAmount: decimalOf(1000), SourceCurrency: "GBP", Fee: decimalOf(6.2)}
+
+	assert.NoError(t, checkQuoteFee(quote, Settings{}), "no cap")
+	assert.NoError(t, checkQuoteFee(quote, Settings{MaxFee: FeeCap{Value: decimalOf(10)}}))
+	err := checkQuoteFee(quote, Settings{MaxFee: FeeCap{Value: decimalOf(0.5), Percent: true}})
+	assert.True(t, errors.Is(err, ErrFeeTooHigh))
+	assert.EqualError(t, err, "fee too high: quote quote-1 charges 6.20 GBP, more than the 0.5% fee cap of 5.00 GBP")
+
+	atCap := QuoteDetail{Id: "quote-2", SourceAmount: decimalOf(10), SourceCurrency: "GBP", Fee: decimalOf(0.029)}
+	assert.NoError(t, checkQuoteFee(atCap, Settings{MaxFee: FeeCap{Value: decimalOf(0.29), Percent: true}}),
+		"a fee right at the cap is allowed, which float64 math says is 0.028999999999999998")
+}
+
+func TestRebookOverFeeCap(t *testing.T) {
+	defer func(file string, c Config, maxFee string) {
+		configFileVar, config.current, maxFeeVar = file, c, maxFee
+	}(configFileVar, getConfig(), maxFeeVar)
+	maxFeeVar = "5"
+	dir, _ := ioutil.TempDir("", "transferwisely")
+	defer os.RemoveAll(dir)
+	configFileVar = filepath.Join(dir, "config.json")
+	_ = ioutil.WriteFile(configFileVar, []byte(`{"pairs": {"EUR-USD": {"maxFee": ""}}}`), 0600)
+	assert.NoError(t, loadConfig())
+
+	mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+		body := `{}`
+		if req.Method == http.MethodPost && strings.HasSuffix(req.URL.String(), quotesAPIPath) {
+			body = `{"id": "quote-1", "rate": 101, "sourceAmount": 1000, "sourceCurrency": "GBP", "profile": 1, "paymentOptions": [
+				{"payIn": "BANK_TRANSFER", "payOut": "BANK_TRANSFER", "sourceAmount": 1000, "fee": {"total": 7.5}}]}`
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(body))}, nil
+	}
+	booked := Transfer{Id: 7, Profile: 1, Rate: 100, SourceAmount: decimalOf(1000), SourceCurrency: "GBP", TargetCurrency: "INR"}
+	settings, err := getSettings(booked)
+	assert.NoError(t, err)
+
+	_, err = createTransfer(booked, settings, rebookReasonBetterRate)
+	assert.True(t, errors.Is(err, ErrFeeTooHigh), "7.50 GBP fee over the 5 GBP cap despite the better rate")
+
+	settings, err = getSettings(Transfer{SourceCurrency: "EUR", TargetCurrency: "USD"})
+	assert.NoError(t, err)
+	assert.Equal(t, FeeCap{}, settings.MaxFee, "cap lifted for the pair")
+
+	t.Run("invalid max fee", func(t *testing.T) {
+		_ = ioutil.WriteFile(configFileVar, []byte(`{"pairs": {"GBP-INR": {"maxFee": "1%%"}}}`), 0600)
+		assert.Error(t, loadConfig())
+	})
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// atom feed related constants
+const (
+	feedPath       = "/feed.atom"
+	feedTitle      = "transferwisely"
+	feedMaxEntries = 100
+	feedDateLayout = "2006-01-02"
+)
+
+// AtomFeed is an Atom 1.0 feed, see RFC 4287
+type AtomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []AtomLink  `xml:"link,omitempty"`
+	Author  AtomAuthor  `xml:"author"`
+	Entries []AtomEntry `xml:"entry"`
+}
+
+type AtomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type AtomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type AtomEntry struct {
+	ID       string       `xml:"id"`
+	Title    string       `xml:"title"`
+	Updated  string       `xml:"updated"`
+	Category AtomCategory `xml:"category"`
+	Content  AtomContent  `xml:"content"`
+}
+
+type AtomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type AtomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// Serve the feed of the recent re-bookings, the decisions other than no action, and the daily rate summary of each
+// pair, to follow the batch from any feed reader
+func feedHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	state, err := loadState()
+	if err != nil {
+		log.Printf("feedHandler: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	feed := buildFeed(state, time.Now().UTC())
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	_, _ = w.Write([]byte(xml.Header))
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(feed); err != nil {
+		log.Printf("feedHandler: %v", err)
+	}
+}
+
+type feedItem struct {
+	time  time.Time
+	entry AtomEntry
+}
+
+func buildFeed(state State, now time.Time) AtomFeed {
+	var items []feedItem
+	for _, rebook := range state.RebookHistory {
+		items = append(items, feedItem{rebook.Time, AtomEntry{
+			ID: fmt.Sprintf("urn:transferwisely:rebook:%v", rebook.NewTransferId),
+			Title: fmt.Sprintf("Transfer %v re-booked at %v: {%v} --> {%v}", rebook.OldTransferId, rebook.NewRate,
+				rebook.SourceCurrency, rebook.TargetCurrency),
+			Category: AtomCategory{Term: checkActionRebooked},
+			Content: AtomContent{Type: "text", Body: fmt.Sprintf("New transfer ID: %v\nRate: %v (was %v)\nAmount: %v %v\nReason: %v",
+				rebook.NewTransferId, rebook.NewRate, rebook.OldRate, formatAmount(rebook.SourceAmount, rebook.SourceCurrency),
+				rebook.SourceCurrency, rebook.Reason)},
+		}})
+	}
+
+	type daySummary struct {
+		source, target string
+		day            string
+		min, max, last float64
+		checks         int
+		updated        time.Time
+	}
+	days := map[string]*daySummary{}
+	for _, decision := range state.Decisions {
+		pair := pairKey(decision.SourceCurrency, decision.TargetCurrency)
+		day := decision.Time.UTC().Format(feedDateLayout)
+		summary, ok := days[pair+" "+day]
+		if !ok {
+			summary = &daySummary{source: decision.SourceCurrency, target: decision.TargetCurrency, day: day,
+				min: decision.LiveRate, max: decision.LiveRate}
+			days[pair+" "+day] = summary
+		}
+		if decision.LiveRate < summary.min {
+			summary.min = decision.LiveRate
+		}
+		if decision.LiveRate > summary.max {
+			summary.max = decision.LiveRate
+		}
+		summary.last, summary.updated = decision.LiveRate, decision.Time
+		summary.checks++
+
+		// no action decisions, most of them, are summed up by day, and re-bookings have entries of their own
+		if decision.Action == checkActionNoAction || decision.Action == checkActionRebooked {
+			continue
+		}
+		body := fmt.Sprintf("Transfer ID: %v\nBooked rate: %v\nLive rate: %v", decision.TransferId, decision.BookedRate,
+			decision.LiveRate)
+		for _, detail := range []struct{ label, value string }{{"Reason", decision.Reason}, {"Error", decision.Error}} {
+			if detail.value != "" {
+				body += fmt.Sprintf("\n%v: %v", detail.label, detail.value)
+			}
+		}
+		items = append(items, feedItem{decision.Time, AtomEntry{
+			ID: fmt.Sprintf("urn:transferwisely:decision:%v:%v", decision.TransferId, decision.Time.UnixNano()),
+			Title: fmt.Sprintf("Transfer %v %v at %v: {%v} --> {%v}", decision.TransferId, decision.Action, decision.LiveRate,
+				decision.SourceCurrency, decision.TargetCurrency),
+			Category: AtomCategory{Term: decision.Action},
+			Content:  AtomContent{Type: "text", Body: body},
+		}})
+	}
+	keys := make([]string, 0, len(days))
+	for key := range days {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		summary := days[key]
+		items = append(items, feedItem{summary.updated, AtomEntry{
+			ID: fmt.Sprintf("urn:transferwisely:rates:%v:%v", pairKey(summary.source, summary.target), summary.day),
+			Title: fmt.Sprintf("{%v} --> {%v} rates on %v: %v to %v", summary.source, summary.target, summary.day,
+				summary.min, summary.max),
+			Category: AtomCategory{Term: "rates"},
+			Content: AtomContent{Type: "text", Body: fmt.Sprintf("Min: %v | Max: %v | Last: %v | Checks: %v", summary.min,
+				summary.max, summary.last, summary.checks)},
+		}})
+	}
+
+	sort.SliceStable(items, func(i, j int) bool { return items[i].time.After(items[j].time) })
+	if len(items) > feedMaxEntries {
+		items = items[:feedMaxEntries]
+	}
+	feed := AtomFeed{ID: "urn:transferwisely:feed", Title: feedTitle, Updated: now.Format(time.RFC3339),
+		Author: AtomAuthor{Name: feedTitle}, Entries: []AtomEntry{}}
+	if len(items) > 0 {
+		feed.Updated = items[0].time.UTC().Format(time.RFC3339)
+	}
+	if publicURLVar != "" {
+		base := strings.TrimSuffix(publicURLVar, "/")
+		feed.Links = []AtomLink{{Href: base + feedPath, Rel: "self"}, {Href: base + "/"}}
+	}
+	for _, item := range items {
+		item.entry.Updated = item.time.UTC().Format(time.RFC3339)
+		feed.Entries = append(feed.Entries, item.entry)
+	}
+	return feed
+}
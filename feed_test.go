@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/xml"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFeedHandler(t *testing.T) {
+	defer func(file string, url string) { stateFileVar, publicURLVar = file, url }(stateFileVar, publicURLVar)
+	dir, _ := ioutil.TempDir("", "transferwisely")
+	defer os.RemoveAll(dir)
+	stateFileVar, publicURLVar = filepath.Join(dir, "state.json"), "https://transferwisely.example.com/"
+
+	day := time.Date(2023, 5, 1, 9, 0, 0, 0, time.UTC)
+	assert.NoError(t, updateState(func(state *State) error {
+		state.RebookHistory = []RebookRecord{{Time: day.Add(2 * time.Hour), OldTransferId: 1, NewTransferId: 2,
+			SourceCurrency: "GBP", TargetCurrency: "INR", OldRate: 100, NewRate: 101, SourceAmount: decimalOf(1000), Reason: rebookReasonBetterRate}}
+		state.Decisions = []Decision{
+			{Time: day, TransferId: 1, SourceCurrency: "GBP", TargetCurrency: "INR", BookedRate: 100, LiveRate: 100.2, Action: checkActionNoAction},
+			{Time: day.Add(time.Hour), TransferId: 1, SourceCurrency: "GBP", TargetCurrency: "INR", BookedRate: 100, LiveRate: 99.8,
+				Action: checkActionError, Error: "quote failed"},
+			{Time: day.Add(2 * time.Hour), TransferId: 1, SourceCurrency: "GBP", TargetCurrency: "INR", BookedRate: 100, LiveRate: 101,
+				Action: checkActionRebooked, NewTransferId: 2},
+		}
+		return nil
+	}))
+
+	w := httptest.NewRecorder()
+	feedHandler(w, httptest.NewRequest(http.MethodGet, feedPath, nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/atom+xml; charset=utf-8", w.Header().Get("Content-Type"))
+
+	var feed AtomFeed
+	assert.NoError(t, xml.Unmarshal(w.Body.Bytes(), &feed))
+	assert.Equal(t, "2023-05-01T11:00:00Z", feed.Updated)
+	assert.Equal(t, "https://transferwisely.example.com/feed.atom", feed.Links[0].Href)
+	assert.Len(t, feed.Entries, 3, "the re-booking, the error and the day's rates, no action being summed up")
+
+	assert.Equal(t, "urn:transferwisely:rebook:2", feed.Entries[0].ID)
+	assert.Equal(t, "Transfer 1 re-booked at 101: {GBP} --> {INR}", feed.Entries[0].Title)
+	assert.Equal(t, "rates", feed.Entries[1].Category.Term)
+	assert.Equal(t, "{GBP} --> {INR} rates on 2023-05-01: 99.8 to 101", feed.Entries[1].Title)
+	assert.Contains(t, feed.Entries[1].Content.Body, "Last: 101 | Checks: 3")
+	assert.Equal(t, checkActionError, feed.Entries[2].Category.Term)
+	assert.Contains(t, feed.Entries[2].Content.Body, "Error: quote failed")
+
+	w = httptest.NewRecorder()
+	feedHandler(w, httptest.NewRequest(http.MethodPost, feedPath, nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
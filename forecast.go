@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"time"
+)
+
+// forecast hint related constants
+const (
+	forecastDays      = 90
+	forecastTrendDays = 7
+	forecastGroup     = "day"
+)
+
+// RateForecast places a live rate within the daily rates of the last Days days, to help decide whether to fund now
+type RateForecast struct {
+	Rate float64
+	Days int
+	// share of the daily rates at or below Rate, in percent
+	Percentile int
+	// change over the last TrendDays days along the least squares line of the daily rates, in percent
+	Trend     float64
+	TrendDays int
+	StdDev    float64
+}
+
+// Hint is the one line summary of the forecast put in notifications
+func (f RateForecast) Hint() string {
+	direction := "flat"
+	if f.Trend > 0 {
+		direction = "up"
+	} else if f.Trend < 0 {
+		direction = "down"
+	}
+	return fmt.Sprintf("Current rate %v is in the %v percentile of the last %v days, trending %v %.2f%% over %v days "+
+		"(std dev %v)", formatRate(f.Rate), ordinal(f.Percentile), f.Days, direction, math.Abs(f.Trend), f.TrendDays, formatRate(f.StdDev))
+}
+
+func isForecastHintEnabled() bool {
+	enabled, _ := strconv.ParseBool(forecastHintVar)
+	return enabled
+}
+
+// Fetch the last forecastDays days of daily rates of the pair and place rate within them
+func getRateForecast(source string, target string, rate float64) (RateForecast, error) {
+	to := time.Now().UTC()
+	history, err := getRateHistory(source, target, to.AddDate(0, 0, -forecastDays), to, forecastGroup)
+	if err != nil {
+		return RateForecast{}, fmt.Errorf("getRateForecast: %v", err)
+	}
+	if len(history) == 0 {
+		return RateForecast{}, fmt.Errorf("getRateForecast: no rate history found for {%v} --> {%v}", source, target)
+	}
+	return forecastRates(history, rate), nil
+}
+
+// The forecast of rate against history, oldest first
+func forecastRates(history []LiveRate, rate float64) RateForecast {
+	forecast := RateForecast{Rate: rate, Days: forecastDays, TrendDays: forecastTrendDays}
+	if len(history) == 0 {
+		return forecast
+	}
+
+	below, sum := 0, 0.0
+	for _, point := range history {
+		if point.Rate <= rate {
+			below++
+		}
+		sum += point.Rate
+	}
+	forecast.Percentile = below * 100 / len(history)
+	mean := sum / float64(len(history))
+	variance := 0.0
+	for _, point := range history {
+		variance += (point.Rate - mean) * (point.Rate - mean)
+	}
+	forecast.StdDev = math.Sqrt(variance / float64(len(history)))
+
+	recent := history
+	if len(recent) > forecastTrendDays {
+		recent = recent[len(recent)-forecastTrendDays:]
+	}
+	forecast.Trend = trendPercent(recent)
+	return forecast
+}
+
+// The change between the first and last points of the least squares line of the rates, in percent of its first point
+func trendPercent(history []LiveRate) float64 {
+	n := float64(len(history))
+	if n < 2 {
+		return 0
+	}
+	var sumX, sumY, sumXY, sumXX float64
+	for i, point := range history {
+		x := float64(i)
+		sumX += x
+		sumY += point.Rate
+		sumXY += x * point.Rate
+		sumXX += x * x
+	}
+	slope := (n*sumXY - sumX*sumY) / (n*sumXX - sumX*sumX)
+	intercept := (sumY - slope*sumX) / n
+	if intercept == 0 {
+		return 0
+	}
+	return slope * (n - 1) / intercept * 100
+}
+
+// The ordinal of n, like 1st, 22nd or 93rd
+func ordinal(n int) string {
+	suffix := "th"
+	switch {
+	case n%100 >= 11 && n%100 <= 13:
+	case n%10 == 1:
+		suffix = "st"
+	case n%10 == 2:
+		suffix = "nd"
+	case n%10 == 3:
+		suffix = "rd"
+	}
+	return strconv.Itoa(n) + suffix
+}
+
+// The forecast hint of the pair at rate, nil when FORECAST_HINT is off or the rate history can't be fetched
+func rateForecastHint(source string, target string, rate float64) *RateForecast {
+	if !isForecastHintEnabled() {
+		return nil
+	}
+	forecast, err := getRateForecast(source, target, rate)
+	if err != nil {
+		log.Printf("rateForecastHint: %v", err)
+		return nil
+	}
+	return &forecast
+}
+
+// The forecast hint as a notification text paragraph, empty without a forecast
+func formatForecast(forecast *RateForecast) string {
+	if forecast == nil {
+		return ""
+	}
+	return "\n\n" + forecast.Hint()
+}
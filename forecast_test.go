@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"transferwisely/mocks"
+)
+
+func TestForecastRates(t *testing.T) {
+	var history []LiveRate
+	for _, rate := range []float64{100, 101, 102, 103, 104, 105, 106, 107, 108, 109} {
+		history = append(history, LiveRate{Rate: rate})
+	}
+
+	forecast := forecastRates(history, 108.5)
+	assert.Equal(t, 90, forecast.Percentile)
+	assert.InDelta(t, 2.872281, forecast.StdDev, 0.000001)
+	assert.InDelta(t, 6/103.0*100, forecast.Trend, 0.000001, "over the last 7 days, 103 to 109")
+	assert.Equal(t, "Current rate 108.5 is in the 90th percentile of the last 90 days, trending up 5.83% over 7 days "+
+		"(std dev 2.872281)", forecast.Hint())
+
+	assert.Equal(t, 0, forecastRates(history, 99).Percentile)
+	assert.Equal(t, 0.0, forecastRates([]LiveRate{{Rate: 1}}, 1).Trend)
+	assert.Contains(t, forecastRates([]LiveRate{{Rate: 2}, {Rate: 1}}, 1).Hint(), "trending down 50.00%")
+}
+
+func TestOrdinal(t *testing.T) {
+	for n, expected := range map[int]string{1: "1st", 2: "2nd", 3: "3rd", 4: "4th", 11: "11th", 12: "12th", 13: "13th",
+		21: "21st", 92: "92nd", 100: "100th"} {
+		assert.Equal(t, expected, ordinal(n))
+	}
+}
+
+func TestRateForecastHint(t *testing.T) {
+	defer func(v string) { forecastHintVar = v }(forecastHintVar)
+	mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+		assert.Equal(t, forecastGroup, req.URL.Query().Get("group"))
+		body, _ := json.Marshal([]LiveRate{{Rate: 1.1}, {Rate: 1.2}, {Rate: 1.3}, {Rate: 1.4}})
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(string(body)))}, nil
+	}
+
+	forecastHintVar = "true"
+	forecast := rateForecastHint("GBP", "INR", 1.3)
+	assert.NotNil(t, forecast)
+	assert.Equal(t, 75, forecast.Percentile)
+	assert.Contains(t, formatForecast(forecast), "75th percentile of the last 90 days")
+
+	forecastHintVar = "false"
+	assert.Nil(t, rateForecastHint("GBP", "INR", 1.3))
+	assert.Empty(t, formatForecast(nil))
+}
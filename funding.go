@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// funding reminder notification
+const (
+	fundingReminderSubject = "Transfer %v isn't funded yet, its rate lock expires in %v"
+	fundingReminderText    = "Transfer ID: %v\n{%v} --> {%v}\nBooked Rate: %v\nAmount to pay in: %v %v\nRate lock expires: %v\n\n" +
+		"Pay in before the rate lock expires or the rate is lost."
+)
+
+// FundingReminders counts the reminders sent for an unfunded transfer, kept until its rate lock expired
+type FundingReminders struct {
+	Sent    int       `json:"sent"`
+	Expires time.Time `json:"expires"`
+}
+
+// Remind to fund the booked transfer as each FUNDING_REMINDERS hour before its rate lock expiry passes, the last
+// reminder being a funding-overdue event that is sent right away even during quiet hours, with emergency priority
+func remindFunding(transfer Transfer, now time.Time) {
+	reminders, err := getFundingReminders()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if len(reminders) == 0 || transfer.Status != transferStatusBooked || transfer.RateExpirationTime == "" {
+		return
+	}
+	expiryTime, err := time.Parse(time.RFC3339, transfer.RateExpirationTime)
+	if err != nil {
+		log.Printf("remindFunding: %v", err)
+		return
+	}
+	left := expiryTime.Sub(now)
+	if left <= 0 {
+		return
+	}
+
+	due := dueFundingReminders(reminders, left)
+	var sent int
+	err = updateState(func(state *State) error {
+		if state.FundingReminders == nil {
+			state.FundingReminders = map[uint64]FundingReminders{}
+		}
+		for id, reminded := range state.FundingReminders {
+			if !now.Before(reminded.Expires) {
+				delete(state.FundingReminders, id)
+			}
+		}
+		sent = state.FundingReminders[transfer.Id].Sent
+		if due > sent {
+			state.FundingReminders[transfer.Id] = FundingReminders{Sent: due, Expires: expiryTime}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("remindFunding: %v", err)
+		return
+	}
+	if due <= sent {
+		return
+	}
+
+	// reminders passed at once, e.g. while the batch was down, collapse into the most urgent one
+	kind := EventFundingReminder
+	if due == len(reminders) {
+		kind = EventFundingOverdue
+	}
+	log.Printf("|| TRANSFER NOT FUNDED || Transfer ID: %v | {%v} --> {%v} | Booked Rate: %v | Expires: %v | Reminder: %v of %v ||",
+		transfer.Id, transfer.SourceCurrency, transfer.TargetCurrency, transfer.Rate, transfer.RateExpirationTime, due, len(reminders))
+	notify(Event{
+		Kind:    kind,
+		Subject: fmt.Sprintf(fundingReminderSubject, transfer.Id, left.Round(time.Minute)),
+		Text: fmt.Sprintf(fundingReminderText, transfer.Id, transfer.SourceCurrency, transfer.TargetCurrency, formatRate(transfer.Rate),
+			formatAmount(transfer.SourceAmount, transfer.SourceCurrency), transfer.SourceCurrency, formatExpiry(transfer.RateExpirationTime)),
+		Pair:       pairKey(transfer.SourceCurrency, transfer.TargetCurrency),
+		TransferId: transfer.Id,
+	})
+}
+
+// Number of reminders due with the time left before the rate lock expires, reminders being sorted from the earliest
+func dueFundingReminders(reminders []time.Duration, left time.Duration) (due int) {
+	for _, before := range reminders {
+		if left <= before {
+			due++
+		}
+	}
+	return due
+}
+
+// Hours before the rate lock expiry to remind at from FUNDING_REMINDERS, like 24,6,1, from the earliest
+func getFundingReminders() ([]time.Duration, error) {
+	var reminders []time.Duration
+	for _, value := range strings.Split(fundingRemindersVar, ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		hours, err := strconv.ParseFloat(value, 64)
+		if err != nil || hours <= 0 {
+			return nil, fmt.Errorf("invalid value for FUNDING_REMINDERS: %v", fundingRemindersVar)
+		}
+		reminders = append(reminders, time.Duration(hours*float64(time.Hour)))
+	}
+	sort.Slice(reminders, func(i, j int) bool { return reminders[i] > reminders[j] })
+	return reminders, nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRemindFunding(t *testing.T) {
+	defer func(file, reminders, limit string) {
+		stateFileVar, fundingRemindersVar, notifyRateLimitVar = file, reminders, limit
+	}(stateFileVar, fundingRemindersVar, notifyRateLimitVar)
+	dir, _ := ioutil.TempDir("", "transferwisely")
+	defer os.RemoveAll(dir)
+	stateFileVar, notifyRateLimitVar = filepath.Join(dir, "state.json"), "0"
+	fake := &fakeNotifier{}
+	defer func(n []Notifier) { Notifiers = n }(Notifiers)
+	Notifiers = []Notifier{fake}
+
+	fundingRemindersVar = "1,24,6"
+	now := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+	transfer := Transfer{Id: 42, Status: transferStatusBooked, SourceCurrency: "GBP", SourceAmount: decimalOf(1000),
+		RateExpirationTime: "2020-05-03T12:00:00Z"}
+
+	remindFunding(transfer, now)
+	assert.Empty(t, fake.events, "expires in 48 hours")
+
+	remindFunding(transfer, now.Add(24*time.Hour))
+	assert.Len(t, fake.events, 1)
+	assert.Equal(t, EventFundingReminder, fake.events[0].Kind)
+	assert.Equal(t, "Transfer 42 isn't funded yet, its rate lock expires in 24h0m0s", fake.events[0].Subject)
+	assert.Contains(t, fake.events[0].Text, "Amount to pay in: 1000.00 GBP")
+
+	remindFunding(transfer, now.Add(30*time.Hour))
+	assert.Len(t, fake.events, 1, "each reminder is sent once")
+
+	funded := transfer
+	funded.Status = "processing"
+	remindFunding(funded, now.Add(43*time.Hour))
+	assert.Len(t, fake.events, 1, "funded")
+
+	// the 6 and 1 hours reminders passed at once, only the most urgent one is sent
+	remindFunding(transfer, now.Add(47*time.Hour+30*time.Minute))
+	assert.Len(t, fake.events, 2)
+	assert.Equal(t, EventFundingOverdue, fake.events[1].Kind)
+	assert.True(t, isCritical(EventFundingOverdue))
+
+	remindFunding(transfer, now.Add(47*time.Hour+45*time.Minute))
+	assert.Len(t, fake.events, 2)
+
+	state, err := loadState()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, state.FundingReminders[42].Sent)
+
+	// reminders of expired rate locks are forgotten
+	remindFunding(Transfer{Id: 43, Status: transferStatusBooked, RateExpirationTime: "2020-05-10T12:00:00Z"}, now.Add(72*time.Hour))
+	state, err = loadState()
+	assert.NoError(t, err)
+	assert.NotContains(t, state.FundingReminders, uint64(42))
+}
+
+func TestGetFundingReminders(t *testing.T) {
+	defer func(v string) { fundingRemindersVar = v }(fundingRemindersVar)
+
+	fundingRemindersVar = ""
+	reminders, err := getFundingReminders()
+	assert.NoError(t, err)
+	assert.Empty(t, reminders)
+
+	fundingRemindersVar = "6, 24,0.5"
+	reminders, err = getFundingReminders()
+	assert.NoError(t, err)
+	assert.Equal(t, []time.Duration{24 * time.Hour, 6 * time.Hour, 30 * time.Minute}, reminders)
+
+	for _, value := range []string{"0", "-1", "6h"} {
+		fundingRemindersVar = value
+		_, err = getFundingReminders()
+		assert.Error(t, err, value)
+	}
+}
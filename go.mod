@@ -7,6 +7,5 @@ require (
 	github.com/go-co-op/gocron v1.33.1
 	github.com/google/uuid v1.3.1
 	github.com/jordan-wright/email v0.0.0-20200322182553-8eef2508c362
-	github.com/mitchellh/mapstructure v1.2.2
 	github.com/stretchr/testify v1.8.2
 )
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+	"transferwisely/wise"
+)
+
+const grafanaAnnotationsAPIPath = "/api/annotations"
+
+// annotation tags, along with transferwisely and the currency pair
+const (
+	annotationRebooked = "rebooked"
+	annotationExpired  = "expired"
+)
+
+// GrafanaAnnotation marks a point in time on the Grafana charts, see https://grafana.com/docs/grafana/latest/developers/http_api/annotations/
+type GrafanaAnnotation struct {
+	DashboardUID string   `json:"dashboardUID,omitempty"`
+	Time         int64    `json:"time"`
+	Tags         []string `json:"tags"`
+	Text         string   `json:"text"`
+}
+
+// transfers whose expiry was already annotated
+var expiryAnnotations = struct {
+	sync.Mutex
+	annotated map[uint64]bool
+}{annotated: map[uint64]bool{}}
+
+func isAnnotatingGrafana() bool {
+	return grafanaURLVar != "" && grafanaAPIKeyVar != ""
+}
+
+// Annotate the re-booking of a transfer
+func annotateRebook(oldTransfer Transfer, newTransfer Transfer, reason string, now time.Time) {
+	annotate(now, fmt.Sprintf("Transfer %v re-booked as %v: %v --> %v (%v)", oldTransfer.Id, newTransfer.Id, oldTransfer.Rate,
+		newTransfer.Rate, reason), annotationRebooked, pairKey(newTransfer.SourceCurrency, newTransfer.TargetCurrency))
+}
+
+// Annotate, once per transfer and at the time it expired, the rate lock of a tracked transfer that expired
+func annotateExpiry(transfer Transfer, now time.Time) {
+	if !isAnnotatingGrafana() || transfer.RateExpirationTime == "" {
+		return
+	}
+	expiryTime, err := time.Parse(time.RFC3339, transfer.RateExpirationTime)
+	if err != nil {
+		log.Printf("annotateExpiry: %v", err)
+		return
+	}
+	if expiryTime.After(now) {
+		return
+	}
+
+	expiryAnnotations.Lock()
+	annotated := expiryAnnotations.annotated[transfer.Id]
+	expiryAnnotations.annotated[transfer.Id] = true
+	expiryAnnotations.Unlock()
+	if annotated {
+		return
+	}
+	annotate(expiryTime, fmt.Sprintf("Rate lock of transfer %v at %v expired", transfer.Id, transfer.Rate),
+		annotationExpired, pairKey(transfer.SourceCurrency, transfer.TargetCurrency))
+}
+
+// Annotate a re-booking that failed as its quote expired before the transfer got created
+func annotateQuoteExpired(transfer Transfer, err error, now time.Time) {
+	var apiErr *wise.APIError
+	if !errors.Is(err, ErrQuoteExpired) || !errors.As(err, &apiErr) {
+		return
+	}
+	annotate(now, fmt.Sprintf("Quote re-booking transfer %v expired: %v", transfer.Id, apiErr.Message),
+		annotationExpired, pairKey(transfer.SourceCurrency, transfer.TargetCurrency))
+}
+
+// POST an annotation to GRAFANA_URL, logging rather than failing the check when Grafana is unreachable
+func annotate(at time.Time, text string, tags ...string) {
+	if !isAnnotatingGrafana() {
+		return
+	}
+
+	annotation := GrafanaAnnotation{
+		DashboardUID: grafanaDashboardUIDVar,
+		Time:         at.UnixNano() / int64(time.Millisecond),
+		Tags:         append([]string{"transferwisely"}, tags...),
+		Text:         text,
+	}
+	headers := map[string]string{"Authorization": "Bearer " + grafanaAPIKeyVar}
+	err := postJSONWithHeaders(strings.TrimSuffix(grafanaURLVar, "/")+grafanaAnnotationsAPIPath, headers, annotation)
+	if err != nil {
+		log.Printf("annotate: %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+	"transferwisely/mocks"
+	"transferwisely/wise"
+)
+
+func TestGrafanaAnnotations(t *testing.T) {
+	defer func(url, key, dashboard string) {
+		grafanaURLVar, grafanaAPIKeyVar, grafanaDashboardUIDVar = url, key, dashboard
+	}(grafanaURLVar, grafanaAPIKeyVar, grafanaDashboardUIDVar)
+	grafanaURLVar, grafanaAPIKeyVar, grafanaDashboardUIDVar = "https://grafana.example.com/", "", "rates"
+
+	var requests []*http.Request
+	var annotations []GrafanaAnnotation
+	mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+		var annotation GrafanaAnnotation
+		body, _ := ioutil.ReadAll(req.Body)
+		_ = json.Unmarshal(body, &annotation)
+		requests = append(requests, req)
+		annotations = append(annotations, annotation)
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(`{"id": 1}`))}, nil
+	}
+
+	now := time.Date(2023, 3, 1, 10, 0, 0, 0, time.UTC)
+	oldTransfer := Transfer{Id: 1, Rate: 100.1, SourceCurrency: "GBP", TargetCurrency: "INR"}
+	newTransfer := Transfer{Id: 2, Rate: 100.5, SourceCurrency: "GBP", TargetCurrency: "INR"}
+
+	t.Run("disabled without an API key", func(t *testing.T) {
+		annotateRebook(oldTransfer, newTransfer, rebookReasonBetterRate, now)
+		assert.Len(t, requests, 0)
+	})
+
+	grafanaAPIKeyVar = "glsa_token"
+	t.Run("re-booking", func(t *testing.T) {
+		annotateRebook(oldTransfer, newTransfer, rebookReasonBetterRate, now)
+		assert.Len(t, requests, 1)
+		assert.Equal(t, "https://grafana.example.com/api/annotations", requests[0].URL.String())
+		assert.Equal(t, "Bearer glsa_token", requests[0].Header.Get("Authorization"))
+		assert.Equal(t, GrafanaAnnotation{
+			DashboardUID: "rates",
+			Time:         now.Unix() * 1000,
+			Tags:         []string{"transferwisely", annotationRebooked, "GBP-INR"},
+			Text:         fmt.Sprintf("Transfer 1 re-booked as 2: 100.1 --> 100.5 (%v)", rebookReasonBetterRate),
+		}, annotations[0])
+	})
+
+	t.Run("rate lock expiry annotated once at its expiry", func(t *testing.T) {
+		requests, annotations = nil, nil
+		expiring := Transfer{Id: 3, Rate: 100.1, SourceCurrency: "GBP", TargetCurrency: "INR",
+			RateExpirationTime: now.Add(time.Hour).Format(time.RFC3339)}
+		annotateExpiry(expiring, now)
+		assert.Len(t, requests, 0, "not expired yet")
+
+		annotateExpiry(expiring, now.Add(2*time.Hour))
+		annotateExpiry(expiring, now.Add(3*time.Hour))
+		assert.Len(t, requests, 1)
+		assert.Equal(t, now.Add(time.Hour).Unix()*1000, annotations[0].Time)
+		assert.Equal(t, []string{"transferwisely", annotationExpired, "GBP-INR"}, annotations[0].Tags)
+	})
+
+	t.Run("expired quote", func(t *testing.T) {
+		requests, annotations = nil, nil
+		annotateQuoteExpired(oldTransfer, fmt.Errorf("error POST create transfer API: %w", &wise.APIError{Status: 500}), now)
+		assert.Len(t, requests, 0)
+
+		err := fmt.Errorf("error POST create transfer API: %w", &wise.APIError{Status: 422, Code: "QUOTE_EXPIRED", Message: "expired"})
+		annotateQuoteExpired(oldTransfer, err, now)
+		assert.Len(t, requests, 1)
+		assert.Equal(t, "Quote re-booking transfer 1 expired: expired", annotations[0].Text)
+	})
+}
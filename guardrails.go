@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const rebookWindow = 24 * time.Hour
+
+// Refuse re-booking while in the cooldown window after the last re-booking or once the daily cap is reached. Renewals
+// are always allowed, the rate lock lapsing otherwise
+func checkRebookAllowed(reason string, now time.Time) error {
+	if reason == rebookReasonRenewal {
+		return nil
+	}
+	cooldown, maxRebooks, err := getGuardrails()
+	if err != nil {
+		return fmt.Errorf("checkRebookAllowed: %v", err)
+	}
+
+	state, err := loadState()
+	if err != nil {
+		return fmt.Errorf("checkRebookAllowed: %v", err)
+	}
+
+	return rebookAllowed(accountRebooks(state, getCurrentAccount()), now, cooldown, maxRebooks)
+}
+
+// The re-bookings the guardrails count for the account, each account having its own
+func accountRebooks(state State, account string) []time.Time {
+	if account == defaultAccount {
+		return state.Rebooks
+	}
+	return state.AccountRebooks[account]
+}
+
+// Whether the past re-bookings leave room for another one at now
+func rebookAllowed(rebooks []time.Time, now time.Time, cooldown time.Duration, maxRebooks int) error {
+	rebooksToday := 0
+	for _, rebook := range rebooks {
+		if now.Sub(rebook) < cooldown {
+			return fmt.Errorf("error: cooling down since last re-booking at %v, next re-booking allowed at %v",
+				rebook.Format(time.RFC3339), rebook.Add(cooldown).Format(time.RFC3339))
+		}
+		if now.Sub(rebook) < rebookWindow {
+			rebooksToday++
+		}
+	}
+
+	if maxRebooks > 0 && rebooksToday >= maxRebooks {
+		return fmt.Errorf("error: reached the maximum of %v re-bookings in the last 24 hours", maxRebooks)
+	}
+	return nil
+}
+
+// Refuse re-booking the transfer for a better rate once it is the end of a chain of MAX_REBOOK_CHAIN re-bookings, or
+// within REBOOK_CHAIN_COOLDOWN of being booked by one. Renewals always go through, else the rate lock would lapse
+func checkChainAllowed(transfer Transfer, reason string, now time.Time) error {
+	if reason != rebookReasonBetterRate {
+		return nil
+	}
+	maxChain, chainCooldown, err := getChainGuardrails()
+	if err != nil {
+		return fmt.Errorf("checkChainAllowed: %v", err)
+	}
+
+	state, err := loadState()
+	if err != nil {
+		return fmt.Errorf("checkChainAllowed: %v", err)
+	}
+
+	depth, last := rebookChain(state.RebookHistory, transfer.Id)
+	if maxChain > 0 && depth >= maxChain {
+		return fmt.Errorf("error: transfer %v is already the re-booking of a re-booking %v times, the maximum", transfer.Id, depth)
+	}
+	if depth > 0 && now.Sub(last) < chainCooldown {
+		return fmt.Errorf("error: transfer %v was re-booked at %v, next re-booking of it allowed at %v", transfer.Id,
+			last.Format(time.RFC3339), last.Add(chainCooldown).Format(time.RFC3339))
+	}
+	return nil
+}
+
+// Number of re-bookings leading to the transfer, following the re-booking history back to the transfer first booked,
+// and when the transfer itself was booked by a re-booking
+func rebookChain(history []RebookRecord, transferId uint64) (depth int, last time.Time) {
+	id := transferId
+	// walking back from the latest re-booking, a chain can't be longer than the history
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].NewTransferId != id {
+			continue
+		}
+		if depth == 0 {
+			last = history[i].Time
+		}
+		depth++
+		id = history[i].OldTransferId
+	}
+	return depth, last
+}
+
+// Persist a re-booking, forgetting the ones no guardrail looks at anymore
+func recordRebook(now time.Time) error {
+	cooldown, _, err := getGuardrails()
+	if err != nil {
+		return fmt.Errorf("recordRebook: %v", err)
+	}
+
+	retention := rebookWindow
+	if cooldown > retention {
+		retention = cooldown
+	}
+
+	account := getCurrentAccount()
+	return updateState(func(state *State) error {
+		rebooks := []time.Time{now}
+		for _, rebook := range accountRebooks(*state, account) {
+			if now.Sub(rebook) < retention {
+				rebooks = append(rebooks, rebook)
+			}
+		}
+		if account == defaultAccount {
+			state.Rebooks = rebooks
+			return nil
+		}
+		if state.AccountRebooks == nil {
+			state.AccountRebooks = map[string][]time.Time{}
+		}
+		state.AccountRebooks[account] = rebooks
+		return nil
+	})
+}
+
+func getGuardrails() (cooldown time.Duration, maxRebooks int, err error) {
+	cooldownMinutes, err := strconv.ParseUint(rebookCooldownVar, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid value for REBOOK_COOLDOWN: %v", err)
+	}
+
+	maxRebooksPerDay, err := strconv.ParseUint(maxRebooksPerDayVar, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid value for MAX_REBOOKS_PER_DAY: %v", err)
+	}
+
+	return time.Duration(cooldownMinutes) * time.Minute, int(maxRebooksPerDay), nil
+}
+
+func getChainGuardrails() (maxChain int, chainCooldown time.Duration, err error) {
+	maxRebookChain, err := strconv.ParseUint(maxRebookChainVar, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid value for MAX_REBOOK_CHAIN: %v", err)
+	}
+
+	cooldownMinutes, err := strconv.ParseUint(rebookChainCooldownVar, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid value for REBOOK_CHAIN_COOLDOWN: %v", err)
+	}
+
+	return int(maxRebookChain), time.Duration(cooldownMinutes) * time.Minute, nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRebookGuardrails(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "transferwisely")
+	defer os.RemoveAll(dir)
+	defer func(file, cooldown, max string) {
+		stateFileVar, rebookCooldownVar, maxRebooksPerDayVar = file, cooldown, max
+	}(stateFileVar, rebookCooldownVar, maxRebooksPerDayVar)
+
+	stateFileVar = filepath.Join(dir, "state.json")
+	rebookCooldownVar = "60"
+	maxRebooksPerDayVar = "2"
+	now := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("no previous re-booking", func(t *testing.T) {
+		assert.NoError(t, checkRebookAllowed(rebookReasonBetterRate, now))
+	})
+
+	t.Run("cooldown", func(t *testing.T) {
+		assert.NoError(t, recordRebook(now))
+		assert.Error(t, checkRebookAllowed(rebookReasonBetterRate, now.Add(30*time.Minute)))
+		assert.NoError(t, checkRebookAllowed(rebookReasonBetterRate, now.Add(time.Hour)))
+	})
+
+	t.Run("renewals skip the cooldown", func(t *testing.T) {
+		assert.NoError(t, checkRebookAllowed(rebookReasonRenewal, now.Add(30*time.Minute)))
+	})
+
+	t.Run("daily cap", func(t *testing.T) {
+		assert.NoError(t, recordRebook(now.Add(2*time.Hour)))
+		assert.Error(t, checkRebookAllowed(rebookReasonBetterRate, now.Add(4*time.Hour)))
+		assert.NoError(t, checkRebookAllowed(rebookReasonBetterRate, now.Add(24*time.Hour)))
+	})
+
+	t.Run("old re-bookings are forgotten", func(t *testing.T) {
+		assert.NoError(t, recordRebook(now.Add(72*time.Hour)))
+		state, err := loadState()
+		assert.NoError(t, err)
+		assert.Len(t, state.Rebooks, 1)
+	})
+
+	t.Run("invalid config", func(t *testing.T) {
+		maxRebooksPerDayVar = "many"
+		assert.Error(t, checkRebookAllowed(rebookReasonBetterRate, now))
+	})
+}
+
+func TestRebookChainGuardrails(t *testing.T) {
+	defer func(file, max, cooldown string) {
+		stateFileVar, maxRebookChainVar, rebookChainCooldownVar = file, max, cooldown
+	}(stateFileVar, maxRebookChainVar, rebookChainCooldownVar)
+
+	dir, _ := ioutil.TempDir("", "transferwisely")
+	defer os.RemoveAll(dir)
+	stateFileVar = filepath.Join(dir, "state.json")
+	maxRebookChainVar = "2"
+	rebookChainCooldownVar = "120"
+	now := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+	assert.NoError(t, recordRebookHistory(RebookRecord{Time: now.Add(-5 * time.Hour), OldTransferId: 1, NewTransferId: 2}))
+	assert.NoError(t, recordRebookHistory(RebookRecord{Time: now.Add(-4 * time.Hour), OldTransferId: 10, NewTransferId: 11}))
+	assert.NoError(t, recordRebookHistory(RebookRecord{Time: now.Add(-time.Hour), OldTransferId: 2, NewTransferId: 3}))
+
+	t.Run("chain depth", func(t *testing.T) {
+		depth, last := rebookChain([]RebookRecord{{Time: now, OldTransferId: 1, NewTransferId: 2}}, 2)
+		assert.Equal(t, 1, depth)
+		assert.Equal(t, now, last)
+		depth, _ = rebookChain(nil, 2)
+		assert.Zero(t, depth)
+	})
+
+	t.Run("transfer first booked", func(t *testing.T) {
+		assert.NoError(t, checkChainAllowed(Transfer{Id: 1}, rebookReasonBetterRate, now))
+	})
+
+	t.Run("chain cooldown", func(t *testing.T) {
+		assert.Error(t, checkChainAllowed(Transfer{Id: 11}, rebookReasonBetterRate, now.Add(-3*time.Hour)))
+		assert.NoError(t, checkChainAllowed(Transfer{Id: 11}, rebookReasonBetterRate, now))
+	})
+
+	t.Run("max chain depth", func(t *testing.T) {
+		assert.Error(t, checkChainAllowed(Transfer{Id: 3}, rebookReasonBetterRate, now.Add(24*time.Hour)))
+		maxRebookChainVar = "0"
+		assert.NoError(t, checkChainAllowed(Transfer{Id: 3}, rebookReasonBetterRate, now.Add(24*time.Hour)))
+		maxRebookChainVar = "2"
+	})
+
+	t.Run("renewals aren't chained", func(t *testing.T) {
+		assert.NoError(t, checkChainAllowed(Transfer{Id: 3}, rebookReasonRenewal, now))
+	})
+
+	t.Run("invalid config", func(t *testing.T) {
+		rebookChainCooldownVar = "soon"
+		assert.Error(t, checkChainAllowed(Transfer{Id: 3}, rebookReasonBetterRate, now))
+	})
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"transferwisely/mqtt"
+)
+
+// number of missed check intervals after which the batch is considered unhealthy
+const staleIntervals = 3
+
+// HealthStatus is reported by the /healthz and /readyz endpoints
+type HealthStatus struct {
+	Status                string    `json:"status"`
+	StartedAt             time.Time `json:"startedAt"`
+	LastCheck             time.Time `json:"lastCheck"`
+	LastSuccessfulAPICall time.Time `json:"lastSuccessfulApiCall"`
+	ConfigError           string    `json:"configError,omitempty"`
+	CircuitOpen           bool      `json:"circuitOpen,omitempty"`
+}
+
+var health = struct {
+	sync.Mutex
+	startedAt             time.Time
+	lastCheck             time.Time
+	lastSuccessfulAPICall time.Time
+}{startedAt: time.Now().UTC()}
+
+func recordCheck() {
+	health.Lock()
+	health.lastCheck = time.Now().UTC()
+	health.Unlock()
+}
+
+func recordSuccessfulAPICall() {
+	health.Lock()
+	health.lastSuccessfulAPICall = time.Now().UTC()
+	health.Unlock()
+}
+
+// Validate every env variable the batch needs to do its job
+func validateConfig() error {
+	if hostVar == "" || apiTokenVar == "" {
+		return ErrEnvVarMissingOrInvalid
+	}
+	if _, err := getDefaultSettings(); err != nil {
+		return err
+	}
+	if _, err := getAPIPaths(); err != nil {
+		return err
+	}
+	if _, err := strconv.ParseBool(fundFromBalanceVar); err != nil {
+		return fmt.Errorf("invalid value for FUND_FROM_BALANCE: %v", err)
+	}
+	if _, err := strconv.ParseBool(startupReportNotifyVar); err != nil {
+		return fmt.Errorf("invalid value for STARTUP_REPORT_NOTIFY: %v", err)
+	}
+	provider, err := getMailProvider()
+	if err != nil {
+		return err
+	}
+	if provider != mailProviderSMTP && toEmailVar != "" && !isMailAPIConfigured(provider) {
+		return fmt.Errorf("MAIL_PROVIDER=%v requires %v", provider, mailProviderRequirements[provider])
+	}
+	if isMailConfigured() && provider == mailProviderSMTP {
+		if _, err := getSMTPAuth(); err != nil {
+			return err
+		}
+		switch strings.ToLower(smtpTLSVar) {
+		case smtpTLSStartTLS, smtpTLSImplicit, smtpTLSNone:
+		default:
+			return fmt.Errorf("invalid value for SMTP_TLS: %v", smtpTLSVar)
+		}
+	}
+	if _, _, _, err := getQuietHours(); err != nil {
+		return err
+	}
+	if _, _, err := getRateDigest(); err != nil {
+		return err
+	}
+	if _, err := strconv.ParseUint(notifyRateLimitVar, 10, 64); err != nil {
+		return fmt.Errorf("invalid value for NOTIFY_RATE_LIMIT: %v", err)
+	}
+	if _, err := getPushPriority("NTFY_PRIORITY", ntfyPriorityVar, ntfyMinPriority, ntfyMaxPriority); err != nil {
+		return err
+	}
+	if _, err := getPushPriority("GOTIFY_PRIORITY", gotifyPriorityVar, gotifyMinPriority, gotifyMaxPriority); err != nil {
+		return err
+	}
+	if _, err := getPushPriority("PUSHOVER_PRIORITY", pushoverPriorityVar, pushoverLowestPriority, pushoverEmergencyPriority); err != nil {
+		return err
+	}
+	if _, _, err := getPushoverRetry(); err != nil {
+		return err
+	}
+	if _, err := strconv.ParseBool(desktopNotifyVar); err != nil {
+		return fmt.Errorf("invalid value for DESKTOP_NOTIFY: %v", err)
+	}
+	if _, err := getExpiryAlert(); err != nil {
+		return err
+	}
+	if _, err := getTimezone(); err != nil {
+		return err
+	}
+	if _, err := getTimezoneOr("CHECK_WINDOWS_TZ", checkWindowsTZVar); err != nil {
+		return err
+	}
+	if _, err := getFundingReminders(); err != nil {
+		return err
+	}
+	if _, err := getBalanceCheckInterval(); err != nil {
+		return err
+	}
+	if _, err := getActivityInterval(); err != nil {
+		return err
+	}
+	if _, err := getQuoteRetryInterval(); err != nil {
+		return err
+	}
+	if _, _, _, err := getAdaptiveVolatility(); err != nil {
+		return err
+	}
+	if _, err := getConfiguredRateSources(); err != nil {
+		return err
+	}
+	if _, err := getRateDeviation(); err != nil {
+		return err
+	}
+	if _, err := getQuoteSpreadThreshold(); err != nil {
+		return err
+	}
+	if _, err := getCheckWorkers(); err != nil {
+		return err
+	}
+	if isPublishingHomeAssistant() {
+		if err := mqtt.ValidateBroker(mqttBrokerVar); err != nil {
+			return fmt.Errorf("invalid value for MQTT_BROKER: %v", err)
+		}
+	}
+	if _, err := getEventPublishers(); err != nil {
+		return err
+	}
+	if _, _, err := getAPIRateLimit(); err != nil {
+		return err
+	}
+	if _, _, _, err := getRenewalConfig(); err != nil {
+		return err
+	}
+	if _, _, err := getGuardrails(); err != nil {
+		return err
+	}
+	if _, _, err := getChainGuardrails(); err != nil {
+		return err
+	}
+	if _, err := getShutdownTimeout(); err != nil {
+		return err
+	}
+	if _, err := strconv.ParseBool(approvalModeVar); err != nil {
+		return fmt.Errorf("invalid value for APPROVAL_MODE: %v", err)
+	}
+	if _, err := newHTTPTransport(); err != nil {
+		return err
+	}
+	if err := validateMailTemplates(); err != nil {
+		return err
+	}
+	if _, err := getTrackedStatuses(); err != nil {
+		return err
+	}
+	if _, err := strconv.ParseBool(monitorTransfersVar); err != nil {
+		return fmt.Errorf("invalid value for MONITOR_TRANSFERS: %v", err)
+	}
+	if _, err := strconv.ParseBool(receiptsVar); err != nil {
+		return fmt.Errorf("invalid value for RECEIPTS: %v", err)
+	}
+	if _, err := strconv.ParseBool(compareProvidersVar); err != nil {
+		return fmt.Errorf("invalid value for COMPARE_PROVIDERS: %v", err)
+	}
+	if _, err := strconv.ParseBool(forecastHintVar); err != nil {
+		return fmt.Errorf("invalid value for FORECAST_HINT: %v", err)
+	}
+	if sentryDSNVar != "" {
+		if _, err := newSentryReporter(sentryDSNVar); err != nil {
+			return err
+		}
+	}
+	if _, err := getErrorReportThreshold(); err != nil {
+		return err
+	}
+	if _, _, err := getCircuitBreaker(); err != nil {
+		return err
+	}
+	if leaderElectionVar != "" && leaderElectionVar != leaderElectionKubernetes {
+		return fmt.Errorf("invalid value for LEADER_ELECTION: %v", leaderElectionVar)
+	}
+	if _, err := getLeaseDuration(); err != nil {
+		return err
+	}
+	if _, err := strconv.ParseBool(readOnlyVar); err != nil {
+		return fmt.Errorf("invalid value for READ_ONLY: %v", err)
+	}
+	if _, err := getNumberFormat(); err != nil {
+		return err
+	}
+	if _, err := strconv.ParseBool(debugHTTPVar); err != nil {
+		return fmt.Errorf("invalid value for DEBUG_HTTP: %v", err)
+	}
+	return nil
+}
+
+func getHealthStatus() (status HealthStatus) {
+	health.Lock()
+	status.StartedAt = health.startedAt
+	status.LastCheck = health.lastCheck
+	status.LastSuccessfulAPICall = health.lastSuccessfulAPICall
+	health.Unlock()
+	status.CircuitOpen = isCircuitOpen()
+
+	if err := validateConfig(); err != nil {
+		status.ConfigError = err.Error()
+	}
+	return
+}
+
+// Everything older than a few check intervals is stale
+func isStale(since time.Time, now time.Time) bool {
+	interval, err := getSchedulerInterval()
+	if err != nil {
+		return true
+	}
+	return now.Sub(since) > time.Duration(staleIntervals*interval)*time.Minute
+}
+
+// Liveness: the scheduler keeps running checks
+func healthzHandler(w http.ResponseWriter, _ *http.Request) {
+	status := getHealthStatus()
+	now := time.Now().UTC()
+
+	lastAlive := status.LastCheck
+	if lastAlive.IsZero() {
+		lastAlive = status.StartedAt
+	}
+
+	code := http.StatusOK
+	if isStale(lastAlive, now) {
+		code = http.StatusServiceUnavailable
+	}
+	writeHealthStatus(w, code, status)
+}
+
+// Readiness: the config is valid and transferwise API has been reachable recently
+func readyzHandler(w http.ResponseWriter, _ *http.Request) {
+	status := getHealthStatus()
+	now := time.Now().UTC()
+
+	code := http.StatusOK
+	if status.ConfigError != "" || status.LastSuccessfulAPICall.IsZero() || isStale(status.LastSuccessfulAPICall, now) {
+		code = http.StatusServiceUnavailable
+	}
+	writeHealthStatus(w, code, status)
+}
+
+func writeHealthStatus(w http.ResponseWriter, code int, status HealthStatus) {
+	status.Status = "ok"
+	if code != http.StatusOK {
+		status.Status = "unavailable"
+	}
+
+	writeJSON(w, code, status)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
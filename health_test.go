@@ -0,0 +1,44 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHealthz(t *testing.T) {
+	recordCheck()
+	w := httptest.NewRecorder()
+	healthzHandler(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	health.Lock()
+	health.lastCheck = time.Now().UTC().Add(-24 * time.Hour)
+	health.Unlock()
+	w = httptest.NewRecorder()
+	healthzHandler(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func TestReadyz(t *testing.T) {
+	defer func(host, token string) { hostVar, apiTokenVar = host, token }(hostVar, apiTokenVar)
+
+	t.Run("invalid config", func(t *testing.T) {
+		hostVar, apiTokenVar = "", ""
+		recordSuccessfulAPICall()
+		w := httptest.NewRecorder()
+		readyzHandler(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Contains(t, w.Body.String(), ErrEnvVarMissingOrInvalid.Error())
+	})
+
+	t.Run("ready", func(t *testing.T) {
+		hostVar, apiTokenVar = hostSandbox, "token"
+		recordSuccessfulAPICall()
+		w := httptest.NewRecorder()
+		readyzHandler(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
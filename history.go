@@ -0,0 +1,82 @@
+package main
+
+import "time"
+
+// number of past re-bookings kept in the state file
+const maxRebookHistory = 100
+
+// reasons for re-booking a transfer
+const (
+	rebookReasonBetterRate = "better rate"
+	rebookReasonRenewal    = "renewal"
+)
+
+// RebookRecord is a past re-booking as shown on the dashboard
+type RebookRecord struct {
+	Time           time.Time `json:"time"`
+	OldTransferId  uint64    `json:"oldTransferId"`
+	NewTransferId  uint64    `json:"newTransferId"`
+	SourceCurrency string    `json:"sourceCurrency"`
+	TargetCurrency string    `json:"targetCurrency"`
+	OldRate        float64   `json:"oldRate"`
+	NewRate        float64   `json:"newRate"`
+	SourceAmount   Decimal   `json:"sourceAmount"`
+	Reason         string    `json:"reason"`
+}
+
+func recordRebookHistory(record RebookRecord) error {
+	return updateState(func(state *State) error {
+		state.RebookHistory = append(state.RebookHistory, record)
+		if len(state.RebookHistory) > maxRebookHistory {
+			state.RebookHistory = state.RebookHistory[len(state.RebookHistory)-maxRebookHistory:]
+		}
+		return nil
+	})
+}
+
+// number of past check decisions kept in the state file, about three days of checks every minute
+const maxDecisionHistory = 5000
+
+// Decision is the outcome of a check cycle along with the rates it was based on
+type Decision struct {
+	Time           time.Time `json:"time"`
+	TransferId     uint64    `json:"transferId"`
+	SourceCurrency string    `json:"sourceCurrency"`
+	TargetCurrency string    `json:"targetCurrency"`
+	BookedRate     float64   `json:"bookedRate"`
+	LiveRate       float64   `json:"liveRate"`
+	Action         string    `json:"action"`
+	Reason         string    `json:"reason,omitempty"`
+	NewTransferId  uint64    `json:"newTransferId,omitempty"`
+	Error          string    `json:"error,omitempty"`
+}
+
+// Keep the outcome of every check that compared rates, to export it for analysis later
+func recordDecision(check CheckResult, now time.Time) error {
+	if check.Transfer == nil || check.LiveRate == 0 {
+		return nil
+	}
+	decision := Decision{
+		Time:           now,
+		TransferId:     check.Transfer.Id,
+		SourceCurrency: check.Transfer.SourceCurrency,
+		TargetCurrency: check.Transfer.TargetCurrency,
+		BookedRate:     check.Transfer.Rate,
+		LiveRate:       check.LiveRate,
+		Action:         check.Action,
+		Reason:         check.Reason,
+		Error:          check.Error,
+	}
+	if check.NewTransfer != nil {
+		decision.NewTransferId = check.NewTransfer.Id
+	}
+	publishStream(streamDecision, decision)
+	publishEventBus(busDecision, pairKey(decision.SourceCurrency, decision.TargetCurrency), decision)
+	return updateState(func(state *State) error {
+		state.Decisions = append(state.Decisions, decision)
+		if len(state.Decisions) > maxDecisionHistory {
+			state.Decisions = state.Decisions[len(state.Decisions)-maxDecisionHistory:]
+		}
+		return nil
+	})
+}
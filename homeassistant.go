@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+	"transferwisely/mqtt"
+)
+
+// HomeAssistantSensor is one figure of a pair, read by Home Assistant from the pair's state topic
+type HomeAssistantSensor struct {
+	Key   string
+	Name  string
+	Unit  string
+	Gauge bool
+}
+
+// sensors of every tracked pair
+var homeAssistantSensors = []HomeAssistantSensor{
+	{Key: "live_rate", Name: "live rate", Gauge: true},
+	{Key: "booked_rate", Name: "booked rate", Gauge: true},
+	{Key: "threshold", Name: "re-booking threshold", Gauge: true},
+	{Key: "margin_delta", Name: "margin delta", Unit: "%", Gauge: true},
+	{Key: "transfer_status", Name: "transfer status"},
+	{Key: "transfer_id", Name: "transfer ID"},
+}
+
+// HomeAssistantState is published, retained, to the state topic of a pair after every check of it
+type HomeAssistantState struct {
+	LiveRate       float64   `json:"live_rate"`
+	BookedRate     float64   `json:"booked_rate"`
+	Threshold      float64   `json:"threshold"`
+	MarginDelta    float64   `json:"margin_delta"`
+	TransferStatus string    `json:"transfer_status"`
+	TransferId     uint64    `json:"transfer_id"`
+	CheckedAt      time.Time `json:"checked_at"`
+}
+
+// homeAssistantDiscovery is the discovery config of a sensor, see https://www.home-assistant.io/integrations/mqtt/#mqtt-discovery
+type homeAssistantDiscovery struct {
+	Name              string              `json:"name"`
+	UniqueId          string              `json:"unique_id"`
+	ObjectId          string              `json:"object_id"`
+	StateTopic        string              `json:"state_topic"`
+	ValueTemplate     string              `json:"value_template"`
+	UnitOfMeasurement string              `json:"unit_of_measurement,omitempty"`
+	StateClass        string              `json:"state_class,omitempty"`
+	Device            homeAssistantDevice `json:"device"`
+}
+
+type homeAssistantDevice struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer"`
+}
+
+// pairs whose discovery configs were published, once per run, and serializes the publishing
+var homeAssistant = struct {
+	sync.Mutex
+	discovered map[string]bool
+}{discovered: map[string]bool{}}
+
+// publishes the messages to MQTT_BROKER, replaced in tests
+var mqttPublish = func(messages []mqtt.Message) error {
+	client, err := mqtt.Connect(mqttBrokerVar, mqtt.Options{ClientID: "transferwisely", Username: mqttUsernameVar,
+		Password: mqttPasswordVar})
+	if err != nil {
+		return err
+	}
+	if err := client.Publish(messages...); err != nil {
+		_ = client.Close()
+		return err
+	}
+	return client.Close()
+}
+
+func isPublishingHomeAssistant() bool {
+	return mqttBrokerVar != ""
+}
+
+// Publish the figures of the checked transfer to MQTT, along with the discovery configs of its pair the first time,
+// so they show up as Home Assistant sensors
+func publishHomeAssistant(observation TrackedTransfer, settings Settings) {
+	if !isPublishingHomeAssistant() {
+		return
+	}
+	transfer := observation.Transfer
+	object := homeAssistantObjectId(transfer.SourceCurrency, transfer.TargetCurrency)
+	stateTopic := fmt.Sprintf("%v/%v/state", mqttTopicPrefixVar, strings.ToLower(transfer.SourceCurrency+"_"+transfer.TargetCurrency))
+
+	homeAssistant.Lock()
+	defer homeAssistant.Unlock()
+	var messages []mqtt.Message
+	if !homeAssistant.discovered[object] {
+		messages = homeAssistantDiscoveryMessages(transfer.SourceCurrency, transfer.TargetCurrency, stateTopic)
+	}
+	var marginDelta float64
+	if transfer.Rate != 0 {
+		improvement := rateImprovement(transfer.Rate, observation.LiveRate, settings)
+		marginDelta = improvement.Quo(decimalOf(transfer.Rate)).Mul(decimalOf(100)).Round(4).Float64()
+	}
+	state, _ := json.Marshal(HomeAssistantState{
+		LiveRate:       observation.LiveRate,
+		BookedRate:     transfer.Rate,
+		Threshold:      observation.Threshold,
+		MarginDelta:    marginDelta,
+		TransferStatus: transfer.Status,
+		TransferId:     transfer.Id,
+		CheckedAt:      observation.CheckedAt,
+	})
+	messages = append(messages, mqtt.Message{Topic: stateTopic, Payload: state, Retain: true})
+
+	if err := mqttPublish(messages); err != nil {
+		log.Printf("publishHomeAssistant: %v", err)
+		return
+	}
+	homeAssistant.discovered[object] = true
+}
+
+func homeAssistantDiscoveryMessages(source string, target string, stateTopic string) []mqtt.Message {
+	object := homeAssistantObjectId(source, target)
+	device := homeAssistantDevice{
+		Identifiers:  []string{object},
+		Name:         fmt.Sprintf("transferwisely %v %v", source, target),
+		Manufacturer: "transferwisely",
+	}
+	messages := make([]mqtt.Message, len(homeAssistantSensors))
+	for i, sensor := range homeAssistantSensors {
+		config := homeAssistantDiscovery{
+			Name:          fmt.Sprintf("%v %v %v", source, target, sensor.Name),
+			UniqueId:      object + "_" + sensor.Key,
+			ObjectId:      object + "_" + sensor.Key,
+			StateTopic:    stateTopic,
+			ValueTemplate: fmt.Sprintf("{{ value_json.%v }}", sensor.Key),
+			Device:        device,
+		}
+		if sensor.Gauge {
+			config.StateClass = "measurement"
+			config.UnitOfMeasurement = target
+		}
+		if sensor.Unit != "" {
+			config.UnitOfMeasurement = sensor.Unit
+		}
+		payload, _ := json.Marshal(config)
+		messages[i] = mqtt.Message{
+			Topic:   fmt.Sprintf("%v/sensor/%v/%v/config", mqttDiscoveryPrefixVar, object, sensor.Key),
+			Payload: payload,
+			Retain:  true,
+		}
+	}
+	return messages
+}
+
+// The object ID of a pair, like transferwisely_gbp_inr
+func homeAssistantObjectId(source string, target string) string {
+	return strings.ToLower("transferwisely_" + source + "_" + target)
+}
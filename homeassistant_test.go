@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+	"transferwisely/mqtt"
+)
+
+func TestPublishHomeAssistant(t *testing.T) {
+	defer func(v string) { mqttBrokerVar = v }(mqttBrokerVar)
+	defer func(f func([]mqtt.Message) error) { mqttPublish = f }(mqttPublish)
+	mqttBrokerVar = "mqtt://homeassistant.local"
+	var published [][]mqtt.Message
+	var publishErr error
+	mqttPublish = func(messages []mqtt.Message) error {
+		published = append(published, messages)
+		return publishErr
+	}
+	homeAssistant.Lock()
+	homeAssistant.discovered = map[string]bool{}
+	homeAssistant.Unlock()
+
+	observation := TrackedTransfer{
+		Transfer:  Transfer{Id: 7, Rate: 100, Status: transferStatusBooked, SourceCurrency: "GBP", TargetCurrency: "INR"},
+		LiveRate:  100.5,
+		Threshold: 101,
+		CheckedAt: time.Date(2023, 3, 1, 9, 0, 0, 0, time.UTC),
+	}
+
+	publishErr = errors.New("broker down")
+	publishHomeAssistant(observation, Settings{})
+	publishErr = nil
+	publishHomeAssistant(observation, Settings{})
+	publishHomeAssistant(observation, Settings{})
+	assert.Len(t, published, 3)
+	assert.Len(t, published[1], len(homeAssistantSensors)+1, "discovery is published again after a failure")
+	assert.Len(t, published[2], 1, "then only the state")
+
+	discovery := published[1][3]
+	assert.Equal(t, "homeassistant/sensor/transferwisely_gbp_inr/margin_delta/config", discovery.Topic)
+	assert.True(t, discovery.Retain)
+	var config homeAssistantDiscovery
+	assert.NoError(t, json.Unmarshal(discovery.Payload, &config))
+	assert.Equal(t, "transferwisely/gbp_inr/state", config.StateTopic)
+	assert.Equal(t, "{{ value_json.margin_delta }}", config.ValueTemplate)
+	assert.Equal(t, "%", config.UnitOfMeasurement)
+
+	state := published[2][0]
+	assert.Equal(t, "transferwisely/gbp_inr/state", state.Topic)
+	assert.JSONEq(t, `{"live_rate": 100.5, "booked_rate": 100, "threshold": 101, "margin_delta": 0.5,
+		"transfer_status": "incoming_payment_waiting", "transfer_id": 7, "checked_at": "2023-03-01T09:00:00Z"}`, string(state.Payload))
+
+	t.Run("lower is better", func(t *testing.T) {
+		publishHomeAssistant(observation, Settings{LowerIsBetter: true})
+		var state HomeAssistantState
+		assert.NoError(t, json.Unmarshal(published[3][0].Payload, &state))
+		assert.Equal(t, -0.5, state.MarginDelta)
+	})
+}
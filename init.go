@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// config file init writes when neither --out nor CONFIG_FILE is given
+const initConfigFile = "transferwisely.json"
+
+// starter margin of a pair, as a share of its booked rate, and starter check interval in minutes
+const (
+	initMarginShare = 0.002
+	initInterval    = 5
+)
+
+// Recipient is an account transfers can be sent to
+type Recipient struct {
+	Id                uint64 `json:"id"`
+	Currency          string `json:"currency"`
+	AccountHolderName string `json:"accountHolderName"`
+	Type              string `json:"type"`
+}
+
+func getRecipients(profileId uint64) ([]Recipient, error) {
+	query := url.Values{}
+	query.Set("profile", strconv.FormatUint(profileId, 10))
+	url := &url.URL{RawQuery: query.Encode(), Host: hostVar, Scheme: "https", Path: recipientsAPIPath}
+
+	var recipients []Recipient
+	_, err := callExternalAPI(http.MethodGet, url.String(), nil, &recipients)
+	if err != nil {
+		return nil, fmt.Errorf("error GET recipients API: %w", err)
+	}
+	return recipients, nil
+}
+
+// The starter margin of a pair booked at rate: its initMarginShare, rounded to 2 significant digits, like 0.21 for
+// GBP-INR at 105 and 0.0012 for JPY-INR at 0.6
+func initMargin(rate float64) float64 {
+	margin := rate * initMarginShare
+	if margin <= 0 {
+		return 0
+	}
+	scale := math.Pow(10, 1-math.Floor(math.Log10(margin)))
+	return math.Round(margin*scale) / scale
+}
+
+// prompter asks questions on out, reading the answers from in, the default answer standing for an empty one
+type prompter struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+func (p prompter) ask(question string, defaultAnswer string) (string, error) {
+	if defaultAnswer != "" {
+		fmt.Fprintf(p.out, "%v [%v]: ", question, defaultAnswer)
+	} else {
+		fmt.Fprintf(p.out, "%v: ", question)
+	}
+	line, err := p.in.ReadString('\n')
+	if err != nil && line == "" {
+		if err == io.EOF {
+			return defaultAnswer, nil
+		}
+		return "", err
+	}
+	if answer := strings.TrimSpace(line); answer != "" {
+		return answer, nil
+	}
+	return defaultAnswer, nil
+}
+
+func (p prompter) confirm(question string, defaultYes bool) (bool, error) {
+	defaultAnswer := "n"
+	if defaultYes {
+		defaultAnswer = "y"
+	}
+	answer, err := p.ask(question+" (y/n)", defaultAnswer)
+	if err != nil {
+		return false, err
+	}
+	return strings.HasPrefix(strings.ToLower(answer), "y"), nil
+}
+
+// InitConfig is the starter config file init writes
+type InitConfig struct {
+	Pairs map[string]Overrides `json:"pairs"`
+}
+
+// Walk through the profiles, recipients and transfers the API token has access to, and build a starter config file
+// tracking the pairs of the booked transfers
+func runInitWizard(p prompter) (InitConfig, uint64, error) {
+	if hostVar == "" {
+		env, err := p.ask("Environment, "+SANDBOX+" or "+PRODUCTION, SANDBOX)
+		if err != nil {
+			return InitConfig{}, 0, err
+		}
+		if hostVar = getHost(env); hostVar == "" {
+			return InitConfig{}, 0, fmt.Errorf("invalid environment %v, expected %v or %v", env, SANDBOX, PRODUCTION)
+		}
+		envVar = env
+	}
+	if apiTokenVar == "" {
+		token, err := p.ask("Wise API token", "")
+		if err != nil {
+			return InitConfig{}, 0, err
+		}
+		if token == "" {
+			return InitConfig{}, 0, fmt.Errorf("no API token given")
+		}
+		apiTokenVar = token
+	}
+
+	profiles, err := getProfiles()
+	if err != nil {
+		return InitConfig{}, 0, err
+	}
+	if len(profiles) == 0 {
+		return InitConfig{}, 0, fmt.Errorf("the API token has no profile")
+	}
+	fmt.Fprintln(p.out, "\nProfiles:")
+	for i, profile := range profiles {
+		fmt.Fprintf(p.out, "  %v. %v (%v)\n", i+1, profile.Id, profile.Type)
+	}
+	profile := profiles[0]
+	if len(profiles) > 1 {
+		answer, err := p.ask("Profile to track", "1")
+		if err != nil {
+			return InitConfig{}, 0, err
+		}
+		n, err := strconv.Atoi(answer)
+		if err != nil || n < 1 || n > len(profiles) {
+			return InitConfig{}, 0, fmt.Errorf("invalid profile %v, expected 1 to %v", answer, len(profiles))
+		}
+		profile = profiles[n-1]
+	}
+
+	recipients, err := getRecipients(profile.Id)
+	if err != nil {
+		return InitConfig{}, 0, err
+	}
+	fmt.Fprintln(p.out, "\nRecipients:")
+	for _, recipient := range recipients {
+		fmt.Fprintf(p.out, "  %v: %v, %v (%v)\n", recipient.Id, recipient.AccountHolderName, recipient.Currency, recipient.Type)
+	}
+
+	profileId := profileIdVar
+	profileIdVar = strconv.FormatUint(profile.Id, 10)
+	defer func() { profileIdVar = profileId }()
+	transfers, err := listAllTransfers(transferStatusBooked)
+	if err != nil {
+		return InitConfig{}, 0, err
+	}
+	fmt.Fprintln(p.out, "\nBooked transfers:")
+	rates := map[string]float64{}
+	for _, transfer := range transfers {
+		pair := pairKey(transfer.SourceCurrency, transfer.TargetCurrency)
+		fmt.Fprintf(p.out, "  %v: {%v} --> {%v} at %v, %v %v to recipient %v\n", transfer.Id, transfer.SourceCurrency,
+			transfer.TargetCurrency, formatRate(transfer.Rate), formatAmount(transfer.SourceAmount, transfer.SourceCurrency),
+			transfer.SourceCurrency, transfer.TargetAccount)
+		if transfer.Rate > rates[pair] {
+			rates[pair] = transfer.Rate
+		}
+	}
+	pairs := make([]string, 0, len(rates))
+	for pair := range rates {
+		pairs = append(pairs, pair)
+	}
+	sort.Strings(pairs)
+	if len(pairs) == 0 {
+		fmt.Fprintln(p.out, "  none, book a first transfer with: transferwisely transfers create")
+	}
+
+	config := InitConfig{Pairs: map[string]Overrides{}}
+	for _, pair := range pairs {
+		track, err := p.confirm(fmt.Sprintf("\nTrack %v", pair), true)
+		if err != nil {
+			return InitConfig{}, 0, err
+		}
+		if !track {
+			continue
+		}
+		answer, err := p.ask(fmt.Sprintf("Margin of %v, re-booking once the live rate beats the booked one by it", pair),
+			strconv.FormatFloat(initMargin(rates[pair]), 'f', -1, 64))
+		if err != nil {
+			return InitConfig{}, 0, err
+		}
+		margin, err := parseDecimal(answer)
+		if err != nil || margin.Sign() < 0 {
+			return InitConfig{}, 0, fmt.Errorf("invalid margin %v", answer)
+		}
+		interval := uint64(initInterval)
+		id := profile.Id
+		config.Pairs[pair] = Overrides{Margin: &margin, Interval: &interval, Profile: &id}
+	}
+	return config, profile.Id, nil
+}
+
+func runInitCommand(args []string) error {
+	flags := flag.NewFlagSet("init", flag.ContinueOnError)
+	out := flags.String("out", configFileVar, "config file to write, defaults to CONFIG_FILE or "+initConfigFile)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *out == "" {
+		*out = initConfigFile
+	}
+
+	p := prompter{in: bufio.NewReader(os.Stdin), out: os.Stdout}
+	config, profileId, err := runInitWizard(p)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(*out); err == nil {
+		overwrite, err := p.confirm(fmt.Sprintf("\n%v exists, overwrite it", *out), false)
+		if err != nil || !overwrite {
+			return err
+		}
+	}
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(*out, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("error writing %v: %v", *out, err)
+	}
+
+	fmt.Printf("\n%v written, start the batch with:\n  ENV=%v API_TOKEN=<YOUR API TOKEN> PROFILE_ID=%v CONFIG_FILE=%v transferwisely\n",
+		*out, envVar, profileId, *out)
+	return nil
+}
+
+func init() {
+	registerCommand("init", Command{
+		Usage: "init [--out <file>]                          write a starter config file from your profiles and transfers",
+		Run:   runInitCommand,
+	})
+}
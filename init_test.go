@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"transferwisely/mocks"
+)
+
+func TestInitMargin(t *testing.T) {
+	assert.Equal(t, 0.21, initMargin(105))
+	assert.Equal(t, 0.0012, initMargin(0.6))
+	assert.Equal(t, 0.0, initMargin(0))
+}
+
+func TestRunInitWizard(t *testing.T) {
+	defer func(env string, host string, token string, profileId string) {
+		envVar, hostVar, apiTokenVar, profileIdVar = env, host, token, profileId
+	}(envVar, hostVar, apiTokenVar, profileIdVar)
+	hostVar, apiTokenVar, profileIdVar = "", "", ""
+	mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+		body := `[]`
+		switch {
+		case strings.HasSuffix(req.URL.Path, profilesAPIPath):
+			body = `[{"id": 1, "type": "personal"}, {"id": 2, "type": "business"}]`
+		case strings.HasSuffix(req.URL.Path, recipientsAPIPath):
+			body = `[{"id": 10, "currency": "INR", "accountHolderName": "John Doe", "type": "indian"}]`
+		case strings.Contains(req.URL.Path, "transfers") && req.URL.Query().Get("offset") == "0":
+			body = `[{"id": 100, "rate": 105, "sourceCurrency": "GBP", "targetCurrency": "INR", "sourceValue": 1000,
+				"targetAccount": 10, "status": "incoming_payment_waiting"},
+				{"id": 101, "rate": 0.6, "sourceCurrency": "JPY", "targetCurrency": "INR", "sourceValue": 50000,
+				"targetAccount": 10, "status": "incoming_payment_waiting"}]`
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(body))}, nil
+	}
+
+	var out bytes.Buffer
+	answers := "sandbox\ntoken\n2\ny\n0.3\nn\n"
+	config, profileId, err := runInitWizard(prompter{in: bufio.NewReader(strings.NewReader(answers)), out: &out})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), profileId)
+	assert.Equal(t, []string{"GBP-INR"}, keysOf(config.Pairs))
+	assert.Equal(t, decimalOf(0.3), *config.Pairs["GBP-INR"].Margin)
+	assert.Equal(t, uint64(initInterval), *config.Pairs["GBP-INR"].Interval)
+	assert.Equal(t, uint64(2), *config.Pairs["GBP-INR"].Profile)
+	assert.Equal(t, "", profileIdVar)
+	assert.Contains(t, out.String(), "10: John Doe, INR (indian)")
+	assert.Contains(t, out.String(), "Margin of GBP-INR, re-booking once the live rate beats the booked one by it [0.21]")
+
+	_, _, err = runInitWizard(prompter{in: bufio.NewReader(strings.NewReader("5\n")), out: &out})
+	assert.EqualError(t, err, "invalid profile 5, expected 1 to 2")
+}
+
+func keysOf(pairs map[string]Overrides) []string {
+	var keys []string
+	for key := range pairs {
+		keys = append(keys, key)
+	}
+	return keys
+}
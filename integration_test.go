@@ -0,0 +1,233 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"transferwisely/wisemock"
+)
+
+// Point the batch at a mock transferwise API playing the scenario, everything else going to a temporary state
+func startMockForTest(t *testing.T, scenario wisemock.Scenario) *wisemock.Server {
+	mock, err := wisemock.New(scenario)
+	assert.NoError(t, err)
+	server := httptest.NewTLSServer(mock)
+
+	host, token, client, stateFile, margin, notifiers := hostVar, apiTokenVar, Client, stateFileVar, marginVar, Notifiers
+	notifyRateLimit := notifyRateLimitVar
+	t.Cleanup(func() {
+		server.Close()
+		hostVar, apiTokenVar, Client, stateFileVar, marginVar, Notifiers = host, token, client, stateFile, margin, notifiers
+		notifyRateLimitVar = notifyRateLimit
+		pairChecks.Lock()
+		pairChecks.checkedAt = map[pairCheck]time.Time{}
+		pairChecks.Unlock()
+	})
+	hostVar, apiTokenVar, Client = server.Listener.Addr().String(), "token", server.Client()
+	dir, _ := ioutil.TempDir("", "transferwisely")
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	stateFileVar = filepath.Join(dir, "state.json")
+	marginVar, Notifiers, notifyRateLimitVar = "0.5", []Notifier{&fakeNotifier{}}, "0"
+	return mock
+}
+
+// Run a check as if its interval passed since the last one
+func runDueCheck() CheckResult {
+	pairChecks.Lock()
+	pairChecks.checkedAt = map[pairCheck]time.Time{}
+	pairChecks.Unlock()
+	return runCheck()
+}
+
+func TestIntegrationRebookWhenRateRises(t *testing.T) {
+	scenario := wisemock.DefaultScenario()
+	scenario.Rates = map[string][]float64{"GBP-INR": {100.2, 101}}
+	mock := startMockForTest(t, scenario)
+
+	check := runDueCheck()
+	assert.Equal(t, checkActionNoAction, check.Action, check.Error)
+	assert.Equal(t, 100.2, check.LiveRate)
+
+	check = runDueCheck()
+	assert.Equal(t, checkActionRebooked, check.Action, check.Error)
+	assert.Equal(t, 101.0, check.NewTransfer.Rate)
+	assert.Equal(t, decimalOf(1000.0), check.NewTransfer.SourceAmount)
+
+	transfers := mock.Transfers()
+	assert.Len(t, transfers, 2)
+	assert.Equal(t, "cancelled", transfers[0].Status)
+	assert.Equal(t, transferStatusBooked, transfers[1].Status)
+	assert.Equal(t, 101000.0, transfers[1].TargetValue)
+
+	events := Notifiers[0].(*fakeNotifier).events
+	assert.Len(t, events, 1)
+	assert.Contains(t, events[0].Text, "Old vs new:")
+	assert.Contains(t, events[0].Text, "Net gain: +1000.00 INR for the recipient")
+
+	state, err := loadState()
+	assert.NoError(t, err)
+	assert.Nil(t, state.PendingRebook)
+	assert.Len(t, state.RebookHistory, 1)
+}
+
+func TestIntegrationQuoteExpired(t *testing.T) {
+	scenario := wisemock.DefaultScenario()
+	scenario.Rates = map[string][]float64{"GBP-INR": {101}}
+	scenario.QuoteTTL = "-1m"
+	mock := startMockForTest(t, scenario)
+
+	check := runDueCheck()
+	assert.Equal(t, checkActionError, check.Action)
+	assert.Contains(t, check.Error, "QUOTE_EXPIRED")
+
+	transfers := mock.Transfers()
+	assert.Len(t, transfers, 1)
+	assert.Equal(t, transferStatusBooked, transfers[0].Status, "the booked transfer is kept")
+}
+
+func TestIntegrationRateLimited(t *testing.T) {
+	scenario := wisemock.DefaultScenario()
+	scenario.Rates = map[string][]float64{"GBP-INR": {100.2}}
+	scenario.Throttle = []int{1}
+	startMockForTest(t, scenario)
+
+	check := runDueCheck()
+	assert.Equal(t, checkActionError, check.Action)
+	assert.Contains(t, check.Error, "429")
+
+	check = runDueCheck()
+	assert.Equal(t, checkActionNoAction, check.Action, check.Error)
+}
+
+func TestIntegrationSeveralPairs(t *testing.T) {
+	scenario := wisemock.DefaultScenario()
+	scenario.Transfers = append(scenario.Transfers, wisemock.Transfer{Id: 2, Profile: 1, TargetAccount: 2, Rate: 90,
+		Status: transferStatusBooked, SourceCurrency: "USD", TargetCurrency: "JPY", SourceValue: 500, TargetValue: 45000})
+	scenario.Rates = map[string][]float64{"GBP-INR": {101}, "USD-JPY": {90.2}}
+	mock := startMockForTest(t, scenario)
+
+	check := runDueCheck()
+	assert.Equal(t, checkActionRebooked, check.Action, "the most significant action of the pairs")
+	assert.Len(t, check.Pairs, 2)
+	assert.Equal(t, "GBP", check.Pairs[0].Transfer.SourceCurrency)
+	assert.Equal(t, checkActionRebooked, check.Pairs[0].Action, check.Pairs[0].Error)
+	assert.Equal(t, "USD", check.Pairs[1].Transfer.SourceCurrency)
+	assert.Equal(t, checkActionNoAction, check.Pairs[1].Action, check.Pairs[1].Error)
+	assert.Equal(t, 90.2, check.Pairs[1].LiveRate)
+
+	transfers := mock.Transfers()
+	assert.Len(t, transfers, 3)
+	assert.Equal(t, transferStatusBooked, transfers[1].Status, "the other pair's transfer is left alone")
+}
+
+func TestIntegrationReplay(t *testing.T) {
+	scenario := wisemock.DefaultScenario()
+	scenario.Rates = map[string][]float64{"GBP-INR": {100.2, 101}}
+	startMockForTest(t, scenario)
+	dir, _ := ioutil.TempDir("", "transferwisely")
+	defer os.RemoveAll(dir)
+	cassette := filepath.Join(dir, "cassette.json")
+	startRecording(cassette)
+	assert.Equal(t, checkActionNoAction, runDueCheck().Action)
+	assert.Equal(t, checkActionRebooked, runDueCheck().Action)
+
+	data, err := ioutil.ReadFile(cassette)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), apiTokenVar)
+
+	// replay the same checks offline, from a fresh state
+	hostVar, stateFileVar = "", filepath.Join(dir, "state.json")
+	assert.NoError(t, startReplay(cassette))
+	check := runDueCheck()
+	assert.Equal(t, checkActionNoAction, check.Action, check.Error)
+	assert.Equal(t, 100.2, check.LiveRate)
+	check = runDueCheck()
+	assert.Equal(t, checkActionRebooked, check.Action, check.Error)
+	assert.Equal(t, 101.0, check.NewTransfer.Rate)
+}
+
+func TestIntegrationFundedDuringRebook(t *testing.T) {
+	scenario := wisemock.DefaultScenario()
+	scenario.Rates = map[string][]float64{"GBP-INR": {101}}
+	scenario.FundOnCreate = []uint64{1}
+	mock := startMockForTest(t, scenario)
+
+	check := runDueCheck()
+	assert.Equal(t, checkActionError, check.Action)
+	assert.Contains(t, check.Error, errTransferFunded.Error())
+
+	transfers := mock.Transfers()
+	assert.Len(t, transfers, 2)
+	assert.Equal(t, "processing", transfers[0].Status, "the funded transfer isn't cancelled")
+	assert.Equal(t, "cancelled", transfers[1].Status, "the re-booking's transfer is cancelled instead")
+
+	fake := Notifiers[0].(*fakeNotifier)
+	assert.Equal(t, EventCancelRefused, fake.events[0].Kind)
+	assert.Contains(t, fake.events[0].Text, "processing")
+	assert.Equal(t, EventManualAction, fake.events[1].Kind)
+	assert.Contains(t, fake.events[1].Text, fmt.Sprintf("after transfer %v was booked", transfers[1].Id))
+
+	state, _ := loadState()
+	assert.Len(t, state.Rebooks, 1, "counted by the guardrails")
+	assert.Len(t, state.RebookHistory, 1)
+	assert.Equal(t, transfers[1].Id, state.RebookHistory[0].NewTransferId)
+}
+
+func TestIntegrationPinnedTransfer(t *testing.T) {
+	defer func(c Config) { config.current = c }(config.current)
+	config.current = Config{Transfers: map[string]Overrides{"1": {Pinned: true}}}
+	scenario := wisemock.DefaultScenario()
+	scenario.Transfers = append(scenario.Transfers, wisemock.Transfer{Id: 2, Profile: 1, TargetAccount: 1, Rate: 99,
+		Status: transferStatusBooked, SourceCurrency: "GBP", TargetCurrency: "INR", SourceValue: 500, TargetValue: 49500})
+	scenario.Rates = map[string][]float64{"GBP-INR": {100}}
+	mock := startMockForTest(t, scenario)
+
+	check := runDueCheck()
+	assert.Equal(t, checkActionRebooked, check.Action, check.Error)
+	assert.Equal(t, uint64(2), check.Transfer.Id, "the pinned transfer isn't tracked, the other one of the pair is")
+
+	transfers := mock.Transfers()
+	assert.Equal(t, transferStatusBooked, transfers[0].Status, "the pinned transfer is left alone")
+	assert.Equal(t, "cancelled", transfers[1].Status)
+
+	_, err := createTransferFromQuote(Transfer{Id: 1}, QuoteDetail{})
+	assert.True(t, errors.Is(err, errTransferPinned))
+}
+
+func TestIntegrationStartupReport(t *testing.T) {
+	defer func(c Config) { config.current = c }(config.current)
+	defer func(v string) { startupReportNotifyVar = v }(startupReportNotifyVar)
+	config.current = Config{Transfers: map[string]Overrides{"3": {Pinned: true}}}
+	startupReportNotifyVar = "true"
+	scenario := wisemock.DefaultScenario()
+	scenario.Transfers = append(scenario.Transfers,
+		wisemock.Transfer{Id: 2, Profile: 1, TargetAccount: 1, Rate: 99, Status: transferStatusBooked,
+			SourceCurrency: "GBP", TargetCurrency: "INR", SourceValue: 500, TargetValue: 49500},
+		wisemock.Transfer{Id: 3, Profile: 1, TargetAccount: 1, Rate: 102, Status: transferStatusBooked,
+			SourceCurrency: "GBP", TargetCurrency: "INR", SourceValue: 200, TargetValue: 20400})
+	scenario.Rates = map[string][]float64{"GBP-INR": {100.2}}
+	startMockForTest(t, scenario)
+
+	report, err := buildStartupReport(time.Now().UTC())
+	assert.NoError(t, err)
+	assert.Len(t, report.Transfers, 3)
+	roles := map[uint64]string{}
+	for _, entry := range report.Transfers {
+		roles[entry.Transfer.Id] = entry.Role
+		assert.Equal(t, 100.2, entry.LiveRate)
+		assert.NotEmpty(t, entry.Transfer.RateExpirationTime, "the quote is fetched")
+	}
+	assert.Equal(t, map[uint64]string{1: startupTracked, 2: startupSpare, 3: startupPinned}, roles)
+
+	reportStartup(time.Now().UTC())
+	fake := Notifiers[0].(*fakeNotifier)
+	assert.Len(t, fake.events, 1)
+	assert.Equal(t, EventStartupReport, fake.events[0].Kind)
+	assert.Contains(t, fake.events[0].Text, "Pinned transfer 3: {GBP} --> {INR} | Booked Rate: 102 | Live Rate: 100.2 | Re-books at: 102.5")
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LEADER_ELECTION values
+const leaderElectionKubernetes = "kubernetes"
+
+// in-cluster service account files
+const (
+	serviceAccountDir       = "/var/run/secrets/kubernetes.io/serviceaccount/"
+	serviceAccountToken     = serviceAccountDir + "token"
+	serviceAccountCA        = serviceAccountDir + "ca.crt"
+	serviceAccountNamespace = serviceAccountDir + "namespace"
+)
+
+// lease API path and the format of its micro time fields
+const (
+	leasesAPIPath   = "/apis/coordination.k8s.io/v1/namespaces/%v/leases"
+	leaseTimeLayout = "2006-01-02T15:04:05.000000Z07:00"
+)
+
+// Kubernetes API client, only used for leader election
+var kubeClient HTTPClient = &http.Client{Timeout: httpClientTimeout}
+
+// only the leader checks, re-books and notifies, every replica being the leader without leader election
+var leadership = struct {
+	sync.Mutex
+	enabled bool
+	leader  bool
+}{}
+
+// Lease is a coordination.k8s.io/v1 Lease
+type Lease struct {
+	APIVersion string        `json:"apiVersion"`
+	Kind       string        `json:"kind"`
+	Metadata   LeaseMetadata `json:"metadata"`
+	Spec       LeaseSpec     `json:"spec"`
+}
+
+type LeaseMetadata struct {
+	Name            string `json:"name"`
+	Namespace       string `json:"namespace"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+}
+
+type LeaseSpec struct {
+	HolderIdentity       string `json:"holderIdentity"`
+	LeaseDurationSeconds int    `json:"leaseDurationSeconds"`
+	AcquireTime          string `json:"acquireTime,omitempty"`
+	RenewTime            string `json:"renewTime,omitempty"`
+	LeaseTransitions     int    `json:"leaseTransitions"`
+}
+
+// kubernetesLease elects the leader among the replicas by holding a Lease, like the Kubernetes controllers do
+type kubernetesLease struct {
+	apiURL    string
+	token     string
+	namespace string
+	name      string
+	identity  string
+	duration  time.Duration
+}
+
+func isLeader() bool {
+	leadership.Lock()
+	defer leadership.Unlock()
+	return !leadership.enabled || leadership.leader
+}
+
+func setLeader(leader bool) {
+	leadership.Lock()
+	defer leadership.Unlock()
+	if leadership.leader != leader {
+		if leader {
+			log.Println("|| BECAME THE LEADER, CHECKING AND RE-BOOKING ||")
+		} else {
+			log.Println("|| NOT THE LEADER, STANDING BY ||")
+		}
+	}
+	leadership.enabled, leadership.leader = true, leader
+}
+
+// Start competing for the lease in the background when LEADER_ELECTION is enabled, once the first attempt tells
+// whether this replica is the leader
+func startLeaderElection() error {
+	switch leaderElectionVar {
+	case "":
+		return nil
+	case leaderElectionKubernetes:
+	default:
+		return fmt.Errorf("invalid value for LEADER_ELECTION: %v, must be %v", leaderElectionVar, leaderElectionKubernetes)
+	}
+
+	lease, err := newKubernetesLease()
+	if err != nil {
+		return err
+	}
+	log.Printf("|| LEADER ELECTION || Lease: %v/%v | Identity: %v ||", lease.namespace, lease.name, lease.identity)
+	elect := func() {
+		leader, err := lease.tryAcquire(time.Now().UTC())
+		if err != nil {
+			log.Printf("leaderElection: %v", err)
+		}
+		setLeader(leader)
+	}
+	elect()
+	go func() {
+		// renew well before the lease expires
+		for range time.Tick(lease.duration / 3) {
+			elect()
+		}
+	}()
+	return nil
+}
+
+func getLeaseDuration() (time.Duration, error) {
+	seconds, err := strconv.ParseUint(leaseDurationVar, 10, 64)
+	if err != nil || seconds == 0 {
+		return 0, fmt.Errorf("invalid value for LEASE_DURATION: %v", leaseDurationVar)
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// Lease of the in-cluster service account, in LEASE_NAMESPACE or the pod's namespace, held under POD_NAME or the hostname
+func newKubernetesLease() (*kubernetesLease, error) {
+	duration, err := getLeaseDuration()
+	if err != nil {
+		return nil, err
+	}
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("leader election: not running in a kubernetes cluster")
+	}
+	token, err := ioutil.ReadFile(serviceAccountToken)
+	if err != nil {
+		return nil, fmt.Errorf("leader election: error reading service account token: %v", err)
+	}
+	ca, err := ioutil.ReadFile(serviceAccountCA)
+	if err != nil {
+		return nil, fmt.Errorf("leader election: error reading service account CA: %v", err)
+	}
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(ca)
+	kubeClient = &http.Client{
+		Timeout:   httpClientTimeout,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}},
+	}
+
+	namespace := leaseNamespaceVar
+	if namespace == "" {
+		data, err := ioutil.ReadFile(serviceAccountNamespace)
+		if err != nil {
+			return nil, fmt.Errorf("leader election: error reading the pod namespace, set LEASE_NAMESPACE: %v", err)
+		}
+		namespace = strings.TrimSpace(string(data))
+	}
+	identity := os.Getenv("POD_NAME")
+	if identity == "" {
+		if identity, err = os.Hostname(); err != nil {
+			return nil, fmt.Errorf("leader election: %v", err)
+		}
+	}
+
+	return &kubernetesLease{
+		apiURL:    "https://" + net.JoinHostPort(host, port),
+		token:     strings.TrimSpace(string(token)),
+		namespace: namespace,
+		name:      leaseNameVar,
+		identity:  identity,
+		duration:  duration,
+	}, nil
+}
+
+// Create, renew or take over the expired lease, reporting whether this replica holds it. Losing the race to
+// another replica updating the lease at the same time isn't an error, just not being the leader
+func (l *kubernetesLease) tryAcquire(now time.Time) (bool, error) {
+	path := fmt.Sprintf(leasesAPIPath, l.namespace)
+	var lease Lease
+	code, err := l.call(http.MethodGet, path+"/"+l.name, nil, &lease)
+	if code == http.StatusNotFound {
+		lease = Lease{
+			APIVersion: "coordination.k8s.io/v1",
+			Kind:       "Lease",
+			Metadata:   LeaseMetadata{Name: l.name, Namespace: l.namespace},
+			Spec:       l.spec(now, 0),
+		}
+		code, err = l.call(http.MethodPost, path, lease, nil)
+		if code == http.StatusConflict {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if lease.Spec.HolderIdentity == l.identity {
+		lease.Spec.RenewTime = now.Format(leaseTimeLayout)
+		lease.Spec.LeaseDurationSeconds = int(l.duration / time.Second)
+	} else if leaseExpired(lease.Spec, now) {
+		lease.Spec = l.spec(now, lease.Spec.LeaseTransitions+1)
+	} else {
+		return false, nil
+	}
+	code, err = l.call(http.MethodPut, path+"/"+l.name, lease, nil)
+	if code == http.StatusConflict {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Spec of the lease acquired by this replica
+func (l *kubernetesLease) spec(now time.Time, transitions int) LeaseSpec {
+	return LeaseSpec{
+		HolderIdentity:       l.identity,
+		LeaseDurationSeconds: int(l.duration / time.Second),
+		AcquireTime:          now.Format(leaseTimeLayout),
+		RenewTime:            now.Format(leaseTimeLayout),
+		LeaseTransitions:     transitions,
+	}
+}
+
+// Whether the holder failed to renew the lease within its duration, an unheld or unreadable lease being up for grabs
+func leaseExpired(spec LeaseSpec, now time.Time) bool {
+	if spec.HolderIdentity == "" {
+		return true
+	}
+	renewTime, err := time.Parse(leaseTimeLayout, spec.RenewTime)
+	if err != nil {
+		return true
+	}
+	return now.After(renewTime.Add(time.Duration(spec.LeaseDurationSeconds) * time.Second))
+}
+
+func (l *kubernetesLease) call(method string, path string, payload interface{}, result interface{}) (int, error) {
+	var body []byte
+	if payload != nil {
+		body, _ = json.Marshal(payload)
+	}
+	req, err := http.NewRequest(method, l.apiURL+path, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("error creating kubernetes api request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+l.token)
+	req.Header.Set("Content-Type", "application/json")
+	res, err := kubeClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("error calling kubernetes api: %v", err)
+	}
+	data, err := ioutil.ReadAll(res.Body)
+	_ = res.Body.Close()
+	if err != nil {
+		return res.StatusCode, fmt.Errorf("error reading kubernetes api response: %v", err)
+	}
+	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusMultipleChoices {
+		return res.StatusCode, fmt.Errorf("kubernetes api error: %v %v: %v %v", method, path, res.StatusCode, strings.TrimSpace(string(data)))
+	}
+	if result != nil {
+		if err = json.Unmarshal(data, result); err != nil {
+			return res.StatusCode, fmt.Errorf("error decoding kubernetes api response: %v", err)
+		}
+	}
+	return res.StatusCode, nil
+}
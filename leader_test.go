@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+	"transferwisely/mocks"
+)
+
+func TestLeaseTryAcquire(t *testing.T) {
+	defer func(c HTTPClient) { kubeClient = c }(kubeClient)
+	kubeClient = &mocks.Client{}
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	lease := &kubernetesLease{apiURL: "https://10.0.0.1:443", token: "token", namespace: "default", name: "transferwisely",
+		identity: "pod-a", duration: time.Minute}
+
+	var stored *Lease
+	var methods []string
+	putCode := http.StatusOK
+	mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+		methods = append(methods, req.Method)
+		assert.Equal(t, "Bearer token", req.Header.Get("Authorization"))
+		switch req.Method {
+		case http.MethodGet:
+			assert.Equal(t, "https://10.0.0.1:443/apis/coordination.k8s.io/v1/namespaces/default/leases/transferwisely", req.URL.String())
+			if stored == nil {
+				return &http.Response{StatusCode: http.StatusNotFound, Body: ioutil.NopCloser(strings.NewReader("{}"))}, nil
+			}
+			data, _ := json.Marshal(stored)
+			return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(string(data)))}, nil
+		default:
+			if req.Method == http.MethodPut && putCode != http.StatusOK {
+				return &http.Response{StatusCode: putCode, Body: ioutil.NopCloser(strings.NewReader("{}"))}, nil
+			}
+			var body Lease
+			data, _ := ioutil.ReadAll(req.Body)
+			_ = json.Unmarshal(data, &body)
+			stored = &body
+			return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(string(data)))}, nil
+		}
+	}
+
+	// no lease yet, create it
+	leader, err := lease.tryAcquire(now)
+	assert.NoError(t, err)
+	assert.True(t, leader)
+	assert.Equal(t, []string{http.MethodGet, http.MethodPost}, methods)
+	assert.Equal(t, "pod-a", stored.Spec.HolderIdentity)
+	assert.Equal(t, 60, stored.Spec.LeaseDurationSeconds)
+
+	// held by this replica, renew it
+	methods = nil
+	leader, err = lease.tryAcquire(now.Add(20 * time.Second))
+	assert.NoError(t, err)
+	assert.True(t, leader)
+	assert.Equal(t, []string{http.MethodGet, http.MethodPut}, methods)
+	assert.Equal(t, now.Add(20*time.Second).Format(leaseTimeLayout), stored.Spec.RenewTime)
+	assert.Equal(t, now.Format(leaseTimeLayout), stored.Spec.AcquireTime)
+
+	// held by another replica renewing it, stand by
+	other := &kubernetesLease{apiURL: lease.apiURL, token: "token", namespace: "default", name: "transferwisely",
+		identity: "pod-b", duration: time.Minute}
+	methods = nil
+	leader, err = other.tryAcquire(now.Add(30 * time.Second))
+	assert.NoError(t, err)
+	assert.False(t, leader)
+	assert.Equal(t, []string{http.MethodGet}, methods)
+
+	// the holder didn't renew it in time, take it over
+	leader, err = other.tryAcquire(now.Add(2 * time.Minute))
+	assert.NoError(t, err)
+	assert.True(t, leader)
+	assert.Equal(t, "pod-b", stored.Spec.HolderIdentity)
+	assert.Equal(t, 1, stored.Spec.LeaseTransitions)
+
+	// another replica updated it at the same time
+	putCode = http.StatusConflict
+	leader, err = other.tryAcquire(now.Add(2*time.Minute + 20*time.Second))
+	assert.NoError(t, err)
+	assert.False(t, leader)
+
+	putCode = http.StatusForbidden
+	leader, err = other.tryAcquire(now.Add(2*time.Minute + 40*time.Second))
+	assert.Error(t, err)
+	assert.False(t, leader)
+}
+
+func TestLeaseExpired(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	spec := LeaseSpec{HolderIdentity: "pod-a", LeaseDurationSeconds: 60, RenewTime: now.Format(leaseTimeLayout)}
+
+	assert.False(t, leaseExpired(spec, now.Add(59*time.Second)))
+	assert.True(t, leaseExpired(spec, now.Add(61*time.Second)))
+	assert.True(t, leaseExpired(LeaseSpec{}, now))
+	assert.True(t, leaseExpired(LeaseSpec{HolderIdentity: "pod-a", RenewTime: "yesterday"}, now))
+}
+
+func TestIsLeader(t *testing.T) {
+	defer func(enabled bool, leader bool) {
+		leadership.enabled, leadership.leader = enabled, leader
+	}(leadership.enabled, leadership.leader)
+
+	leadership.enabled, leadership.leader = false, false
+	assert.True(t, isLeader())
+
+	setLeader(false)
+	assert.False(t, isLeader())
+	setLeader(true)
+	assert.True(t, isLeader())
+}
+
+func TestGetLeaseDuration(t *testing.T) {
+	defer func(v string) { leaseDurationVar = v }(leaseDurationVar)
+
+	leaseDurationVar = "15"
+	duration, err := getLeaseDuration()
+	assert.NoError(t, err)
+	assert.Equal(t, 15*time.Second, duration)
+
+	for _, value := range []string{"0", "-1", "1m"} {
+		leaseDurationVar = value
+		_, err = getLeaseDuration()
+		assert.Error(t, err, value)
+	}
+}
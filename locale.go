@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// decimals rates are shown with at most, beyond the 4 to 6 transferwise quotes and the float noise of computed ones
+const rateDecimals = 6
+
+// numberFormat is how a locale writes numbers: its thousands separator, its decimal separator and whether digits are
+// grouped the Indian way, 12,34,567.89
+type numberFormat struct {
+	group   string
+	decimal string
+	indian  bool
+}
+
+// number formats of the LOCALE values, looked up by tag like de-ch, then by language like de. French groups digits
+// with a narrow no-break space, the other space grouping locales with a no-break space
+var numberFormats = map[string]numberFormat{
+	"en":    {group: ",", decimal: "."},
+	"ja":    {group: ",", decimal: "."},
+	"zh":    {group: ",", decimal: "."},
+	"ko":    {group: ",", decimal: "."},
+	"en-in": {group: ",", decimal: ".", indian: true},
+	"hi":    {group: ",", decimal: ".", indian: true},
+	"de":    {group: ".", decimal: ","},
+	"es":    {group: ".", decimal: ","},
+	"it":    {group: ".", decimal: ","},
+	"nl":    {group: ".", decimal: ","},
+	"pt":    {group: ".", decimal: ","},
+	"da":    {group: ".", decimal: ","},
+	"id":    {group: ".", decimal: ","},
+	"tr":    {group: ".", decimal: ","},
+	"de-ch": {group: "’", decimal: "."},
+	"fr":    {group: "\u202f", decimal: ","},
+	"ru":    {group: "\u00a0", decimal: ","},
+	"pl":    {group: "\u00a0", decimal: ","},
+	"sv":    {group: "\u00a0", decimal: ","},
+	"nb":    {group: "\u00a0", decimal: ","},
+	"fi":    {group: "\u00a0", decimal: ","},
+	"cs":    {group: "\u00a0", decimal: ","},
+	"uk":    {group: "\u00a0", decimal: ","},
+}
+
+// The number format of LOCALE, like en-US, de_DE.UTF-8 or fr, none leaving numbers as they are, e.g. 1000.5
+func getNumberFormat() (*numberFormat, error) {
+	if localeVar == "" {
+		return nil, nil
+	}
+	tag := strings.ToLower(strings.Replace(strings.SplitN(localeVar, ".", 2)[0], "_", "-", -1))
+	if format, ok := numberFormats[tag]; ok {
+		return &format, nil
+	}
+	if format, ok := numberFormats[strings.SplitN(tag, "-", 2)[0]]; ok {
+		return &format, nil
+	}
+	return nil, fmt.Errorf("invalid value for LOCALE: %v, unsupported locale", localeVar)
+}
+
+// The plain number, like -1234567.89, written the LOCALE way, like -1.234.567,89
+func localizeNumber(plain string) string {
+	format, err := getNumberFormat()
+	if err != nil || format == nil {
+		return plain
+	}
+
+	sign := ""
+	if strings.HasPrefix(plain, "-") {
+		sign, plain = "-", plain[1:]
+	}
+	parts := strings.SplitN(plain, ".", 2)
+	integer := parts[0]
+
+	var groups []string
+	size := 3
+	for len(integer) > size {
+		groups = append([]string{integer[len(integer)-size:]}, groups...)
+		integer = integer[:len(integer)-size]
+		if format.indian {
+			size = 2
+		}
+	}
+	groups = append([]string{integer}, groups...)
+
+	localized := sign + strings.Join(groups, format.group)
+	if len(parts) == 2 {
+		localized += format.decimal + parts[1]
+	}
+	return localized
+}
+
+// Rate rounded to rateDecimals, trailing zeros dropped, written the LOCALE way, like 1.035 rather than 1.0350000000000001
+func formatRate(rate float64) string {
+	rounded := roundDecimal(toDecimal(rate), rateDecimals).FloatString(rateDecimals)
+	if strings.Contains(rounded, ".") {
+		rounded = strings.TrimRight(strings.TrimRight(rounded, "0"), ".")
+	}
+	return localizeNumber(rounded)
+}
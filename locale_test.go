@@ -0,0 +1,65 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestLocalizeNumber(t *testing.T) {
+	defer func(v string) { localeVar = v }(localeVar)
+
+	localeVar = ""
+	assert.Equal(t, "1234567.89", localizeNumber("1234567.89"))
+
+	localeVar = "en-US"
+	assert.Equal(t, "1,234,567.89", localizeNumber("1234567.89"))
+	assert.Equal(t, "-1,000", localizeNumber("-1000"))
+	assert.Equal(t, "999.5", localizeNumber("999.5"))
+
+	localeVar = "de_DE.UTF-8"
+	assert.Equal(t, "1.234.567,89", localizeNumber("1234567.89"))
+
+	localeVar = "de-CH"
+	assert.Equal(t, "1’234’567.89", localizeNumber("1234567.89"))
+
+	localeVar = "fr"
+	assert.Equal(t, "1\u202f234,5", localizeNumber("1234.5"))
+
+	localeVar = "en_IN"
+	assert.Equal(t, "12,34,567.89", localizeNumber("1234567.89"))
+}
+
+func TestFormatRate(t *testing.T) {
+	defer func(v string) { localeVar = v }(localeVar)
+
+	localeVar = ""
+	assert.Equal(t, "1.035", formatRate(1.0350000000000001))
+	assert.Equal(t, "0.123457", formatRate(0.1234567))
+	assert.Equal(t, "101", formatRate(101))
+
+	localeVar = "de"
+	assert.Equal(t, "1,035", formatRate(1.0350000000000001))
+	assert.Equal(t, "1.234,5", formatRate(1234.5))
+}
+
+func TestFormatAmountLocale(t *testing.T) {
+	defer func(v string) { localeVar = v }(localeVar)
+
+	localeVar = "en"
+	assert.Equal(t, "150,000", formatAmount(decimalOf(150000), "JPY"))
+	assert.Equal(t, "1,234.500", formatAmount(decimalOf(1234.5), "KWD"))
+	assert.Equal(t, "1,000.00", formatAmount(decimalOf(1000), "GBP"))
+}
+
+func TestGetNumberFormat(t *testing.T) {
+	defer func(v string) { localeVar = v }(localeVar)
+
+	localeVar = "xx-YY"
+	_, err := getNumberFormat()
+	assert.EqualError(t, err, "invalid value for LOCALE: xx-YY, unsupported locale")
+
+	localeVar = "pt_BR"
+	format, err := getNumberFormat()
+	assert.NoError(t, err)
+	assert.Equal(t, ",", format.decimal)
+}
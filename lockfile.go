@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// errAlreadyRunning is returned when another instance holds the lock file
+var errAlreadyRunning = errors.New("another transferwisely instance is running")
+
+// InstanceLock keeps a second instance, like a cron check overlapping with the batch, from racing it to re-book the
+// same transfer. The lock is released by the OS when the process exits, even if it crashed
+type InstanceLock struct {
+	file *os.File
+}
+
+// LOCK_FILE, next to STATE_FILE by default as the instances sharing it are the ones that could race
+func getLockFile() string {
+	if lockFileVar != "" {
+		return lockFileVar
+	}
+	return stateFileVar + ".lock"
+}
+
+// Take the lock file, failing with errAlreadyRunning and who holds it when another instance does. Without leader
+// election only, the replicas electing their leader being meant to run side by side
+func acquireInstanceLock(command string) (*InstanceLock, error) {
+	if leaderElectionVar != "" {
+		return nil, nil
+	}
+	path := getLockFile()
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("error opening lock file: %v", err)
+	}
+	locked, err := tryLockFile(file)
+	if err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("error locking %v: %v", path, err)
+	}
+	if !locked {
+		holder, _ := ioutil.ReadAll(file)
+		_ = file.Close()
+		return nil, fmt.Errorf("%w: %v is held by %v, stop it first or wait for it to finish", errAlreadyRunning, path,
+			strings.TrimSpace(string(holder)))
+	}
+
+	holder := fmt.Sprintf("pid %v (%v) since %v\n", os.Getpid(), command, formatTime(time.Now()))
+	if err := file.Truncate(0); err == nil {
+		_, _ = file.WriteAt([]byte(holder), 0)
+	}
+	log.Printf("|| INSTANCE LOCK || %v ||", path)
+	return &InstanceLock{file: file}, nil
+}
+
+// Release the lock, leaving the file in place as removing it would race with an instance opening it
+func (l *InstanceLock) Release() {
+	if l == nil {
+		return
+	}
+	_ = l.file.Truncate(0)
+	_ = unlockFile(l.file)
+	_ = l.file.Close()
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAcquireInstanceLock(t *testing.T) {
+	defer func(file string, lock string) { stateFileVar, lockFileVar = file, lock }(stateFileVar, lockFileVar)
+	dir, _ := ioutil.TempDir("", "transferwisely")
+	defer os.RemoveAll(dir)
+	stateFileVar, lockFileVar = filepath.Join(dir, "state.json"), ""
+	assert.Equal(t, stateFileVar+".lock", getLockFile())
+
+	lock, err := acquireInstanceLock("batch server")
+	assert.NoError(t, err)
+
+	_, err = acquireInstanceLock("check command")
+	assert.True(t, errors.Is(err, errAlreadyRunning), err)
+	assert.Contains(t, err.Error(), "(batch server) since")
+
+	lock.Release()
+	lock, err = acquireInstanceLock("check command")
+	assert.NoError(t, err, "released")
+	lock.Release()
+
+	t.Run("not locked with leader election", func(t *testing.T) {
+		defer func(v string) { leaderElectionVar = v }(leaderElectionVar)
+		leaderElectionVar = leaderElectionKubernetes
+		lock, err := acquireInstanceLock("batch server")
+		assert.NoError(t, err)
+		assert.Nil(t, lock)
+		lock.Release()
+	})
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"log"
+	"os"
+)
+
+// Windows has no flock, the lock is only advisory there and never held
+func tryLockFile(file *os.File) (bool, error) {
+	log.Printf("|| INSTANCE LOCK UNSUPPORTED ON WINDOWS, %v NOT LOCKED ||", file.Name())
+	return true, nil
+}
+
+func unlockFile(file *os.File) error {
+	return nil
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"github.com/jordan-wright/email"
+	"net"
+	"net/smtp"
+	"strings"
+)
+
+// SMTP TLS modes
+const (
+	smtpTLSStartTLS = "starttls"
+	smtpTLSImplicit = "implicit"
+	smtpTLSNone     = "none"
+)
+
+// SMTP auth mechanisms
+const (
+	smtpAuthPlain   = "plain"
+	smtpAuthLogin   = "login"
+	smtpAuthCRAMMD5 = "cram-md5"
+	smtpAuthNone    = "none"
+)
+
+func sendMail(subject string, body []byte, attachments ...Attachment) error {
+	return sendMailTo(getMailRecipients(), subject, body, attachments...)
+}
+
+func sendMailTo(recipients []string, subject string, body []byte, attachments ...Attachment) (err error) {
+	if !isMailConfigured() {
+		return fmt.Errorf("error: env vars TO_MAIL, FROM_MAIL, MAIL_PASS not found")
+	}
+	provider, err := getMailProvider()
+	if err != nil {
+		return err
+	}
+	e := email.NewEmail()
+	e.From = fmt.Sprintf(" Transferwisely <%s>", fromEmailVar)
+	e.To = recipients
+	e.Subject = subject
+	e.HTML = body
+	for _, attachment := range attachments {
+		if _, err = e.Attach(bytes.NewReader(attachment.Content), attachment.Filename, attachment.ContentType); err != nil {
+			return fmt.Errorf("error attaching %v: %v", attachment.Filename, err)
+		}
+	}
+
+	if provider != mailProviderSMTP {
+		return sendMailAPI(provider, e, attachments)
+	}
+
+	auth, err := getSMTPAuth()
+	if err != nil {
+		return err
+	}
+	msg, err := e.Bytes()
+	if err != nil {
+		return fmt.Errorf("error building mail: %v", err)
+	}
+	return sendSMTP(net.JoinHostPort(smtpHostVar, smtpPortVar), auth, fromEmailVar, e.To, msg)
+}
+
+// Mail can be sent without a password only when the SMTP server doesn't require auth, or with the credentials of the
+// API based MAIL_PROVIDER
+func isMailConfigured() bool {
+	if toEmailVar == "" || fromEmailVar == "" {
+		return false
+	}
+	if provider, err := getMailProvider(); err == nil && provider != mailProviderSMTP {
+		return isMailAPIConfigured(provider)
+	}
+	return mailPassVar != "" || strings.ToLower(smtpAuthVar) == smtpAuthNone
+}
+
+// TO_MAIL may hold several comma separated recipients
+func getMailRecipients() []string {
+	return splitMailRecipients(toEmailVar)
+}
+
+func splitMailRecipients(list string) (recipients []string) {
+	for _, recipient := range strings.Split(list, ",") {
+		if recipient = strings.TrimSpace(recipient); recipient != "" {
+			recipients = append(recipients, recipient)
+		}
+	}
+	return
+}
+
+func getSMTPAuth() (smtp.Auth, error) {
+	user := smtpUserVar
+	if user == "" {
+		user = fromEmailVar
+	}
+
+	switch strings.ToLower(smtpAuthVar) {
+	case smtpAuthPlain:
+		return smtp.PlainAuth("", user, mailPassVar, smtpHostVar), nil
+	case smtpAuthLogin:
+		return &loginAuth{username: user, password: mailPassVar}, nil
+	case smtpAuthCRAMMD5:
+		return smtp.CRAMMD5Auth(user, mailPassVar), nil
+	case smtpAuthNone:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("invalid value for SMTP_AUTH: %v", smtpAuthVar)
+	}
+}
+
+func sendSMTP(addr string, auth smtp.Auth, from string, to []string, msg []byte) error {
+	tlsConfig, err := getTLSConfig(smtpHostVar)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(smtpTLSVar) {
+	case smtpTLSImplicit, smtpTLSStartTLS, smtpTLSNone:
+	default:
+		return fmt.Errorf("invalid value for SMTP_TLS: %v", smtpTLSVar)
+	}
+
+	// dialing through the proxy, if any, the same as the HTTP calls
+	conn, err := dialThroughProxy(addr)
+	if err != nil {
+		return fmt.Errorf("error connecting to SMTP server: %v", err)
+	}
+	if strings.ToLower(smtpTLSVar) == smtpTLSImplicit {
+		conn = tls.Client(conn, tlsConfig)
+	}
+	client, err := smtp.NewClient(conn, smtpHostVar)
+	if err != nil {
+		return fmt.Errorf("error connecting to SMTP server: %v", err)
+	}
+	defer client.Close()
+
+	if strings.ToLower(smtpTLSVar) == smtpTLSStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("error: SMTP server doesn't support STARTTLS")
+		}
+		if err = client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("error starting TLS: %v", err)
+		}
+	}
+
+	if auth != nil {
+		if err = client.Auth(auth); err != nil {
+			return fmt.Errorf("error authenticating to SMTP server: %v", err)
+		}
+	}
+	if err = client.Mail(from); err != nil {
+		return fmt.Errorf("error sending mail: %v", err)
+	}
+	for _, recipient := range to {
+		if err = client.Rcpt(recipient); err != nil {
+			return fmt.Errorf("error sending mail to %v: %v", recipient, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("error sending mail: %v", err)
+	}
+	if _, err = w.Write(msg); err != nil {
+		return fmt.Errorf("error sending mail: %v", err)
+	}
+	if err = w.Close(); err != nil {
+		return fmt.Errorf("error sending mail: %v", err)
+	}
+	return client.Quit()
+}
+
+// loginAuth implements the LOGIN auth mechanism still required by some corporate mail servers
+type loginAuth struct {
+	username, password string
+}
+
+func (a *loginAuth) Start(_ *smtp.ServerInfo) (string, []byte, error) {
+	return "LOGIN", []byte{}, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	switch strings.ToLower(strings.TrimSpace(string(fromServer))) {
+	case "username:":
+		return []byte(a.username), nil
+	case "password:":
+		return []byte(a.password), nil
+	default:
+		return nil, errors.New("unexpected LOGIN challenge from SMTP server")
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"transferwisely/mocks"
+)
+
+func TestGetMailRecipients(t *testing.T) {
+	defer func(value string) { toEmailVar = value }(toEmailVar)
+
+	toEmailVar = "me@example.com, finance@example.com,,"
+	assert.Equal(t, []string{"me@example.com", "finance@example.com"}, getMailRecipients())
+}
+
+func TestGetSMTPAuth(t *testing.T) {
+	defer func(value string) { smtpAuthVar = value }(smtpAuthVar)
+
+	for _, mechanism := range []string{smtpAuthPlain, smtpAuthLogin, "CRAM-MD5"} {
+		smtpAuthVar = mechanism
+		auth, err := getSMTPAuth()
+		assert.NoError(t, err)
+		assert.NotNil(t, auth)
+	}
+
+	smtpAuthVar = smtpAuthNone
+	auth, err := getSMTPAuth()
+	assert.NoError(t, err)
+	assert.Nil(t, auth)
+
+	smtpAuthVar = "kerberos"
+	_, err = getSMTPAuth()
+	assert.Error(t, err)
+}
+
+func TestLoginAuth(t *testing.T) {
+	auth := &loginAuth{username: "user", password: "pass"}
+
+	mechanism, _, err := auth.Start(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "LOGIN", mechanism)
+
+	response, err := auth.Next([]byte("Username:"), true)
+	assert.NoError(t, err)
+	assert.Equal(t, "user", string(response))
+
+	response, err = auth.Next([]byte("Password:"), true)
+	assert.NoError(t, err)
+	assert.Equal(t, "pass", string(response))
+
+	_, err = auth.Next([]byte("Token:"), true)
+	assert.Error(t, err)
+}
+
+func TestSendMailProviders(t *testing.T) {
+	defer func(to, from, provider, key, domain string) {
+		toEmailVar, fromEmailVar, mailProviderVar, mailAPIKeyVar, mailgunDomainVar = to, from, provider, key, domain
+	}(toEmailVar, fromEmailVar, mailProviderVar, mailAPIKeyVar, mailgunDomainVar)
+	defer func(region, keyId, secret string) {
+		awsRegionVar, awsAccessKeyIdVar, awsSecretAccessKeyVar = region, keyId, secret
+	}(awsRegionVar, awsAccessKeyIdVar, awsSecretAccessKeyVar)
+	toEmailVar, fromEmailVar, mailAPIKeyVar, mailgunDomainVar = "me@example.com", "bot@example.com", "key-1", "mg.example.com"
+	awsRegionVar, awsAccessKeyIdVar, awsSecretAccessKeyVar = "eu-west-1", "AKID", "secret"
+
+	var req *http.Request
+	var body string
+	mocks.GetDoFunc = func(r *http.Request) (*http.Response, error) {
+		data, _ := ioutil.ReadAll(r.Body)
+		req, body = r, string(data)
+		return &http.Response{StatusCode: http.StatusAccepted, Body: ioutil.NopCloser(strings.NewReader(`{}`))}, nil
+	}
+	receipt := Attachment{Filename: "receipt.pdf", ContentType: "application/pdf", Content: []byte("%PDF-1.4")}
+
+	t.Run("sendgrid", func(t *testing.T) {
+		mailProviderVar = mailProviderSendGrid
+		assert.True(t, isMailConfigured())
+		assert.NoError(t, sendMail("Rebooked", []byte("<p>Rate: 101</p>"), receipt))
+		assert.Equal(t, sendGridSendURL, req.URL.String())
+		assert.Equal(t, "Bearer key-1", req.Header.Get("Authorization"))
+		assert.JSONEq(t, `{"personalizations": [{"to": [{"email": "me@example.com"}]}],
+			"from": {"email": "bot@example.com", "name": "Transferwisely"}, "subject": "Rebooked",
+			"content": [{"type": "text/html", "value": "<p>Rate: 101</p>"}],
+			"attachments": [{"content": "JVBERi0xLjQ=", "filename": "receipt.pdf", "type": "application/pdf"}]}`, body)
+	})
+
+	t.Run("mailgun", func(t *testing.T) {
+		mailProviderVar = mailProviderMailgun
+		assert.NoError(t, sendMail("Rebooked", []byte("<p>Rate: 101</p>")))
+		assert.Equal(t, "https://api.mailgun.net/v3/mg.example.com/messages.mime", req.URL.String())
+		user, pass, _ := req.BasicAuth()
+		assert.Equal(t, "api", user)
+		assert.Equal(t, "key-1", pass)
+		assert.Contains(t, body, "Subject: Rebooked")
+	})
+
+	t.Run("ses", func(t *testing.T) {
+		mailProviderVar = mailProviderSES
+		assert.NoError(t, sendMail("Rebooked", []byte("<p>Rate: 101</p>")))
+		assert.Equal(t, "https://email.eu-west-1.amazonaws.com/v2/email/outbound-emails", req.URL.String())
+		assert.Contains(t, req.Header.Get("Authorization"), "/eu-west-1/ses/aws4_request")
+		assert.Contains(t, body, `"ToAddresses":["me@example.com"]`)
+	})
+
+	t.Run("missing credentials", func(t *testing.T) {
+		mailProviderVar, mailAPIKeyVar = mailProviderSendGrid, ""
+		assert.False(t, isMailConfigured())
+		mailProviderVar = "postmark"
+		_, err := getMailProvider()
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/jordan-wright/email"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// MAIL_PROVIDER values, the API based ones sending through HTTPS where SMTP egress is blocked
+const (
+	mailProviderSMTP     = "smtp"
+	mailProviderSendGrid = "sendgrid"
+	mailProviderMailgun  = "mailgun"
+	mailProviderSES      = "ses"
+)
+
+// mail provider endpoints
+const (
+	sendGridSendURL      = "https://api.sendgrid.com/v3/mail/send"
+	mailgunMessagesPath  = "/v3/%v/messages.mime"
+	sesOutboundEmailsURL = "https://email.%v.amazonaws.com/v2/email/outbound-emails"
+)
+
+// what each API based MAIL_PROVIDER requires
+var mailProviderRequirements = map[string]string{
+	mailProviderSendGrid: "MAIL_API_KEY",
+	mailProviderMailgun:  "MAIL_API_KEY and MAILGUN_DOMAIN",
+	mailProviderSES:      "AWS_REGION, AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY",
+}
+
+func getMailProvider() (string, error) {
+	provider := strings.ToLower(mailProviderVar)
+	switch provider {
+	case mailProviderSMTP, mailProviderSendGrid, mailProviderMailgun, mailProviderSES:
+		return provider, nil
+	default:
+		return "", fmt.Errorf("invalid value for MAIL_PROVIDER: %v, must be %v, %v, %v or %v", mailProviderVar,
+			mailProviderSMTP, mailProviderSendGrid, mailProviderMailgun, mailProviderSES)
+	}
+}
+
+// Whether the credentials of the API based MAIL_PROVIDER are set
+func isMailAPIConfigured(provider string) bool {
+	switch provider {
+	case mailProviderSendGrid:
+		return mailAPIKeyVar != ""
+	case mailProviderMailgun:
+		return mailAPIKeyVar != "" && mailgunDomainVar != ""
+	case mailProviderSES:
+		return awsRegionVar != "" && awsAccessKeyIdVar != "" && awsSecretAccessKeyVar != ""
+	default:
+		return false
+	}
+}
+
+// Send the mail through the API of the provider
+func sendMailAPI(provider string, e *email.Email, attachments []Attachment) error {
+	switch provider {
+	case mailProviderSendGrid:
+		return sendSendGridMail(e, attachments)
+	case mailProviderMailgun:
+		return sendMailgunMail(e)
+	case mailProviderSES:
+		return sendSESMail(e, time.Now().UTC())
+	default:
+		return fmt.Errorf("sendMailAPI: %v has no API", provider)
+	}
+}
+
+type sendGridAddress struct {
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+}
+
+type sendGridContent struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type sendGridAttachment struct {
+	Content  string `json:"content"`
+	Filename string `json:"filename"`
+	Type     string `json:"type,omitempty"`
+}
+
+// sendGridMail is the body of SendGrid's v3 mail send API, which takes the parts of the mail rather than a MIME message
+type sendGridMail struct {
+	Personalizations []struct {
+		To []sendGridAddress `json:"to"`
+	} `json:"personalizations"`
+	From        sendGridAddress      `json:"from"`
+	Subject     string               `json:"subject"`
+	Content     []sendGridContent    `json:"content"`
+	Attachments []sendGridAttachment `json:"attachments,omitempty"`
+}
+
+func sendSendGridMail(e *email.Email, attachments []Attachment) error {
+	var mail sendGridMail
+	mail.Personalizations = make([]struct {
+		To []sendGridAddress `json:"to"`
+	}, 1)
+	for _, recipient := range e.To {
+		mail.Personalizations[0].To = append(mail.Personalizations[0].To, sendGridAddress{Email: recipient})
+	}
+	mail.From = sendGridAddress{Email: fromEmailVar, Name: "Transferwisely"}
+	mail.Subject = e.Subject
+	mail.Content = []sendGridContent{{Type: "text/html", Value: string(e.HTML)}}
+	for _, attachment := range attachments {
+		mail.Attachments = append(mail.Attachments, sendGridAttachment{
+			Content:  base64.StdEncoding.EncodeToString(attachment.Content),
+			Filename: attachment.Filename,
+			Type:     attachment.ContentType,
+		})
+	}
+	body, _ := json.Marshal(mail)
+
+	req, err := http.NewRequest(http.MethodPost, sendGridSendURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating SendGrid request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+mailAPIKeyVar)
+	return doMailRequest(mailProviderSendGrid, req)
+}
+
+// Send the MIME message as is, Mailgun taking the recipients apart
+func sendMailgunMail(e *email.Email) error {
+	msg, err := e.Bytes()
+	if err != nil {
+		return fmt.Errorf("error building mail: %v", err)
+	}
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	_ = form.WriteField("to", strings.Join(e.To, ","))
+	part, err := form.CreateFormFile("message", "message.mime")
+	if err != nil {
+		return fmt.Errorf("error creating Mailgun request: %v", err)
+	}
+	_, _ = part.Write(msg)
+	_ = form.Close()
+
+	url := strings.TrimSuffix(mailgunAPIBaseVar, "/") + fmt.Sprintf(mailgunMessagesPath, mailgunDomainVar)
+	req, err := http.NewRequest(http.MethodPost, url, &body)
+	if err != nil {
+		return fmt.Errorf("error creating Mailgun request: %v", err)
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.SetBasicAuth("api", mailAPIKeyVar)
+	return doMailRequest(mailProviderMailgun, req)
+}
+
+// Send the MIME message with the SES v2 API, signed with the same AWS credentials as the awssm:// secrets
+func sendSESMail(e *email.Email, now time.Time) error {
+	msg, err := e.Bytes()
+	if err != nil {
+		return fmt.Errorf("error building mail: %v", err)
+	}
+	var request struct {
+		FromEmailAddress string
+		Destination      struct{ ToAddresses []string }
+		Content          struct{ Raw struct{ Data []byte } }
+	}
+	request.FromEmailAddress = fromEmailVar
+	request.Destination.ToAddresses = e.To
+	request.Content.Raw.Data = msg
+	body, _ := json.Marshal(request)
+
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf(sesOutboundEmailsURL, awsRegionVar), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating SES request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	signAWSRequest(req, body, "ses", now)
+	return doMailRequest(mailProviderSES, req)
+}
+
+func doMailRequest(provider string, req *http.Request) error {
+	res, err := Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending mail through %v: %v", provider, err)
+	}
+	body, _ := ioutil.ReadAll(res.Body)
+	_ = res.Body.Close()
+	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("error sending mail through %v: %v %v", provider, res.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// name of the mail template used by the events without one of their own
+const genericMailTemplate = "event"
+
+// ReminderMailData is the Data of expiry-reminder events
+type ReminderMailData struct {
+	Transfer Transfer
+	Expiry   string
+	Summary  *RateSummary
+	Forecast *RateForecast
+}
+
+// RebookedMailData is the Data of rebooked events
+type RebookedMailData struct {
+	OldTransfer Transfer
+	NewTransfer Transfer
+	Reason      string
+	Comparison  []ProviderQuote
+	Forecast    *RateForecast
+	Rebook      *RebookComparison
+	Summary     *RateSummary
+}
+
+// ErrorMailData is the Data of error events
+type ErrorMailData struct {
+	Error string
+}
+
+// DigestMailData is the Data of no-action-digest events
+type DigestMailData struct {
+	Checks   int
+	Transfer Transfer
+	MinRate  float64
+	MaxRate  float64
+	LastRate float64
+	Summary  *RateSummary
+}
+
+// ProposalMailData is the Data of proposal events
+type ProposalMailData struct {
+	Proposal Proposal
+	Summary  *RateSummary
+}
+
+// the 7 day rate summary of the rebooked, expiry-reminder, no-action-digest and proposal events
+const rateSummaryMailTemplate = `
+{{- with .Data.Summary}}
+<h4>&#128200; Rates over the last {{.Days}} days</h4>
+<ul> <li> Min: {{.Min}} </li> <li> Max: {{.Max}} </li> <li> Avg: {{printf "%.6f" .Avg}} </li> </ul>
+<pre>{{.Sparkline}}</pre>
+{{- end}}`
+
+// default mail templates by event kind, rendered with the Event, each may define a "subject" template too
+var defaultMailTemplates = map[string]string{
+	string(EventRebooked): `<h4>&#9989; {{.Subject}}</h4>
+<ul>
+<li> Transfer ID: {{.Data.NewTransfer.Id}} </li>
+<li> {{printf "{%v} --> {%v}" .Data.NewTransfer.SourceCurrency .Data.NewTransfer.TargetCurrency}} </li>
+<li> Rate: <b>{{rate .Data.NewTransfer.Rate}}</b> (was {{rate .Data.OldTransfer.Rate}}) </li>
+<li> Amount: {{amount .Data.NewTransfer.SourceAmount .Data.NewTransfer.SourceCurrency}} {{.Data.NewTransfer.SourceCurrency}} </li>
+<li> Cancelled transfer ID: {{.Data.OldTransfer.Id}} </li>
+</ul>
+{{- with .Data.Rebook}}
+<h4>&#9878; Old vs new</h4>
+<table>
+<tr><th></th><th>Old</th><th>New</th></tr>
+<tr><td>Transfer ID</td><td>{{.Old.TransferId}}</td><td>{{.New.TransferId}}</td></tr>
+<tr><td>Rate</td><td>{{rate .Old.Rate}}</td><td><b>{{rate .New.Rate}}</b></td></tr>
+<tr><td>Fee</td><td>{{amount .Old.Fee .SourceCurrency}} {{.SourceCurrency}}</td><td>{{amount .New.Fee .SourceCurrency}} {{.SourceCurrency}}</td></tr>
+<tr><td>You send</td><td>{{amount .Old.SourceAmount .SourceCurrency}} {{.SourceCurrency}}</td><td>{{amount .New.SourceAmount .SourceCurrency}} {{.SourceCurrency}}</td></tr>
+<tr><td>Recipient gets</td><td>{{amount .Old.TargetAmount .TargetCurrency}} {{.TargetCurrency}}</td><td>{{amount .New.TargetAmount .TargetCurrency}} {{.TargetCurrency}}</td></tr>
+<tr><td>Delivery</td><td>{{or .Old.Delivery "unknown"}}</td><td>{{or .New.Delivery "unknown"}}</td></tr>
+</table>
+<p>Net gain: <b>{{.FormattedGain}} {{.TargetCurrency}}</b> for the recipient</p>
+{{- end}}
+{{- with .Data.Comparison}}
+<h4>&#127974; Compared to other providers</h4>
+<table>
+<tr><th>Provider</th><th>Rate</th><th>Fee</th><th>Recipient gets</th></tr>
+{{- range .}}
+<tr><td>{{.Name}}</td><td>{{rate .Rate}}</td><td>{{.Fee}}</td><td>{{amount .ReceivedAmount $.Data.NewTransfer.TargetCurrency}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- with .Data.Forecast}}
+<p>&#128301; {{.Hint}}</p>
+{{- end}}` + rateSummaryMailTemplate,
+	string(EventExpiryReminder): `<h4>&#128184; The following transfer is going to expire on <b>{{.Data.Expiry}}</b></h4>
+<ul>
+<li> Transfer ID: {{.Data.Transfer.Id}} </li>
+<li> {{printf "{%v} --> {%v}" .Data.Transfer.SourceCurrency .Data.Transfer.TargetCurrency}} </li>
+<li> Booked Rate: {{rate .Data.Transfer.Rate}} </li>
+<li> Amount: {{amount .Data.Transfer.SourceAmount .Data.Transfer.SourceCurrency}} {{.Data.Transfer.SourceCurrency}} </li>
+</ul>
+` + rateSummaryMailTemplate + `{{- with .Data.Forecast}}
+<p>&#128301; {{.Hint}}</p>
+{{- end}}`,
+	string(EventError): `<h4>&#9888;&#65039; {{.Subject}}</h4>
+<pre>{{.Data.Error}}</pre>`,
+	string(EventNoActionDigest): `<h4>&#128197; {{.Subject}}</h4>
+<p>{{.Data.Checks}} checks since the last digest, none found a better rate.</p>
+<ul>
+<li> Transfer ID: {{.Data.Transfer.Id}} </li>
+<li> {{printf "{%v} --> {%v}" .Data.Transfer.SourceCurrency .Data.Transfer.TargetCurrency}} </li>
+<li> Booked Rate: {{rate .Data.Transfer.Rate}} </li>
+<li> Live Rate: min {{rate .Data.MinRate}} | max {{rate .Data.MaxRate}} | last {{rate .Data.LastRate}} </li>
+</ul>` + rateSummaryMailTemplate,
+	string(EventProposal): `<h4>&#128270; {{.Subject}}</h4>
+<ul>
+<li> Proposal ID: {{.Data.Proposal.Id}} </li>
+<li> Transfer ID: {{.Data.Proposal.Transfer.Id}} </li>
+<li> {{printf "{%v} --> {%v}" .Data.Proposal.Transfer.SourceCurrency .Data.Proposal.Transfer.TargetCurrency}} </li>
+<li> Quoted Rate: <b>{{rate .Data.Proposal.Quote.Rate}}</b> (booked {{rate .Data.Proposal.Transfer.Rate}}) </li>
+<li> Amount: {{amount .Data.Proposal.Quote.SourceAmount .Data.Proposal.Transfer.SourceCurrency}} {{.Data.Proposal.Transfer.SourceCurrency}} </li>
+<li> Expires: {{time .Data.Proposal.ExpiresAt}} </li>
+</ul>
+<p>Approve with: <code>transferwisely approve {{.Data.Proposal.Id}}</code></p>
+{{- if .ActionURL}}
+<p><a href="{{.ActionURL}}">{{.ActionLabel}}</a></p>
+{{- end}}` + rateSummaryMailTemplate,
+	string(EventTransferCompleted): `<h4>&#127881; {{.Subject}}</h4>
+<ul>
+<li> Transfer ID: {{.Data.Transfer.Id}} </li>
+<li> {{printf "{%v} --> {%v}" .Data.Transfer.SourceCurrency .Data.Transfer.TargetCurrency}} </li>
+<li> Rate: {{rate .Data.Transfer.Rate}} </li>
+</ul>
+<p>The receipt is attached{{with .Data.ReceiptFile}} and saved to {{.}}{{end}}.</p>`,
+	string(EventRateDigest): `<h4>&#128202; {{.Subject}}</h4>
+{{- if .Data.Pairs}}
+<table>
+<tr><th>Pair</th><th>Open</th><th>High</th><th>Low</th><th>Close</th><th>Booked</th><th>Checks</th></tr>
+{{- range .Data.Pairs}}
+<tr><td>{{.Pair}}</td><td>{{.Open}}</td><td>{{.High}}</td><td>{{.Low}}</td><td>{{.Close}}</td><td>{{.Booked}}</td><td>{{.Checks}}</td></tr>
+{{- end}}
+</table>
+{{- else}}
+<p>No tracked pair was checked.</p>
+{{- end}}
+<h4>&#128260; Re-bookings: {{len .Data.Rebooks}}</h4>
+{{- with .Data.Rebooks}}
+<ul>
+{{- range .}}
+<li> {{time .Time}}: transfer {{.OldTransferId}} re-booked as {{.NewTransferId}}, {{rate .OldRate}} --> <b>{{rate .NewRate}}</b> ({{.Reason}}) </li>
+{{- end}}
+</ul>
+{{- end}}
+{{- with .Data.Expirations}}
+<h4>&#9203; Upcoming rate lock expiries</h4>
+<ul>
+{{- range .}}
+<li> Transfer {{.Id}} {{printf "{%v} --> {%v}" .SourceCurrency .TargetCurrency}} at {{rate .Rate}} expires <b>{{expiry .RateExpirationTime}}</b> </li>
+{{- end}}
+</ul>
+{{- end}}`,
+	genericMailTemplate: `<p>{{range $i, $line := lines .Text}}{{if $i}}<br>{{end}}{{$line}}{{end}}</p>
+{{- if .ActionURL}}
+<p><a href="{{.ActionURL}}">{{.ActionLabel}}</a></p>
+{{- end}}`,
+}
+
+var mailTemplateFuncs = template.FuncMap{
+	"lines":  func(text string) []string { return strings.Split(text, "\n") },
+	"amount": formatAmount,
+	"rate":   formatRate,
+	"expiry": formatExpiry,
+	"time":   formatTime,
+}
+
+// Render the mail subject and body of the event with the template of its kind, from TEMPLATE_DIR if overridden there
+func renderMail(event Event) (subject string, body string, err error) {
+	name := string(event.Kind)
+	if _, ok := defaultMailTemplates[name]; !ok || event.Data == nil {
+		name = genericMailTemplate
+	}
+	tmpl, err := getMailTemplate(name)
+	if err != nil {
+		return "", "", err
+	}
+
+	var buf bytes.Buffer
+	if err = tmpl.Execute(&buf, event); err != nil {
+		return "", "", fmt.Errorf("error rendering %v mail template: %v", name, err)
+	}
+	body = buf.String()
+
+	subject = event.Subject
+	if subjectTmpl := tmpl.Lookup("subject"); subjectTmpl != nil {
+		buf.Reset()
+		if err = subjectTmpl.Execute(&buf, event); err != nil {
+			return "", "", fmt.Errorf("error rendering %v mail subject: %v", name, err)
+		}
+		subject = strings.TrimSpace(buf.String())
+	}
+	return subject, body, nil
+}
+
+// Parse the named mail template, read from <TEMPLATE_DIR>/<name>.html when there is one so it can be edited without a restart
+func getMailTemplate(name string) (*template.Template, error) {
+	text := defaultMailTemplates[name]
+	if templateDirVar != "" {
+		data, err := ioutil.ReadFile(filepath.Join(templateDirVar, name+".html"))
+		if err == nil {
+			text = string(data)
+		} else if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("error reading %v mail template: %v", name, err)
+		}
+	}
+
+	tmpl, err := template.New(name).Funcs(mailTemplateFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %v mail template: %v", name, err)
+	}
+	return tmpl, nil
+}
+
+// Make sure every template in TEMPLATE_DIR parses
+func validateMailTemplates() error {
+	for name := range defaultMailTemplates {
+		if _, err := getMailTemplate(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runTemplatesCommand(args []string) error {
+	flags := flag.NewFlagSet("templates", flag.ContinueOnError)
+	dir := flags.String("dir", ".", "directory to write the templates to")
+	if len(args) == 0 || args[0] != "export" {
+		return fmt.Errorf("usage: templates export [--dir <dir>]")
+	}
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(defaultMailTemplates))
+	for name := range defaultMailTemplates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		file := filepath.Join(*dir, name+".html")
+		if err := ioutil.WriteFile(file, []byte(defaultMailTemplates[name]+"\n"), 0644); err != nil {
+			return fmt.Errorf("error writing %v: %v", file, err)
+		}
+		fmt.Println(file)
+	}
+	return nil
+}
+
+func init() {
+	registerCommand("templates", Command{
+		Usage: "templates export [--dir <dir>]               write the default mail templates to customize",
+		Run:   runTemplatesCommand,
+	})
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRenderMail(t *testing.T) {
+	event := Event{
+		Kind:    EventRebooked,
+		Subject: rebookedSubject,
+		Text:    "text",
+		Data: RebookedMailData{
+			OldTransfer: Transfer{Id: 1, Rate: 0.69},
+			NewTransfer: Transfer{Id: 2, Rate: 0.7, SourceAmount: decimalOf(1000), SourceCurrency: "JPY", TargetCurrency: "INR"},
+		},
+	}
+	subject, body, err := renderMail(event)
+	assert.NoError(t, err)
+	assert.Equal(t, rebookedSubject, subject)
+	assert.Contains(t, body, "{JPY} --&gt; {INR}")
+	assert.Contains(t, body, "Rate: <b>0.7</b> (was 0.69)")
+	assert.Contains(t, body, "Cancelled transfer ID: 1")
+
+	t.Run("generic template", func(t *testing.T) {
+		_, body, err := renderMail(Event{Kind: EventProposal, Text: "line <1>\nline 2", ActionLabel: "Approve",
+			ActionURL: "https://example.com/approve"})
+		assert.NoError(t, err)
+		assert.Contains(t, body, "line &lt;1&gt;<br>line 2")
+		assert.Contains(t, body, `<a href="https://example.com/approve">Approve</a>`)
+	})
+
+	t.Run("reminder with rate summary", func(t *testing.T) {
+		_, body, err := renderMail(Event{Kind: EventExpiryReminder, Data: ReminderMailData{
+			Transfer: Transfer{Id: 1}, Expiry: "2030-01-01 00:00:00 UTC", Summary: &RateSummary{Days: 7, Avg: 0.5}}})
+		assert.NoError(t, err)
+		assert.Contains(t, body, "<b>2030-01-01 00:00:00 UTC</b>")
+		assert.Contains(t, body, "Avg: 0.500000")
+	})
+
+	t.Run("template dir override", func(t *testing.T) {
+		dir, _ := ioutil.TempDir("", "transferwisely")
+		defer os.RemoveAll(dir)
+		defer func(v string) { templateDirVar = v }(templateDirVar)
+		templateDirVar = dir
+
+		override := `{{define "subject"}}Neue Überweisung zu {{.Data.NewTransfer.Rate}}{{end}}<p>Kurs {{.Data.NewTransfer.Rate}}</p>`
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "rebooked.html"), []byte(override), 0644))
+		subject, body, err := renderMail(event)
+		assert.NoError(t, err)
+		assert.Equal(t, "Neue Überweisung zu 0.7", subject)
+		assert.Equal(t, "<p>Kurs 0.7</p>", body)
+		assert.NoError(t, validateMailTemplates())
+
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "error.html"), []byte("{{.Data.Error"), 0644))
+		assert.Error(t, validateMailTemplates())
+	})
+}
+
+func TestDefaultMailTemplates(t *testing.T) {
+	assert.NoError(t, validateMailTemplates())
+}
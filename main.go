@@ -1,29 +1,218 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"github.com/go-co-op/gocron"
 	"net/http"
+	"os"
 	"strconv"
 	"time"
 )
 
+// scheduled checkAndProcess job, used to show the next check time
+var checkJob *gocron.Job
+
 func main() {
-	_, err := strconv.ParseUint(intervalVar, 10, 64)
+	err := configureHTTPClients()
+	if err != nil {
+		fmt.Printf("Invalid HTTP client config: %v", err)
+		return
+	}
+
+	err = resolveSecrets()
+	if err != nil {
+		fmt.Printf("Invalid secret: %v", err)
+		return
+	}
+
+	err = configureAPIPaths()
+	if err != nil {
+		fmt.Printf("Invalid API paths: %v", err)
+		return
+	}
+	err = configureTimezone()
+	if err != nil {
+		fmt.Printf("Invalid timezone: %v", err)
+		return
+	}
+
+	flags := flag.NewFlagSet("transferwisely", flag.ExitOnError)
+	flags.StringVar(&templateDirVar, "template-dir", templateDirVar, "directory overriding the mail templates, defaults to TEMPLATE_DIR")
+	mock := flags.Bool("mock", false, "run against an in-process mock transferwise API")
+	mockScenario := flags.String("mock-scenario", "", "JSON scenario of the mock transferwise API, implies --mock")
+	record := flags.String("record", "", "cassette file to record the transferwise API calls to")
+	replay := flags.String("replay", "", "cassette file to replay the transferwise API calls from, offline")
+	_ = flags.Parse(os.Args[1:])
+	if *mock || *mockScenario != "" {
+		if _, err = startMockAPI(*mockScenario); err != nil {
+			fmt.Printf("Invalid mock scenario: %v", err)
+			return
+		}
+	}
+	if *replay != "" {
+		if err = startReplay(*replay); err != nil {
+			fmt.Printf("Invalid cassette: %v", err)
+			return
+		}
+	} else if *record != "" {
+		startRecording(*record)
+	}
+	if flags.NArg() > 0 {
+		os.Exit(runCommand(flags.Arg(0), flags.Args()[1:]))
+	}
+
+	err = loadConfig()
+	if err != nil {
+		fmt.Printf("Invalid config file: %v", err)
+		return
+	}
+
+	interval, err := getSchedulerInterval()
+	if err != nil {
+		fmt.Printf("Invalid config: %v", err)
+		return
+	}
+
+	_, _, err = getGuardrails()
+	if err != nil {
+		fmt.Printf("Invalid guardrail config: %v", err)
+		return
+	}
+
+	_, err = getTrackedStatuses()
+	if err != nil {
+		fmt.Printf("Invalid config: %v", err)
+		return
+	}
+
+	_, err = getErrorReportThreshold()
+	if err == nil && sentryDSNVar != "" {
+		_, err = newSentryReporter(sentryDSNVar)
+	}
+	if err != nil {
+		fmt.Printf("Invalid error reporting config: %v", err)
+		return
+	}
+
+	_, err = strconv.ParseBool(readOnlyVar)
+	if err != nil {
+		fmt.Printf("Invalid value for READ_ONLY: %v", err)
+		return
+	}
+
+	err = logTokenScope()
+	if err != nil {
+		fmt.Printf("Checking API token failed: %v", err)
+		return
+	}
+
+	err = validateProfile()
+	if err != nil {
+		fmt.Printf("Invalid value for PROFILE_ID: %v", err)
+		return
+	}
+
+	err = validateConfiguredPairs()
+	if err != nil {
+		fmt.Printf("Invalid config file: %v", err)
+		return
+	}
+
+	lock, err := acquireInstanceLock("batch server")
+	if err != nil {
+		fmt.Printf("Not starting: %v", err)
+		return
+	}
+	defer lock.Release()
+
+	err = startEventBus()
+	if err != nil {
+		fmt.Printf("Invalid event bus config: %v", err)
+		return
+	}
+
+	err = startLeaderElection()
+	if err != nil {
+		fmt.Printf("Leader election failed: %v", err)
+		return
+	}
+
+	err = reconcilePendingRebook()
+	if err != nil {
+		fmt.Printf("Reconciling interrupted re-booking failed: %v", err)
+		return
+	}
+	logPause()
+	reportStartup(time.Now().UTC())
+
+	shutdownTimeout, err := getShutdownTimeout()
 	if err != nil {
-		fmt.Printf("Invalid value for INTERVAL: %v", err)
+		fmt.Printf("Invalid config: %v", err)
 		return
 	}
 
 	s1 := gocron.NewScheduler(time.UTC)
-	_, err = s1.Every(2).Minute().Do(checkAndProcess)
+	err = scheduleChecks(s1, interval)
+	if err != nil {
+		fmt.Println(err.Error())
+		panic("couldn't initiate the check jobs")
+	}
+	_, err = s1.Every(12).Hours().Do(defaultAccountJob(sendExpiryReminder))
+	if err != nil {
+		fmt.Println(err.Error())
+		panic("couldn't initiate the sendExpiryReminder job")
+	}
+	_, err = s1.Every(1).Day().Do(defaultAccountJob(sendNoActionDigest))
+	if err != nil {
+		fmt.Println(err.Error())
+		panic("couldn't initiate the sendNoActionDigest job")
+	}
+	err = scheduleRateDigest(s1)
+	if err != nil {
+		fmt.Println(err.Error())
+		panic("couldn't initiate the sendRateDigest job")
+	}
+	err = scheduleActivityFeed(s1)
+	if err != nil {
+		fmt.Println(err.Error())
+		panic("couldn't initiate the pollActivities job")
+	}
+	_, err = s1.Every(1).Minute().Do(defaultAccountJob(flushQuietQueue))
+	if err != nil {
+		fmt.Println(err.Error())
+		panic("couldn't initiate the flushQuietQueue job")
+	}
+	_, err = s1.Every(1).Minute().Do(defaultAccountJob(flushLimitedQueue))
 	if err != nil {
 		fmt.Println(err.Error())
-		panic("couldn't initiate the checkAndProcess job")
+		panic("couldn't initiate the flushLimitedQueue job")
 	}
-	//s1.Every(12).Hours().Do(sendExpiryReminderMail)
 	s1.StartAsync()
+	go watchConfig(s1)
+
+	http.HandleFunc("/", defaultAccountHandler(dashboardHandler))
+	http.HandleFunc("/healthz", healthzHandler)
+	http.HandleFunc("/readyz", readyzHandler)
+	http.HandleFunc("/stream", streamHandler)
+	http.HandleFunc("/proposals", proposalsHandler)
+	http.HandleFunc("/proposals/", proposalsHandler)
+	http.HandleFunc(feedPath, defaultAccountHandler(feedHandler))
+	registerControlAPI(http.DefaultServeMux)
+	registerRPCAPI(http.DefaultServeMux)
+
+	server := &http.Server{Addr: ":3000"}
+	shutdown := make(chan struct{})
+	go func() {
+		waitForShutdown(s1, server, shutdownTimeout)
+		close(shutdown)
+	}()
 
 	fmt.Println("Starting batch server on port 3000")
-	_ = http.ListenAndServe(":3000", nil)
+	err = server.ListenAndServe()
+	if err != http.ErrServerClosed {
+		fmt.Println(err.Error())
+		return
+	}
+	<-shutdown
 }
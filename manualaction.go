@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"transferwisely/wise"
+)
+
+// manual action notification
+const (
+	manualActionSubject = "MANUAL ACTION NEEDED: re-booking of transfer %v didn't complete"
+
+	manualActionCreateUnknownText = "Creating the transfer replacing transfer %v ({%v} --> {%v}, %v %v) failed: %v\n\n" +
+		"Transferwise may still have created it, with the customer transaction ID %v. Check your booked transfers in Wise: " +
+		"if a new one shows up next to transfer %v, cancel one of them and fund only the other. The next start of the " +
+		"batch reconciles it too."
+	manualActionCancelOldText = "Transfer %v ({%v} --> {%v}, %v %v) was re-booked as transfer %v at %v, but cancelling " +
+		"transfer %v failed: %v\n\nBoth transfers are booked now. Cancel transfer %v in Wise and fund transfer %v only."
+	manualActionFundedText = "Transfer %v ({%v} --> {%v}, %v %v) got funded during its re-booking, after transfer %v was " +
+		"booked at %v to replace it: %v\n\nTransfer %v is the one to keep. Check in Wise that transfer %v is cancelled, " +
+		"cancel it otherwise, and don't fund it."
+)
+
+// Alert that a re-booking left the transfers in a state only a human can sort out, text saying what went wrong and
+// how to fix it
+func notifyManualAction(oldTransfer Transfer, newTransferId uint64, text string) {
+	log.Printf("|| MANUAL ACTION NEEDED || Transfer ID: %v | New Transfer ID: %v ||", oldTransfer.Id, newTransferId)
+	notify(Event{
+		Kind:       EventManualAction,
+		Subject:    fmt.Sprintf(manualActionSubject, oldTransfer.Id),
+		Text:       text,
+		Pair:       pairKey(oldTransfer.SourceCurrency, oldTransfer.TargetCurrency),
+		TransferId: oldTransfer.Id,
+	})
+}
+
+// Whether the transfer may have been created despite the error creating it: the request may have reached
+// transferwise unless it was refused before being sent, or answered with a 4xx
+func mayHaveCreated(err error) bool {
+	if errors.Is(err, errReadOnly) || errors.Is(err, errCircuitOpen) || errors.Is(err, errTokenInvalid) {
+		return false
+	}
+	var apiErr *wise.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Status >= http.StatusInternalServerError
+	}
+	return true
+}
+
+func notifyCreateUnknown(oldTransfer Transfer, customerTransactionId string, err error) {
+	notifyManualAction(oldTransfer, 0, fmt.Sprintf(manualActionCreateUnknownText, oldTransfer.Id, oldTransfer.SourceCurrency,
+		oldTransfer.TargetCurrency, formatAmount(oldTransfer.SourceAmount, oldTransfer.SourceCurrency),
+		oldTransfer.SourceCurrency, err, customerTransactionId, oldTransfer.Id))
+}
+
+func notifyCancelOldFailed(oldTransfer Transfer, newTransfer Transfer, err error) {
+	notifyManualAction(oldTransfer, newTransfer.Id, fmt.Sprintf(manualActionCancelOldText, oldTransfer.Id,
+		oldTransfer.SourceCurrency, oldTransfer.TargetCurrency, formatAmount(oldTransfer.SourceAmount, oldTransfer.SourceCurrency),
+		oldTransfer.SourceCurrency, newTransfer.Id, formatRate(newTransfer.Rate), oldTransfer.Id, err, oldTransfer.Id, newTransfer.Id))
+}
+
+func notifyFundedDuringRebook(oldTransfer Transfer, newTransfer Transfer, err error) {
+	notifyManualAction(oldTransfer, newTransfer.Id, fmt.Sprintf(manualActionFundedText, oldTransfer.Id,
+		oldTransfer.SourceCurrency, oldTransfer.TargetCurrency, formatAmount(oldTransfer.SourceAmount, oldTransfer.SourceCurrency),
+		oldTransfer.SourceCurrency, newTransfer.Id, formatRate(newTransfer.Rate), err, oldTransfer.Id, newTransfer.Id))
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"transferwisely/mocks"
+	"transferwisely/wise"
+)
+
+func TestMayHaveCreated(t *testing.T) {
+	assert.True(t, mayHaveCreated(fmt.Errorf("error calling external api: connection reset")))
+	assert.True(t, mayHaveCreated(fmt.Errorf("error POST create transfer API: %w", &wise.APIError{Status: http.StatusBadGateway})))
+	assert.False(t, mayHaveCreated(fmt.Errorf("error POST create transfer API: %w", &wise.APIError{Status: http.StatusUnprocessableEntity})))
+	assert.False(t, mayHaveCreated(fmt.Errorf("error POST create transfer API: %w", errReadOnly)))
+	assert.False(t, mayHaveCreated(fmt.Errorf("%w after 5 failed transferwise api calls", errCircuitOpen)))
+}
+
+func TestManualActionNotifications(t *testing.T) {
+	defer func(file string, limit string) { stateFileVar, notifyRateLimitVar = file, limit }(stateFileVar, notifyRateLimitVar)
+	dir, _ := ioutil.TempDir("", "transferwisely")
+	defer os.RemoveAll(dir)
+	stateFileVar, notifyRateLimitVar = filepath.Join(dir, "state.json"), "0"
+	defer os.Remove(stateFileVar)
+	defer func(n []Notifier) { Notifiers = n }(Notifiers)
+
+	createStatus, cancelStatus, fundedStatus, created := http.StatusOK, http.StatusOK, "", false
+	mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+		status, body := http.StatusOK, `{}`
+		switch {
+		case req.Method == http.MethodPost:
+			status, body, created = createStatus, `{"id": 2, "rate": 101}`, true
+		case req.Method == http.MethodPut:
+			status = cancelStatus
+		case strings.HasSuffix(req.URL.String(), "v1/transfers/1") && created && fundedStatus != "":
+			body = `{"id": 1, "status": "` + fundedStatus + `"}`
+		case strings.HasSuffix(req.URL.String(), "v1/transfers/1"):
+			body = `{"id": 1, "status": "incoming_payment_waiting"}`
+		}
+		return &http.Response{StatusCode: status, Body: ioutil.NopCloser(strings.NewReader(body))}, nil
+	}
+	old := Transfer{Id: 1, Rate: 100, SourceCurrency: "GBP", TargetCurrency: "INR", SourceAmount: decimalOf(500)}
+
+	tests := []struct {
+		name         string
+		createStatus int
+		cancelStatus int
+		funded       string
+		expected     []string
+	}{
+		{"clean re-booking", http.StatusOK, http.StatusOK, "", nil},
+		{"old transfer not cancelled", http.StatusOK, http.StatusInternalServerError, "",
+			[]string{"Both transfers are booked now. Cancel transfer 1 in Wise and fund transfer 2 only."}},
+		{"creation outcome unknown", http.StatusServiceUnavailable, http.StatusOK, "",
+			[]string{"Transferwise may still have created it", "next to transfer 1"}},
+		{"creation refused", http.StatusUnprocessableEntity, http.StatusOK, "", nil},
+		{"old transfer funded meanwhile", http.StatusOK, http.StatusOK, "processing",
+			[]string{"after transfer 2 was booked", "Transfer 1 is the one to keep", "that transfer 2 is cancelled"}},
+		{"old transfer funded and new one not cancelled", http.StatusOK, http.StatusInternalServerError, "processing",
+			[]string{"cancelling the re-booking's transfer 2 failed", "that transfer 2 is cancelled"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := &fakeNotifier{}
+			Notifiers = []Notifier{fake}
+			createStatus, cancelStatus, fundedStatus, created = test.createStatus, test.cancelStatus, test.funded, false
+
+			newTransfer, err := createTransferFromQuote(old, QuoteDetail{Id: "quote-1"})
+			if test.funded != "" {
+				assert.True(t, errors.Is(err, errTransferFunded))
+				assert.Equal(t, uint64(2), newTransfer.Id, "the booked transfer is returned with the error")
+			}
+			var manual []Event
+			for _, event := range fake.events {
+				if event.Kind == EventManualAction {
+					manual = append(manual, event)
+				}
+			}
+			if test.expected == nil {
+				assert.Empty(t, manual)
+				return
+			}
+			assert.Len(t, manual, 1)
+			assert.Equal(t, "MANUAL ACTION NEEDED: re-booking of transfer 1 didn't complete", manual[0].Subject)
+			for _, expected := range test.expected {
+				assert.Contains(t, manual[0].Text, expected)
+			}
+		})
+	}
+	assert.True(t, isCritical(EventManualAction), "sent during quiet hours and mutes")
+}
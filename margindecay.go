@@ -0,0 +1,51 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// margin decay curves, how the margin required to re-book falls to MARGIN_FLOOR as the rate lock expiry nears
+const (
+	marginDecayNone      = "none"
+	marginDecayLinear    = "linear"
+	marginDecayQuadratic = "quadratic"
+	marginDecaySqrt      = "sqrt"
+)
+
+// decimals the decayed margin is rounded to, finer than any rate transferwise quotes
+const marginDecayPlaces = 6
+
+// share of the margin above the floor still required with the given share of the decay window left, from 1 to 0
+var marginDecayCurves = map[string]func(left float64) float64{
+	marginDecayNone:   func(float64) float64 { return 1 },
+	marginDecayLinear: func(left float64) float64 { return left },
+	// gives up most of the margin early in the window
+	marginDecayQuadratic: func(left float64) float64 { return left * left },
+	// holds out for most of the margin until close to the expiry
+	marginDecaySqrt: math.Sqrt,
+}
+
+// Margin required to re-book the booked transfer at now, falling from the margin to the floor along the decay curve
+// over the last MarginDecayHours of its rate lock, so a guaranteed rate doesn't lapse while holding out for the full margin
+func decayMargin(settings Settings, rateExpirationTime string, now time.Time) Decimal {
+	curve, ok := marginDecayCurves[settings.MarginDecay]
+	if !ok || settings.MarginDecayHours == 0 || settings.Margin.Cmp(settings.MarginFloor) <= 0 {
+		return settings.Margin
+	}
+	expiry, err := time.Parse(time.RFC3339, rateExpirationTime)
+	if err != nil {
+		return settings.Margin
+	}
+
+	window := time.Duration(settings.MarginDecayHours) * time.Hour
+	left := float64(expiry.Sub(now)) / float64(window)
+	if left >= 1 {
+		return settings.Margin
+	}
+	if left < 0 {
+		left = 0
+	}
+	above := settings.Margin.Sub(settings.MarginFloor).Mul(decimalOf(curve(left)))
+	return settings.MarginFloor.Add(above).Round(marginDecayPlaces)
+}
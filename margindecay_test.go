@@ -0,0 +1,55 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestDecayMargin(t *testing.T) {
+	now := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	expiresIn := func(d time.Duration) string { return now.Add(d).Format(time.RFC3339) }
+	settings := Settings{Margin: decimalOf(0.5), MarginFloor: decimalOf(0.1), MarginDecay: marginDecayLinear, MarginDecayHours: 24}
+
+	tests := []struct {
+		name     string
+		decay    string
+		left     time.Duration
+		expected float64
+	}{
+		{"before the window", marginDecayLinear, 48 * time.Hour, 0.5},
+		{"linear halfway", marginDecayLinear, 12 * time.Hour, 0.3},
+		{"quadratic halfway", marginDecayQuadratic, 12 * time.Hour, 0.2},
+		{"sqrt a quarter left", marginDecaySqrt, 6 * time.Hour, 0.3},
+		{"at the expiry", marginDecayLinear, 0, 0.1},
+		{"past the expiry", marginDecayLinear, -time.Hour, 0.1},
+		{"no decay", marginDecayNone, time.Hour, 0.5},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := settings
+			s.MarginDecay = test.decay
+			assert.Equal(t, decimalOf(test.expected), decayMargin(s, expiresIn(test.left), now))
+		})
+	}
+
+	t.Run("unknown expiry", func(t *testing.T) {
+		assert.Equal(t, decimalOf(0.5), decayMargin(settings, "", now))
+	})
+
+	t.Run("margin already below the floor", func(t *testing.T) {
+		s := settings
+		s.Margin = decimalOf(0.05)
+		assert.Equal(t, decimalOf(0.05), decayMargin(s, expiresIn(time.Hour), now))
+	})
+
+	t.Run("re-books a smaller improvement close to the expiry", func(t *testing.T) {
+		transfer := Transfer{Rate: 100}
+		s := settings
+		s.Margin = decayMargin(settings, expiresIn(3*time.Hour), now)
+		rebook, _ := marginStrategy{}.ShouldRebook(transfer, 100.2, settings)
+		assert.False(t, rebook)
+		rebook, _ = marginStrategy{}.ShouldRebook(transfer, 100.2, s)
+		assert.True(t, rebook)
+	})
+}
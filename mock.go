@@ -0,0 +1,32 @@
+package main
+
+import (
+	"log"
+	"net/http/httptest"
+	"transferwisely/wisemock"
+)
+
+// Serve the mock transferwise API in-process and point the batch at it, for local development without a sandbox
+// account. The scenario file scripts it, see wisemock.Scenario, the default one booking a transfer whose rate rises
+func startMockAPI(scenarioFile string) (*wisemock.Server, error) {
+	scenario := wisemock.DefaultScenario()
+	if scenarioFile != "" {
+		var err error
+		if scenario, err = wisemock.LoadScenario(scenarioFile); err != nil {
+			return nil, err
+		}
+	}
+	mock, err := wisemock.New(scenario)
+	if err != nil {
+		return nil, err
+	}
+
+	// the batch only calls https URLs, so the mock serves TLS with a certificate only its client trusts
+	server := httptest.NewTLSServer(mock)
+	hostVar, Client = server.Listener.Addr().String(), server.Client()
+	if apiTokenVar == "" {
+		apiTokenVar = "mock"
+	}
+	log.Printf("|| MOCK API || Serving at https://%v ||", hostVar)
+	return mock, nil
+}
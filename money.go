@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// number of decimals of the currencies whose minor unit isn't a cent, per ISO 4217
+var currencyMinorUnits = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0, "PYG": 0, "RWF": 0,
+	"UGX": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+}
+
+func minorUnits(currency string) int {
+	if units, ok := currencyMinorUnits[strings.ToUpper(currency)]; ok {
+		return units
+	}
+	return 2
+}
+
+// Rates come as float64 from the API, but their math is done on the decimal numbers they were written as, so 1.2 - 1.1
+// is 0.1 and a margin of 0.1 is met, which plain float64 math says it isn't
+func toDecimal(value float64) *big.Rat {
+	decimal, _ := new(big.Rat).SetString(strconv.FormatFloat(value, 'f', -1, 64))
+	return decimal
+}
+
+func fromDecimal(decimal *big.Rat) float64 {
+	value, _ := decimal.Float64()
+	return value
+}
+
+// Round half away from zero to the given number of decimals
+func roundDecimal(decimal *big.Rat, places int) *big.Rat {
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(places)), nil)
+	scaled := new(big.Rat).Mul(decimal, new(big.Rat).SetInt(scale))
+	quotient, remainder := new(big.Int).QuoRem(scaled.Num(), scaled.Denom(), new(big.Int))
+	if new(big.Int).Mul(new(big.Int).Abs(remainder), big.NewInt(2)).Cmp(scaled.Denom()) >= 0 {
+		quotient.Add(quotient, big.NewInt(int64(scaled.Num().Sign())))
+	}
+	return new(big.Rat).SetFrac(quotient, scale)
+}
+
+func addDecimal(a float64, b float64) float64 {
+	return fromDecimal(new(big.Rat).Add(toDecimal(a), toDecimal(b)))
+}
+
+func subtractDecimal(a float64, b float64) float64 {
+	return fromDecimal(new(big.Rat).Sub(toDecimal(a), toDecimal(b)))
+}
+
+// Decimal is an amount, a fee or a rate margin, kept as the exact decimal number it was written as, backed by big.Rat,
+// so its math and comparisons don't drift like float64 ones do. The zero value is 0, and a Decimal is never changed in
+// place, so it's passed around and copied like a float64
+type Decimal struct {
+	// nil for 0, else normalized by newDecimal so that equal decimals are deeply equal
+	rat *big.Rat
+}
+
+func newDecimal(rat *big.Rat) Decimal {
+	if rat == nil || rat.Sign() == 0 {
+		return Decimal{}
+	}
+	normalized, _ := new(big.Rat).SetString(rat.RatString())
+	return Decimal{rat: normalized}
+}
+
+// Decimal of the float64, as the shortest decimal number it prints as, e.g. 0.1 rather than 0.1000000000000000055511
+func decimalOf(value float64) Decimal {
+	return newDecimal(toDecimal(value))
+}
+
+// Decimal of a number like 1000.50 or 1e3
+func parseDecimal(s string) (Decimal, error) {
+	rat, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+	if !ok {
+		return Decimal{}, fmt.Errorf("invalid decimal number %q", s)
+	}
+	return newDecimal(rat), nil
+}
+
+func (d Decimal) Rat() *big.Rat {
+	if d.rat == nil {
+		return new(big.Rat)
+	}
+	return new(big.Rat).Set(d.rat)
+}
+
+func (d Decimal) Float64() float64 {
+	return fromDecimal(d.Rat())
+}
+
+func (d Decimal) Add(other Decimal) Decimal {
+	return newDecimal(new(big.Rat).Add(d.Rat(), other.Rat()))
+}
+
+func (d Decimal) Sub(other Decimal) Decimal {
+	return newDecimal(new(big.Rat).Sub(d.Rat(), other.Rat()))
+}
+
+func (d Decimal) Mul(other Decimal) Decimal {
+	return newDecimal(new(big.Rat).Mul(d.Rat(), other.Rat()))
+}
+
+// Quotient of the division by other, 0 when other is 0
+func (d Decimal) Quo(other Decimal) Decimal {
+	if other.Sign() == 0 {
+		return Decimal{}
+	}
+	return newDecimal(new(big.Rat).Quo(d.Rat(), other.Rat()))
+}
+
+// -1, 0 or +1 as d is less than, equal to or greater than other
+func (d Decimal) Cmp(other Decimal) int {
+	return d.Rat().Cmp(other.Rat())
+}
+
+func (d Decimal) Sign() int {
+	if d.rat == nil {
+		return 0
+	}
+	return d.rat.Sign()
+}
+
+// Round half away from zero to the given number of decimals
+func (d Decimal) Round(places int) Decimal {
+	return newDecimal(roundDecimal(d.Rat(), places))
+}
+
+// The decimal number, like 1000.5, with as many decimals as it has, or as the closest float64 has when it has
+// infinitely many, like a third
+func (d Decimal) String() string {
+	places, ok := decimalPlaces(d.Rat().Denom())
+	if !ok {
+		return strconv.FormatFloat(d.Float64(), 'f', -1, 64)
+	}
+	return d.Rat().FloatString(places)
+}
+
+// Number of decimals of the fractions with the denominator, none when it has factors other than 2 and 5
+func decimalPlaces(denominator *big.Int) (int, bool) {
+	rest := new(big.Int).Set(denominator)
+	places := 0
+	for _, factor := range []int64{2, 5} {
+		count := 0
+		for new(big.Int).Rem(rest, big.NewInt(factor)).Sign() == 0 {
+			rest.Quo(rest, big.NewInt(factor))
+			count++
+		}
+		if count > places {
+			places = count
+		}
+	}
+	return places, rest.Cmp(big.NewInt(1)) == 0
+}
+
+// Set parses the decimal of a command line flag
+func (d *Decimal) Set(s string) error {
+	decimal, err := parseDecimal(s)
+	if err != nil {
+		return err
+	}
+	*d = decimal
+	return nil
+}
+
+// Written as a JSON number, like the API and the state file had it
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// Read from a JSON number, or a string holding one, as written, null being 0
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		*d = Decimal{}
+		return nil
+	}
+	decimal, err := parseDecimal(strings.Trim(s, `"`))
+	if err != nil {
+		return err
+	}
+	*d = decimal
+	return nil
+}
+
+// Amount rounded to the minor unit of its currency, e.g. cents, or yens
+func roundAmount(amount Decimal, currency string) Decimal {
+	return amount.Round(minorUnits(currency))
+}
+
+// Amount with as many decimals as its currency has, like 1000.50 EUR or 150000 JPY, written the LOCALE way, like
+// 1,000.50 EUR or 1.000,50 EUR
+func formatAmount(amount Decimal, currency string) string {
+	return localizeNumber(roundDecimal(amount.Rat(), minorUnits(currency)).FloatString(minorUnits(currency)))
+}
+
+// Amount of the target currency a source amount converts to at the rate, rounded to its minor unit
+func convertAmount(amount Decimal, rate float64, currency string) Decimal {
+	return amount.Mul(decimalOf(rate)).Round(minorUnits(currency))
+}
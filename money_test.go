@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRoundAmount(t *testing.T) {
+	assert.Equal(t, decimalOf(2.68), roundAmount(decimalOf(2.675), "GBP"))
+	assert.Equal(t, decimalOf(1.01), roundAmount(decimalOf(1.005), "EUR"))
+	assert.Equal(t, decimalOf(-1.01), roundAmount(decimalOf(-1.005), "EUR"))
+	assert.Equal(t, decimalOf(1235), roundAmount(decimalOf(1234.5), "JPY"))
+	assert.Equal(t, decimalOf(12.346), roundAmount(decimalOf(12.3456), "kwd"))
+	assert.Equal(t, decimalOf(100), roundAmount(decimalOf(100), "USD"))
+}
+
+func TestFormatAmount(t *testing.T) {
+	assert.Equal(t, "1000.00", formatAmount(decimalOf(1000), "GBP"))
+	assert.Equal(t, "0.30", formatAmount(decimalOf(0.1).Add(decimalOf(0.2)), "EUR"))
+	assert.Equal(t, "150000", formatAmount(decimalOf(150000), "JPY"))
+	assert.Equal(t, "1.250", formatAmount(decimalOf(1.25), "BHD"))
+}
+
+func TestDecimalMath(t *testing.T) {
+	assert.Equal(t, 0.1, subtractDecimal(1.2, 1.1))
+	assert.Equal(t, 1.2, addDecimal(1.1, 0.1))
+	assert.Equal(t, decimalOf(200), convertAmount(decimalOf(1000), 0.2, "INR"))
+	assert.Equal(t, decimalOf(33.33), convertAmount(decimalOf(100), 0.33333, "EUR"))
+	assert.Equal(t, decimalOf(0.3), decimalOf(0.1).Add(decimalOf(0.2)))
+	assert.Equal(t, Decimal{}, decimalOf(1.1).Sub(decimalOf(1.1)))
+	assert.Equal(t, 1, decimalOf(1.2).Sub(decimalOf(1.1)).Cmp(decimalOf(0.0999999)))
+
+	// float64 math would find the live rate 0.09999999999999987 better only, missing the margin
+	rebook, err := marginStrategy{}.ShouldRebook(Transfer{Rate: 1.1}, 1.2, Settings{Margin: decimalOf(0.1)})
+	assert.NoError(t, err)
+	assert.True(t, rebook)
+}
+
+func TestDecimalJSON(t *testing.T) {
+	var amounts struct {
+		Source Decimal  `json:"source"`
+		Target Decimal  `json:"target"`
+		Fee    *Decimal `json:"fee"`
+	}
+	err := json.Unmarshal([]byte(`{"source": 1000.10, "target": "1e3", "fee": null}`), &amounts)
+	assert.NoError(t, err)
+	assert.Equal(t, "1000.1", amounts.Source.String())
+	assert.Equal(t, decimalOf(1000), amounts.Target)
+	assert.Nil(t, amounts.Fee)
+
+	data, err := json.Marshal(amounts)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"source": 1000.1, "target": 1000, "fee": null}`, string(data))
+
+	assert.Error(t, json.Unmarshal([]byte(`{"source": "ten"}`), &amounts))
+	assert.Equal(t, "0.3333333333333333", decimalOf(1).Quo(decimalOf(3)).String())
+}
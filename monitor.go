@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// transfer statuses, as documented by transfer-wise
+var transferStatuses = []string{
+	"incoming_payment_waiting",
+	"incoming_payment_initiated",
+	"waiting_recipient_input_to_proceed",
+	"processing",
+	"funds_converted",
+	"outgoing_payment_sent",
+	"cancelled",
+	"funds_refunded",
+	"bounced_back",
+	"charged_back",
+	"unknown",
+}
+
+// number of most recent transfers whose status is monitored
+const monitoredTransfersLimit = 20
+
+// status change notification
+const (
+	statusChangedSubject = "Transfer %v is now %v"
+	statusChangedText    = "Transfer ID: %v\n{%v} --> {%v}\nRate: %v\nAmount: %v %v\nStatus: %v (was %v)"
+)
+
+// StatusChange is a monitored transfer seen in a new status
+type StatusChange struct {
+	Transfer  Transfer
+	OldStatus string
+}
+
+// Statuses of the transfers checked for a better rate, from TRACKED_STATUSES
+func getTrackedStatuses() ([]string, error) {
+	var statuses []string
+	for _, status := range strings.Split(trackedStatusesVar, ",") {
+		status = strings.ToLower(strings.TrimSpace(status))
+		if status == "" {
+			continue
+		}
+		if !isTransferStatus(status) {
+			return nil, fmt.Errorf("invalid value for TRACKED_STATUSES: unknown status %v, must be one of %v",
+				status, strings.Join(transferStatuses, ", "))
+		}
+		statuses = append(statuses, status)
+	}
+	if len(statuses) == 0 {
+		return nil, fmt.Errorf("invalid value for TRACKED_STATUSES: no status given")
+	}
+	return statuses, nil
+}
+
+func isTransferStatus(status string) bool {
+	for _, s := range transferStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// Notify about every status change of the most recent transfers, run by the scheduler in MONITOR_TRANSFERS mode
+func monitorTransfers() {
+	defer reportPanic("monitorTransfers")
+	if !isLeader() {
+		return
+	}
+	transfers, err := listTransfers("", monitoredTransfersLimit)
+	if err != nil {
+		log.Printf("monitorTransfers: %v", err)
+		return
+	}
+
+	changes, err := detectStatusChanges(transfers)
+	if err != nil {
+		log.Printf("monitorTransfers: %v", err)
+		return
+	}
+	for _, change := range changes {
+		transfer := change.Transfer
+		if transfer.Status == transferStatusCompleted && isFetchingReceipts() {
+			err = notifyTransferCompleted(transfer)
+			if err == nil {
+				continue
+			}
+			// still notify about the completion, without its receipt
+			log.Println(err)
+		}
+		log.Printf("|| TRANSFER STATUS CHANGED, Status: %v || Transfer ID: %v | {%v} --> {%v} | Previous Status: %v ||",
+			transfer.Status, transfer.Id, transfer.SourceCurrency, transfer.TargetCurrency, change.OldStatus)
+		notify(Event{
+			Kind:    EventStatusChanged,
+			Subject: fmt.Sprintf(statusChangedSubject, transfer.Id, transfer.Status),
+			Text: fmt.Sprintf(statusChangedText, transfer.Id, transfer.SourceCurrency, transfer.TargetCurrency,
+				formatRate(transfer.Rate), formatAmount(transfer.SourceAmount, transfer.SourceCurrency), transfer.SourceCurrency, transfer.Status, change.OldStatus),
+			Pair:       pairKey(transfer.SourceCurrency, transfer.TargetCurrency),
+			TransferId: transfer.Id,
+		})
+	}
+}
+
+// Compare the transfers with their last seen status and remember the new ones. Transfers seen for the first time
+// aren't a change, the first run only taking a snapshot so enabling the monitoring doesn't flood the channels
+func detectStatusChanges(transfers []Transfer) (changes []StatusChange, err error) {
+	err = updateState(func(state *State) error {
+		statuses := make(map[uint64]string, len(transfers))
+		for _, transfer := range transfers {
+			oldStatus, seen := state.TransferStatuses[transfer.Id]
+			if seen && oldStatus != transfer.Status {
+				changes = append(changes, StatusChange{Transfer: transfer, OldStatus: oldStatus})
+			}
+			statuses[transfer.Id] = transfer.Status
+		}
+		state.TransferStatuses = statuses
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("detectStatusChanges: %v", err)
+	}
+	return changes, nil
+}
+
+func isMonitoringTransfers() bool {
+	monitor, _ := strconv.ParseBool(monitorTransfersVar)
+	return monitor
+}
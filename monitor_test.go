@@ -0,0 +1,78 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"transferwisely/mocks"
+)
+
+func TestGetTrackedStatuses(t *testing.T) {
+	defer func(v string) { trackedStatusesVar = v }(trackedStatusesVar)
+
+	trackedStatusesVar = "incoming_payment_waiting, Waiting_Recipient_Input_To_Proceed,processing"
+	statuses, err := getTrackedStatuses()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"incoming_payment_waiting", "waiting_recipient_input_to_proceed", "processing"}, statuses)
+
+	trackedStatusesVar = "incoming_payment_waiting,paid"
+	_, err = getTrackedStatuses()
+	assert.Error(t, err)
+
+	trackedStatusesVar = " , "
+	_, err = getTrackedStatuses()
+	assert.Error(t, err)
+}
+
+func TestGetBookedTransferTrackedStatuses(t *testing.T) {
+	defer func(v string) { trackedStatusesVar = v }(trackedStatusesVar)
+	trackedStatusesVar = "incoming_payment_waiting,processing"
+
+	var listURL string
+	mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+		body := `{"rate": 0.69, "sourceAmount": 1000, "profile": 1, "rateExpirationTime": "2030-01-01T00:00:00Z"}`
+		if strings.Contains(req.URL.String(), transfersAPIPath) {
+			listURL = req.URL.String()
+			body = `[{"id": 1, "rate": 0.69, "status": "processing", "quote": "quote-1"}]`
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(body))}, nil
+	}
+
+	transfer, err := getBookedTransfer()
+	assert.NoError(t, err)
+	assert.Equal(t, "processing", transfer.Status)
+	assert.Contains(t, listURL, "status=incoming_payment_waiting%2Cprocessing")
+}
+
+func TestMonitorTransfers(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "transferwisely")
+	defer os.RemoveAll(dir)
+	defer func(file string) { stateFileVar = file }(stateFileVar)
+	stateFileVar = filepath.Join(dir, "state.json")
+
+	fake := &fakeNotifier{}
+	defer func(n []Notifier) { Notifiers = n }(Notifiers)
+	Notifiers = []Notifier{fake}
+
+	body := `[{"id": 1, "status": "incoming_payment_waiting"}, {"id": 2, "status": "processing"}]`
+	mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+		assert.NotContains(t, req.URL.String(), "status=")
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(body))}, nil
+	}
+
+	monitorTransfers()
+	assert.Len(t, fake.events, 0, "first run only takes a snapshot")
+
+	body = `[{"id": 3, "status": "incoming_payment_waiting"}, {"id": 1, "status": "cancelled"}, {"id": 2, "status": "processing"}]`
+	monitorTransfers()
+	assert.Len(t, fake.events, 1)
+	assert.Equal(t, EventStatusChanged, fake.events[0].Kind)
+	assert.Equal(t, "Transfer 1 is now cancelled", fake.events[0].Subject)
+
+	state, _ := loadState()
+	assert.Equal(t, map[uint64]string{1: "cancelled", 2: "processing", 3: "incoming_payment_waiting"}, state.TransferStatuses)
+}
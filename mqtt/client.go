@@ -0,0 +1,237 @@
+// Package mqtt publishes messages to an MQTT 3.1.1 broker, at most once, like Home Assistant's Mosquitto add-on. It only
+// connects, publishes with QoS 0 and disconnects, subscribing being out of its scope
+package mqtt
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"time"
+)
+
+// control packet types
+const (
+	packetConnect    = 1
+	packetConnAck    = 2
+	packetPublish    = 3
+	packetDisconnect = 14
+)
+
+// flags of the CONNECT packet
+const (
+	connectCleanSession = 0x02
+	connectPassword     = 0x40
+	connectUsername     = 0x80
+)
+
+const protocolLevel311 = 4
+
+// CONNACK return codes
+var connectErrors = map[byte]string{
+	1: "unacceptable protocol version",
+	2: "identifier rejected",
+	3: "server unavailable",
+	4: "bad user name or password",
+	5: "not authorized",
+}
+
+// Message is published with QoS 0, a retained one being delivered to every later subscriber of its topic too
+type Message struct {
+	Topic   string
+	Payload []byte
+	Retain  bool
+}
+
+// Options of the connection to the broker
+type Options struct {
+	ClientID string
+	Username string
+	Password string
+	Timeout  time.Duration
+}
+
+// Client is a connection to the broker
+type Client struct {
+	conn    net.Conn
+	w       *bufio.Writer
+	timeout time.Duration
+}
+
+// Connect to the broker at mqtt://host:port (tcp:// too), or mqtts://host:port (ssl://, tls://) over TLS, the port
+// defaulting to 1883 and 8883
+func Connect(broker string, options Options) (*Client, error) {
+	u, err := url.Parse(broker)
+	if err != nil {
+		return nil, fmt.Errorf("invalid broker URL: %v", err)
+	}
+	useTLS, port, err := parseScheme(u.Scheme)
+	if err != nil {
+		return nil, err
+	}
+	address := u.Host
+	if u.Port() == "" {
+		address = net.JoinHostPort(u.Hostname(), port)
+	}
+	timeout := options.Timeout
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+
+	dialer := &net.Dialer{Timeout: timeout}
+	var conn net.Conn
+	if useTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, &tls.Config{ServerName: u.Hostname()})
+	} else {
+		conn, err = dialer.Dial("tcp", address)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to %v: %v", address, err)
+	}
+
+	c := &Client{conn: conn, w: bufio.NewWriter(conn), timeout: timeout}
+	if err := c.connect(options); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// ValidateBroker checks the broker URL Connect would be given
+func ValidateBroker(broker string) error {
+	u, err := url.Parse(broker)
+	if err != nil {
+		return fmt.Errorf("invalid broker URL: %v", err)
+	}
+	if u.Hostname() == "" {
+		return fmt.Errorf("invalid broker URL %v, expected mqtt://host:port", broker)
+	}
+	_, _, err = parseScheme(u.Scheme)
+	return err
+}
+
+func parseScheme(scheme string) (useTLS bool, defaultPort string, err error) {
+	switch scheme {
+	case "mqtt", "tcp":
+		return false, "1883", nil
+	case "mqtts", "ssl", "tls":
+		return true, "8883", nil
+	default:
+		return false, "", fmt.Errorf("invalid broker scheme %v, expected mqtt or mqtts", scheme)
+	}
+}
+
+func (c *Client) connect(options Options) error {
+	flags := byte(connectCleanSession)
+	payload := encodeString(options.ClientID)
+	if options.Username != "" {
+		flags |= connectUsername
+		payload = append(payload, encodeString(options.Username)...)
+		if options.Password != "" {
+			flags |= connectPassword
+			payload = append(payload, encodeString(options.Password)...)
+		}
+	}
+	// protocol name, level, flags and a keep alive of 0 as the connection never idles
+	header := append(encodeString("MQTT"), protocolLevel311, flags, 0, 0)
+	if err := c.write(packetConnect<<4, append(header, payload...)); err != nil {
+		return err
+	}
+
+	_ = c.conn.SetReadDeadline(time.Now().Add(c.timeout))
+	packetType, body, err := readPacket(bufio.NewReader(c.conn))
+	if err != nil {
+		return fmt.Errorf("error reading CONNACK: %v", err)
+	}
+	if packetType>>4 != packetConnAck || len(body) != 2 {
+		return fmt.Errorf("unexpected packet %v, expected CONNACK", packetType>>4)
+	}
+	if code := body[1]; code != 0 {
+		return fmt.Errorf("connection refused: %v", connectErrors[code])
+	}
+	return nil
+}
+
+// Publish the messages with QoS 0
+func (c *Client) Publish(messages ...Message) error {
+	for _, message := range messages {
+		flags := byte(packetPublish << 4)
+		if message.Retain {
+			flags |= 0x01
+		}
+		if err := c.write(flags, append(encodeString(message.Topic), message.Payload...)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close disconnects cleanly
+func (c *Client) Close() error {
+	err := c.write(packetDisconnect<<4, nil)
+	if closeErr := c.conn.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (c *Client) write(header byte, body []byte) error {
+	_ = c.conn.SetWriteDeadline(time.Now().Add(c.timeout))
+	packet := append([]byte{header}, encodeLength(len(body))...)
+	if _, err := c.w.Write(append(packet, body...)); err != nil {
+		return fmt.Errorf("error writing packet: %v", err)
+	}
+	if err := c.w.Flush(); err != nil {
+		return fmt.Errorf("error writing packet: %v", err)
+	}
+	return nil
+}
+
+// A UTF-8 string, prefixed by its length
+func encodeString(s string) []byte {
+	return append([]byte{byte(len(s) >> 8), byte(len(s))}, s...)
+}
+
+// The remaining length of a packet, 7 bits per byte, the high bit telling another byte follows
+func encodeLength(n int) []byte {
+	var encoded []byte
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		encoded = append(encoded, b)
+		if n == 0 {
+			return encoded
+		}
+	}
+}
+
+func readPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	length, multiplier := 0, 1
+	for i := 0; ; i++ {
+		if i == 4 {
+			return 0, nil, errors.New("malformed remaining length")
+		}
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length += int(b&0x7f) * multiplier
+		multiplier *= 128
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	body := make([]byte, length)
+	_, err = io.ReadFull(r, body)
+	return header, body, err
+}
@@ -0,0 +1,83 @@
+package mqtt
+
+import (
+	"bufio"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"strings"
+	"testing"
+)
+
+// Accept one connection, answering its CONNECT with the return code and collecting the packets that follow
+func fakeBroker(t *testing.T, returnCode byte) (string, chan [][]byte) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+
+	packets := make(chan [][]byte, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		var received [][]byte
+		for {
+			header, body, err := readPacket(r)
+			if err != nil {
+				packets <- received
+				return
+			}
+			received = append(received, append([]byte{header}, body...))
+			if header>>4 == packetConnect {
+				_, _ = conn.Write([]byte{packetConnAck << 4, 2, 0, returnCode})
+			}
+			if header>>4 == packetDisconnect {
+				packets <- received
+				return
+			}
+		}
+	}()
+	return "mqtt://" + listener.Addr().String(), packets
+}
+
+func TestPublish(t *testing.T) {
+	broker, packets := fakeBroker(t, 0)
+	client, err := Connect(broker, Options{ClientID: "transferwisely", Username: "user", Password: "pass"})
+	assert.NoError(t, err)
+	payload := strings.Repeat("x", 200)
+	assert.NoError(t, client.Publish(Message{Topic: "transferwisely/gbp_inr/state", Payload: []byte(payload), Retain: true}))
+	assert.NoError(t, client.Close())
+
+	received := <-packets
+	assert.Len(t, received, 3)
+	connect := string(received[0])
+	assert.Contains(t, connect, "MQTT")
+	assert.Contains(t, connect, "transferwisely")
+	assert.Contains(t, connect, "user")
+	assert.Contains(t, connect, "pass")
+	assert.Equal(t, byte(packetPublish<<4|1), received[1][0], "retained QoS 0 publish")
+	assert.Equal(t, "\x00\x1ctransferwisely/gbp_inr/state"+payload, string(received[1][1:]))
+	assert.Equal(t, byte(packetDisconnect<<4), received[2][0])
+}
+
+func TestConnectRefused(t *testing.T) {
+	broker, _ := fakeBroker(t, 4)
+	_, err := Connect(broker, Options{ClientID: "transferwisely"})
+	assert.EqualError(t, err, "connection refused: bad user name or password")
+}
+
+func TestValidateBroker(t *testing.T) {
+	assert.NoError(t, ValidateBroker("mqtt://homeassistant.local:1883"))
+	assert.NoError(t, ValidateBroker("mqtts://broker.example.com"))
+	assert.Error(t, ValidateBroker("http://broker.example.com"))
+	assert.Error(t, ValidateBroker("broker.example.com"))
+}
+
+func TestEncodeLength(t *testing.T) {
+	assert.Equal(t, []byte{0}, encodeLength(0))
+	assert.Equal(t, []byte{127}, encodeLength(127))
+	assert.Equal(t, []byte{0x80, 0x01}, encodeLength(128))
+	assert.Equal(t, []byte{0xff, 0x7f}, encodeLength(16383))
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+var errMuteNotFound = errors.New("mute not found")
+
+// Mute silences the notifications about a pair, or about a single transfer, until it is over
+type Mute struct {
+	Pair       string    `json:"pair,omitempty"`
+	TransferId uint64    `json:"transferId,omitempty"`
+	Until      time.Time `json:"until"`
+}
+
+func (m Mute) String() string {
+	if m.TransferId != 0 {
+		return fmt.Sprintf("transfer %v", m.TransferId)
+	}
+	return m.Pair
+}
+
+// Whether the event is about a muted pair or transfer, critical events getting through regardless
+func isMuted(event Event) bool {
+	if isCritical(event.Kind) || (event.Pair == "" && event.TransferId == 0) {
+		return false
+	}
+	state, err := loadState()
+	if err != nil {
+		log.Printf("isMuted: %v", err)
+		return false
+	}
+	for _, mute := range state.Mutes {
+		if !event.Time.Before(mute.Until) {
+			continue
+		}
+		if (mute.Pair != "" && mute.Pair == event.Pair) || (mute.TransferId != 0 && mute.TransferId == event.TransferId) {
+			return true
+		}
+	}
+	return false
+}
+
+// Add or extend the mute of the pair or transfer, dropping the mutes already over
+func addMute(mute Mute, now time.Time) error {
+	return updateState(func(state *State) error {
+		mutes := []Mute{mute}
+		for _, m := range state.Mutes {
+			if m.Until.After(now) && (m.Pair != mute.Pair || m.TransferId != mute.TransferId) {
+				mutes = append(mutes, m)
+			}
+		}
+		state.Mutes = mutes
+		return nil
+	})
+}
+
+func removeMute(mute Mute) error {
+	return updateState(func(state *State) error {
+		for i, m := range state.Mutes {
+			if m.Pair == mute.Pair && m.TransferId == mute.TransferId {
+				state.Mutes = append(state.Mutes[:i], state.Mutes[i+1:]...)
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: %v", errMuteNotFound, mute)
+	})
+}
+
+// Carry the snooze of a transfer over to the transfer it was re-booked as
+func moveMute(oldTransferId uint64, newTransferId uint64) error {
+	return updateState(func(state *State) error {
+		for i := range state.Mutes {
+			if state.Mutes[i].TransferId == oldTransferId {
+				state.Mutes[i].TransferId = newTransferId
+			}
+		}
+		return nil
+	})
+}
+
+// The mute a pair like EUR-USD or a transfer id designates
+func parseMuteTarget(arg string) (Mute, error) {
+	if id, err := strconv.ParseUint(arg, 10, 64); err == nil {
+		return Mute{TransferId: id}, nil
+	}
+	source, target, err := splitPairKey(arg)
+	if err != nil {
+		return Mute{}, err
+	}
+	return Mute{Pair: pairKey(source, target)}, nil
+}
+
+func runMuteCommand(args []string) error {
+	usage := fmt.Errorf("usage: mute (--pair <source>-<target> | --transfer <id>) --for <duration> | " +
+		"mute list [--output json] | mute remove <pair|transferId>")
+	if len(args) == 0 {
+		return usage
+	}
+
+	switch args[0] {
+	case "list":
+		flags := flag.NewFlagSet("mute list", flag.ContinueOnError)
+		output := outputFlag(flags)
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		state, err := loadState()
+		if err != nil {
+			return err
+		}
+		now := time.Now().UTC()
+		mutes := []Mute{}
+		for _, mute := range state.Mutes {
+			if mute.Until.After(now) {
+				mutes = append(mutes, mute)
+			}
+		}
+		return printOutput(os.Stdout, *output, mutes, func(w io.Writer) {
+			tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "MUTED\tUNTIL")
+			for _, mute := range mutes {
+				fmt.Fprintf(tw, "%v\t%v\n", mute, mute.Until.Format(time.RFC3339))
+			}
+			_ = tw.Flush()
+		})
+	case "remove":
+		if len(args) != 2 {
+			return usage
+		}
+		mute, err := parseMuteTarget(args[1])
+		if err != nil {
+			return usage
+		}
+		return removeMute(mute)
+	}
+
+	flags := flag.NewFlagSet("mute", flag.ContinueOnError)
+	pair := flags.String("pair", "", "currency pair like EUR-USD")
+	transfer := flags.Uint64("transfer", 0, "transfer ID to snooze")
+	duration := flags.Duration("for", 0, "how long to mute the notifications for, like 48h")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *duration <= 0 || (*pair == "") == (*transfer == 0) {
+		return usage
+	}
+	mute := Mute{TransferId: *transfer}
+	if *pair != "" {
+		source, target, err := splitPairKey(*pair)
+		if err != nil {
+			return usage
+		}
+		mute.Pair = pairKey(source, target)
+	}
+
+	now := time.Now().UTC()
+	mute.Until = now.Add(*duration)
+	if err := addMute(mute, now); err != nil {
+		return err
+	}
+	fmt.Printf("Notifications about %v muted until %v\n", mute, mute.Until.Format(time.RFC3339))
+	return nil
+}
+
+func init() {
+	registerCommand("mute", Command{
+		Usage: "mute --pair|--transfer|list|remove ...       mute the notifications about a pair or transfer",
+		Run:   runMuteCommand,
+	})
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMute(t *testing.T) {
+	defer func(file, limit string) { stateFileVar, notifyRateLimitVar = file, limit }(stateFileVar, notifyRateLimitVar)
+	dir, _ := ioutil.TempDir("", "transferwisely")
+	defer os.RemoveAll(dir)
+	stateFileVar = filepath.Join(dir, "state.json")
+	notifyRateLimitVar = "0"
+
+	fake := &fakeNotifier{}
+	defer func(n []Notifier) { Notifiers = n }(Notifiers)
+	Notifiers = []Notifier{fake}
+
+	assert.NoError(t, runMuteCommand([]string{"--pair", "EUR-USD", "--for", "48h"}))
+	assert.NoError(t, runMuteCommand([]string{"--transfer", "7", "--for", "1h"}))
+	assert.Error(t, runMuteCommand([]string{"--pair", "EUR-USD", "--transfer", "7", "--for", "1h"}))
+	assert.Error(t, runMuteCommand([]string{"--pair", "EUR-USD"}))
+
+	notify(Event{Kind: EventAlert, Pair: "EUR-USD"})
+	notify(Event{Kind: EventStatusChanged, Pair: "GBP-INR", TransferId: 7})
+	notify(Event{Kind: EventExpiryImminent, Pair: "EUR-USD", TransferId: 8})
+	notify(Event{Kind: EventAlert, Pair: "GBP-INR"})
+	notify(Event{Kind: EventAlert, Pair: "EUR-USD", Time: time.Now().UTC().Add(49 * time.Hour)})
+	assert.Len(t, fake.events, 3)
+	assert.Equal(t, EventExpiryImminent, fake.events[0].Kind, "critical events get through")
+	assert.Equal(t, "GBP-INR", fake.events[1].Pair)
+	assert.Equal(t, "EUR-USD", fake.events[2].Pair, "the mute is over")
+
+	t.Run("the snooze follows a re-booking", func(t *testing.T) {
+		assert.NoError(t, moveMute(7, 9))
+		state, _ := loadState()
+		assert.Equal(t, uint64(9), state.Mutes[0].TransferId)
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		assert.NoError(t, runMuteCommand([]string{"remove", "EUR-USD"}))
+		assert.Error(t, runMuteCommand([]string{"remove", "EUR-USD"}))
+		fake.events = nil
+		notify(Event{Kind: EventAlert, Pair: "EUR-USD"})
+		assert.Len(t, fake.events, 1)
+	})
+}
@@ -0,0 +1,76 @@
+package nats
+
+import (
+	"bufio"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"strings"
+	"testing"
+)
+
+// Accept one connection, greeting it and answering its PINGs, with errReply instead of the first PONG when given, and
+// collecting the lines it sends
+func fakeServer(t *testing.T, errReply string) (string, chan []string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+
+	lines := make(chan []string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = conn.Write([]byte(`INFO {"server_id":"test","max_payload":1048576}` + "\r\n"))
+		r := bufio.NewReader(conn)
+		var received []string
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				lines <- received
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			received = append(received, line)
+			if line == "PING" {
+				if errReply != "" {
+					_, _ = conn.Write([]byte(errReply + "\r\n"))
+					errReply = ""
+					continue
+				}
+				_, _ = conn.Write([]byte("PONG\r\n"))
+			}
+		}
+	}()
+	return "nats://" + listener.Addr().String(), lines
+}
+
+func TestPublish(t *testing.T) {
+	server, lines := fakeServer(t, "")
+	client, err := Connect(strings.Replace(server, "nats://", "nats://user:pass@", 1), Options{Name: "transferwisely"})
+	assert.NoError(t, err)
+	assert.NoError(t, client.Publish(Message{Subject: "transferwisely.rates", Payload: []byte(`{"liveRate":105.2}`)},
+		Message{Subject: "transferwisely.events", Payload: []byte(`{}`)}))
+	assert.NoError(t, client.Close())
+
+	received := <-lines
+	if assert.Len(t, received, 7) {
+		assert.Contains(t, received[0], `"name":"transferwisely","user":"user","pass":"pass"`)
+		assert.Equal(t, []string{"PING", "PUB transferwisely.rates 18", `{"liveRate":105.2}`, "PUB transferwisely.events 2",
+			"{}", "PING"}, received[1:])
+	}
+}
+
+func TestConnectRefused(t *testing.T) {
+	server, _ := fakeServer(t, "-ERR 'Authorization Violation'")
+	_, err := Connect(server, Options{Token: "secret"})
+	assert.EqualError(t, err, "server error: Authorization Violation")
+}
+
+func TestValidateServer(t *testing.T) {
+	assert.NoError(t, ValidateServer("nats://nats.local:4222"))
+	assert.NoError(t, ValidateServer("tls://token@nats.example.com"))
+	assert.Error(t, ValidateServer("http://nats.example.com"))
+	assert.Error(t, ValidateServer("nats.example.com"))
+}
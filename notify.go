@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventKind identifies what happened to notify about
+type EventKind string
+
+// event kinds
+const (
+	EventRebooked          EventKind = "rebooked"
+	EventExpiryReminder    EventKind = "expiry-reminder"
+	EventError             EventKind = "error"
+	EventNoActionDigest    EventKind = "no-action-digest"
+	EventQuietHoursDigest  EventKind = "quiet-hours-digest"
+	EventRateLimitDigest   EventKind = "rate-limit-digest"
+	EventProposal          EventKind = "proposal"
+	EventExpiryImminent    EventKind = "expiry-imminent"
+	EventStatusChanged     EventKind = "status-changed"
+	EventAlert             EventKind = "alert"
+	EventFundingReminder   EventKind = "funding-reminder"
+	EventFundingOverdue    EventKind = "funding-overdue"
+	EventTransferCompleted EventKind = "transfer-completed"
+	EventAPIDown           EventKind = "api-down"
+	EventAPIRecovered      EventKind = "api-recovered"
+	EventRateDigest        EventKind = "rate-digest"
+	EventSourceRanking     EventKind = "source-ranking"
+	EventLowBalance        EventKind = "low-balance"
+	EventRateDeviation     EventKind = "rate-deviation"
+	EventCancelRefused     EventKind = "cancel-refused"
+	EventStartupReport     EventKind = "startup-report"
+	EventManualAction      EventKind = "manual-action"
+	EventTokenInvalid      EventKind = "token-invalid"
+	EventQuoteSpread       EventKind = "quote-spread"
+)
+
+// Event is what gets fanned out to every configured notification channel
+type Event struct {
+	Kind    EventKind `json:"kind"`
+	Subject string    `json:"subject"`
+	Text    string    `json:"text"`
+	Time    time.Time `json:"time"`
+
+	// event specific details the mail templates render, see mailtemplates.go
+	Data interface{} `json:"-"`
+
+	// files attached to the mails, other channels leaving them out
+	Attachments []Attachment `json:"-"`
+
+	// optional call to action, shown as a button by the channels supporting one
+	ActionLabel string `json:"actionLabel,omitempty"`
+	ActionURL   string `json:"actionUrl,omitempty"`
+
+	// pair and transfer the event is about, if any, see mute.go
+	Pair       string `json:"pair,omitempty"`
+	TransferId uint64 `json:"transferId,omitempty"`
+
+	// account the event is about, see accounts.go
+	Account string `json:"account,omitempty"`
+}
+
+// Notifier delivers events to a single notification channel
+type Notifier interface {
+	Name() string
+	Notify(event Event) error
+}
+
+var (
+	Notifiers []Notifier
+)
+
+// names of the notification channels to route events to in CONFIG_FILE, allChannels standing for all of them
+var notificationChannels = []string{"email", "slack", "teams", "telegram", "webhook", "ntfy", "pushover", "matrix", "gotify",
+	"desktop"}
+
+const allChannels = "all"
+
+func init() {
+	Notifiers = getConfiguredNotifiers()
+}
+
+// Every channel whose env variables are provided gets notified
+func getConfiguredNotifiers() (notifiers []Notifier) {
+	if isMailConfigured() {
+		notifiers = append(notifiers, &emailNotifier{})
+	}
+	if slackWebhookURLVar != "" {
+		notifiers = append(notifiers, &slackNotifier{webhookURL: slackWebhookURLVar})
+	}
+	if teamsWebhookURLVar != "" {
+		notifiers = append(notifiers, &teamsNotifier{webhookURL: teamsWebhookURLVar})
+	}
+	if telegramBotTokenVar != "" && telegramChatIdVar != "" {
+		notifiers = append(notifiers, &telegramNotifier{botToken: telegramBotTokenVar, chatId: telegramChatIdVar})
+	}
+	if webhookURLVar != "" {
+		notifiers = append(notifiers, &webhookNotifier{url: webhookURLVar, secret: webhookSecretVar})
+	}
+	if ntfyTopicVar != "" {
+		priority, _ := getPushPriority("NTFY_PRIORITY", ntfyPriorityVar, ntfyMinPriority, ntfyMaxPriority)
+		notifiers = append(notifiers, &ntfyNotifier{server: ntfyServerVar, topic: ntfyTopicVar, token: ntfyTokenVar, priority: priority})
+	}
+	if pushoverTokenVar != "" && pushoverUserVar != "" {
+		notifiers = append(notifiers, &pushoverNotifier{token: pushoverTokenVar, user: pushoverUserVar})
+	}
+	if matrixHomeserverVar != "" && matrixAccessTokenVar != "" && matrixRoomIdVar != "" {
+		notifiers = append(notifiers, &matrixNotifier{homeserver: matrixHomeserverVar, accessToken: matrixAccessTokenVar, roomId: matrixRoomIdVar})
+	}
+	if gotifyURLVar != "" && gotifyTokenVar != "" {
+		priority, _ := getPushPriority("GOTIFY_PRIORITY", gotifyPriorityVar, gotifyMinPriority, gotifyMaxPriority)
+		notifiers = append(notifiers, &gotifyNotifier{url: gotifyURLVar, token: gotifyTokenVar, priority: priority})
+	}
+	if desktop, _ := strconv.ParseBool(desktopNotifyVar); desktop {
+		notifiers = append(notifiers, newDesktopNotifier())
+	}
+	return
+}
+
+// Notify all configured notifiers, holding non critical events back during quiet hours, and leaving the ones the
+// rate digest summarizes out with RATE_DIGEST_ONLY
+func notify(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	if event.Account == "" {
+		event.Account = getCurrentAccount()
+	}
+	if event.Account != defaultAccount && !strings.HasPrefix(event.Subject, "["+event.Account+"]") {
+		event.Subject = "[" + event.Account + "] " + event.Subject
+	}
+	publishEventBus(busEvent, event.Pair, event)
+	if isDigested(event.Kind) {
+		log.Printf("notify: RATE_DIGEST_ONLY, leaving %v out for the rate digest", event.Kind)
+		return
+	}
+	if isMuted(event) {
+		log.Printf("notify: muted, dropping %v notification about %v transfer %v", event.Kind, event.Pair, event.TransferId)
+		return
+	}
+	if !isCritical(event.Kind) && queueIfQuiet(event) {
+		return
+	}
+
+	dispatch(event)
+}
+
+// Fan the event out to the configured notifiers it is routed to concurrently, logging the ones that failed and
+// holding the non critical ones back for a digest when the channel is rate limited
+func dispatch(event Event) {
+	routes := accountRoutes(event)
+	var wg sync.WaitGroup
+	for _, notifier := range Notifiers {
+		if !isRouted(routes, event.Kind, notifier.Name()) {
+			continue
+		}
+		if isRateLimited(event.Kind) {
+			allowed, err := allowNotification(notifier.Name(), event.Time)
+			if err != nil {
+				log.Printf("notify: %v: %v", notifier.Name(), err)
+			}
+			if !allowed {
+				log.Printf("notify: %v: rate limited, holding %v notification back for the digest", notifier.Name(), event.Kind)
+				queueLimited(notifier.Name(), event)
+				continue
+			}
+		}
+
+		wg.Add(1)
+		go func(notifier Notifier) {
+			defer wg.Done()
+			if err := notifier.Notify(event); err != nil {
+				log.Printf("notify: %v: %v", notifier.Name(), err)
+			}
+		}(notifier)
+	}
+	wg.Wait()
+}
+
+// Whether the routes send the event kind to the channel, every channel getting the kinds without a route
+func isRouted(routes map[EventKind][]string, kind EventKind, channel string) bool {
+	channels, ok := routes[kind]
+	if !ok {
+		return true
+	}
+	for _, c := range channels {
+		if c == channel || c == allChannels {
+			return true
+		}
+	}
+	return false
+}
+
+func isNotificationChannel(name string) bool {
+	for _, channel := range notificationChannels {
+		if channel == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Notify the error, but for an invalid API token, alerted about once by its own token-invalid event
+func notifyError(subject string, err error) {
+	if errors.Is(err, errTokenInvalid) {
+		log.Printf("notifyError: %v: %v, already alerted", subject, err)
+		return
+	}
+	notify(Event{Kind: EventError, Subject: subject, Text: err.Error(), Data: ErrorMailData{Error: err.Error()}})
+}
+
+// POST payload as JSON to url, shared by all the HTTP based notifiers
+func postJSON(url string, payload interface{}) error {
+	return postJSONWithHeaders(url, nil, payload)
+}
+
+func postJSONWithHeaders(url string, headers map[string]string, payload interface{}) error {
+	return sendJSON(http.MethodPost, url, headers, payload)
+}
+
+func sendJSON(method string, url string, headers map[string]string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("error encoding notification payload: %v", err)
+	}
+
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating notification request: %v", err)
+	}
+	req.Header.Add("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Add(key, value)
+	}
+
+	res, err := Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending notification: %v", err)
+	}
+	_ = res.Body.Close()
+	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("error sending notification: %v", res.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// events worth popping up on a laptop: a better rate found or booked, and the booked rate about to expire
+var desktopEvents = map[EventKind]bool{
+	EventRebooked:       true,
+	EventProposal:       true,
+	EventAlert:          true,
+	EventExpiryReminder: true,
+	EventExpiryImminent: true,
+}
+
+// shows a Windows toast through the WinRT notification API, the PowerShell of Windows 10 and later loading it
+const desktopToastScript = `[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] > $null
+[Windows.Data.Xml.Dom.XmlDocument, Windows.Data.Xml.Dom.XmlDocument, ContentType = WindowsRuntime] > $null
+$xml = New-Object Windows.Data.Xml.Dom.XmlDocument
+$xml.LoadXml('<toast><visual><binding template="ToastGeneric"><text>%v</text><text>%v</text></binding></visual></toast>')
+[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier('transferwisely').Show([Windows.UI.Notifications.ToastNotification]::new($xml))`
+
+// runs the OS notification command
+var runDesktopCommand = func(name string, args ...string) error {
+	out, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%v: %v %v", name, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// desktopNotifier pops the events up on the desktop the batch runs on, with notify-send on linux, osascript on macOS
+// and a toast on Windows, for running it on a laptop rather than a server
+type desktopNotifier struct {
+	goos string
+}
+
+func (n *desktopNotifier) Name() string {
+	return "desktop"
+}
+
+func (n *desktopNotifier) Notify(event Event) error {
+	if !desktopEvents[event.Kind] {
+		return nil
+	}
+	text := event.Text
+	if event.ActionURL != "" {
+		text += "\n" + event.ActionLabel + ": " + event.ActionURL
+	}
+
+	switch n.goos {
+	case "linux", "freebsd", "openbsd", "netbsd":
+		urgency := "normal"
+		if isCritical(event.Kind) {
+			urgency = "critical"
+		}
+		return runDesktopCommand("notify-send", "--app-name=transferwisely", "--urgency="+urgency, event.Subject, text)
+	case "darwin":
+		return runDesktopCommand("osascript", "-e", fmt.Sprintf("display notification %v with title %v",
+			appleScriptString(text), appleScriptString(event.Subject)))
+	case "windows":
+		return runDesktopCommand("powershell", "-NoProfile", "-NonInteractive", "-Command", fmt.Sprintf(desktopToastScript,
+			powerShellQuote(html.EscapeString(event.Subject)), powerShellQuote(html.EscapeString(text))))
+	default:
+		return fmt.Errorf("desktop notifications aren't supported on %v", n.goos)
+	}
+}
+
+func newDesktopNotifier() *desktopNotifier {
+	return &desktopNotifier{goos: runtime.GOOS}
+}
+
+// Quote s as an AppleScript string literal
+func appleScriptString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// Escape s for a single quoted PowerShell string
+func powerShellQuote(s string) string {
+	return strings.NewReplacer("'", "''", "‘", "''", "’", "''").Replace(s)
+}
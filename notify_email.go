@@ -0,0 +1,16 @@
+package main
+
+// emailNotifier sends events as HTML mails to TO_MAIL, or the recipients of their account, rendered with the mail template of their kind
+type emailNotifier struct{}
+
+func (n *emailNotifier) Name() string {
+	return "email"
+}
+
+func (n *emailNotifier) Notify(event Event) error {
+	subject, body, err := renderMail(event)
+	if err != nil {
+		return err
+	}
+	return sendMailTo(accountMailRecipients(event), subject, []byte(body), event.Attachments...)
+}
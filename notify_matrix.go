@@ -0,0 +1,48 @@
+package main
+
+import (
+	"github.com/google/uuid"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// matrix client-server api path sending a message event to a room
+const matrixSendMessagePath = "/_matrix/client/v3/rooms/{roomId}/send/m.room.message/{txnId}"
+
+// matrixNotifier sends events as formatted messages to a Matrix room, as the user of the access token
+type matrixNotifier struct {
+	homeserver  string
+	accessToken string
+	roomId      string
+}
+
+func (n *matrixNotifier) Name() string {
+	return "matrix"
+}
+
+func (n *matrixNotifier) Notify(event Event) error {
+	// the transaction id makes the homeserver ignore retries of the same message
+	path := strings.Replace(matrixSendMessagePath, "{roomId}", url.PathEscape(n.roomId), 1)
+	path = strings.Replace(path, "{txnId}", uuid.New().String(), 1)
+
+	formatted := "<strong>" + html.EscapeString(event.Subject) + "</strong><br>" +
+		strings.Replace(html.EscapeString(event.Text), "\n", "<br>", -1)
+	text := event.Subject + "\n\n" + event.Text
+	if event.ActionURL != "" {
+		formatted += `<br><a href="` + html.EscapeString(event.ActionURL) + `">` + html.EscapeString(event.ActionLabel) + "</a>"
+		text += "\n" + event.ActionLabel + ": " + event.ActionURL
+	}
+
+	return sendJSON(http.MethodPut, strings.TrimRight(n.homeserver, "/")+path,
+		map[string]string{"Authorization": "Bearer " + n.accessToken},
+		MatrixMessage{MsgType: "m.text", Body: text, Format: "org.matrix.custom.html", FormattedBody: formatted})
+}
+
+type MatrixMessage struct {
+	MsgType       string `json:"msgtype"`
+	Body          string `json:"body"`
+	Format        string `json:"format"`
+	FormattedBody string `json:"formatted_body"`
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// priority ranges of the push notification services
+const (
+	ntfyMinPriority   = 1
+	ntfyMaxPriority   = 5
+	gotifyMinPriority = 0
+	gotifyMaxPriority = 10
+)
+
+// ntfyNotifier publishes events to a topic of an ntfy server, ntfy.sh by default
+type ntfyNotifier struct {
+	server   string
+	topic    string
+	token    string
+	priority int
+}
+
+func (n *ntfyNotifier) Name() string {
+	return "ntfy"
+}
+
+func (n *ntfyNotifier) Notify(event Event) error {
+	var headers map[string]string
+	if n.token != "" {
+		headers = map[string]string{"Authorization": "Bearer " + n.token}
+	}
+	message := NtfyMessage{
+		Topic:    n.topic,
+		Title:    event.Subject,
+		Message:  event.Text,
+		Priority: n.priority,
+		Tags:     []string{string(event.Kind)},
+	}
+	if event.ActionURL != "" {
+		message.Actions = []NtfyAction{{Action: "view", Label: event.ActionLabel, URL: event.ActionURL}}
+	}
+	return postJSONWithHeaders(strings.TrimRight(n.server, "/"), headers, message)
+}
+
+// gotifyNotifier pushes events as messages of a Gotify application
+type gotifyNotifier struct {
+	url      string
+	token    string
+	priority int
+}
+
+func (n *gotifyNotifier) Name() string {
+	return "gotify"
+}
+
+func (n *gotifyNotifier) Notify(event Event) error {
+	return postJSONWithHeaders(strings.TrimRight(n.url, "/")+"/message", map[string]string{"X-Gotify-Key": n.token},
+		GotifyMessage{Title: event.Subject, Message: event.Text, Priority: n.priority})
+}
+
+func getPushPriority(key string, value string, min int, max int) (int, error) {
+	priority, err := strconv.Atoi(value)
+	if err != nil || priority < min || priority > max {
+		return 0, fmt.Errorf("invalid value for %v: %v, expected %v to %v", key, value, min, max)
+	}
+	return priority, nil
+}
+
+type NtfyMessage struct {
+	Topic    string   `json:"topic"`
+	Title    string   `json:"title"`
+	Message  string   `json:"message"`
+	Priority int      `json:"priority"`
+	Tags     []string `json:"tags"`
+
+	Actions []NtfyAction `json:"actions,omitempty"`
+}
+
+type NtfyAction struct {
+	Action string `json:"action"`
+	Label  string `json:"label"`
+	URL    string `json:"url"`
+}
+
+type GotifyMessage struct {
+	Title    string `json:"title"`
+	Message  string `json:"message"`
+	Priority int    `json:"priority"`
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// pushover api url and priorities
+const (
+	pushoverMessagesURL       = "https://api.pushover.net/1/messages.json"
+	pushoverLowestPriority    = -2
+	pushoverHighPriority      = 1
+	pushoverEmergencyPriority = 2
+	pushoverMinRetry          = 30 * time.Second
+	pushoverMaxExpire         = 3 * time.Hour
+)
+
+// default priority of the events, the CONFIG_FILE pushover priorities taking precedence
+var pushoverPriorities = map[EventKind]int{
+	EventError:          pushoverHighPriority,
+	EventExpiryImminent: pushoverEmergencyPriority,
+	EventFundingOverdue: pushoverEmergencyPriority,
+	EventAPIDown:        pushoverHighPriority,
+	EventManualAction:   pushoverHighPriority,
+	EventTokenInvalid:   pushoverHighPriority,
+}
+
+// pushoverNotifier pushes events to a Pushover user or group, emergency priority ones until acknowledged
+type pushoverNotifier struct {
+	token string
+	user  string
+}
+
+func (n *pushoverNotifier) Name() string {
+	return "pushover"
+}
+
+func (n *pushoverNotifier) Notify(event Event) error {
+	priority, err := getPushoverPriority(event.Kind)
+	if err != nil {
+		return err
+	}
+	message := PushoverMessage{
+		Token:    n.token,
+		User:     n.user,
+		Title:    event.Subject,
+		Message:  event.Text,
+		Priority: priority,
+		URL:      event.ActionURL,
+		URLTitle: event.ActionLabel,
+	}
+
+	// emergency notifications repeat every retry seconds until acknowledged or expired
+	if priority == pushoverEmergencyPriority {
+		retry, expire, err := getPushoverRetry()
+		if err != nil {
+			return err
+		}
+		message.Retry, message.Expire = int(retry.Seconds()), int(expire.Seconds())
+	}
+	return postJSON(pushoverMessagesURL, message)
+}
+
+func getPushoverPriority(kind EventKind) (int, error) {
+	if priority, ok := getConfig().Pushover.Priorities[kind]; ok {
+		return priority, nil
+	}
+	if priority, ok := pushoverPriorities[kind]; ok {
+		return priority, nil
+	}
+	return getPushPriority("PUSHOVER_PRIORITY", pushoverPriorityVar, pushoverLowestPriority, pushoverEmergencyPriority)
+}
+
+func getPushoverRetry() (retry time.Duration, expire time.Duration, err error) {
+	retrySeconds, err := strconv.ParseUint(pushoverRetryVar, 10, 64)
+	retry = time.Duration(retrySeconds) * time.Second
+	if err != nil || retry < pushoverMinRetry {
+		return 0, 0, fmt.Errorf("invalid value for PUSHOVER_RETRY: %v, expected at least %v", pushoverRetryVar, pushoverMinRetry)
+	}
+
+	expireSeconds, err := strconv.ParseUint(pushoverExpireVar, 10, 64)
+	expire = time.Duration(expireSeconds) * time.Second
+	if err != nil || expire > pushoverMaxExpire {
+		return 0, 0, fmt.Errorf("invalid value for PUSHOVER_EXPIRE: %v, expected at most %v", pushoverExpireVar, pushoverMaxExpire)
+	}
+	return retry, expire, nil
+}
+
+type PushoverMessage struct {
+	Token    string `json:"token"`
+	User     string `json:"user"`
+	Title    string `json:"title"`
+	Message  string `json:"message"`
+	Priority int    `json:"priority"`
+	Retry    int    `json:"retry,omitempty"`
+	Expire   int    `json:"expire,omitempty"`
+	URL      string `json:"url,omitempty"`
+	URLTitle string `json:"url_title,omitempty"`
+}
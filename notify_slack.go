@@ -0,0 +1,18 @@
+package main
+
+// slackNotifier posts events to a Slack incoming webhook
+type slackNotifier struct {
+	webhookURL string
+}
+
+func (n *slackNotifier) Name() string {
+	return "slack"
+}
+
+func (n *slackNotifier) Notify(event Event) error {
+	return postJSON(n.webhookURL, SlackMessage{Text: "*" + event.Subject + "*\n" + event.Text})
+}
+
+type SlackMessage struct {
+	Text string `json:"text"`
+}
@@ -0,0 +1,102 @@
+package main
+
+import "strings"
+
+// adaptive card content type and schema, version 1.4 being the latest Teams renders on every client
+const (
+	adaptiveCardContentType = "application/vnd.microsoft.card.adaptive"
+	adaptiveCardSchema      = "http://adaptivecards.io/schemas/adaptive-card.json"
+	adaptiveCardVersion     = "1.4"
+)
+
+// teamsNotifier posts events as Adaptive Cards to a Microsoft Teams incoming webhook
+type teamsNotifier struct {
+	webhookURL string
+}
+
+func (n *teamsNotifier) Name() string {
+	return "teams"
+}
+
+func (n *teamsNotifier) Notify(event Event) error {
+	return postJSON(n.webhookURL, TeamsMessage{
+		Type:        "message",
+		Attachments: []TeamsAttachment{{ContentType: adaptiveCardContentType, Content: newAdaptiveCard(event)}},
+	})
+}
+
+// The subject as the card's title, colored by how the event went, and the text below it, its "Key: value" lines
+// like "Rate: 0.69 (was 0.68)" grouped into fact sets so transfer details read as a table
+func newAdaptiveCard(event Event) AdaptiveCard {
+	title := AdaptiveCardElement{Type: "TextBlock", Text: event.Subject, Weight: "Bolder", Size: "Medium", Wrap: true}
+	switch {
+	case isCritical(event.Kind):
+		title.Color = "Attention"
+	case event.Kind == EventRebooked || event.Kind == EventTransferCompleted || event.Kind == EventAPIRecovered:
+		title.Color = "Good"
+	}
+
+	card := AdaptiveCard{Type: "AdaptiveCard", Schema: adaptiveCardSchema, Version: adaptiveCardVersion,
+		Body: []AdaptiveCardElement{title}}
+	// index of the fact set the following "Key: value" lines go to, -1 starting a new one
+	facts := -1
+	for _, line := range strings.Split(event.Text, "\n") {
+		if strings.TrimSpace(line) == "" {
+			facts = -1
+			continue
+		}
+		if i := strings.Index(line, ": "); i > 0 {
+			if facts < 0 {
+				card.Body = append(card.Body, AdaptiveCardElement{Type: "FactSet"})
+				facts = len(card.Body) - 1
+			}
+			card.Body[facts].Facts = append(card.Body[facts].Facts, AdaptiveCardFact{Title: line[:i], Value: line[i+2:]})
+			continue
+		}
+		card.Body = append(card.Body, AdaptiveCardElement{Type: "TextBlock", Text: line, Wrap: true})
+		facts = -1
+	}
+	if event.ActionURL != "" {
+		card.Actions = []AdaptiveCardAction{{Type: "Action.OpenUrl", Title: event.ActionLabel, URL: event.ActionURL}}
+	}
+	return card
+}
+
+type TeamsMessage struct {
+	Type        string            `json:"type"`
+	Attachments []TeamsAttachment `json:"attachments"`
+}
+
+type TeamsAttachment struct {
+	ContentType string       `json:"contentType"`
+	Content     AdaptiveCard `json:"content"`
+}
+
+type AdaptiveCard struct {
+	Type    string                `json:"type"`
+	Schema  string                `json:"$schema"`
+	Version string                `json:"version"`
+	Body    []AdaptiveCardElement `json:"body"`
+	Actions []AdaptiveCardAction  `json:"actions,omitempty"`
+}
+
+type AdaptiveCardElement struct {
+	Type   string             `json:"type"`
+	Text   string             `json:"text,omitempty"`
+	Weight string             `json:"weight,omitempty"`
+	Size   string             `json:"size,omitempty"`
+	Color  string             `json:"color,omitempty"`
+	Wrap   bool               `json:"wrap,omitempty"`
+	Facts  []AdaptiveCardFact `json:"facts,omitempty"`
+}
+
+type AdaptiveCardFact struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+}
+
+type AdaptiveCardAction struct {
+	Type  string `json:"type"`
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
@@ -0,0 +1,42 @@
+package main
+
+import "strings"
+
+// telegram bot api url
+const telegramSendMessageURL = "https://api.telegram.org/bot{botToken}/sendMessage"
+
+// telegramNotifier sends events as messages from a Telegram bot to a chat
+type telegramNotifier struct {
+	botToken string
+	chatId   string
+}
+
+func (n *telegramNotifier) Name() string {
+	return "telegram"
+}
+
+func (n *telegramNotifier) Notify(event Event) error {
+	url := strings.Replace(telegramSendMessageURL, "{botToken}", n.botToken, 1)
+	message := TelegramMessage{ChatId: n.chatId, Text: event.Subject + "\n\n" + event.Text}
+	if event.ActionURL != "" {
+		message.ReplyMarkup = &TelegramReplyMarkup{
+			InlineKeyboard: [][]TelegramButton{{{Text: event.ActionLabel, URL: event.ActionURL}}},
+		}
+	}
+	return postJSON(url, message)
+}
+
+type TelegramMessage struct {
+	ChatId      string               `json:"chat_id"`
+	Text        string               `json:"text"`
+	ReplyMarkup *TelegramReplyMarkup `json:"reply_markup,omitempty"`
+}
+
+type TelegramReplyMarkup struct {
+	InlineKeyboard [][]TelegramButton `json:"inline_keyboard"`
+}
+
+type TelegramButton struct {
+	Text string `json:"text"`
+	URL  string `json:"url"`
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"transferwisely/mocks"
+)
+
+type fakeNotifier struct {
+	sync.Mutex
+	events []Event
+	err    error
+}
+
+func (n *fakeNotifier) Name() string {
+	return "fake"
+}
+
+func (n *fakeNotifier) Notify(event Event) error {
+	n.Lock()
+	defer n.Unlock()
+	n.events = append(n.events, event)
+	return n.err
+}
+
+func TestNotify(t *testing.T) {
+	defer func(notifiers []Notifier) { Notifiers = notifiers }(Notifiers)
+
+	first, second := &fakeNotifier{}, &fakeNotifier{err: errors.New("channel down")}
+	Notifiers = []Notifier{first, second}
+
+	notify(Event{Kind: EventRebooked, Subject: "subject", Text: "text"})
+	assert.Len(t, first.events, 1)
+	assert.Len(t, second.events, 1)
+	assert.Equal(t, EventRebooked, first.events[0].Kind)
+	assert.False(t, first.events[0].Time.IsZero())
+}
+
+func TestNotificationRoutes(t *testing.T) {
+	defer func(file string, c Config) { configFileVar, config.current = file, c }(configFileVar, getConfig())
+	dir, _ := ioutil.TempDir("", "transferwisely")
+	defer os.RemoveAll(dir)
+	configFileVar = filepath.Join(dir, "config.json")
+	_ = ioutil.WriteFile(configFileVar, []byte(`{"notifications": {"routes": {
+		"error": ["pushover"], "rebooked": ["email", "slack"], "no-action-digest": [], "expiry-imminent": ["all"]
+	}}}`), 0600)
+	assert.NoError(t, loadConfig())
+
+	routes := getConfig().Notifications.Routes
+	tests := []struct {
+		kind     EventKind
+		channel  string
+		expected bool
+	}{
+		{EventError, "pushover", true},
+		{EventError, "email", false},
+		{EventRebooked, "slack", true},
+		{EventRebooked, "pushover", false},
+		{EventNoActionDigest, "email", false},
+		{EventExpiryImminent, "telegram", true},
+		{EventProposal, "matrix", true},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.expected, isRouted(routes, test.kind, test.channel), "%v to %v", test.kind, test.channel)
+	}
+
+	t.Run("unrouted channels aren't notified", func(t *testing.T) {
+		defer func(notifiers []Notifier, limit string) { Notifiers, notifyRateLimitVar = notifiers, limit }(Notifiers, notifyRateLimitVar)
+		fake := &fakeNotifier{}
+		Notifiers, notifyRateLimitVar = []Notifier{fake}, "0"
+
+		notify(Event{Kind: EventNoActionDigest})
+		notify(Event{Kind: EventExpiryImminent})
+		assert.Len(t, fake.events, 1)
+		assert.Equal(t, EventExpiryImminent, fake.events[0].Kind)
+	})
+
+	t.Run("unknown channel", func(t *testing.T) {
+		_ = ioutil.WriteFile(configFileVar, []byte(`{"notifications": {"routes": {"error": ["pager"]}}}`), 0600)
+		assert.Error(t, loadConfig())
+	})
+}
+
+func TestHTTPNotifiers(t *testing.T) {
+	var requests []*http.Request
+	var bodies []string
+	mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+		body, _ := ioutil.ReadAll(req.Body)
+		requests = append(requests, req)
+		bodies = append(bodies, string(body))
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(strings.NewReader("ok")),
+		}, nil
+	}
+	event := Event{Kind: EventError, Subject: "subject", Text: "text"}
+
+	t.Run("slack", func(t *testing.T) {
+		assert.NoError(t, (&slackNotifier{webhookURL: "https://hooks.slack.com/services/x"}).Notify(event))
+		assert.Equal(t, "hooks.slack.com", requests[len(requests)-1].URL.Host)
+		assert.Contains(t, bodies[len(bodies)-1], "*subject*")
+	})
+
+	t.Run("teams", func(t *testing.T) {
+		rebooked := Event{Kind: EventRebooked, Subject: "New transfer booked", Text: "Transfer ID: 2\n{GBP} --> {INR}\nRate: 100.5 (was 100.1)",
+			ActionLabel: "Open", ActionURL: "https://example.com/transfers/2"}
+		assert.NoError(t, (&teamsNotifier{webhookURL: "https://example.webhook.office.com/webhookb2/x"}).Notify(rebooked))
+		assert.Equal(t, "example.webhook.office.com", requests[len(requests)-1].URL.Host)
+		var message TeamsMessage
+		assert.NoError(t, json.Unmarshal([]byte(bodies[len(bodies)-1]), &message))
+		assert.Len(t, message.Attachments, 1)
+		assert.Equal(t, adaptiveCardContentType, message.Attachments[0].ContentType)
+
+		card := message.Attachments[0].Content
+		assert.Equal(t, []AdaptiveCardElement{
+			{Type: "TextBlock", Text: "New transfer booked", Weight: "Bolder", Size: "Medium", Color: "Good", Wrap: true},
+			{Type: "FactSet", Facts: []AdaptiveCardFact{{Title: "Transfer ID", Value: "2"}}},
+			{Type: "TextBlock", Text: "{GBP} --> {INR}", Wrap: true},
+			{Type: "FactSet", Facts: []AdaptiveCardFact{{Title: "Rate", Value: "100.5 (was 100.1)"}}},
+		}, card.Body)
+		assert.Equal(t, []AdaptiveCardAction{{Type: "Action.OpenUrl", Title: "Open", URL: "https://example.com/transfers/2"}}, card.Actions)
+
+		assert.Equal(t, "Attention", newAdaptiveCard(Event{Kind: EventExpiryImminent}).Body[0].Color)
+	})
+
+	t.Run("telegram", func(t *testing.T) {
+		assert.NoError(t, (&telegramNotifier{botToken: "token", chatId: "42"}).Notify(event))
+		assert.Equal(t, "/bottoken/sendMessage", requests[len(requests)-1].URL.Path)
+		var message TelegramMessage
+		assert.NoError(t, json.Unmarshal([]byte(bodies[len(bodies)-1]), &message))
+		assert.Equal(t, "42", message.ChatId)
+	})
+
+	t.Run("webhook", func(t *testing.T) {
+		assert.NoError(t, (&webhookNotifier{url: "https://example.com/hook"}).Notify(event))
+		var received Event
+		assert.NoError(t, json.Unmarshal([]byte(bodies[len(bodies)-1]), &received))
+		assert.Equal(t, EventError, received.Kind)
+		assert.Empty(t, requests[len(requests)-1].Header.Get(webhookSignatureHeader))
+	})
+
+	t.Run("signed webhook", func(t *testing.T) {
+		signed := Event{Kind: EventRebooked, Subject: "subject", Text: "text", Time: time.Unix(1700000000, 0).UTC(),
+			Data: RebookedMailData{Reason: "margin"}}
+		assert.NoError(t, (&webhookNotifier{url: "https://example.com/hook", secret: "s3cret"}).Notify(signed))
+		req, body := requests[len(requests)-1], bodies[len(bodies)-1]
+		assert.Equal(t, "1700000000", req.Header.Get(webhookTimestampHeader))
+		assert.Equal(t, "sha256="+signWebhook("s3cret", "1700000000", []byte(body)), req.Header.Get(webhookSignatureHeader))
+
+		var received map[string]interface{}
+		assert.NoError(t, json.Unmarshal([]byte(body), &received))
+		assert.NotEmpty(t, received["id"])
+		assert.Equal(t, "rebooked", received["kind"])
+		assert.Equal(t, "margin", received["data"].(map[string]interface{})["Reason"])
+	})
+
+	t.Run("ntfy", func(t *testing.T) {
+		assert.NoError(t, (&ntfyNotifier{server: "https://ntfy.sh/", topic: "rates", token: "tk", priority: 4}).Notify(event))
+		assert.Equal(t, "https://ntfy.sh", requests[len(requests)-1].URL.String())
+		assert.Equal(t, "Bearer tk", requests[len(requests)-1].Header.Get("Authorization"))
+		var message NtfyMessage
+		assert.NoError(t, json.Unmarshal([]byte(bodies[len(bodies)-1]), &message))
+		assert.Equal(t, NtfyMessage{Topic: "rates", Title: "subject", Message: "text", Priority: 4, Tags: []string{"error"}}, message)
+	})
+
+	t.Run("gotify", func(t *testing.T) {
+		assert.NoError(t, (&gotifyNotifier{url: "https://gotify.example.com", token: "app", priority: 8}).Notify(event))
+		assert.Equal(t, "/message", requests[len(requests)-1].URL.Path)
+		assert.Equal(t, "app", requests[len(requests)-1].Header.Get("X-Gotify-Key"))
+		var message GotifyMessage
+		assert.NoError(t, json.Unmarshal([]byte(bodies[len(bodies)-1]), &message))
+		assert.Equal(t, 8, message.Priority)
+	})
+
+	t.Run("pushover", func(t *testing.T) {
+		assert.NoError(t, (&pushoverNotifier{token: "app", user: "user"}).Notify(event))
+		assert.Equal(t, pushoverMessagesURL, requests[len(requests)-1].URL.String())
+		var message PushoverMessage
+		assert.NoError(t, json.Unmarshal([]byte(bodies[len(bodies)-1]), &message))
+		assert.Equal(t, pushoverHighPriority, message.Priority)
+		assert.Zero(t, message.Retry)
+
+		assert.NoError(t, (&pushoverNotifier{token: "app", user: "user"}).Notify(Event{Kind: EventExpiryImminent}))
+		assert.NoError(t, json.Unmarshal([]byte(bodies[len(bodies)-1]), &message))
+		assert.Equal(t, pushoverEmergencyPriority, message.Priority)
+		assert.Equal(t, 60, message.Retry)
+		assert.Equal(t, 3600, message.Expire)
+	})
+
+	t.Run("matrix", func(t *testing.T) {
+		notifier := &matrixNotifier{homeserver: "https://matrix.example.com/", accessToken: "syt", roomId: "!room:example.com"}
+		assert.NoError(t, notifier.Notify(Event{Kind: EventProposal, Subject: "subject", Text: "a < b\nc",
+			ActionLabel: "Approve", ActionURL: "https://example.com/approve"}))
+		req := requests[len(requests)-1]
+		assert.Equal(t, http.MethodPut, req.Method)
+		assert.True(t, strings.HasPrefix(req.URL.EscapedPath(), "/_matrix/client/v3/rooms/%21room:example.com/send/m.room.message/"))
+		assert.Equal(t, "Bearer syt", req.Header.Get("Authorization"))
+		var message MatrixMessage
+		assert.NoError(t, json.Unmarshal([]byte(bodies[len(bodies)-1]), &message))
+		assert.Equal(t, "subject\n\na < b\nc\nApprove: https://example.com/approve", message.Body)
+		assert.Equal(t, `<strong>subject</strong><br>a &lt; b<br>c<br><a href="https://example.com/approve">Approve</a>`,
+			message.FormattedBody)
+	})
+
+	t.Run("non 2xx response", func(t *testing.T) {
+		mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusBadRequest,
+				Body:       ioutil.NopCloser(strings.NewReader("bad request")),
+			}, nil
+		}
+		assert.Error(t, (&webhookNotifier{url: "https://example.com/hook"}).Notify(event))
+	})
+}
+
+func TestDesktopNotifier(t *testing.T) {
+	defer func(run func(string, ...string) error) { runDesktopCommand = run }(runDesktopCommand)
+	var commands [][]string
+	runDesktopCommand = func(name string, args ...string) error {
+		commands = append(commands, append([]string{name}, args...))
+		return nil
+	}
+	event := Event{Kind: EventExpiryImminent, Subject: `Rate "lock" expiring`, Text: "Transfer 7 expires in 1h",
+		ActionLabel: "Open", ActionURL: "https://wise.com/transactions"}
+
+	t.Run("linux", func(t *testing.T) {
+		assert.NoError(t, (&desktopNotifier{goos: "linux"}).Notify(event))
+		assert.Equal(t, []string{"notify-send", "--app-name=transferwisely", "--urgency=critical", `Rate "lock" expiring`,
+			"Transfer 7 expires in 1h\nOpen: https://wise.com/transactions"}, commands[len(commands)-1])
+	})
+
+	t.Run("macOS", func(t *testing.T) {
+		assert.NoError(t, (&desktopNotifier{goos: "darwin"}).Notify(event))
+		assert.Equal(t, []string{"osascript", "-e", `display notification "Transfer 7 expires in 1h` + "\n" +
+			`Open: https://wise.com/transactions" with title "Rate \"lock\" expiring"`}, commands[len(commands)-1])
+	})
+
+	t.Run("windows", func(t *testing.T) {
+		assert.NoError(t, (&desktopNotifier{goos: "windows"}).Notify(Event{Kind: EventRebooked, Subject: "Rebooked at 101",
+			Text: "Alice's <transfer>"}))
+		command := commands[len(commands)-1]
+		assert.Equal(t, "powershell", command[0])
+		assert.Contains(t, command[len(command)-1], "<text>Rebooked at 101</text><text>Alice&#39;s &lt;transfer&gt;</text>")
+	})
+
+	t.Run("other events left out", func(t *testing.T) {
+		count := len(commands)
+		assert.NoError(t, (&desktopNotifier{goos: "linux"}).Notify(Event{Kind: EventNoActionDigest}))
+		assert.Len(t, commands, count)
+	})
+
+	t.Run("unsupported OS", func(t *testing.T) {
+		assert.EqualError(t, (&desktopNotifier{goos: "plan9"}).Notify(event), "desktop notifications aren't supported on plan9")
+	})
+}
+
+func TestGetPushPriority(t *testing.T) {
+	priority, err := getPushPriority("NTFY_PRIORITY", "5", ntfyMinPriority, ntfyMaxPriority)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, priority)
+	_, err = getPushPriority("NTFY_PRIORITY", "6", ntfyMinPriority, ntfyMaxPriority)
+	assert.Error(t, err)
+	_, err = getPushPriority("GOTIFY_PRIORITY", "high", gotifyMinPriority, gotifyMaxPriority)
+	assert.Error(t, err)
+}
+
+func TestGetPushoverPriority(t *testing.T) {
+	defer func(c Config) { config.current = c }(getConfig())
+
+	priority, err := getPushoverPriority(EventRebooked)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, priority)
+
+	config.current = Config{Pushover: PushoverConfig{Priorities: map[EventKind]int{EventRebooked: 1, EventExpiryImminent: 1}}}
+	priority, _ = getPushoverPriority(EventRebooked)
+	assert.Equal(t, 1, priority)
+	priority, _ = getPushoverPriority(EventExpiryImminent)
+	assert.Equal(t, 1, priority)
+
+	assert.Error(t, validateOverrides(Config{Pushover: PushoverConfig{Priorities: map[EventKind]int{EventError: 3}}}))
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"strconv"
+)
+
+// headers of the signed webhook requests
+const (
+	webhookTimestampHeader = "X-Transferwisely-Timestamp"
+	webhookSignatureHeader = "X-Transferwisely-Signature"
+)
+
+// webhookNotifier posts events as JSON to an arbitrary URL, signed with WEBHOOK_SECRET if given
+type webhookNotifier struct {
+	url    string
+	secret string
+}
+
+// WebhookPayload is the event along with its details, the id letting receivers drop the deliveries they already got
+type WebhookPayload struct {
+	Id string `json:"id"`
+	Event
+	Data interface{} `json:"data,omitempty"`
+}
+
+func (n *webhookNotifier) Name() string {
+	return "webhook"
+}
+
+func (n *webhookNotifier) Notify(event Event) error {
+	body, err := json.Marshal(WebhookPayload{Id: uuid.New().String(), Event: event, Data: event.Data})
+	if err != nil {
+		return fmt.Errorf("error encoding notification payload: %v", err)
+	}
+
+	var headers map[string]string
+	if n.secret != "" {
+		timestamp := strconv.FormatInt(event.Time.Unix(), 10)
+		headers = map[string]string{
+			webhookTimestampHeader: timestamp,
+			webhookSignatureHeader: "sha256=" + signWebhook(n.secret, timestamp, body),
+		}
+	}
+	return postJSONWithHeaders(n.url, headers, json.RawMessage(body))
+}
+
+// HMAC-SHA256 of the timestamp and body joined by a dot, so a captured request can't be replayed with another timestamp
+func signWebhook(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	notifyRateLimitWindow  = time.Hour
+	rateLimitDigestSubject = "Digest: %v notifications held back by NOTIFY_RATE_LIMIT"
+)
+
+// notifications sent per channel within the last rate limit window
+var notifySent = struct {
+	sync.Mutex
+	channels map[string][]time.Time
+}{channels: map[string][]time.Time{}}
+
+// events held back per channel once over its limit, sent as a digest when the channel may notify again
+var limitedQueue = struct {
+	sync.Mutex
+	channels map[string][]Event
+}{channels: map[string][]Event{}}
+
+// Critical events, re-bookings and proposals always go out, however many notifications the channel already sent
+func isRateLimited(kind EventKind) bool {
+	return !isCritical(kind) && kind != EventRebooked && kind != EventProposal
+}
+
+func queueLimited(channel string, event Event) {
+	limitedQueue.Lock()
+	defer limitedQueue.Unlock()
+	limitedQueue.channels[channel] = append(limitedQueue.channels[channel], event)
+}
+
+// Send each channel the events held back by its rate limit as a single digest, once it may notify again outside
+// quiet hours
+func flushLimitedQueue() {
+	now := time.Now().UTC()
+	if quiet, err := inQuietHours(now); err != nil || quiet {
+		return
+	}
+	for _, notifier := range Notifiers {
+		limitedQueue.Lock()
+		events := limitedQueue.channels[notifier.Name()]
+		limitedQueue.Unlock()
+		if len(events) == 0 {
+			continue
+		}
+		if allowed, _ := allowNotification(notifier.Name(), now); !allowed {
+			continue
+		}
+
+		limitedQueue.Lock()
+		events = limitedQueue.channels[notifier.Name()]
+		delete(limitedQueue.channels, notifier.Name())
+		limitedQueue.Unlock()
+
+		var sb strings.Builder
+		for _, event := range events {
+			sb.WriteString(fmt.Sprintf("[%v] %v\n%v\n\n", formatTime(event.Time), event.Subject, event.Text))
+		}
+		err := notifier.Notify(Event{
+			Kind:    EventRateLimitDigest,
+			Subject: fmt.Sprintf(rateLimitDigestSubject, len(events)),
+			Text:    strings.TrimSpace(sb.String()),
+			Time:    now,
+		})
+		if err != nil {
+			log.Printf("flushLimitedQueue: %v: %v", notifier.Name(), err)
+		}
+	}
+}
+
+// Whether the channel may send another notification, counting it when it may. An invalid limit never drops notifications
+func allowNotification(channel string, now time.Time) (bool, error) {
+	limit, err := strconv.ParseUint(notifyRateLimitVar, 10, 64)
+	if err != nil {
+		return true, fmt.Errorf("invalid value for NOTIFY_RATE_LIMIT: %v", err)
+	}
+	if limit == 0 {
+		return true, nil
+	}
+
+	notifySent.Lock()
+	defer notifySent.Unlock()
+
+	var sent []time.Time
+	for _, t := range notifySent.channels[channel] {
+		if now.Sub(t) < notifyRateLimitWindow {
+			sent = append(sent, t)
+		}
+	}
+	if uint64(len(sent)) >= limit {
+		notifySent.channels[channel] = sent
+		return false, nil
+	}
+	notifySent.channels[channel] = append(sent, now)
+	return true, nil
+}
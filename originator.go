@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// legal entity types of an originator
+const (
+	originatorPrivate  = "PRIVATE"
+	originatorBusiness = "BUSINESS"
+)
+
+// Originator is the person or business a business profile sends a transfer on behalf of, which transferwise asks for
+// third-party transfers to pass its compliance checks, see
+// https://docs.wise.com/api-docs/api-reference/transfer#create-third-party
+type Originator struct {
+	LegalEntityType          string            `json:"legalEntityType"`
+	Reference                string            `json:"reference"`
+	Name                     OriginatorName    `json:"name"`
+	DateOfBirth              string            `json:"dateOfBirth,omitempty"`
+	BusinessRegistrationCode string            `json:"businessRegistrationCode,omitempty"`
+	Address                  OriginatorAddress `json:"address"`
+}
+
+type OriginatorName struct {
+	GivenName   string `json:"givenName,omitempty"`
+	MiddleNames string `json:"middleNames,omitempty"`
+	FamilyName  string `json:"familyName,omitempty"`
+	FullName    string `json:"fullName"`
+}
+
+type OriginatorAddress struct {
+	FirstLine   string `json:"firstLine"`
+	City        string `json:"city"`
+	StateCode   string `json:"stateCode,omitempty"`
+	CountryCode string `json:"countryCode"`
+	PostCode    string `json:"postCode,omitempty"`
+}
+
+// Check the originator has the fields transferwise requires, before a transfer gets created with it
+func validateOriginator(o Originator) error {
+	if o.LegalEntityType != originatorPrivate && o.LegalEntityType != originatorBusiness {
+		return fmt.Errorf("invalid legal entity type %v, must be %v or %v", o.LegalEntityType, originatorPrivate,
+			originatorBusiness)
+	}
+	if o.Reference == "" {
+		return fmt.Errorf("missing reference, the originator's ID in your own records")
+	}
+	if o.Name.FullName == "" {
+		return fmt.Errorf("missing name.fullName")
+	}
+	if o.DateOfBirth != "" {
+		if _, err := time.Parse("2006-01-02", o.DateOfBirth); err != nil {
+			return fmt.Errorf("invalid date of birth %v, expected yyyy-mm-dd", o.DateOfBirth)
+		}
+	}
+	if o.Address.FirstLine == "" || o.Address.City == "" || o.Address.CountryCode == "" {
+		return fmt.Errorf("missing address.firstLine, address.city or address.countryCode")
+	}
+	return nil
+}
+
+// The originator the transfer is sent on behalf of, nil when the profile sends it on its own behalf
+func transferOriginator(transfer Transfer) (*Originator, error) {
+	settings, err := getSettings(transfer)
+	if err != nil {
+		return nil, err
+	}
+	return settings.Originator, nil
+}
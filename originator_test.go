@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"transferwisely/mocks"
+)
+
+func TestValidateOriginator(t *testing.T) {
+	valid := Originator{LegalEntityType: originatorPrivate, Reference: "CST-1", Name: OriginatorName{FullName: "John Doe"},
+		DateOfBirth: "1977-07-01", Address: OriginatorAddress{FirstLine: "1 Main St", City: "London", CountryCode: "GB"}}
+	assert.NoError(t, validateOriginator(valid))
+
+	tests := []struct {
+		name   string
+		modify func(o *Originator)
+	}{
+		{"legal entity type", func(o *Originator) { o.LegalEntityType = "person" }},
+		{"reference", func(o *Originator) { o.Reference = "" }},
+		{"full name", func(o *Originator) { o.Name.FullName = "" }},
+		{"date of birth", func(o *Originator) { o.DateOfBirth = "01/07/1977" }},
+		{"address", func(o *Originator) { o.Address.City = "" }},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			originator := valid
+			test.modify(&originator)
+			assert.Error(t, validateOriginator(originator))
+		})
+	}
+}
+
+func TestRebookWithOriginator(t *testing.T) {
+	defer func(file string, c Config) { configFileVar, config.current = file, c }(configFileVar, getConfig())
+	dir, _ := ioutil.TempDir("", "transferwisely")
+	defer os.RemoveAll(dir)
+	configFileVar = filepath.Join(dir, "config.json")
+	_ = ioutil.WriteFile(configFileVar, []byte(`{
+		"transfers": {"7": {"originator": {"legalEntityType": "BUSINESS", "reference": "CST-7",
+			"name": {"fullName": "Acme Ltd"}, "businessRegistrationCode": "123456",
+			"address": {"firstLine": "1 Main St", "city": "London", "countryCode": "GB"}}}}
+	}`), 0600)
+	assert.NoError(t, loadConfig())
+
+	var created CreateTransferRequest
+	mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+		body := `{}`
+		switch {
+		case req.Method == http.MethodGet && strings.Contains(req.URL.String(), transfersAPIPath):
+			body = `[{"id": 7, "profile": 1, "targetAccount": 9, "sourceAmount": 1000, "sourceCurrency": "GBP",
+				"targetCurrency": "INR", "details": {"reference": "rent"}}]`
+		case req.Method == http.MethodPost && strings.HasSuffix(req.URL.String(), quotesAPIPath):
+			body = `{"id": "quote-1", "rate": 100.5, "sourceAmount": 1000, "profile": 1}`
+		case req.Method == http.MethodPost && strings.HasSuffix(req.URL.String(), transfersAPIPath):
+			data, _ := ioutil.ReadAll(req.Body)
+			_ = json.Unmarshal(data, &created)
+			body = `{"id": 8, "rate": 100.5, "targetAccount": 55, "sourceCurrency": "GBP", "targetCurrency": "INR"}`
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(body))}, nil
+	}
+
+	_, err := cloneTransfer(7, 55)
+	assert.NoError(t, err)
+	if assert.NotNil(t, created.Originator) {
+		assert.Equal(t, "CST-7", created.Originator.Reference)
+		assert.Equal(t, "Acme Ltd", created.Originator.Name.FullName)
+		assert.Equal(t, "GB", created.Originator.Address.CountryCode)
+	}
+
+	originator, err := transferOriginator(Transfer{Id: 8, SourceCurrency: "GBP", TargetCurrency: "INR"})
+	assert.NoError(t, err)
+	assert.Nil(t, originator, "sent on the profile's own behalf")
+
+	t.Run("invalid originator", func(t *testing.T) {
+		_ = ioutil.WriteFile(configFileVar, []byte(`{"pairs": {"GBP-INR": {"originator": {"legalEntityType": "PRIVATE"}}}}`), 0600)
+		assert.EqualError(t, loadConfig(), "invalid originator for pair GBP-INR in config file: missing reference, "+
+			"the originator's ID in your own records")
+	})
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// exit codes of check --pair --exit-code, like grep's: worth re-booking, not worth it, and failed
+const (
+	pairCheckExitRebook   = 0
+	pairCheckExitNoRebook = 1
+	pairCheckExitError    = 2
+)
+
+// PairCheckRequest is a check --pair against a booked rate given by a script rather than listed from transferwise
+type PairCheckRequest struct {
+	Pair       string
+	BookedRate string
+
+	// negative for the pair's configured margin
+	Margin float64
+	Amount float64
+}
+
+// PairCheckResult is the outcome of check --pair
+type PairCheckResult struct {
+	Pair       string  `json:"pair"`
+	BookedRate float64 `json:"bookedRate"`
+	LiveRate   float64 `json:"liveRate,omitempty"`
+	Margin     Decimal `json:"margin"`
+	Rebook     bool    `json:"rebook"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// Compare the live rate of the pair to the booked rate with the pair's strategy, nothing being listed, booked or
+// notified
+func checkPair(in io.Reader, req PairCheckRequest) (PairCheckResult, error) {
+	result := PairCheckResult{Pair: strings.ToUpper(req.Pair)}
+	source, target, err := splitPairKey(req.Pair)
+	if err != nil {
+		return result, err
+	}
+	result.Pair = pairKey(source, target)
+
+	bookedRate := strings.TrimSpace(req.BookedRate)
+	if bookedRate == "-" {
+		line, err := bufio.NewReader(in).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return result, fmt.Errorf("error reading the booked rate from stdin: %v", err)
+		}
+		bookedRate = strings.TrimSpace(line)
+	}
+	result.BookedRate, err = strconv.ParseFloat(bookedRate, 64)
+	if err != nil || result.BookedRate <= 0 {
+		return result, fmt.Errorf("invalid booked rate %q, expected a positive number", bookedRate)
+	}
+
+	transfer := Transfer{SourceCurrency: source, TargetCurrency: target, Rate: result.BookedRate, SourceAmount: decimalOf(req.Amount)}
+	settings, err := getSettings(transfer)
+	if err != nil {
+		return result, err
+	}
+	if req.Margin >= 0 {
+		settings.Margin = decimalOf(req.Margin)
+	}
+	result.Margin = settings.Margin
+
+	result.Rebook, result.LiveRate, err = compareRates(transfer, settings)
+	return result, err
+}
+
+func runPairCheck(in io.Reader, out io.Writer, output string, req PairCheckRequest, exitCode bool) error {
+	if req.Pair == "" || req.BookedRate == "" {
+		return fmt.Errorf("usage: check --pair <source>-<target> --booked-rate <rate|-> [--margin <margin>] [--amount <amount>] " +
+			"[--exit-code] [--output json]")
+	}
+	result, err := checkPair(in, req)
+	if err != nil {
+		result.Error = err.Error()
+	}
+	printErr := printOutput(out, output, result, func(w io.Writer) {
+		switch {
+		case result.Error != "":
+			fmt.Fprintf(w, "{%v}: check failed: %v\n", result.Pair, result.Error)
+		case result.Rebook:
+			fmt.Fprintf(w, "{%v}: live rate %v beats the booked rate %v by margin %v, worth re-booking\n", result.Pair,
+				formatRate(result.LiveRate), formatRate(result.BookedRate), result.Margin)
+		default:
+			fmt.Fprintf(w, "{%v}: live rate %v doesn't beat the booked rate %v by margin %v\n", result.Pair,
+				formatRate(result.LiveRate), formatRate(result.BookedRate), result.Margin)
+		}
+	})
+	if printErr != nil {
+		return printErr
+	}
+
+	if !exitCode {
+		return err
+	}
+	switch {
+	case err != nil:
+		return &ExitError{Code: pairCheckExitError}
+	case result.Rebook:
+		return &ExitError{Code: pairCheckExitRebook}
+	default:
+		return &ExitError{Code: pairCheckExitNoRebook}
+	}
+}
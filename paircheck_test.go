@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"transferwisely/mocks"
+)
+
+func TestRunPairCheck(t *testing.T) {
+	defer func(margin string) { marginVar = margin }(marginVar)
+	marginVar = "0.5"
+	mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK,
+			Body: ioutil.NopCloser(strings.NewReader(`[{"rate": 105.6, "source": "GBP", "target": "INR"}]`))}, nil
+	}
+	exitCode := func(err error) int {
+		var exitErr *ExitError
+		if !assert.True(t, errors.As(err, &exitErr), err) {
+			return -1
+		}
+		return exitErr.Code
+	}
+
+	t.Run("worth re-booking", func(t *testing.T) {
+		var out bytes.Buffer
+		err := runPairCheck(nil, &out, outputJSON, PairCheckRequest{Pair: "gbp-inr", BookedRate: "105", Margin: 0.3}, true)
+		assert.Equal(t, pairCheckExitRebook, exitCode(err))
+		var result PairCheckResult
+		assert.NoError(t, json.Unmarshal(out.Bytes(), &result))
+		assert.Equal(t, PairCheckResult{Pair: "GBP-INR", BookedRate: 105, LiveRate: 105.6, Margin: decimalOf(0.3), Rebook: true}, result)
+	})
+
+	t.Run("not worth it with the configured margin, the booked rate read from stdin", func(t *testing.T) {
+		var out bytes.Buffer
+		err := runPairCheck(strings.NewReader("105.2\n"), &out, outputText, PairCheckRequest{Pair: "GBP-INR", BookedRate: "-",
+			Margin: -1}, true)
+		assert.Equal(t, pairCheckExitNoRebook, exitCode(err))
+		assert.Equal(t, "{GBP-INR}: live rate 105.6 doesn't beat the booked rate 105.2 by margin 0.5\n", out.String())
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		var out bytes.Buffer
+		err := runPairCheck(nil, &out, outputJSON, PairCheckRequest{Pair: "GBP-INR", BookedRate: "abc"}, true)
+		assert.Equal(t, pairCheckExitError, exitCode(err))
+		assert.Contains(t, out.String(), `"error": "invalid booked rate \"abc\", expected a positive number"`)
+
+		err = runPairCheck(nil, &out, outputJSON, PairCheckRequest{Pair: "GBPINR", BookedRate: "1"}, false)
+		assert.EqualError(t, err, "invalid currency pair GBPINR, expected <source>-<target> like GBP-INR")
+	})
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+)
+
+var errTransferPinned = errors.New("transfer pinned")
+
+// Whether CONFIG_FILE pins the transfer, which is then never re-booked nor cancelled, e.g. for a negotiated rate
+func isPinned(transferId uint64) bool {
+	return getConfig().Transfers[strconv.FormatUint(transferId, 10)].Pinned
+}
+
+// The transfers left to track once the pinned ones are set aside
+func withoutPinned(transfers []Transfer) []Transfer {
+	var unpinned []Transfer
+	for _, transfer := range transfers {
+		if isPinned(transfer.Id) {
+			log.Printf("|| PINNED, LEFT ALONE || Transfer ID: %v | {%v} --> {%v} | Rate: %v ||", transfer.Id,
+				transfer.SourceCurrency, transfer.TargetCurrency, transfer.Rate)
+			continue
+		}
+		unpinned = append(unpinned, transfer)
+	}
+	return unpinned
+}
+
+// Refuse to re-book or cancel a pinned transfer, for the ones picked before it got pinned like pending proposals
+func checkNotPinned(transfer Transfer) error {
+	if isPinned(transfer.Id) {
+		return fmt.Errorf("%w: transfer %v is pinned in the config file", errTransferPinned, transfer.Id)
+	}
+	return nil
+}
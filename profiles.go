@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// Profile is a personal or business profile belonging to the API token
+type Profile struct {
+	Id   uint64 `json:"id"`
+	Type string `json:"type"`
+}
+
+func getProfiles() ([]Profile, error) {
+	url := &url.URL{Host: hostVar, Scheme: "https", Path: profilesAPIPath}
+
+	var profiles []Profile
+	_, err := callExternalAPI(http.MethodGet, url.String(), nil, &profiles)
+	if err != nil {
+		return nil, fmt.Errorf("error GET profiles API: %w", err)
+	}
+
+	return profiles, nil
+}
+
+// Returns the configured PROFILE_ID, 0 meaning no explicit profile was configured
+func getConfiguredProfile() (uint64, error) {
+	if profileIdVar == "" {
+		return 0, nil
+	}
+
+	profileId, err := strconv.ParseUint(profileIdVar, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value for PROFILE_ID: %v", err)
+	}
+	return profileId, nil
+}
+
+// Make sure the configured PROFILE_ID is one of the profiles the API token has access to
+func validateProfile() error {
+	profileId, err := getConfiguredProfile()
+	if err != nil || profileId == 0 {
+		return err
+	}
+
+	profiles, err := getProfiles()
+	if err != nil {
+		return fmt.Errorf("validateProfile: %w", err)
+	}
+
+	for _, profile := range profiles {
+		if profile.Id == profileId {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %v, available profiles: %v", ErrProfileNotFound, profileId, profiles)
+}
+
+// Resolve the profile quotes and transfers should be created under for the given transfer, 0 meaning the transfer's own profile
+func resolveProfile(transfer Transfer, profileId uint64) (uint64, error) {
+	if profileId == 0 {
+		return transfer.Profile, nil
+	}
+	if transfer.Profile != 0 && transfer.Profile != profileId {
+		return 0, fmt.Errorf("error: transfer %v belongs to profile %v, expected profile %v", transfer.Id, transfer.Profile, profileId)
+	}
+	return profileId, nil
+}
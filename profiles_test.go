@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"transferwisely/mocks"
+)
+
+func TestGetProfiles(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		// build response JSON
+		profiles := []Profile{{Id: 1, Type: "personal"}, {Id: 2, Type: "business"}}
+		j, _ := json.Marshal(profiles)
+		// create a new reader with that JSON
+		r := ioutil.NopCloser(bytes.NewReader(j))
+		mocks.GetDoFunc = func(*http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       r,
+			}, nil
+		}
+
+		result, err := getProfiles()
+		assert.NoError(t, err)
+		assert.Equal(t, profiles, result)
+	})
+
+	t.Run("external api error", func(t *testing.T) {
+		r := ioutil.NopCloser(bytes.NewReader([]byte("{}")))
+		mocks.GetDoFunc = func(*http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusUnauthorized,
+				Body:       r,
+			}, nil
+		}
+
+		result, err := getProfiles()
+		assert.Empty(t, result)
+		assert.Error(t, err)
+	})
+}
+
+func TestResolveProfile(t *testing.T) {
+	t.Run("falls back to transfer profile", func(t *testing.T) {
+		profile, err := resolveProfile(Transfer{Profile: 7}, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(7), profile)
+	})
+
+	t.Run("configured profile", func(t *testing.T) {
+		profile, err := resolveProfile(Transfer{Profile: 2}, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(2), profile)
+	})
+
+	t.Run("transfer under another profile", func(t *testing.T) {
+		_, err := resolveProfile(Transfer{Id: 10, Profile: 1}, 2)
+		assert.Error(t, err)
+	})
+}
+
+func TestGetConfiguredProfile(t *testing.T) {
+	defer func(value string) { profileIdVar = value }(profileIdVar)
+
+	profileIdVar = ""
+	profile, err := getConfiguredProfile()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), profile)
+
+	profileIdVar = "business"
+	_, err = getConfiguredProfile()
+	assert.Error(t, err)
+}
+
+func TestListTransfersProfileScoped(t *testing.T) {
+	defer func(v string) { profileIdVar = v }(profileIdVar)
+	defer func() { profileTransfers.unavailable = false }()
+	profileIdVar = "7"
+
+	var paths []string
+	v3Available := true
+	mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+		paths = append(paths, req.URL.String())
+		if strings.Contains(req.URL.String(), "v3/") && !v3Available {
+			return &http.Response{StatusCode: http.StatusNotFound, Body: ioutil.NopCloser(strings.NewReader(`{}`))}, nil
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(`[{"id": 1}]`))}, nil
+	}
+
+	transfers, err := listTransfers(transferStatusBooked, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(7), transfers[0].Profile, "the profile of the scope")
+	assert.Contains(t, paths[0], "v3/profiles/7/transfers?limit=10&offset=0&status=incoming_payment_waiting")
+
+	t.Run("falls back to v1 once v3 isn't available", func(t *testing.T) {
+		paths, v3Available = nil, false
+		_, err := listTransfers(transferStatusBooked, 10)
+		assert.NoError(t, err)
+		_, err = listTransfers(transferStatusBooked, 10)
+		assert.NoError(t, err)
+		assert.Len(t, paths, 3)
+		assert.Contains(t, paths[1], "v1/transfers?limit=10&offset=0&profile=7")
+		assert.Contains(t, paths[2], "v1/transfers?")
+	})
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	quietHoursDigestSubject = "Morning digest: %v notifications held during quiet hours"
+	quietHoursLayout        = "15:04"
+)
+
+// non critical events held back during quiet hours
+var quietQueue = struct {
+	sync.Mutex
+	events []Event
+}{}
+
+// Errors and a rate lock about to lapse, or unfunded, need attention right away, everything else can wait for the morning digest
+func isCritical(kind EventKind) bool {
+	return kind == EventError || kind == EventExpiryImminent || kind == EventFundingOverdue || kind == EventAPIDown ||
+		kind == EventManualAction || kind == EventTokenInvalid
+}
+
+// Queue the event when it arrives during quiet hours, reporting whether it was queued
+func queueIfQuiet(event Event) bool {
+	quiet, err := inQuietHours(event.Time)
+	if err != nil || !quiet {
+		return false
+	}
+
+	quietQueue.Lock()
+	quietQueue.events = append(quietQueue.events, event)
+	quietQueue.Unlock()
+	return true
+}
+
+// Send the events queued during quiet hours as a single digest once quiet hours are over
+func flushQuietQueue() {
+	quiet, err := inQuietHours(time.Now().UTC())
+	if err != nil || quiet {
+		return
+	}
+
+	quietQueue.Lock()
+	events := quietQueue.events
+	quietQueue.events = nil
+	quietQueue.Unlock()
+
+	if len(events) == 0 {
+		return
+	}
+
+	var sb strings.Builder
+	for _, event := range events {
+		sb.WriteString(fmt.Sprintf("[%v] %v\n%v\n\n", formatTime(event.Time), event.Subject, event.Text))
+	}
+	dispatch(Event{
+		Kind:    EventQuietHoursDigest,
+		Subject: fmt.Sprintf(quietHoursDigestSubject, len(events)),
+		Text:    strings.TrimSpace(sb.String()),
+		Time:    time.Now().UTC(),
+	})
+}
+
+// Whether t falls within QUIET_HOURS in the QUIET_HOURS_TZ timezone, TIMEZONE by default, quiet hours may span midnight
+func inQuietHours(t time.Time) (bool, error) {
+	start, end, loc, err := getQuietHours()
+	if err != nil || start == end {
+		return false, err
+	}
+
+	local := t.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	if start < end {
+		return minute >= start && minute < end, nil
+	}
+	return minute >= start || minute < end, nil
+}
+
+// Parse QUIET_HOURS like 23:00-07:00 into minutes of the day
+func getQuietHours() (start int, end int, loc *time.Location, err error) {
+	if quietHoursVar == "" {
+		return 0, 0, time.UTC, nil
+	}
+
+	loc, err = getTimezoneOr("QUIET_HOURS_TZ", quietHoursTZVar)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+
+	bounds := strings.Split(quietHoursVar, "-")
+	if len(bounds) != 2 {
+		return 0, 0, nil, fmt.Errorf("invalid value for QUIET_HOURS: %v, expected e.g. 23:00-07:00", quietHoursVar)
+	}
+	startTime, err := time.Parse(quietHoursLayout, strings.TrimSpace(bounds[0]))
+	if err != nil {
+		return 0, 0, nil, fmt.Errorf("invalid value for QUIET_HOURS: %v", err)
+	}
+	endTime, err := time.Parse(quietHoursLayout, strings.TrimSpace(bounds[1]))
+	if err != nil {
+		return 0, 0, nil, fmt.Errorf("invalid value for QUIET_HOURS: %v", err)
+	}
+
+	return startTime.Hour()*60 + startTime.Minute(), endTime.Hour()*60 + endTime.Minute(), loc, nil
+}
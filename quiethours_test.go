@@ -0,0 +1,109 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestInQuietHours(t *testing.T) {
+	defer func(hours, tz string) { quietHoursVar, quietHoursTZVar = hours, tz }(quietHoursVar, quietHoursTZVar)
+
+	t.Run("spanning midnight", func(t *testing.T) {
+		quietHoursVar, quietHoursTZVar = "23:00-07:00", "UTC"
+		for hour, expected := range map[int]bool{22: false, 23: true, 2: true, 6: true, 7: false, 12: false} {
+			quiet, err := inQuietHours(time.Date(2020, 5, 1, hour, 30, 0, 0, time.UTC))
+			assert.NoError(t, err)
+			assert.Equal(t, expected, quiet, "hour %v", hour)
+		}
+	})
+
+	t.Run("within a day in another timezone", func(t *testing.T) {
+		quietHoursVar, quietHoursTZVar = "12:00-14:00", "Asia/Tokyo"
+		quiet, err := inQuietHours(time.Date(2020, 5, 1, 4, 0, 0, 0, time.UTC))
+		assert.NoError(t, err)
+		assert.True(t, quiet)
+	})
+
+	t.Run("not configured", func(t *testing.T) {
+		quietHoursVar = ""
+		quiet, err := inQuietHours(time.Now())
+		assert.NoError(t, err)
+		assert.False(t, quiet)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		quietHoursVar, quietHoursTZVar = "late", "UTC"
+		_, err := inQuietHours(time.Now())
+		assert.Error(t, err)
+	})
+}
+
+func TestQuietHoursDigest(t *testing.T) {
+	defer func(notifiers []Notifier, hours string) { Notifiers, quietHoursVar = notifiers, hours }(Notifiers, quietHoursVar)
+	notifier := &fakeNotifier{}
+	Notifiers = []Notifier{notifier}
+
+	now := time.Now().UTC()
+	quietHoursVar = now.Add(-time.Hour).Format(quietHoursLayout) + "-" + now.Add(time.Hour).Format(quietHoursLayout)
+	notify(Event{Kind: EventRebooked, Subject: "rebooked"})
+	notify(Event{Kind: EventError, Subject: "error"})
+	assert.Len(t, notifier.events, 1)
+	assert.Equal(t, EventError, notifier.events[0].Kind)
+
+	quietHoursVar = ""
+	flushQuietQueue()
+	assert.Len(t, notifier.events, 2)
+	assert.Equal(t, EventQuietHoursDigest, notifier.events[1].Kind)
+	assert.Contains(t, notifier.events[1].Text, "rebooked")
+}
+
+func TestAllowNotification(t *testing.T) {
+	defer func(value string) { notifyRateLimitVar = value }(notifyRateLimitVar)
+	notifyRateLimitVar = "2"
+	now := time.Now().UTC()
+
+	allowed, _ := allowNotification("test", now)
+	assert.True(t, allowed)
+	allowed, _ = allowNotification("test", now)
+	assert.True(t, allowed)
+	allowed, _ = allowNotification("test", now)
+	assert.False(t, allowed)
+	allowed, _ = allowNotification("other", now)
+	assert.True(t, allowed)
+	allowed, _ = allowNotification("test", now.Add(notifyRateLimitWindow))
+	assert.True(t, allowed)
+}
+
+func TestDispatchRateLimited(t *testing.T) {
+	defer func(value string) { notifyRateLimitVar = value }(notifyRateLimitVar)
+	notifyRateLimitVar = "1"
+	notifier := &fakeNotifier{}
+	defer func(n []Notifier) { Notifiers = n }(Notifiers)
+	Notifiers = []Notifier{notifier}
+	notifySent.Lock()
+	notifySent.channels["fake"] = nil
+	notifySent.Unlock()
+	now := time.Now().UTC()
+
+	dispatch(Event{Kind: EventAlert, Subject: "first alert", Time: now})
+	dispatch(Event{Kind: EventAlert, Subject: "second alert", Time: now})
+	dispatch(Event{Kind: EventRebooked, Subject: "rebooked", Time: now})
+	dispatch(Event{Kind: EventError, Subject: "error", Time: now})
+	assert.Len(t, notifier.events, 3, "the second alert is held back, critical events and re-bookings aren't")
+	assert.Equal(t, EventRebooked, notifier.events[1].Kind)
+	assert.Equal(t, EventError, notifier.events[2].Kind)
+
+	flushLimitedQueue()
+	assert.Len(t, notifier.events, 3, "still rate limited")
+
+	notifySent.Lock()
+	notifySent.channels["fake"] = nil
+	notifySent.Unlock()
+	flushLimitedQueue()
+	assert.Len(t, notifier.events, 4)
+	assert.Equal(t, EventRateLimitDigest, notifier.events[3].Kind)
+	assert.Contains(t, notifier.events[3].Text, "second alert")
+	flushLimitedQueue()
+	assert.Len(t, notifier.events, 4, "the digest is sent once")
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+)
+
+func getQuoteRetryInterval() (time.Duration, error) {
+	minutes, err := strconv.ParseUint(quoteRetryIntervalVar, 10, 64)
+	if err != nil || minutes == 0 {
+		return 0, fmt.Errorf("invalid value for QUOTE_RETRY_INTERVAL: %v", quoteRetryIntervalVar)
+	}
+	return time.Duration(minutes) * time.Minute, nil
+}
+
+// Defer the re-booking of the transfer whose quote came back with every payment option disabled, checking its pair
+// again in QUOTE_RETRY_INTERVAL minutes rather than failing the check, as the options usually come back once the
+// corridor's off-hours are over
+func deferQuoteRetry(transfer Transfer, liveRate float64, err error, now time.Time) {
+	retry, retryErr := getQuoteRetryInterval()
+	if retryErr != nil {
+		log.Printf("deferQuoteRetry: %v", retryErr)
+		return
+	}
+	retryAt := now.Add(retry)
+	key := accountPairCheck(pairKey(transfer.SourceCurrency, transfer.TargetCurrency))
+	pairChecks.Lock()
+	pairChecks.retryAt[key] = retryAt
+	pairChecks.Unlock()
+
+	log.Printf("|| REBOOK DEFERRED, Live Rate: %v || Transfer ID: %v | {%v} --> {%v} | Booked Rate: %v | Retry at: %v | %v ||",
+		liveRate, transfer.Id, transfer.SourceCurrency, transfer.TargetCurrency, transfer.Rate, formatTime(retryAt), err)
+	alertImminentExpiry(transfer, now)
+}
+
+// Whether the account's check of the pair is deferred until a quote retry still to come
+func isQuoteRetryPending(pair string, now time.Time) bool {
+	key := accountPairCheck(pair)
+	pairChecks.Lock()
+	defer pairChecks.Unlock()
+	retryAt, ok := pairChecks.retryAt[key]
+	return ok && now.Before(retryAt)
+}
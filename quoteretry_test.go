@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"transferwisely/mocks"
+)
+
+func TestAllPaymentOptionsDisabled(t *testing.T) {
+	assert.False(t, allPaymentOptionsDisabled(QuoteDetail{}), "no payment options")
+	assert.False(t, allPaymentOptionsDisabled(QuoteDetail{PaymentOptions: []PaymentOptions{{Disabled: true}, {}}}))
+	assert.True(t, allPaymentOptionsDisabled(QuoteDetail{PaymentOptions: []PaymentOptions{{Disabled: true}, {Disabled: true}}}))
+}
+
+func TestQuoteRetryWhenPaymentOptionsDisabled(t *testing.T) {
+	disabled := `"paymentOptions": [{"disabled": true, "payIn": "BANK_TRANSFER", "payOut": "BANK_TRANSFER", "sourceAmount": 1000}]`
+	mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+		body := `{"id": "quote-1", "rate": 101, "profile": 1, "sourceCurrency": "GBP", "targetCurrency": "INR", ` + disabled + `}`
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(body))}, nil
+	}
+	booked := Transfer{Id: 7, Profile: 1, Rate: 100, SourceAmount: decimalOf(1000), SourceCurrency: "GBP", TargetCurrency: "INR"}
+
+	_, err := createTransfer(booked, Settings{PayOut: "BANK_TRANSFER"}, rebookReasonBetterRate)
+	assert.True(t, errors.Is(err, ErrPaymentOptionsDisabled))
+
+	_, err = getDetailByQuoteId("quote-1")
+	assert.True(t, errors.Is(err, ErrPaymentOptionsDisabled), "no source amount to check the booked transfer with")
+
+	t.Run("pair checked again after the retry interval", func(t *testing.T) {
+		defer func() {
+			pairChecks.Lock()
+			pairChecks.checkedAt, pairChecks.retryAt = map[pairCheck]time.Time{}, map[pairCheck]time.Time{}
+			pairChecks.Unlock()
+		}()
+		now := time.Date(2026, 10, 16, 22, 0, 0, 0, time.UTC)
+		assert.True(t, isCheckDue("GBP-INR", 60, now))
+
+		deferQuoteRetry(booked, 101, err, now)
+		assert.False(t, isCheckDue("GBP-INR", 60, now.Add(4*time.Minute)))
+		assert.True(t, isCheckDue("GBP-INR", 60, now.Add(5*time.Minute)), "QUOTE_RETRY_INTERVAL elapsed")
+		assert.False(t, isCheckDue("GBP-INR", 60, now.Add(10*time.Minute)), "back to the pair's interval")
+	})
+}
+
+func TestCheckDeferredWhenBookedQuoteHasPaymentOptionsDisabled(t *testing.T) {
+	defer func(file string) { stateFileVar = file }(stateFileVar)
+	dir, _ := ioutil.TempDir("", "transferwisely")
+	defer os.RemoveAll(dir)
+	stateFileVar = filepath.Join(dir, "state.json")
+	defer func() {
+		pairChecks.Lock()
+		pairChecks.checkedAt, pairChecks.retryAt = map[pairCheck]time.Time{}, map[pairCheck]time.Time{}
+		pairChecks.Unlock()
+	}()
+	mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+		body := `{"id": "quote-1", "rate": 100, "profile": 1, "sourceCurrency": "GBP", "targetCurrency": "INR",
+			"paymentOptions": [{"disabled": true, "payIn": "BANK_TRANSFER", "payOut": "BANK_TRANSFER"}]}`
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(body))}, nil
+	}
+	booked := Transfer{Id: 7, QuoteUuid: "quote-1", Rate: 100, SourceCurrency: "GBP", TargetCurrency: "INR"}
+
+	check := checkTransfer(booked, nil)
+	assert.Equal(t, checkActionDeferred, check.Action)
+	pairChecks.Lock()
+	retryAt, ok := pairChecks.retryAt[pairCheck{defaultAccount, "GBP-INR"}]
+	pairChecks.Unlock()
+	assert.True(t, ok, "a quote retry is scheduled")
+	assert.WithinDuration(t, time.Now().UTC().Add(5*time.Minute), retryAt, time.Minute)
+
+	check = checkTransfer(booked, nil)
+	assert.Equal(t, checkActionNotDue, check.Action, "not checked again before the retry")
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"github.com/go-co-op/gocron"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rate digest periods
+const (
+	rateDigestDaily  = "daily"
+	rateDigestWeekly = "weekly"
+)
+
+// rate digest notification
+const (
+	rateDigestSubject    = "%v digest: rates from %v to %v"
+	rateDigestPairText   = "%v: open %v | high %v | low %v | close %v | booked %v (%+g)"
+	rateDigestRebookText = "Transfer %v re-booked as %v at %v, %v --> %v (%v)"
+	rateDigestExpiryText = "Transfer %v {%v} --> {%v} at %v expires %v"
+)
+
+// events the rate digest stands in for with RATE_DIGEST_ONLY
+var digestedEvents = map[EventKind]bool{
+	EventRebooked:       true,
+	EventNoActionDigest: true,
+	EventExpiryReminder: true,
+	EventStatusChanged:  true,
+}
+
+// PairDigest is the rate movement of a tracked pair over the digest period, from the live rates its checks saw
+type PairDigest struct {
+	Pair   string
+	Checks int
+	Open   float64
+	High   float64
+	Low    float64
+	Close  float64
+	Booked float64
+}
+
+// RateDigestMailData is the Data of rate-digest events
+type RateDigestMailData struct {
+	Period      string
+	From        time.Time
+	To          time.Time
+	Pairs       []PairDigest
+	Rebooks     []RebookRecord
+	Expirations []Transfer
+}
+
+// Schedule the rate digest every day, or every monday, at RATE_DIGEST_AT if RATE_DIGEST is set
+func scheduleRateDigest(scheduler *gocron.Scheduler) error {
+	period, at, err := getRateDigest()
+	if err != nil || period == "" {
+		return err
+	}
+
+	job := scheduler.Every(1).Day()
+	if period == rateDigestWeekly {
+		job = scheduler.Every(1).Monday()
+	}
+	_, err = job.At(at).Do(defaultAccountJob(sendRateDigest))
+	if err != nil {
+		return fmt.Errorf("couldn't schedule the sendRateDigest job: %v", err)
+	}
+	return nil
+}
+
+// Notify the rate movement of the tracked pairs, the re-bookings and the upcoming rate lock expiries of the period
+func sendRateDigest() {
+	period, _, err := getRateDigest()
+	if err != nil || period == "" {
+		return
+	}
+	to := time.Now().UTC()
+	from := to.AddDate(0, 0, -1)
+	if period == rateDigestWeekly {
+		from = to.AddDate(0, 0, -7)
+	}
+
+	state, err := loadState()
+	if err != nil {
+		log.Printf("sendRateDigest: %v", err)
+		return
+	}
+	transfers, err := getUpcomingExpirations()
+	if err != nil {
+		log.Printf("sendRateDigest: %v", err)
+	}
+
+	digest := buildRateDigest(state, transfers, from, to)
+	digest.Period = period
+	notify(Event{
+		Kind:    EventRateDigest,
+		Subject: fmt.Sprintf(rateDigestSubject, strings.Title(period), from.Format("2006-01-02"), to.Format("2006-01-02")),
+		Text:    formatRateDigest(digest),
+		Data:    digest,
+	})
+}
+
+// Summarize the checks and re-bookings of the state between from and to, along with the transfers still to expire
+func buildRateDigest(state State, transfers []Transfer, from time.Time, to time.Time) (digest RateDigestMailData) {
+	digest.From, digest.To = from, to
+
+	pairs := map[string]*PairDigest{}
+	for _, decision := range filterDecisions(state.Decisions, from, to) {
+		pair := pairKey(decision.SourceCurrency, decision.TargetCurrency)
+		p, ok := pairs[pair]
+		if !ok {
+			p = &PairDigest{Pair: pair, Open: decision.LiveRate, High: decision.LiveRate, Low: decision.LiveRate}
+			pairs[pair] = p
+		}
+		p.Checks++
+		if decision.LiveRate > p.High {
+			p.High = decision.LiveRate
+		}
+		if decision.LiveRate < p.Low {
+			p.Low = decision.LiveRate
+		}
+		p.Close, p.Booked = decision.LiveRate, decision.BookedRate
+		if decision.NewTransferId != 0 {
+			p.Booked = decision.LiveRate
+		}
+	}
+	for _, p := range pairs {
+		digest.Pairs = append(digest.Pairs, *p)
+	}
+	sort.Slice(digest.Pairs, func(i, j int) bool { return digest.Pairs[i].Pair < digest.Pairs[j].Pair })
+
+	for _, record := range state.RebookHistory {
+		if !record.Time.Before(from) && record.Time.Before(to) {
+			digest.Rebooks = append(digest.Rebooks, record)
+		}
+	}
+
+	for _, transfer := range transfers {
+		expiry, err := time.Parse(time.RFC3339, transfer.RateExpirationTime)
+		if err == nil && expiry.After(to) {
+			digest.Expirations = append(digest.Expirations, transfer)
+		}
+	}
+	sort.Slice(digest.Expirations, func(i, j int) bool {
+		return digest.Expirations[i].RateExpirationTime < digest.Expirations[j].RateExpirationTime
+	})
+	return digest
+}
+
+func formatRateDigest(digest RateDigestMailData) string {
+	var lines []string
+	if len(digest.Pairs) == 0 {
+		lines = append(lines, "No tracked pair was checked.")
+	}
+	for _, p := range digest.Pairs {
+		lines = append(lines, fmt.Sprintf(rateDigestPairText, p.Pair, p.Open, p.High, p.Low, p.Close, p.Booked,
+			subtractDecimal(p.Close, p.Booked)))
+	}
+
+	lines = append(lines, "", fmt.Sprintf("Re-bookings: %v", len(digest.Rebooks)))
+	for _, record := range digest.Rebooks {
+		lines = append(lines, fmt.Sprintf(rateDigestRebookText, record.OldTransferId, record.NewTransferId,
+			formatTime(record.Time), record.OldRate, record.NewRate, record.Reason))
+	}
+
+	if len(digest.Expirations) > 0 {
+		lines = append(lines, "", "Upcoming rate lock expiries")
+	}
+	for _, transfer := range digest.Expirations {
+		lines = append(lines, fmt.Sprintf(rateDigestExpiryText, transfer.Id, transfer.SourceCurrency, transfer.TargetCurrency,
+			formatRate(transfer.Rate), formatExpiry(transfer.RateExpirationTime)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// The tracked transfers along with their rate lock expiry
+func getUpcomingExpirations() ([]Transfer, error) {
+	statuses, err := getTrackedStatuses()
+	if err != nil {
+		return nil, err
+	}
+	transfers, err := listAllTransfers(strings.Join(statuses, ","))
+	if err != nil {
+		return nil, err
+	}
+	for i, transfer := range transfers {
+		quote, err := getDetailByQuoteId(transfer.QuoteUuid)
+		if err != nil {
+			log.Printf("getUpcomingExpirations: %v", err)
+			continue
+		}
+		transfers[i].RateExpirationTime = quote.RateExpirationTime
+	}
+	return transfers, nil
+}
+
+// Whether the event is left out for the rate digest to summarize instead
+func isDigested(kind EventKind) bool {
+	period, _, err := getRateDigest()
+	if err != nil || period == "" {
+		return false
+	}
+	digestOnly, _ := strconv.ParseBool(rateDigestOnlyVar)
+	return digestOnly && digestedEvents[kind]
+}
+
+func getRateDigest() (period string, at string, err error) {
+	switch rateDigestVar {
+	case "", rateDigestDaily, rateDigestWeekly:
+	default:
+		return "", "", fmt.Errorf("invalid value for RATE_DIGEST: %v, must be %v or %v", rateDigestVar, rateDigestDaily, rateDigestWeekly)
+	}
+	minute, err := parseMinuteOfDay(rateDigestAtVar)
+	if err != nil || minute >= minutesPerDay {
+		return "", "", fmt.Errorf("invalid value for RATE_DIGEST_AT: %v", rateDigestAtVar)
+	}
+	if _, err := strconv.ParseBool(rateDigestOnlyVar); err != nil {
+		return "", "", fmt.Errorf("invalid value for RATE_DIGEST_ONLY: %v", err)
+	}
+	return rateDigestVar, rateDigestAtVar, nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestBuildRateDigest(t *testing.T) {
+	from := time.Date(2023, 3, 1, 8, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 1)
+	decision := func(hours int, pair string, booked float64, live float64, newTransferId uint64) Decision {
+		source, target, _ := splitPairKey(pair)
+		return Decision{Time: from.Add(time.Duration(hours) * time.Hour), SourceCurrency: source, TargetCurrency: target,
+			BookedRate: booked, LiveRate: live, NewTransferId: newTransferId}
+	}
+	state := State{
+		Decisions: []Decision{
+			decision(-1, "GBP-INR", 100, 99, 0),
+			decision(1, "GBP-INR", 100, 100.2, 0),
+			decision(2, "JPY-INR", 0.6, 0.61, 0),
+			decision(3, "GBP-INR", 100, 101, 2),
+			decision(4, "GBP-INR", 101, 100.6, 0),
+			decision(25, "GBP-INR", 101, 103, 0),
+		},
+		RebookHistory: []RebookRecord{
+			{Time: from.Add(-time.Hour), OldTransferId: 0, NewTransferId: 1},
+			{Time: from.Add(3 * time.Hour), OldTransferId: 1, NewTransferId: 2, OldRate: 100, NewRate: 101, Reason: rebookReasonBetterRate},
+		},
+	}
+	transfers := []Transfer{
+		{Id: 3, Rate: 0.6, SourceCurrency: "JPY", TargetCurrency: "INR", RateExpirationTime: "2023-03-04T08:00:00Z"},
+		{Id: 2, Rate: 101, SourceCurrency: "GBP", TargetCurrency: "INR", RateExpirationTime: "2023-03-03T08:00:00Z"},
+		{Id: 4, Rate: 0.6, SourceCurrency: "JPY", TargetCurrency: "INR", RateExpirationTime: "2023-03-01T09:00:00Z"},
+	}
+
+	digest := buildRateDigest(state, transfers, from, to)
+	assert.Equal(t, []PairDigest{
+		{Pair: "GBP-INR", Checks: 3, Open: 100.2, High: 101, Low: 100.2, Close: 100.6, Booked: 101},
+		{Pair: "JPY-INR", Checks: 1, Open: 0.61, High: 0.61, Low: 0.61, Close: 0.61, Booked: 0.6},
+	}, digest.Pairs)
+	assert.Len(t, digest.Rebooks, 1)
+	assert.Equal(t, uint64(2), digest.Rebooks[0].NewTransferId)
+	assert.Len(t, digest.Expirations, 2, "the rate lock of transfer 4 already expired")
+	assert.Equal(t, uint64(2), digest.Expirations[0].Id)
+
+	text := formatRateDigest(digest)
+	assert.Contains(t, text, "GBP-INR: open 100.2 | high 101 | low 100.2 | close 100.6 | booked 101 (-0.4)")
+	assert.Contains(t, text, "JPY-INR: open 0.61 | high 0.61 | low 0.61 | close 0.61 | booked 0.6 (+0.01)")
+	assert.Contains(t, text, "Re-bookings: 1\nTransfer 1 re-booked as 2")
+	assert.Contains(t, text, "Transfer 2 {GBP} --> {INR} at 101 expires 2023-03-03 08:00:00 UTC")
+
+	_, body, err := renderMail(Event{Kind: EventRateDigest, Data: digest})
+	assert.NoError(t, err)
+	assert.Contains(t, body, "<td>GBP-INR</td><td>100.2</td><td>101</td><td>100.2</td><td>100.6</td><td>101</td><td>3</td>")
+	assert.Contains(t, body, "Re-bookings: 1")
+	assert.Contains(t, body, "expires <b>2023-03-03 08:00:00 UTC</b>")
+}
+
+func TestRateDigestOnly(t *testing.T) {
+	defer func(digest, at, only, limit string) {
+		rateDigestVar, rateDigestAtVar, rateDigestOnlyVar, notifyRateLimitVar = digest, at, only, limit
+	}(rateDigestVar, rateDigestAtVar, rateDigestOnlyVar, notifyRateLimitVar)
+	notifyRateLimitVar = "0"
+
+	fake := &fakeNotifier{}
+	defer func(n []Notifier) { Notifiers = n }(Notifiers)
+	Notifiers = []Notifier{fake}
+
+	rateDigestVar, rateDigestOnlyVar = rateDigestWeekly, "true"
+	notify(Event{Kind: EventRebooked})
+	notify(Event{Kind: EventError})
+	assert.Len(t, fake.events, 1, "rebooked is left out for the digest")
+	assert.Equal(t, EventError, fake.events[0].Kind)
+
+	rateDigestOnlyVar = "false"
+	notify(Event{Kind: EventRebooked})
+	assert.Len(t, fake.events, 2)
+
+	t.Run("invalid settings", func(t *testing.T) {
+		rateDigestVar = "hourly"
+		_, _, err := getRateDigest()
+		assert.Error(t, err)
+
+		rateDigestVar, rateDigestAtVar = rateDigestDaily, "24:00"
+		_, _, err = getRateDigest()
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// rate history related constants
+const (
+	rateHistoryDays       = 7
+	rateHistoryGroup      = "hour"
+	rateHistoryTimeFormat = "2006-01-02T15:04:05"
+	sparklineWidth        = 28
+)
+
+// sparklineTicks are the block characters used to draw a sparkline, lowest to highest
+var sparklineTicks = []rune("▁▂▃▄▅▆▇█")
+
+// RateSummary holds min/max/avg figures over a rate history window
+type RateSummary struct {
+	Days      int
+	Min       float64
+	Max       float64
+	Avg       float64
+	Sparkline string
+}
+
+// Fetch the grouped rate history between from and to for the given currency pair
+func getRateHistory(source string, target string, from time.Time, to time.Time, group string) ([]LiveRate, error) {
+	params := url.Values{
+		"source": {source},
+		"target": {target},
+		"from":   {from.UTC().Format(rateHistoryTimeFormat)},
+		"to":     {to.UTC().Format(rateHistoryTimeFormat)},
+		"group":  {group},
+	}
+	url := &url.URL{RawQuery: params.Encode(), Host: hostVar, Scheme: "https", Path: liveRateAPIPath}
+
+	var history []LiveRate
+	_, err := callExternalAPI(http.MethodGet, url.String(), nil, &history)
+	if err != nil {
+		return nil, fmt.Errorf("error GET rate history API: %w", err)
+	}
+
+	return history, nil
+}
+
+// Fetch and summarize the last rateHistoryDays days of hourly rates for the given currency pair
+func getRateSummary(source string, target string) (RateSummary, error) {
+	to := time.Now().UTC()
+	from := to.AddDate(0, 0, -rateHistoryDays)
+
+	history, err := getRateHistory(source, target, from, to, rateHistoryGroup)
+	if err != nil {
+		return RateSummary{}, fmt.Errorf("getRateSummary: %v", err)
+	}
+	if len(history) == 0 {
+		return RateSummary{}, fmt.Errorf("getRateSummary: no rate history found for {%v} --> {%v}", source, target)
+	}
+
+	summary := summarizeRates(history)
+	summary.Days = rateHistoryDays
+	return summary, nil
+}
+
+// The rate summary of the pair added to notifications and its text, none when the rate history can't be fetched
+func notificationRateSummary(source string, target string) (*RateSummary, string) {
+	summary, err := getRateSummary(source, target)
+	if err != nil {
+		log.Printf("notificationRateSummary: %v", err)
+		return nil, ""
+	}
+	return &summary, fmt.Sprintf(rateSummaryText, summary.Days, formatRate(summary.Min), formatRate(summary.Max),
+		formatRate(summary.Avg), summary.Sparkline)
+}
+
+func summarizeRates(history []LiveRate) (summary RateSummary) {
+	if len(history) == 0 {
+		return
+	}
+
+	values := make([]float64, len(history))
+	sum := 0.0
+	for i, rate := range history {
+		values[i] = rate.Rate
+		sum += rate.Rate
+		if i == 0 || rate.Rate < summary.Min {
+			summary.Min = rate.Rate
+		}
+		if i == 0 || rate.Rate > summary.Max {
+			summary.Max = rate.Rate
+		}
+	}
+	summary.Avg = sum / float64(len(values))
+	summary.Sparkline = sparkline(downsample(values, sparklineWidth))
+	return
+}
+
+// Reduce values to at most width points by averaging evenly sized buckets
+func downsample(values []float64, width int) []float64 {
+	if width <= 0 || len(values) <= width {
+		return values
+	}
+
+	sampled := make([]float64, width)
+	for i := 0; i < width; i++ {
+		start := i * len(values) / width
+		end := (i + 1) * len(values) / width
+		sum := 0.0
+		for _, value := range values[start:end] {
+			sum += value
+		}
+		sampled[i] = sum / float64(end-start)
+	}
+	return sampled
+}
+
+func sparkline(values []float64) string {
+	if len(values) == 0 {
+		return ""
+	}
+
+	min, max := values[0], values[0]
+	for _, value := range values {
+		min = math.Min(min, value)
+		max = math.Max(max, value)
+	}
+
+	var sb strings.Builder
+	for _, value := range values {
+		index := 0
+		if max > min {
+			index = int((value - min) / (max - min) * float64(len(sparklineTicks)-1))
+		}
+		sb.WriteRune(sparklineTicks[index])
+	}
+	return sb.String()
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"transferwisely/mocks"
+)
+
+func TestGetRateHistory(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		// build response JSON
+		history := []LiveRate{{Rate: 1.1}, {Rate: 1.2}, {Rate: 1.3}}
+		j, _ := json.Marshal(history)
+		// create a new reader with that JSON
+		r := ioutil.NopCloser(bytes.NewReader(j))
+		mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+			assert.Equal(t, rateHistoryGroup, req.URL.Query().Get("group"))
+			assert.NotEmpty(t, req.URL.Query().Get("from"))
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       r,
+			}, nil
+		}
+
+		rates, err := getRateHistory("EUR", "USD", time.Now().AddDate(0, 0, -7), time.Now(), rateHistoryGroup)
+		assert.NoError(t, err)
+		assert.Len(t, rates, 3)
+		assert.Equal(t, 1.2, rates[1].Rate)
+	})
+
+	t.Run("external api error", func(t *testing.T) {
+		r := ioutil.NopCloser(bytes.NewReader([]byte("{}")))
+		mocks.GetDoFunc = func(*http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusInternalServerError,
+				Body:       r,
+			}, nil
+		}
+
+		rates, err := getRateHistory("EUR", "USD", time.Now().AddDate(0, 0, -7), time.Now(), rateHistoryGroup)
+		assert.Empty(t, rates)
+		assert.Error(t, err)
+	})
+}
+
+func TestSummarizeRates(t *testing.T) {
+	summary := summarizeRates([]LiveRate{{Rate: 2}, {Rate: 1}, {Rate: 3}})
+	assert.Equal(t, 1.0, summary.Min)
+	assert.Equal(t, 3.0, summary.Max)
+	assert.Equal(t, 2.0, summary.Avg)
+	assert.Equal(t, "▄▁█", summary.Sparkline)
+
+	assert.Empty(t, summarizeRates(nil))
+}
+
+func TestSparkline(t *testing.T) {
+	assert.Equal(t, "", sparkline(nil))
+	assert.Equal(t, "▁▁▁", sparkline([]float64{5, 5, 5}))
+	assert.Len(t, []rune(sparkline(downsample(make([]float64, 168), sparklineWidth))), sparklineWidth)
+}
+
+func TestRateSummaryInNotifications(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "transferwisely")
+	defer os.RemoveAll(dir)
+	defer func(file string) { stateFileVar = file }(stateFileVar)
+	stateFileVar = filepath.Join(dir, "state.json")
+	fake := &fakeNotifier{}
+	defer func(n []Notifier) { Notifiers = n }(Notifiers)
+	Notifiers = []Notifier{fake}
+	mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+		body := "[]"
+		switch {
+		case req.URL.Query().Get("group") == rateHistoryGroup:
+			body = `[{"rate": 100}, {"rate": 102}, {"rate": 101}]`
+		case req.Method == http.MethodPost && strings.HasSuffix(req.URL.String(), quotesAPIPath):
+			body = fmt.Sprintf(`{"id": "quote-1", "rate": 101, "sourceAmount": 1000, "rateExpirationTime": %q}`,
+				time.Now().UTC().Add(time.Hour).Format(time.RFC3339))
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewReader([]byte(body)))}, nil
+	}
+
+	recordNoAction(Transfer{Id: 1, Rate: 100.5, SourceCurrency: "GBP", TargetCurrency: "INR"}, 100.2)
+	sendNoActionDigest()
+	assert.Len(t, fake.events, 1)
+	assert.Equal(t, EventNoActionDigest, fake.events[0].Kind)
+	assert.Contains(t, fake.events[0].Text, "Rates over the last 7 days\nMin: 100 | Max: 102 | Avg: 101\n▁█▄")
+
+	_, body, err := renderMail(fake.events[0])
+	assert.NoError(t, err)
+	assert.Contains(t, body, "Rates over the last 7 days")
+	assert.Contains(t, body, "<pre>▁█▄</pre>")
+
+	proposal, err := proposeRebook(Transfer{Id: 2, Rate: 100.5, SourceAmount: decimalOf(1000), SourceCurrency: "GBP", TargetCurrency: "INR"},
+		Settings{}, rebookReasonBetterRate, time.Now().UTC())
+	assert.NoError(t, err)
+	assert.Len(t, fake.events, 2)
+	assert.Equal(t, EventProposal, fake.events[1].Kind)
+	assert.Contains(t, fake.events[1].Text, "Rates over the last 7 days")
+	data := fake.events[1].Data.(ProposalMailData)
+	assert.Equal(t, proposal.Id, data.Proposal.Id)
+	assert.Equal(t, 102.0, data.Summary.Max)
+
+	_, body, err = renderMail(fake.events[1])
+	assert.NoError(t, err)
+	assert.Contains(t, body, "Proposal ID: "+proposal.Id)
+	assert.Contains(t, body, "<pre>▁█▄</pre>")
+}
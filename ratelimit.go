@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// tokenBucket lets rate calls through per second on average, with bursts of up to burst calls
+type tokenBucket struct {
+	sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// shared by every call to transfer-wise API whatever the pair, nil meaning no limit
+var apiLimiter = struct {
+	sync.Once
+	bucket *tokenBucket
+}{}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// Reserve a token, returning how long to wait before using it
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	b.Lock()
+	defer b.Unlock()
+
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+
+	// going below zero queues the caller behind the ones already waiting
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// Block until a call to transfer-wise API is allowed by API_RATE_LIMIT
+func waitForAPI() {
+	apiLimiter.Do(func() {
+		rate, burst, err := getAPIRateLimit()
+		if err != nil {
+			log.Printf("waitForAPI: %v, not rate limiting", err)
+			return
+		}
+		if rate > 0 {
+			apiLimiter.bucket = newTokenBucket(rate, burst)
+		}
+	})
+	if apiLimiter.bucket == nil {
+		return
+	}
+
+	if wait := apiLimiter.bucket.reserve(time.Now()); wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+func getAPIRateLimit() (rate float64, burst int, err error) {
+	rate, err = strconv.ParseFloat(apiRateLimitVar, 64)
+	if err != nil || rate < 0 {
+		return 0, 0, fmt.Errorf("invalid value for API_RATE_LIMIT: %v", apiRateLimitVar)
+	}
+	burst, err = strconv.Atoi(apiRateBurstVar)
+	if err != nil || burst < 1 {
+		return 0, 0, fmt.Errorf("invalid value for API_RATE_BURST: %v", apiRateBurstVar)
+	}
+	return rate, burst, nil
+}
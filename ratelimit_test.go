@@ -0,0 +1,43 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// tests call the mocked API far more often than the default limit allows
+func init() {
+	apiRateLimitVar = "0"
+}
+
+func TestTokenBucket(t *testing.T) {
+	bucket := newTokenBucket(2, 3)
+	now := bucket.last
+
+	for i := 0; i < 3; i++ {
+		assert.Zero(t, bucket.reserve(now), "burst")
+	}
+	assert.Equal(t, 500*time.Millisecond, bucket.reserve(now))
+	assert.Equal(t, time.Second, bucket.reserve(now), "queued behind the previous caller")
+
+	// refilled at 2 tokens per second, never above the burst
+	assert.Zero(t, bucket.reserve(now.Add(10*time.Second)))
+	assert.Zero(t, bucket.reserve(now.Add(10*time.Second)))
+	assert.Zero(t, bucket.reserve(now.Add(10*time.Second)))
+	assert.Equal(t, 500*time.Millisecond, bucket.reserve(now.Add(10*time.Second)))
+}
+
+func TestGetAPIRateLimit(t *testing.T) {
+	defer func(rate, burst string) { apiRateLimitVar, apiRateBurstVar = rate, burst }(apiRateLimitVar, apiRateBurstVar)
+
+	apiRateLimitVar, apiRateBurstVar = "0.5", "1"
+	rate, burst, err := getAPIRateLimit()
+	assert.NoError(t, err)
+	assert.Equal(t, 0.5, rate)
+	assert.Equal(t, 1, burst)
+
+	apiRateBurstVar = "0"
+	_, _, err = getAPIRateLimit()
+	assert.Error(t, err)
+}
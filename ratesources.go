@@ -0,0 +1,279 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// secondary rate sources RATE_SOURCES can list
+const (
+	rateSourceECB              = "ecb"
+	rateSourceExchangeRateHost = "exchangerate.host"
+)
+
+// rate source endpoints
+const (
+	ecbDailyRatesURL        = "https://www.ecb.europa.eu/stats/eurofxref/eurofxref-daily.xml"
+	exchangeRateHostLiveURL = "https://api.exchangerate.host/live"
+)
+
+// how long a reference rate is reused, the ECB publishing once a day and exchangerate.host having a monthly quota
+const rateSourceCacheTTL = 15 * time.Minute
+
+// rate deviation notification
+const (
+	rateDeviationSubject = "Wise's {%v} --> {%v} rate is %+.2f%% off %v"
+	rateDeviationText    = "Wise: %v\n%v: %v\nSpread: %+.2f%%, more than the %v%% RATE_DEVIATION\n\n" +
+		"Wise may be updating the pair slowly, or the reference may be stale: double check before funding a transfer."
+	crossCheckText     = "\n\nCross-checked against:\n%v"
+	crossCheckLineText = "%v: %v (Wise %+.2f%%)"
+)
+
+// RateSource is an independent reference rate to cross-check Wise's live rate against
+type RateSource interface {
+	Name() string
+	Rate(source string, target string) (float64, error)
+}
+
+// ReferenceRate is a rate source's rate for a pair along with how far Wise's live rate is from it, in percent
+type ReferenceRate struct {
+	Source string    `json:"source"`
+	Rate   float64   `json:"rate"`
+	Spread float64   `json:"spread"`
+	Time   time.Time `json:"time"`
+}
+
+var rateSourceCache = struct {
+	sync.Mutex
+	rates map[string]ReferenceRate
+}{rates: map[string]ReferenceRate{}}
+
+// last cross-check by pair, and the rate sources Wise deviated from by pair and source
+var crossChecks = struct {
+	sync.Mutex
+	byPair   map[string][]ReferenceRate
+	deviated map[string]bool
+}{byPair: map[string][]ReferenceRate{}, deviated: map[string]bool{}}
+
+func getConfiguredRateSources() ([]RateSource, error) {
+	var sources []RateSource
+	for _, name := range strings.Split(rateSourcesVar, ",") {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "":
+		case rateSourceECB:
+			sources = append(sources, ecbRateSource{})
+		case rateSourceExchangeRateHost:
+			if exchangeRateHostKeyVar == "" {
+				return nil, fmt.Errorf("EXCHANGERATE_HOST_KEY is required with RATE_SOURCES=%v", rateSourceExchangeRateHost)
+			}
+			sources = append(sources, exchangeRateHostSource{key: exchangeRateHostKeyVar})
+		default:
+			return nil, fmt.Errorf("invalid value for RATE_SOURCES: %v, must be %v or %v", name, rateSourceECB,
+				rateSourceExchangeRateHost)
+		}
+	}
+	return sources, nil
+}
+
+func getRateDeviation() (float64, error) {
+	deviation, err := strconv.ParseFloat(rateDeviationVar, 64)
+	if err != nil || deviation <= 0 {
+		return 0, fmt.Errorf("invalid value for RATE_DEVIATION: %v, expected a positive percentage", rateDeviationVar)
+	}
+	return deviation, nil
+}
+
+// Compare Wise's live rate for the pair to every configured rate source, logging the spreads and notifying once when
+// one deviates more than RATE_DEVIATION percent, until it's back within it
+func crossCheckRate(source string, target string, liveRate float64, now time.Time) []ReferenceRate {
+	sources, err := getConfiguredRateSources()
+	if err != nil {
+		log.Printf("crossCheckRate: %v", err)
+		return nil
+	}
+	if len(sources) == 0 {
+		return nil
+	}
+	deviation, err := getRateDeviation()
+	if err != nil {
+		log.Printf("crossCheckRate: %v", err)
+		return nil
+	}
+
+	pair := pairKey(source, target)
+	var references []ReferenceRate
+	for _, rateSource := range sources {
+		reference, err := getReferenceRate(rateSource, source, target, now)
+		if err != nil {
+			log.Printf("crossCheckRate: %v: %v", rateSource.Name(), err)
+			continue
+		}
+		reference.Spread = (liveRate/reference.Rate - 1) * 100
+		references = append(references, reference)
+		log.Printf("|| RATE CROSS-CHECK || {%v} --> {%v} | Wise: %v | %v: %v | Spread: %+.2f%% ||", source, target, liveRate,
+			reference.Source, reference.Rate, reference.Spread)
+
+		key := pair + " " + reference.Source
+		deviated := math.Abs(reference.Spread) > deviation
+		crossChecks.Lock()
+		wasDeviated := crossChecks.deviated[key]
+		crossChecks.deviated[key] = deviated
+		crossChecks.Unlock()
+		if !deviated || wasDeviated {
+			continue
+		}
+		notify(Event{
+			Kind:    EventRateDeviation,
+			Subject: fmt.Sprintf(rateDeviationSubject, source, target, reference.Spread, reference.Source),
+			Text: fmt.Sprintf(rateDeviationText, liveRate, reference.Source, reference.Rate, reference.Spread,
+				rateDeviationVar),
+			Pair: pair,
+		})
+	}
+
+	crossChecks.Lock()
+	crossChecks.byPair[pair] = references
+	crossChecks.Unlock()
+	return references
+}
+
+// The reference rate of the pair, fetched again once rateSourceCacheTTL passed
+func getReferenceRate(rateSource RateSource, source string, target string, now time.Time) (ReferenceRate, error) {
+	key := rateSource.Name() + " " + pairKey(source, target)
+	rateSourceCache.Lock()
+	cached, ok := rateSourceCache.rates[key]
+	rateSourceCache.Unlock()
+	if ok && now.Sub(cached.Time) < rateSourceCacheTTL {
+		return cached, nil
+	}
+
+	rate, err := rateSource.Rate(source, target)
+	if err != nil {
+		return ReferenceRate{}, err
+	}
+	if rate <= 0 {
+		return ReferenceRate{}, fmt.Errorf("no {%v} --> {%v} rate", source, target)
+	}
+	reference := ReferenceRate{Source: rateSource.Name(), Rate: rate, Time: now}
+	rateSourceCache.Lock()
+	rateSourceCache.rates[key] = reference
+	rateSourceCache.Unlock()
+	return reference, nil
+}
+
+// The spreads of the pair's last cross-check, appended to the rebooked notification
+func formatCrossCheck(source string, target string) string {
+	crossChecks.Lock()
+	references := crossChecks.byPair[pairKey(source, target)]
+	crossChecks.Unlock()
+	if len(references) == 0 {
+		return ""
+	}
+	lines := make([]string, len(references))
+	for i, reference := range references {
+		lines[i] = fmt.Sprintf(crossCheckLineText, reference.Source, reference.Rate, reference.Spread)
+	}
+	sort.Strings(lines)
+	return fmt.Sprintf(crossCheckText, strings.Join(lines, "\n"))
+}
+
+func fetchRateSource(rawURL string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating rate source request: %v", err)
+	}
+	res, err := Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error calling rate source: %v", err)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	_ = res.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("error reading rate source response: %v", err)
+	}
+	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusMultipleChoices {
+		return nil, fmt.Errorf("error calling rate source: %v", res.StatusCode)
+	}
+	return body, nil
+}
+
+// ecbRateSource crosses the euro foreign exchange reference rates the European Central Bank publishes every working day
+type ecbRateSource struct{}
+
+// ecbRates is the daily reference rates file, each rate being how much of the currency a euro buys
+type ecbRates struct {
+	Cube struct {
+		Cube struct {
+			Time  string `xml:"time,attr"`
+			Rates []struct {
+				Currency string  `xml:"currency,attr"`
+				Rate     float64 `xml:"rate,attr"`
+			} `xml:"Cube"`
+		} `xml:"Cube"`
+	} `xml:"Cube"`
+}
+
+func (ecbRateSource) Name() string {
+	return rateSourceECB
+}
+
+func (ecbRateSource) Rate(source string, target string) (float64, error) {
+	body, err := fetchRateSource(ecbDailyRatesURL)
+	if err != nil {
+		return 0, err
+	}
+	var rates ecbRates
+	if err := xml.Unmarshal(body, &rates); err != nil {
+		return 0, fmt.Errorf("error decoding ECB rates: %v", err)
+	}
+	perEuro := map[string]float64{"EUR": 1}
+	for _, rate := range rates.Cube.Cube.Rates {
+		perEuro[rate.Currency] = rate.Rate
+	}
+	if perEuro[source] == 0 || perEuro[target] == 0 {
+		return 0, fmt.Errorf("the ECB publishes no reference rate for {%v} --> {%v}", source, target)
+	}
+	return perEuro[target] / perEuro[source], nil
+}
+
+// exchangeRateHostSource asks exchangerate.host, which needs an access key
+type exchangeRateHostSource struct {
+	key string
+}
+
+func (exchangeRateHostSource) Name() string {
+	return rateSourceExchangeRateHost
+}
+
+func (s exchangeRateHostSource) Rate(source string, target string) (float64, error) {
+	query := url.Values{"access_key": {s.key}, "source": {source}, "currencies": {target}}
+	body, err := fetchRateSource(exchangeRateHostLiveURL + "?" + query.Encode())
+	if err != nil {
+		return 0, err
+	}
+	var response struct {
+		Success bool               `json:"success"`
+		Quotes  map[string]float64 `json:"quotes"`
+		Error   struct {
+			Info string `json:"info"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return 0, fmt.Errorf("error decoding exchangerate.host rates: %v", err)
+	}
+	if !response.Success {
+		return 0, fmt.Errorf("exchangerate.host: %v", response.Error.Info)
+	}
+	return response.Quotes[source+target], nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+	"transferwisely/mocks"
+)
+
+const ecbDailyRates = `<?xml version="1.0" encoding="UTF-8"?>
+<gesmes:Envelope xmlns:gesmes="http://www.gesmes.org/xml/2002-08-01" xmlns="http://www.ecb.int/vocabulary/2002-08-01/eurofxref">
+	<gesmes:subject>Reference rates</gesmes:subject>
+	<Cube>
+		<Cube time="2020-05-01">
+			<Cube currency="USD" rate="1.1"/>
+			<Cube currency="GBP" rate="0.88"/>
+			<Cube currency="INR" rate="88"/>
+		</Cube>
+	</Cube>
+</gesmes:Envelope>`
+
+func TestCrossCheckRate(t *testing.T) {
+	defer func(sources, key, deviation, limit string) {
+		rateSourcesVar, exchangeRateHostKeyVar, rateDeviationVar, notifyRateLimitVar = sources, key, deviation, limit
+	}(rateSourcesVar, exchangeRateHostKeyVar, rateDeviationVar, notifyRateLimitVar)
+	rateSourcesVar, exchangeRateHostKeyVar, rateDeviationVar, notifyRateLimitVar = "ecb,exchangerate.host", "key", "1", "0"
+	fake := &fakeNotifier{}
+	defer func(n []Notifier) { Notifiers = n }(Notifiers)
+	Notifiers = []Notifier{fake}
+
+	calls := 0
+	mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+		calls++
+		body := ecbDailyRates
+		if strings.HasPrefix(req.URL.String(), exchangeRateHostLiveURL) {
+			assert.Equal(t, "key", req.URL.Query().Get("access_key"))
+			body = `{"success": true, "quotes": {"GBPINR": 98}}`
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(body))}, nil
+	}
+	now := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	references := crossCheckRate("GBP", "INR", 100.5, now)
+	assert.Len(t, references, 2)
+	assert.Equal(t, rateSourceECB, references[0].Source)
+	assert.InDelta(t, 100, references[0].Rate, 1e-9, "crossed through the euro")
+	assert.InDelta(t, 0.5, references[0].Spread, 1e-9)
+	assert.InDelta(t, 2.55, references[1].Spread, 0.01)
+	assert.Len(t, fake.events, 1, "only exchangerate.host deviates more than RATE_DEVIATION")
+	assert.Equal(t, EventRateDeviation, fake.events[0].Kind)
+	assert.Contains(t, formatCrossCheck("GBP", "INR"), "ecb: 100 (Wise +0.50%)")
+
+	crossCheckRate("GBP", "INR", 100.6, now.Add(time.Minute))
+	assert.Equal(t, 2, calls, "reference rates cached")
+	assert.Len(t, fake.events, 1, "notified once while deviated")
+
+	t.Run("pair the ECB doesn't publish", func(t *testing.T) {
+		_, err := ecbRateSource{}.Rate("GBP", "BRL")
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid config", func(t *testing.T) {
+		rateSourcesVar = "oanda"
+		_, err := getConfiguredRateSources()
+		assert.Error(t, err)
+		rateSourcesVar, exchangeRateHostKeyVar = rateSourceExchangeRateHost, ""
+		_, err = getConfiguredRateSources()
+		assert.Error(t, err)
+	})
+}
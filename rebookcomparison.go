@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// old vs new table of the rebooked notification
+const (
+	rebookComparisonText = "\n\nOld vs new:\n%v"
+	rebookGainText       = "Net gain: %v %v for the recipient"
+)
+
+// RebookSide is one of the transfers of a re-booking, as the rebooked notification compares them
+type RebookSide struct {
+	TransferId   uint64
+	Rate         float64
+	Fee          Decimal
+	SourceAmount Decimal
+	TargetAmount Decimal
+
+	// estimated delivery time, empty when transferwise didn't tell
+	Delivery string
+}
+
+// RebookComparison puts the transfer a re-booking cancelled side by side with the one it booked, to check the
+// re-booking paid off
+type RebookComparison struct {
+	SourceCurrency string
+	TargetCurrency string
+	Old            RebookSide
+	New            RebookSide
+
+	// target amount the recipient gets more with the new transfer, less when negative
+	Gain Decimal
+}
+
+type DeliveryEstimate struct {
+	EstimatedDeliveryDate string `json:"estimatedDeliveryDate"`
+}
+
+// Fetch when transferwise expects the transfer to reach its recipient
+func getDeliveryEstimate(transferId uint64) (DeliveryEstimate, error) {
+	path := strings.Replace(deliveryEstimateAPIPath, "{transferId}", strconv.FormatUint(transferId, 10), 1)
+	url := &url.URL{Host: hostVar, Scheme: "https", Path: path}
+	var estimate DeliveryEstimate
+	_, err := callExternalAPI(http.MethodGet, url.String(), nil, &estimate)
+	if err != nil {
+		return DeliveryEstimate{}, fmt.Errorf("error GET delivery estimate API: %w", err)
+	}
+	return estimate, nil
+}
+
+// The transfer's side of the comparison, completed with the fee and target amount of its quote and its delivery
+// estimate, the ones that can't be fetched being left out
+func getRebookSide(transfer Transfer) RebookSide {
+	side := RebookSide{TransferId: transfer.Id, Rate: transfer.Rate, SourceAmount: transfer.SourceAmount,
+		TargetAmount: transfer.TargetAmount}
+	if transfer.QuoteUuid != "" {
+		quote, err := getDetailByQuoteId(transfer.QuoteUuid)
+		if err != nil {
+			log.Printf("getRebookSide: %v", err)
+		} else {
+			side.Fee = quote.Fee
+			if quote.TargetAmount.Sign() > 0 {
+				side.TargetAmount = quote.TargetAmount
+			}
+		}
+	}
+	if side.TargetAmount.Sign() == 0 {
+		side.TargetAmount = convertAmount(side.SourceAmount.Sub(side.Fee), side.Rate, transfer.TargetCurrency)
+	}
+
+	estimate, err := getDeliveryEstimate(transfer.Id)
+	if err != nil {
+		log.Printf("getRebookSide: %v", err)
+	} else if estimate.EstimatedDeliveryDate != "" {
+		side.Delivery = formatExpiry(estimate.EstimatedDeliveryDate)
+	}
+	return side
+}
+
+func getRebookComparison(oldTransfer Transfer, newTransfer Transfer) RebookComparison {
+	comparison := RebookComparison{
+		SourceCurrency: newTransfer.SourceCurrency,
+		TargetCurrency: newTransfer.TargetCurrency,
+		Old:            getRebookSide(oldTransfer),
+		New:            getRebookSide(newTransfer),
+	}
+	comparison.Gain = comparison.New.TargetAmount.Sub(comparison.Old.TargetAmount)
+	return comparison
+}
+
+// The gain with its sign, like +520.00
+func (c RebookComparison) FormattedGain() string {
+	gain := formatAmount(c.Gain, c.TargetCurrency)
+	if c.Gain.Sign() > 0 {
+		gain = "+" + gain
+	}
+	return gain
+}
+
+func formatRebookComparison(c RebookComparison) string {
+	delivery := func(side RebookSide) string {
+		if side.Delivery == "" {
+			return "unknown"
+		}
+		return side.Delivery
+	}
+
+	var buf bytes.Buffer
+	tw := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "\tOld\tNew\n")
+	fmt.Fprintf(tw, "Transfer ID\t%v\t%v\n", c.Old.TransferId, c.New.TransferId)
+	fmt.Fprintf(tw, "Rate\t%v\t%v\n", formatRate(c.Old.Rate), formatRate(c.New.Rate))
+	fmt.Fprintf(tw, "Fee\t%v %v\t%v %v\n", formatAmount(c.Old.Fee, c.SourceCurrency), c.SourceCurrency,
+		formatAmount(c.New.Fee, c.SourceCurrency), c.SourceCurrency)
+	fmt.Fprintf(tw, "You send\t%v %v\t%v %v\n", formatAmount(c.Old.SourceAmount, c.SourceCurrency), c.SourceCurrency,
+		formatAmount(c.New.SourceAmount, c.SourceCurrency), c.SourceCurrency)
+	fmt.Fprintf(tw, "Recipient gets\t%v %v\t%v %v\n", formatAmount(c.Old.TargetAmount, c.TargetCurrency), c.TargetCurrency,
+		formatAmount(c.New.TargetAmount, c.TargetCurrency), c.TargetCurrency)
+	fmt.Fprintf(tw, "Delivery\t%v\t%v\n", delivery(c.Old), delivery(c.New))
+	_ = tw.Flush()
+
+	return fmt.Sprintf(rebookComparisonText, buf.String()) + fmt.Sprintf(rebookGainText, c.FormattedGain(), c.TargetCurrency)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"transferwisely/mocks"
+)
+
+func TestRebookComparison(t *testing.T) {
+	defer func(v string) { timezoneVar = v }(timezoneVar)
+	timezoneVar = "UTC"
+	mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+		body := `{}`
+		switch {
+		case strings.HasSuffix(req.URL.Path, "quotes/old"):
+			body = `{"id": "old", "rate": 100, "sourceAmount": 1000, "targetAmount": 99650, "payOut": "BANK_TRANSFER",
+				"paymentOptions": [{"payIn": "BANK_TRANSFER", "payOut": "BANK_TRANSFER", "sourceAmount": 1000, "targetAmount": 99650, "fee": {"total": 3.5}}]}`
+		case strings.HasSuffix(req.URL.Path, "quotes/new"):
+			body = `{"id": "new", "rate": 101, "sourceAmount": 1000, "targetAmount": 100646.5, "payOut": "BANK_TRANSFER",
+				"paymentOptions": [{"payIn": "BANK_TRANSFER", "payOut": "BANK_TRANSFER", "sourceAmount": 1000, "targetAmount": 100646.5, "fee": {"total": 3.5}}]}`
+		case strings.HasSuffix(req.URL.Path, "delivery-estimates/1"):
+			return &http.Response{StatusCode: http.StatusNotFound, Body: ioutil.NopCloser(strings.NewReader(`{}`))}, nil
+		case strings.HasSuffix(req.URL.Path, "delivery-estimates/2"):
+			body = `{"estimatedDeliveryDate": "2024-05-02T10:00:00.000Z"}`
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(body))}, nil
+	}
+
+	comparison := getRebookComparison(
+		Transfer{Id: 1, Rate: 100, SourceAmount: decimalOf(1000), QuoteUuid: "old", SourceCurrency: "GBP", TargetCurrency: "INR"},
+		Transfer{Id: 2, Rate: 101, SourceAmount: decimalOf(1000), QuoteUuid: "new", SourceCurrency: "GBP", TargetCurrency: "INR"})
+	assert.Equal(t, decimalOf(3.5), comparison.New.Fee)
+	assert.Equal(t, decimalOf(99650.0), comparison.Old.TargetAmount)
+	assert.Equal(t, "", comparison.Old.Delivery)
+	assert.Equal(t, "2024-05-02 10:00:00 UTC", comparison.New.Delivery)
+	assert.Equal(t, decimalOf(996.5), comparison.Gain)
+
+	text := formatRebookComparison(comparison)
+	assert.Contains(t, text, "Old vs new:\n")
+	assert.Contains(t, text, "Rate            100           101\n")
+	assert.Contains(t, text, "Recipient gets  99650.00 INR  100646.50 INR\n")
+	assert.Contains(t, text, "Delivery        unknown       2024-05-02 10:00:00 UTC\n")
+	assert.True(t, strings.HasSuffix(text, "Net gain: +996.50 INR for the recipient"))
+}
+
+func TestRebookComparisonWithoutQuotes(t *testing.T) {
+	mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusInternalServerError, Body: ioutil.NopCloser(strings.NewReader(`{}`))}, nil
+	}
+
+	// the target amounts fall back to the converted source amounts
+	comparison := getRebookComparison(
+		Transfer{Id: 1, Rate: 100, SourceAmount: decimalOf(1000), QuoteUuid: "old", SourceCurrency: "GBP", TargetCurrency: "INR"},
+		Transfer{Id: 2, Rate: 99.5, SourceAmount: decimalOf(1000), QuoteUuid: "new", SourceCurrency: "GBP", TargetCurrency: "INR"})
+	assert.Equal(t, decimalOf(100000.0), comparison.Old.TargetAmount)
+	assert.Equal(t, decimalOf(-500.0), comparison.Gain)
+	assert.Equal(t, "-500.00", comparison.FormattedGain())
+}
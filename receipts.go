@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// receipt API path and the status of the transfers it's available for
+const (
+	receiptAPIPath          = "v1/transfers/{transferId}/receipt.pdf"
+	transferStatusCompleted = "outgoing_payment_sent"
+)
+
+// transfer completed notification
+const (
+	transferCompletedSubject = "Transfer %v completed"
+	transferCompletedText    = "Transfer ID: %v\n{%v} --> {%v}\nRate: %v\nThe receipt is attached."
+)
+
+// Attachment is a file attached to the mails of an event
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Content     []byte
+}
+
+// TransferCompletedMailData is the Data of transfer-completed events
+type TransferCompletedMailData struct {
+	Transfer    Transfer
+	ReceiptFile string
+}
+
+func isFetchingReceipts() bool {
+	receipts, _ := strconv.ParseBool(receiptsVar)
+	return receipts
+}
+
+// Fetch the receipt of the transfer that just completed, save it to RECEIPT_DIR if set, and notify about the
+// completion with the receipt attached to the mails
+func notifyTransferCompleted(transfer Transfer) error {
+	receipt, err := getReceipt(transfer.Id)
+	if err != nil {
+		return fmt.Errorf("notifyTransferCompleted: %v", err)
+	}
+	filename := fmt.Sprintf("transfer-%v-receipt.pdf", transfer.Id)
+
+	var receiptFile string
+	if receiptDirVar != "" {
+		receiptFile = filepath.Join(receiptDirVar, filename)
+		if err = os.MkdirAll(receiptDirVar, 0700); err == nil {
+			err = ioutil.WriteFile(receiptFile, receipt, 0600)
+		}
+		if err != nil {
+			return fmt.Errorf("notifyTransferCompleted: error saving receipt: %v", err)
+		}
+	}
+
+	log.Printf("|| TRANSFER COMPLETED || Transfer ID: %v | {%v} --> {%v} | Rate: %v | Receipt: %v ||",
+		transfer.Id, transfer.SourceCurrency, transfer.TargetCurrency, transfer.Rate, receiptFile)
+	notify(Event{
+		Kind:        EventTransferCompleted,
+		Subject:     fmt.Sprintf(transferCompletedSubject, transfer.Id),
+		Text:        fmt.Sprintf(transferCompletedText, transfer.Id, transfer.SourceCurrency, transfer.TargetCurrency, formatRate(transfer.Rate)),
+		Data:        TransferCompletedMailData{Transfer: transfer, ReceiptFile: receiptFile},
+		Attachments: []Attachment{{Filename: filename, ContentType: "application/pdf", Content: receipt}},
+		Pair:        pairKey(transfer.SourceCurrency, transfer.TargetCurrency),
+		TransferId:  transfer.Id,
+	})
+	return nil
+}
+
+func getReceipt(transferId uint64) ([]byte, error) {
+	path := strings.Replace(receiptAPIPath, "{transferId}", strconv.FormatUint(transferId, 10), 1)
+	url := &url.URL{Host: hostVar, Scheme: "https", Path: path}
+
+	var receipt []byte
+	_, err := callExternalAPI(http.MethodGet, url.String(), nil, &receipt)
+	if err != nil {
+		return nil, fmt.Errorf("error GET receipt API: %w", err)
+	}
+	return receipt, nil
+}
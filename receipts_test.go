@@ -0,0 +1,62 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"transferwisely/mocks"
+)
+
+func TestMonitorTransfersReceipts(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "transferwisely")
+	defer os.RemoveAll(dir)
+	defer func(file, receipts, receiptDir, limit string) {
+		stateFileVar, receiptsVar, receiptDirVar, notifyRateLimitVar = file, receipts, receiptDir, limit
+	}(stateFileVar, receiptsVar, receiptDirVar, notifyRateLimitVar)
+	stateFileVar, receiptsVar, receiptDirVar = filepath.Join(dir, "state.json"), "true", filepath.Join(dir, "receipts")
+	notifyRateLimitVar = "0"
+
+	fake := &fakeNotifier{}
+	defer func(n []Notifier) { Notifiers = n }(Notifiers)
+	Notifiers = []Notifier{fake}
+
+	status := "funds_converted"
+	receiptCode := http.StatusOK
+	mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+		if strings.Contains(req.URL.String(), "v1/transfers/1/receipt.pdf") {
+			return &http.Response{StatusCode: receiptCode, Body: ioutil.NopCloser(strings.NewReader("%PDF-1.4 receipt"))}, nil
+		}
+		body := `[{"id": 1, "rate": 100.5, "sourceCurrency": "GBP", "targetCurrency": "INR", "status": "` + status + `"}]`
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(body))}, nil
+	}
+
+	monitorTransfers()
+	status = transferStatusCompleted
+	monitorTransfers()
+	assert.Len(t, fake.events, 1)
+	event := fake.events[0]
+	assert.Equal(t, EventTransferCompleted, event.Kind)
+	assert.Equal(t, "Transfer 1 completed", event.Subject)
+	assert.Equal(t, []Attachment{{Filename: "transfer-1-receipt.pdf", ContentType: "application/pdf",
+		Content: []byte("%PDF-1.4 receipt")}}, event.Attachments)
+
+	receiptFile := filepath.Join(dir, "receipts", "transfer-1-receipt.pdf")
+	assert.Equal(t, receiptFile, event.Data.(TransferCompletedMailData).ReceiptFile)
+	saved, err := ioutil.ReadFile(receiptFile)
+	assert.NoError(t, err)
+	assert.Equal(t, "%PDF-1.4 receipt", string(saved))
+
+	// without its receipt, the completion is still notified as a status change
+	assert.NoError(t, updateState(func(state *State) error {
+		state.TransferStatuses[1] = "funds_converted"
+		return nil
+	}))
+	receiptCode = http.StatusNotFound
+	monitorTransfers()
+	assert.Len(t, fake.events, 2)
+	assert.Equal(t, EventStatusChanged, fake.events[1].Kind)
+}
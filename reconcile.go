@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// number of most recent transfers searched for a re-booking interrupted before its transfer was known
+const reconcileTransfersLimit = 20
+
+// reason of re-bookings completed by reconciling
+const rebookReasonReconciled = "reconciled"
+
+// PendingRebook journals a re-booking from right before its transfer gets created until the old transfer is cancelled
+type PendingRebook struct {
+	CustomerTransactionId string    `json:"customerTransactionId"`
+	OldTransfer           Transfer  `json:"oldTransfer"`
+	QuoteId               string    `json:"quoteId"`
+	NewTransferId         uint64    `json:"newTransferId,omitempty"`
+	CreatedAt             time.Time `json:"createdAt"`
+
+	// account the re-booking runs for, the default one when empty
+	Account string `json:"account,omitempty"`
+}
+
+// Persist the re-booking in progress of the account being checked, nil once it completed
+func setPendingRebook(pending *PendingRebook) error {
+	account := getCurrentAccount()
+	return updateState(func(state *State) error {
+		if account == defaultAccount {
+			state.PendingRebook = pending
+			return nil
+		}
+		if pending == nil {
+			delete(state.AccountPendingRebooks, account)
+			return nil
+		}
+		if state.AccountPendingRebooks == nil {
+			state.AccountPendingRebooks = map[string]*PendingRebook{}
+		}
+		state.AccountPendingRebooks[account] = pending
+		return nil
+	})
+}
+
+// Finish the re-bookings a previous run was interrupted in, each with the API token and profile of its account
+func reconcilePendingRebook() error {
+	state, err := loadState()
+	if err != nil {
+		return fmt.Errorf("reconcilePendingRebook: %v", err)
+	}
+	pendings := map[string]*PendingRebook{defaultAccount: state.PendingRebook}
+	for account, pending := range state.AccountPendingRebooks {
+		pendings[account] = pending
+	}
+	for _, account := range append([]string{defaultAccount}, accountNames()...) {
+		pending := pendings[account]
+		delete(pendings, account)
+		if pending == nil {
+			continue
+		}
+		_ = runAsAccount(account, func() {
+			err = reconcileRebook(*pending)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for account := range pendings {
+		log.Printf("reconcilePendingRebook: re-booking of unknown account %v left as is", account)
+	}
+	return nil
+}
+
+// Finish a re-booking of the account being checked: when its new transfer got created, the old transfer still gets
+// cancelled so it doesn't stay booked next to it, otherwise there's nothing left to undo
+func reconcileRebook(pending PendingRebook) error {
+	old := pending.OldTransfer
+
+	newTransfer := Transfer{Id: pending.NewTransferId}
+	if newTransfer.Id == 0 {
+		// crashed before the create API answered, look the transfer up by its customerTransactionId
+		transfers, err := listTransfers("", reconcileTransfersLimit)
+		if err != nil {
+			return fmt.Errorf("reconcilePendingRebook: %v", err)
+		}
+		for _, transfer := range transfers {
+			if transfer.CustomerTransactionId == pending.CustomerTransactionId {
+				newTransfer = transfer
+			}
+		}
+	}
+
+	if newTransfer.Id == 0 {
+		log.Printf("|| RECONCILED, NO TRANSFER WAS CREATED || Transfer ID: %v | {%v} --> {%v} | Quote: %v ||",
+			old.Id, old.SourceCurrency, old.TargetCurrency, pending.QuoteId)
+		return setPendingRebook(nil)
+	}
+
+	log.Printf("|| RECONCILING INTERRUPTED REBOOK || New Transfer ID: %v | Cancelling Transfer ID: %v | {%v} --> {%v} ||",
+		newTransfer.Id, old.Id, old.SourceCurrency, old.TargetCurrency)
+	err := cancelReplacedTransfer(old, newTransfer)
+	if errors.Is(err, errTransferFunded) {
+		notifyFundedDuringRebook(old, newTransfer, err)
+		return setPendingRebook(nil)
+	}
+	if err != nil {
+		notifyError(fmt.Sprintf("Cancelling transfer %v after the interrupted re-booking to %v failed", old.Id, newTransfer.Id), err)
+	}
+	if newTransfer.Rate != 0 {
+		_ = recordRebookHistory(RebookRecord{
+			Time:           time.Now().UTC(),
+			OldTransferId:  old.Id,
+			NewTransferId:  newTransfer.Id,
+			SourceCurrency: old.SourceCurrency,
+			TargetCurrency: old.TargetCurrency,
+			OldRate:        old.Rate,
+			NewRate:        newTransfer.Rate,
+			SourceAmount:   old.SourceAmount,
+			Reason:         rebookReasonReconciled,
+		})
+	}
+	return setPendingRebook(nil)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"transferwisely/mocks"
+)
+
+func TestReconcilePendingRebook(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "transferwisely")
+	defer os.RemoveAll(dir)
+	defer func(file string) { stateFileVar = file }(stateFileVar)
+	stateFileVar = filepath.Join(dir, "state.json")
+
+	old := Transfer{Id: 1, Rate: 0.69, SourceCurrency: "JPY", TargetCurrency: "INR"}
+	var cancelled []string
+	listed := `[]`
+	mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+		body := listed
+		if strings.HasSuffix(req.URL.String(), "v1/transfers/1") {
+			body = `{"id": 1, "status": "incoming_payment_waiting"}`
+		}
+		if req.Method == http.MethodPut {
+			cancelled = append(cancelled, req.URL.String())
+			body = `{}`
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(body))}, nil
+	}
+
+	t.Run("nothing pending", func(t *testing.T) {
+		assert.NoError(t, reconcilePendingRebook())
+		assert.Len(t, cancelled, 0)
+	})
+
+	t.Run("crashed before the transfer was created", func(t *testing.T) {
+		assert.NoError(t, setPendingRebook(&PendingRebook{CustomerTransactionId: "tx-1", OldTransfer: old}))
+		listed = `[{"id": 5, "customerTransactionId": "tx-other"}]`
+		assert.NoError(t, reconcilePendingRebook())
+		assert.Len(t, cancelled, 0)
+
+		state, _ := loadState()
+		assert.Nil(t, state.PendingRebook)
+	})
+
+	t.Run("crashed before the old transfer was cancelled", func(t *testing.T) {
+		assert.NoError(t, setPendingRebook(&PendingRebook{CustomerTransactionId: "tx-2", OldTransfer: old}))
+		listed = `[{"id": 2, "rate": 0.7, "customerTransactionId": "tx-2"}]`
+		assert.NoError(t, reconcilePendingRebook())
+		assert.Len(t, cancelled, 1)
+		assert.Contains(t, cancelled[0], "v1/transfers/1/cancel")
+
+		state, _ := loadState()
+		assert.Nil(t, state.PendingRebook)
+		assert.Len(t, state.RebookHistory, 1)
+		assert.Equal(t, uint64(2), state.RebookHistory[0].NewTransferId)
+		assert.Equal(t, rebookReasonReconciled, state.RebookHistory[0].Reason)
+	})
+}
+
+func TestCreateTransferFromQuoteJournal(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "transferwisely")
+	defer os.RemoveAll(dir)
+	defer func(file string) { stateFileVar = file }(stateFileVar)
+	stateFileVar = filepath.Join(dir, "state.json")
+
+	var journaled *PendingRebook
+	mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+		if req.Method == http.MethodPost {
+			state, _ := loadState()
+			journaled = state.PendingRebook
+			body, _ := ioutil.ReadAll(req.Body)
+			assert.Contains(t, string(body), journaled.CustomerTransactionId)
+			return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(`{"id": 2}`))}, nil
+		}
+		if strings.HasSuffix(req.URL.String(), "v1/transfers/1") {
+			return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(`{"id": 1, "status": "incoming_payment_waiting"}`))}, nil
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(`{}`))}, nil
+	}
+
+	newTransfer, err := createTransferFromQuote(Transfer{Id: 1}, QuoteDetail{Id: "quote-1"})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), newTransfer.Id)
+	assert.NotNil(t, journaled)
+	assert.Equal(t, "quote-1", journaled.QuoteId)
+
+	state, _ := loadState()
+	assert.Nil(t, state.PendingRebook, "cleared once the old transfer is cancelled")
+}
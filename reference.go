@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// ReferenceRule is what the payout rails of a target currency accept as a transfer reference
+type ReferenceRule struct {
+	MaxLength int
+	Charset   *regexp.Regexp
+}
+
+// characters every corridor accepts, the SWIFT character set
+var swiftCharset = regexp.MustCompile(`^[A-Za-z0-9 /?:().,'+-]*$`)
+
+// reference rules of the target currencies with stricter rails than defaultReferenceRule, e.g. Faster Payments for GBP
+var referenceRules = map[string]ReferenceRule{
+	"GBP": {MaxLength: 18, Charset: swiftCharset},
+	"EUR": {MaxLength: 140, Charset: swiftCharset},
+	"USD": {MaxLength: 10, Charset: regexp.MustCompile(`^[A-Za-z0-9 ]*$`)},
+	"INR": {MaxLength: 30, Charset: regexp.MustCompile(`^[A-Za-z0-9 ]*$`)},
+}
+
+var defaultReferenceRule = ReferenceRule{MaxLength: 35, Charset: swiftCharset}
+
+// ReferenceData is what REFERENCE_TEMPLATE and the reference overrides of CONFIG_FILE are executed with
+type ReferenceData struct {
+	Reference      string
+	OldTransferID  uint64
+	Month          string
+	Year           int
+	Date           string
+	SourceCurrency string
+	TargetCurrency string
+	Rate           float64
+	SourceAmount   Decimal
+	TargetAmount   Decimal
+}
+
+func parseReferenceTemplate(text string) (*template.Template, error) {
+	return template.New("reference").Option("missingkey=error").Parse(text)
+}
+
+// The details of the transfer re-booking oldTransfer with the quote, its reference rendered from the template of its
+// settings, if any, and checked against the rules of the target currency before anything is submitted
+func rebookDetails(oldTransfer Transfer, quote QuoteDetail, now time.Time) (TransferDetails, error) {
+	details := oldTransfer.Details
+	settings, err := getSettings(oldTransfer)
+	if err != nil || settings.Reference == "" {
+		return details, err
+	}
+	reference, err := renderReference(settings.Reference, oldTransfer, quote, now)
+	if err != nil {
+		return details, err
+	}
+	if err := validateReference(reference, oldTransfer.TargetCurrency); err != nil {
+		return details, err
+	}
+	details.Reference = reference
+	return details, nil
+}
+
+func renderReference(text string, oldTransfer Transfer, quote QuoteDetail, now time.Time) (string, error) {
+	tmpl, err := parseReferenceTemplate(text)
+	if err != nil {
+		return "", fmt.Errorf("error parsing reference template: %v", err)
+	}
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, ReferenceData{
+		Reference:      oldTransfer.Details.Reference,
+		OldTransferID:  oldTransfer.Id,
+		Month:          now.Month().String(),
+		Year:           now.Year(),
+		Date:           now.Format(backtestDateLayout),
+		SourceCurrency: oldTransfer.SourceCurrency,
+		TargetCurrency: oldTransfer.TargetCurrency,
+		Rate:           quote.Rate,
+		SourceAmount:   quote.SourceAmount,
+		TargetAmount:   quote.TargetAmount,
+	})
+	if err != nil {
+		return "", fmt.Errorf("error rendering reference template: %v", err)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+func validateReference(reference string, targetCurrency string) error {
+	rule, ok := referenceRules[targetCurrency]
+	if !ok {
+		rule = defaultReferenceRule
+	}
+	if length := len([]rune(reference)); length > rule.MaxLength {
+		return fmt.Errorf("reference %q is %v characters long, {%v} transfers allow %v", reference, length, targetCurrency,
+			rule.MaxLength)
+	}
+	if !rule.Charset.MatchString(reference) {
+		return fmt.Errorf("reference %q has characters {%v} transfers don't allow", reference, targetCurrency)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestRenderReference(t *testing.T) {
+	now := time.Date(2023, time.March, 1, 9, 0, 0, 0, time.UTC)
+	oldTransfer := Transfer{Id: 7, SourceCurrency: "GBP", TargetCurrency: "INR", Details: TransferDetails{Reference: "rent"}}
+
+	reference, err := renderReference("{{.Reference}} {{.Month}} from {{.OldTransferID}}", oldTransfer, QuoteDetail{}, now)
+	assert.NoError(t, err)
+	assert.Equal(t, "rent March from 7", reference)
+	assert.NoError(t, validateReference(reference, "INR"))
+
+	_, err = renderReference("{{.Unknown}}", oldTransfer, QuoteDetail{}, now)
+	assert.Error(t, err)
+
+	assert.Error(t, validateReference("rebooked from 123456789", "GBP"), "Faster Payments references are 18 characters")
+	assert.Error(t, validateReference("rent #3", "INR"), "# isn't allowed")
+	assert.NoError(t, validateReference("rent 03/2023", "CAD"))
+}
+
+func TestRebookDetails(t *testing.T) {
+	defer func(v string) { referenceTemplateVar = v }(referenceTemplateVar)
+	now := time.Date(2023, time.March, 1, 9, 0, 0, 0, time.UTC)
+	oldTransfer := Transfer{Id: 7, SourceCurrency: "GBP", TargetCurrency: "INR",
+		Details: TransferDetails{Reference: "rent", TransferPurpose: "verification.transfers.purpose.pay.bills"}}
+
+	referenceTemplateVar = ""
+	details, err := rebookDetails(oldTransfer, QuoteDetail{}, now)
+	assert.NoError(t, err)
+	assert.Equal(t, oldTransfer.Details, details, "copied verbatim without a template")
+
+	referenceTemplateVar = "rent {{.Month}}"
+	details, err = rebookDetails(oldTransfer, QuoteDetail{}, now)
+	assert.NoError(t, err)
+	assert.Equal(t, "rent March", details.Reference)
+	assert.Equal(t, oldTransfer.Details.TransferPurpose, details.TransferPurpose)
+
+	referenceTemplateVar = "rent for the month of {{.Month}} {{.Year}}"
+	_, err = rebookDetails(oldTransfer, QuoteDetail{}, now)
+	assert.Error(t, err, "too long for INR")
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/go-co-op/gocron"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"time"
+)
+
+// how often CONFIG_FILE is checked for changes
+const configWatchInterval = 10 * time.Second
+
+// scheduled monitorTransfers job, rescheduled along with checkJob
+var monitorJob *gocron.Job
+
+// Reload CONFIG_FILE on SIGHUP and whenever the file changes
+func watchConfig(scheduler *gocron.Scheduler) {
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	ticker := time.NewTicker(configWatchInterval)
+	defer ticker.Stop()
+
+	lastModified := configModTime()
+	for {
+		select {
+		case <-hangups:
+			log.Println("|| SIGHUP RECEIVED, RELOADING CONFIG ||")
+		case <-ticker.C:
+			modified := configModTime()
+			if modified.Equal(lastModified) {
+				continue
+			}
+			lastModified = modified
+			log.Println("|| CONFIG FILE CHANGED, RELOADING CONFIG ||")
+		}
+		if err := reloadConfig(scheduler); err != nil {
+			log.Printf("|| CONFIG RELOAD FAILED, KEEPING THE CURRENT CONFIG || %v ||", err)
+		}
+	}
+}
+
+func configModTime() time.Time {
+	if configFileVar == "" {
+		return time.Time{}
+	}
+	info, err := os.Stat(configFileVar)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// Validate and apply CONFIG_FILE, logging what changed and rescheduling the checks when the shortest interval changed.
+// An invalid config file leaves the current config in place
+func reloadConfig(scheduler *gocron.Scheduler) error {
+	previous := getConfig()
+	previousInterval, err := getSchedulerInterval()
+	if err != nil {
+		return err
+	}
+	if err = loadConfig(); err != nil {
+		return err
+	}
+
+	changes := diffConfig(previous, getConfig())
+	if len(changes) == 0 {
+		log.Println("|| CONFIG RELOADED, NOTHING CHANGED ||")
+		return nil
+	}
+	for _, change := range changes {
+		log.Printf("|| CONFIG RELOADED || %v ||", change)
+	}
+
+	interval, err := getSchedulerInterval()
+	if err != nil || interval == previousInterval || scheduler == nil {
+		return err
+	}
+	log.Printf("|| CHECKS RESCHEDULED || Interval: %v minutes, was %v ||", interval, previousInterval)
+	return scheduleChecks(scheduler, interval)
+}
+
+// (Re)schedule the checkAndProcess job, and the monitorTransfers job if enabled, every interval minutes
+func scheduleChecks(scheduler *gocron.Scheduler, interval uint64) (err error) {
+	if checkJob != nil {
+		scheduler.RemoveByReference(checkJob)
+	}
+	checkJob, err = scheduler.Every(int(interval)).Minutes().Do(checkAndProcess)
+	if err != nil {
+		return fmt.Errorf("couldn't schedule the checkAndProcess job: %v", err)
+	}
+	if !isMonitoringTransfers() {
+		return nil
+	}
+	if monitorJob != nil {
+		scheduler.RemoveByReference(monitorJob)
+	}
+	monitorJob, err = scheduler.Every(int(interval)).Minutes().Do(defaultAccountJob(monitorTransfers))
+	if err != nil {
+		return fmt.Errorf("couldn't schedule the monitorTransfers job: %v", err)
+	}
+	return nil
+}
+
+// Describe every override and pushover priority that was added, removed or changed, sorted
+func diffConfig(previous Config, current Config) (changes []string) {
+	diffOverrides := func(section string, previous map[string]Overrides, current map[string]Overrides) {
+		for key, overrides := range current {
+			old, ok := previous[key]
+			switch {
+			case !ok:
+				changes = append(changes, fmt.Sprintf("%v.%v added: %v", section, key, toJSON(overrides)))
+			case toJSON(old) != toJSON(overrides):
+				changes = append(changes, fmt.Sprintf("%v.%v: %v --> %v", section, key, toJSON(old), toJSON(overrides)))
+			}
+		}
+		for key, old := range previous {
+			if _, ok := current[key]; !ok {
+				changes = append(changes, fmt.Sprintf("%v.%v removed: %v", section, key, toJSON(old)))
+			}
+		}
+	}
+	diffOverrides("pairs", previous.Pairs, current.Pairs)
+	diffOverrides("purposes", previous.Purposes, current.Purposes)
+	diffOverrides("transfers", previous.Transfers, current.Transfers)
+	if toJSON(previous.Pushover) != toJSON(current.Pushover) {
+		changes = append(changes, fmt.Sprintf("pushover: %v --> %v", toJSON(previous.Pushover), toJSON(current.Pushover)))
+	}
+	if toJSON(previous.Notifications) != toJSON(current.Notifications) {
+		changes = append(changes, fmt.Sprintf("notifications: %v --> %v", toJSON(previous.Notifications), toJSON(current.Notifications)))
+	}
+	if toJSON(previous.SourceRankings) != toJSON(current.SourceRankings) {
+		changes = append(changes, fmt.Sprintf("sourceRankings: %v --> %v", toJSON(previous.SourceRankings), toJSON(current.SourceRankings)))
+	}
+	sort.Strings(changes)
+	return changes
+}
+
+func toJSON(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"github.com/go-co-op/gocron"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDiffConfig(t *testing.T) {
+	margin, otherMargin := decimalOf(0.2), decimalOf(0.3)
+	previous := Config{
+		Pairs:     map[string]Overrides{"GBP-INR": {Margin: &margin}, "JPY-INR": {Strategy: strategyMargin}},
+		Transfers: map[string]Overrides{"42": {Margin: &margin}},
+	}
+	current := Config{
+		Pairs:    map[string]Overrides{"GBP-INR": {Margin: &otherMargin}, "EUR-USD": {Direction: directionLower}},
+		Pushover: PushoverConfig{Priorities: map[EventKind]int{EventRebooked: 1}},
+	}
+
+	assert.Equal(t, []string{
+		`pairs.EUR-USD added: {"direction":"lower"}`,
+		`pairs.GBP-INR: {"margin":0.2} --> {"margin":0.3}`,
+		`pairs.JPY-INR removed: {"strategy":"margin"}`,
+		`pushover: {"priorities":null} --> {"priorities":{"rebooked":1}}`,
+		`transfers.42 removed: {"margin":0.2}`,
+	}, diffConfig(previous, current))
+	assert.Empty(t, diffConfig(current, current))
+}
+
+func TestReloadConfig(t *testing.T) {
+	defer func(file, interval string, c Config, job *gocron.Job) {
+		configFileVar, intervalVar, config.current, checkJob = file, interval, c, job
+	}(configFileVar, intervalVar, getConfig(), checkJob)
+	dir, _ := ioutil.TempDir("", "transferwisely")
+	defer os.RemoveAll(dir)
+	configFileVar, intervalVar = filepath.Join(dir, "config.json"), "5"
+	_ = ioutil.WriteFile(configFileVar, []byte(`{"pairs": {"GBP-INR": {"margin": 0.2}}}`), 0600)
+	assert.NoError(t, loadConfig())
+
+	scheduler := gocron.NewScheduler(time.UTC)
+	checkJob = nil
+	assert.NoError(t, scheduleChecks(scheduler, 5))
+	assert.Equal(t, 1, scheduler.Len())
+
+	t.Run("invalid config is not applied", func(t *testing.T) {
+		_ = ioutil.WriteFile(configFileVar, []byte(`{"pairs": {"GBP-INR": {"strategy": "yolo"}}}`), 0600)
+		assert.Error(t, reloadConfig(scheduler))
+		assert.Equal(t, decimalOf(0.2), *getConfig().Pairs["GBP-INR"].Margin)
+	})
+
+	t.Run("shorter interval reschedules the checks", func(t *testing.T) {
+		previousJob := checkJob
+		_ = ioutil.WriteFile(configFileVar, []byte(`{"pairs": {"GBP-INR": {"margin": 0.2, "interval": 9}}}`), 0600)
+		assert.NoError(t, reloadConfig(scheduler))
+		assert.Same(t, previousJob, checkJob, "the shortest interval is still the global one")
+
+		_ = ioutil.WriteFile(configFileVar, []byte(`{"pairs": {"GBP-INR": {"margin": 0.5, "interval": 2}}}`), 0600)
+		assert.NoError(t, reloadConfig(scheduler))
+		assert.Equal(t, decimalOf(0.5), *getConfig().Pairs["GBP-INR"].Margin)
+		assert.Equal(t, 1, scheduler.Len())
+		assert.NotSame(t, previousJob, checkJob)
+	})
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Whether the booked transfer should be re-booked because its rate lock expires within RENEW_BEFORE
+// and the live rate isn't worse than the booked rate by more than RENEW_TOLERANCE
+func shouldRenew(transfer Transfer, liveRate float64, now time.Time) (bool, error) {
+	autoRenew, renewBefore, tolerance, err := getRenewalConfig()
+	if err != nil || !autoRenew {
+		return false, err
+	}
+	if transfer.RateExpirationTime == "" {
+		return false, nil
+	}
+
+	expiryTime, err := time.Parse(time.RFC3339, transfer.RateExpirationTime)
+	if err != nil {
+		return false, fmt.Errorf("shouldRenew: %v", err)
+	}
+	if expiryTime.Sub(now) > renewBefore {
+		return false, nil
+	}
+
+	settings, err := getSettings(transfer)
+	if err != nil {
+		return false, fmt.Errorf("shouldRenew: %v", err)
+	}
+	return liveRate > 0 && rateImprovement(transfer.Rate, liveRate, settings).Cmp(decimalOf(-tolerance)) >= 0, nil
+}
+
+func getRenewalConfig() (autoRenew bool, renewBefore time.Duration, tolerance float64, err error) {
+	autoRenew, err = strconv.ParseBool(autoRenewVar)
+	if err != nil {
+		return false, 0, 0, fmt.Errorf("invalid value for AUTO_RENEW: %v", err)
+	}
+
+	renewBeforeMinutes, err := strconv.ParseUint(renewBeforeVar, 10, 64)
+	if err != nil {
+		return false, 0, 0, fmt.Errorf("invalid value for RENEW_BEFORE: %v", err)
+	}
+
+	tolerance, err = strconv.ParseFloat(renewToleranceVar, 64)
+	if err != nil {
+		return false, 0, 0, fmt.Errorf("invalid value for RENEW_TOLERANCE: %v", err)
+	}
+
+	return autoRenew, time.Duration(renewBeforeMinutes) * time.Minute, tolerance, nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestShouldRenew(t *testing.T) {
+	defer func(autoRenew, before, tolerance string) {
+		autoRenewVar, renewBeforeVar, renewToleranceVar = autoRenew, before, tolerance
+	}(autoRenewVar, renewBeforeVar, renewToleranceVar)
+
+	now := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+	transfer := Transfer{Rate: 1.1, RateExpirationTime: now.Add(time.Hour).Format(time.RFC3339)}
+	autoRenewVar, renewBeforeVar, renewToleranceVar = "true", "120", "0.01"
+
+	t.Run("expiring within tolerance", func(t *testing.T) {
+		renew, err := shouldRenew(transfer, 1.095, now)
+		assert.NoError(t, err)
+		assert.True(t, renew)
+	})
+
+	t.Run("live rate worse than tolerance", func(t *testing.T) {
+		renew, err := shouldRenew(transfer, 1.05, now)
+		assert.NoError(t, err)
+		assert.False(t, renew)
+	})
+
+	t.Run("not expiring yet", func(t *testing.T) {
+		renew, err := shouldRenew(transfer, 1.1, now.Add(-2*time.Hour))
+		assert.NoError(t, err)
+		assert.False(t, renew)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		autoRenewVar = "false"
+		renew, err := shouldRenew(transfer, 1.1, now)
+		assert.NoError(t, err)
+		assert.False(t, renew)
+	})
+
+	t.Run("invalid config", func(t *testing.T) {
+		autoRenewVar, renewToleranceVar = "true", "a bit"
+		_, err := shouldRenew(transfer, 1.1, now)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/google/uuid"
+	"io"
+	"log"
+	"os"
+	"strconv"
+)
+
+// The recipient account to re-book a transfer to, the one routed to in CONFIG_FILE by pair, purpose or transfer ID,
+// otherwise the transfer's own
+func routeTargetAccount(transfer Transfer) uint64 {
+	settings, err := getSettings(transfer)
+	if err != nil {
+		log.Printf("routeTargetAccount: %v", err)
+		return transfer.TargetAccount
+	}
+	if settings.TargetAccount == 0 {
+		return transfer.TargetAccount
+	}
+	if settings.TargetAccount != transfer.TargetAccount {
+		log.Printf("|| Routing transfer %v to recipient account %v instead of %v ||", transfer.Id, settings.TargetAccount, transfer.TargetAccount)
+	}
+	return settings.TargetAccount
+}
+
+// Book a copy of a booked transfer toward another recipient at the current rate, leaving the original booked
+func cloneTransfer(transferId uint64, targetAccount uint64) (Transfer, error) {
+	transfers, err := listAllTransfers(transferStatusBooked)
+	if err != nil {
+		return Transfer{}, fmt.Errorf("cloneTransfer: %v", err)
+	}
+	var original *Transfer
+	for i := range transfers {
+		if transfers[i].Id == transferId {
+			original = &transfers[i]
+			break
+		}
+	}
+	if original == nil {
+		return Transfer{}, fmt.Errorf("no booked transfer %v to clone", transferId)
+	}
+
+	settings, err := getSettings(*original)
+	if err != nil {
+		return Transfer{}, err
+	}
+	quote, err := createRebookQuote(*original, settings)
+	if err != nil {
+		return Transfer{}, fmt.Errorf("cloneTransfer: %v", err)
+	}
+
+	return postTransfer(CreateTransferRequest{
+		TargetAccount:         targetAccount,
+		QuoteUuid:             quote.Id,
+		CustomerTransactionId: uuid.New().String(),
+		Details:               original.Details,
+		Originator:            settings.Originator,
+	}, quote)
+}
+
+func runCloneCommand(args []string) error {
+	usage := fmt.Errorf("usage: transfers clone <transferId> --target-account <id> [--output json]")
+	if len(args) == 0 {
+		return usage
+	}
+	transferId, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid transfer ID %v: %v", args[0], err)
+	}
+	flags := flag.NewFlagSet("transfers clone", flag.ContinueOnError)
+	targetAccount := flags.Uint64("target-account", 0, "recipient account to book the copy to")
+	output := outputFlag(flags)
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if *targetAccount == 0 {
+		return usage
+	}
+
+	newTransfer, err := cloneTransfer(transferId, *targetAccount)
+	if err != nil {
+		return err
+	}
+	return printOutput(os.Stdout, *output, newTransfer, func(w io.Writer) {
+		fmt.Fprintf(w, "Transfer %v cloned as %v to recipient account %v: {%v} --> {%v} %v at %v\n", transferId, newTransfer.Id,
+			*targetAccount, newTransfer.SourceCurrency, newTransfer.TargetCurrency, formatAmount(newTransfer.SourceAmount,
+				newTransfer.SourceCurrency), newTransfer.Rate)
+	})
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"transferwisely/mocks"
+)
+
+func TestRouteTargetAccount(t *testing.T) {
+	defer func(file string, c Config) { configFileVar, config.current = file, c }(configFileVar, getConfig())
+
+	dir, _ := ioutil.TempDir("", "transferwisely")
+	defer os.RemoveAll(dir)
+	configFileVar = filepath.Join(dir, "config.json")
+	_ = ioutil.WriteFile(configFileVar, []byte(`{
+		"pairs": {"GBP-INR": {"targetAccount": 100}},
+		"purposes": {"verification.transfers.purpose.pay.bills": {"targetAccount": 200}},
+		"transfers": {"42": {"targetAccount": 300}}
+	}`), 0600)
+	assert.NoError(t, loadConfig())
+
+	bills := TransferDetails{TransferPurpose: "verification.transfers.purpose.pay.bills"}
+	tests := []struct {
+		name     string
+		transfer Transfer
+		expected uint64
+	}{
+		{"inherited without a route", Transfer{Id: 1, TargetAccount: 9, SourceCurrency: "EUR", TargetCurrency: "USD"}, 9},
+		{"routed by pair", Transfer{Id: 1, TargetAccount: 9, SourceCurrency: "GBP", TargetCurrency: "INR"}, 100},
+		{"purpose over pair", Transfer{Id: 1, TargetAccount: 9, SourceCurrency: "GBP", TargetCurrency: "INR", Details: bills}, 200},
+		{"transfer over purpose", Transfer{Id: 42, TargetAccount: 9, SourceCurrency: "GBP", TargetCurrency: "INR", Details: bills}, 300},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, routeTargetAccount(test.transfer))
+		})
+	}
+
+	t.Run("target account 0 is invalid", func(t *testing.T) {
+		_ = ioutil.WriteFile(configFileVar, []byte(`{"purposes": {"gift": {"targetAccount": 0}}}`), 0600)
+		assert.Error(t, loadConfig())
+	})
+}
+
+func TestCloneTransfer(t *testing.T) {
+	var created CreateTransferRequest
+	cancelled := false
+	mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+		body := `{}`
+		switch {
+		case req.Method == http.MethodGet && strings.Contains(req.URL.String(), transfersAPIPath):
+			body = `[{"id": 7, "profile": 1, "targetAccount": 9, "sourceAmount": 1000, "sourceCurrency": "GBP",
+				"targetCurrency": "INR", "details": {"reference": "rent"}}]`
+		case req.Method == http.MethodPost && strings.HasSuffix(req.URL.String(), quotesAPIPath):
+			body = `{"id": "quote-1", "rate": 100.5, "sourceAmount": 1000, "profile": 1}`
+		case req.Method == http.MethodPost && strings.HasSuffix(req.URL.String(), transfersAPIPath):
+			data, _ := ioutil.ReadAll(req.Body)
+			_ = json.Unmarshal(data, &created)
+			body = `{"id": 8, "rate": 100.5, "targetAccount": 55, "sourceCurrency": "GBP", "targetCurrency": "INR"}`
+		case req.Method == http.MethodPut:
+			cancelled = true
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(body))}, nil
+	}
+
+	newTransfer, err := cloneTransfer(7, 55)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(8), newTransfer.Id)
+	assert.Equal(t, decimalOf(1000.0), newTransfer.SourceAmount)
+	assert.Equal(t, uint64(55), created.TargetAccount)
+	assert.Equal(t, "quote-1", created.QuoteUuid)
+	assert.Equal(t, "rent", created.Details.Reference)
+	assert.NotEmpty(t, created.CustomerTransactionId)
+	assert.False(t, cancelled, "the original transfer stays booked")
+
+	t.Run("unknown transfer", func(t *testing.T) {
+		_, err := cloneTransfer(99, 55)
+		assert.Error(t, err)
+	})
+}
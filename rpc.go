@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// path prefix of the Transferwisely service of proto/transferwisely/v1/transferwisely.proto, served with the Twirp
+// protocol and its JSON encoding
+const rpcPathPrefix = "/twirp/transferwisely.v1.Transferwisely/"
+
+// Twirp error codes
+const (
+	rpcBadRoute           = "bad_route"
+	rpcMalformed          = "malformed"
+	rpcInvalidArgument    = "invalid_argument"
+	rpcUnauthenticated    = "unauthenticated"
+	rpcNotFound           = "not_found"
+	rpcFailedPrecondition = "failed_precondition"
+	rpcUnavailable        = "unavailable"
+	rpcInternal           = "internal"
+)
+
+// HTTP status of each Twirp error code
+var rpcErrorStatuses = map[string]int{
+	rpcBadRoute:           http.StatusNotFound,
+	rpcMalformed:          http.StatusBadRequest,
+	rpcInvalidArgument:    http.StatusBadRequest,
+	rpcUnauthenticated:    http.StatusUnauthorized,
+	rpcNotFound:           http.StatusNotFound,
+	rpcFailedPrecondition: http.StatusPreconditionFailed,
+	rpcUnavailable:        http.StatusServiceUnavailable,
+	rpcInternal:           http.StatusInternalServerError,
+}
+
+// RPCError is the body of failed calls, as Twirp clients expect it
+type RPCError struct {
+	Code string `json:"code"`
+	Msg  string `json:"msg"`
+}
+
+func (e *RPCError) Error() string {
+	return e.Code + ": " + e.Msg
+}
+
+// request messages, the JSON encoding of proto3 accepting both the lowerCamelCase and the original field names
+type rpcListTransfersRequest struct {
+	Statuses []string `json:"statuses"`
+}
+
+type rpcGetRateHistoryRequest struct {
+	Source string    `json:"source"`
+	Target string    `json:"target"`
+	From   time.Time `json:"from"`
+	To     time.Time `json:"to"`
+	Group  string    `json:"group"`
+}
+
+type rpcApproveRequest struct {
+	ProposalId      string `json:"proposalId"`
+	ProposalIdProto string `json:"proposal_id"`
+}
+
+// rpcListTransfersResponse and rpcGetRateHistoryResponse wrap the repeated fields, a proto3 message being an object
+type rpcListTransfersResponse struct {
+	Transfers []Transfer `json:"transfers"`
+}
+
+type rpcGetRateHistoryResponse struct {
+	Rates []LiveRate `json:"rates"`
+}
+
+// methods of the Transferwisely service, each decoding its request from the body
+var rpcMethods = map[string]func(body []byte) (interface{}, error){
+	"GetStatus": defaultAccountMethod(func([]byte) (interface{}, error) {
+		return getControlStatus(), nil
+	}),
+	"ListTransfers": defaultAccountMethod(func(body []byte) (interface{}, error) {
+		var req rpcListTransfersRequest
+		if err := decodeRPCRequest(body, &req); err != nil {
+			return nil, err
+		}
+		statuses := req.Statuses
+		if len(statuses) == 0 {
+			var err error
+			if statuses, err = getTrackedStatuses(); err != nil {
+				return nil, &RPCError{Code: rpcInternal, Msg: err.Error()}
+			}
+		}
+		transfers, err := listTransfers(strings.Join(statuses, ","), controlTransfersLimit)
+		if err != nil {
+			return nil, &RPCError{Code: rpcUnavailable, Msg: err.Error()}
+		}
+		if transfers == nil {
+			transfers = []Transfer{}
+		}
+		return rpcListTransfersResponse{Transfers: transfers}, nil
+	}),
+	"GetRateHistory": defaultAccountMethod(func(body []byte) (interface{}, error) {
+		var req rpcGetRateHistoryRequest
+		if err := decodeRPCRequest(body, &req); err != nil {
+			return nil, err
+		}
+		if req.Source == "" || req.Target == "" || req.From.IsZero() {
+			return nil, &RPCError{Code: rpcInvalidArgument, Msg: "source, target and from are required"}
+		}
+		if req.To.IsZero() {
+			req.To = time.Now().UTC()
+		}
+		if req.Group == "" {
+			req.Group = rateHistoryGroup
+		}
+		rates, err := getRateHistory(strings.ToUpper(req.Source), strings.ToUpper(req.Target), req.From, req.To, req.Group)
+		if err != nil {
+			return nil, &RPCError{Code: rpcUnavailable, Msg: err.Error()}
+		}
+		if rates == nil {
+			rates = []LiveRate{}
+		}
+		return rpcGetRateHistoryResponse{Rates: rates}, nil
+	}),
+	"Check": defaultAccountMethod(func([]byte) (interface{}, error) {
+		if !isLeader() {
+			return nil, &RPCError{Code: rpcFailedPrecondition, Msg: "not the leader, only the leader checks"}
+		}
+		log.Println("|| CHECK REQUESTED THROUGH THE RPC API ||")
+		return checkNow(), nil
+	}),
+	"Pause": defaultAccountMethod(func([]byte) (interface{}, error) {
+		log.Println("|| PAUSED THROUGH THE RPC API ||")
+		if err := setPaused(true, pausedByRPCAPI); err != nil {
+			return nil, &RPCError{Code: rpcInternal, Msg: err.Error()}
+		}
+		return getControlStatus(), nil
+	}),
+	"Resume": defaultAccountMethod(func([]byte) (interface{}, error) {
+		log.Println("|| RESUMED THROUGH THE RPC API ||")
+		if err := setPaused(false, pausedByRPCAPI); err != nil {
+			return nil, &RPCError{Code: rpcInternal, Msg: err.Error()}
+		}
+		return getControlStatus(), nil
+	}),
+	"Approve": func(body []byte) (interface{}, error) {
+		var req rpcApproveRequest
+		if err := decodeRPCRequest(body, &req); err != nil {
+			return nil, err
+		}
+		id := req.ProposalId
+		if id == "" {
+			id = req.ProposalIdProto
+		}
+		newTransfer, err := approveProposal(id, time.Now().UTC())
+		switch {
+		case errors.Is(err, errProposalNotFound):
+			return nil, &RPCError{Code: rpcNotFound, Msg: err.Error()}
+		case errors.Is(err, errProposalNotPending):
+			return nil, &RPCError{Code: rpcFailedPrecondition, Msg: err.Error()}
+		case err != nil:
+			return nil, &RPCError{Code: rpcInternal, Msg: err.Error()}
+		}
+		return newTransfer, nil
+	},
+}
+
+// The method run through withDefaultAccount
+func defaultAccountMethod(method func(body []byte) (interface{}, error)) func(body []byte) (interface{}, error) {
+	return func(body []byte) (res interface{}, err error) {
+		withDefaultAccount(func() {
+			res, err = method(body)
+		})
+		return res, err
+	}
+}
+
+func decodeRPCRequest(body []byte, req interface{}) error {
+	if len(body) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(body, req); err != nil {
+		return &RPCError{Code: rpcMalformed, Msg: "invalid JSON request: " + err.Error()}
+	}
+	return nil
+}
+
+func writeRPCError(w http.ResponseWriter, err error) {
+	var rpcErr *RPCError
+	if !errors.As(err, &rpcErr) {
+		rpcErr = &RPCError{Code: rpcInternal, Msg: err.Error()}
+	}
+	writeJSON(w, rpcErrorStatuses[rpcErr.Code], rpcErr)
+}
+
+// Register the Transferwisely service next to the control API, enabled and authenticated by CONTROL_API_TOKEN too
+func registerRPCAPI(mux *http.ServeMux) {
+	mux.HandleFunc(rpcPathPrefix, func(w http.ResponseWriter, r *http.Request) {
+		if controlAPITokenVar == "" {
+			http.NotFound(w, r)
+			return
+		}
+		if !hasControlToken(r) {
+			writeRPCError(w, &RPCError{Code: rpcUnauthenticated, Msg: "invalid or missing bearer token"})
+			return
+		}
+		method, ok := rpcMethods[strings.TrimPrefix(r.URL.Path, rpcPathPrefix)]
+		if !ok || r.Method != http.MethodPost {
+			writeRPCError(w, &RPCError{Code: rpcBadRoute, Msg: "no such method: " + r.Method + " " + r.URL.Path})
+			return
+		}
+		if !strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+			writeRPCError(w, &RPCError{Code: rpcBadRoute, Msg: "only the JSON encoding is served, Content-Type must be application/json"})
+			return
+		}
+
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			writeRPCError(w, &RPCError{Code: rpcMalformed, Msg: "error reading request: " + err.Error()})
+			return
+		}
+		res, err := method(body)
+		if err != nil {
+			writeRPCError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, res)
+	})
+}
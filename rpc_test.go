@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"transferwisely/mocks"
+)
+
+func TestRPCAPI(t *testing.T) {
+	defer func(v string, file string) { controlAPITokenVar, stateFileVar = v, file }(controlAPITokenVar, stateFileVar)
+	dir, _ := ioutil.TempDir("", "transferwisely")
+	defer os.RemoveAll(dir)
+	stateFileVar = filepath.Join(dir, "state.json")
+	mux := http.NewServeMux()
+	registerRPCAPI(mux)
+
+	call := func(method string, body string, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, rpcPathPrefix+method, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+	errorCode := func(w *httptest.ResponseRecorder) string {
+		var rpcErr RPCError
+		_ = json.Unmarshal(w.Body.Bytes(), &rpcErr)
+		return rpcErr.Code
+	}
+
+	controlAPITokenVar = ""
+	assert.Equal(t, http.StatusNotFound, call("GetStatus", `{}`, "").Code, "disabled without a token")
+
+	controlAPITokenVar = "secret"
+	w := call("GetStatus", `{}`, "wrong")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, rpcUnauthenticated, errorCode(w))
+	assert.Equal(t, rpcBadRoute, errorCode(call("Unknown", `{}`, "secret")))
+	assert.Equal(t, rpcMalformed, errorCode(call("Approve", `{`, "secret")))
+
+	t.Run("pause", func(t *testing.T) {
+		w := call("Pause", `{}`, "secret")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.True(t, isPaused())
+		var status ControlStatus
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+		assert.True(t, status.Paused)
+
+		call("Resume", ``, "secret")
+		assert.False(t, isPaused())
+	})
+
+	t.Run("list transfers", func(t *testing.T) {
+		var listURL string
+		mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+			listURL = req.URL.String()
+			return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(`[{"id": 1}]`))}, nil
+		}
+		w := call("ListTransfers", `{"statuses": ["processing"]}`, "secret")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, listURL, "status=processing")
+		var res rpcListTransfersResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		assert.Len(t, res.Transfers, 1)
+	})
+
+	t.Run("rate history", func(t *testing.T) {
+		assert.Equal(t, rpcInvalidArgument, errorCode(call("GetRateHistory", `{"source": "GBP"}`, "secret")))
+
+		var historyURL string
+		mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+			historyURL = req.URL.String()
+			return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(`[{"rate": 100.5}]`))}, nil
+		}
+		w := call("GetRateHistory", `{"source": "gbp", "target": "inr", "from": "2020-05-01T00:00:00Z"}`, "secret")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, historyURL, "source=GBP")
+		assert.Contains(t, historyURL, "group=hour")
+		assert.JSONEq(t, `{"rates": [{"rate": 100.5, "source": "", "target": "", "time": ""}]}`, w.Body.String())
+	})
+
+	t.Run("approve unknown proposal", func(t *testing.T) {
+		defer func(file string) { stateFileVar = file }(stateFileVar)
+		dir, _ := ioutil.TempDir("", "transferwisely")
+		defer os.RemoveAll(dir)
+		stateFileVar = filepath.Join(dir, "state.json")
+
+		w := call("Approve", `{"proposal_id": "unknown"}`, "secret")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, rpcNotFound, errorCode(w))
+	})
+}
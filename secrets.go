@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// secret manager references an env variable can hold instead of the secret itself
+const (
+	secretRefVault                = "vault://"
+	secretRefAWSSecretsManager    = "awssm://"
+	awsSecretsManagerTarget       = "secretsmanager.GetSecretValue"
+	awsSecretsManagerHostTemplate = "secretsmanager.%v.amazonaws.com"
+)
+
+// env variables holding secrets, resolved in order so the secret manager credentials come first
+var secretVars = []struct {
+	key   string
+	value *string
+}{
+	{"VAULT_TOKEN", &vaultTokenVar},
+	{"AWS_SECRET_ACCESS_KEY", &awsSecretAccessKeyVar},
+	{"CREDENTIALS_PASSPHRASE", &credentialsPassphraseVar},
+	{"API_TOKEN", &apiTokenVar},
+	{"CONTROL_API_TOKEN", &controlAPITokenVar},
+	{"MAIL_PASS", &mailPassVar},
+	{"MAIL_API_KEY", &mailAPIKeyVar},
+	{"TELEGRAM_BOT_TOKEN", &telegramBotTokenVar},
+	{"WEBHOOK_SECRET", &webhookSecretVar},
+	{"NTFY_TOKEN", &ntfyTokenVar},
+	{"GOTIFY_TOKEN", &gotifyTokenVar},
+	{"PUSHOVER_TOKEN", &pushoverTokenVar},
+	{"MATRIX_ACCESS_TOKEN", &matrixAccessTokenVar},
+	{"SENTRY_DSN", &sentryDSNVar},
+	{"ROLLBAR_ACCESS_TOKEN", &rollbarAccessTokenVar},
+	{"GRAFANA_API_KEY", &grafanaAPIKeyVar},
+	{"EXCHANGERATE_HOST_KEY", &exchangeRateHostKeyVar},
+	{"MQTT_PASSWORD", &mqttPasswordVar},
+}
+
+// Replace secrets given as KEY_FILE, e.g. Docker or Kubernetes secret mounts, or as secret manager references
+// like vault://secret/data/transferwisely#api_token or awssm://transferwisely#api_token with their value
+func resolveSecrets() error {
+	for _, secret := range secretVars {
+		value, err := resolveSecret(secret.key, *secret.value)
+		if err != nil {
+			return err
+		}
+		*secret.value = value
+	}
+	if err := readStoredCredentials(); err != nil {
+		return err
+	}
+
+	// notifiers and error reporters were configured before their secrets got resolved
+	Notifiers = getConfiguredNotifiers()
+	ErrorReporters = getConfiguredErrorReporters()
+	return nil
+}
+
+func resolveSecret(key string, value string) (string, error) {
+	if file := os.Getenv(key + "_FILE"); file != "" {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("error reading %v_FILE: %v", key, err)
+		}
+		value = strings.TrimRight(string(data), "\r\n")
+	}
+
+	var err error
+	switch {
+	case strings.HasPrefix(value, secretRefVault):
+		value, err = getVaultSecret(strings.TrimPrefix(value, secretRefVault))
+	case strings.HasPrefix(value, secretRefAWSSecretsManager):
+		value, err = getAWSSecret(strings.TrimPrefix(value, secretRefAWSSecretsManager))
+	}
+	if err != nil {
+		return "", fmt.Errorf("error resolving %v: %v", key, err)
+	}
+	return value, nil
+}
+
+// Split a secret reference into the secret path and the key of the wanted value inside of it, if any
+func splitSecretRef(ref string) (path string, key string) {
+	i := strings.LastIndex(ref, "#")
+	if i < 0 {
+		return ref, ""
+	}
+	return ref[:i], ref[i+1:]
+}
+
+// Read a secret from a HashiCorp Vault KV (v1 or v2) secrets engine
+func getVaultSecret(ref string) (string, error) {
+	path, key := splitSecretRef(ref)
+	if vaultAddrVar == "" || vaultTokenVar == "" {
+		return "", fmt.Errorf("VAULT_ADDR and VAULT_TOKEN are required to read %v", path)
+	}
+	if key == "" {
+		return "", fmt.Errorf("missing #key in vault reference %v", ref)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, strings.TrimRight(vaultAddrVar, "/")+"/v1/"+strings.TrimLeft(path, "/"), nil)
+	if err != nil {
+		return "", fmt.Errorf("error creating vault request: %v", err)
+	}
+	req.Header.Set("X-Vault-Token", vaultTokenVar)
+
+	var secret struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	err = doSecretRequest(req, &secret)
+	if err != nil {
+		return "", fmt.Errorf("error reading vault secret %v: %v", path, err)
+	}
+
+	// KV v2 nests the secret in data.data
+	data := secret.Data
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		data = nested
+	}
+	value, ok := data[key].(string)
+	if !ok {
+		return "", fmt.Errorf("no %v found in vault secret %v", key, path)
+	}
+	return value, nil
+}
+
+// Read a secret from AWS Secrets Manager, picking key out of it when the secret is a JSON object
+func getAWSSecret(ref string) (string, error) {
+	secretId, key := splitSecretRef(ref)
+	if awsRegionVar == "" || awsAccessKeyIdVar == "" || awsSecretAccessKeyVar == "" {
+		return "", fmt.Errorf("AWS_REGION, AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are required to read %v", secretId)
+	}
+
+	body, _ := json.Marshal(map[string]string{"SecretId": secretId})
+	host := fmt.Sprintf(awsSecretsManagerHostTemplate, awsRegionVar)
+	req, err := http.NewRequest(http.MethodPost, "https://"+host+"/", bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("error creating secrets manager request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", awsSecretsManagerTarget)
+	signAWSRequest(req, body, "secretsmanager", time.Now().UTC())
+
+	var secret struct {
+		SecretString string `json:"SecretString"`
+	}
+	err = doSecretRequest(req, &secret)
+	if err != nil {
+		return "", fmt.Errorf("error reading AWS secret %v: %v", secretId, err)
+	}
+	if key == "" {
+		return secret.SecretString, nil
+	}
+
+	var values map[string]interface{}
+	err = json.Unmarshal([]byte(secret.SecretString), &values)
+	if err != nil {
+		return "", fmt.Errorf("error decoding AWS secret %v: %v", secretId, err)
+	}
+	value, ok := values[key].(string)
+	if !ok {
+		return "", fmt.Errorf("no %v found in AWS secret %v", key, secretId)
+	}
+	return value, nil
+}
+
+func doSecretRequest(req *http.Request, result interface{}) error {
+	res, err := Client.Do(req)
+	if err != nil {
+		return err
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	_ = res.Body.Close()
+	if err != nil {
+		return err
+	}
+	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("%v %v", res.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return json.Unmarshal(body, result)
+}
+
+// Sign the request with AWS Signature Version 4
+func signAWSRequest(req *http.Request, body []byte, service string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	if awsSessionTokenVar != "" {
+		req.Header.Set("X-Amz-Security-Token", awsSessionTokenVar)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(req.Header.Get(name))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{req.Method, path, req.URL.RawQuery, canonicalHeaders.String(),
+		signedHeaders, sha256Hex(body)}, "\n")
+	scope := strings.Join([]string{date, awsRegionVar, service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+awsSecretAccessKeyVar), date)
+	for _, part := range []string{awsRegionVar, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%v/%v, SignedHeaders=%v, Signature=%v",
+		awsAccessKeyIdVar, scope, signedHeaders, signature))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"transferwisely/mocks"
+)
+
+func TestResolveSecretFromFile(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "transferwisely")
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "api_token")
+	_ = ioutil.WriteFile(file, []byte("file-token\n"), 0600)
+
+	_ = os.Setenv("API_TOKEN_FILE", file)
+	defer os.Unsetenv("API_TOKEN_FILE")
+	value, err := resolveSecret("API_TOKEN", "env-token")
+	assert.NoError(t, err)
+	assert.Equal(t, "file-token", value)
+
+	_ = os.Setenv("API_TOKEN_FILE", filepath.Join(dir, "missing"))
+	_, err = resolveSecret("API_TOKEN", "env-token")
+	assert.Error(t, err)
+
+	value, err = resolveSecret("MAIL_PASS", "plain")
+	assert.NoError(t, err)
+	assert.Equal(t, "plain", value)
+}
+
+func TestResolveSecretFromVault(t *testing.T) {
+	defer func(addr, token string) { vaultAddrVar, vaultTokenVar = addr, token }(vaultAddrVar, vaultTokenVar)
+
+	_, err := resolveSecret("API_TOKEN", "vault://secret/data/transferwisely#api_token")
+	assert.Error(t, err, "vault isn't configured")
+
+	vaultAddrVar, vaultTokenVar = "https://vault.example.com/", "s.token"
+	mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+		assert.Equal(t, "https://vault.example.com/v1/secret/data/transferwisely", req.URL.String())
+		assert.Equal(t, "s.token", req.Header.Get("X-Vault-Token"))
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(strings.NewReader(`{"data": {"data": {"api_token": "vault-token"}, "metadata": {}}}`)),
+		}, nil
+	}
+	value, err := resolveSecret("API_TOKEN", "vault://secret/data/transferwisely#api_token")
+	assert.NoError(t, err)
+	assert.Equal(t, "vault-token", value)
+
+	_, err = resolveSecret("API_TOKEN", "vault://secret/data/transferwisely#unknown")
+	assert.Error(t, err)
+}
+
+func TestResolveSecretFromAWS(t *testing.T) {
+	defer func(region, id, key string) {
+		awsRegionVar, awsAccessKeyIdVar, awsSecretAccessKeyVar = region, id, key
+	}(awsRegionVar, awsAccessKeyIdVar, awsSecretAccessKeyVar)
+
+	awsRegionVar, awsAccessKeyIdVar, awsSecretAccessKeyVar = "eu-west-1", "AKID", "secret"
+	mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+		assert.Equal(t, "secretsmanager.eu-west-1.amazonaws.com", req.URL.Host)
+		assert.Equal(t, awsSecretsManagerTarget, req.Header.Get("X-Amz-Target"))
+		assert.True(t, strings.HasPrefix(req.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/"))
+		body, _ := ioutil.ReadAll(req.Body)
+		assert.JSONEq(t, `{"SecretId": "transferwisely/prod"}`, string(body))
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(strings.NewReader(`{"SecretString": "{\"api_token\": \"aws-token\"}"}`)),
+		}, nil
+	}
+
+	value, err := resolveSecret("API_TOKEN", "awssm://transferwisely/prod#api_token")
+	assert.NoError(t, err)
+	assert.Equal(t, "aws-token", value)
+	value, err = resolveSecret("API_TOKEN", "awssm://transferwisely/prod")
+	assert.NoError(t, err)
+	assert.Equal(t, `{"api_token": "aws-token"}`, value)
+}
+
+// Example from the AWS Signature Version 4 test suite (get-vanilla)
+func TestSignAWSRequest(t *testing.T) {
+	defer func(region, id, key string) {
+		awsRegionVar, awsAccessKeyIdVar, awsSecretAccessKeyVar = region, id, key
+	}(awsRegionVar, awsAccessKeyIdVar, awsSecretAccessKeyVar)
+	awsRegionVar, awsAccessKeyIdVar = "us-east-1", "AKIDEXAMPLE"
+	awsSecretAccessKeyVar = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+
+	req, _ := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	signAWSRequest(req, nil, "service", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+	assert.Equal(t, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, "+
+		"SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		req.Header.Get("Authorization"))
+}
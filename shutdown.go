@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-co-op/gocron"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// time given to the HTTP server to finish serving its requests once re-bookings are drained
+const serverShutdownTimeout = 5 * time.Second
+
+// re-bookings in flight, a create-then-cancel sequence that must never be interrupted halfway
+var operations = struct {
+	sync.Mutex
+	shuttingDown bool
+	inFlight     sync.WaitGroup
+}{}
+
+// Start an operation that must finish before shutting down, false once shutting down
+func beginOperation() bool {
+	operations.Lock()
+	defer operations.Unlock()
+
+	if operations.shuttingDown {
+		return false
+	}
+	operations.inFlight.Add(1)
+	return true
+}
+
+func endOperation() {
+	operations.inFlight.Done()
+}
+
+// Refuse any new operation and wait for the in-flight ones to finish, false if they didn't within timeout
+func drainOperations(timeout time.Duration) bool {
+	operations.Lock()
+	operations.shuttingDown = true
+	operations.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		operations.inFlight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func getShutdownTimeout() (time.Duration, error) {
+	seconds, err := strconv.ParseUint(shutdownTimeoutVar, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value for SHUTDOWN_TIMEOUT: %v", err)
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// Block until SIGTERM or SIGINT, then stop scheduling checks, let the in-flight re-booking finish and stop the server
+func waitForShutdown(scheduler *gocron.Scheduler, server *http.Server, timeout time.Duration) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	sig := <-signals
+
+	log.Printf("|| %v RECEIVED, SHUTTING DOWN ||", sig)
+	scheduler.Stop()
+	if !drainOperations(timeout) {
+		log.Printf("|| SHUTDOWN TIMEOUT, RE-BOOKING STILL IN FLIGHT AFTER %v ||", timeout)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), serverShutdownTimeout)
+	defer cancel()
+	err := server.Shutdown(ctx)
+	if err != nil {
+		log.Printf("waitForShutdown: %v", err)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestDrainOperations(t *testing.T) {
+	defer func() {
+		operations.Lock()
+		operations.shuttingDown = false
+		operations.Unlock()
+	}()
+
+	assert.True(t, beginOperation())
+	finished := make(chan struct{})
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		close(finished)
+		endOperation()
+	}()
+
+	assert.True(t, drainOperations(time.Second))
+	select {
+	case <-finished:
+	default:
+		t.Fatal("drained before the in-flight operation finished")
+	}
+	assert.False(t, beginOperation(), "no new operation once shutting down")
+}
+
+func TestDrainOperationsTimeout(t *testing.T) {
+	defer func() {
+		operations.Lock()
+		operations.shuttingDown = false
+		operations.Unlock()
+	}()
+
+	assert.True(t, beginOperation())
+	defer endOperation()
+	assert.False(t, drainOperations(10*time.Millisecond))
+}
+
+func TestGetShutdownTimeout(t *testing.T) {
+	defer func(v string) { shutdownTimeoutVar = v }(shutdownTimeoutVar)
+
+	shutdownTimeoutVar = "30"
+	timeout, err := getShutdownTimeout()
+	assert.NoError(t, err)
+	assert.Equal(t, 30*time.Second, timeout)
+
+	shutdownTimeoutVar = "-1"
+	_, err = getShutdownTimeout()
+	assert.Error(t, err)
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// transfer statuses the sandbox can simulate, in the order a transfer goes through them
+var simulatedStatuses = []string{"processing", "funds_converted", "outgoing_payment_sent"}
+
+// statuses the sandbox can simulate besides the happy path
+var simulatedFailureStatuses = []string{"bounced_back", "funds_refunded"}
+
+// Move a sandbox transfer to the given status
+func simulateTransferStatus(transferId uint64, status string) (Transfer, error) {
+	path := strings.NewReplacer(
+		"{transferId}", strconv.FormatUint(transferId, 10),
+		"{status}", status,
+	).Replace(simulateTransferAPIPath)
+	url := &url.URL{Host: hostVar, Scheme: "https", Path: path}
+
+	var transfer Transfer
+	_, err := callExternalAPI(http.MethodGet, url.String(), nil, &transfer)
+	if err != nil {
+		return Transfer{}, fmt.Errorf("error GET simulate transfer API: %w", err)
+	}
+	return transfer, nil
+}
+
+// Top up a sandbox balance of the profile in the given currency
+func simulateBalanceTopUp(profile uint64, currency string, amount float64) error {
+	balance, err := getBalance(profile, currency)
+	if err != nil {
+		return fmt.Errorf("simulateBalanceTopUp: %v", err)
+	}
+
+	request, _ := json.Marshal(TopUpRequest{
+		ProfileId: profile,
+		BalanceId: balance.Id,
+		Currency:  currency,
+		Amount:    amount,
+	})
+	url := &url.URL{Host: hostVar, Scheme: "https", Path: simulateTopUpAPIPath}
+	_, err = callExternalAPI(http.MethodPost, url.String(), request, nil)
+	if err != nil {
+		return fmt.Errorf("error POST simulate top up API: %w", err)
+	}
+	return nil
+}
+
+// Simulation endpoints only exist in the sandbox, never point them at production
+func requireSandbox() error {
+	if hostVar != hostSandbox || apiTokenVar == "" {
+		return fmt.Errorf("error: simulation requires ENV=sandbox and API_TOKEN")
+	}
+	return nil
+}
+
+func isSimulatedStatus(status string) bool {
+	for _, s := range append(simulatedStatuses, simulatedFailureStatuses...) {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+func runSimulate(args []string) error {
+	if err := requireSandbox(); err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("usage: simulate transfer|complete|topup")
+	}
+
+	switch args[0] {
+	case "transfer":
+		if len(args) != 3 || !isSimulatedStatus(args[2]) {
+			return fmt.Errorf("usage: simulate transfer <transferId> <%v>",
+				strings.Join(append(simulatedStatuses, simulatedFailureStatuses...), "|"))
+		}
+		transferId, err := strconv.ParseUint(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid transfer ID: %v", err)
+		}
+		_, err = simulateTransferStatus(transferId, args[2])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Transfer %v moved to %v\n", transferId, args[2])
+		return nil
+
+	case "complete":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: simulate complete <transferId>")
+		}
+		transferId, err := strconv.ParseUint(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid transfer ID: %v", err)
+		}
+		for _, status := range simulatedStatuses {
+			_, err = simulateTransferStatus(transferId, status)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Transfer %v moved to %v\n", transferId, status)
+		}
+		return nil
+
+	case "topup":
+		flags := flag.NewFlagSet("simulate topup", flag.ContinueOnError)
+		profile := flags.Uint64("profile", 0, "profile ID owning the balance")
+		currency := flags.String("currency", "", "currency of the balance to top up")
+		amount := flags.Float64("amount", 0, "amount to top up")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		if *profile == 0 || *currency == "" || *amount <= 0 {
+			return fmt.Errorf("usage: simulate topup --profile <id> --currency <currency> --amount <amount>")
+		}
+		err := simulateBalanceTopUp(*profile, strings.ToUpper(*currency), *amount)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Topped up %v %v balance of profile %v\n", *amount, strings.ToUpper(*currency), *profile)
+		return nil
+
+	default:
+		return fmt.Errorf("usage: simulate transfer|complete|topup")
+	}
+}
+
+func init() {
+	registerCommand("simulate", Command{
+		Usage: "simulate transfer|complete|topup ...        drive the sandbox simulation endpoints",
+		Run:   runSimulate,
+	})
+}
+
+type TopUpRequest struct {
+	ProfileId uint64  `json:"profileId"`
+	BalanceId uint64  `json:"balanceId"`
+	Currency  string  `json:"currency"`
+	Amount    float64 `json:"amount"`
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"transferwisely/mocks"
+)
+
+func TestRunSimulate(t *testing.T) {
+	defer func(host, token string) { hostVar, apiTokenVar = host, token }(hostVar, apiTokenVar)
+
+	t.Run("refuses production", func(t *testing.T) {
+		hostVar, apiTokenVar = hostProduction, "token"
+		assert.Error(t, runSimulate([]string{"complete", "1"}))
+	})
+
+	t.Run("complete", func(t *testing.T) {
+		hostVar, apiTokenVar = hostSandbox, "token"
+		var paths []string
+		mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+			paths = append(paths, req.URL.Path)
+			j, _ := json.Marshal(Transfer{Id: 1})
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewReader(j)),
+			}, nil
+		}
+
+		assert.NoError(t, runSimulate([]string{"complete", "1"}))
+		assert.Equal(t, []string{
+			"/v1/simulation/transfers/1/processing",
+			"/v1/simulation/transfers/1/funds_converted",
+			"/v1/simulation/transfers/1/outgoing_payment_sent",
+		}, paths)
+	})
+
+	t.Run("unknown status", func(t *testing.T) {
+		hostVar, apiTokenVar = hostSandbox, "token"
+		assert.Error(t, runSimulate([]string{"transfer", "1", "teleported"}))
+	})
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+)
+
+// source ranking notification
+const (
+	sourceRankingSubject = "Best source for %v {%v}: {%v}, was {%v}"
+	sourceRankingText    = "Paying %v {%v} from each of your source currencies\n\n%v"
+	sourceQuoteText      = "%v. {%v}: %v {%v} at %v, %.2f%% over mid-market, fee %v"
+)
+
+// SourceRanking quotes the same target amount from several source currencies, e.g. balances held in USD, EUR and GBP
+type SourceRanking struct {
+	Sources      []string `json:"sources"`
+	Target       string   `json:"target"`
+	TargetAmount Decimal  `json:"targetAmount"`
+}
+
+func (r SourceRanking) String() string {
+	return fmt.Sprintf("%v --> %v %v", strings.Join(r.Sources, ","), r.TargetAmount, r.Target)
+}
+
+// SourceQuote is what the target amount costs from a source currency, Cost being how much more than at the
+// mid-market rate it is in percent, comparable across source currencies
+type SourceQuote struct {
+	Source        string  `json:"source"`
+	SourceAmount  Decimal `json:"sourceAmount"`
+	Fee           Decimal `json:"fee"`
+	Rate          float64 `json:"rate"`
+	EffectiveRate float64 `json:"effectiveRate"`
+	MidRate       float64 `json:"midRate"`
+	Cost          float64 `json:"cost"`
+}
+
+// best source currency last seen by source ranking, to notify when it changes
+var bestSources = struct {
+	sync.Mutex
+	bySourceRanking map[string]string
+}{bySourceRanking: map[string]string{}}
+
+// Quote the target amount from every source currency of the ranking, cheapest first, the sources that can't be
+// quoted being logged and left out
+func rankSources(ranking SourceRanking, profile uint64) ([]SourceQuote, error) {
+	var quotes []SourceQuote
+	for _, source := range ranking.Sources {
+		quote, err := generateTargetQuoteDetail(source, ranking.Target, ranking.TargetAmount, profile)
+		if err != nil {
+			log.Printf("rankSources: {%v} --> {%v}: %v", source, ranking.Target, err)
+			continue
+		}
+		midRate, err := getLiveRate(source, ranking.Target)
+		if err != nil || quote.SourceAmount.Sign() <= 0 {
+			log.Printf("rankSources: {%v} --> {%v}: no rate to compare with: %v", source, ranking.Target, err)
+			continue
+		}
+		quotes = append(quotes, SourceQuote{
+			Source:        source,
+			SourceAmount:  quote.SourceAmount,
+			Fee:           quote.Fee,
+			Rate:          quote.Rate,
+			EffectiveRate: ranking.TargetAmount.Quo(quote.SourceAmount).Float64(),
+			MidRate:       midRate,
+			Cost:          (quote.SourceAmount.Mul(decimalOf(midRate)).Quo(ranking.TargetAmount).Float64() - 1) * 100,
+		})
+	}
+	if len(quotes) == 0 {
+		return nil, fmt.Errorf("rankSources: no source currency of %v could be quoted", ranking)
+	}
+	sort.SliceStable(quotes, func(i, j int) bool { return quotes[i].Cost < quotes[j].Cost })
+	return quotes, nil
+}
+
+// Rank the sources of every configured source ranking, notifying when the cheapest source changed since the last check
+func evaluateSourceRankings() {
+	rankings := getConfig().SourceRankings
+	if len(rankings) == 0 {
+		return
+	}
+	profile, err := getConfiguredProfile()
+	if err != nil {
+		log.Printf("evaluateSourceRankings: %v", err)
+		return
+	}
+
+	for _, ranking := range rankings {
+		quotes, err := rankSources(ranking, profile)
+		if err != nil {
+			log.Printf("evaluateSourceRankings: %v", err)
+			continue
+		}
+		best := quotes[0].Source
+
+		bestSources.Lock()
+		previous := bestSources.bySourceRanking[ranking.String()]
+		bestSources.bySourceRanking[ranking.String()] = best
+		bestSources.Unlock()
+		if previous == "" || previous == best {
+			continue
+		}
+
+		log.Printf("|| BEST SOURCE CHANGED || {%v} --> {%v} | Was: {%v} | Cost: %.2f%% over mid-market ||",
+			best, ranking.Target, previous, quotes[0].Cost)
+		notify(Event{
+			Kind:    EventSourceRanking,
+			Subject: fmt.Sprintf(sourceRankingSubject, formatAmount(ranking.TargetAmount, ranking.Target), ranking.Target, best, previous),
+			Text: fmt.Sprintf(sourceRankingText, formatAmount(ranking.TargetAmount, ranking.Target), ranking.Target,
+				formatSourceQuotes(quotes)),
+		})
+	}
+}
+
+func formatSourceQuotes(quotes []SourceQuote) string {
+	lines := make([]string, len(quotes))
+	for i, quote := range quotes {
+		lines[i] = fmt.Sprintf(sourceQuoteText, i+1, quote.Source, formatAmount(quote.SourceAmount, quote.Source), quote.Source,
+			formatRate(quote.Rate), quote.Cost, formatAmount(quote.Fee, quote.Source))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func runSourcesCommand(args []string) error {
+	flags := flag.NewFlagSet("sources", flag.ContinueOnError)
+	sources := flags.String("sources", "", "comma separated source currencies like USD,EUR,GBP")
+	target := flags.String("target", "", "target currency")
+	targetAmount := flags.Float64("target-amount", 0, "amount the recipient gets")
+	profile := flags.Uint64("profile", 0, "profile ID to quote under, defaults to PROFILE_ID")
+	output := outputFlag(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	ranking := SourceRanking{Target: strings.ToUpper(*target), TargetAmount: decimalOf(*targetAmount)}
+	for _, source := range strings.Split(*sources, ",") {
+		if source = strings.ToUpper(strings.TrimSpace(source)); source != "" {
+			ranking.Sources = append(ranking.Sources, source)
+		}
+	}
+	if len(ranking.Sources) == 0 || ranking.Target == "" || ranking.TargetAmount.Sign() <= 0 {
+		return fmt.Errorf("usage: sources --sources <cur>,<cur>... --target <cur> --target-amount <amount> [--profile <id>] [--output json]")
+	}
+	if *profile == 0 {
+		configured, err := getConfiguredProfile()
+		if err != nil {
+			return err
+		}
+		*profile = configured
+	}
+
+	quotes, err := rankSources(ranking, *profile)
+	if err != nil {
+		return err
+	}
+	return printOutput(os.Stdout, *output, quotes, func(w io.Writer) {
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(tw, "SOURCE\tPAY\tRATE\tEFFECTIVE RATE\tFEE\tOVER MID-MARKET\n")
+		for _, quote := range quotes {
+			fmt.Fprintf(tw, "%v\t%v\t%v\t%.6g\t%v\t%.2f%%\n", quote.Source, formatAmount(quote.SourceAmount, quote.Source),
+				quote.Rate, quote.EffectiveRate, formatAmount(quote.Fee, quote.Source), quote.Cost)
+		}
+		_ = tw.Flush()
+	})
+}
+
+func init() {
+	registerCommand("sources", Command{
+		Usage: "sources --sources <cur>,... --target <cur>   rank source currencies for a target amount",
+		Run:   runSourcesCommand,
+	})
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"transferwisely/mocks"
+)
+
+func TestRankSources(t *testing.T) {
+	defer func(c Config, limit string) { config.current, notifyRateLimitVar = c, limit }(getConfig(), notifyRateLimitVar)
+	notifyRateLimitVar = "0"
+	fake := &fakeNotifier{}
+	defer func(n []Notifier) { Notifiers = n }(Notifiers)
+	Notifiers = []Notifier{fake}
+
+	// source amounts to pay 100000 INR, the mid-market rates being 80, 90 and 100
+	sourceAmounts := map[string]float64{"USD": 1270, "EUR": 1120, "GBP": 1010}
+	midRates := map[string]float64{"USD": 80, "EUR": 90, "GBP": 100}
+	mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+		body := `{}`
+		switch {
+		case req.Method == http.MethodPost && strings.HasSuffix(req.URL.String(), quotesAPIPath):
+			var request CreateQuoteRequest
+			data, _ := ioutil.ReadAll(req.Body)
+			_ = json.Unmarshal(data, &request)
+			if amount, ok := sourceAmounts[request.SourceCurrency]; ok {
+				j, _ := json.Marshal(QuoteDetail{Id: request.SourceCurrency, SourceAmount: decimalOf(amount), Rate: 100000 / amount,
+					TargetAmount: decimalOf(request.TargetAmount)})
+				body = string(j)
+			} else {
+				return &http.Response{StatusCode: http.StatusUnprocessableEntity, Body: ioutil.NopCloser(strings.NewReader(`{}`))}, nil
+			}
+		case strings.Contains(req.URL.String(), liveRateAPIPath):
+			body = fmt.Sprintf(`[{"rate": %v}]`, midRates[req.URL.Query().Get("source")])
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(body))}, nil
+	}
+
+	ranking := SourceRanking{Sources: []string{"USD", "EUR", "GBP", "XXX"}, Target: "INR", TargetAmount: decimalOf(100000)}
+	quotes, err := rankSources(ranking, 1)
+	assert.NoError(t, err)
+	assert.Len(t, quotes, 3, "XXX can't be quoted")
+	assert.Equal(t, "EUR", quotes[0].Source)
+	assert.InDelta(t, 0.8, quotes[0].Cost, 1e-9)
+	assert.Equal(t, "GBP", quotes[1].Source)
+	assert.InDelta(t, 1.0, quotes[1].Cost, 1e-9)
+	assert.Equal(t, "USD", quotes[2].Source)
+
+	t.Run("notifies when the best source changes", func(t *testing.T) {
+		config.current = Config{SourceRankings: []SourceRanking{ranking}}
+		evaluateSourceRankings()
+		assert.Empty(t, fake.events, "first ranking")
+
+		sourceAmounts["GBP"] = 1005
+		evaluateSourceRankings()
+		assert.Len(t, fake.events, 1)
+		assert.Equal(t, EventSourceRanking, fake.events[0].Kind)
+		assert.Contains(t, fake.events[0].Subject, "{GBP}, was {EUR}")
+
+		evaluateSourceRankings()
+		assert.Len(t, fake.events, 1)
+	})
+}
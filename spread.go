@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// number of quote spreads kept in the state file
+const maxQuoteSpreads = 1000
+
+// quote spread notification
+const (
+	quoteSpreadSubject = "Wise quoted {%v} --> {%v} %.2f%% below the mid-market rate"
+	quoteSpreadText    = "Quoted rate: %v\nMid-market rate: %v\nSpread: %.2f%%, more than the %v%% QUOTE_SPREAD\n\n" +
+		"Wise is pricing its guaranteed rate quotes off the mid-market rate, re-bookings get less than the live rate shows."
+)
+
+// QuoteSpread is how far below the mid-market rate a quote was priced, in percent
+type QuoteSpread struct {
+	Time           time.Time `json:"time"`
+	QuoteId        string    `json:"quoteId"`
+	SourceCurrency string    `json:"sourceCurrency"`
+	TargetCurrency string    `json:"targetCurrency"`
+	QuotedRate     float64   `json:"quotedRate"`
+	MidRate        float64   `json:"midRate"`
+	Spread         float64   `json:"spread"`
+}
+
+// SpreadSummary sums the recorded quote spreads of a pair up for the dashboard
+type SpreadSummary struct {
+	Pair      string
+	Quotes    int
+	Last      float64
+	Avg       float64
+	Max       float64
+	Sparkline string
+}
+
+// pairs whose quote spread is beyond QUOTE_SPREAD, notified once until it's back within it
+var widenedSpreads = struct {
+	sync.Mutex
+	pairs map[string]bool
+}{pairs: map[string]bool{}}
+
+func getQuoteSpreadThreshold() (float64, error) {
+	threshold, err := strconv.ParseFloat(quoteSpreadVar, 64)
+	if err != nil || threshold <= 0 {
+		return 0, fmt.Errorf("invalid value for QUOTE_SPREAD: %v, expected a positive percentage", quoteSpreadVar)
+	}
+	return threshold, nil
+}
+
+// Compare the quote's rate to the pair's mid-market rate, keeping the spread in the state file and notifying once when
+// it gets beyond QUOTE_SPREAD percent
+func recordQuoteSpread(quote QuoteDetail, now time.Time) {
+	if quote.Rate <= 0 {
+		return
+	}
+	midRate, err := getLiveRate(quote.SourceCurrency, quote.TargetCurrency)
+	if err != nil {
+		log.Printf("recordQuoteSpread: %v", err)
+		return
+	}
+	spread := QuoteSpread{
+		Time:           now,
+		QuoteId:        quote.Id,
+		SourceCurrency: quote.SourceCurrency,
+		TargetCurrency: quote.TargetCurrency,
+		QuotedRate:     quote.Rate,
+		MidRate:        midRate,
+		Spread:         (1 - quote.Rate/midRate) * 100,
+	}
+	log.Printf("|| QUOTE SPREAD || {%v} --> {%v} | Quoted: %v | Mid-market: %v | Spread: %.2f%% ||", spread.SourceCurrency,
+		spread.TargetCurrency, spread.QuotedRate, spread.MidRate, spread.Spread)
+
+	err = updateState(func(state *State) error {
+		state.QuoteSpreads = append(state.QuoteSpreads, spread)
+		if len(state.QuoteSpreads) > maxQuoteSpreads {
+			state.QuoteSpreads = state.QuoteSpreads[len(state.QuoteSpreads)-maxQuoteSpreads:]
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("recordQuoteSpread: %v", err)
+	}
+
+	threshold, err := getQuoteSpreadThreshold()
+	if err != nil {
+		log.Printf("recordQuoteSpread: %v", err)
+		return
+	}
+	pair := pairKey(spread.SourceCurrency, spread.TargetCurrency)
+	widened := spread.Spread > threshold
+	widenedSpreads.Lock()
+	wasWidened := widenedSpreads.pairs[pair]
+	widenedSpreads.pairs[pair] = widened
+	widenedSpreads.Unlock()
+	if !widened || wasWidened {
+		return
+	}
+	notify(Event{
+		Kind:    EventQuoteSpread,
+		Subject: fmt.Sprintf(quoteSpreadSubject, spread.SourceCurrency, spread.TargetCurrency, spread.Spread),
+		Text:    fmt.Sprintf(quoteSpreadText, spread.QuotedRate, spread.MidRate, spread.Spread, quoteSpreadVar),
+		Pair:    pair,
+	})
+}
+
+// Sum the quote spreads up by pair, in the order of the pairs
+func summarizeQuoteSpreads(spreads []QuoteSpread) []SpreadSummary {
+	var pairs []string
+	values := map[string][]float64{}
+	for _, spread := range spreads {
+		pair := pairKey(spread.SourceCurrency, spread.TargetCurrency)
+		if _, ok := values[pair]; !ok {
+			pairs = append(pairs, pair)
+		}
+		values[pair] = append(values[pair], spread.Spread)
+	}
+	sort.Strings(pairs)
+
+	summaries := make([]SpreadSummary, len(pairs))
+	for i, pair := range pairs {
+		summary := SpreadSummary{Pair: pair, Quotes: len(values[pair])}
+		sum := 0.0
+		for j, value := range values[pair] {
+			sum += value
+			if j == 0 || value > summary.Max {
+				summary.Max = value
+			}
+		}
+		summary.Last = values[pair][len(values[pair])-1]
+		summary.Avg = sum / float64(summary.Quotes)
+		summary.Sparkline = sparkline(downsample(values[pair], sparklineWidth))
+		summaries[i] = summary
+	}
+	return summaries
+}
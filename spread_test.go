@@ -0,0 +1,69 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"transferwisely/mocks"
+)
+
+func TestRecordQuoteSpread(t *testing.T) {
+	defer func(spread, limit, file string) {
+		quoteSpreadVar, notifyRateLimitVar, stateFileVar = spread, limit, file
+	}(quoteSpreadVar, notifyRateLimitVar, stateFileVar)
+	dir, _ := ioutil.TempDir("", "transferwisely")
+	defer os.RemoveAll(dir)
+	quoteSpreadVar, notifyRateLimitVar, stateFileVar = "0.5", "0", filepath.Join(dir, "state.json")
+	fake := &fakeNotifier{}
+	defer func(n []Notifier) { Notifiers = n }(Notifiers)
+	Notifiers = []Notifier{fake}
+	mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK,
+			Body: ioutil.NopCloser(strings.NewReader(`[{"rate": 100, "source": "GBP", "target": "INR"}]`))}, nil
+	}
+	now := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+	quote := func(id string, rate float64) QuoteDetail {
+		return QuoteDetail{Id: id, Rate: rate, SourceCurrency: "GBP", TargetCurrency: "INR"}
+	}
+
+	recordQuoteSpread(quote("q1", 99.8), now)
+	assert.Empty(t, fake.events, "within QUOTE_SPREAD")
+	recordQuoteSpread(quote("q2", 99.2), now.Add(time.Minute))
+	recordQuoteSpread(quote("q3", 99.1), now.Add(2*time.Minute))
+	if assert.Len(t, fake.events, 1, "notified once while widened") {
+		assert.Equal(t, EventQuoteSpread, fake.events[0].Kind)
+		assert.Equal(t, "Wise quoted {GBP} --> {INR} 0.80% below the mid-market rate", fake.events[0].Subject)
+	}
+	recordQuoteSpread(quote("q4", 99.9), now.Add(3*time.Minute))
+	recordQuoteSpread(quote("q5", 99), now.Add(4*time.Minute))
+	assert.Len(t, fake.events, 2, "notified again once widened after narrowing")
+
+	state, err := loadState()
+	assert.NoError(t, err)
+	if assert.Len(t, state.QuoteSpreads, 5) {
+		assert.Equal(t, "q2", state.QuoteSpreads[1].QuoteId)
+		assert.Equal(t, 100.0, state.QuoteSpreads[1].MidRate)
+		assert.InDelta(t, 0.8, state.QuoteSpreads[1].Spread, 1e-9)
+	}
+
+	summaries := summarizeQuoteSpreads(append(state.QuoteSpreads, QuoteSpread{SourceCurrency: "EUR", TargetCurrency: "USD",
+		Spread: 0.1}))
+	if assert.Len(t, summaries, 2) {
+		assert.Equal(t, "EUR-USD", summaries[0].Pair)
+		assert.Equal(t, "GBP-INR", summaries[1].Pair)
+		assert.Equal(t, 5, summaries[1].Quotes)
+		assert.InDelta(t, 1, summaries[1].Last, 1e-9)
+		assert.InDelta(t, 1, summaries[1].Max, 1e-9)
+		assert.InDelta(t, 0.6, summaries[1].Avg, 1e-9)
+		assert.Len(t, []rune(summaries[1].Sparkline), 5)
+	}
+
+	quoteSpreadVar = "-1"
+	_, err = getQuoteSpreadThreshold()
+	assert.EqualError(t, err, "invalid value for QUOTE_SPREAD: -1, expected a positive percentage")
+}
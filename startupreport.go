@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// roles of the transfers in the startup report
+const (
+	startupTracked = "tracked"
+	startupPinned  = "pinned"
+	startupSpare   = "spare"
+)
+
+// startup report notification
+const (
+	startupReportSubject = "transferwisely started, managing %v transfers"
+	startupReportLine    = "%v transfer %v: {%v} --> {%v} | Booked Rate: %v | Live Rate: %v | Re-books at: %v | Amount: %v | " +
+		"Rate lock expires: %v"
+)
+
+// StartupReport is what the batch believes it manages when it starts
+type StartupReport struct {
+	Time             time.Time         `json:"time"`
+	Leader           bool              `json:"leader"`
+	Paused           bool              `json:"paused"`
+	ReadOnly         bool              `json:"readOnly"`
+	PendingProposals int               `json:"pendingProposals"`
+	Transfers        []StartupTransfer `json:"transfers"`
+	Errors           []string          `json:"errors,omitempty"`
+}
+
+// StartupTransfer is a transfer of a tracked status, tracked when it's the best one of its pair, pinned, or spare
+type StartupTransfer struct {
+	Transfer  Transfer `json:"transfer"`
+	Role      string   `json:"role"`
+	LiveRate  float64  `json:"liveRate,omitempty"`
+	Threshold float64  `json:"threshold,omitempty"`
+	Error     string   `json:"error,omitempty"`
+}
+
+// Fetch every transfer of a tracked status with its quote and the live rate of its pair
+func buildStartupReport(now time.Time) (StartupReport, error) {
+	report := StartupReport{Time: now, Leader: isLeader(), Paused: isPaused(), ReadOnly: isReadOnly()}
+	if state, err := loadState(); err == nil {
+		for _, proposal := range state.Proposals {
+			if proposal.Status == proposalPending && now.Before(proposal.ExpiresAt) {
+				report.PendingProposals++
+			}
+		}
+	}
+
+	statuses, err := getTrackedStatuses()
+	if err != nil {
+		return report, fmt.Errorf("buildStartupReport: %v", err)
+	}
+	transfers, err := listAllTransfers(strings.Join(statuses, ","))
+	if err != nil {
+		return report, fmt.Errorf("buildStartupReport: %v", err)
+	}
+
+	best := map[string]Transfer{}
+	for _, transfer := range transfers {
+		pair := pairKey(transfer.SourceCurrency, transfer.TargetCurrency)
+		if current, ok := best[pair]; !isPinned(transfer.Id) && (!ok || current.Rate < transfer.Rate) {
+			best[pair] = transfer
+		}
+	}
+	liveRates := map[string]float64{}
+	for _, transfer := range transfers {
+		pair := pairKey(transfer.SourceCurrency, transfer.TargetCurrency)
+		entry := StartupTransfer{Transfer: transfer, Role: startupSpare}
+		switch {
+		case isPinned(transfer.Id):
+			entry.Role = startupPinned
+		case best[pair].Id == transfer.Id:
+			entry.Role = startupTracked
+		}
+
+		if detailed, err := withQuoteDetail(transfer); err != nil {
+			entry.Error = err.Error()
+		} else {
+			entry.Transfer = detailed
+		}
+		if _, ok := liveRates[pair]; !ok {
+			liveRates[pair], err = getLiveRate(transfer.SourceCurrency, transfer.TargetCurrency)
+			if err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("live rate of %v: %v", pair, err))
+			}
+		}
+		entry.LiveRate = liveRates[pair]
+		if settings, err := getSettings(transfer); err == nil {
+			entry.Threshold = rebookThreshold(transfer, settings)
+		}
+		report.Transfers = append(report.Transfers, entry)
+	}
+	sort.SliceStable(report.Transfers, func(i, j int) bool {
+		a, b := report.Transfers[i].Transfer, report.Transfers[j].Transfer
+		return pairKey(a.SourceCurrency, a.TargetCurrency) < pairKey(b.SourceCurrency, b.TargetCurrency)
+	})
+	return report, nil
+}
+
+func (r StartupReport) lines() []string {
+	lines := []string{fmt.Sprintf("Leader: %v | Paused: %v | Read only: %v | Pending proposals: %v", r.Leader, r.Paused,
+		r.ReadOnly, r.PendingProposals)}
+	for _, entry := range r.Transfers {
+		t := entry.Transfer
+		expiry := formatExpiry(t.RateExpirationTime)
+		if expiry == "" {
+			expiry = "unknown"
+		}
+		line := fmt.Sprintf(startupReportLine, strings.Title(entry.Role), t.Id, t.SourceCurrency, t.TargetCurrency,
+			formatRate(t.Rate), formatRate(entry.LiveRate), formatRate(entry.Threshold), formatAmount(t.SourceAmount, t.SourceCurrency), expiry)
+		if entry.Error != "" {
+			line += " | Error: " + entry.Error
+		}
+		lines = append(lines, line)
+	}
+	for _, err := range r.Errors {
+		lines = append(lines, "Error: "+err)
+	}
+	return lines
+}
+
+// Log what the batch manages once it starts, and notify it too with STARTUP_REPORT_NOTIFY, from the leader only
+func reportStartup(now time.Time) {
+	report, err := buildStartupReport(now)
+	if err != nil {
+		log.Printf("reportStartup: %v", err)
+		report.Errors = append(report.Errors, err.Error())
+	}
+	lines := report.lines()
+	for _, line := range lines {
+		log.Printf("|| STARTUP REPORT || %v ||", line)
+	}
+
+	if notifyStartup, _ := strconv.ParseBool(startupReportNotifyVar); !notifyStartup || !report.Leader {
+		return
+	}
+	notify(Event{
+		Kind:    EventStartupReport,
+		Subject: fmt.Sprintf(startupReportSubject, len(report.Transfers)),
+		Text:    strings.Join(lines, "\n"),
+	})
+}
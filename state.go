@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// State is everything the batch persists between runs in STATE_FILE
+type State struct {
+	Rebooks       []time.Time    `json:"rebooks"`
+	RebookHistory []RebookRecord `json:"rebookHistory"`
+	Proposals     []Proposal     `json:"proposals"`
+	Alerts        []Alert        `json:"alerts,omitempty"`
+	Decisions     []Decision     `json:"decisions,omitempty"`
+
+	// re-booking in progress, see createTransferFromQuote
+	PendingRebook *PendingRebook `json:"pendingRebook,omitempty"`
+
+	// re-bookings the guardrails count and re-booking in progress of the accounts other than the default one, whose
+	// are Rebooks and PendingRebook, by account, see accounts.go
+	AccountRebooks        map[string][]time.Time    `json:"accountRebooks,omitempty"`
+	AccountPendingRebooks map[string]*PendingRebook `json:"accountPendingRebooks,omitempty"`
+
+	// last seen status of the monitored transfers by transfer id
+	TransferStatuses map[uint64]string `json:"transferStatuses,omitempty"`
+
+	// funding reminders sent by transfer id
+	FundingReminders map[uint64]FundingReminders `json:"fundingReminders,omitempty"`
+
+	// notifications muted by pair or transfer, see mute.go
+	Mutes []Mute `json:"mutes,omitempty"`
+
+	// best live rate seen by transfer id, see trailingStopStrategy
+	TrailingPeaks map[uint64]float64 `json:"trailingPeaks,omitempty"`
+
+	// source currency balance of the transfers it can't fund, as last notified, by transfer id
+	LowBalances map[uint64]Decimal `json:"lowBalances,omitempty"`
+
+	// pause of the batch, see control.go
+	Pause *Pause `json:"pause,omitempty"`
+
+	// spread of the generated quotes to the mid-market rate, see spread.go
+	QuoteSpreads []QuoteSpread `json:"quoteSpreads,omitempty"`
+}
+
+var stateMutex sync.Mutex
+
+// Read the persisted state, a missing state file being an empty state
+func loadState() (State, error) {
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+
+	return readStateFile()
+}
+
+// Apply fn to the persisted state and write it back, unless fn fails
+func updateState(fn func(state *State) error) error {
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+
+	state, err := readStateFile()
+	if err != nil {
+		return err
+	}
+	err = fn(&state)
+	if err != nil {
+		return err
+	}
+	return writeStateFile(state)
+}
+
+func readStateFile() (state State, err error) {
+	data, err := ioutil.ReadFile(stateFileVar)
+	if os.IsNotExist(err) {
+		return State{}, nil
+	}
+	if err != nil {
+		return State{}, fmt.Errorf("error reading state file: %v", err)
+	}
+
+	err = json.Unmarshal(data, &state)
+	if err != nil {
+		return State{}, fmt.Errorf("error decoding state file: %v", err)
+	}
+	return state, nil
+}
+
+// Write to a temporary file first so a crash never leaves a half written state file behind
+func writeStateFile(state State) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding state file: %v", err)
+	}
+
+	tmpFile := stateFileVar + ".tmp"
+	err = ioutil.WriteFile(tmpFile, data, 0600)
+	if err != nil {
+		return fmt.Errorf("error writing state file: %v", err)
+	}
+	err = os.Rename(tmpFile, stateFileVar)
+	if err != nil {
+		return fmt.Errorf("error writing state file: %v", err)
+	}
+	return nil
+}
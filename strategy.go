@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// comparison directions, whether a higher or a lower live rate than the booked one is an improvement
+const (
+	directionHigher = "higher"
+	directionLower  = "lower"
+)
+
+// amount modes, whether re-booking keeps the source amount paid or the target amount the recipient gets
+const (
+	amountModeSource = "source"
+	amountModeTarget = "target"
+)
+
+// re-booking strategies
+const (
+	strategyMargin        = "margin"
+	strategyMovingAverage = "moving-average"
+	strategyTrailingStop  = "trailing-stop"
+)
+
+// Strategy decides whether the booked transfer should be re-booked at the live rate
+type Strategy interface {
+	ShouldRebook(transfer Transfer, liveRate float64, settings Settings) (bool, error)
+}
+
+var strategies = map[string]Strategy{
+	strategyMargin:        marginStrategy{},
+	strategyMovingAverage: movingAverageStrategy{},
+	strategyTrailingStop:  trailingStopStrategy{},
+}
+
+// How much more of the target currency the recipient gets when re-booking at the live rate
+func targetGain(transfer Transfer, liveRate float64, settings Settings) Decimal {
+	sourceAmount := transfer.SourceAmount
+	if settings.Amount.Sign() > 0 {
+		sourceAmount = settings.Amount
+	}
+	return roundAmount(sourceAmount.Mul(rateImprovement(transfer.Rate, liveRate, settings)), transfer.TargetCurrency)
+}
+
+// How much better the live rate is than the booked one in absolute terms, negative when it's worse
+func rateImprovement(bookedRate float64, liveRate float64, settings Settings) Decimal {
+	if settings.LowerIsBetter {
+		return decimalOf(bookedRate).Sub(decimalOf(liveRate))
+	}
+	return decimalOf(liveRate).Sub(decimalOf(bookedRate))
+}
+
+// marginStrategy re-books as soon as the live rate beats the booked rate by at least the margin
+type marginStrategy struct{}
+
+func (marginStrategy) ShouldRebook(transfer Transfer, liveRate float64, settings Settings) (bool, error) {
+	improvement := rateImprovement(transfer.Rate, liveRate, settings)
+	return improvement.Sign() > 0 && improvement.Cmp(settings.Margin) >= 0, nil
+}
+
+// movingAverageStrategy re-books like marginStrategy, but only once the live rate also beats its moving average
+// over the last MovingAverageHours, so a brief spike right before a sustained climb doesn't lock in the rate too early
+type movingAverageStrategy struct{}
+
+func (movingAverageStrategy) ShouldRebook(transfer Transfer, liveRate float64, settings Settings) (bool, error) {
+	rebook, err := marginStrategy{}.ShouldRebook(transfer, liveRate, settings)
+	if err != nil || !rebook {
+		return false, err
+	}
+
+	to := time.Now().UTC()
+	from := to.Add(-time.Duration(settings.MovingAverageHours) * time.Hour)
+	history, err := getRateHistory(transfer.SourceCurrency, transfer.TargetCurrency, from, to, rateHistoryGroup)
+	if err != nil {
+		return false, fmt.Errorf("movingAverageStrategy: %v", err)
+	}
+	if len(history) == 0 {
+		return false, fmt.Errorf("movingAverageStrategy: no rate history found for {%v} --> {%v}",
+			transfer.SourceCurrency, transfer.TargetCurrency)
+	}
+
+	return rateImprovement(summarizeRates(history).Avg, liveRate, settings).Sign() > 0, nil
+}
+
+// trailingStopStrategy follows the live rate up once it beats the booked rate, and re-books only after it fell back
+// TrailingStop percent from its peak, still beating the booked rate by the margin, capturing more of an upward move
+// than re-booking at the first margin breach. Peaks are kept in STATE_FILE by transfer
+type trailingStopStrategy struct{}
+
+func (trailingStopStrategy) ShouldRebook(transfer Transfer, liveRate float64, settings Settings) (bool, error) {
+	if rateImprovement(transfer.Rate, liveRate, settings).Sign() <= 0 {
+		// the move is over, the next one is followed from its own peak
+		if err := forgetTrailingPeak(transfer.Id); err != nil {
+			return false, fmt.Errorf("trailingStopStrategy: %v", err)
+		}
+		return false, nil
+	}
+
+	var peak float64
+	err := updateState(func(state *State) error {
+		peak = state.TrailingPeaks[transfer.Id]
+		if peak == 0 || rateImprovement(peak, liveRate, settings).Sign() > 0 {
+			peak = liveRate
+			if state.TrailingPeaks == nil {
+				state.TrailingPeaks = map[uint64]float64{}
+			}
+			state.TrailingPeaks[transfer.Id] = peak
+		}
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("trailingStopStrategy: %v", err)
+	}
+
+	retracement := rateImprovement(liveRate, peak, settings).Float64() / peak * 100
+	log.Printf("|| TRAILING STOP || Transfer ID: %v | Peak: %v | Live Rate: %v | Retracement: %.3f%% of %v%% ||",
+		transfer.Id, peak, liveRate, retracement, settings.TrailingStop)
+	if retracement < settings.TrailingStop {
+		return false, nil
+	}
+	return marginStrategy{}.ShouldRebook(transfer, liveRate, settings)
+}
+
+// Drop the peak followed for the transfer once it got re-booked or the live rate fell below its booked rate
+func forgetTrailingPeak(transferId uint64) error {
+	state, err := loadState()
+	if err != nil || state.TrailingPeaks[transferId] == 0 {
+		return err
+	}
+	return updateState(func(state *State) error {
+		delete(state.TrailingPeaks, transferId)
+		return nil
+	})
+}
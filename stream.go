@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// kinds of the messages /stream pushes, the SSE event names
+const (
+	streamRate     = "rate"
+	streamDecision = "decision"
+)
+
+// messages buffered per subscriber, the ones a slow subscriber can't keep up with being dropped
+const streamBuffer = 16
+
+// how often /stream sends a comment so proxies don't close an idle stream
+var streamKeepAlive = 30 * time.Second
+
+// RateUpdate is pushed to /stream every time a tracked transfer's live rate is checked
+type RateUpdate struct {
+	Time           time.Time `json:"time"`
+	TransferId     uint64    `json:"transferId"`
+	SourceCurrency string    `json:"sourceCurrency"`
+	TargetCurrency string    `json:"targetCurrency"`
+	BookedRate     float64   `json:"bookedRate"`
+	LiveRate       float64   `json:"liveRate"`
+	Threshold      float64   `json:"threshold"`
+	Better         bool      `json:"better"`
+}
+
+type streamMessage struct {
+	kind string
+	data []byte
+}
+
+// subscribers of /stream
+var streamHub = struct {
+	sync.Mutex
+	subscribers map[chan streamMessage]bool
+}{subscribers: map[chan streamMessage]bool{}}
+
+func subscribeStream() chan streamMessage {
+	ch := make(chan streamMessage, streamBuffer)
+	streamHub.Lock()
+	streamHub.subscribers[ch] = true
+	streamHub.Unlock()
+	return ch
+}
+
+func unsubscribeStream(ch chan streamMessage) {
+	streamHub.Lock()
+	delete(streamHub.subscribers, ch)
+	streamHub.Unlock()
+}
+
+// Push v to every subscriber of /stream, never blocking the check on a slow one
+func publishStream(kind string, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("publishStream: %v", err)
+		return
+	}
+	streamHub.Lock()
+	defer streamHub.Unlock()
+	for ch := range streamHub.subscribers {
+		select {
+		case ch <- streamMessage{kind: kind, data: data}:
+		default:
+		}
+	}
+}
+
+// Server-Sent Events of the rate updates and check decisions, ?types=rate,decision picking some of them
+func streamHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	types := map[string]bool{streamRate: true, streamDecision: true}
+	if param := r.URL.Query().Get("types"); param != "" {
+		types = map[string]bool{}
+		for _, kind := range strings.Split(param, ",") {
+			types[strings.TrimSpace(kind)] = true
+		}
+	}
+
+	ch := subscribeStream()
+	defer unsubscribeStream(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case message := <-ch:
+			if !types[message.kind] {
+				continue
+			}
+			fmt.Fprintf(w, "event: %v\ndata: %s\n\n", message.kind, message.data)
+		}
+		flusher.Flush()
+	}
+}
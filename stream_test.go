@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bufio"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(streamHandler))
+	defer server.Close()
+
+	res, err := http.Get(server.URL + "?types=decision")
+	assert.NoError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+	reader := bufio.NewReader(res.Body)
+	line, _ := reader.ReadString('\n')
+	assert.Equal(t, ": connected\n", line, "subscribed once the stream starts")
+
+	publishStream(streamRate, RateUpdate{LiveRate: 101})
+	publishStream(streamDecision, Decision{Time: time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC), TransferId: 7, Action: checkActionNoAction})
+
+	var lines []string
+	for len(lines) < 2 {
+		line, err := reader.ReadString('\n')
+		assert.NoError(t, err)
+		if line = strings.TrimRight(line, "\n"); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	assert.Equal(t, "event: decision", lines[0], "rate updates filtered out")
+	assert.True(t, strings.HasPrefix(lines[1], `data: {"time":"2020-05-01T12:00:00Z","transferId":7,`))
+}
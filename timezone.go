@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// layout of the timestamps shown in notifications, the dashboard and the CLI
+const displayTimeLayout = "2006-01-02 15:04:05 MST"
+
+// The TIMEZONE timestamps are shown in, like Europe/Berlin
+func getTimezone() (*time.Location, error) {
+	loc, err := time.LoadLocation(timezoneVar)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value for TIMEZONE: %v", err)
+	}
+	return loc, nil
+}
+
+// The timezone of the setting name, like QUIET_HOURS_TZ, TIMEZONE when the setting is left empty
+func getTimezoneOr(name string, tz string) (*time.Location, error) {
+	if tz == "" {
+		return getTimezone()
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value for %v: %v", name, err)
+	}
+	return loc, nil
+}
+
+// Make the log timestamps, stamped in the local time, TIMEZONE ones
+func configureTimezone() error {
+	loc, err := getTimezone()
+	if err != nil {
+		return err
+	}
+	time.Local = loc
+	return nil
+}
+
+// The time in TIMEZONE, like 2024-05-01 05:00:00 CEST, UTC when TIMEZONE is invalid
+func formatTime(t time.Time) string {
+	loc, err := getTimezone()
+	if err != nil {
+		loc = time.UTC
+	}
+	return t.In(loc).Format(displayTimeLayout)
+}
+
+// The RFC 3339 time transferwise sends, like a rate lock expiry, in TIMEZONE, or as it is when it doesn't parse
+func formatExpiry(expiry string) string {
+	t, err := time.Parse(time.RFC3339, expiry)
+	if err != nil {
+		return expiry
+	}
+	return formatTime(t)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestFormatTime(t *testing.T) {
+	defer func(v string) { timezoneVar = v }(timezoneVar)
+	at := time.Date(2024, 5, 1, 3, 0, 0, 0, time.UTC)
+
+	timezoneVar = "UTC"
+	assert.Equal(t, "2024-05-01 03:00:00 UTC", formatTime(at))
+	assert.Equal(t, "2024-05-01 03:00:00 UTC", formatExpiry("2024-05-01T03:00:00Z"))
+
+	timezoneVar = "Europe/Berlin"
+	assert.Equal(t, "2024-05-01 05:00:00 CEST", formatTime(at))
+	assert.Equal(t, "2024-05-01 05:00:00 CEST", formatExpiry("2024-05-01T03:00:00.000Z"))
+	assert.Equal(t, "unknown", formatExpiry("unknown"))
+}
+
+func TestGetTimezoneOr(t *testing.T) {
+	defer func(v string) { timezoneVar = v }(timezoneVar)
+	timezoneVar = "Asia/Kolkata"
+
+	loc, err := getTimezoneOr("QUIET_HOURS_TZ", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "Asia/Kolkata", loc.String())
+
+	loc, err = getTimezoneOr("QUIET_HOURS_TZ", "Europe/London")
+	assert.NoError(t, err)
+	assert.Equal(t, "Europe/London", loc.String())
+
+	_, err = getTimezoneOr("QUIET_HOURS_TZ", "Mars/Olympus")
+	assert.EqualError(t, err, "invalid value for QUIET_HOURS_TZ: unknown time zone Mars/Olympus")
+
+	timezoneVar = "Mars/Olympus"
+	_, err = getTimezone()
+	assert.EqualError(t, err, "invalid value for TIMEZONE: unknown time zone Mars/Olympus")
+}
+
+func TestQuietHoursDefaultTimezone(t *testing.T) {
+	defer func(v, tz, q string) { timezoneVar, quietHoursTZVar, quietHoursVar = v, tz, q }(timezoneVar, quietHoursTZVar, quietHoursVar)
+	timezoneVar, quietHoursTZVar, quietHoursVar = "Asia/Kolkata", "", "23:00-07:00"
+
+	// 18:00 UTC is 23:30 in Kolkata
+	quiet, err := inQuietHours(time.Date(2024, 5, 1, 18, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.True(t, quiet)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"transferwisely/wise"
+)
+
+// READ_ONLY refuses every transfer-wise API call but GETs, whatever the token is allowed to do
+var errReadOnly = errors.New("read only mode: only GET calls to transferwise api are allowed")
+
+// TokenScope is what the API token turned out to be allowed to do on startup
+type TokenScope struct {
+	Read         bool `json:"read"`
+	Write        bool `json:"write"`
+	WriteChecked bool `json:"writeChecked"`
+}
+
+func isReadOnly() bool {
+	readOnly, _ := strconv.ParseBool(readOnlyVar)
+	return readOnly
+}
+
+// Check what the API token can do: listing the profiles needs read access, and creating a transfer out of an empty
+// body, which transferwise always rejects as invalid, tells a full access token (validation error) from a read only
+// one (403) without creating anything. The write check is skipped in READ_ONLY mode, as it isn't a GET
+func checkTokenScope() (scope TokenScope, err error) {
+	if _, err = getProfiles(); err != nil {
+		return scope, fmt.Errorf("checkTokenScope: %w", err)
+	}
+	scope.Read = true
+	if isReadOnly() {
+		return scope, nil
+	}
+
+	url := &url.URL{Host: hostVar, Scheme: "https", Path: transfersAPIPath}
+	_, err = callExternalAPI(http.MethodPost, url.String(), []byte("{}"), nil)
+	var apiErr *wise.APIError
+	switch {
+	case err == nil:
+		scope.Write = true
+	case errors.As(err, &apiErr) && (apiErr.Status == http.StatusUnauthorized || apiErr.Status == http.StatusForbidden):
+	case errors.As(err, &apiErr) && apiErr.Status < http.StatusInternalServerError:
+		scope.Write = true
+	default:
+		return scope, fmt.Errorf("checkTokenScope: %w", err)
+	}
+	scope.WriteChecked = true
+	return scope, nil
+}
+
+// Log what the API token can do, warning when it can't re-book outside of READ_ONLY mode
+func logTokenScope() error {
+	scope, err := checkTokenScope()
+	if err != nil {
+		return err
+	}
+	switch {
+	case isReadOnly():
+		log.Println("|| READ ONLY MODE, NO TRANSFER WILL BE CREATED, CANCELLED OR FUNDED || API token can read, write access not checked ||")
+	case scope.Write:
+		log.Println("|| API TOKEN HAS FULL ACCESS ||")
+	default:
+		log.Println("|| API TOKEN IS READ ONLY, RE-BOOKING WILL FAIL || Use a full access token or set READ_ONLY=true ||")
+	}
+	return nil
+}
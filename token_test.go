@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"transferwisely/mocks"
+	"transferwisely/wise"
+)
+
+func TestCheckTokenScope(t *testing.T) {
+	defer func(v string) { readOnlyVar = v }(readOnlyVar)
+
+	mockAPI := func(createStatus int) *[]string {
+		var methods []string
+		mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+			methods = append(methods, req.Method)
+			status, body := http.StatusOK, `[{"id": 1, "type": "personal"}]`
+			if strings.Contains(req.URL.String(), transfersAPIPath) {
+				status, body = createStatus, `{"errors": [{"code": "NOT_VALID", "message": "targetAccount is required"}]}`
+			}
+			return &http.Response{StatusCode: status, Body: ioutil.NopCloser(bytes.NewReader([]byte(body)))}, nil
+		}
+		return &methods
+	}
+
+	t.Run("full access token", func(t *testing.T) {
+		readOnlyVar = "false"
+		mockAPI(http.StatusUnprocessableEntity)
+		scope, err := checkTokenScope()
+		assert.NoError(t, err)
+		assert.Equal(t, TokenScope{Read: true, Write: true, WriteChecked: true}, scope)
+	})
+
+	t.Run("read only token", func(t *testing.T) {
+		readOnlyVar = "false"
+		mockAPI(http.StatusForbidden)
+		scope, err := checkTokenScope()
+		assert.NoError(t, err)
+		assert.Equal(t, TokenScope{Read: true, WriteChecked: true}, scope)
+	})
+
+	t.Run("read only mode skips the write check", func(t *testing.T) {
+		readOnlyVar = "true"
+		methods := mockAPI(http.StatusUnprocessableEntity)
+		scope, err := checkTokenScope()
+		assert.NoError(t, err)
+		assert.Equal(t, TokenScope{Read: true}, scope)
+		assert.Equal(t, []string{http.MethodGet}, *methods)
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		readOnlyVar = "false"
+		mocks.GetDoFunc = func(*http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusUnauthorized, Body: ioutil.NopCloser(bytes.NewReader([]byte("{}")))}, nil
+		}
+		_, err := checkTokenScope()
+		var apiErr *wise.APIError
+		assert.True(t, errors.As(err, &apiErr))
+		assert.Equal(t, http.StatusUnauthorized, apiErr.Status)
+	})
+}
+
+func TestCallExternalAPIReadOnly(t *testing.T) {
+	defer func(v string) { readOnlyVar = v }(readOnlyVar)
+	readOnlyVar = "true"
+
+	called := false
+	mocks.GetDoFunc = func(*http.Request) (*http.Response, error) {
+		called = true
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewReader([]byte("{}")))}, nil
+	}
+
+	for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodDelete} {
+		_, err := callExternalAPI(method, "https://example.com/v1/transfers", nil, nil)
+		assert.Equal(t, errReadOnly, err)
+	}
+	assert.False(t, called)
+
+	_, err := callExternalAPI(http.MethodGet, "https://example.com/v1/transfers", nil, nil)
+	assert.NoError(t, err)
+	assert.True(t, called)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+	"transferwisely/wise"
+)
+
+// where API tokens are generated
+const tokenSettingsURL = "https://wise.com/settings/api-tokens"
+
+// token invalid notification
+const (
+	tokenInvalidSubject = "Transferwise API token invalid, re-bookings stopped"
+	tokenInvalidText    = "Transferwise refused the API token with 401 Unauthorized, it has expired or been revoked.\n" +
+		"Error: %v\n\nNo transfer gets re-booked, cancelled or funded until it works again. Generate a new token at %v, " +
+		"set it as %v and restart the batch."
+)
+
+// errTokenInvalid matches the 401 responses of transferwise API, and the write calls refused after one
+var errTokenInvalid = wise.ErrTokenInvalid
+
+// accounts whose API token got a 401, by account, since when. Write calls are refused until a call succeeds again,
+// the GETs of the checks going on to tell when it does
+var invalidTokens = struct {
+	sync.Mutex
+	since map[string]time.Time
+}{since: map[string]time.Time{}}
+
+// Whether the API token of the account being checked got a 401 since its last successful call
+func isTokenInvalid() bool {
+	invalidTokens.Lock()
+	defer invalidTokens.Unlock()
+	_, ok := invalidTokens.since[getCurrentAccount()]
+	return ok
+}
+
+// Refuse the write calls of an invalid API token, as they can't succeed and may leave a re-booking half done
+func checkTokenValid(method string, url string) error {
+	if method == http.MethodGet || !isTokenInvalid() {
+		return nil
+	}
+	log.Printf("|| API TOKEN INVALID, REFUSED %v %v ||", method, url)
+	return fmt.Errorf("%w: refusing %v %v until it works again", errTokenInvalid, method, url)
+}
+
+// Track the API token's validity from the status code of a call, alerting once when it turns invalid
+func recordTokenResult(code int, err error, now time.Time) {
+	account := getCurrentAccount()
+	invalidTokens.Lock()
+	since, invalid := invalidTokens.since[account]
+	switch {
+	case code == http.StatusUnauthorized:
+		if invalid {
+			invalidTokens.Unlock()
+			return
+		}
+		invalidTokens.since[account] = now
+		invalidTokens.Unlock()
+
+		log.Printf("|| API TOKEN INVALID, WRITE CALLS STOPPED || Error: %v ||", err)
+		notify(Event{
+			Kind:        EventTokenInvalid,
+			Subject:     tokenInvalidSubject,
+			Text:        fmt.Sprintf(tokenInvalidText, err, tokenSettingsURL, currentTokenEnv()),
+			ActionLabel: "Generate a new token",
+			ActionURL:   tokenSettingsURL,
+		})
+	case err == nil && invalid:
+		delete(invalidTokens.since, account)
+		invalidTokens.Unlock()
+		log.Printf("|| API TOKEN VALID AGAIN || Invalid for: %v ||", now.Sub(since).Round(time.Second))
+	default:
+		invalidTokens.Unlock()
+	}
+}
+
+// The env variable holding the API token of the account being checked
+func currentTokenEnv() string {
+	name := getCurrentAccount()
+	if name == defaultAccount {
+		return "API_TOKEN"
+	}
+	config.RLock()
+	defer config.RUnlock()
+	return config.current.Accounts[name].TokenEnv
+}
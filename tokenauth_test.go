@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+	"transferwisely/mocks"
+)
+
+func resetInvalidTokens() {
+	invalidTokens.Lock()
+	invalidTokens.since = map[string]time.Time{}
+	invalidTokens.Unlock()
+}
+
+func TestInvalidToken(t *testing.T) {
+	defer func(limit, threshold string) { notifyRateLimitVar, circuitBreakerThresholdVar = limit, threshold }(notifyRateLimitVar, circuitBreakerThresholdVar)
+	notifyRateLimitVar, circuitBreakerThresholdVar = "0", "0"
+	defer resetInvalidTokens()
+	resetInvalidTokens()
+
+	fake := &fakeNotifier{}
+	defer func(n []Notifier) { Notifiers = n }(Notifiers)
+	Notifiers = []Notifier{fake}
+
+	status := http.StatusUnauthorized
+	var methods []string
+	mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+		methods = append(methods, req.Method)
+		body := `[]`
+		if status == http.StatusUnauthorized {
+			body = `{"error":"invalid_token","error_description":"Invalid token"}`
+		}
+		return &http.Response{StatusCode: status, Body: ioutil.NopCloser(strings.NewReader(body))}, nil
+	}
+
+	// a single alert, however many calls get a 401
+	for i := 0; i < 3; i++ {
+		_, err := getProfiles()
+		assert.True(t, errors.Is(err, errTokenInvalid))
+	}
+	assert.True(t, isTokenInvalid())
+	assert.Len(t, fake.events, 1)
+	assert.Equal(t, EventTokenInvalid, fake.events[0].Kind)
+	assert.Contains(t, fake.events[0].Text, "set it as API_TOKEN")
+	assert.Equal(t, tokenSettingsURL, fake.events[0].ActionURL)
+
+	// write calls are refused without calling transferwise, and their errors aren't notified again
+	methods = nil
+	_, err := cancelTransfer(1)
+	assert.True(t, errors.Is(err, errTokenInvalid))
+	assert.Empty(t, methods)
+	assert.False(t, mayHaveCreated(err))
+	notifyError("Re-booking transfer failed", err)
+	assert.Len(t, fake.events, 1)
+
+	// a successful call validates the token again
+	status = http.StatusOK
+	_, err = getProfiles()
+	assert.NoError(t, err)
+	assert.False(t, isTokenInvalid())
+}
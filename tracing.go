@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OTLP span kinds and status codes
+const (
+	spanKindInternal = 1
+	spanKindClient   = 3
+	spanStatusOk     = 1
+	spanStatusError  = 2
+)
+
+const otlpTracesPath = "/v1/traces"
+
+// Span is a single timed operation of a trace, a nil span being a no-op so tracing can be disabled
+type Span struct {
+	traceId      string
+	spanId       string
+	parentSpanId string
+	name         string
+	kind         int
+	start        time.Time
+	end          time.Time
+	attributes   map[string]interface{}
+	err          error
+	trace        *trace
+}
+
+// trace collects the spans of one check cycle until its root span ends
+type trace struct {
+	sync.Mutex
+	spans []*Span
+}
+
+// root span of the cycle currently running, API calls become its children
+var activeSpan = struct {
+	sync.Mutex
+	span *Span
+}{}
+
+// exporting must never go through the mockable transfer-wise Client
+var otlpClient = &http.Client{Timeout: 10 * time.Second}
+
+// Start the root span of a new trace, nil when tracing isn't configured
+func startRootSpan(name string) *Span {
+	if otlpEndpointVar == "" {
+		return nil
+	}
+
+	span := &Span{traceId: randomHex(16), spanId: randomHex(8), name: name, kind: spanKindInternal,
+		start: time.Now(), trace: &trace{}}
+	activeSpan.Lock()
+	activeSpan.span = span
+	activeSpan.Unlock()
+	return span
+}
+
+// Start a child span of the active root span, nil when there is none
+func startSpan(name string, kind int) *Span {
+	activeSpan.Lock()
+	parent := activeSpan.span
+	activeSpan.Unlock()
+	if parent == nil {
+		return nil
+	}
+
+	return &Span{traceId: parent.traceId, spanId: randomHex(8), parentSpanId: parent.spanId, name: name, kind: kind,
+		start: time.Now(), trace: parent.trace}
+}
+
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.trace.Lock()
+	if s.attributes == nil {
+		s.attributes = map[string]interface{}{}
+	}
+	s.attributes[key] = value
+	s.trace.Unlock()
+}
+
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.trace.Lock()
+	s.err = err
+	s.trace.Unlock()
+}
+
+// End the span, ending the root span exports the whole trace in the background
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+
+	s.trace.Lock()
+	s.end = time.Now()
+	s.trace.spans = append(s.trace.spans, s)
+	spans := s.trace.spans
+	s.trace.Unlock()
+
+	if s.parentSpanId != "" {
+		return
+	}
+	activeSpan.Lock()
+	if activeSpan.span == s {
+		activeSpan.span = nil
+	}
+	activeSpan.Unlock()
+
+	go func() {
+		if err := exportSpans(spans); err != nil {
+			log.Printf("tracing: %v", err)
+		}
+	}()
+}
+
+// Export the spans to the OTLP/HTTP endpoint using the JSON encoding
+func exportSpans(spans []*Span) error {
+	otlpSpans := make([]OTLPSpan, len(spans))
+	for i, s := range spans {
+		otlpSpans[i] = OTLPSpan{
+			TraceId:           s.traceId,
+			SpanId:            s.spanId,
+			ParentSpanId:      s.parentSpanId,
+			Name:              s.name,
+			Kind:              s.kind,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+			Attributes:        toOTLPAttributes(s.attributes),
+			Status:            OTLPStatus{Code: spanStatusOk},
+		}
+		if s.err != nil {
+			otlpSpans[i].Status = OTLPStatus{Code: spanStatusError, Message: s.err.Error()}
+		}
+	}
+
+	body, err := json.Marshal(OTLPTraces{ResourceSpans: []OTLPResourceSpans{{
+		Resource:   OTLPResource{Attributes: toOTLPAttributes(map[string]interface{}{"service.name": otlpServiceNameVar})},
+		ScopeSpans: []OTLPScopeSpans{{Scope: OTLPScope{Name: "transferwisely"}, Spans: otlpSpans}},
+	}}})
+	if err != nil {
+		return fmt.Errorf("error encoding spans: %v", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimRight(otlpEndpointVar, "/")+otlpTracesPath, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating OTLP request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range parseOTLPHeaders(otlpHeadersVar) {
+		req.Header.Set(key, value)
+	}
+
+	res, err := otlpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error exporting spans: %v", err)
+	}
+	_ = res.Body.Close()
+	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("error exporting spans: %v", res.StatusCode)
+	}
+	return nil
+}
+
+// Parse OTEL_EXPORTER_OTLP_HEADERS like key1=value1,key2=value2
+func parseOTLPHeaders(value string) map[string]string {
+	headers := map[string]string{}
+	for _, pair := range strings.Split(value, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) == 2 && strings.TrimSpace(kv[0]) != "" {
+			headers[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+		}
+	}
+	return headers
+}
+
+func toOTLPAttributes(attributes map[string]interface{}) []OTLPAttribute {
+	otlpAttributes := make([]OTLPAttribute, 0, len(attributes))
+	for key, value := range attributes {
+		var v OTLPValue
+		switch value := value.(type) {
+		case string:
+			v.StringValue = &value
+		case bool:
+			v.BoolValue = &value
+		case int:
+			i := strconv.Itoa(value)
+			v.IntValue = &i
+		case uint64:
+			i := strconv.FormatUint(value, 10)
+			v.IntValue = &i
+		case float64:
+			v.DoubleValue = &value
+		default:
+			s := fmt.Sprint(value)
+			v.StringValue = &s
+		}
+		otlpAttributes = append(otlpAttributes, OTLPAttribute{Key: key, Value: v})
+	}
+	return otlpAttributes
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+type OTLPTraces struct {
+	ResourceSpans []OTLPResourceSpans `json:"resourceSpans"`
+}
+
+type OTLPResourceSpans struct {
+	Resource   OTLPResource     `json:"resource"`
+	ScopeSpans []OTLPScopeSpans `json:"scopeSpans"`
+}
+
+type OTLPResource struct {
+	Attributes []OTLPAttribute `json:"attributes"`
+}
+
+type OTLPScopeSpans struct {
+	Scope OTLPScope  `json:"scope"`
+	Spans []OTLPSpan `json:"spans"`
+}
+
+type OTLPScope struct {
+	Name string `json:"name"`
+}
+
+type OTLPSpan struct {
+	TraceId           string          `json:"traceId"`
+	SpanId            string          `json:"spanId"`
+	ParentSpanId      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []OTLPAttribute `json:"attributes"`
+	Status            OTLPStatus      `json:"status"`
+}
+
+type OTLPAttribute struct {
+	Key   string    `json:"key"`
+	Value OTLPValue `json:"value"`
+}
+
+type OTLPValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+type OTLPStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
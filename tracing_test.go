@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSpansDisabledWithoutEndpoint(t *testing.T) {
+	defer func(v string) { otlpEndpointVar = v }(otlpEndpointVar)
+	otlpEndpointVar = ""
+
+	span := startRootSpan("checkAndProcess")
+	assert.Nil(t, span)
+	assert.Nil(t, startSpan("GET /v1/transfers", spanKindClient))
+
+	// a nil span is a no-op
+	span.SetAttribute("key", "value")
+	span.SetError(errors.New("error"))
+	span.End()
+}
+
+func TestSpansExportedOnRootEnd(t *testing.T) {
+	defer func(v, h string) { otlpEndpointVar, otlpHeadersVar = v, h }(otlpEndpointVar, otlpHeadersVar)
+
+	received := make(chan OTLPTraces, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, otlpTracesPath, r.URL.Path)
+		assert.Equal(t, "secret", r.Header.Get("X-Api-Key"))
+		var traces OTLPTraces
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&traces))
+		received <- traces
+	}))
+	defer server.Close()
+	otlpEndpointVar = server.URL
+	otlpHeadersVar = "X-Api-Key=secret"
+
+	root := startRootSpan("checkAndProcess")
+	root.SetAttribute("transfer.id", uint64(1))
+	child := startSpan("GET /v1/transfers", spanKindClient)
+	child.SetError(errors.New("error calling external api"))
+	child.End()
+	root.End()
+	assert.Nil(t, startSpan("GET /v1/rates", spanKindClient))
+
+	select {
+	case traces := <-received:
+		spans := traces.ResourceSpans[0].ScopeSpans[0].Spans
+		assert.Len(t, spans, 2)
+		assert.Equal(t, "GET /v1/transfers", spans[0].Name)
+		assert.Equal(t, spans[1].SpanId, spans[0].ParentSpanId)
+		assert.Equal(t, spans[1].TraceId, spans[0].TraceId)
+		assert.Len(t, spans[0].TraceId, 32)
+		assert.Equal(t, spanStatusError, spans[0].Status.Code)
+		assert.Equal(t, "checkAndProcess", spans[1].Name)
+		assert.Equal(t, spanStatusOk, spans[1].Status.Code)
+		assert.Equal(t, "transfer.id", spans[1].Attributes[0].Key)
+		assert.Equal(t, "1", *spans[1].Attributes[0].Value.IntValue)
+	case <-time.After(5 * time.Second):
+		t.Fatal("spans not exported")
+	}
+}
+
+func TestParseOTLPHeaders(t *testing.T) {
+	assert.Equal(t, map[string]string{"a": "1", "b": "2=3"}, parseOTLPHeaders("a=1, b=2=3,invalid"))
+	assert.Empty(t, parseOTLPHeaders(""))
+}
@@ -3,51 +3,160 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
-	"github.com/jordan-wright/email"
-	"github.com/mitchellh/mapstructure"
+	"io/ioutil"
 	"log"
 	"net/http"
-	"net/smtp"
 	"net/url"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"transferwisely/wise"
 )
 
-// transfer-wise api paths
-const (
+// transfer-wise api paths, overridable in API_PATHS
+var (
 	transfersAPIPath      = "v1/transfers"
 	quotesAPIPath         = "v2/quotes"
 	liveRateAPIPath       = "v1/rates"
 	cancelTransferAPIPath = "v1/transfers/{transferId}/cancel"
+	transferAPIPath       = "v1/transfers/{transferId}"
+	profilesAPIPath       = "v1/profiles"
+	balancesAPIPath       = "v4/profiles/{profileId}/balances"
+	fundTransferAPIPath   = "v3/profiles/{profileId}/transfers/{transferId}/payments"
+
+	profileTransfersAPIPath = "v3/profiles/{profileId}/transfers"
+	deliveryEstimateAPIPath = "v1/delivery-estimates/{transferId}"
+	recipientsAPIPath       = "v1/accounts"
+	currencyPairsAPIPath    = "v1/currency-pairs"
+	activitiesAPIPath       = "v1/profiles/{profileId}/activities"
+)
+
+// sandbox only simulation paths
+const (
+	simulateTransferAPIPath = "v1/simulation/transfers/{transferId}/{status}"
+	simulateTopUpAPIPath    = "v1/simulation/balance/topup"
+)
+
+// booked transfers are awaiting their payment
+const transferStatusBooked = "incoming_payment_waiting"
+
+// number of transfers fetched per page of the transfers list
+const transfersPageSize = 100
+
+// outcomes of a check cycle
+const (
+	checkActionNotDue        = "not-due"
+	checkActionNoAction      = "no-action"
+	checkActionRebookSkipped = "rebook-skipped"
+	checkActionDeferred      = "deferred"
+	checkActionRebooked      = "rebooked"
+	checkActionProposed      = "proposed"
+	checkActionError         = "error"
 )
 
 // transfer-wise hosts
 const (
-	hostProduction = "api.transferwise.com"
-	hostSandbox    = "api.sandbox.transferwise.tech"
+	hostProduction = wise.HostProduction
+	hostSandbox    = wise.HostSandbox
 )
 
 // fallback values for optional env variables
 const (
-	fallbackInterval = "1"
-	fallbackMargin   = "0"
+	fallbackInterval         = "1"
+	fallbackMargin           = "0"
+	fallbackStrategy         = strategyMargin
+	fallbackStateFile        = "transferwisely-state.json"
+	fallbackRebookCooldown   = "60"
+	fallbackMaxRebooksPerDay = "3"
+	fallbackMaxRebookChain   = "5"
+	fallbackChainCooldown    = "0"
+	fallbackFundFromBalance  = "false"
+	fallbackAutoRenew        = "false"
+	fallbackRenewBefore      = "120"
+	fallbackRenewTolerance   = "0"
+	fallbackNotifyRateLimit  = "10"
+	fallbackOTLPServiceName  = "transferwisely"
+	fallbackMovingAvgHours   = "24"
+	fallbackMinGain          = "0"
+	fallbackDirection        = directionHigher
+	fallbackAmountMode       = amountModeSource
+	fallbackCompareProviders = "false"
+	fallbackForecastHint     = "true"
+	fallbackReportThreshold  = "3"
+	fallbackLeaseName        = "transferwisely"
+	fallbackLeaseDuration    = "60"
+	fallbackShutdownTimeout  = "60"
+	fallbackNtfyServer       = "https://ntfy.sh"
+	fallbackNtfyPriority     = "3"
+	fallbackGotifyPriority   = "5"
+	fallbackApprovalMode     = "false"
+	fallbackExpiryAlert      = "120"
+	fallbackPushoverPriority = "0"
+	fallbackPushoverRetry    = "60"
+	fallbackPushoverExpire   = "3600"
+	fallbackAPIRateLimit     = "5"
+	fallbackAPIRateBurst     = "10"
+	fallbackTrackedStatuses  = transferStatusBooked
+	fallbackMonitorTransfers = "false"
+	fallbackReceipts         = "false"
+	fallbackTimezone         = "UTC"
+	fallbackOffInterval      = "60"
+	fallbackTLSMinVersion    = "1.2"
+	fallbackReadOnly         = "false"
+	fallbackDebugHTTP        = "false"
+	fallbackBreakerThreshold = "5"
+	fallbackBreakerBackoff   = "1"
+	fallbackCredentialsFile  = "transferwisely-credentials.enc"
+	fallbackTrailingStop     = "0.5"
+	fallbackPayOut           = "BANK_TRANSFER"
+	fallbackMarginDecay      = marginDecayNone
+	fallbackMarginDecayHours = "24"
+	fallbackMarginFloor      = "0"
+	fallbackRateDigestAt     = "08:00"
+	fallbackRateDigestOnly   = "false"
+	fallbackBalanceCheck     = "0"
+	fallbackRateDeviation    = "1"
+	fallbackQuoteSpread      = "0.5"
+	fallbackCheckWorkers     = "4"
+	fallbackDesktopNotify    = "false"
+	fallbackActivityInterval = "0"
+	fallbackQuoteRetry       = "5"
+	fallbackAdaptiveVol      = "0.02-0.2"
+	fallbackAdaptiveSamples  = "12"
+
+	fallbackMQTTTopicPrefix     = "transferwisely"
+	fallbackMQTTDiscoveryPrefix = "homeassistant"
+	fallbackEventTopicPrefix    = "transferwisely"
 )
 
-// SMTP mail server
+// fallback SMTP mail server
 const (
-	smtpHost = "smtp.gmail.com"
-	smtpPort = "587"
+	fallbackSMTPHost = "smtp.gmail.com"
+	fallbackSMTPPort = "587"
+	fallbackSMTPTLS  = smtpTLSStartTLS
+	fallbackSMTPAuth = smtpAuthPlain
+)
+
+// fallback mail provider
+const (
+	fallbackMailProvider   = mailProviderSMTP
+	fallbackMailgunAPIBase = "https://api.mailgun.net"
 )
 
 // other mail related constants
 const (
 	reminderMailSubject = "Reminder: Your transfer is about to expire"
-	reminderMailBody    = "<h4>&#128184; The following transfer is going to expire on <b>%v</b></h4>" +
-		"<ul> <li>Transfer ID: %v </li> <li> {%v} --> {%v} </li> <li> Booked Rate: %v </li> <li> Amount: %v %v </li> </ul>"
+	reminderText        = "The following transfer is going to expire on %v\n" +
+		"Transfer ID: %v\n{%v} --> {%v}\nBooked Rate: %v\nAmount: %v %v"
+	rateSummaryText     = "\n\nRates over the last %v days\nMin: %v | Max: %v | Avg: %v\n%v"
+	rebookedSubject     = "New transfer booked at a better rate"
+	renewedSubject      = "Transfer renewed before its rate lock expired"
+	rebookedText        = "Transfer ID: %v\n{%v} --> {%v}\nRate: %v (was %v)\nAmount: %v %v\nCancelled transfer ID: %v"
 	expiryPeriodInHours = 36
 )
 
@@ -55,19 +164,170 @@ const (
 const PRODUCTION = "production"
 const SANDBOX = "sandbox"
 
-// error messages
-const ErrNoCurrentTransferFound = "error: no current transfer found, please create a transfer before proceeding"
-const ErrEnvVarMissingOrInvalid = "error: make sure env variables ENV, API_TOKEN are both provided and are valid"
+// errors callers branch on with errors.Is, wrapped with the details of the failure. Transferwise API failures are
+// *wise.APIError
+var (
+	ErrNoTrackedTransfer      = errors.New("error: no current transfer found, please create a transfer before proceeding")
+	ErrEnvVarMissingOrInvalid = errors.New("error: make sure env variables ENV, API_TOKEN are both provided and are valid")
+	ErrProfileNotFound        = errors.New("error: profile not found")
+
+	// the quote's rate lock expired before the transfer booking it got created, matching a *wise.APIError QUOTE_EXPIRED
+	ErrQuoteExpired = wise.ErrQuoteExpired
+
+	// the quote a re-booking got doesn't beat the booked rate, the live rate it was triggered by being out of date or
+	// not what Wise quotes
+	ErrInsufficientImprovement = errors.New("insufficient rate improvement")
+
+	// the fee of the quote a re-booking got is beyond MAX_FEE, see feecap.go
+	ErrFeeTooHigh = errors.New("fee too high")
+
+	// every payment option of the quote came back disabled, as Wise does for some corridors off-hours, see
+	// deferQuoteRetry
+	ErrPaymentOptionsDisabled = errors.New("all payment options disabled")
+)
 
 // env vars
 var envVar = getEnv("ENV", "")
 var hostVar = getHost(envVar)
 var apiTokenVar = getEnv("API_TOKEN", "")
+var apiPathsVar = getEnv("API_PATHS", "")
 var marginVar = getEnv("MARGIN", fallbackMargin)
+var marginDecayVar = getEnv("MARGIN_DECAY", fallbackMarginDecay)
+var marginDecayHoursVar = getEnv("MARGIN_DECAY_HOURS", fallbackMarginDecayHours)
+var marginFloorVar = getEnv("MARGIN_FLOOR", fallbackMarginFloor)
 var intervalVar = getEnv("INTERVAL", fallbackInterval)
+var strategyVar = getEnv("STRATEGY", fallbackStrategy)
+var movingAverageHoursVar = getEnv("MOVING_AVERAGE_HOURS", fallbackMovingAvgHours)
+var trailingStopVar = getEnv("TRAILING_STOP", fallbackTrailingStop)
+var minGainVar = getEnv("MIN_GAIN", fallbackMinGain)
+var directionVar = getEnv("DIRECTION", fallbackDirection)
+var amountModeVar = getEnv("AMOUNT_MODE", fallbackAmountMode)
+var payInVar = getEnv("PAY_IN", "")
+var payOutVar = getEnv("PAY_OUT", fallbackPayOut)
+var checkWindowsVar = getEnv("CHECK_WINDOWS", "")
+var checkWindowsTZVar = getEnv("CHECK_WINDOWS_TZ", "")
+var offWindowIntervalVar = getEnv("OFF_WINDOW_INTERVAL", fallbackOffInterval)
+var adaptiveIntervalVar = getEnv("ADAPTIVE_INTERVAL", "")
+var adaptiveVolatilityVar = getEnv("ADAPTIVE_VOLATILITY", fallbackAdaptiveVol)
+var adaptiveSamplesVar = getEnv("ADAPTIVE_SAMPLES", fallbackAdaptiveSamples)
+var configFileVar = getEnv("CONFIG_FILE", "")
+var templateDirVar = getEnv("TEMPLATE_DIR", "")
 var toEmailVar = getEnv("TO_MAIL", "")
 var fromEmailVar = getEnv("FROM_MAIL", "")
 var mailPassVar = getEnv("MAIL_PASS", "")
+var smtpHostVar = getEnv("SMTP_HOST", fallbackSMTPHost)
+var smtpPortVar = getEnv("SMTP_PORT", fallbackSMTPPort)
+var smtpTLSVar = getEnv("SMTP_TLS", fallbackSMTPTLS)
+var smtpAuthVar = getEnv("SMTP_AUTH", fallbackSMTPAuth)
+var smtpUserVar = getEnv("SMTP_USER", "")
+var mailProviderVar = getEnv("MAIL_PROVIDER", fallbackMailProvider)
+var mailAPIKeyVar = getEnv("MAIL_API_KEY", "")
+var mailgunDomainVar = getEnv("MAILGUN_DOMAIN", "")
+var mailgunAPIBaseVar = getEnv("MAILGUN_API_BASE", fallbackMailgunAPIBase)
+var profileIdVar = getEnv("PROFILE_ID", "")
+var stateFileVar = getEnv("STATE_FILE", fallbackStateFile)
+var lockFileVar = getEnv("LOCK_FILE", "")
+var trackedStatusesVar = getEnv("TRACKED_STATUSES", fallbackTrackedStatuses)
+var monitorTransfersVar = getEnv("MONITOR_TRANSFERS", fallbackMonitorTransfers)
+var apiRateLimitVar = getEnv("API_RATE_LIMIT", fallbackAPIRateLimit)
+var apiRateBurstVar = getEnv("API_RATE_BURST", fallbackAPIRateBurst)
+var rebookCooldownVar = getEnv("REBOOK_COOLDOWN", fallbackRebookCooldown)
+var maxRebooksPerDayVar = getEnv("MAX_REBOOKS_PER_DAY", fallbackMaxRebooksPerDay)
+var maxRebookChainVar = getEnv("MAX_REBOOK_CHAIN", fallbackMaxRebookChain)
+var maxFeeVar = getEnv("MAX_FEE", "")
+var quoteRetryIntervalVar = getEnv("QUOTE_RETRY_INTERVAL", fallbackQuoteRetry)
+var rebookChainCooldownVar = getEnv("REBOOK_CHAIN_COOLDOWN", fallbackChainCooldown)
+var fundFromBalanceVar = getEnv("FUND_FROM_BALANCE", fallbackFundFromBalance)
+var startupReportNotifyVar = getEnv("STARTUP_REPORT_NOTIFY", "false")
+var autoRenewVar = getEnv("AUTO_RENEW", fallbackAutoRenew)
+var renewBeforeVar = getEnv("RENEW_BEFORE", fallbackRenewBefore)
+var renewToleranceVar = getEnv("RENEW_TOLERANCE", fallbackRenewTolerance)
+var expiryAlertVar = getEnv("EXPIRY_ALERT", fallbackExpiryAlert)
+var fundingRemindersVar = getEnv("FUNDING_REMINDERS", "")
+var receiptsVar = getEnv("RECEIPTS", fallbackReceipts)
+var receiptDirVar = getEnv("RECEIPT_DIR", "")
+var rateDigestVar = getEnv("RATE_DIGEST", "")
+var rateDigestAtVar = getEnv("RATE_DIGEST_AT", fallbackRateDigestAt)
+var rateDigestOnlyVar = getEnv("RATE_DIGEST_ONLY", fallbackRateDigestOnly)
+var balanceCheckIntervalVar = getEnv("BALANCE_CHECK_INTERVAL", fallbackBalanceCheck)
+var activityIntervalVar = getEnv("ACTIVITY_INTERVAL", fallbackActivityInterval)
+var rateSourcesVar = getEnv("RATE_SOURCES", "")
+var exchangeRateHostKeyVar = getEnv("EXCHANGERATE_HOST_KEY", "")
+var rateDeviationVar = getEnv("RATE_DEVIATION", fallbackRateDeviation)
+var quoteSpreadVar = getEnv("QUOTE_SPREAD", fallbackQuoteSpread)
+var checkWorkersVar = getEnv("CHECK_WORKERS", fallbackCheckWorkers)
+var referenceTemplateVar = getEnv("REFERENCE_TEMPLATE", "")
+var mqttBrokerVar = getEnv("MQTT_BROKER", "")
+var mqttUsernameVar = getEnv("MQTT_USERNAME", "")
+var mqttPasswordVar = getEnv("MQTT_PASSWORD", "")
+var mqttTopicPrefixVar = getEnv("MQTT_TOPIC_PREFIX", fallbackMQTTTopicPrefix)
+var mqttDiscoveryPrefixVar = getEnv("MQTT_DISCOVERY_PREFIX", fallbackMQTTDiscoveryPrefix)
+var natsURLVar = getEnv("NATS_URL", "")
+var kafkaRESTURLVar = getEnv("KAFKA_REST_URL", "")
+var eventTopicPrefixVar = getEnv("EVENT_TOPIC_PREFIX", fallbackEventTopicPrefix)
+var quietHoursVar = getEnv("QUIET_HOURS", "")
+var quietHoursTZVar = getEnv("QUIET_HOURS_TZ", "")
+var notifyRateLimitVar = getEnv("NOTIFY_RATE_LIMIT", fallbackNotifyRateLimit)
+var shutdownTimeoutVar = getEnv("SHUTDOWN_TIMEOUT", fallbackShutdownTimeout)
+var approvalModeVar = getEnv("APPROVAL_MODE", fallbackApprovalMode)
+var readOnlyVar = getEnv("READ_ONLY", fallbackReadOnly)
+var localeVar = getEnv("LOCALE", "")
+var timezoneVar = getEnv("TIMEZONE", getEnv("TZ", fallbackTimezone))
+var debugHTTPVar = getEnv("DEBUG_HTTP", fallbackDebugHTTP)
+var publicURLVar = getEnv("PUBLIC_URL", "")
+var compareProvidersVar = getEnv("COMPARE_PROVIDERS", fallbackCompareProviders)
+var forecastHintVar = getEnv("FORECAST_HINT", fallbackForecastHint)
+var controlAPITokenVar = getEnv("CONTROL_API_TOKEN", "")
+var sentryDSNVar = getEnv("SENTRY_DSN", "")
+var rollbarAccessTokenVar = getEnv("ROLLBAR_ACCESS_TOKEN", "")
+var errorReportThresholdVar = getEnv("ERROR_REPORT_THRESHOLD", fallbackReportThreshold)
+var circuitBreakerThresholdVar = getEnv("CIRCUIT_BREAKER_THRESHOLD", fallbackBreakerThreshold)
+var circuitBreakerBackoffVar = getEnv("CIRCUIT_BREAKER_BACKOFF", fallbackBreakerBackoff)
+var leaderElectionVar = getEnv("LEADER_ELECTION", "")
+var leaseNameVar = getEnv("LEASE_NAME", fallbackLeaseName)
+var leaseNamespaceVar = getEnv("LEASE_NAMESPACE", "")
+var leaseDurationVar = getEnv("LEASE_DURATION", fallbackLeaseDuration)
+var slackWebhookURLVar = getEnv("SLACK_WEBHOOK_URL", "")
+var teamsWebhookURLVar = getEnv("TEAMS_WEBHOOK_URL", "")
+var telegramBotTokenVar = getEnv("TELEGRAM_BOT_TOKEN", "")
+var telegramChatIdVar = getEnv("TELEGRAM_CHAT_ID", "")
+var webhookURLVar = getEnv("WEBHOOK_URL", "")
+var webhookSecretVar = getEnv("WEBHOOK_SECRET", "")
+var ntfyServerVar = getEnv("NTFY_SERVER", fallbackNtfyServer)
+var ntfyTopicVar = getEnv("NTFY_TOPIC", "")
+var ntfyTokenVar = getEnv("NTFY_TOKEN", "")
+var ntfyPriorityVar = getEnv("NTFY_PRIORITY", fallbackNtfyPriority)
+var gotifyURLVar = getEnv("GOTIFY_URL", "")
+var gotifyTokenVar = getEnv("GOTIFY_TOKEN", "")
+var gotifyPriorityVar = getEnv("GOTIFY_PRIORITY", fallbackGotifyPriority)
+var pushoverTokenVar = getEnv("PUSHOVER_TOKEN", "")
+var pushoverUserVar = getEnv("PUSHOVER_USER", "")
+var pushoverPriorityVar = getEnv("PUSHOVER_PRIORITY", fallbackPushoverPriority)
+var pushoverRetryVar = getEnv("PUSHOVER_RETRY", fallbackPushoverRetry)
+var pushoverExpireVar = getEnv("PUSHOVER_EXPIRE", fallbackPushoverExpire)
+var matrixHomeserverVar = getEnv("MATRIX_HOMESERVER", "")
+var matrixAccessTokenVar = getEnv("MATRIX_ACCESS_TOKEN", "")
+var matrixRoomIdVar = getEnv("MATRIX_ROOM_ID", "")
+var desktopNotifyVar = getEnv("DESKTOP_NOTIFY", fallbackDesktopNotify)
+var grafanaURLVar = getEnv("GRAFANA_URL", "")
+var grafanaAPIKeyVar = getEnv("GRAFANA_API_KEY", "")
+var grafanaDashboardUIDVar = getEnv("GRAFANA_DASHBOARD_UID", "")
+var otlpEndpointVar = getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+var otlpHeadersVar = getEnv("OTEL_EXPORTER_OTLP_HEADERS", "")
+var otlpServiceNameVar = getEnv("OTEL_SERVICE_NAME", fallbackOTLPServiceName)
+var proxyURLVar = getEnv("PROXY_URL", "")
+var tlsCAFileVar = getEnv("TLS_CA_FILE", "")
+var tlsMinVersionVar = getEnv("TLS_MIN_VERSION", fallbackTLSMinVersion)
+var vaultAddrVar = getEnv("VAULT_ADDR", "")
+var vaultTokenVar = getEnv("VAULT_TOKEN", "")
+var awsRegionVar = getEnv("AWS_REGION", os.Getenv("AWS_DEFAULT_REGION"))
+var awsAccessKeyIdVar = getEnv("AWS_ACCESS_KEY_ID", "")
+var awsSecretAccessKeyVar = getEnv("AWS_SECRET_ACCESS_KEY", "")
+var awsSessionTokenVar = getEnv("AWS_SESSION_TOKEN", "")
+var credentialsStoreVar = getEnv("CREDENTIALS_STORE", "")
+var credentialsFileVar = getEnv("CREDENTIALS_FILE", fallbackCredentialsFile)
+var credentialsPassphraseVar = getEnv("CREDENTIALS_PASSPHRASE", "")
+var auditLogVar = getEnv("AUDIT_LOG", "")
 
 // HTTPClient interface
 type HTTPClient interface {
@@ -82,174 +342,735 @@ func init() {
 	Client = &http.Client{Timeout: 10 * time.Second}
 }
 
+// The transferwise API client the batch calls through, built on every call from Client, hostVar and apiTokenVar so
+// tests swapping them keep working. Retries are left to the breaker and the rate limiter of callExternalAPI
+func newWiseClient() *wise.Client {
+	return wise.NewClient(apiTokenVar, wise.WithHost(hostVar), wise.WithHTTPClient(debugHTTPClient(Client)))
+}
+
+// Check the booked transfer against the live rate, unless paused, and evaluate the rate alerts, run by the scheduler
 func checkAndProcess() {
+	defer reportPanic("checkAndProcess")
+	if !isLeader() {
+		// followers stand by, the leader checks, re-books and notifies
+		recordCheck()
+		return
+	}
+	if isPaused() {
+		// still alive, just told not to check
+		recordCheck()
+		log.Println("|| PAUSED, CHECK SKIPPED ||")
+	} else {
+		forEachAccount(func() { checkNow() })
+	}
+	withDefaultAccount(func() {
+		evaluateAlerts(time.Now().UTC())
+		evaluateSourceRankings()
+	})
+}
+
+// Run a check cycle over the booked transfer of every pair, re-booking if needed, and report its outcome
+func runCheck() (check CheckResult) {
+	span := startRootSpan("checkAndProcess")
+	span.SetAttribute("account", getCurrentAccount())
+	defer span.End()
+
+	recordCheck()
+	defer func() {
+		recordCheckOutcome(check)
+	}()
 	if hostVar == "" || apiTokenVar == "" {
 		log.Println(ErrEnvVarMissingOrInvalid)
+		return CheckResult{Action: checkActionError, Error: ErrEnvVarMissingOrInvalid.Error()}
+	}
+
+	transfers, err := getBookedTransfers()
+	if err != nil {
+		log.Printf("checkAndProcess: %v", err)
+		span.SetError(err)
+		return CheckResult{Action: checkActionError, Error: fmt.Sprint(err)}
+	}
+	if len(transfers) == 1 {
+		return checkTransfer(transfers[0], span)
+	}
+	return summarizeChecks(checkPairs(transfers))
+}
+
+// Check the booked transfer of a pair against the live rate, re-booking it if needed
+func checkTransfer(transfer Transfer, span *Span) (check CheckResult) {
+	defer func() {
+		if err := recordDecision(check, time.Now().UTC()); err != nil {
+			log.Println(err)
+		}
+	}()
+	unlock := lockPair(pairKey(transfer.SourceCurrency, transfer.TargetCurrency))
+	defer unlock()
+
+	detailed, err := withQuoteDetail(transfer)
+	if errors.Is(err, ErrPaymentOptionsDisabled) {
+		now := time.Now().UTC()
+		if isQuoteRetryPending(pairKey(transfer.SourceCurrency, transfer.TargetCurrency), now) {
+			return CheckResult{Action: checkActionNotDue}
+		}
+		log.Printf("|| CHECK DEFERRED || Transfer ID: %v | {%v} --> {%v} | %v ||", transfer.Id, transfer.SourceCurrency,
+			transfer.TargetCurrency, err)
+		deferQuoteRetry(transfer, 0, err, now)
+		return CheckResult{Action: checkActionDeferred, Error: err.Error()}
+	}
+	transfer = detailed
+	if err != nil {
+		log.Printf("checkAndProcess: %v", err)
+		span.SetError(err)
+		return CheckResult{Action: checkActionError, Error: fmt.Sprint(err)}
+	}
+	check.Transfer = &transfer
+	span.SetAttribute("transfer.id", transfer.Id)
+	span.SetAttribute("transfer.pair", pairKey(transfer.SourceCurrency, transfer.TargetCurrency))
+	remindFunding(transfer, time.Now().UTC())
+	watchBalance(transfer, time.Now().UTC())
+	annotateExpiry(transfer, time.Now().UTC())
+
+	settings, err := getSettings(transfer)
+	if err != nil {
+		log.Printf("checkAndProcess: %v", err)
+		span.SetError(err)
+		check.Action, check.Error = checkActionError, err.Error()
+		return
+	}
+	interval, err := settings.pairInterval(pairKey(transfer.SourceCurrency, transfer.TargetCurrency), time.Now().UTC())
+	if err != nil {
+		log.Printf("checkAndProcess: %v", err)
+		span.SetError(err)
+		check.Action, check.Error = checkActionError, err.Error()
+		return
+	}
+	if !isCheckDue(pairKey(transfer.SourceCurrency, transfer.TargetCurrency), interval, time.Now().UTC()) {
+		check.Action = checkActionNotDue
 		return
 	}
 
-	result, transfer, liveRate, err := compareRates()
+	result, liveRate, err := compareRates(transfer, settings)
 	if err != nil {
 		log.Println(err)
+		span.SetError(err)
+		check.Action, check.Error = checkActionError, err.Error()
 		return
 	}
+	check.LiveRate = liveRate
+	span.SetAttribute("rate.booked", transfer.Rate)
+	span.SetAttribute("rate.live", liveRate)
+	recordTracked(transfer, liveRate, settings)
+	crossCheckRate(transfer.SourceCurrency, transfer.TargetCurrency, liveRate, time.Now().UTC())
+	subject, reason := rebookedSubject, rebookReasonBetterRate
 	if !result {
-		log.Printf("|| NO ACTION NEEDED, Live Rate: %v || Transfer ID: %v | {%v} --> {%v} | Booked Rate: %v | Amount: %v ||",
-			liveRate, transfer.Id, transfer.SourceCurrency, transfer.TargetCurrency, transfer.Rate, transfer.SourceAmount)
+		renew, err := shouldRenew(transfer, liveRate, time.Now().UTC())
+		if err != nil {
+			log.Println(err)
+		}
+		if !renew {
+			log.Printf("|| NO ACTION NEEDED, Live Rate: %v || Transfer ID: %v | {%v} --> {%v} | Booked Rate: %v | Amount: %v ||",
+				liveRate, transfer.Id, transfer.SourceCurrency, transfer.TargetCurrency, transfer.Rate, transfer.SourceAmount)
+			recordNoAction(transfer, liveRate)
+			alertImminentExpiry(transfer, time.Now().UTC())
+			check.Action = checkActionNoAction
+			return
+		}
+
+		log.Printf("|| RATE LOCK EXPIRING, RENEWING, Live Rate: %v || Transfer ID: %v | {%v} --> {%v} | Booked Rate: %v | Expires: %v ||",
+			liveRate, transfer.Id, transfer.SourceCurrency, transfer.TargetCurrency, transfer.Rate, transfer.RateExpirationTime)
+		subject, reason = renewedSubject, rebookReasonRenewal
+	}
+	check.Reason = reason
+
+	// the guardrails count the re-bookings of every pair, checked concurrently
+	rebookMutex.Lock()
+	defer rebookMutex.Unlock()
+	err = checkRebookAllowed(reason, time.Now().UTC())
+	if err == nil {
+		err = checkChainAllowed(transfer, reason, time.Now().UTC())
+	}
+	if err != nil {
+		log.Printf("|| REBOOK SKIPPED, Live Rate: %v || Transfer ID: %v | {%v} --> {%v} | Booked Rate: %v | %v ||",
+			liveRate, transfer.Id, transfer.SourceCurrency, transfer.TargetCurrency, transfer.Rate, err)
+		alertImminentExpiry(transfer, time.Now().UTC())
+		check.Action, check.Error = checkActionRebookSkipped, err.Error()
 		return
 	}
 
-	newTransfer, err := createTransfer(transfer)
-	if err != nil || !result {
+	if isReadOnly() {
+		log.Printf("|| READ ONLY MODE, REBOOK SKIPPED, Live Rate: %v || Transfer ID: %v | {%v} --> {%v} | Booked Rate: %v | Reason: %v ||",
+			liveRate, transfer.Id, transfer.SourceCurrency, transfer.TargetCurrency, transfer.Rate, reason)
+		alertImminentExpiry(transfer, time.Now().UTC())
+		check.Action, check.Error = checkActionRebookSkipped, errReadOnly.Error()
+		return
+	}
+
+	approvalMode, _ := strconv.ParseBool(approvalModeVar)
+	if approvalMode {
+		proposal, err := proposeRebook(transfer, settings, reason, time.Now().UTC())
+		if errors.Is(err, ErrPaymentOptionsDisabled) {
+			deferQuoteRetry(transfer, liveRate, err, time.Now().UTC())
+			check.Action, check.Error = checkActionDeferred, err.Error()
+			return
+		}
+		if errors.Is(err, ErrFeeTooHigh) {
+			log.Printf("|| REBOOK SKIPPED, Live Rate: %v || Transfer ID: %v | {%v} --> {%v} | Booked Rate: %v | %v ||",
+				liveRate, transfer.Id, transfer.SourceCurrency, transfer.TargetCurrency, transfer.Rate, err)
+			check.Action, check.Error = checkActionRebookSkipped, err.Error()
+			return
+		}
+		if err != nil {
+			log.Println(err)
+			span.SetError(err)
+			check.Action, check.Error = checkActionError, err.Error()
+			return
+		}
+		alertImminentExpiry(transfer, time.Now().UTC())
+		check.Action, check.Proposal = checkActionProposed, &proposal
+		return
+	}
+
+	// from here on the re-booking must run to completion, shutdown waits for it
+	if !beginOperation() {
+		log.Printf("|| SHUTTING DOWN, REBOOK SKIPPED || Transfer ID: %v | {%v} --> {%v} ||",
+			transfer.Id, transfer.SourceCurrency, transfer.TargetCurrency)
+		check.Action, check.Error = checkActionRebookSkipped, "shutting down"
+		return
+	}
+	defer endOperation()
+
+	newTransfer, err := createTransfer(transfer, settings, reason)
+	if errors.Is(err, ErrPaymentOptionsDisabled) {
+		deferQuoteRetry(transfer, liveRate, err, time.Now().UTC())
+		check.Action, check.Error = checkActionDeferred, err.Error()
+		return
+	}
+	if errors.Is(err, ErrInsufficientImprovement) || errors.Is(err, ErrFeeTooHigh) {
+		log.Printf("|| REBOOK SKIPPED, Live Rate: %v || Transfer ID: %v | {%v} --> {%v} | Booked Rate: %v | %v ||",
+			liveRate, transfer.Id, transfer.SourceCurrency, transfer.TargetCurrency, transfer.Rate, err)
+		check.Action, check.Error = checkActionRebookSkipped, err.Error()
+		return
+	}
+	if errors.Is(err, errTransferFunded) && newTransfer.Id != 0 {
 		log.Println(err)
+		span.SetError(err)
+		recordRebooking(transfer, newTransfer, reason, time.Now().UTC())
+		check.Action, check.Error = checkActionError, err.Error()
 		return
 	}
+	if err != nil {
+		log.Println(err)
+		span.SetError(err)
+		annotateQuoteExpired(transfer, err, time.Now().UTC())
+		notifyError("Re-booking transfer failed", err)
+		check.Action, check.Error = checkActionError, err.Error()
+		return
+	}
+	check.Action, check.NewTransfer = checkActionRebooked, &newTransfer
+	span.SetAttribute("transfer.rebooked_id", newTransfer.Id)
+
+	check.Funded, err = completeRebook(transfer, newTransfer, subject, reason, span)
+	if err != nil {
+		check.Error = err.Error()
+	}
+	return
+}
+
+// Record the re-booking in the guardrails and the rebook history, for every transfer it booked, kept or not
+func recordRebooking(transfer Transfer, newTransfer Transfer, reason string, now time.Time) {
+	if err := recordRebook(now); err != nil {
+		log.Println(err)
+	}
+	err := recordRebookHistory(RebookRecord{
+		Time:           now,
+		OldTransferId:  transfer.Id,
+		NewTransferId:  newTransfer.Id,
+		SourceCurrency: newTransfer.SourceCurrency,
+		TargetCurrency: newTransfer.TargetCurrency,
+		OldRate:        transfer.Rate,
+		NewRate:        newTransfer.Rate,
+		SourceAmount:   newTransfer.SourceAmount,
+		Reason:         reason,
+	})
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// Record, announce and fund a re-booking once the new transfer is created, reporting whether it got funded
+func completeRebook(transfer Transfer, newTransfer Transfer, subject string, reason string, span *Span) (funded bool, err error) {
+	now := time.Now().UTC()
+	recordRebooking(transfer, newTransfer, reason, now)
 
 	log.Printf("|| NEW TRANSFER BOOKED || Transfer ID: %v | {%v} --> {%v} | Rate: %v |  Amount: %v ||",
 		newTransfer.Id, newTransfer.SourceCurrency, newTransfer.TargetCurrency, newTransfer.Rate, newTransfer.SourceAmount)
+	annotateRebook(transfer, newTransfer, reason, now)
+	var comparison []ProviderQuote
+	if isComparingProviders() {
+		comparison, err = getProviderQuotes(newTransfer.SourceCurrency, newTransfer.TargetCurrency, newTransfer.SourceAmount)
+		if err != nil {
+			log.Printf("completeRebook: %v", err)
+		}
+	}
+	forecast := rateForecastHint(newTransfer.SourceCurrency, newTransfer.TargetCurrency, newTransfer.Rate)
+	rebookComparison := getRebookComparison(transfer, newTransfer)
+	summary, summaryText := notificationRateSummary(newTransfer.SourceCurrency, newTransfer.TargetCurrency)
+	notify(Event{
+		Kind:    EventRebooked,
+		Subject: subject,
+		Text: fmt.Sprintf(rebookedText, newTransfer.Id, newTransfer.SourceCurrency, newTransfer.TargetCurrency,
+			formatRate(newTransfer.Rate), formatRate(transfer.Rate), newTransfer.SourceCurrency, formatAmount(newTransfer.SourceAmount, newTransfer.SourceCurrency), transfer.Id) +
+			formatRebookComparison(rebookComparison) + formatComparison(newTransfer, comparison) +
+			formatCrossCheck(newTransfer.SourceCurrency, newTransfer.TargetCurrency) + formatForecast(forecast) + summaryText,
+		Data: RebookedMailData{OldTransfer: transfer, NewTransfer: newTransfer, Reason: reason, Comparison: comparison,
+			Forecast: forecast, Rebook: &rebookComparison, Summary: summary},
+		Pair:       pairKey(transfer.SourceCurrency, transfer.TargetCurrency),
+		TransferId: transfer.Id,
+	})
+	if err := moveMute(transfer.Id, newTransfer.Id); err != nil {
+		log.Printf("completeRebook: %v", err)
+	}
+	if err := forgetTrailingPeak(transfer.Id); err != nil {
+		log.Printf("completeRebook: %v", err)
+	}
+
+	fundFromBalance, _ := strconv.ParseBool(fundFromBalanceVar)
+	if !fundFromBalance {
+		return false, nil
+	}
+	err = fundTransferFromBalance(newTransfer)
+	if err != nil {
+		log.Println(err)
+		span.SetError(err)
+		notifyError("Funding transfer from balance failed", err)
+		return false, err
+	}
+	log.Printf("|| TRANSFER FUNDED FROM BALANCE || Transfer ID: %v | Amount: %v %v ||",
+		newTransfer.Id, newTransfer.SourceCurrency, newTransfer.SourceAmount)
+	return true, nil
 }
 
-// Send reminder mail in case the best quote is about to expire
-func sendExpiryReminderMail() {
+// Send reminder in case the best quote is about to expire
+func sendExpiryReminder() {
 	empty := Transfer{}
 	bookedTransfer, err := getBookedTransfer()
 	if err != nil || bookedTransfer == empty {
-		log.Printf("sendExpiryMail: %v", err)
+		log.Printf("sendExpiryReminder: %v", err)
+		return
 	}
 
 	quoteDetail, err := getDetailByQuoteId(bookedTransfer.QuoteUuid)
 	if err != nil {
-		log.Printf("sendExpiryMail: %v", err)
+		log.Printf("sendExpiryReminder: %v", err)
+		return
 	}
 
 	expiryTime, err := time.Parse(time.RFC3339, quoteDetail.RateExpirationTime)
 	if err != nil {
-		log.Printf("sendExpiryMail: %v", err)
+		log.Printf("sendExpiryReminder: %v", err)
+		return
 	}
 
 	if expiryTime.Sub(time.Now().UTC()).Hours() < expiryPeriodInHours {
-		body := fmt.Sprintf(
-			reminderMailBody,
-			expiryTime.Format("2006-01-02 15:04:05 UTC"),
-			bookedTransfer.Id,
-			bookedTransfer.SourceCurrency,
-			bookedTransfer.TargetCurrency,
-			bookedTransfer.Rate,
-			bookedTransfer.SourceCurrency,
-			bookedTransfer.SourceAmount,
-		)
-		err := sendMail(reminderMailSubject, []byte(body))
-		if err != nil {
-			log.Printf("sendExpiryMail: %v", err)
+		expiry := formatTime(expiryTime)
+		data := ReminderMailData{Transfer: bookedTransfer, Expiry: expiry}
+		text := fmt.Sprintf(reminderText, expiry, bookedTransfer.Id, bookedTransfer.SourceCurrency, bookedTransfer.TargetCurrency,
+			formatRate(bookedTransfer.Rate), bookedTransfer.SourceCurrency, formatAmount(bookedTransfer.SourceAmount, bookedTransfer.SourceCurrency))
+
+		summary, summaryText := notificationRateSummary(bookedTransfer.SourceCurrency, bookedTransfer.TargetCurrency)
+		data.Summary = summary
+		text += summaryText
+		if isForecastHintEnabled() {
+			liveRate, err := getLiveRate(bookedTransfer.SourceCurrency, bookedTransfer.TargetCurrency)
+			if err != nil {
+				log.Printf("sendExpiryReminder: %v", err)
+			} else {
+				data.Forecast = rateForecastHint(bookedTransfer.SourceCurrency, bookedTransfer.TargetCurrency, liveRate)
+				text += formatForecast(data.Forecast)
+			}
 		}
-	}
-	return
-}
 
-func compareRates() (result bool, bookedTransfer Transfer, currentRate float64, err error) {
-	empty := Transfer{}
-	bookedTransfer, err = getBookedTransfer()
-	if err != nil || bookedTransfer == empty {
-		return false, empty, 0, fmt.Errorf("compareRates: %v", err)
+		notify(Event{Kind: EventExpiryReminder, Subject: reminderMailSubject, Text: text, Data: data,
+			Pair: pairKey(bookedTransfer.SourceCurrency, bookedTransfer.TargetCurrency), TransferId: bookedTransfer.Id})
 	}
+}
 
+func compareRates(bookedTransfer Transfer, settings Settings) (result bool, currentRate float64, err error) {
 	liveRate, err := getLiveRate(bookedTransfer.SourceCurrency, bookedTransfer.TargetCurrency)
 	if err != nil || liveRate == 0 {
-		return false, empty, 0, fmt.Errorf("compareRates: %v", err)
+		return false, 0, fmt.Errorf("compareRates: %w", err)
 	}
 
-	marginRate, err := strconv.ParseFloat(marginVar, 64)
+	strategy, ok := strategies[settings.Strategy]
+	if !ok {
+		return false, liveRate, fmt.Errorf("compareRates: unknown strategy %v", settings.Strategy)
+	}
+	if margin := decayMargin(settings, bookedTransfer.RateExpirationTime, time.Now().UTC()); margin.Cmp(settings.Margin) != 0 {
+		log.Printf("|| MARGIN DECAYED || Transfer ID: %v | Expires: %v | Margin: %v, was %v ||",
+			bookedTransfer.Id, bookedTransfer.RateExpirationTime, margin, settings.Margin)
+		settings.Margin = margin
+	}
+	result, err = strategy.ShouldRebook(bookedTransfer, liveRate, settings)
 	if err != nil {
-		return false, empty, 0, fmt.Errorf("compareRates: %v", err)
+		return false, liveRate, fmt.Errorf("compareRates: %w", err)
 	}
-
-	bookedRate := bookedTransfer.Rate
-	if liveRate > bookedRate && (liveRate-bookedRate >= marginRate) {
-		return true, bookedTransfer, 0, nil
+	if gain := targetGain(bookedTransfer, liveRate, settings); result && gain.Cmp(settings.MinGain) < 0 {
+		log.Printf("|| GAIN BELOW MIN_GAIN, Live Rate: %v || Transfer ID: %v | {%v} --> {%v} | Gain: %v %v | Min Gain: %v ||",
+			liveRate, bookedTransfer.Id, bookedTransfer.SourceCurrency, bookedTransfer.TargetCurrency, gain,
+			bookedTransfer.TargetCurrency, settings.MinGain)
+		result = false
 	}
 
-	return false, bookedTransfer, liveRate, nil
+	return result, liveRate, nil
 }
 
 func getBookedTransfer() (Transfer, error) {
-	params := url.Values{"limit": {"3"}, "offset": {"0"}, "status": {"incoming_payment_waiting"}}
-	url := &url.URL{RawQuery: params.Encode(), Host: hostVar, Scheme: "https", Path: transfersAPIPath}
+	statuses, err := getTrackedStatuses()
+	if err != nil {
+		return Transfer{}, fmt.Errorf("getBookedTransfer: %w", err)
+	}
+	transfersList, err := listAllTransfers(strings.Join(statuses, ","))
+	if err != nil {
+		return Transfer{}, fmt.Errorf("getBookedTransfer: %w", err)
+	}
+	transfersList = withoutPinned(transfersList)
 
-	response, code, err := callExternalAPI(http.MethodGet, url.String(), nil)
-	if err != nil || code != http.StatusOK {
-		return Transfer{}, fmt.Errorf("error GET transfer list API: %v : %v", code, err)
+	if len(transfersList) == 0 {
+		return Transfer{}, ErrNoTrackedTransfer
 	}
 
-	var transfersList []Transfer
-	err = mapstructure.Decode(response, &transfersList)
+	return withQuoteDetail(findBestTransfer(transfersList))
+}
+
+// The transfer along with the amounts, profile and rate lock expiry of the quote it was booked under
+func withQuoteDetail(transfer Transfer) (Transfer, error) {
+	quoteDetail, err := getDetailByQuoteId(transfer.QuoteUuid)
 	if err != nil {
-		return Transfer{}, fmt.Errorf("error decoding response: %v", err)
+		return Transfer{}, fmt.Errorf("withQuoteDetail: %w", err)
 	}
+	transfer.SourceAmount = quoteDetail.SourceAmount
+	transfer.TargetAmount = quoteDetail.TargetAmount
+	transfer.Profile = quoteDetail.Profile
+	transfer.RateExpirationTime = quoteDetail.RateExpirationTime
 
-	if len(transfersList) == 0 {
-		return Transfer{}, fmt.Errorf(ErrNoCurrentTransferFound)
+	return transfer, nil
+}
+
+// List the most recent transfers, up to limit, in the given comma separated statuses, or in any status if empty,
+// of the configured profile, if any
+func listTransfers(status string, limit int) ([]Transfer, error) {
+	pageSize := limit
+	if pageSize > transfersPageSize {
+		pageSize = transfersPageSize
+	}
+	var transfers []Transfer
+	it := newTransferIterator(status, pageSize)
+	for len(transfers) < limit && it.Next() {
+		transfers = append(transfers, it.Transfer())
 	}
+	return transfers, it.Err()
+}
 
-	bookedTransfer := findBestTransfer(transfersList)
-	quoteDetail, err := getDetailByQuoteId(bookedTransfer.QuoteUuid)
+// List every transfer in the given comma separated statuses, or in any status if empty, of the configured profile, if any
+func listAllTransfers(status string) ([]Transfer, error) {
+	var transfers []Transfer
+	it := newTransferIterator(status, transfersPageSize)
+	for it.Next() {
+		transfers = append(transfers, it.Transfer())
+	}
+	return transfers, it.Err()
+}
+
+// TransferIterator walks the transfers list page by page, fetching a page only once the previous one is consumed
+type TransferIterator struct {
+	status   string
+	pageSize int
+	offset   int
+	page     []Transfer
+	lastPage bool
+	current  Transfer
+	err      error
+}
+
+func newTransferIterator(status string, pageSize int) *TransferIterator {
+	return &TransferIterator{status: status, pageSize: pageSize}
+}
+
+// Advance to the next transfer, false once every transfer was consumed or fetching a page failed
+func (it *TransferIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	if len(it.page) == 0 {
+		if it.lastPage {
+			return false
+		}
+		it.page, it.err = listTransfersPage(it.status, it.pageSize, it.offset)
+		if it.err != nil {
+			return false
+		}
+		it.offset += len(it.page)
+		it.lastPage = len(it.page) < it.pageSize
+		if len(it.page) == 0 {
+			return false
+		}
+	}
+	it.current, it.page = it.page[0], it.page[1:]
+	return true
+}
+
+func (it *TransferIterator) Transfer() Transfer {
+	return it.current
+}
+
+func (it *TransferIterator) Err() error {
+	return it.err
+}
+
+// whether the profile scoped transfers list turned out to be unavailable, falling back to the v1 one from then on
+var profileTransfers = struct {
+	sync.Mutex
+	unavailable bool
+}{}
+
+// List a page of transfers, scoped to the configured profile, if any, with the profile scoped v3 endpoint, as the
+// profile filter of the v1 one mixes the profiles of tokens having several
+func listTransfersPage(status string, limit int, offset int) ([]Transfer, error) {
+	params := url.Values{"limit": {strconv.Itoa(limit)}, "offset": {strconv.Itoa(offset)}}
+	if status != "" {
+		params.Set("status", status)
+	}
+	profileId, err := getConfiguredProfile()
 	if err != nil {
-		return Transfer{}, fmt.Errorf("getBookedTransfer: %v", err)
+		return nil, fmt.Errorf("listTransfers: %v", err)
+	}
+	if profileId == 0 {
+		return listTransfersPageV1(params)
 	}
-	bookedTransfer.SourceAmount = quoteDetail.SourceAmount
-	bookedTransfer.Profile = quoteDetail.Profile
 
-	return bookedTransfer, nil
+	profileTransfers.Lock()
+	unavailable := profileTransfers.unavailable
+	profileTransfers.Unlock()
+	if !unavailable {
+		transfersList, err := listProfileTransfersPage(profileId, params)
+		var apiErr *wise.APIError
+		if !errors.As(err, &apiErr) || apiErr.Status != http.StatusNotFound {
+			return transfersList, err
+		}
+		log.Printf("listTransfers: %v not available, falling back to %v", profileTransfersAPIPath, transfersAPIPath)
+		profileTransfers.Lock()
+		profileTransfers.unavailable = true
+		profileTransfers.Unlock()
+	}
+
+	params.Set("profile", strconv.FormatUint(profileId, 10))
+	return listTransfersPageV1(params)
+}
+
+func listProfileTransfersPage(profileId uint64, params url.Values) ([]Transfer, error) {
+	path := strings.Replace(profileTransfersAPIPath, "{profileId}", strconv.FormatUint(profileId, 10), 1)
+	url := &url.URL{RawQuery: params.Encode(), Host: hostVar, Scheme: "https", Path: path}
+
+	var transfersList []Transfer
+	_, err := callExternalAPI(http.MethodGet, url.String(), nil, &transfersList)
+	if err != nil {
+		return nil, fmt.Errorf("error GET profile transfer list API: %w", err)
+	}
+	for i := range transfersList {
+		if transfersList[i].Profile == 0 {
+			transfersList[i].Profile = profileId
+		}
+	}
+
+	return transfersList, nil
+}
+
+func listTransfersPageV1(params url.Values) ([]Transfer, error) {
+	url := &url.URL{RawQuery: params.Encode(), Host: hostVar, Scheme: "https", Path: transfersAPIPath}
+
+	var transfersList []Transfer
+	_, err := callExternalAPI(http.MethodGet, url.String(), nil, &transfersList)
+	if err != nil {
+		return nil, fmt.Errorf("error GET transfer list API: %w", err)
+	}
+
+	return transfersList, nil
 }
 
 func getLiveRate(source string, target string) (float64, error) {
+	liveRate, err := getLiveRateDetail(source, target)
+	if err != nil {
+		return 0, err
+	}
+
+	return liveRate.Rate, nil
+}
+
+func getLiveRateDetail(source string, target string) (LiveRate, error) {
 	params := url.Values{"source": {source}, "target": {target}}
 	url := &url.URL{RawQuery: params.Encode(), Host: hostVar, Scheme: "https", Path: liveRateAPIPath}
 
-	response, code, err := callExternalAPI(http.MethodGet, url.String(), nil)
-	if err != nil || code != http.StatusOK {
-		return 0, fmt.Errorf("error GET live rate API: %v : %v", code, err)
+	var liveRate []LiveRate
+	_, err := callExternalAPI(http.MethodGet, url.String(), nil, &liveRate)
+	if err != nil {
+		return LiveRate{}, fmt.Errorf("error GET live rate API: %w", err)
+	}
+	if len(liveRate) == 0 {
+		return LiveRate{}, fmt.Errorf("error: no live rate found for {%v} --> {%v}", source, target)
 	}
 
-	var liveRate []LiveRate
-	err = mapstructure.Decode(response, &liveRate)
+	return liveRate[0], nil
+}
+
+// Re-book the old transfer at the current rate. For a better rate, the quote must still beat the booked rate, or
+// ErrInsufficientImprovement is returned before anything gets booked
+func createTransfer(oldTransfer Transfer, settings Settings, reason string) (Transfer, error) {
+	quote, err := createRebookQuote(oldTransfer, settings)
 	if err != nil {
-		return 0, fmt.Errorf("error decoding live rate response: %v", err)
+		return Transfer{}, fmt.Errorf("createTransfer: %w", err)
+	}
+	if reason == rebookReasonBetterRate && quote.Rate > 0 && rateImprovement(oldTransfer.Rate, quote.Rate, settings).Sign() <= 0 {
+		return Transfer{}, fmt.Errorf("createTransfer: %w: quote %v at %v doesn't beat the booked rate %v",
+			ErrInsufficientImprovement, quote.Id, quote.Rate, oldTransfer.Rate)
+	}
+	if err := checkQuoteFee(quote, settings); err != nil {
+		return Transfer{}, fmt.Errorf("createTransfer: %w", err)
 	}
 
-	return liveRate[0].Rate, nil
+	return createTransferFromQuote(oldTransfer, quote)
 }
 
-func createTransfer(oldTransfer Transfer) (Transfer, error) {
-	quoteId, err := generateQuote(oldTransfer.SourceCurrency, oldTransfer.TargetCurrency, oldTransfer.SourceAmount, oldTransfer.Profile)
+// Quote re-booking the old transfer under its resolved profile, keeping either its source or its target amount
+func createRebookQuote(oldTransfer Transfer, settings Settings) (QuoteDetail, error) {
+	profile, err := resolveProfile(oldTransfer, settings.Profile)
 	if err != nil {
-		return Transfer{}, fmt.Errorf("createTransfer: %v", err)
+		return QuoteDetail{}, err
 	}
 
-	createRequest := CreateTransferRequest{
-		TargetAccount:         oldTransfer.TargetAccount,
-		QuoteUuid:             quoteId,
+	if settings.KeepTargetAmount {
+		targetAmount := oldTransfer.TargetAmount
+		if settings.TargetAmount.Sign() > 0 {
+			targetAmount = settings.TargetAmount
+		}
+		if targetAmount.Sign() <= 0 {
+			return QuoteDetail{}, fmt.Errorf("no target amount to keep for transfer %v", oldTransfer.Id)
+		}
+		quote, err := requestPaymentQuote(CreateQuoteRequest{SourceCurrency: oldTransfer.SourceCurrency,
+			TargetCurrency: oldTransfer.TargetCurrency, TargetAmount: targetAmount.Float64(), Profile: profile}, settings.PayIn, settings.PayOut)
+		if err != nil {
+			return QuoteDetail{}, err
+		}
+		if quote.Profile != profile {
+			return QuoteDetail{}, fmt.Errorf("quote %v created under profile %v, expected profile %v", quote.Id, quote.Profile, profile)
+		}
+		return quote, nil
+	}
+
+	sourceAmount := oldTransfer.SourceAmount
+	if settings.Amount.Sign() > 0 {
+		sourceAmount = settings.Amount
+	}
+
+	quote, err := requestPaymentQuote(CreateQuoteRequest{SourceCurrency: oldTransfer.SourceCurrency,
+		TargetCurrency: oldTransfer.TargetCurrency, SourceAmount: sourceAmount.Float64(), Profile: profile}, settings.PayIn, settings.PayOut)
+	if err != nil {
+		return QuoteDetail{}, err
+	}
+	if quote.Profile != profile {
+		return QuoteDetail{}, fmt.Errorf("quote %v created under profile %v, expected profile %v", quote.Id, quote.Profile, profile)
+	}
+	quote.SourceAmount = sourceAmount
+
+	return quote, nil
+}
+
+// Book the quote to the old transfer's recipient, or the one routed to in CONFIG_FILE, and cancel the old transfer,
+// journaling the re-booking in the state file first so a crash half way is reconciled on the next start instead of
+// leaving a duplicate. When the old transfer got funded meanwhile, the new transfer is returned along with
+// errTransferFunded
+func createTransferFromQuote(oldTransfer Transfer, quote QuoteDetail) (Transfer, error) {
+	if err := checkNotPinned(oldTransfer); err != nil {
+		return Transfer{}, fmt.Errorf("createTransferFromQuote: %w", err)
+	}
+	details, err := rebookDetails(oldTransfer, quote, time.Now().UTC())
+	if err != nil {
+		return Transfer{}, fmt.Errorf("createTransferFromQuote: %w", err)
+	}
+	originator, err := transferOriginator(oldTransfer)
+	if err != nil {
+		return Transfer{}, fmt.Errorf("createTransferFromQuote: %w", err)
+	}
+	if _, err = checkUnfunded(oldTransfer); err != nil {
+		return Transfer{}, fmt.Errorf("createTransferFromQuote: %w", err)
+	}
+
+	pending := PendingRebook{
 		CustomerTransactionId: uuid.New().String(),
-		Details:               oldTransfer.Details,
+		OldTransfer:           oldTransfer,
+		QuoteId:               quote.Id,
+		CreatedAt:             time.Now().UTC(),
+		Account:               getCurrentAccount(),
+	}
+	err = setPendingRebook(&pending)
+	if err != nil {
+		return Transfer{}, fmt.Errorf("createTransferFromQuote: %w", err)
 	}
-	request, _ := json.Marshal(createRequest)
 
-	url := &url.URL{Host: hostVar, Scheme: "https", Path: transfersAPIPath}
-	response, code, err := callExternalAPI(http.MethodPost, url.String(), request)
-	if err != nil || code != http.StatusOK {
-		return Transfer{}, fmt.Errorf("error POST create transfer API: %v : %v", code, err)
+	createRequest := CreateTransferRequest{
+		TargetAccount:         routeTargetAccount(oldTransfer),
+		QuoteUuid:             quote.Id,
+		CustomerTransactionId: pending.CustomerTransactionId,
+		Details:               details,
+		Originator:            originator,
+	}
+	newTransfer, err := postTransfer(createRequest, quote)
+	if err != nil {
+		// the transfer may still have been created, reconciling tells on the next start
+		if mayHaveCreated(err) {
+			notifyCreateUnknown(oldTransfer, pending.CustomerTransactionId, err)
+		}
+		return Transfer{}, err
 	}
 
-	var newTransfer Transfer
-	err = mapstructure.Decode(response, &newTransfer)
+	pending.NewTransferId = newTransfer.Id
+	err = setPendingRebook(&pending)
 	if err != nil {
-		return Transfer{}, fmt.Errorf("error decoding response: %v", err)
+		log.Printf("createTransferFromQuote: %v", err)
+	}
+
+	cancelErr := cancelReplacedTransfer(oldTransfer, newTransfer)
+	if cancelErr != nil {
+		log.Printf("Error deleting old transfer: %v", cancelErr)
+		if !errors.Is(cancelErr, errTransferFunded) {
+			notifyCancelOldFailed(oldTransfer, newTransfer, cancelErr)
+		}
+	}
+	err = setPendingRebook(nil)
+	if err != nil {
+		log.Printf("createTransferFromQuote: %v", err)
+	}
+	if errors.Is(cancelErr, errTransferFunded) {
+		// both transfers got booked, the caller still records the new one
+		notifyFundedDuringRebook(oldTransfer, newTransfer, cancelErr)
+		return newTransfer, fmt.Errorf("createTransferFromQuote: %w", cancelErr)
 	}
-	newTransfer.SourceAmount = oldTransfer.SourceAmount
 
-	cancelResult, err := cancelTransfer(oldTransfer.Id)
-	if !cancelResult || err != nil {
-		log.Println("Error deleting old transfer")
+	return newTransfer, nil
+}
+
+// Create a transfer booking the quote, filling in the amounts and profile of the quote
+func postTransfer(createRequest CreateTransferRequest, quote QuoteDetail) (Transfer, error) {
+	request, _ := json.Marshal(createRequest)
+
+	url := &url.URL{Host: hostVar, Scheme: "https", Path: transfersAPIPath}
+	var newTransfer Transfer
+	_, err := callExternalAPI(http.MethodPost, url.String(), request, &newTransfer)
+	if err != nil {
+		return Transfer{}, fmt.Errorf("error POST create transfer API: %w", err)
 	}
+	newTransfer.SourceAmount = quote.SourceAmount
+	newTransfer.TargetAmount = quote.TargetAmount
+	newTransfer.Profile = quote.Profile
 
 	return newTransfer, nil
 }
@@ -258,108 +1079,241 @@ func cancelTransfer(transferId uint64) (bool, error) {
 	path := strings.Replace(cancelTransferAPIPath, "{transferId}", strconv.FormatUint(transferId, 10), 1)
 
 	url := &url.URL{Host: hostVar, Scheme: "https", Path: path}
-	_, code, err := callExternalAPI(http.MethodPut, url.String(), nil)
-	if err != nil || code != http.StatusOK {
-		return false, fmt.Errorf("error PUT cancel transfer API: %v : %v", code, err)
+	_, err := callExternalAPI(http.MethodPut, url.String(), nil, nil)
+	if err != nil {
+		return false, fmt.Errorf("error PUT cancel transfer API: %w", err)
 	}
 
 	return true, nil
 }
 
-func generateQuote(source string, target string, sourceAmount float64, profile uint64) (string, error) {
-	quoteRequest := CreateQuoteRequest{
+func generateQuote(source string, target string, sourceAmount Decimal, profile uint64) (string, error) {
+	quote, err := generateQuoteDetail(source, target, sourceAmount, profile)
+	if err != nil {
+		return "", err
+	}
+
+	return quote.Id, nil
+}
+
+func generateQuoteDetail(source string, target string, sourceAmount Decimal, profile uint64) (QuoteDetail, error) {
+	return requestPaymentQuote(CreateQuoteRequest{
 		SourceCurrency: source,
 		TargetCurrency: target,
-		SourceAmount:   sourceAmount,
+		SourceAmount:   sourceAmount.Float64(),
 		Profile:        profile,
+	}, payInVar, payOutVar)
+}
+
+// Quote the source amount needed for the recipient to get exactly targetAmount
+func generateTargetQuoteDetail(source string, target string, targetAmount Decimal, profile uint64) (QuoteDetail, error) {
+	return requestPaymentQuote(CreateQuoteRequest{
+		SourceCurrency: source,
+		TargetCurrency: target,
+		TargetAmount:   targetAmount.Float64(),
+		Profile:        profile,
+	}, payInVar, payOutVar)
+}
+
+// Quote for paying in with payIn, any pay-in when empty, and out with payOut, PAY_OUT when empty, its amounts and fee
+// being the ones of the matching payment option
+func requestPaymentQuote(quoteRequest CreateQuoteRequest, payIn string, payOut string) (QuoteDetail, error) {
+	if payOut == "" {
+		payOut = payOutVar
+	}
+	quoteRequest.PreferredPayIn, quoteRequest.PayOut = payIn, payOut
+	quote, err := requestQuote(quoteRequest)
+	if err != nil {
+		return QuoteDetail{}, err
 	}
+	if allPaymentOptionsDisabled(quote) {
+		return QuoteDetail{}, fmt.Errorf("%w: quote %v for {%v} --> {%v}", ErrPaymentOptionsDisabled, quote.Id,
+			quote.SourceCurrency, quote.TargetCurrency)
+	}
+	applyPaymentOption(&quote, payIn, payOut)
+	return quote, nil
+}
 
+func requestQuote(quoteRequest CreateQuoteRequest) (QuoteDetail, error) {
+	// transferwise rejects amounts with more decimals than their currency has
+	if quoteRequest.SourceAmount > 0 {
+		quoteRequest.SourceAmount = roundAmount(decimalOf(quoteRequest.SourceAmount), quoteRequest.SourceCurrency).Float64()
+	}
+	if quoteRequest.TargetAmount > 0 {
+		quoteRequest.TargetAmount = roundAmount(decimalOf(quoteRequest.TargetAmount), quoteRequest.TargetCurrency).Float64()
+	}
 	request, _ := json.Marshal(quoteRequest)
 
 	url := &url.URL{Host: hostVar, Scheme: "https", Path: quotesAPIPath}
-	response, code, err := callExternalAPI(http.MethodPost, url.String(), request)
-	if err != nil || code != http.StatusOK {
-		return "", fmt.Errorf("error POST quote API: %v : %v", code, err)
-	}
-
 	var quote QuoteDetail
-	err = mapstructure.Decode(response, &quote)
+	_, err := callExternalAPI(http.MethodPost, url.String(), request, &quote)
 	if err != nil {
-		return "", fmt.Errorf("error decoding quote response: %v", err)
+		return QuoteDetail{}, fmt.Errorf("error POST quote API: %w", err)
 	}
+	recordQuoteSpread(quote, time.Now().UTC())
 
-	return quote.Id, nil
+	return quote, nil
 }
 
 func getDetailByQuoteId(quoteUuid string) (QuoteDetail, error) {
 	path := quotesAPIPath + "/" + quoteUuid
 	url := &url.URL{Host: hostVar, Scheme: "https", Path: path}
 
-	response, code, err := callExternalAPI(http.MethodGet, url.String(), nil)
-	if err != nil || code != http.StatusOK {
-		return QuoteDetail{}, fmt.Errorf("error GET quote detail API: %v : %v", code, err)
-	}
-
 	var quoteDetail QuoteDetail
-	err = mapstructure.Decode(response, &quoteDetail)
+	_, err := callExternalAPI(http.MethodGet, url.String(), nil, &quoteDetail)
 	if err != nil {
-		return QuoteDetail{}, fmt.Errorf("error decoding to quote detail: %v : %v", code, err)
+		return QuoteDetail{}, fmt.Errorf("error GET quote detail API: %w", err)
 	}
 
-	for _, paymentOption := range quoteDetail.PaymentOptions {
-		if !paymentOption.Disabled && paymentOption.PayOut == "BANK_TRANSFER" {
-			quoteDetail.SourceAmount = paymentOption.SourceAmount
-			break
-		}
+	// the quote knows the payout it was created for, the booked transfer being paid out that way
+	payIn, payOut := payInVar, payOutVar
+	if quoteDetail.PayOut != "" {
+		payOut = quoteDetail.PayOut
+	}
+	if quoteDetail.PreferredPayIn != "" {
+		payIn = quoteDetail.PreferredPayIn
+	}
+	applyPaymentOption(&quoteDetail, payIn, payOut)
+	// without an enabled payment option, the quote may not give its amounts at all
+	if quoteDetail.SourceAmount.Sign() == 0 && allPaymentOptionsDisabled(quoteDetail) {
+		return QuoteDetail{}, fmt.Errorf("%w: quote %v has no source amount", ErrPaymentOptionsDisabled, quoteUuid)
 	}
 
 	return quoteDetail, nil
 }
 
-func callExternalAPI(method string, url string, reqBody []byte) (response interface{}, code int, err error) {
+// The enabled payment option of the quote paying in with payIn, any pay-in when empty, and paying out with payOut
+func selectPaymentOption(quote QuoteDetail, payIn string, payOut string) (PaymentOptions, bool) {
+	for _, paymentOption := range quote.PaymentOptions {
+		if !paymentOption.Disabled && paymentOption.PayOut == payOut && (payIn == "" || paymentOption.PayIn == payIn) {
+			return paymentOption, true
+		}
+	}
+	return PaymentOptions{}, false
+}
+
+// Whether the quote came with payment options, all of them disabled
+func allPaymentOptionsDisabled(quote QuoteDetail) bool {
+	for _, paymentOption := range quote.PaymentOptions {
+		if !paymentOption.Disabled {
+			return false
+		}
+	}
+	return len(quote.PaymentOptions) > 0
+}
+
+// Whether method looks like a transferwise pay-in or payout method, e.g. BANK_TRANSFER, SWIFT or BALANCE
+func isPaymentMethod(method string) bool {
+	if method == "" {
+		return false
+	}
+	for _, c := range method {
+		if (c < 'A' || c > 'Z') && c != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// Take the amounts and fee of the quote from its payment option paying in with payIn and out with payOut, the quoted
+// ones staying if that's not an option
+func applyPaymentOption(quote *QuoteDetail, payIn string, payOut string) {
+	paymentOption, ok := selectPaymentOption(*quote, payIn, payOut)
+	if !ok {
+		return
+	}
+	quote.SourceAmount = paymentOption.SourceAmount
+	if paymentOption.TargetAmount.Sign() > 0 {
+		quote.TargetAmount = paymentOption.TargetAmount
+	}
+	quote.Fee = paymentOption.Fee.Total
+}
+
+// Call transfer-wise API decoding a 2xx JSON response into result, and any other response into a *wise.APIError
+func callExternalAPI(method string, url string, reqBody []byte, result interface{}) (code int, err error) {
+	if method != http.MethodGet && isReadOnly() {
+		log.Printf("|| READ ONLY MODE, REFUSED %v %v ||", method, url)
+		return http.StatusForbidden, errReadOnly
+	}
+	if err := checkTokenValid(method, url); err != nil {
+		return http.StatusUnauthorized, err
+	}
+	if err := allowAPICall(time.Now().UTC()); err != nil {
+		return http.StatusServiceUnavailable, err
+	}
 	req, err := http.NewRequest(method, url, bytes.NewReader(reqBody))
 	if err != nil {
-		return nil, http.StatusInternalServerError, fmt.Errorf("error creating external api request: %v", err)
+		return http.StatusInternalServerError, fmt.Errorf("error creating external api request: %v", err)
 	}
-	req.Header.Add("Authorization", "Bearer "+apiTokenVar)
-	req.Header.Add("Content-Type", "application/json")
-
-	res, err := Client.Do(req)
+	span := startSpan(req.Method+" "+req.URL.Path, spanKindClient)
+	span.SetAttribute("http.method", req.Method)
+	span.SetAttribute("http.url", req.URL.Scheme+"://"+req.URL.Host+req.URL.Path)
+	defer func() {
+		span.SetAttribute("http.status_code", code)
+		span.SetError(err)
+		span.End()
+	}()
+
+	waitForAPI()
+	res, err := newWiseClient().Do(req)
 	if err != nil {
-		return nil, http.StatusInternalServerError, fmt.Errorf("error calling external api: %v", err)
+		recordAPIResult(http.StatusInternalServerError, err, time.Now().UTC())
+		auditAPICall(method, url, 0, reqBody, nil, err, time.Now().UTC())
+		return http.StatusInternalServerError, fmt.Errorf("error calling external api: %v", err)
 	}
-	err = json.NewDecoder(res.Body).Decode(&response)
+	body, err := ioutil.ReadAll(res.Body)
+	_ = res.Body.Close()
 	if err != nil {
-		return nil, http.StatusInternalServerError, fmt.Errorf("error decoding json response: %v", err)
+		return http.StatusInternalServerError, fmt.Errorf("error reading external api response: %v", err)
 	}
+
 	code = res.StatusCode
-	_ = res.Body.Close()
+	recordAPIStatusCode(code)
+	if code < http.StatusOK || code >= http.StatusMultipleChoices {
+		apiErr := wise.NewAPIError(req, code, body)
+		recordAPIResult(code, apiErr, time.Now().UTC())
+		recordTokenResult(code, apiErr, time.Now().UTC())
+		auditAPICall(method, url, code, reqBody, body, apiErr, time.Now().UTC())
+		return code, apiErr
+	}
+	auditAPICall(method, url, code, reqBody, body, nil, time.Now().UTC())
+	recordAPIResult(code, nil, time.Now().UTC())
+	recordTokenResult(code, nil, time.Now().UTC())
+	recordSuccessfulAPICall()
 
-	return
+	if result == nil || len(body) == 0 {
+		return code, nil
+	}
+	// non JSON responses, like receipts, are returned as they are
+	if raw, ok := result.(*[]byte); ok {
+		*raw = body
+		return code, nil
+	}
+	err = json.Unmarshal(body, result)
+	if err != nil {
+		return code, fmt.Errorf("error decoding json response of %v %v: %v", req.Method, req.URL.Path, err)
+	}
+	return code, nil
 }
 
+// The transfer booked at the best rate in its pair's DIRECTION, the highest unless lower is better
 func findBestTransfer(transferList []Transfer) (bestTransfer Transfer) {
 	for i := range transferList {
-		if i == 0 || bestTransfer.Rate < transferList[i].Rate {
+		if i == 0 {
+			bestTransfer = transferList[i]
+			continue
+		}
+		settings, err := getSettings(transferList[i])
+		if err != nil {
+			log.Printf("findBestTransfer: %v", err)
+		}
+		if rateImprovement(bestTransfer.Rate, transferList[i].Rate, settings).Sign() > 0 {
 			bestTransfer = transferList[i]
 		}
 	}
 	return
 }
 
-func sendMail(subject string, body []byte) (err error) {
-	if toEmailVar == "" || fromEmailVar == "" || mailPassVar == "" {
-		return fmt.Errorf("error: env vars TO_MAIL, FROM_MAIL, MAIL_PASS not found")
-	}
-	e := email.NewEmail()
-	e.From = fmt.Sprintf(" Transferwisely <%s>", fromEmailVar)
-	e.To = []string{toEmailVar}
-	e.Subject = subject
-	e.HTML = body
-	err = e.Send(smtpHost+":"+smtpPort, smtp.PlainAuth("", fromEmailVar, mailPassVar, smtpHost))
-	return
-}
-
 func getHost(envVar string) string {
 	switch strings.ToLower(envVar) {
 	case SANDBOX:
@@ -379,16 +1333,35 @@ func getEnv(key, fallback string) string {
 	return fallback
 }
 
+// CheckResult is the outcome of a check cycle
+type CheckResult struct {
+	Action      string    `json:"action"`
+	Transfer    *Transfer `json:"transfer,omitempty"`
+	LiveRate    float64   `json:"liveRate,omitempty"`
+	Reason      string    `json:"reason,omitempty"`
+	Proposal    *Proposal `json:"proposal,omitempty"`
+	NewTransfer *Transfer `json:"newTransfer,omitempty"`
+	Funded      bool      `json:"funded"`
+	Error       string    `json:"error,omitempty"`
+
+	// outcome of each pair when several are tracked, the action and error above being the most significant of them
+	Pairs []CheckResult `json:"pairs,omitempty"`
+}
+
 type Transfer struct {
-	Id             uint64          `json:"id"`
-	Profile        uint64          `json:"profile"`
-	TargetAccount  uint64          `json:"targetAccount"`
-	SourceAmount   float64         `json:"sourceAmount"`
-	Rate           float64         `json:"rate"`
-	QuoteUuid      string          `json:"quote"`
-	SourceCurrency string          `json:"sourceCurrency"`
-	TargetCurrency string          `json:"targetCurrency"`
-	Details        TransferDetails `json:"details"`
+	Id                    uint64          `json:"id"`
+	Profile               uint64          `json:"profile"`
+	TargetAccount         uint64          `json:"targetAccount"`
+	SourceAmount          Decimal         `json:"sourceAmount"`
+	TargetAmount          Decimal         `json:"targetAmount"`
+	Rate                  float64         `json:"rate"`
+	QuoteUuid             string          `json:"quote"`
+	Status                string          `json:"status"`
+	CustomerTransactionId string          `json:"customerTransactionId"`
+	SourceCurrency        string          `json:"sourceCurrency"`
+	TargetCurrency        string          `json:"targetCurrency"`
+	Details               TransferDetails `json:"details"`
+	RateExpirationTime    string          `json:"-"`
 }
 
 type TransferDetails struct {
@@ -400,22 +1373,38 @@ type TransferDetails struct {
 type QuoteDetail struct {
 	Id                 string           `json:"id"`
 	Rate               float64          `json:"rate"`
-	SourceAmount       float64          `json:"sourceAmount"`
+	SourceAmount       Decimal          `json:"sourceAmount"`
+	TargetAmount       Decimal          `json:"targetAmount"`
 	SourceCurrency     string           `json:"sourceCurrency"`
 	TargetCurrency     string           `json:"targetCurrency"`
 	Profile            uint64           `json:"profile"`
 	RateExpirationTime string           `json:"rateExpirationTime"`
+	PayOut             string           `json:"payOut"`
+	PreferredPayIn     string           `json:"preferredPayIn"`
 	PaymentOptions     []PaymentOptions `json:"paymentOptions"`
+
+	// fee of the selected payment option, see applyPaymentOption
+	Fee Decimal `json:"-"`
 }
 
 type PaymentOptions struct {
-	Disabled     bool    `json:"disabled"`
-	PayOut       string  `json:"payOut"`
-	SourceAmount float64 `json:"sourceAmount"`
+	Disabled     bool             `json:"disabled"`
+	PayIn        string           `json:"payIn"`
+	PayOut       string           `json:"payOut"`
+	SourceAmount Decimal          `json:"sourceAmount"`
+	TargetAmount Decimal          `json:"targetAmount"`
+	Fee          PaymentOptionFee `json:"fee"`
+}
+
+type PaymentOptionFee struct {
+	Total Decimal `json:"total"`
 }
 
 type LiveRate struct {
-	Rate float64 `json:"rate"`
+	Rate   float64 `json:"rate"`
+	Source string  `json:"source"`
+	Target string  `json:"target"`
+	Time   string  `json:"time"`
 }
 
 type CreateTransferRequest struct {
@@ -423,11 +1412,15 @@ type CreateTransferRequest struct {
 	QuoteUuid             string          `json:"quoteUuid"`
 	CustomerTransactionId string          `json:"customerTransactionId"`
 	Details               TransferDetails `json:"details"`
+	Originator            *Originator     `json:"originator,omitempty"`
 }
 
 type CreateQuoteRequest struct {
 	SourceCurrency string  `json:"sourceCurrency"`
 	TargetCurrency string  `json:"targetCurrency"`
-	SourceAmount   float64 `json:"sourceAmount"`
+	SourceAmount   float64 `json:"sourceAmount,omitempty"`
+	TargetAmount   float64 `json:"targetAmount,omitempty"`
 	Profile        uint64  `json:"profile"`
+	PayOut         string  `json:"payOut,omitempty"`
+	PreferredPayIn string  `json:"preferredPayIn,omitempty"`
 }
@@ -4,6 +4,7 @@ import (
     "bytes"
     "encoding/json"
     "errors"
+    "fmt"
     "github.com/bxcodec/faker/v3"
     "github.com/stretchr/testify/assert"
     "io/ioutil"
@@ -79,7 +80,7 @@ func TestGenerateQuote(t *testing.T)  {
             }, nil
         }
 
-        qId, err := generateQuote("anything", "anything", 1, 1)
+        qId, err := generateQuote("anything", "anything", decimalOf(1), 1)
         assert.NotEmpty(t, qId)
         assert.Equal(t, q.Id, qId)
         assert.NoError(t, err)
@@ -98,7 +99,7 @@ func TestGenerateQuote(t *testing.T)  {
             }, nil
         }
 
-        qId, err := generateQuote("anything", "anything", 1, 1)
+        qId, err := generateQuote("anything", "anything", decimalOf(1), 1)
         assert.Empty(t, qId)
         assert.Error(t, err)
     })
@@ -143,3 +144,173 @@ func TestCancelTransfer(t *testing.T)  {
 }
 
 
+
+func TestListTransfers(t *testing.T)  {
+    // 250 transfers served in pages of at most the requested limit
+    var offsets []string
+    mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+        offsets = append(offsets, req.URL.Query().Get("offset"))
+        var offset, limit int
+        _, _ = fmt.Sscan(req.URL.Query().Get("offset"), &offset)
+        _, _ = fmt.Sscan(req.URL.Query().Get("limit"), &limit)
+        var page []Transfer
+        for id := offset; id < offset+limit && id < 250; id++ {
+            page = append(page, Transfer{Id: uint64(id)})
+        }
+        j, _ := json.Marshal(page)
+        return &http.Response{
+            StatusCode: http.StatusOK,
+            Body:       ioutil.NopCloser(bytes.NewReader(j)),
+        }, nil
+    }
+
+    t.Run("all pages", func(t *testing.T) {
+        offsets = nil
+        transfers, err := listAllTransfers(transferStatusBooked)
+        assert.NoError(t, err)
+        assert.Len(t, transfers, 250)
+        assert.Equal(t, uint64(249), transfers[249].Id)
+        assert.Equal(t, []string{"0", "100", "200"}, offsets)
+    })
+
+    t.Run("up to limit", func(t *testing.T) {
+        offsets = nil
+        transfers, err := listTransfers(transferStatusBooked, 120)
+        assert.NoError(t, err)
+        assert.Len(t, transfers, 120)
+        assert.Equal(t, []string{"0", "100"}, offsets)
+
+        offsets = nil
+        transfers, err = listTransfers(transferStatusBooked, 20)
+        assert.NoError(t, err)
+        assert.Len(t, transfers, 20)
+        assert.Equal(t, []string{"0"}, offsets)
+    })
+
+    t.Run("external api error", func(t *testing.T) {
+        mocks.GetDoFunc = func(*http.Request) (*http.Response, error) {
+            return &http.Response{
+                StatusCode: http.StatusInternalServerError,
+                Body:       ioutil.NopCloser(bytes.NewReader([]byte("{}"))),
+            }, nil
+        }
+
+        transfers, err := listAllTransfers(transferStatusBooked)
+        assert.Empty(t, transfers)
+        assert.Error(t, err)
+    })
+}
+
+func TestCreateRebookQuote(t *testing.T)  {
+    var requests []CreateQuoteRequest
+    var bodies []map[string]interface{}
+    mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+        data, _ := ioutil.ReadAll(req.Body)
+        var request CreateQuoteRequest
+        var body map[string]interface{}
+        _ = json.Unmarshal(data, &request)
+        _ = json.Unmarshal(data, &body)
+        requests, bodies = append(requests, request), append(bodies, body)
+        quote := QuoteDetail{Id: "quote", Profile: request.Profile, SourceAmount: decimalOf(1010), TargetAmount: decimalOf(request.TargetAmount),
+            PaymentOptions: []PaymentOptions{{PayOut: "BANK_TRANSFER", SourceAmount: decimalOf(1000)}}}
+        if request.SourceAmount > 0 {
+            quote.SourceAmount, quote.TargetAmount = decimalOf(request.SourceAmount), decimalOf(request.SourceAmount * 100)
+        }
+        j, _ := json.Marshal(quote)
+        return &http.Response{
+            StatusCode: http.StatusOK,
+            Body:       ioutil.NopCloser(bytes.NewReader(j)),
+        }, nil
+    }
+    transfer := Transfer{Id: 1, Profile: 7, SourceCurrency: "GBP", TargetCurrency: "INR", SourceAmount: decimalOf(900), TargetAmount: decimalOf(90000)}
+
+    t.Run("keeps the source amount", func(t *testing.T) {
+        requests, bodies = nil, nil
+        quote, err := createRebookQuote(transfer, Settings{})
+        assert.NoError(t, err)
+        assert.Equal(t, 900.0, requests[0].SourceAmount)
+        assert.NotContains(t, bodies[0], "targetAmount")
+        assert.Equal(t, decimalOf(900.0), quote.SourceAmount)
+    })
+
+    t.Run("keeps the target amount", func(t *testing.T) {
+        requests, bodies = nil, nil
+        quote, err := createRebookQuote(transfer, Settings{KeepTargetAmount: true})
+        assert.NoError(t, err)
+        assert.Equal(t, 90000.0, requests[0].TargetAmount)
+        assert.NotContains(t, bodies[0], "sourceAmount")
+        assert.Equal(t, decimalOf(1000.0), quote.SourceAmount, "paid by bank transfer")
+        assert.Equal(t, decimalOf(90000.0), quote.TargetAmount)
+    })
+
+    t.Run("fixed target amount", func(t *testing.T) {
+        requests, bodies = nil, nil
+        _, err := createRebookQuote(transfer, Settings{KeepTargetAmount: true, TargetAmount: decimalOf(50000)})
+        assert.NoError(t, err)
+        assert.Equal(t, 50000.0, requests[0].TargetAmount)
+    })
+
+    t.Run("no target amount to keep", func(t *testing.T) {
+        _, err := createRebookQuote(Transfer{Id: 1, Profile: 7}, Settings{KeepTargetAmount: true})
+        assert.Error(t, err)
+    })
+}
+
+func TestPaymentOptions(t *testing.T)  {
+    quote := QuoteDetail{SourceAmount: decimalOf(1000), TargetAmount: decimalOf(100000), PayOut: "SWIFT", PaymentOptions: []PaymentOptions{
+        {PayIn: "BANK_TRANSFER", PayOut: "BANK_TRANSFER", SourceAmount: decimalOf(1000), TargetAmount: decimalOf(99500), Fee: PaymentOptionFee{Total: decimalOf(5)}},
+        {PayIn: "BALANCE", PayOut: "SWIFT", SourceAmount: decimalOf(1000), TargetAmount: decimalOf(98000), Fee: PaymentOptionFee{Total: decimalOf(20)}, Disabled: true},
+        {PayIn: "BANK_TRANSFER", PayOut: "SWIFT", SourceAmount: decimalOf(1000), TargetAmount: decimalOf(97000), Fee: PaymentOptionFee{Total: decimalOf(30)}},
+    }}
+
+    t.Run("bank transfer payout", func(t *testing.T) {
+        q := quote
+        applyPaymentOption(&q, "", "BANK_TRANSFER")
+        assert.Equal(t, decimalOf(99500.0), q.TargetAmount)
+        assert.Equal(t, decimalOf(5.0), q.Fee)
+    })
+
+    t.Run("disabled options are skipped", func(t *testing.T) {
+        q := quote
+        applyPaymentOption(&q, "", "SWIFT")
+        assert.Equal(t, decimalOf(97000.0), q.TargetAmount)
+        assert.Equal(t, decimalOf(30.0), q.Fee)
+    })
+
+    t.Run("no matching option keeps the quoted amounts", func(t *testing.T) {
+        q := quote
+        applyPaymentOption(&q, "BALANCE", "SWIFT")
+        assert.Equal(t, decimalOf(100000.0), q.TargetAmount)
+        assert.Zero(t, q.Fee)
+    })
+
+    t.Run("the booked quote is read with its own payout", func(t *testing.T) {
+        j, _ := json.Marshal(quote)
+        mocks.GetDoFunc = func(*http.Request) (*http.Response, error) {
+            return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewReader(j))}, nil
+        }
+        q, err := getDetailByQuoteId("anything")
+        assert.NoError(t, err)
+        assert.Equal(t, decimalOf(97000.0), q.TargetAmount)
+    })
+
+    t.Run("rebooking quotes for the configured payout", func(t *testing.T) {
+        var request CreateQuoteRequest
+        mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+            data, _ := ioutil.ReadAll(req.Body)
+            _ = json.Unmarshal(data, &request)
+            q := quote
+            q.Profile = request.Profile
+            j, _ := json.Marshal(q)
+            return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewReader(j))}, nil
+        }
+        q, err := createRebookQuote(Transfer{Id: 1, Profile: 7, SourceAmount: decimalOf(1000)}, Settings{PayIn: "BANK_TRANSFER", PayOut: "SWIFT"})
+        assert.NoError(t, err)
+        assert.Equal(t, "SWIFT", request.PayOut)
+        assert.Equal(t, "BANK_TRANSFER", request.PreferredPayIn)
+        assert.Equal(t, decimalOf(30.0), q.Fee)
+    })
+
+    assert.True(t, isPaymentMethod("BANK_TRANSFER"))
+    assert.False(t, isPaymentMethod("swift"))
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// timeout of every outbound HTTP call
+const httpClientTimeout = 10 * time.Second
+
+// TLS_MIN_VERSION values
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// Route every outbound HTTP call through PROXY_URL, or the standard HTTPS_PROXY, HTTP_PROXY and NO_PROXY env variables,
+// trusting TLS_CA_FILE on top of the system CAs
+func configureHTTPClients() error {
+	transport, err := newHTTPTransport()
+	if err != nil {
+		return err
+	}
+	Client = &http.Client{Timeout: httpClientTimeout, Transport: transport}
+	otlpClient = &http.Client{Timeout: httpClientTimeout, Transport: transport}
+	return nil
+}
+
+func newHTTPTransport() (*http.Transport, error) {
+	proxy, err := getProxy()
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := getTLSConfig("")
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxy
+	transport.TLSClientConfig = tlsConfig
+	return transport, nil
+}
+
+func getProxy() (func(*http.Request) (*url.URL, error), error) {
+	if proxyURLVar == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+	proxyURL, err := url.Parse(proxyURLVar)
+	if err != nil || proxyURL.Host == "" {
+		return nil, fmt.Errorf("invalid value for PROXY_URL: %v", proxyURLVar)
+	}
+	if proxyURL.Scheme != "http" && proxyURL.Scheme != "https" {
+		return nil, fmt.Errorf("invalid value for PROXY_URL: unsupported scheme %v", proxyURL.Scheme)
+	}
+	return http.ProxyURL(proxyURL), nil
+}
+
+// TLS config for connections to serverName, an empty one letting the HTTP transport fill it in per request
+func getTLSConfig(serverName string) (*tls.Config, error) {
+	minVersion, ok := tlsVersions[tlsMinVersionVar]
+	if !ok {
+		return nil, fmt.Errorf("invalid value for TLS_MIN_VERSION: %v, must be 1.0, 1.1, 1.2 or 1.3", tlsMinVersionVar)
+	}
+	config := &tls.Config{ServerName: serverName, MinVersion: minVersion}
+	if tlsCAFileVar == "" {
+		return config, nil
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	pem, err := ioutil.ReadFile(tlsCAFileVar)
+	if err != nil {
+		return nil, fmt.Errorf("error reading TLS_CA_FILE: %v", err)
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("invalid value for TLS_CA_FILE: no PEM certificate found in %v", tlsCAFileVar)
+	}
+	config.RootCAs = pool
+	return config, nil
+}
+
+// Dial addr directly, or through an HTTP CONNECT tunnel when a proxy applies to it
+func dialThroughProxy(addr string) (net.Conn, error) {
+	proxy, err := getProxy()
+	if err != nil {
+		return nil, err
+	}
+	proxyURL, err := proxy(&http.Request{URL: &url.URL{Scheme: "https", Host: addr}})
+	if err != nil {
+		return nil, fmt.Errorf("error resolving proxy for %v: %v", addr, err)
+	}
+	if proxyURL == nil {
+		return net.DialTimeout("tcp", addr, httpClientTimeout)
+	}
+
+	proxyAddr := proxyURL.Host
+	if proxyURL.Port() == "" {
+		proxyAddr = net.JoinHostPort(proxyURL.Hostname(), map[string]string{"http": "80", "https": "443"}[proxyURL.Scheme])
+	}
+	conn, err := net.DialTimeout("tcp", proxyAddr, httpClientTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to proxy: %v", err)
+	}
+	if proxyURL.Scheme == "https" {
+		tlsConfig, err := getTLSConfig(proxyURL.Hostname())
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tls.Client(conn, tlsConfig)
+	}
+
+	header := "CONNECT " + addr + " HTTP/1.1\r\nHost: " + addr + "\r\n"
+	if user := proxyURL.User; user != nil {
+		password, _ := user.Password()
+		header += "Proxy-Authorization: Basic " + base64.StdEncoding.EncodeToString([]byte(user.Username()+":"+password)) + "\r\n"
+	}
+	_ = conn.SetDeadline(time.Now().Add(httpClientTimeout))
+	if _, err = conn.Write([]byte(header + "\r\n")); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error connecting through proxy: %v", err)
+	}
+	reader := bufio.NewReader(conn)
+	res, err := http.ReadResponse(reader, &http.Request{Method: http.MethodConnect})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error connecting through proxy: %v", err)
+	}
+	_ = res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("error connecting through proxy: %v", strings.TrimSpace(res.Status))
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return &bufferedConn{Conn: conn, reader: reader}, nil
+}
+
+// bufferedConn reads what the proxy response reader already buffered, like an eager SMTP greeting, before the connection
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// number of log lines the terminal dashboard keeps
+const tuiLogLines = 10
+
+// ANSI escape sequences
+const (
+	ansiClearScreen = "\033[H\033[2J"
+	ansiBold        = "\033[1m"
+	ansiReset       = "\033[0m"
+)
+
+// tuiModel is everything the terminal dashboard shows
+type tuiModel struct {
+	Rates     []LiveRate
+	Transfers []Transfer
+	Proposals []Proposal
+	Log       []string
+	Status    string
+	UpdatedAt time.Time
+}
+
+// tuiLog keeps the last log lines for the terminal dashboard, which owns the terminal
+type tuiLog struct {
+	sync.Mutex
+	lines []string
+}
+
+func (l *tuiLog) Write(p []byte) (int, error) {
+	l.Lock()
+	defer l.Unlock()
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		l.lines = append(l.lines, line)
+	}
+	if len(l.lines) > tuiLogLines {
+		l.lines = l.lines[len(l.lines)-tuiLogLines:]
+	}
+	return len(p), nil
+}
+
+func (l *tuiLog) Lines() []string {
+	l.Lock()
+	defer l.Unlock()
+	return append([]string(nil), l.lines...)
+}
+
+// Fetch the tracked transfers with their rate lock expiry, the live rates of their pairs, of the pairs in
+// CONFIG_FILE and of the rate alerts, and the pending proposals
+func loadTUIModel(now time.Time) (model tuiModel, err error) {
+	statuses, err := getTrackedStatuses()
+	if err != nil {
+		return model, err
+	}
+	model.Transfers, err = listAllTransfers(strings.Join(statuses, ","))
+	if err != nil {
+		return model, err
+	}
+
+	pairs := map[string]bool{}
+	for i, transfer := range model.Transfers {
+		pairs[pairKey(transfer.SourceCurrency, transfer.TargetCurrency)] = true
+		quote, err := getDetailByQuoteId(transfer.QuoteUuid)
+		if err != nil {
+			log.Printf("loadTUIModel: %v", err)
+			continue
+		}
+		model.Transfers[i].RateExpirationTime = quote.RateExpirationTime
+	}
+	for pair := range getConfig().Pairs {
+		pairs[strings.ToUpper(pair)] = true
+	}
+	state, err := loadState()
+	if err != nil {
+		return model, err
+	}
+	for _, alert := range state.Alerts {
+		pairs[pairKey(alert.Source, alert.Target)] = true
+	}
+
+	sorted := make([]string, 0, len(pairs))
+	for pair := range pairs {
+		sorted = append(sorted, pair)
+	}
+	sort.Strings(sorted)
+	for _, pair := range sorted {
+		source, target, err := splitPairKey(pair)
+		if err != nil {
+			continue
+		}
+		rate, err := getLiveRateDetail(source, target)
+		if err != nil {
+			log.Printf("loadTUIModel: %v", err)
+			continue
+		}
+		model.Rates = append(model.Rates, rate)
+	}
+
+	expireProposals(&state, now)
+	for _, proposal := range state.Proposals {
+		if proposal.Status == proposalPending {
+			model.Proposals = append(model.Proposals, proposal)
+		}
+	}
+	model.UpdatedAt = now
+	return model, nil
+}
+
+func renderTUI(w io.Writer, model tuiModel, now time.Time) {
+	fmt.Fprint(w, ansiClearScreen)
+	fmt.Fprintf(w, "%vtransferwisely%v  updated %v\n\n", ansiBold, ansiReset, model.UpdatedAt.Format("15:04:05"))
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "%vLIVE RATES%v\n", ansiBold, ansiReset)
+	for _, rate := range model.Rates {
+		fmt.Fprintf(tw, "{%v} --> {%v}\t%v\n", rate.Source, rate.Target, rate.Rate)
+	}
+	fmt.Fprintf(tw, "\n%vTRANSFERS%v\n", ansiBold, ansiReset)
+	for _, transfer := range model.Transfers {
+		fmt.Fprintf(tw, "%v\t{%v} --> {%v}\t%v\t%v\n", transfer.Id, transfer.SourceCurrency, transfer.TargetCurrency,
+			transfer.Rate, formatExpiryCountdown(transfer.RateExpirationTime, now))
+	}
+	if len(model.Proposals) > 0 {
+		fmt.Fprintf(tw, "\n%vPENDING PROPOSALS%v\n", ansiBold, ansiReset)
+		for _, proposal := range model.Proposals {
+			fmt.Fprintf(tw, "%v\ttransfer %v\t%v --> %v\texpires in %v\n", proposal.Id, proposal.Transfer.Id,
+				proposal.Transfer.Rate, proposal.Quote.Rate, proposal.ExpiresAt.Sub(now).Round(time.Second))
+		}
+	}
+	_ = tw.Flush()
+
+	fmt.Fprintf(w, "\n%vLOG%v\n", ansiBold, ansiReset)
+	for _, line := range model.Log {
+		fmt.Fprintln(w, line)
+	}
+	fmt.Fprintf(w, "\n%v\n[c] check  [a] approve latest proposal  [r] refresh  [q] quit\n", model.Status)
+}
+
+func formatExpiryCountdown(rateExpirationTime string, now time.Time) string {
+	expiry, err := time.Parse(time.RFC3339, rateExpirationTime)
+	if err != nil {
+		return "expiry unknown"
+	}
+	left := expiry.Sub(now)
+	if left <= 0 {
+		return "rate lock expired"
+	}
+	return "expires in " + left.Round(time.Second).String()
+}
+
+// Read single key presses without waiting for enter, using stty as there's no terminal support in the standard library
+func setTerminalRaw() (restore func(), err error) {
+	saved, err := stty("-g")
+	if err != nil {
+		return nil, err
+	}
+	if _, err = stty("cbreak", "-echo"); err != nil {
+		return nil, err
+	}
+	return func() { _, _ = stty(strings.TrimSpace(saved)) }, nil
+}
+
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+func runTUICommand(args []string) error {
+	flags := flag.NewFlagSet("tui", flag.ContinueOnError)
+	refresh := flags.Duration("refresh", 30*time.Second, "how often to refresh the rates and transfers")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := loadConfig(); err != nil {
+		return err
+	}
+
+	logs := &tuiLog{}
+	log.SetOutput(logs)
+	defer log.SetOutput(os.Stderr)
+
+	restore, err := setTerminalRaw()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "no terminal raw mode, press enter after each key")
+	} else {
+		defer restore()
+	}
+
+	keys := make(chan byte)
+	go func() {
+		buf := make([]byte, 1)
+		for {
+			if _, err := os.Stdin.Read(buf); err != nil {
+				close(keys)
+				return
+			}
+			keys <- buf[0]
+		}
+	}()
+
+	ticker := time.NewTicker(*refresh)
+	defer ticker.Stop()
+	model := tuiModel{Status: "Loading..."}
+	refreshModel := func() {
+		status := model.Status
+		loaded, err := loadTUIModel(time.Now().UTC())
+		if err != nil {
+			log.Printf("tui: %v", err)
+		} else {
+			model = loaded
+		}
+		model.Status = status
+	}
+	redraw := func() {
+		model.Log = logs.Lines()
+		renderTUI(os.Stdout, model, time.Now().UTC())
+	}
+
+	redraw()
+	refreshModel()
+	model.Status = ""
+	redraw()
+	countdown := time.NewTicker(time.Second)
+	defer countdown.Stop()
+	for {
+		select {
+		case <-countdown.C:
+			redraw()
+		case <-ticker.C:
+			refreshModel()
+			redraw()
+		case key, ok := <-keys:
+			if !ok {
+				return nil
+			}
+			switch key {
+			case 'q', 'Q':
+				fmt.Print(ansiClearScreen)
+				return nil
+			case 'c', 'C':
+				model.Status = "Running a check..."
+				redraw()
+				check := runCheck()
+				model.Status = "Check done: " + check.Action
+				if check.Error != "" {
+					model.Status += ", " + check.Error
+				}
+			case 'a', 'A':
+				if len(model.Proposals) == 0 {
+					model.Status = "No pending proposal to approve"
+					break
+				}
+				proposal := model.Proposals[len(model.Proposals)-1]
+				newTransfer, err := approveProposal(proposal.Id, time.Now().UTC())
+				if err != nil {
+					model.Status = "Approving failed: " + err.Error()
+				} else {
+					model.Status = fmt.Sprintf("Proposal %v approved, transfer %v booked at %v", proposal.Id, newTransfer.Id, newTransfer.Rate)
+				}
+			case 'r', 'R':
+				model.Status = ""
+			default:
+				continue
+			}
+			refreshModel()
+			redraw()
+		}
+	}
+}
+
+func init() {
+	registerCommand("tui", Command{
+		Usage: "tui [--refresh <duration>]                   interactive terminal dashboard",
+		Run:   runTUICommand,
+	})
+}
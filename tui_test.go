@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"transferwisely/mocks"
+)
+
+func TestTUILog(t *testing.T) {
+	logs := &tuiLog{}
+	for i := 0; i < tuiLogLines+2; i++ {
+		fmt.Fprintf(logs, "line %v\n", i)
+	}
+	lines := logs.Lines()
+	assert.Len(t, lines, tuiLogLines)
+	assert.Equal(t, "line 2", lines[0])
+}
+
+func TestFormatExpiryCountdown(t *testing.T) {
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	assert.Equal(t, "expires in 1h30m0s", formatExpiryCountdown("2023-01-01T13:30:00Z", now))
+	assert.Equal(t, "rate lock expired", formatExpiryCountdown("2023-01-01T11:00:00Z", now))
+	assert.Equal(t, "expiry unknown", formatExpiryCountdown("", now))
+}
+
+func TestLoadAndRenderTUI(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "transferwisely")
+	defer os.RemoveAll(dir)
+	defer func(file string) { stateFileVar = file }(stateFileVar)
+	stateFileVar = filepath.Join(dir, "state.json")
+
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	_ = updateState(func(state *State) error {
+		state.Alerts = []Alert{{Id: "alert", Source: "EUR", Target: "USD", Condition: alertAbove, Threshold: 1.1}}
+		state.Proposals = []Proposal{{Id: "proposal", Status: proposalPending, ExpiresAt: now.Add(time.Minute),
+			Transfer: Transfer{Id: 1, Rate: 0.69}, Quote: QuoteDetail{Rate: 0.7}}}
+		return nil
+	})
+
+	mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+		var body string
+		switch {
+		case strings.Contains(req.URL.String(), transfersAPIPath):
+			body = `[{"id": 1, "rate": 0.69, "sourceCurrency": "JPY", "targetCurrency": "INR", "quote": "quote-1"}]`
+		case strings.Contains(req.URL.String(), quotesAPIPath):
+			body = `{"rateExpirationTime": "2023-01-01T14:00:00Z"}`
+		case strings.Contains(req.URL.String(), "source=EUR"):
+			body = `[{"rate": 1.08, "source": "EUR", "target": "USD"}]`
+		default:
+			body = `[{"rate": 0.7, "source": "JPY", "target": "INR"}]`
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(body))}, nil
+	}
+
+	model, err := loadTUIModel(now)
+	assert.NoError(t, err)
+	assert.Len(t, model.Rates, 2)
+	assert.Equal(t, "EUR", model.Rates[0].Source)
+	assert.Equal(t, "2023-01-01T14:00:00Z", model.Transfers[0].RateExpirationTime)
+	assert.Len(t, model.Proposals, 1)
+
+	var buf bytes.Buffer
+	model.Log = []string{"|| NO ACTION NEEDED ||"}
+	renderTUI(&buf, model, now)
+	assert.Contains(t, buf.String(), "expires in 2h0m0s")
+	assert.Contains(t, buf.String(), "proposal")
+	assert.Contains(t, buf.String(), "|| NO ACTION NEEDED ||")
+}
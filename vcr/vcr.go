@@ -0,0 +1,235 @@
+// Package vcr records HTTP interactions, such as the transferwise API calls of the batch, to a sanitized cassette file
+// and replays them, for realistic regression tests and offline demos without network access or a real API token
+package vcr
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// what redacted values are replaced with in cassettes
+const Redacted = "[REDACTED]"
+
+// JSON keys whose values are redacted from cassettes, matched case insensitively within the key
+var secretKeys = []string{"token", "password", "secret", "authorization", "apikey"}
+
+// HTTPClient sends the requests, an *http.Client or a test double
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Cassette is the content of a cassette file, the recorded interactions in the order they happened
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a request and the response it got. Request headers are never recorded, so neither is the API token
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Request is recorded without its scheme and host, so a cassette replays against any host
+type Request struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	Body   string `json:"body,omitempty"`
+}
+
+type Response struct {
+	StatusCode  int    `json:"statusCode"`
+	ContentType string `json:"contentType,omitempty"`
+	Body        string `json:"body,omitempty"`
+}
+
+// Option customizes a Recorder
+type Option func(*Recorder)
+
+// WithRedact replaces the values, like the API token, wherever they appear in the recorded URLs and bodies
+func WithRedact(values ...string) Option {
+	return func(r *Recorder) {
+		for _, value := range values {
+			if value != "" {
+				r.redact = append(r.redact, value)
+			}
+		}
+	}
+}
+
+// Recorder is an HTTPClient that either records the interactions of the client it wraps to a cassette file, saved
+// after every interaction, or replays the interactions of a cassette file without any network access
+type Recorder struct {
+	sync.Mutex
+	file      string
+	next      HTTPClient
+	redact    []string
+	cassette  Cassette
+	replaying bool
+	replayed  []bool
+}
+
+// NewRecorder records the interactions of next to the cassette file, replacing any cassette already there
+func NewRecorder(file string, next HTTPClient, options ...Option) *Recorder {
+	r := &Recorder{file: file, next: next}
+	for _, option := range options {
+		option(r)
+	}
+	return r
+}
+
+// NewReplayer replays the interactions of the cassette file
+func NewReplayer(file string, options ...Option) (*Recorder, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading cassette: %v", err)
+	}
+	r := &Recorder{file: file, replaying: true}
+	if err := json.Unmarshal(data, &r.cassette); err != nil {
+		return nil, fmt.Errorf("error decoding cassette %v: %v", file, err)
+	}
+	r.replayed = make([]bool, len(r.cassette.Interactions))
+	for _, option := range options {
+		option(r)
+	}
+	return r, nil
+}
+
+func (r *Recorder) Do(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(req.Body); err != nil {
+			return nil, fmt.Errorf("error reading request body: %v", err)
+		}
+		_ = req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	request := Request{Method: req.Method, URL: r.sanitize(req.URL.RequestURI()), Body: r.sanitizeBody(body)}
+
+	if r.replaying {
+		return r.replay(req, request)
+	}
+	return r.record(req, request)
+}
+
+func (r *Recorder) record(req *http.Request, request Request) (*http.Response, error) {
+	res, err := r.next.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	_ = res.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("error reading response body: %v", err)
+	}
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	r.Lock()
+	defer r.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, Interaction{
+		Request:  request,
+		Response: Response{StatusCode: res.StatusCode, ContentType: res.Header.Get("Content-Type"), Body: r.sanitizeBody(body)},
+	})
+	data, _ := json.MarshalIndent(r.cassette, "", "  ")
+	if err := ioutil.WriteFile(r.file, data, 0600); err != nil {
+		return nil, fmt.Errorf("error saving cassette: %v", err)
+	}
+	return res, nil
+}
+
+// Answer with the first interaction not replayed yet matching the method, URL and body of the request, or the last
+// matching one once all were replayed, so a demo can keep polling past the end of the cassette. Bodies carrying
+// values generated on every run, like the customerTransactionId of transfers, fall back to matching the method and URL
+func (r *Recorder) replay(req *http.Request, request Request) (*http.Response, error) {
+	r.Lock()
+	defer r.Unlock()
+	match := r.match(request, true)
+	if match < 0 {
+		match = r.match(request, false)
+	}
+	if match < 0 {
+		return nil, fmt.Errorf("no interaction of cassette %v matches %v %v", r.file, request.Method, request.URL)
+	}
+	r.replayed[match] = true
+
+	response := r.cassette.Interactions[match].Response
+	header := http.Header{}
+	if response.ContentType != "" {
+		header.Set("Content-Type", response.ContentType)
+	}
+	return &http.Response{
+		Status:     fmt.Sprintf("%d %s", response.StatusCode, http.StatusText(response.StatusCode)),
+		StatusCode: response.StatusCode,
+		Header:     header,
+		Body:       ioutil.NopCloser(strings.NewReader(response.Body)),
+		Request:    req,
+	}, nil
+}
+
+func (r *Recorder) match(request Request, matchBody bool) int {
+	match := -1
+	for i, interaction := range r.cassette.Interactions {
+		recorded := interaction.Request
+		if recorded.Method != request.Method || recorded.URL != request.URL || (matchBody && recorded.Body != request.Body) {
+			continue
+		}
+		match = i
+		if !r.replayed[i] {
+			break
+		}
+	}
+	return match
+}
+
+func (r *Recorder) sanitize(s string) string {
+	for _, value := range r.redact {
+		s = strings.Replace(s, value, Redacted, -1)
+	}
+	return s
+}
+
+// The body with the values of secret looking JSON keys and of the redacted values replaced, compacted when JSON
+func (r *Recorder) sanitizeBody(body []byte) string {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return ""
+	}
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return r.sanitize(string(body))
+	}
+	data, _ := json.Marshal(redactKeys(v))
+	return r.sanitize(string(data))
+}
+
+func redactKeys(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for key, field := range value {
+			if isSecretKey(key) {
+				value[key] = Redacted
+			} else {
+				value[key] = redactKeys(field)
+			}
+		}
+	case []interface{}:
+		for i := range value {
+			value[i] = redactKeys(value[i])
+		}
+	}
+	return v
+}
+
+func isSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, secretKey := range secretKeys {
+		if strings.Contains(key, secretKey) {
+			return true
+		}
+	}
+	return false
+}
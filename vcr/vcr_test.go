@@ -0,0 +1,73 @@
+package vcr
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type doFunc func(req *http.Request) (*http.Response, error)
+
+func (f doFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func get(t *testing.T, client HTTPClient, rawURL string) (int, string) {
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	assert.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer api-token")
+	res, err := client.Do(req)
+	if !assert.NoError(t, err) {
+		return 0, ""
+	}
+	body, _ := ioutil.ReadAll(res.Body)
+	return res.StatusCode, string(body)
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "transferwisely")
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "cassette.json")
+	rates := []string{`[{"rate": 100.2}]`, `[{"rate": 101}]`}
+	calls := 0
+	next := doFunc(func(req *http.Request) (*http.Response, error) {
+		body := `{"id": 1, "accessToken": "abc", "note": "api-token"}`
+		if strings.Contains(req.URL.Path, "rates") {
+			body, calls = rates[calls], calls+1
+		}
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Content-Type": {"application/json"}},
+			Body: ioutil.NopCloser(strings.NewReader(body))}, nil
+	})
+
+	recorder := NewRecorder(file, next, WithRedact("api-token"))
+	_, body := get(t, recorder, "https://api.sandbox.transferwise.tech/v1/rates?source=GBP")
+	assert.Equal(t, rates[0], body, "the recorded response is passed through")
+	get(t, recorder, "https://api.sandbox.transferwise.tech/v1/rates?source=GBP")
+	get(t, recorder, "https://api.sandbox.transferwise.tech/v1/profiles")
+
+	data, err := ioutil.ReadFile(file)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "api-token")
+	assert.NotContains(t, string(data), "abc")
+	assert.NotContains(t, string(data), "transferwise.tech", "cassettes replay against any host")
+
+	replayer, err := NewReplayer(file)
+	assert.NoError(t, err)
+	status, body := get(t, replayer, "https://localhost/v1/rates?source=GBP")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, `[{"rate":100.2}]`, body)
+	_, body = get(t, replayer, "https://localhost/v1/rates?source=GBP")
+	assert.Equal(t, `[{"rate":101}]`, body)
+	_, body = get(t, replayer, "https://localhost/v1/rates?source=GBP")
+	assert.Equal(t, `[{"rate":101}]`, body, "the last match is replayed past the end of the cassette")
+	_, body = get(t, replayer, "https://localhost/v1/profiles")
+	assert.JSONEq(t, `{"id": 1, "accessToken": "[REDACTED]", "note": "[REDACTED]"}`, body)
+
+	req, _ := http.NewRequest(http.MethodGet, "https://localhost/v1/transfers", nil)
+	_, err = replayer.Do(req)
+	assert.Error(t, err, "requests not recorded fail")
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const minutesPerDay = 24 * 60
+
+// weekdays as written in CHECK_WINDOWS
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// CheckWindow is a weekly range of days and time of the day during which a pair is checked every interval. A time
+// range spanning midnight, like 22:00-02:00, belongs to the day it starts on
+type CheckWindow struct {
+	FromDay time.Weekday
+	ToDay   time.Weekday
+	Start   int
+	End     int
+}
+
+// Interval the pair is checked at, at now: its interval within its check windows, its off window interval outside them
+func (s Settings) checkInterval(now time.Time) (uint64, error) {
+	if len(s.Windows) == 0 {
+		return s.Interval, nil
+	}
+	loc, err := getTimezoneOr("CHECK_WINDOWS_TZ", checkWindowsTZVar)
+	if err != nil {
+		return 0, err
+	}
+	if inCheckWindows(s.Windows, now.In(loc)) {
+		return s.Interval, nil
+	}
+	return s.OffWindowInterval, nil
+}
+
+func inCheckWindows(windows []CheckWindow, t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	for _, window := range windows {
+		if window.Start < window.End {
+			if window.hasDay(t.Weekday()) && minute >= window.Start && minute < window.End {
+				return true
+			}
+			continue
+		}
+		if (window.hasDay(t.Weekday()) && minute >= window.Start) || (window.hasDay((t.Weekday()+6)%7) && minute < window.End) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w CheckWindow) hasDay(day time.Weekday) bool {
+	if w.FromDay <= w.ToDay {
+		return day >= w.FromDay && day <= w.ToDay
+	}
+	return day >= w.FromDay || day <= w.ToDay
+}
+
+// Parse comma separated check windows like Mon-Fri 07:00-22:00,Sun 20:00-24:00, days or hours being optional
+func parseCheckWindows(spec string) ([]CheckWindow, error) {
+	var windows []CheckWindow
+	for _, part := range strings.Split(spec, ",") {
+		fields := strings.Fields(part)
+		if len(fields) == 0 {
+			continue
+		}
+		window := CheckWindow{FromDay: time.Sunday, ToDay: time.Saturday, Start: 0, End: minutesPerDay}
+		if len(fields) > 2 {
+			return nil, fmt.Errorf("invalid check window %v, expected e.g. Mon-Fri 07:00-22:00", strings.TrimSpace(part))
+		}
+		if !strings.Contains(fields[0], ":") {
+			var err error
+			if window.FromDay, window.ToDay, err = parseDays(fields[0]); err != nil {
+				return nil, err
+			}
+			fields = fields[1:]
+		}
+		if len(fields) == 1 {
+			var err error
+			if window.Start, window.End, err = parseTimeRange(fields[0]); err != nil {
+				return nil, err
+			}
+		}
+		windows = append(windows, window)
+	}
+	return windows, nil
+}
+
+func parseDays(days string) (from time.Weekday, to time.Weekday, err error) {
+	bounds := strings.Split(strings.ToLower(days), "-")
+	if len(bounds) > 2 {
+		return 0, 0, fmt.Errorf("invalid check window days %v, expected e.g. Mon-Fri", days)
+	}
+	from, ok := weekdays[bounds[0]]
+	to = from
+	if len(bounds) == 2 {
+		var toOk bool
+		to, toOk = weekdays[bounds[1]]
+		ok = ok && toOk
+	}
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid check window days %v, expected e.g. Mon-Fri", days)
+	}
+	return from, to, nil
+}
+
+// Parse a time range like 07:00-22:00 into minutes of the day, 24:00 ending it at midnight
+func parseTimeRange(timeRange string) (start int, end int, err error) {
+	bounds := strings.Split(timeRange, "-")
+	if len(bounds) != 2 {
+		return 0, 0, fmt.Errorf("invalid check window hours %v, expected e.g. 07:00-22:00", timeRange)
+	}
+	if start, err = parseMinuteOfDay(bounds[0]); err == nil {
+		end, err = parseMinuteOfDay(bounds[1])
+	}
+	if err != nil || start == end || start == minutesPerDay {
+		return 0, 0, fmt.Errorf("invalid check window hours %v, expected e.g. 07:00-22:00", timeRange)
+	}
+	return start, end, nil
+}
+
+func parseMinuteOfDay(value string) (int, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("invalid time %v", value)
+	}
+	hours, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, err
+	}
+	minutes, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, err
+	}
+	minute := hours*60 + minutes
+	if hours < 0 || minutes < 0 || minutes > 59 || minute > minutesPerDay {
+		return 0, fmt.Errorf("invalid time %v", value)
+	}
+	return minute, nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestParseCheckWindows(t *testing.T) {
+	windows, err := parseCheckWindows("Mon-Fri 07:00-22:00, Sun 20:00-24:00,Fri-Mon, 23:00-01:30")
+	assert.NoError(t, err)
+	assert.Equal(t, []CheckWindow{
+		{FromDay: time.Monday, ToDay: time.Friday, Start: 7 * 60, End: 22 * 60},
+		{FromDay: time.Sunday, ToDay: time.Sunday, Start: 20 * 60, End: minutesPerDay},
+		{FromDay: time.Friday, ToDay: time.Monday, Start: 0, End: minutesPerDay},
+		{FromDay: time.Sunday, ToDay: time.Saturday, Start: 23 * 60, End: 90},
+	}, windows)
+
+	windows, err = parseCheckWindows("")
+	assert.NoError(t, err)
+	assert.Empty(t, windows)
+
+	for _, spec := range []string{"Weekdays", "Mon-Fri 7-22", "Mon-Fri 07:00-07:00", "Mon 24:00-02:00", "Mon 07:00-25:00",
+		"Mon Tue 07:00-22:00", "Mon-Tue-Wed"} {
+		_, err = parseCheckWindows(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestInCheckWindows(t *testing.T) {
+	windows, _ := parseCheckWindows("Mon-Fri 07:00-22:00,Fri 22:00-02:00")
+	// 2024-05-03 is a Friday
+	friday := time.Date(2024, 5, 3, 0, 0, 0, 0, time.UTC)
+
+	assert.True(t, inCheckWindows(windows, friday.Add(7*time.Hour)))
+	assert.False(t, inCheckWindows(windows, friday.Add(6*time.Hour+59*time.Minute)))
+	assert.True(t, inCheckWindows(windows, friday.Add(23*time.Hour)), "overnight window")
+	assert.True(t, inCheckWindows(windows, friday.Add(25*time.Hour)), "overnight window, past midnight")
+	assert.False(t, inCheckWindows(windows, friday.Add(26*time.Hour)), "saturday")
+	assert.False(t, inCheckWindows(windows, friday.Add(-22*time.Hour)), "thursday night isn't in the friday window")
+}
+
+func TestCheckInterval(t *testing.T) {
+	defer func(tz string) { checkWindowsTZVar = tz }(checkWindowsTZVar)
+	checkWindowsTZVar = "Asia/Tokyo"
+	windows, _ := parseCheckWindows("Mon-Fri")
+	settings := Settings{Interval: 5, Windows: windows, OffWindowInterval: 60}
+
+	// friday 20:00 UTC is already saturday in Tokyo
+	interval, err := settings.checkInterval(time.Date(2024, 5, 3, 20, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(60), interval)
+
+	interval, err = settings.checkInterval(time.Date(2024, 5, 3, 10, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(5), interval)
+
+	interval, err = Settings{Interval: 5, OffWindowInterval: 60}.checkInterval(time.Date(2024, 5, 4, 10, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(5), interval, "always checked without windows")
+
+	checkWindowsTZVar = "Mars/Olympus"
+	_, err = settings.checkInterval(time.Now())
+	assert.Error(t, err)
+}
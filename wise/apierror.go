@@ -0,0 +1,99 @@
+package wise
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// CodeQuoteExpired is the code of the errors of a quote whose rate lock expired
+const CodeQuoteExpired = "QUOTE_EXPIRED"
+
+// errors an *APIError matches with errors.Is
+var (
+	// the API token expired or got revoked, a 401
+	ErrTokenInvalid = errors.New("transferwise api token invalid")
+
+	// the quote's rate lock expired before the transfer booking it got created, a QUOTE_EXPIRED error
+	ErrQuoteExpired = errors.New("quote expired")
+)
+
+// APIError is returned for every non 2xx response of the transferwise API
+type APIError struct {
+	Status  int
+	Method  string
+	Path    string
+	Code    string
+	Message string
+	Errors  []APIErrorDetail
+}
+
+func (e *APIError) Error() string {
+	message := fmt.Sprintf("transferwise api error: %v %v: %v", e.Method, e.Path, e.Status)
+	if e.Code != "" {
+		message += " " + e.Code
+	}
+	if e.Message != "" {
+		message += ": " + e.Message
+	}
+	return message
+}
+
+// Is matches ErrTokenInvalid for a 401 and ErrQuoteExpired for a QUOTE_EXPIRED error
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrTokenInvalid:
+		return e.Status == http.StatusUnauthorized
+	case ErrQuoteExpired:
+		return e.Code == CodeQuoteExpired
+	}
+	return false
+}
+
+// APIErrorResponse covers both the validation and the oauth error bodies of the transferwise API
+type APIErrorResponse struct {
+	Errors           []APIErrorDetail `json:"errors"`
+	Error            string           `json:"error"`
+	ErrorDescription string           `json:"error_description"`
+	Message          string           `json:"message"`
+}
+
+type APIErrorDetail struct {
+	Code      string        `json:"code"`
+	Message   string        `json:"message"`
+	Path      string        `json:"path"`
+	Arguments []interface{} `json:"arguments"`
+}
+
+// NewAPIError parses the error body of the response to the request, keeping it as the message when it isn't JSON
+func NewAPIError(req *http.Request, status int, body []byte) *APIError {
+	apiErr := &APIError{Status: status, Method: req.Method, Path: req.URL.Path}
+
+	var response APIErrorResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		apiErr.Message = strings.TrimSpace(string(body))
+		return apiErr
+	}
+
+	apiErr.Errors = response.Errors
+	switch {
+	case len(response.Errors) > 0:
+		apiErr.Code = response.Errors[0].Code
+		messages := make([]string, len(response.Errors))
+		for i, detail := range response.Errors {
+			messages[i] = detail.Message
+			if detail.Path != "" {
+				messages[i] = detail.Path + ": " + detail.Message
+			}
+		}
+		apiErr.Message = strings.Join(messages, "; ")
+	case response.Error != "":
+		apiErr.Code = response.Error
+		apiErr.Message = response.ErrorDescription
+	default:
+		apiErr.Message = response.Message
+	}
+	return apiErr
+}
@@ -0,0 +1,39 @@
+package wise
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestNewAPIError(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPost, "https://"+HostSandbox+"/v3/profiles/1/quotes", nil)
+
+	apiErr := NewAPIError(req, http.StatusUnprocessableEntity,
+		[]byte(`{"errors":[{"code":"NOT_VALID","message":"Please specify a valid amount","path":"sourceAmount"}]}`))
+	assert.Equal(t, "NOT_VALID", apiErr.Code)
+	assert.Equal(t, "/v3/profiles/1/quotes", apiErr.Path)
+	assert.Equal(t, "sourceAmount: Please specify a valid amount", apiErr.Message)
+	assert.Len(t, apiErr.Errors, 1)
+	assert.Equal(t, "transferwise api error: POST /v3/profiles/1/quotes: 422 NOT_VALID: sourceAmount: Please specify a valid amount",
+		apiErr.Error())
+
+	apiErr = NewAPIError(req, http.StatusUnauthorized, []byte(`{"error":"invalid_token","error_description":"Invalid token"}`))
+	assert.Equal(t, "invalid_token", apiErr.Code)
+	assert.Equal(t, "Invalid token", apiErr.Message)
+
+	apiErr = NewAPIError(req, http.StatusBadGateway, []byte("<html>Bad Gateway</html>"))
+	assert.Equal(t, "<html>Bad Gateway</html>", apiErr.Message)
+}
+
+func TestAPIErrorIs(t *testing.T) {
+	expired := fmt.Errorf("createTransfer: %w", &APIError{Status: http.StatusUnprocessableEntity, Code: CodeQuoteExpired})
+	assert.True(t, errors.Is(expired, ErrQuoteExpired))
+	assert.False(t, errors.Is(expired, ErrTokenInvalid))
+
+	unauthorized := fmt.Errorf("getProfiles: %w", &APIError{Status: http.StatusUnauthorized, Code: "invalid_token"})
+	assert.True(t, errors.Is(unauthorized, ErrTokenInvalid))
+	assert.False(t, errors.Is(unauthorized, ErrQuoteExpired))
+}
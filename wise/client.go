@@ -0,0 +1,196 @@
+// Package wise is a client for the transferwise API, configured with functional options so library consumers and
+// tests can swap its transport, host, retries and user agent without touching any package level state
+package wise
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// transferwise hosts
+const (
+	HostProduction = "api.transferwise.com"
+	HostSandbox    = "api.sandbox.transferwise.tech"
+)
+
+// DefaultUserAgent is sent unless WithUserAgent sets another one
+const DefaultUserAgent = "transferwisely"
+
+// HTTPClient sends the requests, an *http.Client or a test double
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Client calls the transferwise API with a personal or business API token
+type Client struct {
+	token      string
+	host       string
+	httpClient HTTPClient
+	userAgent  string
+
+	// retries after the first attempt, and the wait before the first retry, doubling on each one
+	retries int
+	backoff time.Duration
+	sleep   func(time.Duration)
+}
+
+// Option customizes a Client built by NewClient
+type Option func(*Client)
+
+// WithHost calls another host than HostProduction, like HostSandbox or a mock server
+func WithHost(host string) Option {
+	return func(c *Client) { c.host = host }
+}
+
+// WithHTTPClient sends the requests through the HTTP client, e.g. to set timeouts, a proxy or a recording transport
+func WithHTTPClient(httpClient HTTPClient) Option {
+	return func(c *Client) { c.httpClient = httpClient }
+}
+
+// WithRetry retries the requests failing with a network error, 429 Too Many Requests or a 5xx status up to retries
+// times, waiting backoff before the first retry and twice as long before each next one
+func WithRetry(retries int, backoff time.Duration) Option {
+	return func(c *Client) { c.retries, c.backoff = retries, backoff }
+}
+
+// WithUserAgent sets the User-Agent header of the requests
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) { c.userAgent = userAgent }
+}
+
+// NewClient builds a client calling HostProduction over an http.Client with a 10 seconds timeout, without retries,
+// unless options say otherwise
+func NewClient(token string, options ...Option) *Client {
+	c := &Client{
+		token:      token,
+		host:       HostProduction,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		userAgent:  DefaultUserAgent,
+		sleep:      time.Sleep,
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// Host is the host the client calls
+func (c *Client) Host() string {
+	return c.host
+}
+
+// NewRequest builds a request to the path of the API, like v1/rates, with an optional JSON body
+func (c *Client) NewRequest(method string, path string, query url.Values, body []byte) (*http.Request, error) {
+	u := &url.URL{Scheme: "https", Host: c.host, Path: strings.TrimPrefix(path, "/"), RawQuery: query.Encode()}
+	return http.NewRequest(method, u.String(), bytes.NewReader(body))
+}
+
+// Do sends the request with the client's credentials and user agent, retrying it as WithRetry says. The response
+// of the last attempt is returned, whatever its status
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("User-Agent", c.userAgent)
+	if req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	backoff := c.backoff
+	for attempt := 0; ; attempt++ {
+		res, err := c.httpClient.Do(req)
+		if attempt >= c.retries || !isRetryable(res, err) {
+			return res, err
+		}
+		// the body of a request can only be read once, requests without GetBody aren't retried
+		if req.Body != nil && req.GetBody == nil {
+			return res, err
+		}
+		if res != nil {
+			_ = res.Body.Close()
+		}
+		c.sleep(backoff)
+		backoff *= 2
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("error rewinding request body: %v", err)
+			}
+			req.Body = body
+		}
+	}
+}
+
+func isRetryable(res *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= http.StatusInternalServerError
+}
+
+// Call sends a request to the path of the API, JSON encoding body unless it is nil and decoding the response into
+// result unless it is nil. A non 2xx response is returned as an *APIError
+func (c *Client) Call(method string, path string, query url.Values, body interface{}, result interface{}) error {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("error encoding request: %v", err)
+		}
+	}
+	req, err := c.NewRequest(method, path, query, data)
+	if err != nil {
+		return fmt.Errorf("error creating request: %v", err)
+	}
+	resBody, err := c.send(req, method, path)
+	if err != nil {
+		return err
+	}
+	if result == nil || len(resBody) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(resBody, result); err != nil {
+		return fmt.Errorf("error decoding response of %v %v: %v", method, path, err)
+	}
+	return nil
+}
+
+// Raw sends body as is to any path of the API, which may carry its own query string like
+// v3/profiles/1/transfers?status=processing, and returns the response body undecoded. It reaches the endpoints and
+// fields the typed calls don't know of yet. A non 2xx response is returned as an *APIError
+func (c *Client) Raw(ctx context.Context, method string, path string, body []byte) (json.RawMessage, error) {
+	u, err := url.Parse(strings.TrimPrefix(path, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid path %v: %v", path, err)
+	}
+	req, err := c.NewRequest(method, u.Path, u.Query(), body)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %v", err)
+	}
+	resBody, err := c.send(req.WithContext(ctx), method, path)
+	if err != nil {
+		return nil, err
+	}
+	return resBody, nil
+}
+
+func (c *Client) send(req *http.Request, method string, path string) ([]byte, error) {
+	res, err := c.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error calling %v %v: %v", method, path, err)
+	}
+	defer res.Body.Close()
+	resBody, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response of %v %v: %v", method, path, err)
+	}
+	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusMultipleChoices {
+		return nil, NewAPIError(req, res.StatusCode, resBody)
+	}
+	return resBody, nil
+}
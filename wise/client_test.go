@@ -0,0 +1,79 @@
+package wise
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+type doFunc func(req *http.Request) (*http.Response, error)
+
+func (f doFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestClient(t *testing.T) {
+	var requests []*http.Request
+	var bodies []string
+	statuses := []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusOK}
+	httpClient := doFunc(func(req *http.Request) (*http.Response, error) {
+		body, _ := ioutil.ReadAll(req.Body)
+		requests, bodies = append(requests, req), append(bodies, string(body))
+		status := statuses[len(requests)-1]
+		return &http.Response{StatusCode: status, Body: ioutil.NopCloser(strings.NewReader(`{"id": "quote-1"}`))}, nil
+	})
+
+	var waits []time.Duration
+	client := NewClient("token", WithHost(HostSandbox), WithHTTPClient(httpClient), WithRetry(2, time.Second),
+		WithUserAgent("test/1.0"))
+	client.sleep = func(d time.Duration) { waits = append(waits, d) }
+
+	var quote struct {
+		Id string `json:"id"`
+	}
+	err := client.Call(http.MethodPost, "v2/quotes", nil, map[string]string{"sourceCurrency": "GBP"}, &quote)
+	assert.NoError(t, err)
+	assert.Equal(t, "quote-1", quote.Id)
+	assert.Len(t, requests, 3)
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second}, waits)
+	assert.Equal(t, "https://api.sandbox.transferwise.tech/v2/quotes", requests[0].URL.String())
+	assert.Equal(t, "Bearer token", requests[0].Header.Get("Authorization"))
+	assert.Equal(t, "test/1.0", requests[0].Header.Get("User-Agent"))
+	assert.Equal(t, `{"sourceCurrency":"GBP"}`, bodies[2], "body sent again on retries")
+
+	t.Run("no retries by default", func(t *testing.T) {
+		requests, statuses = nil, []int{http.StatusServiceUnavailable}
+		err := NewClient("token", WithHTTPClient(httpClient)).Call(http.MethodGet, "v1/rates", nil, nil, nil)
+		assert.IsType(t, &APIError{}, err)
+		assert.Equal(t, http.StatusServiceUnavailable, err.(*APIError).Status)
+		assert.Equal(t, HostProduction, requests[0].URL.Host)
+		assert.Equal(t, DefaultUserAgent, requests[0].Header.Get("User-Agent"))
+	})
+}
+
+func TestClientRaw(t *testing.T) {
+	var request *http.Request
+	var body string
+	status := http.StatusOK
+	client := NewClient("token", WithHTTPClient(doFunc(func(req *http.Request) (*http.Response, error) {
+		data, _ := ioutil.ReadAll(req.Body)
+		request, body = req, string(data)
+		return &http.Response{StatusCode: status, Body: ioutil.NopCloser(strings.NewReader(`[{"id": 1, "newField": true}]`))}, nil
+	})))
+
+	res, err := client.Raw(context.Background(), http.MethodPost, "/v3/profiles/1/transfers?status=processing", []byte(`{"a":1}`))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `[{"id": 1, "newField": true}]`, string(res))
+	assert.Equal(t, "https://api.transferwise.com/v3/profiles/1/transfers?status=processing", request.URL.String())
+	assert.Equal(t, `{"a":1}`, body)
+	assert.Equal(t, "Bearer token", request.Header.Get("Authorization"))
+
+	status = http.StatusNotFound
+	_, err = client.Raw(context.Background(), http.MethodGet, "v9/unknown", nil)
+	assert.IsType(t, &APIError{}, err)
+	assert.Equal(t, "/v9/unknown", err.(*APIError).Path)
+}
@@ -0,0 +1,472 @@
+// Package wisemock is an in-memory transferwise API serving the endpoints the batch calls, driven by a scenario,
+// for integration tests and local development without a sandbox account
+package wisemock
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// status of the transfers waiting for their payment, the only ones that can be cancelled, and of the funded ones
+const (
+	statusWaiting    = "incoming_payment_waiting"
+	statusProcessing = "processing"
+)
+
+// Scenario scripts the mock: the transfers booked to begin with, the live rates of each pair and the requests
+// answered with 429 Too Many Requests
+type Scenario struct {
+	Profile   uint64     `json:"profile"`
+	Transfers []Transfer `json:"transfers"`
+
+	// successive live rates by pair like GBP-INR, each live rate request moving to the next one, the last one staying.
+	// Quotes are at the last live rate served
+	Rates map[string][]float64 `json:"rates"`
+
+	// how long quotes lock their rate, like 2h, a negative one creating already expired quotes, defaults to 24h
+	QuoteTTL string `json:"quoteTtl"`
+
+	// numbers of the requests, counting from 1, answered with 429 Too Many Requests
+	Throttle []int `json:"throttle"`
+
+	// IDs of the transfers whose payment arrives as the next transfer is created, moving them to processing, like a
+	// transfer funded in the middle of its re-booking
+	FundOnCreate []uint64 `json:"fundOnCreate"`
+}
+
+type Transfer struct {
+	Id                    uint64          `json:"id"`
+	Profile               uint64          `json:"profile"`
+	TargetAccount         uint64          `json:"targetAccount"`
+	Rate                  float64         `json:"rate"`
+	Quote                 string          `json:"quote"`
+	Status                string          `json:"status"`
+	CustomerTransactionId string          `json:"customerTransactionId"`
+	SourceCurrency        string          `json:"sourceCurrency"`
+	TargetCurrency        string          `json:"targetCurrency"`
+	SourceValue           float64         `json:"sourceValue"`
+	TargetValue           float64         `json:"targetValue"`
+	Details               json.RawMessage `json:"details,omitempty"`
+}
+
+type Quote struct {
+	Id                 string  `json:"id"`
+	Rate               float64 `json:"rate"`
+	SourceAmount       float64 `json:"sourceAmount"`
+	TargetAmount       float64 `json:"targetAmount"`
+	SourceCurrency     string  `json:"sourceCurrency"`
+	TargetCurrency     string  `json:"targetCurrency"`
+	Profile            uint64  `json:"profile"`
+	RateExpirationTime string  `json:"rateExpirationTime"`
+}
+
+type errorResponse struct {
+	Errors []errorDetail `json:"errors"`
+}
+
+type errorDetail struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Server is the mock API, an http.Handler
+type Server struct {
+	sync.Mutex
+	scenario  Scenario
+	quoteTTL  time.Duration
+	transfers []Transfer
+	quotes    map[string]Quote
+	rateCalls map[string]int
+	requests  int
+	nextId    uint64
+	now       func() time.Time
+}
+
+// Load a scenario from a JSON file
+func LoadScenario(file string) (Scenario, error) {
+	var scenario Scenario
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return scenario, fmt.Errorf("error reading mock scenario: %v", err)
+	}
+	if err = json.Unmarshal(data, &scenario); err != nil {
+		return scenario, fmt.Errorf("error parsing mock scenario %v: %v", file, err)
+	}
+	return scenario, nil
+}
+
+// Default scenario of the --mock run mode: a GBP to INR transfer booked at 100 with the live rate rising past it
+func DefaultScenario() Scenario {
+	return Scenario{
+		Profile: 1,
+		Transfers: []Transfer{{Id: 1, Profile: 1, TargetAccount: 1, Rate: 100, Status: statusWaiting,
+			SourceCurrency: "GBP", TargetCurrency: "INR", SourceValue: 1000, TargetValue: 100000}},
+		Rates: map[string][]float64{"GBP-INR": {99.8, 100.1, 100.4, 100.9, 101.3, 100.7}},
+	}
+}
+
+func New(scenario Scenario) (*Server, error) {
+	quoteTTL := 24 * time.Hour
+	if scenario.QuoteTTL != "" {
+		var err error
+		if quoteTTL, err = time.ParseDuration(scenario.QuoteTTL); err != nil {
+			return nil, fmt.Errorf("invalid quoteTtl in mock scenario: %v", err)
+		}
+	}
+	s := &Server{scenario: scenario, quoteTTL: quoteTTL, quotes: map[string]Quote{}, rateCalls: map[string]int{}, now: time.Now}
+	for _, transfer := range scenario.Transfers {
+		if transfer.Quote == "" {
+			transfer.Quote = s.addQuote(Quote{Rate: transfer.Rate, SourceAmount: transfer.SourceValue, TargetAmount: transfer.TargetValue,
+				SourceCurrency: transfer.SourceCurrency, TargetCurrency: transfer.TargetCurrency, Profile: transfer.Profile})
+		}
+		s.transfers = append(s.transfers, transfer)
+		if transfer.Id > s.nextId {
+			s.nextId = transfer.Id
+		}
+	}
+	return s, nil
+}
+
+// Transfers as they are now, like after a re-booking
+func (s *Server) Transfers() []Transfer {
+	s.Lock()
+	defer s.Unlock()
+	return append([]Transfer(nil), s.transfers...)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.requests++
+	for _, n := range s.scenario.Throttle {
+		if n == s.requests {
+			w.Header().Set("Retry-After", "1")
+			writeError(w, http.StatusTooManyRequests, "RATE_LIMITED", "too many requests")
+			return
+		}
+	}
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+		writeError(w, http.StatusUnauthorized, "UNAUTHORIZED", "missing bearer token")
+		return
+	}
+
+	path := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/v1/profiles":
+		writeJSON(w, http.StatusOK, []map[string]interface{}{{"id": s.scenario.Profile, "type": "personal"}})
+	case r.Method == http.MethodGet && r.URL.Path == "/v1/currency-pairs":
+		s.getCurrencyPairs(w)
+	case r.Method == http.MethodGet && r.URL.Path == "/v1/rates":
+		s.getRates(w, r)
+	case r.Method == http.MethodGet && r.URL.Path == "/v1/transfers":
+		s.listTransfers(w, r, r.URL.Query().Get("profile"))
+	case r.Method == http.MethodGet && len(path) == 4 && path[0] == "v3" && path[1] == "profiles" && path[3] == "transfers":
+		s.listTransfers(w, r, path[2])
+	case r.Method == http.MethodGet && len(path) == 3 && path[0] == "v1" && path[1] == "transfers":
+		s.getTransfer(w, path[2])
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/transfers":
+		s.createTransfer(w, r)
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/transfer-requirements":
+		s.transferRequirements(w)
+	case r.Method == http.MethodPut && len(path) == 4 && path[0] == "v1" && path[1] == "transfers" && path[3] == "cancel":
+		s.cancelTransfer(w, path[2])
+	case r.Method == http.MethodPost && r.URL.Path == "/v2/quotes":
+		s.createQuote(w, r)
+	case r.Method == http.MethodGet && len(path) == 3 && path[0] == "v1" && path[1] == "delivery-estimates":
+		s.getDeliveryEstimate(w, path[2])
+	case r.Method == http.MethodGet && len(path) == 3 && path[0] == "v2" && path[1] == "quotes":
+		quote, ok := s.quotes[path[2]]
+		if !ok {
+			writeError(w, http.StatusNotFound, "NOT_FOUND", "quote not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, quote)
+	default:
+		writeError(w, http.StatusNotFound, "NOT_FOUND", r.Method+" "+r.URL.Path+" isn't mocked")
+	}
+}
+
+// Live rate, moving to the pair's next scripted rate, or the whole script as history when from and to are given
+func (s *Server) getRates(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	source, target := strings.ToUpper(query.Get("source")), strings.ToUpper(query.Get("target"))
+	pair := source + "-" + target
+	rates := s.scenario.Rates[pair]
+	if len(rates) == 0 {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "no rate for "+pair)
+		return
+	}
+
+	type rate struct {
+		Rate   float64 `json:"rate"`
+		Source string  `json:"source"`
+		Target string  `json:"target"`
+		Time   string  `json:"time"`
+	}
+	now := s.now().UTC()
+	if query.Get("from") != "" {
+		history := make([]rate, len(rates))
+		for i, value := range rates {
+			history[i] = rate{Rate: value, Source: source, Target: target,
+				Time: now.Add(time.Duration(i-len(rates)+1) * time.Hour).Format("2006-01-02T15:04:05-0700")}
+		}
+		writeJSON(w, http.StatusOK, history)
+		return
+	}
+	s.rateCalls[pair]++
+	writeJSON(w, http.StatusOK, []rate{{Rate: s.liveRate(pair), Source: source, Target: target, Time: now.Format("2006-01-02T15:04:05-0700")}})
+}
+
+// Currency pairs of the scripted rates and the booked transfers, the only routes the mock supports
+func (s *Server) getCurrencyPairs(w http.ResponseWriter) {
+	type currency struct {
+		CurrencyCode string `json:"currencyCode"`
+	}
+	type sourceCurrency struct {
+		CurrencyCode     string     `json:"currencyCode"`
+		TargetCurrencies []currency `json:"targetCurrencies"`
+	}
+	routes := map[string][]string{}
+	var sources []string
+	add := func(source string, target string) {
+		if _, ok := routes[source]; !ok {
+			sources = append(sources, source)
+		}
+		for _, existing := range routes[source] {
+			if existing == target {
+				return
+			}
+		}
+		routes[source] = append(routes[source], target)
+	}
+	for pair := range s.scenario.Rates {
+		if currencies := strings.Split(pair, "-"); len(currencies) == 2 {
+			add(currencies[0], currencies[1])
+		}
+	}
+	for _, transfer := range s.transfers {
+		add(transfer.SourceCurrency, transfer.TargetCurrency)
+	}
+	sort.Strings(sources)
+
+	response := struct {
+		SourceCurrencies []sourceCurrency `json:"sourceCurrencies"`
+		Total            int              `json:"total"`
+	}{SourceCurrencies: []sourceCurrency{}}
+	for _, source := range sources {
+		targets := routes[source]
+		sort.Strings(targets)
+		entry := sourceCurrency{CurrencyCode: source}
+		for _, target := range targets {
+			entry.TargetCurrencies = append(entry.TargetCurrencies, currency{CurrencyCode: target})
+		}
+		response.SourceCurrencies = append(response.SourceCurrencies, entry)
+		response.Total += len(targets)
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (s *Server) liveRate(pair string) float64 {
+	rates := s.scenario.Rates[pair]
+	if len(rates) == 0 {
+		return 0
+	}
+	i := s.rateCalls[pair] - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(rates) {
+		i = len(rates) - 1
+	}
+	return rates[i]
+}
+
+// List the transfers most recent first, of the given profile if not empty
+func (s *Server) listTransfers(w http.ResponseWriter, r *http.Request, profile string) {
+	query := r.URL.Query()
+	statuses := map[string]bool{}
+	for _, status := range strings.Split(query.Get("status"), ",") {
+		if status != "" {
+			statuses[status] = true
+		}
+	}
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	offset, _ := strconv.Atoi(query.Get("offset"))
+
+	// most recent first, like transferwise
+	transfers := []Transfer{}
+	for i := len(s.transfers) - 1; i >= 0; i-- {
+		if profile != "" && profile != strconv.FormatUint(s.transfers[i].Profile, 10) {
+			continue
+		}
+		if len(statuses) == 0 || statuses[s.transfers[i].Status] {
+			transfers = append(transfers, s.transfers[i])
+		}
+	}
+	if offset > len(transfers) {
+		offset = len(transfers)
+	}
+	transfers = transfers[offset:]
+	if limit > 0 && limit < len(transfers) {
+		transfers = transfers[:limit]
+	}
+	writeJSON(w, http.StatusOK, transfers)
+}
+
+func (s *Server) createQuote(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		SourceCurrency string  `json:"sourceCurrency"`
+		TargetCurrency string  `json:"targetCurrency"`
+		SourceAmount   float64 `json:"sourceAmount"`
+		TargetAmount   float64 `json:"targetAmount"`
+		Profile        uint64  `json:"profile"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_JSON", err.Error())
+		return
+	}
+	if (request.SourceAmount > 0) == (request.TargetAmount > 0) {
+		writeError(w, http.StatusUnprocessableEntity, "INVALID_AMOUNT", "exactly one of sourceAmount and targetAmount is required")
+		return
+	}
+
+	rate := s.liveRate(strings.ToUpper(request.SourceCurrency + "-" + request.TargetCurrency))
+	if rate == 0 {
+		writeError(w, http.StatusUnprocessableEntity, "UNSUPPORTED_ROUTE", "no rate for the currency pair")
+		return
+	}
+	quote := Quote{Rate: rate, SourceAmount: request.SourceAmount, TargetAmount: request.TargetAmount,
+		SourceCurrency: request.SourceCurrency, TargetCurrency: request.TargetCurrency, Profile: request.Profile}
+	if quote.SourceAmount > 0 {
+		quote.TargetAmount = round(quote.SourceAmount * rate)
+	} else {
+		quote.SourceAmount = round(quote.TargetAmount / rate)
+	}
+	id := s.addQuote(quote)
+	writeJSON(w, http.StatusOK, s.quotes[id])
+}
+
+func (s *Server) addQuote(quote Quote) string {
+	quote.Id = fmt.Sprintf("00000000-0000-0000-0000-%012d", len(s.quotes)+1)
+	quote.RateExpirationTime = s.now().UTC().Add(s.quoteTTL).Format(time.RFC3339)
+	s.quotes[quote.Id] = quote
+	return quote.Id
+}
+
+func (s *Server) createTransfer(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		TargetAccount         uint64          `json:"targetAccount"`
+		QuoteUuid             string          `json:"quoteUuid"`
+		CustomerTransactionId string          `json:"customerTransactionId"`
+		Details               json.RawMessage `json:"details"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_JSON", err.Error())
+		return
+	}
+	quote, ok := s.quotes[request.QuoteUuid]
+	if !ok {
+		writeError(w, http.StatusUnprocessableEntity, "QUOTE_NOT_FOUND", "quote not found")
+		return
+	}
+	if expiry, _ := time.Parse(time.RFC3339, quote.RateExpirationTime); !s.now().Before(expiry) {
+		writeError(w, http.StatusUnprocessableEntity, "QUOTE_EXPIRED", "the quote has expired, create a new one")
+		return
+	}
+
+	// the same customerTransactionId creates the transfer only once, like transferwise
+	for _, transfer := range s.transfers {
+		if request.CustomerTransactionId != "" && transfer.CustomerTransactionId == request.CustomerTransactionId {
+			writeJSON(w, http.StatusOK, transfer)
+			return
+		}
+	}
+	s.nextId++
+	transfer := Transfer{Id: s.nextId, Profile: quote.Profile, TargetAccount: request.TargetAccount, Rate: quote.Rate,
+		Quote: quote.Id, Status: statusWaiting, CustomerTransactionId: request.CustomerTransactionId,
+		SourceCurrency: quote.SourceCurrency, TargetCurrency: quote.TargetCurrency, SourceValue: quote.SourceAmount,
+		TargetValue: quote.TargetAmount, Details: request.Details}
+	for i := range s.transfers {
+		for _, id := range s.scenario.FundOnCreate {
+			if s.transfers[i].Id == id && s.transfers[i].Status == statusWaiting {
+				s.transfers[i].Status = statusProcessing
+			}
+		}
+	}
+	s.scenario.FundOnCreate = nil
+	s.transfers = append(s.transfers, transfer)
+	writeJSON(w, http.StatusOK, transfer)
+}
+
+func (s *Server) getTransfer(w http.ResponseWriter, id string) {
+	for _, transfer := range s.transfers {
+		if strconv.FormatUint(transfer.Id, 10) == id {
+			writeJSON(w, http.StatusOK, transfer)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "NOT_FOUND", "transfer not found")
+}
+
+// Delivery a day from now, like a typical bank transfer route
+func (s *Server) getDeliveryEstimate(w http.ResponseWriter, id string) {
+	for _, transfer := range s.transfers {
+		if strconv.FormatUint(transfer.Id, 10) == id {
+			writeJSON(w, http.StatusOK, map[string]string{
+				"estimatedDeliveryDate": s.now().UTC().Add(24 * time.Hour).Format(time.RFC3339),
+			})
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "NOT_FOUND", "transfer not found")
+}
+
+// A required reference, like transferwise asks for on most routes
+func (s *Server) transferRequirements(w http.ResponseWriter) {
+	writeJSON(w, http.StatusOK, []map[string]interface{}{{
+		"type": "transfer",
+		"fields": []map[string]interface{}{{
+			"name":  "Transfer reference",
+			"group": []map[string]interface{}{{"key": "reference", "name": "Transfer reference", "required": true}},
+		}},
+	}})
+}
+
+func (s *Server) cancelTransfer(w http.ResponseWriter, id string) {
+	for i := range s.transfers {
+		if strconv.FormatUint(s.transfers[i].Id, 10) != id {
+			continue
+		}
+		if s.transfers[i].Status != statusWaiting {
+			writeError(w, http.StatusConflict, "TRANSFER_NOT_CANCELLABLE", "only transfers waiting for their payment can be cancelled")
+			return
+		}
+		s.transfers[i].Status = "cancelled"
+		writeJSON(w, http.StatusOK, s.transfers[i])
+		return
+	}
+	writeError(w, http.StatusNotFound, "NOT_FOUND", "transfer not found")
+}
+
+func round(amount float64) float64 {
+	rounded, _ := strconv.ParseFloat(strconv.FormatFloat(amount, 'f', 2, 64), 64)
+	return rounded
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, errorCode string, message string) {
+	writeJSON(w, code, errorResponse{Errors: []errorDetail{{Code: errorCode, Message: message}}})
+}
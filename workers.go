@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// check actions from the most to the least significant, the summary of a cycle over several pairs taking the most
+// significant action of its pairs
+var checkActionRanks = []string{checkActionRebooked, checkActionProposed, checkActionError, checkActionRebookSkipped,
+	checkActionDeferred, checkActionNoAction, checkActionNotDue}
+
+// held while re-booking, so the guardrails see the re-bookings of the pairs checked concurrently
+var rebookMutex sync.Mutex
+
+// one mutex per pair, so the same pair is never checked, and its transfer re-booked, twice at once
+var pairLocks = struct {
+	sync.Mutex
+	byPair map[string]*sync.Mutex
+}{byPair: map[string]*sync.Mutex{}}
+
+func lockPair(pair string) (unlock func()) {
+	pairLocks.Lock()
+	lock, ok := pairLocks.byPair[pair]
+	if !ok {
+		lock = &sync.Mutex{}
+		pairLocks.byPair[pair] = lock
+	}
+	pairLocks.Unlock()
+	lock.Lock()
+	return lock.Unlock
+}
+
+// The booked transfer of every pair, the one with the best rate when a pair has several, sorted by pair
+func getBookedTransfers() ([]Transfer, error) {
+	statuses, err := getTrackedStatuses()
+	if err != nil {
+		return nil, fmt.Errorf("getBookedTransfers: %w", err)
+	}
+	transfersList, err := listAllTransfers(strings.Join(statuses, ","))
+	if err != nil {
+		return nil, fmt.Errorf("getBookedTransfers: %w", err)
+	}
+	transfersList = withoutPinned(transfersList)
+	if len(transfersList) == 0 {
+		return nil, ErrNoTrackedTransfer
+	}
+
+	byPair := map[string][]Transfer{}
+	for _, transfer := range transfersList {
+		pair := pairKey(transfer.SourceCurrency, transfer.TargetCurrency)
+		byPair[pair] = append(byPair[pair], transfer)
+	}
+	pairs := make([]string, 0, len(byPair))
+	for pair := range byPair {
+		pairs = append(pairs, pair)
+	}
+	sort.Strings(pairs)
+	transfers := make([]Transfer, len(pairs))
+	for i, pair := range pairs {
+		transfers[i] = findBestTransfer(byPair[pair])
+	}
+	return transfers, nil
+}
+
+// Check the transfers of several pairs with up to CHECK_WORKERS at once, logging a summary once all are done
+func checkPairs(transfers []Transfer) []CheckResult {
+	workers, err := getCheckWorkers()
+	if err != nil {
+		log.Printf("checkPairs: %v", err)
+		workers = 1
+	}
+	if workers > len(transfers) {
+		workers = len(transfers)
+	}
+
+	start := time.Now()
+	results := make([]CheckResult, len(transfers))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				transfer := transfers[i]
+				span := startSpan("checkPair", spanKindInternal)
+				results[i] = checkTransfer(transfer, span)
+				span.SetError(checkError(results[i]))
+				span.End()
+			}
+		}()
+	}
+	for i := range transfers {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	counts := map[string]int{}
+	for _, result := range results {
+		counts[result.Action]++
+	}
+	summary := make([]string, 0, len(counts))
+	for _, action := range checkActionRanks {
+		if counts[action] > 0 {
+			summary = append(summary, fmt.Sprintf("%v: %v", action, counts[action]))
+		}
+	}
+	log.Printf("|| CHECK SUMMARY || Pairs: %v | Workers: %v | %v | Took: %v ||", len(transfers), workers,
+		strings.Join(summary, " | "), time.Since(start).Round(time.Millisecond))
+	return results
+}
+
+// The outcome of a cycle over several pairs: the most significant action of its pairs, along with each of them
+func summarizeChecks(results []CheckResult) CheckResult {
+	summary := CheckResult{Pairs: results}
+	rank := len(checkActionRanks)
+	for _, result := range results {
+		for i, action := range checkActionRanks {
+			if result.Action == action && i < rank {
+				rank = i
+				summary.Action, summary.Error = result.Action, result.Error
+			}
+		}
+	}
+	return summary
+}
+
+func checkError(check CheckResult) error {
+	if check.Error == "" {
+		return nil
+	}
+	return fmt.Errorf("%v", check.Error)
+}
+
+func getCheckWorkers() (int, error) {
+	workers, err := strconv.ParseUint(checkWorkersVar, 10, 64)
+	if err != nil || workers == 0 {
+		return 0, fmt.Errorf("invalid value for CHECK_WORKERS: %v, expected at least 1", checkWorkersVar)
+	}
+	return int(workers), nil
+}